@@ -198,6 +198,11 @@ func main() {
 		return
 	}
 
+	// The configuration is reloaded from the same config file and CLI flags.
+	t.ConfigReloader = func() (mimir.Config, error) {
+		return reloadConfig(configFile, expandEnv, os.Args[1:])
+	}
+
 	level.Info(util_log.Logger).Log("msg", "Starting application", "version", version.Info())
 
 	err = t.Run()
@@ -227,6 +232,34 @@ func parseConfigFileParameter(args []string) (configFile string, expandEnv bool)
 	return
 }
 
+// reloadConfig reads the configuration again as at startup: the default values, overridden by the
+// config file, overridden by the CLI flags.
+func reloadConfig(configFile string, expandEnv bool, args []string) (mimir.Config, error) {
+	var (
+		cfg       mimir.Config
+		mainFlags mainFlags
+	)
+
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cfg.RegisterFlags(fs, util_log.Logger)
+
+	if configFile != "" {
+		if err := LoadConfig(configFile, expandEnv, &cfg); err != nil {
+			return mimir.Config{}, err
+		}
+	}
+
+	flagext.IgnoredFlag(fs, configFileOption, "Configuration file to load.")
+	_ = fs.Bool(configExpandEnv, false, "Expands ${var} or $var in config according to the values of the environment variables.")
+	mainFlags.registerFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return mimir.Config{}, errors.Wrap(err, "Error parsing CLI flags")
+	}
+	return cfg, nil
+}
+
 // LoadConfig read YAML-formatted config from filename into cfg.
 func LoadConfig(filename string, expandEnv bool, cfg *mimir.Config) error {
 	buf, err := ioutil.ReadFile(filename)
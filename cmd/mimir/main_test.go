@@ -14,6 +14,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/stretchr/testify/assert"
@@ -368,3 +369,32 @@ func TestFieldCategoryOverridesNotStale(t *testing.T) {
 
 	require.Empty(t, overrides, "There are category overrides for configuration options that no longer exist")
 }
+
+func TestReloadConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+ruler:
+  alertmanager_url: http://alertmanager-1
+  poll_interval: 5m
+`), 0600))
+
+	args := []string{"-config.file", configFile, "-ruler.poll-interval=2m", "-mem-ballast-size-bytes=1024"}
+	cfg, err := reloadConfig(configFile, false, args)
+	require.NoError(t, err)
+	assert.Equal(t, "http://alertmanager-1", cfg.Ruler.AlertmanagerURL)
+	// The CLI flags override the config file.
+	assert.Equal(t, 2*time.Minute, cfg.Ruler.PollInterval)
+
+	// The changes of the config file are read.
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+ruler:
+  alertmanager_url: http://alertmanager-2
+`), 0600))
+	cfg, err = reloadConfig(configFile, false, args)
+	require.NoError(t, err)
+	assert.Equal(t, "http://alertmanager-2", cfg.Ruler.AlertmanagerURL)
+
+	require.NoError(t, os.WriteFile(configFile, []byte(`unknown: true`), 0600))
+	_, err = reloadConfig(configFile, false, args)
+	require.Error(t, err)
+}
@@ -191,7 +191,7 @@ func getFlagName(fl *flag.Flag) string {
 	// Check custom types.
 	if v := reflect.ValueOf(fl.Value); v.IsValid() {
 		switch v.Type().String() {
-		case "*flagext.Secret":
+		case "*flagext.Secret", "*validation.Secret":
 			return "string"
 		}
 	}
@@ -35,6 +35,7 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/purger"
 	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/querier/evaluatorpb"
 	"github.com/grafana/mimir/pkg/ruler"
 	"github.com/grafana/mimir/pkg/scheduler"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
@@ -50,6 +51,7 @@ type ConfigHandler func(actualCfg interface{}, defaultCfg interface{}) http.Hand
 
 type Config struct {
 	SkipLabelNameValidationHeader bool `yaml:"skip_label_name_validation_header_enabled" category:"advanced"`
+	ReloadEndpointEnabled         bool `yaml:"reload_endpoint_enabled" category:"experimental"`
 
 	AlertmanagerHTTPPrefix string `yaml:"alertmanager_http_prefix" category:"advanced"`
 	PrometheusHTTPPrefix   string `yaml:"prometheus_http_prefix" category:"advanced"`
@@ -72,6 +74,7 @@ type Config struct {
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.SkipLabelNameValidationHeader, "api.skip-label-name-validation-header-enabled", false, "Allows to skip label name validation via X-Mimir-SkipLabelNameValidation header on the http write path. Use with caution as it breaks PromQL. Allowing this for external clients allows any client to send invalid label names. After enabling it, requests with a specific HTTP header set to true will not have label names validated.")
+	f.BoolVar(&cfg.ReloadEndpointEnabled, "api.reload-endpoint-enabled", false, "Enable the POST /-/reload endpoint, which reloads the configuration like the SIGHUP signal. The endpoint isn't authenticated, so enable it only when it can't be reached by untrusted clients.")
 	cfg.RegisterFlagsWithPrefix("", f)
 }
 
@@ -284,52 +287,84 @@ func (a *API) RegisterTenantDeletion(api *purger.TenantDeletionAPI) {
 func (a *API) RegisterRuler(r *ruler.Ruler) {
 	a.indexPage.AddLinks(defaultWeight, "Ruler", []IndexPageLink{
 		{Desc: "Ring status", Path: "/ruler/ring"},
+		{Desc: "Tenant rules", Path: "/ruler/rules"},
+		{Desc: "Tenant alerts", Path: "/ruler/alerts"},
 	})
 	a.RegisterRoute("/ruler/ring", r, false, true, "GET", "POST")
 
+	// Web pages with the rules and alerts of the authenticated tenant.
+	a.RegisterRoute("/ruler/rules", http.HandlerFunc(r.RulesPageHandler), true, true, "GET")
+	a.RegisterRoute("/ruler/alerts", http.HandlerFunc(r.AlertsPageHandler), true, true, "GET")
+
 	// Administrative API, uses authentication to inform which user's configuration to delete.
 	a.RegisterRoute("/ruler/delete_tenant_config", http.HandlerFunc(r.DeleteTenantConfiguration), true, true, "POST")
 
 	// List all user rule groups
 	a.RegisterRoute("/ruler/rule_groups", http.HandlerFunc(r.ListAllRules), false, true, "GET")
 
+	// List the rule groups skipped by the ruler because they can't be loaded
+	a.RegisterRoute("/ruler/broken_groups", http.HandlerFunc(r.BrokenRuleGroupsHandler), false, true, "GET")
+
 	ruler.RegisterRulerServer(a.server.GRPC, r)
 }
 
 // RegisterRulerAPI registers routes associated with the Ruler API
-func (a *API) RegisterRulerAPI(r *ruler.API, configAPIEnabled bool) {
+func (a *API) RegisterRulerAPI(r *ruler.API, configAPIEnabled bool, buildInfoHandler, flagsHandler http.Handler) {
 	// Prometheus Rule API Routes
 	// We want to always enable these. They are read-only. Also if using local storage as rule storage,
 	// you would like the API to be disabled and still be able to understand in what state rule evaluations are.
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/rules"), http.HandlerFunc(r.PrometheusRules), true, true, "GET")
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/alerts"), http.HandlerFunc(r.PrometheusAlerts), true, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/alerts/history"), http.HandlerFunc(r.PrometheusAlertsHistory), true, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/rules/health"), http.HandlerFunc(r.PrometheusRulesHealth), true, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/federation/consents"), r.AuthorizeToken(r.AdminOverride(r.GetFederationConsents)), true, true, "GET")
+
+	// Prometheus status API routes, probed by the tooling checking the health of a Prometheus datasource.
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/status/buildinfo"), buildInfoHandler, false, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/status/flags"), flagsHandler, true, true, "GET")
 
 	if configAPIEnabled {
 		// Ruler API Routes
 		// TODO remove the /api/v1/rules/** endpoints in Mimir 2.2.0 as agreed in https://github.com/grafana/mimir/pull/763#discussion_r808270581
-		a.RegisterDeprecatedRoute("/api/v1/rules", http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}/{groupName}", http.HandlerFunc(r.GetRuleGroup), true, true, "GET")
-		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.CreateRuleGroup), true, true, "POST")
-		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}/{groupName}", http.HandlerFunc(r.DeleteRuleGroup), true, true, "DELETE")
-		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.DeleteNamespace), true, true, "DELETE")
+		a.RegisterDeprecatedRoute("/api/v1/rules", r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}/{groupName}", r.AuthorizeToken(r.AdminOverride(r.GetRuleGroup)), true, true, "GET", "HEAD")
+		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.CreateRuleGroup))), true, true, "POST")
+		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}/{groupName}", r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteRuleGroup))), true, true, "DELETE")
+		a.RegisterDeprecatedRoute("/api/v1/rules/{namespace}", r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteNamespace))), true, true, "DELETE")
 
 		// Configuration endpoints with Prometheus prefix, so we keep Prometheus-compatible EPs and config EPs under the same prefix.
 		// TODO remove the <prometheus-http-prefix>/v1/rules/** endpoints in Mimir 2.2.0 as agreed in https://github.com/grafana/mimir/pull/1222#issuecomment-1046759965
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules"), http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}/{groupName}"), http.HandlerFunc(r.GetRuleGroup), true, true, "GET")
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), http.HandlerFunc(r.CreateRuleGroup), true, true, "POST")
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}/{groupName}"), http.HandlerFunc(r.DeleteRuleGroup), true, true, "DELETE")
-		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), http.HandlerFunc(r.DeleteNamespace), true, true, "DELETE")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules"), r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}/{groupName}"), r.AuthorizeToken(r.AdminOverride(r.GetRuleGroup)), true, true, "GET", "HEAD")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.CreateRuleGroup))), true, true, "POST")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}/{groupName}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteRuleGroup))), true, true, "DELETE")
+		a.RegisterDeprecatedRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteNamespace))), true, true, "DELETE")
 
 		// Long-term maintained configuration API routes
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules"), http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.ListRules), true, true, "GET")
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), http.HandlerFunc(r.GetRuleGroup), true, true, "GET")
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.CreateRuleGroup), true, true, "POST")
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), http.HandlerFunc(r.DeleteRuleGroup), true, true, "DELETE")
-		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.DeleteNamespace), true, true, "DELETE")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules"), r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_namespaces"), r.AuthorizeToken(r.AdminOverride(r.ListRuleNamespaces)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_search"), r.AuthorizeToken(r.AdminOverride(r.SearchRules)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_metric_usage"), r.AuthorizeToken(r.AdminOverride(r.MetricUsage)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_graph"), r.AuthorizeToken(r.AdminOverride(r.RuleGraph)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_template_preview"), r.AuthorizeToken(r.AdminOverride(r.PreviewRuleGroupTemplate)), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_import/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.ImportRuleGroups))), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.ListRules)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), r.AuthorizeToken(r.AdminOverride(r.GetRuleGroup)), true, true, "GET", "HEAD")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.CreateRuleGroup))), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteRuleGroup))), true, true, "DELETE")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteNamespace))), true, true, "DELETE")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/delete"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.DeleteRuleGroups))), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/status"), r.AuthorizeToken(r.AdminOverride(r.GetRuleGroupStatus)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/evaluate"), r.AuthorizeToken(r.AdminOverride(r.EvaluateRuleGroup)), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rule_trash"), r.AuthorizeToken(r.AdminOverride(r.ListTrashedRuleGroups)), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/restore"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.RestoreNamespace))), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/restore"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.RestoreRuleGroup))), true, true, "POST")
+
+		// Federation consents, granted by the source tenants of the federated rule groups.
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/federation/consents"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.GrantFederationConsents))), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/federation/consents/{consumerTenant}"), r.AuthorizeToken(r.AdminOverride(r.RateLimitWrites(r.RevokeFederationConsent))), true, true, "DELETE")
 	}
 }
 
@@ -392,6 +427,11 @@ func (a *API) RegisterQueryAPI(handler http.Handler, buildInfoHandler http.Handl
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/cardinality/label_values"), handler, true, true, "GET", "POST")
 }
 
+// RegisterRuleEvaluator registers the gRPC service used by the rulers to evaluate the rules on the querier.
+func (a *API) RegisterRuleEvaluator(e *querier.RuleEvaluator) {
+	evaluatorpb.RegisterEvaluatorServer(a.server.GRPC, e)
+}
+
 // RegisterQueryFrontend registers the Prometheus routes supported by the
 // Mimir querier service. Currently this can not be registered simultaneously
 // with the Querier.
@@ -422,6 +462,14 @@ func (a *API) RegisterServiceMapHandler(handler http.Handler) {
 	a.RegisterRoute("/services", handler, false, true, "GET")
 }
 
+// RegisterConfigReload registers the endpoint reloading the configuration, if enabled.
+func (a *API) RegisterConfigReload(handler http.Handler) {
+	if !a.cfg.ReloadEndpointEnabled {
+		return
+	}
+	a.RegisterRoute("/-/reload", handler, false, true, "POST")
+}
+
 func (a *API) RegisterMemberlistKV(handler http.Handler) {
 	a.indexPage.AddLinks(memberlistWeight, "Memberlist", []IndexPageLink{
 		{Desc: "Status", Path: "/memberlist"},
@@ -6,6 +6,8 @@
 package api
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -62,3 +64,21 @@ func TestNewApiWithInvalidSourceIPExtractor(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, api)
 }
+
+func TestRegisterConfigReload(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		s := server.Server{
+			HTTP: mux.NewRouter(),
+		}
+		api, err := New(Config{ReloadEndpointEnabled: enabled}, server.Config{}, &s, &FakeLogger{})
+		require.NoError(t, err)
+
+		reloaded := false
+		api.RegisterConfigReload(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			reloaded = true
+		}))
+
+		s.HTTP.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+		require.Equal(t, enabled, reloaded)
+	}
+}
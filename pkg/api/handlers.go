@@ -8,6 +8,7 @@ package api
 import (
 	"context"
 	"embed"
+	"flag"
 	"html/template"
 	"net/http"
 	"path"
@@ -23,7 +24,6 @@ import (
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/route"
 	"github.com/prometheus/prometheus/config"
-	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/storage"
 	v1 "github.com/prometheus/prometheus/web/api/v1"
 	"github.com/weaveworks/common/instrument"
@@ -158,6 +158,28 @@ func DefaultConfigHandler(actualCfg interface{}, defaultCfg interface{}) http.Ha
 	}
 }
 
+// FlagsHandler returns a HTTP handler serving the values of the flags of the flag set, in the format
+// of the Prometheus /api/v1/status/flags endpoint. Only the flags listed in names are served, since
+// the flag set can include credentials. The names missing from the flag set are skipped.
+func FlagsHandler(fs *flag.FlagSet, names []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		flags := map[string]string{}
+		for _, name := range names {
+			if f := fs.Lookup(name); f != nil {
+				flags[name] = f.Value.String()
+			}
+		}
+
+		util.WriteJSONResponse(w, struct {
+			Status string            `json:"status"`
+			Data   map[string]string `json:"data"`
+		}{
+			Status: "success",
+			Data:   flags,
+		})
+	}
+}
+
 // NewQuerierHandler returns a HTTP handler that can be used by the querier service to
 // either register with the frontend worker query processor or with the external HTTP
 // server to fulfill the Prometheus query API.
@@ -165,7 +187,7 @@ func NewQuerierHandler(
 	cfg Config,
 	queryable storage.SampleAndChunkQueryable,
 	exemplarQueryable storage.ExemplarQueryable,
-	engine *promql.Engine,
+	engine v1.QueryEngine,
 	distributor Distributor,
 	reg prometheus.Registerer,
 	logger log.Logger,
@@ -6,12 +6,14 @@
 package api
 
 import (
+	"flag"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -198,3 +200,31 @@ func TestConfigOverrideHandler(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("config"), body)
 }
+
+func TestFlagsHandler(t *testing.T) {
+	var password flagext.Secret
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	fs.String("ruler.external.url", "", "")
+	fs.Int("ruler.evaluation-shards", 1, "")
+	fs.Var(&password, "ruler.alertmanager-client.basic-auth-password", "")
+	fs.String("ruler.query-backend-bearer-token", "", "")
+	require.NoError(t, fs.Parse([]string{
+		"-ruler.external.url=http://grafana",
+		"-ruler.alertmanager-client.basic-auth-password=secret",
+		"-ruler.query-backend-bearer-token=token",
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/status/flags", nil)
+	resp := httptest.NewRecorder()
+	FlagsHandler(fs, []string{"ruler.external.url", "ruler.evaluation-shards", "ruler.missing"}).ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"status": "success",
+		"data": {
+			"ruler.external.url": "http://grafana",
+			"ruler.evaluation-shards": "1"
+		}
+	}`, resp.Body.String())
+}
@@ -271,10 +271,18 @@ func writeServiceTimingHeader(queryResponseTime time.Duration, headers http.Head
 		parts := make([]string, 0)
 		parts = append(parts, statsValue("querier_wall_time", stats.LoadWallTime()))
 		parts = append(parts, statsValue("response_time", queryResponseTime))
+		parts = append(parts, statsCount("fetched_series_count", stats.LoadFetchedSeries()))
+		parts = append(parts, statsCount("fetched_chunks_count", stats.LoadFetchedChunks()))
+		parts = append(parts, statsCount("fetched_chunk_bytes", stats.LoadFetchedChunkBytes()))
 		headers.Set(ServiceTimingHeaderName, strings.Join(parts, ", "))
 	}
 }
 
+// statsCount returns a Server-Timing metric reporting a count, which is not a duration, in its description.
+func statsCount(name string, count uint64) string {
+	return name + ";desc=" + strconv.FormatUint(count, 10)
+}
+
 func statsValue(name string, d time.Duration) string {
 	durationInMs := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
 	return name + ";dur=" + durationInMs
@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
@@ -21,6 +22,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 )
 
 type roundTripperFunc func(*http.Request) (*http.Response, error)
@@ -97,3 +100,15 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteServiceTimingHeader(t *testing.T) {
+	stats := &querier_stats.Stats{}
+	stats.AddWallTime(1500 * time.Millisecond)
+	stats.AddFetchedSeries(10)
+	stats.AddFetchedChunks(20)
+	stats.AddFetchedChunkBytes(3000)
+
+	headers := http.Header{}
+	writeServiceTimingHeader(2*time.Second, headers, stats)
+	assert.Equal(t, "querier_wall_time;dur=1500, response_time;dur=2000, fetched_series_count;desc=10, fetched_chunks_count;desc=20, fetched_chunk_bytes;desc=3000", headers.Get(ServiceTimingHeaderName))
+}
@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/runtimeconfig"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/rules"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/ruler"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// ReloadConfig reads the configuration again with ConfigReloader, and applies the settings which can be
+// changed without restarting, keeping the state of the alerts and the evaluations of the rules:
+//   - the Alertmanager notifier settings of the ruler;
+//   - the ruler storage settings, by replacing the client of the rule store;
+//   - the runtime configuration settings, by reading the limits of the tenants from the new file.
+//
+// The changes of the other settings of the ruler are logged, because they require a restart.
+func (t *Mimir) ReloadConfig() error {
+	t.configReloadMtx.Lock()
+	defer t.configReloadMtx.Unlock()
+
+	if t.ConfigReloader == nil {
+		return errors.New("the configuration can't be reloaded")
+	}
+	cfg, err := t.ConfigReloader()
+	if err != nil {
+		return errors.Wrap(err, "failed to read the configuration")
+	}
+	if err := cfg.Validate(util_log.Logger); err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+	// Set as at the initialization of the ruler.
+	cfg.Ruler.Ring.ListenPort = cfg.Server.GRPCListenPort
+
+	if t.Ruler != nil {
+		if err := t.Ruler.ApplyNotifierConfig(cfg.Ruler); err != nil {
+			return err
+		}
+	}
+	t.Cfg.Ruler = t.Cfg.Ruler.WithNotifierConfig(cfg.Ruler)
+
+	if err := t.reloadRulerStorage(cfg.RulerStorage); err != nil {
+		return errors.Wrap(err, "failed to reload the ruler storage")
+	}
+	if err := t.reloadRuntimeConfig(cfg.RuntimeConfig); err != nil {
+		return errors.Wrap(err, "failed to reload the runtime configuration")
+	}
+
+	var changed []string
+	for name, sections := range map[string][2]interface{}{
+		"ruler":          {t.Cfg.Ruler, cfg.Ruler},
+		"ruler_storage":  {t.Cfg.RulerStorage, cfg.RulerStorage},
+		"runtime_config": {t.Cfg.RuntimeConfig, cfg.RuntimeConfig},
+	} {
+		sectionChanged, err := changedSettings(name, sections[0], sections[1])
+		if err != nil {
+			return err
+		}
+		changed = append(changed, sectionChanged...)
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		level.Warn(util_log.Logger).Log("msg", "the changes of some settings aren't applied by the configuration reload and require a restart", "settings", strings.Join(changed, ","))
+	}
+
+	level.Info(util_log.Logger).Log("msg", "configuration reloaded")
+	return nil
+}
+
+// reloadRulerStorage replaces the rule store by a store created with cfg, if it differs from the current
+// configuration. The rule groups are read from and written to the new store once it's created, while the
+// operations in progress complete with the replaced store. The buckets of the federation consents, the
+// audit log and the alert history keep the client created at startup.
+func (t *Mimir) reloadRulerStorage(cfg rulestore.Config) error {
+	// The ruler storage is set up at startup, its changes are logged as requiring a restart otherwise.
+	if t.rulerStorage == nil {
+		return nil
+	}
+	changed, err := changedSettings("ruler_storage", t.Cfg.RulerStorage, cfg)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	// The metrics of the new store replace the ones of the replaced store.
+	t.rulerStorageReg.unregisterAll()
+	reg := newReloadRegisterer(prometheus.DefaultRegisterer)
+	store, err := ruler.NewRuleStore(context.Background(), cfg, t.Overrides, rules.FileLoader{}, util_log.Logger, reg)
+	if err == nil {
+		err = t.rulerStorage.Reload(store)
+	}
+	if err != nil {
+		reg.unregisterAll()
+		t.rulerStorageReg.registerAll()
+		return err
+	}
+
+	t.rulerStorageReg = reg
+	t.Cfg.RulerStorage = cfg
+	level.Info(util_log.Logger).Log("msg", "ruler storage reloaded", "settings", strings.Join(changed, ","))
+	return nil
+}
+
+// reloadRuntimeConfig starts a runtime config manager with cfg, if it differs from the current configuration,
+// and reads the limits of the tenants from it. The other users of the runtime configuration, like the
+// multi KV client of the rings, keep reading the file configured at startup.
+func (t *Mimir) reloadRuntimeConfig(cfg runtimeconfig.Config) error {
+	// Enabling or disabling the runtime configuration is logged as requiring a restart.
+	tenantLimits, ok := t.TenantLimits.(*runtimeConfigTenantLimits)
+	if t.RuntimeConfig == nil || t.RuntimeConfig.State() != services.Running || !ok || cfg.LoadPath == "" {
+		return nil
+	}
+	changed, err := changedSettings("runtime_config", t.Cfg.RuntimeConfig, cfg)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	// The metrics of the new manager replace the ones of the replaced manager.
+	t.runtimeConfigReg.unregisterAll()
+	reg := newReloadRegisterer(prometheus.WrapRegistererWithPrefix("cortex_", prometheus.DefaultRegisterer))
+	cfg.Loader = loadRuntimeConfig
+	manager, err := runtimeconfig.New(cfg, reg, util_log.Logger)
+	if err == nil {
+		err = services.StartAndAwaitRunning(context.Background(), manager)
+	}
+	if err != nil {
+		reg.unregisterAll()
+		t.runtimeConfigReg.registerAll()
+		return err
+	}
+
+	tenantLimits.setManager(manager)
+	if t.reloadedRuntimeConfig != nil {
+		if err := services.StopAndAwaitTerminated(context.Background(), t.reloadedRuntimeConfig); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to stop the replaced runtime config manager", "err", err)
+		}
+	}
+	t.reloadedRuntimeConfig = manager
+	t.runtimeConfigReg = reg
+	t.Cfg.RuntimeConfig = cfg
+	level.Info(util_log.Logger).Log("msg", "runtime configuration reloaded", "settings", strings.Join(changed, ","))
+	return nil
+}
+
+// stopReloadedRuntimeConfig stops the runtime config manager started by the configuration reload, if any.
+func (t *Mimir) stopReloadedRuntimeConfig() {
+	t.configReloadMtx.Lock()
+	defer t.configReloadMtx.Unlock()
+
+	if t.reloadedRuntimeConfig == nil {
+		return
+	}
+	if err := services.StopAndAwaitTerminated(context.Background(), t.reloadedRuntimeConfig); err != nil {
+		level.Warn(util_log.Logger).Log("msg", "failed to stop the reloaded runtime config manager", "err", err)
+	}
+	t.reloadedRuntimeConfig = nil
+}
+
+// configReloadHandler reloads the configuration, as on SIGHUP.
+func (t *Mimir) configReloadHandler(w http.ResponseWriter, _ *http.Request) {
+	if err := t.ReloadConfig(); err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to reload the configuration", "err", err)
+		http.Error(w, fmt.Sprintf("failed to reload the configuration: %s", err), http.StatusInternalServerError)
+	}
+}
+
+// changedSettings returns the YAML paths, prefixed by prefix, of the settings whose value differs between
+// the configurations cfg and other. The settings not marshalled to YAML are ignored.
+func changedSettings(prefix string, cfg, other interface{}) ([]string, error) {
+	var values [2]interface{}
+	for i, c := range []interface{}{cfg, other} {
+		out, err := yaml.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(out, &values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+	diffSettings(prefix, values[0], values[1], &changed)
+	return changed, nil
+}
+
+func diffSettings(path string, value, other interface{}, changed *[]string) {
+	settings, ok := value.(map[interface{}]interface{})
+	otherSettings, otherOK := other.(map[interface{}]interface{})
+	if !ok || !otherOK {
+		if !reflect.DeepEqual(value, other) {
+			*changed = append(*changed, path)
+		}
+		return
+	}
+
+	for name, v := range settings {
+		diffSettings(fmt.Sprintf("%s.%v", path, name), v, otherSettings[name], changed)
+	}
+	for name, v := range otherSettings {
+		if _, ok := settings[name]; !ok {
+			diffSettings(fmt.Sprintf("%s.%v", path, name), nil, v, changed)
+		}
+	}
+}
+
+// reloadRegisterer registers the metrics of a component replaced by the configuration reload, and keeps them
+// so that they can be unregistered before the metrics of the new component are registered.
+type reloadRegisterer struct {
+	reg prometheus.Registerer
+
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func newReloadRegisterer(reg prometheus.Registerer) *reloadRegisterer {
+	return &reloadRegisterer{reg: reg}
+}
+
+// Register implements prometheus.Registerer.
+func (r *reloadRegisterer) Register(c prometheus.Collector) error {
+	if err := r.reg.Register(c); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// MustRegister implements prometheus.Registerer.
+func (r *reloadRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements prometheus.Registerer.
+func (r *reloadRegisterer) Unregister(c prometheus.Collector) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for i, collector := range r.collectors {
+		if collector == c {
+			r.collectors = append(r.collectors[:i], r.collectors[i+1:]...)
+			break
+		}
+	}
+	return r.reg.Unregister(c)
+}
+
+// unregisterAll unregisters the registered collectors, which are kept to be registered again by registerAll.
+func (r *reloadRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, c := range r.collectors {
+		r.reg.Unregister(c)
+	}
+}
+
+// registerAll registers again the collectors unregistered by unregisterAll.
+func (r *reloadRegisterer) registerAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, c := range r.collectors {
+		if err := r.reg.Register(c); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to register the metrics again", "err", err)
+		}
+	}
+}
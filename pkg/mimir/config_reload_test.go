@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+func TestMimir_ReloadConfig(t *testing.T) {
+	newConfig := func() Config {
+		var cfg Config
+		cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError), log.NewNopLogger())
+		return cfg
+	}
+
+	m := &Mimir{Cfg: newConfig()}
+	require.Error(t, m.ReloadConfig())
+
+	reloaded := newConfig()
+	reloaded.Ruler.AlertmanagerURL = "http://alertmanager"
+	reloaded.Ruler.PollInterval = 5 * time.Second
+	m.ConfigReloader = func() (Config, error) {
+		return reloaded, nil
+	}
+	require.NoError(t, m.ReloadConfig())
+
+	// Only the Alertmanager notifier settings are applied.
+	assert.Equal(t, "http://alertmanager", m.Cfg.Ruler.AlertmanagerURL)
+	assert.Equal(t, time.Minute, m.Cfg.Ruler.PollInterval)
+
+	reloaded.Ruler.MaxIndependentRuleConcurrency = -1
+	require.Error(t, m.ReloadConfig())
+}
+
+func TestMimir_ReloadConfig_RulerStorageAndRuntimeConfig(t *testing.T) {
+	prepareGlobalMetricsRegistry(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	writeRuntimeConfig := func(name string, maxRules int) string {
+		path := filepath.Join(dir, name)
+		content := fmt.Sprintf("overrides:\n  user1:\n    ruler_max_rules_per_rule_group: %d\n", maxRules)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Server.HTTPListenPort = 0
+	cfg.Server.GRPCListenPort = 0
+	cfg.Target = []string{Ruler}
+	cfg.RuntimeConfig.LoadPath = writeRuntimeConfig("runtime-1.yaml", 5)
+	cfg.RulerStorage.Backend = bucket.Filesystem
+	cfg.RulerStorage.Filesystem.Directory = filepath.Join(dir, "rules-1")
+
+	m, err := New(cfg)
+	require.NoError(t, err)
+	_, err = m.ModuleManager.InitModuleServices(RulerStorage)
+	require.NoError(t, err)
+	defer m.Server.Stop()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, m.RuntimeConfig))
+
+	assert.Equal(t, 5, m.Overrides.RulerMaxRulesPerRuleGroup("user1"))
+	require.NoError(t, m.RulerStorage.SetRuleGroup(ctx, "user1", "namespace", &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}))
+	users, err := m.RulerStorage.ListAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user1"}, users)
+
+	reloaded := cfg
+	reloaded.RuntimeConfig.LoadPath = writeRuntimeConfig("runtime-2.yaml", 10)
+	reloaded.RulerStorage.Filesystem.Directory = filepath.Join(dir, "rules-2")
+	m.ConfigReloader = func() (Config, error) {
+		return reloaded, nil
+	}
+	require.NoError(t, m.ReloadConfig())
+
+	// The limits are read from the new file, and the rule groups from the new store.
+	assert.Equal(t, 10, m.Overrides.RulerMaxRulesPerRuleGroup("user1"))
+	users, err = m.RulerStorage.ListAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	// The metrics of the replaced store and runtime config manager are replaced.
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	operations := map[string]bool{}
+	runtimeConfigHashes := 0
+	for _, family := range families {
+		switch family.GetName() {
+		case "cortex_ruler_storage_operation_duration_seconds":
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "operation" {
+						operations[label.GetValue()] = true
+					}
+				}
+			}
+		case "cortex_runtime_config_hash":
+			runtimeConfigHashes = len(family.GetMetric())
+		}
+	}
+	assert.Equal(t, map[string]bool{"list_users": true}, operations)
+	assert.Equal(t, 1, runtimeConfigHashes)
+
+	// A runtime configuration file which can't be loaded keeps the current one.
+	reloaded.RuntimeConfig.LoadPath = filepath.Join(dir, "missing.yaml")
+	require.Error(t, m.ReloadConfig())
+	assert.Equal(t, 10, m.Overrides.RulerMaxRulesPerRuleGroup("user1"))
+	assert.Equal(t, filepath.Join(dir, "runtime-2.yaml"), m.Cfg.RuntimeConfig.LoadPath)
+
+	// The reloaded runtime config manager is stopped with the module.
+	reloadedRuntimeConfig := m.reloadedRuntimeConfig
+	require.NotNil(t, reloadedRuntimeConfig)
+	require.NoError(t, services.StopAndAwaitTerminated(ctx, m.RuntimeConfig))
+	assert.Eventually(t, func() bool {
+		return reloadedRuntimeConfig.State() == services.Terminated
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestChangedSettings(t *testing.T) {
+	type section struct {
+		Name     string            `yaml:"name"`
+		Interval time.Duration     `yaml:"interval"`
+		Labels   map[string]string `yaml:"labels"`
+		Ignored  string            `yaml:"-"`
+	}
+	type config struct {
+		Section section `yaml:"section"`
+		Enabled bool    `yaml:"enabled"`
+	}
+
+	cfg := config{Section: section{Name: "a", Interval: time.Minute, Labels: map[string]string{"a": "1"}, Ignored: "a"}}
+	changed, err := changedSettings("root", cfg, cfg)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	other := config{Section: section{Name: "b", Interval: time.Minute, Labels: map[string]string{"b": "1"}, Ignored: "b"}, Enabled: true}
+	changed, err = changedSettings("root", cfg, other)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"root.enabled", "root.section.name", "root.section.labels.a", "root.section.labels.b"}, changed)
+}
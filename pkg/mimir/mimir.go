@@ -12,7 +12,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
+	"sync"
+	"syscall"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -355,6 +358,23 @@ type Mimir struct {
 
 	// Queryables that the querier should use to query the long term storage.
 	StoreQueryables []querier.QueryableWithFilter
+
+	// Validates the API tokens of the requests to the ruler configuration API. Can be set before the
+	// initialization of the ruler to plug a custom validator, otherwise it's set from the ruler configuration.
+	RulerTokenValidator ruler.TokenValidator
+
+	// Reads the configuration again. Can be set before Run to reload the configuration on SIGHUP
+	// and on the requests to the /-/reload endpoint.
+	ConfigReloader  func() (Config, error)
+	configReloadMtx sync.Mutex
+
+	// Registerers of the metrics of the components replaced by the configuration reload.
+	runtimeConfigReg *reloadRegisterer
+	rulerStorageReg  *reloadRegisterer
+
+	// The rule store and the runtime config manager replaced by the configuration reload.
+	rulerStorage          *rulestore.ReloadableRuleStore
+	reloadedRuntimeConfig *runtimeconfig.Manager
 }
 
 // New makes a new Mimir.
@@ -429,6 +449,24 @@ func (t *Mimir) Run() error {
 
 	t.API.RegisterServiceMapHandler(http.HandlerFunc(t.servicesHandler))
 
+	if t.ConfigReloader != nil {
+		t.API.RegisterConfigReload(http.HandlerFunc(t.configReloadHandler))
+
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		defer func() {
+			signal.Stop(reload)
+			close(reload)
+		}()
+		go func() {
+			for range reload {
+				if err := t.ReloadConfig(); err != nil {
+					level.Error(util_log.Logger).Log("msg", "failed to reload the configuration", "err", err)
+				}
+			}
+		}()
+	}
+
 	// register ingester ring handlers, if they exists prefer the full ring
 	// implementation provided by module.Ring over the BasicLifecycler
 	// available in ingesters
@@ -544,6 +582,15 @@ func (t *Mimir) readyHandler(sm *services.Manager) http.HandlerFunc {
 			}
 		}
 
+		// Ruler can be configured to be ready only once it loaded and scheduled the rule groups it evaluates,
+		// so that its rules API doesn't return incomplete results.
+		if t.Ruler != nil {
+			if err := t.Ruler.CheckReady(r.Context()); err != nil {
+				http.Error(w, "Ruler not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		util.WriteTextResponse(w, "ready")
 	}
 }
@@ -96,7 +96,8 @@ func TestMimir(t *testing.T) {
 				KVStore: kv.Config{
 					Store: "memberlist",
 				},
-				InstanceAddr: "test:8080",
+				InstanceAddr:      "test:8080",
+				ReplicationFactor: 1,
 			},
 		},
 		RulerStorage: rulestore.Config{
@@ -30,6 +30,7 @@ import (
 	prom_storage "github.com/prometheus/prometheus/storage"
 	prom_remote "github.com/prometheus/prometheus/storage/remote"
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
+	"github.com/thanos-io/thanos/pkg/objstore"
 	httpgrpc_server "github.com/weaveworks/common/httpgrpc/server"
 	"github.com/weaveworks/common/server"
 
@@ -49,7 +50,10 @@ import (
 	"github.com/grafana/mimir/pkg/querier/tenantfederation"
 	querier_worker "github.com/grafana/mimir/pkg/querier/worker"
 	"github.com/grafana/mimir/pkg/ruler"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/local"
 	"github.com/grafana/mimir/pkg/scheduler"
+	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
@@ -234,16 +238,27 @@ func (t *Mimir) initRuntimeConfig() (services.Service, error) {
 	// make sure to set default limits before we start loading configuration into memory
 	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
 
-	serv, err := runtimeconfig.New(t.Cfg.RuntimeConfig, prometheus.WrapRegistererWithPrefix("cortex_", prometheus.DefaultRegisterer), util_log.Logger)
-	if err == nil {
-		// TenantLimits just delegates to RuntimeConfig and doesn't have any state or need to do
-		// anything in the start/stopping phase. Thus we can create it as part of runtime config
-		// setup without any service instance of its own.
-		t.TenantLimits = newTenantLimits(serv)
+	t.runtimeConfigReg = newReloadRegisterer(prometheus.WrapRegistererWithPrefix("cortex_", prometheus.DefaultRegisterer))
+	serv, err := runtimeconfig.New(t.Cfg.RuntimeConfig, t.runtimeConfigReg, util_log.Logger)
+	if err != nil {
+		return nil, err
 	}
 
+	// TenantLimits just delegates to RuntimeConfig and doesn't have any state or need to do
+	// anything in the start/stopping phase. Thus we can create it as part of runtime config
+	// setup without any service instance of its own.
+	tenantLimits := newTenantLimits(serv)
+	t.TenantLimits = tenantLimits
+
 	t.RuntimeConfig = serv
-	t.API.RegisterRuntimeConfig(runtimeConfigHandler(t.RuntimeConfig, t.Cfg.LimitsConfig))
+	t.API.RegisterRuntimeConfig(runtimeConfigHandler(tenantLimits, t.Cfg.LimitsConfig))
+
+	// The runtime config manager created by a configuration reload is stopped with the module.
+	serv.AddListener(services.NewListener(nil, nil, nil, func(services.State) {
+		t.stopReloadedRuntimeConfig()
+	}, func(services.State, error) {
+		t.stopReloadedRuntimeConfig()
+	}))
 
 	// Update config fields using runtime config. Only if multiKV is used for given ring these returned functions will be
 	// called and register the listener.
@@ -379,17 +394,25 @@ func (t *Mimir) initTenantFederation() (serv services.Service, err error) {
 func (t *Mimir) initQuerier() (serv services.Service, err error) {
 	// Create a internal HTTP handler that is configured with the Prometheus API routes and points
 	// to a Prometheus API struct instantiated with the Mimir Queryable.
+	// The experimental PromQL functions are rejected by the querier engine instead of the PromQL parser,
+	// whose functions are shared by all the components running in the process.
+	querierEngine := engine.NewExperimentalFunctionsQueryEngine(t.QuerierEngine, t.Cfg.Querier.EngineConfig)
+
 	internalQuerierRouter := api.NewQuerierHandler(
 		t.Cfg.API,
 		t.QuerierQueryable,
 		t.ExemplarQueryable,
-		t.QuerierEngine,
+		querierEngine,
 		t.Distributor,
 		prometheus.DefaultRegisterer,
 		util_log.Logger,
 		t.Overrides,
 	)
 
+	if t.Cfg.Querier.RuleEvaluator.Enabled {
+		t.API.RegisterRuleEvaluator(querier.NewRuleEvaluator(t.Cfg.Querier.RuleEvaluator, querierEngine, t.QuerierQueryable, util_log.Logger))
+	}
+
 	// If the querier is running standalone without the query-frontend or query-scheduler, we must register it's internal
 	// HTTP handler externally and provide the external Mimir Server HTTP handler to the frontend worker
 	// to ensure requests it processes use the default middleware instrumentation.
@@ -557,7 +580,15 @@ func (t *Mimir) initRulerStorage() (serv services.Service, err error) {
 		return
 	}
 
-	t.RulerStorage, err = ruler.NewRuleStore(context.Background(), t.Cfg.RulerStorage, t.Overrides, rules.FileLoader{}, util_log.Logger, prometheus.DefaultRegisterer)
+	t.rulerStorageReg = newReloadRegisterer(prometheus.DefaultRegisterer)
+	store, err := ruler.NewRuleStore(context.Background(), t.Cfg.RulerStorage, t.Overrides, rules.FileLoader{}, util_log.Logger, t.rulerStorageReg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The store is replaced when the configuration reload changes the ruler storage settings.
+	t.rulerStorage = rulestore.NewReloadableRuleStore(store)
+	t.RulerStorage = t.rulerStorage.Store()
 	return
 }
 
@@ -568,16 +599,30 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	}
 
 	t.Cfg.Ruler.Ring.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Ruler.QueryEngine.ExperimentalFunctionsEnabled = t.Cfg.Querier.EngineConfig.PromQLExperimentalFunctionsEnabled
 
 	var embeddedQueryable prom_storage.Queryable
 	var queryFunc rules.QueryFunc
 
+	var federationConsents *ruler.FederationConsents
+	if t.Cfg.Ruler.TenantFederation.ConsentsEnabled {
+		if t.Cfg.RulerStorage.Backend == local.Name {
+			return nil, errors.New("-ruler.tenant-federation.consents-enabled=true requires an object storage backend for the ruler storage")
+		}
+
+		bucketClient, err := bucket.NewClient(context.Background(), t.Cfg.RulerStorage.Config, "ruler-federation-consents", util_log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		federationConsents = ruler.NewFederationConsents(bucketClient)
+	}
+
 	if t.Cfg.Ruler.QueryFrontend.Address != "" {
 		queryFrontendClient, err := ruler.DialQueryFrontend(t.Cfg.Ruler.QueryFrontend)
 		if err != nil {
 			return nil, err
 		}
-		remoteQuerier := ruler.NewRemoteQuerier(queryFrontendClient, t.Cfg.API.PrometheusHTTPPrefix, util_log.Logger, ruler.WithOrgIDMiddleware)
+		remoteQuerier := ruler.NewRemoteQuerier(queryFrontendClient, t.Cfg.API.PrometheusHTTPPrefix, util_log.Logger, ruler.WithOrgIDMiddleware, ruler.WithTracingMiddleware)
 
 		embeddedQueryable = prom_remote.NewSampleAndChunkQueryableClient(
 			remoteQuerier,
@@ -594,7 +639,11 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 		// TODO: Consider wrapping logger to differentiate from querier module logger
 		rulerRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "ruler"}, prometheus.DefaultRegisterer)
 
-		queryable, _, eng := querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, rulerRegisterer, util_log.Logger, t.ActivityTracker)
+		// The ruler can override the PromQL engine settings of the querier.
+		querierCfg := t.Cfg.Querier
+		querierCfg.EngineConfig = t.Cfg.Ruler.QueryEngine.Apply(querierCfg.EngineConfig)
+
+		queryable, _, eng := querier.New(querierCfg, t.Overrides, t.Distributor, t.StoreQueryables, rulerRegisterer, util_log.Logger, t.ActivityTracker)
 		queryable = querier.NewErrorTranslateQueryableWithFn(queryable, ruler.WrapQueryableErrors)
 
 		if t.Cfg.Ruler.TenantFederation.Enabled {
@@ -602,26 +651,82 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 				return nil, errors.New("-ruler.tenant-federation.enabled=true requires -tenant-federation.enabled=true")
 			}
 			// Setting bypassForSingleQuerier=false forces `tenantfederation.NewQueryable` to add
-			// the `__tenant_id__` label on all metrics regardless if they're for a single tenant or multiple tenants.
+			// the source tenant label on all metrics regardless if they're for a single tenant or multiple tenants.
 			// This makes this label more consistent and hopefully less confusing to users.
 			const bypassForSingleQuerier = false
 
-			federatedQueryable = tenantfederation.NewQueryable(queryable, bypassForSingleQuerier, util_log.Logger)
+			sourceTenantQueryable := ruler.NewSourceTenantMetricsQueryable(queryable, t.Overrides)
+			if federationConsents != nil {
+				sourceTenantQueryable = ruler.NewSourceTenantConsentsQueryable(sourceTenantQueryable, federationConsents)
+			}
+			federatedQueryable = tenantfederation.NewQueryableWithMaxConcurrency(
+				ruler.NewSourceTenantsInstrumentedQueryable(sourceTenantQueryable, prometheus.DefaultRegisterer),
+				bypassForSingleQuerier,
+				t.Cfg.Ruler.TenantFederation.MaxConcurrent,
+				util_log.Logger,
+			)
 
 			regularQueryFunc := rules.EngineQueryFunc(eng, queryable)
 			federatedQueryFunc := rules.EngineQueryFunc(eng, federatedQueryable)
 
 			embeddedQueryable = federatedQueryable
-			queryFunc = ruler.TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc)
+			queryFunc = ruler.TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc, t.Cfg.Ruler.TenantFederation.SourceTenantLabel)
 
 		} else {
 			embeddedQueryable = queryable
 			queryFunc = rules.EngineQueryFunc(eng, queryable)
 		}
+
+		// The rule groups stored before the experimental functions were disabled are still loaded, so their queries are rejected too.
+		// The rule groups evaluated remotely are subject to the querier engine instead.
+		queryFunc = ruler.ExperimentalFunctionsQueryFunc(queryFunc, querierCfg.EngineConfig)
+
+		// The embedded queryable is still used to restore the state of the alerts.
+		if t.Cfg.Ruler.RemoteEvaluator.Address != "" {
+			evaluatorClient, err := ruler.DialRemoteEvaluator(t.Cfg.Ruler.RemoteEvaluator)
+			if err != nil {
+				return nil, err
+			}
+			queryFunc = ruler.NewRemoteEvaluator(evaluatorClient, util_log.Logger).Query
+		}
 	}
+	var federationAudit *ruler.FederationAudit
+	if t.Cfg.Ruler.TenantFederation.Audit.Enabled() {
+		var bucketClient objstore.Bucket
+		if t.Cfg.Ruler.TenantFederation.Audit.StoreEnabled {
+			if t.Cfg.RulerStorage.Backend == local.Name {
+				return nil, errors.New("-ruler.tenant-federation.audit.store-enabled=true requires an object storage backend for the ruler storage")
+			}
+
+			bucketClient, err = bucket.NewClient(context.Background(), t.Cfg.RulerStorage.Config, "ruler-federation-audit", util_log.Logger, prometheus.DefaultRegisterer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		federationAudit = ruler.NewFederationAudit(t.Cfg.Ruler.TenantFederation.Audit, bucketClient, t.Cfg.Ruler.Ring.InstanceID, util_log.Logger, prometheus.DefaultRegisterer)
+		queryFunc = federationAudit.QueryFunc(queryFunc)
+	}
+
+	var pusher ruler.Pusher = t.Distributor
+	var alertHistory *ruler.AlertHistory
+	if t.Cfg.Ruler.AlertHistory.Enabled {
+		if t.Cfg.RulerStorage.Backend == local.Name {
+			return nil, errors.New("-ruler.alert-history.enabled=true requires an object storage backend for the ruler storage")
+		}
+
+		bucketClient, err := bucket.NewClient(context.Background(), t.Cfg.RulerStorage.Config, "ruler-alert-history", util_log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+
+		alertHistory = ruler.NewAlertHistory(t.Cfg.Ruler.AlertHistory, bucketClient, t.Cfg.Ruler.Ring.InstanceID, util_log.Logger, prometheus.DefaultRegisterer)
+		pusher = alertHistory.Pusher(pusher)
+	}
+
 	managerFactory := ruler.DefaultTenantManagerFactory(
 		t.Cfg.Ruler,
-		t.Distributor,
+		pusher,
 		embeddedQueryable,
 		queryFunc,
 		t.Overrides,
@@ -639,7 +744,7 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	)
 
 	dnsResolver := dns.NewProvider(util_log.Logger, dnsProviderReg, dns.GolangResolverType)
-	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, prometheus.DefaultRegisterer, util_log.Logger, dnsResolver)
+	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, t.Overrides, prometheus.DefaultRegisterer, util_log.Logger, dnsResolver)
 	if err != nil {
 		return nil, err
 	}
@@ -647,6 +752,10 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	t.Ruler, err = ruler.NewRuler(
 		t.Cfg.Ruler,
 		manager,
+		queryFunc,
+		alertHistory,
+		federationConsents,
+		federationAudit,
 		prometheus.DefaultRegisterer,
 		util_log.Logger,
 		t.RulerStorage,
@@ -660,11 +769,35 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	t.API.RegisterRuler(t.Ruler)
 
 	// Expose HTTP configuration and prometheus-compatible Ruler APIs
-	t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, t.RulerStorage, util_log.Logger), t.Cfg.Ruler.EnableAPI)
+	rulerAPI := ruler.NewAPI(t.Ruler, t.RulerStorage, util_log.Logger)
+	if t.RulerTokenValidator == nil && t.Cfg.Ruler.NamespaceAuthorization.TokensFile != "" {
+		t.RulerTokenValidator, err = ruler.NewFileTokenValidator(t.Cfg.Ruler.NamespaceAuthorization.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rulerAPI.SetTokenValidator(t.RulerTokenValidator)
+	t.API.RegisterRulerAPI(rulerAPI, t.Cfg.Ruler.EnableAPI, t.BuildInfoHandler, api.FlagsHandler(flag.CommandLine, rulerStatusFlags))
 
 	return t.Ruler, nil
 }
 
+// rulerStatusFlags are the flags served by the Prometheus-compatible flags endpoint of the ruler API.
+// Only non-sensitive settings are listed: the other flags can hold credentials, URLs or file paths.
+var rulerStatusFlags = []string{
+	"ruler.enable-api",
+	"ruler.evaluation-interval",
+	"ruler.poll-interval",
+	"ruler.for-grace-period",
+	"ruler.for-outage-tolerance",
+	"ruler.resend-delay",
+	"ruler.query-stats-enabled",
+	"ruler.tenant-federation.enabled",
+	"ruler.query-engine.lookback-delta",
+	"ruler.query-engine.max-samples",
+	"ruler.query-engine.timeout",
+}
+
 func (t *Mimir) initAlertManager() (serv services.Service, err error) {
 	t.Cfg.Alertmanager.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
 	t.Cfg.Alertmanager.CheckExternalURL(t.Cfg.API.AlertmanagerHTTPPrefix, util_log.Logger)
@@ -6,11 +6,13 @@
 package mimir
 
 import (
+	"flag"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/assert"
@@ -291,3 +293,13 @@ func TestActiveSeriesOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestRulerStatusFlags(t *testing.T) {
+	c := Config{}
+	f := flag.NewFlagSet("test", flag.PanicOnError)
+	c.RegisterFlags(f, log.NewNopLogger())
+
+	for _, name := range rulerStatusFlags {
+		assert.NotNil(t, f.Lookup(name), name)
+	}
+}
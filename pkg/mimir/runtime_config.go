@@ -9,6 +9,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/runtimeconfig"
@@ -37,25 +38,41 @@ type runtimeConfigValues struct {
 }
 
 // runtimeConfigTenantLimits provides per-tenant limit overrides based on a runtimeconfig.Manager
-// that reads limits from a configuration file on disk and periodically reloads them.
+// that reads limits from a configuration file on disk and periodically reloads them. The manager
+// is replaced when a configuration reload changes the path of the file.
 type runtimeConfigTenantLimits struct {
+	mtx     sync.RWMutex
 	manager *runtimeconfig.Manager
 }
 
 // newTenantLimits creates a new validation.TenantLimits that loads per-tenant limit overrides from
 // a runtimeconfig.Manager
-func newTenantLimits(manager *runtimeconfig.Manager) validation.TenantLimits {
+func newTenantLimits(manager *runtimeconfig.Manager) *runtimeConfigTenantLimits {
 	return &runtimeConfigTenantLimits{
 		manager: manager,
 	}
 }
 
+// setManager replaces the manager the limits are read from.
+func (l *runtimeConfigTenantLimits) setManager(manager *runtimeconfig.Manager) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.manager = manager
+}
+
+// GetConfig returns the runtime configuration loaded by the current manager.
+func (l *runtimeConfigTenantLimits) GetConfig() interface{} {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return l.manager.GetConfig()
+}
+
 func (l *runtimeConfigTenantLimits) ByUserID(userID string) *validation.Limits {
 	return l.AllByUserID()[userID]
 }
 
 func (l *runtimeConfigTenantLimits) AllByUserID() map[string]*validation.Limits {
-	cfg, ok := l.manager.GetConfig().(*runtimeConfigValues)
+	cfg, ok := l.GetConfig().(*runtimeConfigValues)
 	if cfg != nil && ok {
 		return cfg.TenantLimits
 	}
@@ -145,7 +162,12 @@ func ingesterInstanceLimits(manager *runtimeconfig.Manager) func() *ingester.Ins
 	}
 }
 
-func runtimeConfigHandler(runtimeCfgManager *runtimeconfig.Manager, defaultLimits validation.Limits) http.HandlerFunc {
+// runtimeConfigGetter returns the loaded runtime configuration.
+type runtimeConfigGetter interface {
+	GetConfig() interface{}
+}
+
+func runtimeConfigHandler(runtimeCfgManager runtimeConfigGetter, defaultLimits validation.Limits) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cfg, ok := runtimeCfgManager.GetConfig().(*runtimeConfigValues)
 		if !ok || cfg == nil {
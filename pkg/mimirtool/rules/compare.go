@@ -19,11 +19,17 @@ import (
 )
 
 var (
-	errNameDiff          = errors.New("rule groups are named differently")
-	errIntervalDiff      = errors.New("rule groups have different intervals")
-	errDiffRuleLen       = errors.New("rule groups have a different number of rules")
-	errDiffRWConfigs     = errors.New("rule groups have different remote write configs")
-	errDiffSourceTenants = errors.New("rule groups have different source tenants")
+	errNameDiff              = errors.New("rule groups are named differently")
+	errIntervalDiff          = errors.New("rule groups have different intervals")
+	errDiffRuleLen           = errors.New("rule groups have a different number of rules")
+	errDiffRWConfigs         = errors.New("rule groups have different remote write configs")
+	errDiffSourceTenants     = errors.New("rule groups have different source tenants")
+	errDiffLimit             = errors.New("rule groups have different limits")
+	errDiffTimeIntervals     = errors.New("rule groups have different active time intervals")
+	errDiffDependsOn         = errors.New("rule groups have different dependencies")
+	errDiffDestTenants       = errors.New("rule groups have different destination tenants")
+	errDiffSourceTenantLabel = errors.New("rule groups have different source tenant labels")
+	errDiffGroupLabels       = errors.New("rule groups have different labels")
 )
 
 // NamespaceState is used to denote the difference between the staged namespace
@@ -102,6 +108,32 @@ func CompareGroups(groupOne, groupTwo rwrulefmt.RuleGroup) error {
 		return errDiffSourceTenants
 	}
 
+	if groupOne.Limit != groupTwo.Limit {
+		return errDiffLimit
+	}
+
+	if len(groupOne.ActiveTimeIntervals) != len(groupTwo.ActiveTimeIntervals) ||
+		(len(groupOne.ActiveTimeIntervals) > 0 && !reflect.DeepEqual(groupOne.ActiveTimeIntervals, groupTwo.ActiveTimeIntervals)) {
+		return errDiffTimeIntervals
+	}
+
+	if !stringSlicesElementsMatch(groupOne.DependsOn, groupTwo.DependsOn) {
+		return errDiffDependsOn
+	}
+
+	if !stringSlicesElementsMatch(groupOne.DestTenants, groupTwo.DestTenants) {
+		return errDiffDestTenants
+	}
+
+	if groupOne.SourceTenantLabel != groupTwo.SourceTenantLabel {
+		return errDiffSourceTenantLabel
+	}
+
+	if len(groupOne.Labels) != len(groupTwo.Labels) ||
+		(len(groupOne.Labels) > 0 && !reflect.DeepEqual(groupOne.Labels, groupTwo.Labels)) {
+		return errDiffGroupLabels
+	}
+
 	for i := range groupOne.Rules {
 		eq := rulesEqual(&groupOne.Rules[i], &groupTwo.Rules[i])
 		if !eq {
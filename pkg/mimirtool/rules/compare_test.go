@@ -8,6 +8,7 @@ package rules
 import (
 	"testing"
 
+	"github.com/prometheus/alertmanager/timeinterval"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
@@ -378,6 +379,201 @@ func TestCompareGroups(t *testing.T) {
 			},
 			expectedErr: errDiffSourceTenants,
 		},
+		{
+			name: "different limits",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name:  "example_group",
+					Limit: 10,
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name:  "example_group",
+					Limit: 20,
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+			},
+			expectedErr: errDiffLimit,
+		},
+		{
+			name: "different active time intervals",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				ActiveTimeIntervals: []timeinterval.TimeInterval{{Weekdays: []timeinterval.WeekdayRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 1, End: 5}}}}},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				ActiveTimeIntervals: []timeinterval.TimeInterval{{Weekdays: []timeinterval.WeekdayRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 0, End: 6}}}}},
+			},
+			expectedErr: errDiffTimeIntervals,
+		},
+		{
+			name: "different dependencies",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				DependsOn: []string{"recording/per-instance"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				DependsOn: []string{"recording/per-job"},
+			},
+			expectedErr: errDiffDependsOn,
+		},
+		{
+			name: "different destination tenants",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+				DestTenants: []string{"team-a"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+				DestTenants: []string{"team-a", "global"},
+			},
+			expectedErr: errDiffDestTenants,
+		},
+		{
+			name: "different source tenant labels",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+				SourceTenantLabel: "team",
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+			},
+			expectedErr: errDiffSourceTenantLabel,
+		},
+		{
+			name: "different group labels",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+				Labels: map[string]string{"team": "a"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "one"},
+							Expr:   yaml.Node{Value: "up"},
+						},
+					},
+				},
+				Labels: map[string]string{"team": "b"},
+			},
+			expectedErr: errDiffGroupLabels,
+		},
+		{
+			name: "same dependencies in a different order",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				DependsOn: []string{"recording/per-instance", "recording/per-job"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "example_group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Alert: yaml.Node{Value: "one"},
+							Expr:  yaml.Node{Value: "up == 0"},
+						},
+					},
+				},
+				DependsOn: []string{"recording/per-job", "recording/per-instance"},
+			},
+			expectedErr: nil,
+		},
 		{
 			name: "repeated single tenant (tenants should be deduplicated)",
 			groupOne: rwrulefmt.RuleGroup{
@@ -7,8 +7,10 @@ package rules
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/prometheus/alertmanager/timeinterval"
 	"github.com/prometheus/prometheus/model/rulefmt"
 
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
@@ -119,6 +121,38 @@ func TestParseFiles(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "mimir_rule_group_fields",
+			backend: MimirBackend,
+			files: []string{
+				"testdata/mimir_rule_group_fields.yaml",
+			},
+			want: map[string]RuleNamespace{
+				"example_namespace": {
+					Namespace: "example_namespace",
+					Groups: []rwrulefmt.RuleGroup{
+						{
+							RuleGroup: rulefmt.RuleGroup{
+								Name: "example_rule_group",
+								Rules: []rulefmt.RuleNode{
+									{
+										// currently, the tests only check length
+									},
+								},
+							},
+							ActiveTimeIntervals: []timeinterval.TimeInterval{{
+								Times:    []timeinterval.TimeRange{{StartMinute: 9 * 60, EndMinute: 17 * 60}},
+								Weekdays: []timeinterval.WeekdayRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 1, End: 5}}},
+							}},
+							DependsOn:         []string{"recording/per-instance"},
+							DestTenants:       []string{"team-a", "global"},
+							SourceTenantLabel: "team",
+							Labels:            map[string]string{"severity": "page"},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -177,6 +211,21 @@ func compareNamespace(g, w RuleNamespace) error {
 				return fmt.Errorf("tenant %d (0-indexed) is different, actual=%s, expected=%s", j, got, want)
 			}
 		}
+		if !reflect.DeepEqual(g.Groups[i].ActiveTimeIntervals, w.Groups[i].ActiveTimeIntervals) {
+			return fmt.Errorf("active time intervals do not match, actual=%v expected=%v", g.Groups[i].ActiveTimeIntervals, w.Groups[i].ActiveTimeIntervals)
+		}
+		if !reflect.DeepEqual(g.Groups[i].DependsOn, w.Groups[i].DependsOn) {
+			return fmt.Errorf("dependencies do not match, actual=%v expected=%v", g.Groups[i].DependsOn, w.Groups[i].DependsOn)
+		}
+		if !reflect.DeepEqual(g.Groups[i].DestTenants, w.Groups[i].DestTenants) {
+			return fmt.Errorf("destination tenants do not match, actual=%v expected=%v", g.Groups[i].DestTenants, w.Groups[i].DestTenants)
+		}
+		if g.Groups[i].SourceTenantLabel != w.Groups[i].SourceTenantLabel {
+			return fmt.Errorf("source tenant labels do not match, actual=%v expected=%v", g.Groups[i].SourceTenantLabel, w.Groups[i].SourceTenantLabel)
+		}
+		if !reflect.DeepEqual(g.Groups[i].Labels, w.Groups[i].Labels) {
+			return fmt.Errorf("group labels do not match, actual=%v expected=%v", g.Groups[i].Labels, w.Groups[i].Labels)
+		}
 	}
 
 	return nil
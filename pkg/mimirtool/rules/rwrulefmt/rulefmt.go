@@ -5,7 +5,10 @@
 
 package rwrulefmt
 
-import "github.com/prometheus/prometheus/model/rulefmt"
+import (
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/prometheus/model/rulefmt"
+)
 
 // Wrapper around Prometheus rulefmt.
 
@@ -14,6 +17,21 @@ type RuleGroup struct {
 	rulefmt.RuleGroup `yaml:",inline"`
 	// RWConfigs is used by the remote write forwarding ruler
 	RWConfigs []RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	// ActiveTimeIntervals are the time intervals during which the alerts of the group are sent
+	// to the Alertmanager by the Mimir ruler. The alerts are always sent if empty.
+	ActiveTimeIntervals []timeinterval.TimeInterval `yaml:"active_time_intervals,omitempty"`
+	// DependsOn are the rule groups of the same tenant, in the namespace/group format, whose
+	// evaluation the group waits for in the Mimir ruler.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+	// DestTenants are the tenants the series of the recording rules of the group are written to
+	// by the Mimir ruler, instead of the tenant owning the group.
+	DestTenants []string `yaml:"dest_tenants,omitempty"`
+	// SourceTenantLabel is the label added by the Mimir ruler to the series read from each source
+	// tenant of the group, whose value is the source tenant.
+	SourceTenantLabel string `yaml:"source_tenant_label,omitempty"`
+	// Labels are added by the Mimir ruler to every rule of the group, the labels of a rule taking
+	// precedence.
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 // RemoteWriteConfig is used to specify a remote write endpoint
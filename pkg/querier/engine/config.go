@@ -28,6 +28,13 @@ type Config struct {
 	// LookbackDelta determines the time since the last sample after which a time
 	// series is considered stale.
 	LookbackDelta time.Duration `yaml:"lookback_delta" category:"advanced"`
+
+	PromQLExperimentalFunctionsEnabled bool `yaml:"promql_experimental_functions_enabled" category:"experimental"`
+
+	// EnableAtModifier and EnableNegativeOffset enable the corresponding PromQL features.
+	// They're not configurable for the querier, but can be overridden by the ruler.
+	EnableAtModifier     bool `yaml:"-"`
+	EnableNegativeOffset bool `yaml:"-"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
@@ -44,6 +51,10 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxSamples, "querier.max-samples", 50e6, sharedWithQueryFrontend("Maximum number of samples a single query can load into memory."))
 	f.DurationVar(&cfg.DefaultEvaluationInterval, "querier.default-evaluation-interval", time.Minute, sharedWithQueryFrontend("The default evaluation interval or step size for subqueries."))
 	f.DurationVar(&cfg.LookbackDelta, "querier.lookback-delta", 5*time.Minute, sharedWithQueryFrontend("Time since the last sample after which a time series is considered stale and ignored by expression evaluations."))
+	f.BoolVar(&cfg.PromQLExperimentalFunctionsEnabled, "querier.promql-experimental-functions-enabled", true, "Enable experimental PromQL functions, such as holt_winters. Rule expressions using experimental functions are rejected by the ruler when disabled. This config option should be set on query-frontend and ruler too.")
+
+	cfg.EnableAtModifier = true
+	cfg.EnableNegativeOffset = false // If this can be enabled, please change the error mapping in errorTranslateQueryEngine.
 }
 
 // NewPromQLEngineOptions returns the PromQL engine options based on the provided config.
@@ -55,8 +66,8 @@ func NewPromQLEngineOptions(cfg Config, activityTracker *activitytracker.Activit
 		MaxSamples:           cfg.MaxSamples,
 		Timeout:              cfg.Timeout,
 		LookbackDelta:        cfg.LookbackDelta,
-		EnableAtModifier:     true,
-		EnableNegativeOffset: false, // If this can be enabled, please change the error mapping in errorTranslateQueryEngine.
+		EnableAtModifier:     cfg.EnableAtModifier,
+		EnableNegativeOffset: cfg.EnableNegativeOffset,
 		NoStepSubqueryIntervalFn: func(int64) int64 {
 			return cfg.DefaultEvaluationInterval.Milliseconds()
 		},
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// experimentalFunctions are the PromQL functions considered experimental by Mimir.
+var experimentalFunctions = map[string]struct{}{
+	"holt_winters": {},
+}
+
+// ValidateExperimentalFunctions returns an error if expr calls an experimental PromQL function while
+// they're disabled in cfg. The functions of the PromQL parser are shared by all the engines running
+// in the process, so the experimental functions are rejected on the parsed expression instead of
+// being removed from the parser.
+func (cfg Config) ValidateExperimentalFunctions(expr parser.Expr) (err error) {
+	if cfg.PromQLExperimentalFunctionsEnabled {
+		return nil
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if call, ok := node.(*parser.Call); ok {
+			if _, experimental := experimentalFunctions[call.Func.Name]; experimental {
+				err = fmt.Errorf("function %q is experimental and disabled", call.Func.Name)
+			}
+		}
+		return err
+	})
+	return err
+}
+
+// validateQueryExperimentalFunctions is like ValidateExperimentalFunctions, but parses qs first.
+// Invalid expressions are left to the engine, which reports the parsing errors.
+func (cfg Config) validateQueryExperimentalFunctions(qs string) error {
+	if cfg.PromQLExperimentalFunctionsEnabled {
+		return nil
+	}
+
+	expr, err := parser.ParseExpr(qs)
+	if err != nil {
+		return nil
+	}
+	return cfg.ValidateExperimentalFunctions(expr)
+}
+
+// QueryEngine is the interface of the PromQL engine used to create queries.
+type QueryEngine interface {
+	SetQueryLogger(l promql.QueryLogger)
+	NewInstantQuery(q storage.Queryable, qs string, ts time.Time) (promql.Query, error)
+	NewRangeQuery(q storage.Queryable, qs string, start, end time.Time, interval time.Duration) (promql.Query, error)
+}
+
+// NewExperimentalFunctionsQueryEngine returns a QueryEngine rejecting the queries calling experimental
+// PromQL functions when they're disabled in cfg.
+func NewExperimentalFunctionsQueryEngine(engine QueryEngine, cfg Config) QueryEngine {
+	return experimentalFunctionsQueryEngine{QueryEngine: engine, cfg: cfg}
+}
+
+type experimentalFunctionsQueryEngine struct {
+	QueryEngine
+	cfg Config
+}
+
+func (qe experimentalFunctionsQueryEngine) NewInstantQuery(q storage.Queryable, qs string, ts time.Time) (promql.Query, error) {
+	if err := qe.cfg.validateQueryExperimentalFunctions(qs); err != nil {
+		return nil, err
+	}
+	return qe.QueryEngine.NewInstantQuery(q, qs, ts)
+}
+
+func (qe experimentalFunctionsQueryEngine) NewRangeQuery(q storage.Queryable, qs string, start, end time.Time, interval time.Duration) (promql.Query, error) {
+	if err := qe.cfg.validateQueryExperimentalFunctions(qs); err != nil {
+		return nil, err
+	}
+	return qe.QueryEngine.NewRangeQuery(q, qs, start, end, interval)
+}
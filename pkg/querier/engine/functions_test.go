@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ValidateExperimentalFunctions(t *testing.T) {
+	for _, expr := range []string{"holt_winters(up[5m], 0.5, 0.5)", "sum(holt_winters(up[5m], 0.5, 0.5))"} {
+		parsed, err := parser.ParseExpr(expr)
+		require.NoError(t, err)
+
+		assert.NoError(t, Config{PromQLExperimentalFunctionsEnabled: true}.ValidateExperimentalFunctions(parsed), expr)
+		err = Config{PromQLExperimentalFunctionsEnabled: false}.ValidateExperimentalFunctions(parsed)
+		require.Error(t, err, expr)
+		assert.Contains(t, err.Error(), `function "holt_winters" is experimental and disabled`)
+	}
+
+	parsed, err := parser.ParseExpr("rate(up[5m])")
+	require.NoError(t, err)
+	assert.NoError(t, Config{PromQLExperimentalFunctionsEnabled: false}.ValidateExperimentalFunctions(parsed))
+}
+
+func TestNewExperimentalFunctionsQueryEngine(t *testing.T) {
+	eng := promql.NewEngine(promql.EngineOpts{Logger: log.NewNopLogger(), MaxSamples: 100, Timeout: time.Minute})
+	enabled := NewExperimentalFunctionsQueryEngine(eng, Config{PromQLExperimentalFunctionsEnabled: true})
+	disabled := NewExperimentalFunctionsQueryEngine(eng, Config{PromQLExperimentalFunctionsEnabled: false})
+
+	const expr = "holt_winters(up[5m], 0.5, 0.5)"
+	now := time.Now()
+
+	_, err := enabled.NewInstantQuery(nil, expr, now)
+	require.NoError(t, err)
+	_, err = enabled.NewRangeQuery(nil, expr, now.Add(-time.Hour), now, time.Minute)
+	require.NoError(t, err)
+
+	_, err = disabled.NewInstantQuery(nil, expr, now)
+	require.Error(t, err)
+	_, err = disabled.NewRangeQuery(nil, expr, now.Add(-time.Hour), now, time.Minute)
+	require.Error(t, err)
+
+	// The other queries, including the invalid ones, are left to the engine.
+	_, err = disabled.NewInstantQuery(nil, "up", now)
+	require.NoError(t, err)
+	_, err = disabled.NewInstantQuery(nil, "up{", now)
+	require.Error(t, err)
+}
@@ -53,6 +53,8 @@ type Config struct {
 
 	// PromQL engine config.
 	EngineConfig engine.Config `yaml:",inline"`
+
+	RuleEvaluator RuleEvaluatorConfig `yaml:"rule_evaluator" category:"experimental"`
 }
 
 var (
@@ -72,6 +74,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.ShuffleShardingIngestersLookbackPeriod, "querier.shuffle-sharding-ingesters-lookback-period", 0, "When distributor's sharding strategy is shuffle-sharding and this setting is > 0, queriers fetch in-memory series from the minimum set of required ingesters, selecting only ingesters which may have received series since 'now - lookback period'. The lookback period should be greater or equal than the configured -querier.query-store-after and -querier.query-ingesters-within. If this setting is 0, queriers always query all ingesters (ingesters shuffle sharding on read path is disabled).")
 
 	cfg.EngineConfig.RegisterFlags(f)
+	cfg.RuleEvaluator.RegisterFlags(f)
 }
 
 // Validate the config
@@ -89,6 +92,10 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if err := cfg.RuleEvaluator.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
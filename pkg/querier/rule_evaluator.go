@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/engine"
+	"github.com/grafana/mimir/pkg/querier/evaluatorpb"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+var (
+	errInvalidRuleEvaluatorMaxResponseSize = errors.New("invalid querier rule evaluator max response size, must be greater than zero")
+	errInvalidRuleEvaluatorBatchSize       = errors.New("invalid querier rule evaluator batch size, must be greater than zero")
+)
+
+type RuleEvaluatorConfig struct {
+	Enabled              bool `yaml:"enabled"`
+	MaxResponseSizeBytes int  `yaml:"max_response_size_bytes"`
+	BatchSize            int  `yaml:"batch_size"`
+}
+
+func (cfg *RuleEvaluatorConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "querier.rule-evaluator.enabled", false, "Expose the gRPC service used by the rulers to evaluate the expressions of the rules on the queriers.")
+	f.IntVar(&cfg.MaxResponseSizeBytes, "querier.rule-evaluator.max-response-size-bytes", 100*1024*1024, "Maximum size of the result of a rule expression evaluated on behalf of a ruler. The evaluation fails if the result is larger.")
+	f.IntVar(&cfg.BatchSize, "querier.rule-evaluator.batch-size", 1000, "Maximum number of samples sent to the ruler in each message of the streamed result of a rule expression.")
+}
+
+func (cfg *RuleEvaluatorConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxResponseSizeBytes <= 0 {
+		return errInvalidRuleEvaluatorMaxResponseSize
+	}
+	if cfg.BatchSize <= 0 {
+		return errInvalidRuleEvaluatorBatchSize
+	}
+	return nil
+}
+
+// RuleEvaluator evaluates the expressions of the rules on behalf of the rulers, and streams
+// the results back to them in batches of samples.
+type RuleEvaluator struct {
+	cfg       RuleEvaluatorConfig
+	engine    engine.QueryEngine
+	queryable storage.Queryable
+	logger    log.Logger
+}
+
+// NewRuleEvaluator makes a new RuleEvaluator evaluating the expressions with the engine against the queryable.
+func NewRuleEvaluator(cfg RuleEvaluatorConfig, eng engine.QueryEngine, queryable storage.Queryable, logger log.Logger) *RuleEvaluator {
+	return &RuleEvaluator{
+		cfg:       cfg,
+		engine:    eng,
+		queryable: queryable,
+		logger:    logger,
+	}
+}
+
+// Evaluate implements evaluatorpb.EvaluatorServer. The tenant is read from the request context.
+func (e *RuleEvaluator) Evaluate(req *evaluatorpb.EvaluateRequest, stream evaluatorpb.Evaluator_EvaluateServer) error {
+	spanLog, ctx := spanlogger.NewWithLogger(stream.Context(), e.logger, "RuleEvaluator.Evaluate")
+	defer spanLog.Span.Finish()
+
+	q, err := e.engine.NewInstantQuery(e.queryable, req.Expr, timestamp.Time(req.TimestampMs))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return res.Err
+	}
+
+	var vector promql.Vector
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		vector = v
+	case promql.Scalar:
+		vector = promql.Vector{promql.Sample{Point: promql.Point{T: v.T, V: v.V}}}
+	default:
+		return status.Errorf(codes.InvalidArgument, "rule result is not a vector or scalar: %q", res.Value.Type())
+	}
+
+	// The samples are sent in batches as soon as each batch is built, and the size of the result
+	// is checked against the limit while streaming it. When the limit is exceeded, the evaluation
+	// fails after some batches were sent, and the ruler discards the samples it received.
+	batch := make([]evaluatorpb.Sample, 0, e.cfg.BatchSize)
+	size := 0
+	for _, s := range vector {
+		sample := evaluatorpb.Sample{
+			Labels:      mimirpb.FromLabelsToLabelAdapters(s.Metric),
+			Value:       s.V,
+			TimestampMs: s.T,
+		}
+		if size += sample.Size(); size > e.cfg.MaxResponseSizeBytes {
+			level.Warn(spanLog).Log("msg", "rule evaluation result exceeds the max response size", "expr", req.Expr, "samples", len(vector), "limit", e.cfg.MaxResponseSizeBytes)
+			return status.Errorf(codes.ResourceExhausted, "the result of the rule expression exceeds the max response size of %d bytes", e.cfg.MaxResponseSizeBytes)
+		}
+
+		batch = append(batch, sample)
+		if len(batch) == e.cfg.BatchSize {
+			if err := stream.Send(&evaluatorpb.EvaluateResponse{Samples: batch}); err != nil {
+				return err
+			}
+			// The message is serialized by Send, so the batch can be reused.
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		return stream.Send(&evaluatorpb.EvaluateResponse{Samples: batch})
+	}
+	return nil
+}
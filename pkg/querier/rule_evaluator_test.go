@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/mimir/pkg/querier/evaluatorpb"
+)
+
+func TestRuleEvaluator_Evaluate(t *testing.T) {
+	storage := teststorage.New(t)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	app := storage.Appender(context.Background())
+	for _, instance := range []string{"a", "b", "c", "d", "e"} {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, "up", "instance", instance), 1000, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	engine := promql.NewEngine(promql.EngineOpts{
+		Logger:     log.NewNopLogger(),
+		MaxSamples: 1e6,
+		Timeout:    time.Minute,
+	})
+
+	tests := map[string]struct {
+		expr                 string
+		maxResponseSizeBytes int
+		expectedBatches      []int
+		expectedCode         codes.Code
+	}{
+		"vector streamed in batches": {
+			expr:                 "up",
+			maxResponseSizeBytes: 1024,
+			expectedBatches:      []int{2, 2, 1},
+		},
+		"scalar": {
+			expr:                 "scalar(count(up))",
+			maxResponseSizeBytes: 1024,
+			expectedBatches:      []int{1},
+		},
+		"empty vector": {
+			expr:                 "up{instance=\"z\"}",
+			maxResponseSizeBytes: 1024,
+		},
+		"result exceeding the max response size": {
+			expr:                 "up",
+			maxResponseSizeBytes: 100,
+			expectedBatches:      []int{2},
+			expectedCode:         codes.ResourceExhausted,
+		},
+		"invalid expression": {
+			expr:                 "sum(",
+			maxResponseSizeBytes: 1024,
+			expectedCode:         codes.InvalidArgument,
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := RuleEvaluatorConfig{Enabled: true, MaxResponseSizeBytes: testData.maxResponseSizeBytes, BatchSize: 2}
+			client := newRuleEvaluatorTestClient(t, NewRuleEvaluator(cfg, engine, storage, log.NewNopLogger()))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			stream, err := client.Evaluate(ctx, &evaluatorpb.EvaluateRequest{Expr: testData.expr, TimestampMs: 1000})
+			require.NoError(t, err)
+
+			var batches []int
+			for {
+				resp, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil && testData.expectedCode != codes.OK {
+					// The batches within the limit are streamed before the evaluation fails.
+					assert.Equal(t, testData.expectedCode, status.Code(err))
+					assert.Equal(t, testData.expectedBatches, batches)
+					return
+				}
+				require.NoError(t, err)
+				batches = append(batches, len(resp.Samples))
+
+				for _, s := range resp.Samples {
+					assert.Equal(t, int64(1000), s.TimestampMs)
+				}
+			}
+			require.Equal(t, codes.OK, testData.expectedCode)
+			assert.Equal(t, testData.expectedBatches, batches)
+		})
+	}
+}
+
+func newRuleEvaluatorTestClient(t *testing.T, evaluator *RuleEvaluator) evaluatorpb.EvaluatorClient {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer(grpc.StreamInterceptor(middleware.StreamServerUserHeaderInterceptor))
+	evaluatorpb.RegisterEvaluatorServer(server, evaluator)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure(), grpc.WithStreamInterceptor(middleware.StreamClientUserHeaderInterceptor))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return evaluatorpb.NewEvaluatorClient(conn)
+}
@@ -37,7 +37,19 @@ import (
 // by the tenant ID and the previous value is exposed through a new label
 // prefixed with "original_". This behaviour is not implemented recursively.
 func NewQueryable(upstream storage.Queryable, byPassWithSingleQuerier bool, logger log.Logger) storage.Queryable {
-	return NewMergeQueryable(defaultTenantLabel, tenantQuerierCallback(upstream), byPassWithSingleQuerier, logger)
+	return NewQueryableWithMaxConcurrency(upstream, byPassWithSingleQuerier, maxConcurrency, logger)
+}
+
+// NewQueryableWithMaxConcurrency is like NewQueryable, but queries at most
+// maxConcurrent tenants concurrently.
+func NewQueryableWithMaxConcurrency(upstream storage.Queryable, byPassWithSingleQuerier bool, maxConcurrent int, logger log.Logger) storage.Queryable {
+	return &mergeQueryable{
+		logger:                  logger,
+		idLabelName:             defaultTenantLabel,
+		callback:                tenantQuerierCallback(upstream),
+		bypassWithSingleQuerier: byPassWithSingleQuerier,
+		maxConcurrency:          maxConcurrent,
+	}
 }
 
 func tenantQuerierCallback(queryable storage.Queryable) MergeQuerierCallback {
@@ -86,6 +98,7 @@ func NewMergeQueryable(idLabelName string, callback MergeQuerierCallback, byPass
 		idLabelName:             idLabelName,
 		callback:                callback,
 		bypassWithSingleQuerier: byPassWithSingleQuerier,
+		maxConcurrency:          maxConcurrency,
 	}
 }
 
@@ -94,6 +107,7 @@ type mergeQueryable struct {
 	idLabelName             string
 	bypassWithSingleQuerier bool
 	callback                MergeQuerierCallback
+	maxConcurrency          int
 }
 
 // Querier returns a new mergeQuerier, which aggregates results from multiple
@@ -113,11 +127,12 @@ func (m *mergeQueryable) Querier(ctx context.Context, mint int64, maxt int64) (s
 	}
 
 	return &mergeQuerier{
-		logger:      m.logger,
-		ctx:         ctx,
-		idLabelName: m.idLabelName,
-		queriers:    queriers,
-		ids:         ids,
+		logger:         m.logger,
+		ctx:            ctx,
+		idLabelName:    idLabelNameFromContext(ctx, m.idLabelName),
+		queriers:       queriers,
+		ids:            ids,
+		maxConcurrency: m.maxConcurrency,
 	}, nil
 }
 
@@ -133,6 +148,8 @@ type mergeQuerier struct {
 	queriers    []storage.Querier
 	idLabelName string
 	ids         []string
+
+	maxConcurrency int
 }
 
 // LabelValues returns all potential values for a label name.  It is not safe
@@ -246,7 +263,7 @@ func (m *mergeQuerier) mergeDistinctStringSliceWithTenants(f stringSliceFunc, te
 		return nil
 	}
 
-	err := concurrency.ForEachJob(m.ctx, len(jobs), maxConcurrency, run)
+	err := concurrency.ForEachJob(m.ctx, len(jobs), m.maxConcurrency, run)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -324,7 +341,7 @@ func (m *mergeQuerier) Select(sortSeries bool, hints *storage.SelectHints, match
 		return nil
 	}
 
-	err := concurrency.ForEachJob(ctx, len(jobs), maxConcurrency, run)
+	err := concurrency.ForEachJob(ctx, len(jobs), m.maxConcurrency, run)
 	if err != nil {
 		return storage.ErrSeriesSet(err)
 	}
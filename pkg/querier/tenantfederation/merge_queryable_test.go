@@ -353,6 +353,28 @@ func TestMergeQueryable_Querier(t *testing.T) {
 		_, err := q.Querier(ctx, mint, maxt)
 		require.EqualError(t, err, user.ErrNoOrgID.Error())
 	})
+
+	t.Run("the label injected in the context should identify the tenant of the series", func(t *testing.T) {
+		tenant.WithDefaultResolver(tenant.NewMultiResolver())
+
+		queryable := &mockTenantQueryableWithFilter{logger: log.NewNopLogger()}
+		q := NewQueryable(queryable, false /* bypassWithSingleQuerier */, log.NewNopLogger())
+		ctx := InjectIDLabelName(user.InjectOrgID(context.Background(), "team-a|team-b"), "source_tenant")
+
+		querier, err := q.Querier(ctx, mint, maxt)
+		require.NoError(t, err)
+
+		seriesSet := querier.Select(true, &storage.SelectHints{Start: mint, End: maxt}, labels.MustNewMatcher(labels.MatchEqual, "source_tenant", "team-b"))
+		var series []labels.Labels
+		for seriesSet.Next() {
+			series = append(series, seriesSet.At().Labels())
+		}
+		require.NoError(t, seriesSet.Err())
+		require.Equal(t, []labels.Labels{
+			labels.FromStrings("instance", "host1", "source_tenant", "team-b", "tenant-team-b", "static"),
+			labels.FromStrings("instance", "host2.team-b", "source_tenant", "team-b"),
+		}, series)
+	})
 }
 
 var (
@@ -6,6 +6,7 @@
 package tenantfederation
 
 import (
+	"context"
 	"flag"
 
 	"github.com/prometheus/prometheus/model/labels"
@@ -26,6 +27,26 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.Enabled, "tenant-federation.enabled", false, "If enabled on all services, queries can be federated across multiple tenants. The tenant IDs involved need to be specified separated by a '|' character in the 'X-Scope-OrgID' header.")
 }
 
+type contextKey int
+
+const idLabelNameContextKey contextKey = 0
+
+// InjectIDLabelName returns a context overriding the label identifying the
+// tenant of the series returned by the queriers of the merge queryables created
+// with this context.
+func InjectIDLabelName(ctx context.Context, idLabelName string) context.Context {
+	return context.WithValue(ctx, idLabelNameContextKey, idLabelName)
+}
+
+// idLabelNameFromContext returns the label injected by InjectIDLabelName, or
+// defaultIDLabelName if none.
+func idLabelNameFromContext(ctx context.Context, defaultIDLabelName string) string {
+	if idLabelName, _ := ctx.Value(idLabelNameContextKey).(string); idLabelName != "" {
+		return idLabelName
+	}
+	return defaultIDLabelName
+}
+
 // filterValuesByMatchers applies matchers to inputed `idLabelName` and
 // `ids`. A set of matched IDs is returned and also all label matchers not
 // targeting the `idLabelName` label.
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// targetTenantHeader is the header of the requests of the admin tenants acting on the rules of another tenant.
+const targetTenantHeader = "X-Mimir-Target-Tenant"
+
+var errTargetTenantNotAllowed = errors.New("the tenant is not allowed to act on behalf of other tenants")
+
+type AdminOverrideConfig struct {
+	AdminTenants flagext.StringSliceCSV `yaml:"admin_tenants"`
+}
+
+func (cfg *AdminOverrideConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.AdminTenants, "ruler.admin-override.admin-tenants", "Comma separated list of admin tenants allowed to act on the rules of any tenant through the ruler configuration API, by setting the tenant to act on behalf of in the "+targetTenantHeader+" header. Each of these requests is audit logged. If empty, the "+targetTenantHeader+" header is rejected.")
+}
+
+// AdminOverride wraps a handler of the configuration API so that the requests of the admin tenants
+// having the X-Mimir-Target-Tenant header act on the rules of the target tenant.
+func (a *API) AdminOverride(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		target := req.Header.Get(targetTenantHeader)
+		if target == "" {
+			next(w, req)
+			return
+		}
+
+		adminID, err := tenant.TenantID(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !util.StringsContain(a.ruler.cfg.AdminOverride.AdminTenants, adminID) {
+			http.Error(w, errTargetTenantNotAllowed.Error(), http.StatusForbidden)
+			return
+		}
+		if err := tenant.ValidTenantID(target); err != nil {
+			http.Error(w, errors.Wrapf(err, "invalid %s header", targetTenantHeader).Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := user.InjectOrgID(req.Context(), target)
+		level.Info(util_log.WithContext(ctx, a.logger)).Log("msg", "admin tenant acting on behalf of tenant", "admin_tenant", adminID, "target_tenant", target, "method", req.Method, "path", req.URL.Path)
+		next(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_AdminOverride(t *testing.T) {
+	cfg := Config{}
+	cfg.AdminOverride.AdminTenants = []string{"admin"}
+	a := &API{ruler: &Ruler{cfg: cfg}, logger: log.NewNopLogger()}
+
+	tests := map[string]struct {
+		userID         string
+		targetTenant   string
+		expectedStatus int
+		expectedTenant string
+	}{
+		"no target tenant": {
+			userID:         "user1",
+			expectedStatus: http.StatusOK,
+			expectedTenant: "user1",
+		},
+		"admin tenant without target tenant": {
+			userID:         "admin",
+			expectedStatus: http.StatusOK,
+			expectedTenant: "admin",
+		},
+		"admin tenant acting on behalf of a tenant": {
+			userID:         "admin",
+			targetTenant:   "user2",
+			expectedStatus: http.StatusOK,
+			expectedTenant: "user2",
+		},
+		"non admin tenant acting on behalf of a tenant": {
+			userID:         "user1",
+			targetTenant:   "user2",
+			expectedStatus: http.StatusForbidden,
+		},
+		"invalid target tenant": {
+			userID:         "admin",
+			targetTenant:   "user2/..",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var actualTenant string
+			handler := a.AdminOverride(func(w http.ResponseWriter, req *http.Request) {
+				var err error
+				actualTenant, err = tenant.TenantID(req.Context())
+				require.NoError(t, err)
+			})
+
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules", nil, tc.userID)
+			if tc.targetTenant != "" {
+				req.Header.Set(targetTenantHeader, tc.targetTenant)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedTenant, actualTenant)
+		})
+	}
+}
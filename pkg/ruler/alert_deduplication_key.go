@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// AlertDeduplicationKeyFunc returns a function adding to the labels of an alert of the rule group evaluated
+// in the context the label labelName, whose value is the deduplication key of the alert: a hash of the tenant
+// userID, the namespace and the name of the rule group, and the other labels of the alert. The key doesn't
+// depend on the ruler evaluating the rule group, so the Alertmanager deduplicates the alerts sent by multiple
+// rulers evaluating the same rule group, for example while the rule groups are resharded.
+func AlertDeduplicationKeyFunc(labelName, userID string) func(ctx context.Context, lbls labels.Labels) labels.Labels {
+	return func(ctx context.Context, lbls labels.Labels) labels.Labels {
+		b := labels.NewBuilder(lbls)
+		b.Del(labelName)
+		lbls = b.Labels()
+
+		g, _ := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo)
+		return b.Set(labelName, alertDeduplicationKey(userID, g.namespace, g.name, lbls)).Labels()
+	}
+}
+
+// alertDeduplicationKey returns the hash identifying the alert with the labels lbls of a rule group of a tenant.
+func alertDeduplicationKey(userID, namespace, group string, lbls labels.Labels) string {
+	h := fnv.New64a()
+	for _, s := range []string{userID, namespace, group} {
+		_, _ = h.Write([]byte(s))
+		// The separator isn't valid UTF-8, so it can't be part of the tenant, namespace or group.
+		_, _ = h.Write([]byte{0xff})
+	}
+	_, _ = h.Write(lbls.Bytes(nil))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
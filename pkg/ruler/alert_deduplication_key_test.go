@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertDeduplicationKeyFunc(t *testing.T) {
+	const keyLabel = "__alert_key__"
+
+	expr, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+	newGroupContext := func(file, name string) context.Context {
+		return RuleGroupContextFunc(context.Background(), promRules.NewGroup(promRules.GroupOptions{
+			Name:     name,
+			File:     file,
+			Interval: time.Minute,
+			Opts:     &promRules.ManagerOptions{},
+			Rules:    []promRules.Rule{promRules.NewAlertingRule("InstanceDown", expr, 0, nil, nil, nil, "", true, nil)},
+		}))
+	}
+
+	ctx := newGroupContext("/rules/user-1/namespace-1", "group-1")
+	lbls := labels.FromStrings("alertname", "InstanceDown", "instance", "web-1")
+	key := AlertDeduplicationKeyFunc(keyLabel, "user-1")(ctx, lbls).Get(keyLabel)
+	assert.Len(t, key, 16)
+
+	// The key depends only on the tenant, the rule group and the other labels of the alert.
+	assert.Equal(t, key, AlertDeduplicationKeyFunc(keyLabel, "user-1")(newGroupContext("/other-path/user-1/namespace-1", "group-1"), lbls).Get(keyLabel))
+	assert.Equal(t, key, AlertDeduplicationKeyFunc(keyLabel, "user-1")(ctx, labels.FromStrings("alertname", "InstanceDown", "instance", "web-1", keyLabel, "other")).Get(keyLabel))
+
+	for name, other := range map[string]labels.Labels{
+		"other tenant":    AlertDeduplicationKeyFunc(keyLabel, "user-2")(ctx, lbls),
+		"other namespace": AlertDeduplicationKeyFunc(keyLabel, "user-1")(newGroupContext("/rules/user-1/namespace-2", "group-1"), lbls),
+		"other group":     AlertDeduplicationKeyFunc(keyLabel, "user-1")(newGroupContext("/rules/user-1/namespace-1", "group-2"), lbls),
+		"other labels":    AlertDeduplicationKeyFunc(keyLabel, "user-1")(ctx, labels.FromStrings("alertname", "InstanceDown", "instance", "web-2")),
+	} {
+		assert.NotEqual(t, key, other.Get(keyLabel), name)
+	}
+
+	t.Run("labels of the alerts", func(t *testing.T) {
+		var sent []*notifier.Alert
+		notify := SendAlerts(senderFunc(func(alerts ...*notifier.Alert) {
+			sent = append(sent, alerts...)
+		}), func(context.Context) string { return "http://localhost:9090" }, AlertDeduplicationKeyFunc(keyLabel, "user-1"))
+		alert := &promRules.Alert{Labels: lbls, FiredAt: time.Unix(1, 0)}
+		notify(ctx, "up == 0", alert)
+
+		require.Len(t, sent, 1)
+		assert.Equal(t, labels.FromStrings("alertname", "InstanceDown", "instance", "web-1", keyLabel, key), sent[0].Labels)
+		// The labels of the alert of the rule are unchanged.
+		assert.Equal(t, lbls, alert.Labels)
+	})
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// alertGeneratorURLData is the data of the template of the external URL of the alerts of a tenant.
+type alertGeneratorURLData struct {
+	Namespace string
+	Group     string
+}
+
+// TenantAlertGeneratorURLFunc returns a function returning the external URL prefixing the generator URL
+// of the alerts of the rule group evaluated in the context: the ruler_alert_generator_url of the tenant
+// expanded for the rule group, or defaultURL if the tenant has none or it's invalid.
+func TenantAlertGeneratorURLFunc(defaultURL, userID string, limits RulesLimits, logger log.Logger) func(ctx context.Context) string {
+	var (
+		mtx sync.Mutex
+		// The template is parsed again only when the limit of the tenant changes.
+		text string
+		tmpl *template.Template
+		err  error
+	)
+
+	return func(ctx context.Context) string {
+		tmplText := limits.RulerAlertGeneratorURL(userID)
+		if tmplText == "" {
+			return defaultURL
+		}
+
+		mtx.Lock()
+		if tmplText != text {
+			text = tmplText
+			tmpl, err = template.New("alert_generator_url").Option("missingkey=error").Parse(tmplText)
+		}
+		t, parseErr := tmpl, err
+		mtx.Unlock()
+
+		if parseErr != nil {
+			level.Warn(logger).Log("msg", "invalid alert generator URL template of the tenant, using the ruler external URL", "user", userID, "err", parseErr)
+			return defaultURL
+		}
+
+		g, _ := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo)
+		u, expandErr := expandAlertGeneratorURL(t, g.namespace, g.name)
+		if expandErr != nil {
+			level.Warn(logger).Log("msg", "failed to expand the alert generator URL template of the tenant, using the ruler external URL", "user", userID, "namespace", g.namespace, "group", g.name, "err", expandErr)
+			return defaultURL
+		}
+		return u
+	}
+}
+
+// expandAlertGeneratorURL expands the template of the external URL of the alerts of a rule group, and
+// validates the result. The trailing slashes are removed, because the generator URL of an alert is the
+// external URL followed by the path of the query of the alerting rule.
+func expandAlertGeneratorURL(tmpl *template.Template, namespace, group string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, alertGeneratorURLData{Namespace: url.PathEscape(namespace), Group: url.PathEscape(group)}); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(buf.String())
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() {
+		return "", errors.Errorf("the URL %q is not absolute", buf.String())
+	}
+	return strings.TrimRight(buf.String(), "/"), nil
+}
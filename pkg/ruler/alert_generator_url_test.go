@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantAlertGeneratorURLFunc(t *testing.T) {
+	const defaultURL = "http://ruler.example.com"
+
+	expr, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+	g := promRules.NewGroup(promRules.GroupOptions{
+		Name:     "api/alerts",
+		File:     "/rules/user-1/team%20a",
+		Interval: time.Minute,
+		Opts:     &promRules.ManagerOptions{},
+		Rules:    []promRules.Rule{promRules.NewAlertingRule("InstanceDown", expr, 0, nil, nil, nil, "", true, nil)},
+	})
+	ctx := RuleGroupContextFunc(context.Background(), g)
+
+	for name, tc := range map[string]struct {
+		template string
+		expected string
+	}{
+		"no template": {
+			expected: defaultURL,
+		},
+		"static URL": {
+			template: "https://grafana.example.com/",
+			expected: "https://grafana.example.com",
+		},
+		"URL with the namespace and the group": {
+			template: "https://grafana.example.com/{{ .Namespace }}/{{ .Group }}",
+			expected: "https://grafana.example.com/team%20a/api%2Falerts",
+		},
+		"invalid template": {
+			template: "https://grafana.example.com/{{ .Namespace",
+			expected: defaultURL,
+		},
+		"unknown field": {
+			template: "https://grafana.example.com/{{ .Tenant }}",
+			expected: defaultURL,
+		},
+		"relative URL": {
+			template: "/grafana/{{ .Namespace }}",
+			expected: defaultURL,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			externalURL := TenantAlertGeneratorURLFunc(defaultURL, "user-1", ruleLimits{alertGeneratorURL: tc.template}, log.NewNopLogger())
+			assert.Equal(t, tc.expected, externalURL(ctx))
+		})
+	}
+
+	t.Run("generator URL of the alerts", func(t *testing.T) {
+		externalURL := TenantAlertGeneratorURLFunc(defaultURL, "user-1", ruleLimits{alertGeneratorURL: "https://grafana.example.com/{{ .Namespace }}"}, log.NewNopLogger())
+
+		var sent []*notifier.Alert
+		notify := SendAlerts(senderFunc(func(alerts ...*notifier.Alert) {
+			sent = append(sent, alerts...)
+		}), externalURL, nil)
+		notify(ctx, "up == 0", &promRules.Alert{Labels: labels.FromStrings("alertname", "InstanceDown"), FiredAt: time.Unix(1, 0)})
+
+		require.Len(t, sent, 1)
+		assert.Equal(t, "https://grafana.example.com/team%20a/graph?g0.expr=up+%3D%3D+0&g0.tab=1", sent[0].GeneratorURL)
+	})
+}
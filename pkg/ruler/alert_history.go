@@ -0,0 +1,442 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	alertHistoryPrefix = "alerts-history"
+
+	// Layout of the directories grouping the objects of each day.
+	alertHistoryDayLayout = "2006-01-02"
+
+	// How frequently the objects older than the retention are deleted.
+	alertHistoryCleanupInterval = time.Hour
+
+	alertMetricName         = "ALERTS"
+	alertForStateMetricName = "ALERTS_FOR_STATE"
+	alertStateLabel         = "alertstate"
+)
+
+var (
+	errInvalidAlertHistoryFlushInterval = errors.New("invalid ruler alert history flush interval, must be greater than zero")
+	errInvalidAlertHistoryRetention     = errors.New("invalid ruler alert history retention, must be greater or equal to zero")
+	errAlertHistoryDisabled             = errors.New("alert history is disabled")
+)
+
+type AlertHistoryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Retention     time.Duration `yaml:"retention"`
+}
+
+func (cfg *AlertHistoryConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.alert-history.enabled", false, "Record the state transitions of alerts to the ruler storage, and expose them through the alerts history API. Requires an object storage backend for the ruler storage.")
+	f.DurationVar(&cfg.FlushInterval, "ruler.alert-history.flush-interval", time.Minute, "How frequently the recorded alert state transitions are flushed to the ruler storage.")
+	f.DurationVar(&cfg.Retention, "ruler.alert-history.retention", 30*24*time.Hour, "How long the recorded alert state transitions are kept in the ruler storage. The transitions are deleted by day, once the whole day is older than the retention. 0 to keep them forever.")
+}
+
+func (cfg *AlertHistoryConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.FlushInterval <= 0 {
+		return errInvalidAlertHistoryFlushInterval
+	}
+	if cfg.Retention < 0 {
+		return errInvalidAlertHistoryRetention
+	}
+	return nil
+}
+
+// AlertStateTransition is a change of the state of an alert.
+type AlertStateTransition struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Labels    labels.Labels `json:"labels"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+}
+
+// AlertHistory records the state transitions of the alerts of each tenant and stores them in
+// the object storage, where they can be queried from.
+//
+// The transitions are tracked from the ALERTS series written by the rule evaluations: an alert
+// transitions to the state of the first sample of its series, and to inactive when its series
+// is marked stale. An alert which was already active before its first sample, according to its
+// ALERTS_FOR_STATE series, like the alerts restored after a restart of the ruler or moved from
+// another ruler, starts in the state of its first sample instead.
+//
+// The transitions are stored in an object per flush and per day, grouped by day, so that the
+// queries only list the objects of the days they span, and the days older than the retention
+// are deleted at once.
+type AlertHistory struct {
+	services.Service
+
+	cfg        AlertHistoryConfig
+	bucket     objstore.Bucket
+	instanceID string
+	logger     log.Logger
+
+	mtx sync.Mutex
+	// Current state of the alerts of each tenant, by alert labels.
+	states map[string]map[string]string
+	// Transitions not flushed to the object storage yet, by tenant.
+	pending map[string][]AlertStateTransition
+
+	// Last time the objects older than the retention were deleted. Only accessed by the service.
+	lastCleanup time.Time
+
+	transitionsTotal *prometheus.CounterVec
+	flushesFailed    prometheus.Counter
+	cleanupsFailed   prometheus.Counter
+}
+
+func NewAlertHistory(cfg AlertHistoryConfig, bucket objstore.Bucket, instanceID string, logger log.Logger, reg prometheus.Registerer) *AlertHistory {
+	h := &AlertHistory{
+		cfg:        cfg,
+		bucket:     bucket,
+		instanceID: instanceID,
+		logger:     logger,
+		states:     map[string]map[string]string{},
+		pending:    map[string][]AlertStateTransition{},
+		transitionsTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_alert_history_transitions_total",
+			Help: "Total number of alert state transitions recorded by the ruler.",
+		}, []string{"user"}),
+		flushesFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_alert_history_flushes_failed_total",
+			Help: "Total number of failed flushes of the alert state transitions to the ruler storage.",
+		}),
+		cleanupsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_alert_history_cleanups_failed_total",
+			Help: "Total number of failed deletions of the alert state transitions older than the retention from the ruler storage.",
+		}),
+	}
+
+	h.Service = services.NewTimerService(cfg.FlushInterval, nil, h.iteration, h.stopping)
+	return h
+}
+
+func (h *AlertHistory) iteration(ctx context.Context) error {
+	h.flush(ctx)
+
+	if h.cfg.Retention > 0 && time.Since(h.lastCleanup) >= alertHistoryCleanupInterval {
+		h.lastCleanup = time.Now()
+		if err := h.cleanup(ctx, h.lastCleanup.Add(-h.cfg.Retention)); err != nil {
+			h.cleanupsFailed.Inc()
+			level.Warn(h.logger).Log("msg", "failed to delete the alert state transitions older than the retention", "err", err)
+		}
+	}
+	return nil
+}
+
+func (h *AlertHistory) stopping(_ error) error {
+	// Flush the transitions recorded since the last iteration.
+	h.flush(context.Background())
+	return nil
+}
+
+// Pusher returns a Pusher recording the alert state transitions from the series pushed to p.
+func (h *AlertHistory) Pusher(p Pusher) Pusher {
+	return &alertHistoryPusher{Pusher: p, history: h}
+}
+
+type alertHistoryPusher struct {
+	Pusher
+	history *AlertHistory
+}
+
+func (p *alertHistoryPusher) Push(ctx context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	if userID, err := user.ExtractOrgID(ctx); err == nil {
+		p.history.record(userID, req)
+	}
+	return p.Pusher.Push(ctx, req)
+}
+
+func (h *AlertHistory) record(userID string, req *mimirpb.WriteRequest) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	// The time since when the alerts of the request are active, in seconds, by alert labels.
+	var activeAt map[string]float64
+	for _, ts := range req.Timeseries {
+		lbls := mimirpb.FromLabelAdaptersToLabels(ts.Labels)
+		if lbls.Get(labels.MetricName) != alertForStateMetricName || len(ts.Samples) == 0 {
+			continue
+		}
+		if activeAt == nil {
+			activeAt = map[string]float64{}
+		}
+		activeAt[labels.NewBuilder(lbls).Del(labels.MetricName).Labels().String()] = ts.Samples[len(ts.Samples)-1].Value
+	}
+
+	for _, ts := range req.Timeseries {
+		lbls := mimirpb.FromLabelAdaptersToLabels(ts.Labels)
+		if lbls.Get(labels.MetricName) != alertMetricName || len(ts.Samples) == 0 {
+			continue
+		}
+
+		state := lbls.Get(alertStateLabel)
+		alertLabels := labels.NewBuilder(lbls).Del(labels.MetricName, alertStateLabel).Labels()
+		key := alertLabels.String()
+		sample := ts.Samples[len(ts.Samples)-1]
+
+		states := h.states[userID]
+		if states == nil {
+			states = map[string]string{}
+			h.states[userID] = states
+		}
+
+		from, ok := states[key]
+		if !ok {
+			// The alert becomes active at the evaluation writing its first sample, so an alert active since
+			// an earlier evaluation has been restored: its state before the first sample isn't known.
+			if since, active := activeAt[key]; active && !value.IsStaleNaN(sample.Value) && since < float64(sample.TimestampMs/1000) {
+				states[key] = state
+				continue
+			}
+			from = rules.StateInactive.String()
+		}
+
+		to := state
+		if value.IsStaleNaN(sample.Value) {
+			// The series of a previous state of the alert is marked stale after the series
+			// of its new state has been written, so it's a transition only if it's the current state.
+			if from != state {
+				continue
+			}
+			to = rules.StateInactive.String()
+			delete(states, key)
+		} else {
+			if from == state {
+				continue
+			}
+			states[key] = state
+		}
+
+		h.pending[userID] = append(h.pending[userID], AlertStateTransition{
+			Timestamp: util.TimeFromMillis(sample.TimestampMs).UTC(),
+			Labels:    alertLabels,
+			From:      from,
+			To:        to,
+		})
+		h.transitionsTotal.WithLabelValues(userID).Inc()
+	}
+}
+
+// flush uploads the pending transitions of each tenant to the object storage.
+func (h *AlertHistory) flush(ctx context.Context) {
+	h.mtx.Lock()
+	pending := h.pending
+	h.pending = map[string][]AlertStateTransition{}
+	h.mtx.Unlock()
+
+	for userID, transitions := range pending {
+		if err := h.upload(ctx, userID, transitions); err != nil {
+			h.flushesFailed.Inc()
+			level.Warn(h.logger).Log("msg", "failed to flush alert state transitions", "user", userID, "transitions", len(transitions), "err", err)
+
+			// Retry at the next flush.
+			h.mtx.Lock()
+			h.pending[userID] = append(transitions, h.pending[userID]...)
+			h.mtx.Unlock()
+		}
+	}
+}
+
+// upload stores the transitions of the tenant in an object per day.
+func (h *AlertHistory) upload(ctx context.Context, userID string, transitions []AlertStateTransition) error {
+	byDay := map[string][]AlertStateTransition{}
+	for _, t := range transitions {
+		day := t.Timestamp.UTC().Format(alertHistoryDayLayout)
+		byDay[day] = append(byDay[day], t)
+	}
+
+	for day, transitions := range byDay {
+		buf := bytes.Buffer{}
+		enc := json.NewEncoder(&buf)
+		for _, t := range transitions {
+			if err := enc.Encode(t); err != nil {
+				return err
+			}
+		}
+
+		minT, maxT := transitions[0].Timestamp, transitions[0].Timestamp
+		for _, t := range transitions[1:] {
+			if t.Timestamp.Before(minT) {
+				minT = t.Timestamp
+			}
+			if t.Timestamp.After(maxT) {
+				maxT = t.Timestamp
+			}
+		}
+
+		if err := h.bucket.Upload(ctx, alertHistoryObjectName(userID, day, minT, maxT, h.instanceID), &buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alertHistoryObjectName returns the name of the object storing transitions of the day between minT and maxT.
+// The day and the time range are part of the name, so that objects can be filtered when listing them.
+func alertHistoryObjectName(userID, day string, minT, maxT time.Time, instanceID string) string {
+	return path.Join(alertHistoryPrefix, userID, day, fmt.Sprintf("%d-%d-%s.json", util.TimeToMillis(minT), util.TimeToMillis(maxT), instanceID))
+}
+
+// parseAlertHistoryDay returns the time range of the day of the directory.
+func parseAlertHistoryDay(dir string) (start, end time.Time, err error) {
+	start, err = time.Parse(alertHistoryDayLayout, path.Base(dir))
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrapf(err, "invalid alert history directory %q", dir)
+	}
+	return start, start.Add(24 * time.Hour), nil
+}
+
+// parseAlertHistoryObjectName returns the time range of the transitions stored in the object.
+func parseAlertHistoryObjectName(name string) (minT, maxT int64, err error) {
+	parts := strings.SplitN(path.Base(name), "-", 3)
+	if len(parts) != 3 {
+		return 0, 0, errors.Errorf("invalid alert history object name %q", name)
+	}
+	if minT, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid alert history object name %q", name)
+	}
+	if maxT, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid alert history object name %q", name)
+	}
+	return minT, maxT, nil
+}
+
+// Transitions returns the state transitions of the alerts of the tenant within the time range,
+// sorted by timestamp. The transitions not flushed yet are not included.
+func (h *AlertHistory) Transitions(ctx context.Context, userID string, start, end time.Time) ([]AlertStateTransition, error) {
+	startMs, endMs := util.TimeToMillis(start), util.TimeToMillis(end)
+
+	// Only the objects of the days within the time range are listed.
+	var days []string
+	err := h.bucket.Iter(ctx, path.Join(alertHistoryPrefix, userID)+objstore.DirDelim, func(dir string) error {
+		dayStart, dayEnd, err := parseAlertHistoryDay(dir)
+		if err != nil {
+			level.Warn(h.logger).Log("msg", "skipped alert history directory", "user", userID, "err", err)
+			return nil
+		}
+		if !dayEnd.Before(start) && !dayStart.After(end) {
+			days = append(days, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var transitions []AlertStateTransition
+	for _, day := range days {
+		err := h.bucket.Iter(ctx, day, func(name string) error {
+			minT, maxT, err := parseAlertHistoryObjectName(name)
+			if err != nil {
+				level.Warn(h.logger).Log("msg", "skipped alert history object", "user", userID, "err", err)
+				return nil
+			}
+			if maxT < startMs || minT > endMs {
+				return nil
+			}
+
+			r, err := h.bucket.Get(ctx, name)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read alert history object %q", name)
+			}
+			defer r.Close()
+
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				var t AlertStateTransition
+				if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+					return errors.Wrapf(err, "failed to decode alert history object %q", name)
+				}
+				if t.Timestamp.Before(start) || t.Timestamp.After(end) {
+					continue
+				}
+				transitions = append(transitions, t)
+			}
+			return scanner.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].Timestamp.Before(transitions[j].Timestamp)
+	})
+	return transitions, nil
+}
+
+// cleanup deletes the objects of the days of all the tenants which ended before the threshold. The rulers
+// delete the objects concurrently, so the objects already deleted by another ruler are ignored.
+func (h *AlertHistory) cleanup(ctx context.Context, threshold time.Time) error {
+	var users []string
+	err := h.bucket.Iter(ctx, alertHistoryPrefix+objstore.DirDelim, func(dir string) error {
+		if strings.HasSuffix(dir, objstore.DirDelim) {
+			users = append(users, path.Base(dir))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range users {
+		var days []string
+		err := h.bucket.Iter(ctx, path.Join(alertHistoryPrefix, userID)+objstore.DirDelim, func(dir string) error {
+			if _, dayEnd, err := parseAlertHistoryDay(dir); err == nil && dayEnd.Before(threshold) {
+				days = append(days, dir)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, day := range days {
+			err := h.bucket.Iter(ctx, day, func(name string) error {
+				if err := h.bucket.Delete(ctx, name); err != nil && !h.bucket.IsObjNotFoundErr(err) {
+					return errors.Wrapf(err, "failed to delete alert history object %q", name)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestAlertHistory(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	reg := prometheus.NewPedanticRegistry()
+	h := NewAlertHistory(AlertHistoryConfig{Enabled: true, FlushInterval: time.Minute}, bkt, "ruler-1", log.NewNopLogger(), reg)
+
+	pusher := h.Pusher(&fakePusher{response: &mimirpb.WriteResponse{}})
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	alert := func(state string) labels.Labels {
+		return labels.FromStrings(labels.MetricName, "ALERTS", "alertname", "HighLatency", "alertstate", state, "job", "api")
+	}
+	push := func(ts time.Time, series map[string]float64) {
+		var lbls []labels.Labels
+		var samples []mimirpb.Sample
+		for state, v := range series {
+			lbls = append(lbls, alert(state))
+			samples = append(samples, mimirpb.Sample{TimestampMs: util.TimeToMillis(ts), Value: v})
+		}
+		// Series not related to alerts are ignored.
+		lbls = append(lbls, labels.FromStrings(labels.MetricName, "job:up:sum", "job", "api"))
+		samples = append(samples, mimirpb.Sample{TimestampMs: util.TimeToMillis(ts), Value: 1})
+
+		_, err := pusher.Push(ctx, mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.RULE))
+		require.NoError(t, err)
+	}
+
+	stale := math.Float64frombits(value.StaleNaN)
+	t0 := time.Unix(1000, 0).UTC()
+	push(t0, map[string]float64{"pending": 1})
+	push(t0.Add(time.Minute), map[string]float64{"pending": 1})
+	push(t0.Add(2*time.Minute), map[string]float64{"firing": 1})
+	push(t0.Add(2*time.Minute), map[string]float64{"pending": stale})
+	push(t0.Add(3*time.Minute), map[string]float64{"firing": 1})
+	push(t0.Add(4*time.Minute), map[string]float64{"firing": stale})
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(h.transitionsTotal.WithLabelValues("user-1")))
+
+	// Nothing is returned until the transitions are flushed.
+	transitions, err := h.Transitions(context.Background(), "user-1", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+
+	h.flush(context.Background())
+	assert.Len(t, bkt.Objects(), 1)
+
+	alertLabels := labels.FromStrings("alertname", "HighLatency", "job", "api")
+	transitions, err = h.Transitions(context.Background(), "user-1", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []AlertStateTransition{
+		{Timestamp: t0, Labels: alertLabels, From: "inactive", To: "pending"},
+		{Timestamp: t0.Add(2 * time.Minute), Labels: alertLabels, From: "pending", To: "firing"},
+		{Timestamp: t0.Add(4 * time.Minute), Labels: alertLabels, From: "firing", To: "inactive"},
+	}, transitions)
+
+	// Transitions are filtered by time range.
+	transitions, err = h.Transitions(context.Background(), "user-1", t0.Add(time.Minute), t0.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []AlertStateTransition{
+		{Timestamp: t0.Add(2 * time.Minute), Labels: alertLabels, From: "pending", To: "firing"},
+	}, transitions)
+
+	transitions, err = h.Transitions(context.Background(), "user-1", t0.Add(time.Hour), t0.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+
+	// Transitions are isolated by tenant.
+	transitions, err = h.Transitions(context.Background(), "user-2", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+}
+
+func TestAlertHistory_RestoredAlerts(t *testing.T) {
+	h := NewAlertHistory(AlertHistoryConfig{Enabled: true, FlushInterval: time.Minute}, objstore.NewInMemBucket(), "ruler-1", log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	pusher := h.Pusher(&fakePusher{response: &mimirpb.WriteResponse{}})
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	push := func(ts time.Time, alertname, state string, activeAt time.Time) {
+		lbls := []labels.Labels{
+			labels.FromStrings(labels.MetricName, "ALERTS", "alertname", alertname, "alertstate", state),
+			labels.FromStrings(labels.MetricName, "ALERTS_FOR_STATE", "alertname", alertname),
+		}
+		samples := []mimirpb.Sample{
+			{TimestampMs: util.TimeToMillis(ts), Value: 1},
+			{TimestampMs: util.TimeToMillis(ts), Value: float64(activeAt.Unix())},
+		}
+		_, err := pusher.Push(ctx, mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.RULE))
+		require.NoError(t, err)
+	}
+
+	t0 := time.Unix(1000, 0).UTC()
+	// The alert becomes active at its first evaluation.
+	push(t0, "New", "firing", t0)
+	// The alert was already active before its first sample, as restored after a restart of the ruler.
+	push(t0, "Restored", "firing", t0.Add(-time.Hour))
+	push(t0.Add(time.Minute), "Restored", "firing", t0.Add(-time.Hour))
+
+	h.flush(context.Background())
+	transitions, err := h.Transitions(context.Background(), "user-1", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []AlertStateTransition{
+		{Timestamp: t0, Labels: labels.FromStrings("alertname", "New"), From: "inactive", To: "firing"},
+	}, transitions)
+}
+
+func TestAlertHistory_Days(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	reg := prometheus.NewPedanticRegistry()
+	h := NewAlertHistory(AlertHistoryConfig{Enabled: true, FlushInterval: time.Minute, Retention: 24 * time.Hour}, bkt, "ruler-1", log.NewNopLogger(), reg)
+	pusher := h.Pusher(&fakePusher{response: &mimirpb.WriteResponse{}})
+
+	push := func(userID string, ts time.Time, state string, v float64) {
+		lbls := []labels.Labels{labels.FromStrings(labels.MetricName, "ALERTS", "alertname", "HighLatency", "alertstate", state)}
+		samples := []mimirpb.Sample{{TimestampMs: util.TimeToMillis(ts), Value: v}}
+		_, err := pusher.Push(user.InjectOrgID(context.Background(), userID), mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.RULE))
+		require.NoError(t, err)
+	}
+
+	stale := math.Float64frombits(value.StaleNaN)
+	day1 := time.Date(2022, 3, 1, 23, 0, 0, 0, time.UTC)
+	day2 := time.Date(2022, 3, 2, 1, 0, 0, 0, time.UTC)
+	push("user-1", day1, "firing", 1)
+	push("user-1", day2, "firing", stale)
+	push("user-2", day1, "firing", 1)
+	h.flush(context.Background())
+
+	// The transitions flushed at once are stored in an object per day.
+	names := map[string]bool{}
+	for name := range bkt.Objects() {
+		names[name] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"alerts-history/user-1/2022-03-01/" + "1646175600000-1646175600000-ruler-1.json": true,
+		"alerts-history/user-1/2022-03-02/" + "1646182800000-1646182800000-ruler-1.json": true,
+		"alerts-history/user-2/2022-03-01/" + "1646175600000-1646175600000-ruler-1.json": true,
+	}, names)
+
+	// Only the objects of the days within the time range are read.
+	require.NoError(t, bkt.Upload(context.Background(), "alerts-history/user-1/2022-03-02/0-9999999999999-ruler-2.json", bytes.NewReader([]byte("corrupted"))))
+	transitions, err := h.Transitions(context.Background(), "user-1", day1.Add(-time.Hour), day1.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []AlertStateTransition{
+		{Timestamp: day1, Labels: labels.FromStrings("alertname", "HighLatency"), From: "inactive", To: "firing"},
+	}, transitions)
+
+	_, err = h.Transitions(context.Background(), "user-1", day1, day2)
+	require.Error(t, err)
+
+	// The days which ended before the threshold are deleted.
+	require.NoError(t, h.cleanup(context.Background(), day2))
+	names = map[string]bool{}
+	for name := range bkt.Objects() {
+		names[name] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"alerts-history/user-1/2022-03-02/" + "1646182800000-1646182800000-ruler-1.json": true,
+		"alerts-history/user-1/2022-03-02/0-9999999999999-ruler-2.json":                 true,
+	}, names)
+
+	// The cleanup runs with the flushes, deleting the days older than the retention.
+	require.NoError(t, h.iteration(context.Background()))
+	assert.Empty(t, bkt.Objects())
+	assert.Equal(t, float64(0), testutil.ToFloat64(h.cleanupsFailed))
+}
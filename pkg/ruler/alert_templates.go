@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+const alertTemplatesGroupKey contextKey = 11
+
+// alertTemplatesEvaluation tracks the query of the rule being evaluated in a rule group, to tell it apart
+// from the queries of the templates of the rule.
+type alertTemplatesEvaluation struct {
+	group *rules.Group
+
+	mtx sync.Mutex
+	// Context and timestamp of the last query of a rule.
+	ruleCtx       context.Context
+	ruleQueryTime time.Time
+}
+
+// isTemplateQuery returns whether the query run with ctx at t is run by the templates of the rule whose
+// query was run last, and records the query as the query of a rule otherwise.
+//
+// The Prometheus rules manager evaluates the rules of a group one at a time, each with its own context,
+// and an alerting rule runs its query before the queries of its templates, with the same context. The
+// queries of the templates are run at the evaluation timestamp, so the query of the rule plus the
+// evaluation delay.
+func (e *alertTemplatesEvaluation) isTemplateQuery(ctx context.Context, t time.Time, evaluationDelay time.Duration) bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if ctx == e.ruleCtx && t.UnixMilli() == e.ruleQueryTime.Add(evaluationDelay).UnixMilli() {
+		return true
+	}
+	e.ruleCtx = ctx
+	e.ruleQueryTime = t
+	return false
+}
+
+// AlertTemplatesContextFunc injects in the context of each rule group the group itself, used by
+// AlertTemplatesQueryFunc to run the queries of the alert templates of the group.
+func AlertTemplatesContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	return context.WithValue(ctx, alertTemplatesGroupKey, &alertTemplatesEvaluation{group: g})
+}
+
+// AlertTemplatesQueryFunc returns a rules.QueryFunc running the queries of the query function of the
+// templates of the labels and annotations of the alerting rules as of the queries of the rules.
+//
+// The Prometheus rules manager runs the queries of the templates with the query function of the rule
+// group, so they're subject to the limits of the tenant and read the source tenants of a federated
+// rule group, but at the evaluation timestamp, while the queries of the rules are run at the evaluation
+// timestamp minus the evaluation delay. The queries of the templates are run at the evaluation timestamp
+// minus the evaluation delay too, so that the templates read the same samples as the rules, and the
+// queries run by an evaluation have a single timestamp.
+func AlertTemplatesQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		e, ok := ctx.Value(alertTemplatesGroupKey).(*alertTemplatesEvaluation)
+		if !ok {
+			return qf(ctx, qs, t)
+		}
+		evaluationDelay := e.group.EvaluationDelay()
+		if evaluationDelay <= 0 || !e.isTemplateQuery(ctx, t, evaluationDelay) {
+			return qf(ctx, qs, t)
+		}
+		return qf(ctx, qs, t.Add(-evaluationDelay))
+	}
+}
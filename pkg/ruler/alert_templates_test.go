@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestAlertTemplatesQueryFunc(t *testing.T) {
+	expr, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+
+	// The template runs the same query as the rule.
+	annotations := labels.FromStrings("down", `{{ with query "up == 0" }}{{ . | len }}{{ end }}`)
+	rule := rules.NewAlertingRule("InstanceDown", expr, 0, nil, annotations, nil, "", true, nil)
+
+	evaluationDelay := time.Minute
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:            "group-1",
+		File:            "/rules/user-1/namespace-1",
+		Interval:        time.Minute,
+		EvaluationDelay: &evaluationDelay,
+		Opts:            &rules.ManagerOptions{},
+		Rules:           []rules.Rule{rule},
+	})
+
+	var queried []int64
+	qf := AlertTemplatesQueryFunc(func(_ context.Context, qs string, t time.Time) (promql.Vector, error) {
+		queried = append(queried, t.UnixMilli())
+		return promql.Vector{{Point: promql.Point{T: t.UnixMilli(), V: 0}, Metric: labels.FromStrings("__name__", "up")}}, nil
+	})
+
+	ts := time.Unix(3600, 0)
+
+	// The queries are unchanged outside of the evaluations of the rule groups.
+	_, err = qf(context.Background(), "sum(up)", ts)
+	require.NoError(t, err)
+	require.Equal(t, []int64{ts.UnixMilli()}, queried)
+
+	// The queries of the templates are run with the evaluation delay, unlike the queries of the rules
+	// which already have it, even when they're identical.
+	queried = nil
+	ctx := ChainGroupEvaluationContextFuncs(RuleGroupContextFunc, AlertTemplatesContextFunc)(context.Background(), g)
+	_, err = rule.Eval(ctx, evaluationDelay, ts, qf, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ts.Add(-evaluationDelay).UnixMilli(), ts.Add(-evaluationDelay).UnixMilli()}, queried)
+
+	// The query of the rule of the next evaluation isn't taken for a query of the templates, the rules
+	// manager evaluating each rule with its own context.
+	queried = nil
+	ts = ts.Add(time.Minute)
+	sp, ruleCtx := opentracing.StartSpanFromContext(ctx, "rule")
+	defer sp.Finish()
+	_, err = rule.Eval(ruleCtx, evaluationDelay, ts, qf, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ts.Add(-evaluationDelay).UnixMilli(), ts.Add(-evaluationDelay).UnixMilli()}, queried)
+
+	alerts := rule.ActiveAlerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "1", alerts[0].Annotations.Get("down"))
+}
+
+func TestAlertTemplates_PrometheusTemplateFunctions(t *testing.T) {
+	const userID = "user-1"
+
+	expr, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+
+	annotations := labels.FromMap(map[string]string{
+		"summary":     `{{ define "instance" }}{{ .Labels.instance }}{{ end }}{{ template "instance" . }} of {{ $labels.job }} is down ({{ $value }})`,
+		"query":       `{{ with query "sum(up)" }}{{ . | first | value | humanize }}{{ end }}`,
+		"sorted":      `{{ range query "up" | sortByLabel "instance" }}{{ label "instance" . }} {{ end }}`,
+		"humanize":    `{{ 1234567 | humanize }} {{ 1048576 | humanize1024 }} {{ 3600 | humanizeDuration }} {{ 0.25 | humanizePercentage }} {{ 0 | humanizeTimestamp }}`,
+		"links":       `{{ externalURL }}/graph {{ pathPrefix }}`,
+		"stringUtils": `{{ "web-1:9090" | stripPort }} {{ reReplaceAll "-[0-9]+" "" "web-1" }} {{ "web" | title }}`,
+	})
+	rule := rules.NewAlertingRule("InstanceDown", expr, 0, labels.FromStrings("severity", "{{ if gt $value 0.0 }}warning{{ else }}critical{{ end }}"), annotations, nil, "", true, nil)
+
+	evaluationDelay := time.Minute
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:            "group-1",
+		File:            "/rules/user-1/namespace-1",
+		Interval:        time.Minute,
+		EvaluationDelay: &evaluationDelay,
+		Opts:            &rules.ManagerOptions{},
+		Rules:           []rules.Rule{rule},
+	})
+
+	ts := time.Unix(3600, 0).UTC()
+	queried := map[string]time.Time{}
+	tenants := map[string]string{}
+	qf := AlertTemplatesQueryFunc(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		tenants[qs], _ = user.ExtractOrgID(ctx)
+		queried[qs] = t
+
+		switch qs {
+		case "up == 0":
+			return promql.Vector{{Point: promql.Point{T: t.UnixMilli(), V: 0}, Metric: labels.FromStrings("__name__", "up", "instance", "web-1:9090", "job", "web")}}, nil
+		case "sum(up)":
+			return promql.Vector{{Point: promql.Point{T: t.UnixMilli(), V: 1500}, Metric: labels.Labels{}}}, nil
+		case "up":
+			return promql.Vector{
+				{Point: promql.Point{T: t.UnixMilli(), V: 1}, Metric: labels.FromStrings("instance", "web-2:9090")},
+				{Point: promql.Point{T: t.UnixMilli(), V: 0}, Metric: labels.FromStrings("instance", "web-1:9090")},
+			}, nil
+		}
+		return nil, nil
+	})
+
+	externalURL, err := url.Parse("https://mimir.example.com/prometheus")
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	ctx = ChainGroupEvaluationContextFuncs(RuleGroupContextFunc, AlertTemplatesContextFunc)(ctx, g)
+	_, err = rule.Eval(ctx, evaluationDelay, ts, qf, externalURL, 0)
+	require.NoError(t, err)
+
+	alerts := rule.ActiveAlerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "critical", alerts[0].Labels.Get("severity"))
+	assert.Equal(t, map[string]string{
+		"summary":     "web-1:9090 of web is down (0)",
+		"query":       "1.5k",
+		"sorted":      "web-1:9090 web-2:9090 ",
+		"humanize":    "1.235M 1Mi 1h 0m 0s 25% 1970-01-01 00:00:00 +0000 UTC",
+		"links":       "https://mimir.example.com/prometheus/graph /prometheus",
+		"stringUtils": "web-1 web Web",
+	}, alerts[0].Annotations.Map())
+
+	// The queries of the templates are run for the tenant, and read the same samples as the query of the rule.
+	require.Len(t, queried, 3)
+	for qs, queryTime := range queried {
+		assert.Equal(t, ts.Add(-evaluationDelay).UnixMilli(), queryTime.UnixMilli(), qs)
+		assert.Equal(t, userID, tenants[qs], qs)
+	}
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+const (
+	tenantDroppedAlerts contextKey = 12
+	alertsLimiterKey    contextKey = 13
+)
+
+// droppedAlerts holds the number of alerts dropped at the latest evaluation of each alerting rule of
+// the rule groups of a tenant, because the rule exceeded the maximum number of alerts per rule.
+type droppedAlerts struct {
+	mtx    sync.Mutex
+	groups map[string][]int64 // By group key, then by rule index.
+}
+
+func newDroppedAlerts() *droppedAlerts {
+	return &droppedAlerts{groups: map[string][]int64{}}
+}
+
+// set sets the number of alerts dropped by the rules at indexes of the group with the given key,
+// out of numRules rules.
+func (d *droppedAlerts) set(key string, numRules int, indexes []int, dropped int64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	counts := d.groups[key]
+	if len(counts) != numRules {
+		counts = make([]int64, numRules)
+		d.groups[key] = counts
+	}
+	for _, i := range indexes {
+		counts[i] = dropped
+	}
+}
+
+// get returns the number of alerts dropped by each rule of the group with the given key, by rule
+// index, or nil if none were ever dropped.
+func (d *droppedAlerts) get(key string) []int64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	counts, ok := d.groups[key]
+	if !ok {
+		return nil
+	}
+	return append([]int64(nil), counts...)
+}
+
+// retain removes the dropped alerts of the rule groups not in groups.
+func (d *droppedAlerts) retain(groups []*rules.Group) {
+	keep := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		keep[rules.GroupKey(g.File(), g.Name())] = struct{}{}
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	for key := range d.groups {
+		if _, ok := keep[key]; !ok {
+			delete(d.groups, key)
+		}
+	}
+}
+
+// AlertsLimitContextFunc returns a rules.ContextWrapFunc injecting in the context of each rule group with
+// alerting rules a limiter used by AlertsLimitQueryFunc to apply to them the maximum number of alerts per
+// rule returned by limit.
+func AlertsLimitContextFunc(limit func() int) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		// The queries shared with a recording rule aren't limited, because their results are written.
+		recorded := map[string]struct{}{}
+		for _, r := range g.Rules() {
+			if _, ok := r.(*rules.RecordingRule); ok {
+				recorded[r.Query().String()] = struct{}{}
+			}
+		}
+
+		rulesByQuery := map[string][]int{}
+		for i, r := range g.Rules() {
+			if _, ok := r.(*rules.AlertingRule); !ok {
+				continue
+			}
+			qs := r.Query().String()
+			if _, ok := recorded[qs]; ok {
+				continue
+			}
+			rulesByQuery[qs] = append(rulesByQuery[qs], i)
+		}
+		if len(rulesByQuery) == 0 {
+			return ctx
+		}
+
+		counts, _ := ctx.Value(tenantDroppedAlerts).(*droppedAlerts)
+		return context.WithValue(ctx, alertsLimiterKey, &alertsLimiter{
+			group:        g,
+			limit:        limit,
+			counts:       counts,
+			rulesByQuery: rulesByQuery,
+			kept:         map[string]map[uint64]struct{}{},
+		})
+	}
+}
+
+// AlertsLimitQueryFunc returns a rules.QueryFunc limiting the number of series returned by the queries of
+// the alerting rules of the evaluated rule group, which are the alerts of the rules, to the maximum number of
+// alerts per rule of the tenant. The series of the alerts kept by the previous evaluation are kept first, so
+// that the active alerts aren't resolved and fired again as the other series come and go. The number of
+// dropped alerts is counted by dropped.
+func AlertsLimitQueryFunc(qf rules.QueryFunc, dropped prometheus.Counter, logger log.Logger) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		vector, err := qf(ctx, qs, t)
+		if err != nil {
+			return vector, err
+		}
+		l, ok := ctx.Value(alertsLimiterKey).(*alertsLimiter)
+		if !ok {
+			return vector, nil
+		}
+
+		limited, numDropped, numRules := l.apply(qs, vector)
+		if numDropped > 0 {
+			dropped.Add(float64(numDropped * numRules))
+			level.Warn(logger).Log("msg", "dropped alerts of alerting rules exceeding the maximum number of alerts per rule", "group", l.group.Name(), "query", qs, "limit", len(limited), "dropped", numDropped)
+		}
+		return limited, nil
+	}
+}
+
+type alertsLimiter struct {
+	group  *rules.Group
+	limit  func() int
+	counts *droppedAlerts
+	// Indexes of the alerting rules of the group, by query.
+	rulesByQuery map[string][]int
+
+	mtx sync.Mutex
+	// Hashes of the series kept by the previous evaluation, by query.
+	kept map[string]map[uint64]struct{}
+}
+
+// apply limits the series of vector, the result of the query qs, if qs is the query of alerting rules. It returns
+// the limited series, the number of series dropped and the number of rules whose alerts they are.
+func (l *alertsLimiter) apply(qs string, vector promql.Vector) (promql.Vector, int, int) {
+	indexes, ok := l.rulesByQuery[qs]
+	if !ok {
+		return vector, 0, 0
+	}
+	limit := l.limit()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	limited := vector
+	if limit > 0 && len(vector) > limit {
+		// The vector isn't sorted in place, because it may be shared with other evaluations by the query results cache.
+		previous := l.kept[qs]
+		var kept, others promql.Vector
+		for _, s := range vector {
+			if _, ok := previous[s.Metric.Hash()]; ok {
+				kept = append(kept, s)
+			} else {
+				others = append(others, s)
+			}
+		}
+		sortSeriesByLabels(kept)
+		sortSeriesByLabels(others)
+		limited = append(kept, others...)[:limit]
+	}
+
+	if limit > 0 {
+		hashes := make(map[uint64]struct{}, len(limited))
+		for _, s := range limited {
+			hashes[s.Metric.Hash()] = struct{}{}
+		}
+		l.kept[qs] = hashes
+	} else {
+		delete(l.kept, qs)
+	}
+
+	numDropped := len(vector) - len(limited)
+	if l.counts != nil {
+		l.counts.set(rules.GroupKey(l.group.File(), l.group.Name()), len(l.group.Rules()), indexes, int64(numDropped))
+	}
+	return limited, numDropped, len(indexes)
+}
+
+func sortSeriesByLabels(v promql.Vector) {
+	sort.Slice(v, func(i, j int) bool {
+		return labels.Compare(v[i].Metric, v[j].Metric) < 0
+	})
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertsLimitQueryFunc(t *testing.T) {
+	recording, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+	alerting, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:     "group-1",
+		File:     "/rules/user-1/namespace-1",
+		Interval: time.Minute,
+		Opts:     &rules.ManagerOptions{},
+		Rules: []rules.Rule{
+			rules.NewRecordingRule("up:sum", recording, nil),
+			rules.NewAlertingRule("InstanceDown", alerting, 0, nil, nil, nil, "", false, log.NewNopLogger()),
+		},
+	})
+
+	series := func(instances ...string) promql.Vector {
+		v := make(promql.Vector, 0, len(instances))
+		for _, instance := range instances {
+			v = append(v, promql.Sample{Metric: labels.FromStrings("__name__", "up", "instance", instance)})
+		}
+		return v
+	}
+	instances := func(v promql.Vector) []string {
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			res = append(res, s.Metric.Get("instance"))
+		}
+		return res
+	}
+
+	var results map[string]promql.Vector
+	limit := 2
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{})
+	qf := AlertsLimitQueryFunc(func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		return results[qs], nil
+	}, dropped, log.NewNopLogger())
+
+	counts := newDroppedAlerts()
+	ctx := context.WithValue(context.Background(), tenantDroppedAlerts, counts)
+	ctx = AlertsLimitContextFunc(func() int { return limit })(ctx, g)
+	key := rules.GroupKey(g.File(), g.Name())
+
+	// The queries of the recording rules aren't limited.
+	results = map[string]promql.Vector{"sum(up)": series("a", "b", "c")}
+	v, err := qf(ctx, "sum(up)", time.Now())
+	require.NoError(t, err)
+	assert.Len(t, v, 3)
+	assert.Nil(t, counts.get(key))
+
+	// The alerts beyond the limit are dropped in the order of their labels.
+	results = map[string]promql.Vector{"up == 0": series("d", "c", "b")}
+	v, err = qf(ctx, "up == 0", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, instances(v))
+	assert.Equal(t, []int64{0, 1}, counts.get(key))
+	assert.Equal(t, 1.0, testutil.ToFloat64(dropped))
+	// The query result isn't modified.
+	assert.Equal(t, []string{"d", "c", "b"}, instances(results["up == 0"]))
+
+	// The alerts kept by the previous evaluation are kept first.
+	results = map[string]promql.Vector{"up == 0": series("a", "b", "c", "d")}
+	v, err = qf(ctx, "up == 0", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, instances(v))
+	assert.Equal(t, []int64{0, 2}, counts.get(key))
+	assert.Equal(t, 3.0, testutil.ToFloat64(dropped))
+
+	// No alerts are dropped when the limit is disabled.
+	limit = 0
+	v, err = qf(ctx, "up == 0", time.Now())
+	require.NoError(t, err)
+	assert.Len(t, v, 4)
+	assert.Equal(t, []int64{0, 0}, counts.get(key))
+	assert.Equal(t, 3.0, testutil.ToFloat64(dropped))
+
+	// The queries outside of the evaluations of the rule groups aren't limited.
+	limit = 1
+	v, err = qf(context.Background(), "up == 0", time.Now())
+	require.NoError(t, err)
+	assert.Len(t, v, 4)
+
+	counts.retain(nil)
+	assert.Nil(t, counts.get(key))
+}
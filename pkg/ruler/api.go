@@ -6,13 +6,21 @@
 package ruler
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -21,15 +29,18 @@ import (
 	"github.com/pkg/errors"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
 	"github.com/weaveworks/common/user"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/tenant"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
@@ -42,6 +53,7 @@ type response struct {
 	Data      interface{}  `json:"data"`
 	ErrorType v1.ErrorType `json:"errorType"`
 	Error     string       `json:"error"`
+	Warnings  []string     `json:"warnings,omitempty"`
 }
 
 // AlertDiscovery has info for all active alerts.
@@ -75,6 +87,46 @@ type RuleGroup struct {
 	LastEvaluation time.Time `json:"lastEvaluation"`
 	EvaluationTime float64   `json:"evaluationTime"`
 	SourceTenants  []string  `json:"sourceTenants"`
+	// Limit is the maximum number of alerts or series produced by each rule of the group, 0 if unlimited.
+	Limit int64 `json:"limit"`
+	// MissedIterations is the number of iterations of the group missed because a previous
+	// evaluation took longer than the interval of the group.
+	MissedIterations int64 `json:"missedIterations"`
+	// Tenant is the tenant of the group, only returned to the requests of multiple tenants.
+	Tenant string `json:"tenant,omitempty"`
+	// LastConfigUpdate is the time the configuration of the group was last updated in the rule store.
+	// It's unknown for the groups last updated before it was tracked.
+	LastConfigUpdate *time.Time `json:"lastConfigUpdate,omitempty"`
+	// Federation has the federation settings of the group, only returned for the federated rule groups.
+	Federation *RuleGroupFederation `json:"federation,omitempty"`
+}
+
+// RuleGroupFederation has the effective federation settings of a federated rule group, so that the
+// reasons why its evaluations fail, or don't read some series of a source tenant, can be explained.
+type RuleGroupFederation struct {
+	// Enabled is whether the federated rule groups are evaluated.
+	Enabled       bool     `json:"enabled"`
+	SourceTenants []string `json:"sourceTenants"`
+	// DestTenants are the tenants the series of the recording rules are written to, which is the
+	// tenant of the group unless the group has destination tenants.
+	DestTenants       []string `json:"destTenants"`
+	SourceTenantLabel string   `json:"sourceTenantLabel"`
+	// SourceTenantsStatus has the restrictions of each source tenant other than the tenant of the group.
+	SourceTenantsStatus []FederationSourceTenantStatus `json:"sourceTenantsStatus"`
+}
+
+// FederationSourceTenantStatus has the restrictions of a source tenant of a federated rule group.
+type FederationSourceTenantStatus struct {
+	Tenant string `json:"tenant"`
+	// Consent is "granted" or "missing" when the federation consents are enabled, "unknown" if they
+	// couldn't be read, and "not_required" when they are disabled.
+	Consent string `json:"consent"`
+	// AllowedMetrics and BlockedMetrics are the regular expressions of the metric names of the source
+	// tenant readable by the group, empty if unrestricted.
+	AllowedMetrics string `json:"allowedMetrics,omitempty"`
+	BlockedMetrics string `json:"blockedMetrics,omitempty"`
+	// Error is the reason why the queries of the group fail for the source tenant, if any.
+	Error string `json:"error,omitempty"`
 }
 
 type rule interface{}
@@ -93,6 +145,11 @@ type alertingRule struct {
 	Type           v1.RuleType   `json:"type"`
 	LastEvaluation time.Time     `json:"lastEvaluation"`
 	EvaluationTime float64       `json:"evaluationTime"`
+	// Interval is the evaluation interval of the rule, if longer than the interval of its group.
+	Interval float64 `json:"interval,omitempty"`
+	// DroppedAlerts is the number of alerts dropped at the latest evaluation of the rule, because
+	// it exceeded the maximum number of alerts per rule of the tenant.
+	DroppedAlerts int64 `json:"droppedAlerts,omitempty"`
 }
 
 type recordingRule struct {
@@ -104,6 +161,8 @@ type recordingRule struct {
 	Type           v1.RuleType   `json:"type"`
 	LastEvaluation time.Time     `json:"lastEvaluation"`
 	EvaluationTime float64       `json:"evaluationTime"`
+	// Interval is the evaluation interval of the rule, if longer than the interval of its group.
+	Interval float64 `json:"interval,omitempty"`
 }
 
 func respondError(logger log.Logger, w http.ResponseWriter, msg string) {
@@ -131,6 +190,9 @@ type API struct {
 	ruler *Ruler
 	store rulestore.RuleStore
 
+	// Validates the API tokens of the requests to the configuration API, unless nil.
+	tokenValidator TokenValidator
+
 	logger log.Logger
 }
 
@@ -145,84 +207,173 @@ func NewAPI(r *Ruler, s rulestore.RuleStore, logger log.Logger) *API {
 
 func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), a.logger)
-	userID, err := tenant.TenantID(req.Context())
-	if err != nil || userID == "" {
+	tenantIDs, err := tenant.TenantIDs(req.Context())
+	if err != nil || len(tenantIDs) == 0 {
 		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
 		respondError(logger, w, "no valid org id found")
 		return
 	}
+	if len(tenantIDs) > 1 && !a.ruler.cfg.TenantFederation.Enabled {
+		http.Error(w, ErrTenantFederationDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := parseRulesAPIVersion(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	rgs, err := a.ruler.GetRules(req.Context())
+	rgs, err := a.getTenantsRules(req.Context(), tenantIDs)
 
 	if err != nil {
 		respondError(logger, w, err.Error())
 		return
 	}
 
-	groups := make([]*RuleGroup, 0, len(rgs))
+	// The rule groups are gathered from several rulers, so they're sorted to be returned in the same order
+	// whichever ruler serves the request. The rules are kept in their declaration order.
+	sortGroupStateDescs(rgs)
+	federated := len(tenantIDs) > 1
 
-	for _, g := range rgs {
-		grp := RuleGroup{
-			Name:           g.Group.Name,
-			File:           g.Group.Namespace,
-			Rules:          make([]rule, len(g.ActiveRules)),
-			Interval:       g.Group.Interval.Seconds(),
-			LastEvaluation: g.GetEvaluationTimestamp(),
-			EvaluationTime: g.GetEvaluationDuration().Seconds(),
-			SourceTenants:  g.Group.GetSourceTenants(),
-		}
-
-		for i, rl := range g.ActiveRules {
-			if g.ActiveRules[i].Rule.Alert != "" {
-				alerts := make([]*Alert, 0, len(rl.Alerts))
-				for _, a := range rl.Alerts {
-					alerts = append(alerts, &Alert{
-						Labels:      mimirpb.FromLabelAdaptersToLabels(a.Labels),
-						Annotations: mimirpb.FromLabelAdaptersToLabels(a.Annotations),
-						State:       a.GetState(),
-						ActiveAt:    &a.ActiveAt,
-						Value:       strconv.FormatFloat(a.Value, 'e', -1, 64),
-					})
-				}
-				grp.Rules[i] = alertingRule{
-					State:          rl.GetState(),
-					Name:           rl.Rule.GetAlert(),
-					Query:          rl.Rule.GetExpr(),
-					Duration:       rl.Rule.For.Seconds(),
-					Labels:         mimirpb.FromLabelAdaptersToLabels(rl.Rule.Labels),
-					Annotations:    mimirpb.FromLabelAdaptersToLabels(rl.Rule.Annotations),
-					Alerts:         alerts,
-					Health:         rl.GetHealth(),
-					LastError:      rl.GetLastError(),
-					LastEvaluation: rl.GetEvaluationTimestamp(),
-					EvaluationTime: rl.GetEvaluationDuration().Seconds(),
-					Type:           v1.RuleTypeAlerting,
-				}
-			} else {
-				grp.Rules[i] = recordingRule{
-					Name:           rl.Rule.GetRecord(),
-					Query:          rl.Rule.GetExpr(),
-					Labels:         mimirpb.FromLabelAdaptersToLabels(rl.Rule.Labels),
-					Health:         rl.GetHealth(),
-					LastError:      rl.GetLastError(),
-					LastEvaluation: rl.GetEvaluationTimestamp(),
-					EvaluationTime: rl.GetEvaluationDuration().Seconds(),
-					Type:           v1.RuleTypeRecording,
-				}
-			}
+	// The response is streamed one rule group at a time, so that the rule groups of the tenants
+	// with many rules are not all converted and marshaled in memory at once.
+	w.WriteHeader(http.StatusOK)
+	bw := bufio.NewWriter(w)
+	federation := func(g *rulespb.RuleGroupDesc) *RuleGroupFederation {
+		return a.ruler.ruleGroupFederation(req.Context(), g)
+	}
+	if err := writeRuleDiscovery(bw, rgs, version, federated, federation); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "err", err)
+		return
+	}
+	if err := bw.Flush(); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "err", err)
+	}
+}
+
+// writeRuleDiscovery writes the successful response of the rules API, encoding the rule groups one at a time.
+// The output is the same as the marshaling of a response whose data is a RuleDiscovery, or a RuleDiscoveryV2
+// when version is rulesAPIVersion2. The tenant of each rule group is included if federated is true, and the
+// federation settings of the federated rule groups returned by federation unless it's nil.
+func writeRuleDiscovery(w io.Writer, rgs []*GroupStateDesc, version string, federated bool, federation func(*rulespb.RuleGroupDesc) *RuleGroupFederation) error {
+	if _, err := io.WriteString(w, `{"status":"success","data":{"groups":[`); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, g := range rgs {
+		buf.Reset()
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		rg := newRuleGroup(g)
+		if federated {
+			rg.Tenant = g.Group.GetUser()
+		}
+		if federation != nil {
+			rg.Federation = federation(g.Group)
 		}
-		groups = append(groups, &grp)
+		var grp interface{} = rg
+		if version == rulesAPIVersion2 {
+			grp = newRuleGroupV2(rg)
+		}
+		if err := enc.Encode(grp); err != nil {
+			return err
+		}
+		// Trim the newline added by the encoder.
+		if _, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, `]},"errorType":"","error":""}`)
+	return err
+}
+
+// getTenantsRules returns the rule groups of the tenants of the request. When the request has several tenants,
+// as for the queries of multiple tenants, the rule groups of the tenants are fetched concurrently and merged.
+func (a *API) getTenantsRules(ctx context.Context, tenantIDs []string) ([]*GroupStateDesc, error) {
+	if len(tenantIDs) == 1 {
+		return a.ruler.GetRules(ctx)
 	}
 
-	// keep data.groups are in order
-	sort.Slice(groups, func(i, j int) bool {
-		return groups[i].File < groups[j].File
+	var (
+		mergedMx sync.Mutex
+		merged   []*GroupStateDesc
+	)
+	err := concurrency.ForEachJob(ctx, len(tenantIDs), federatedRulesMaxConcurrency, func(ctx context.Context, idx int) error {
+		rgs, err := a.ruler.GetRules(user.InjectOrgID(ctx, tenantIDs[idx]))
+		if err != nil {
+			return errors.Wrapf(err, "unable to get the rules of tenant %s", tenantIDs[idx])
+		}
+
+		mergedMx.Lock()
+		merged = append(merged, rgs...)
+		mergedMx.Unlock()
+		return nil
 	})
+	return merged, err
+}
+
+// AlertHistoryDiscovery has the state transitions of the alerts.
+type AlertHistoryDiscovery struct {
+	Transitions []AlertStateTransition `json:"transitions"`
+}
+
+// PrometheusAlertsHistory returns the state transitions of the tenant's alerts within the requested
+// time range, which defaults to the last hour.
+func (a *API) PrometheusAlertsHistory(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondError(logger, w, "no valid org id found")
+		return
+	}
+
+	if a.ruler.alertHistory == nil {
+		http.Error(w, errAlertHistoryDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	end := time.Now()
+	if v := req.FormValue("end"); v != "" {
+		ms, err := util.ParseTime(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end = util.TimeFromMillis(ms)
+	}
+	start := end.Add(-time.Hour)
+	if v := req.FormValue("start"); v != "" {
+		ms, err := util.ParseTime(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		start = util.TimeFromMillis(ms)
+	}
+	if end.Before(start) {
+		http.Error(w, "end timestamp must not be before start time", http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := a.ruler.alertHistory.Transitions(req.Context(), userID, start, end)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+	if transitions == nil {
+		transitions = []AlertStateTransition{}
+	}
 
 	b, err := json.Marshal(&response{
 		Status: "success",
-		Data:   &RuleDiscovery{RuleGroups: groups},
+		Data:   &AlertHistoryDiscovery{Transitions: transitions},
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
@@ -236,7 +387,32 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (a *API) PrometheusAlerts(w http.ResponseWriter, req *http.Request) {
+// RuleHealthDiscovery has the health summary of the rules of each namespace.
+type RuleHealthDiscovery struct {
+	Namespaces []*NamespaceRuleHealth `json:"namespaces"`
+}
+
+// NamespaceRuleHealth has the number of rules of a namespace by health, and the failing rules.
+type NamespaceRuleHealth struct {
+	Namespace    string         `json:"namespace"`
+	Healthy      int            `json:"healthy"`
+	Failing      int            `json:"failing"`
+	Unknown      int            `json:"unknown"`
+	FailingRules []*FailingRule `json:"failingRules"`
+}
+
+// FailingRule has the most recent error of a rule whose last evaluation failed.
+type FailingRule struct {
+	Group          string      `json:"group"`
+	Name           string      `json:"name"`
+	Type           v1.RuleType `json:"type"`
+	LastError      string      `json:"lastError"`
+	LastEvaluation time.Time   `json:"lastEvaluation"`
+}
+
+// PrometheusRulesHealth returns the number of healthy, failing and unknown rules of the tenant
+// per namespace, along with the most recent error of each failing rule.
+func (a *API) PrometheusRulesHealth(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), a.logger)
 	userID, err := tenant.TenantID(req.Context())
 	if err != nil || userID == "" {
@@ -245,22 +421,191 @@ func (a *API) PrometheusAlerts(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	rgs, err := a.ruler.GetRules(req.Context())
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   newRuleHealthDiscovery(rgs),
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// newRuleHealthDiscovery summarizes the health of the rules of the rule groups, sorting the namespaces
+// by name and the failing rules by group.
+func newRuleHealthDiscovery(rgs []*GroupStateDesc) *RuleHealthDiscovery {
+	byNamespace := map[string]*NamespaceRuleHealth{}
+	for _, g := range rgs {
+		ns, ok := byNamespace[g.Group.Namespace]
+		if !ok {
+			ns = &NamespaceRuleHealth{Namespace: g.Group.Namespace, FailingRules: []*FailingRule{}}
+			byNamespace[g.Group.Namespace] = ns
+		}
+
+		for _, rl := range g.ActiveRules {
+			switch rl.GetHealth() {
+			case string(promRules.HealthGood):
+				ns.Healthy++
+			case string(promRules.HealthBad):
+				ns.Failing++
+				failing := &FailingRule{
+					Group:          g.Group.Name,
+					Name:           rl.Rule.GetRecord(),
+					Type:           v1.RuleTypeRecording,
+					LastError:      rl.GetLastError(),
+					LastEvaluation: rl.GetEvaluationTimestamp(),
+				}
+				if rl.Rule.GetAlert() != "" {
+					failing.Name, failing.Type = rl.Rule.GetAlert(), v1.RuleTypeAlerting
+				}
+				ns.FailingRules = append(ns.FailingRules, failing)
+			default:
+				ns.Unknown++
+			}
+		}
+	}
+
+	discovery := &RuleHealthDiscovery{Namespaces: make([]*NamespaceRuleHealth, 0, len(byNamespace))}
+	for _, ns := range byNamespace {
+		sort.SliceStable(ns.FailingRules, func(i, j int) bool {
+			return ns.FailingRules[i].Group < ns.FailingRules[j].Group
+		})
+		discovery.Namespaces = append(discovery.Namespaces, ns)
+	}
+	sort.Slice(discovery.Namespaces, func(i, j int) bool {
+		return discovery.Namespaces[i].Namespace < discovery.Namespaces[j].Namespace
+	})
+	return discovery
+}
+
+// sortGroupStateDescs sorts the rule groups by tenant, namespace and name.
+func sortGroupStateDescs(rgs []*GroupStateDesc) {
+	sort.Slice(rgs, func(i, j int) bool {
+		if rgs[i].Group.User != rgs[j].Group.User {
+			return rgs[i].Group.User < rgs[j].Group.User
+		}
+		if rgs[i].Group.Namespace != rgs[j].Group.Namespace {
+			return rgs[i].Group.Namespace < rgs[j].Group.Namespace
+		}
+		return rgs[i].Group.Name < rgs[j].Group.Name
+	})
+}
+
+// newRuleGroup converts the state of a rule group to its Prometheus API representation.
+func newRuleGroup(g *GroupStateDesc) *RuleGroup {
+	grp := RuleGroup{
+		Name:           g.Group.Name,
+		File:           g.Group.Namespace,
+		Rules:          make([]rule, len(g.ActiveRules)),
+		Interval:       g.Group.Interval.Seconds(),
+		LastEvaluation: g.GetEvaluationTimestamp(),
+		EvaluationTime: g.GetEvaluationDuration().Seconds(),
+		SourceTenants:  g.Group.GetSourceTenants(),
+		Limit:          g.Group.GetLimit(),
+
+		MissedIterations: g.GetMissedIterations(),
+		LastConfigUpdate: g.Group.GetUpdatedAt(),
+	}
+
+	for i, rl := range g.ActiveRules {
+		if g.ActiveRules[i].Rule.Alert != "" {
+			alerts := make([]*Alert, 0, len(rl.Alerts))
+			for _, a := range rl.Alerts {
+				alerts = append(alerts, &Alert{
+					Labels:      mimirpb.FromLabelAdaptersToLabels(a.Labels),
+					Annotations: mimirpb.FromLabelAdaptersToLabels(a.Annotations),
+					State:       a.GetState(),
+					ActiveAt:    &a.ActiveAt,
+					Value:       strconv.FormatFloat(a.Value, 'e', -1, 64),
+				})
+			}
+			grp.Rules[i] = alertingRule{
+				State:          rl.GetState(),
+				Name:           rl.Rule.GetAlert(),
+				Query:          rl.Rule.GetExpr(),
+				Duration:       rl.Rule.For.Seconds(),
+				Labels:         mimirpb.FromLabelAdaptersToLabels(rl.Rule.Labels),
+				Annotations:    mimirpb.FromLabelAdaptersToLabels(rl.Rule.Annotations),
+				Alerts:         alerts,
+				Health:         rl.GetHealth(),
+				LastError:      rl.GetLastError(),
+				LastEvaluation: rl.GetEvaluationTimestamp(),
+				EvaluationTime: rl.GetEvaluationDuration().Seconds(),
+				Type:           v1.RuleTypeAlerting,
+				Interval:       rl.Rule.Interval.Seconds(),
+				DroppedAlerts:  rl.GetDroppedAlerts(),
+			}
+		} else {
+			grp.Rules[i] = recordingRule{
+				Name:           rl.Rule.GetRecord(),
+				Query:          rl.Rule.GetExpr(),
+				Labels:         mimirpb.FromLabelAdaptersToLabels(rl.Rule.Labels),
+				Health:         rl.GetHealth(),
+				LastError:      rl.GetLastError(),
+				LastEvaluation: rl.GetEvaluationTimestamp(),
+				EvaluationTime: rl.GetEvaluationDuration().Seconds(),
+				Type:           v1.RuleTypeRecording,
+				Interval:       rl.Rule.Interval.Seconds(),
+			}
+		}
+	}
+	return &grp
+}
+
+func (a *API) PrometheusAlerts(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	tenantIDs, err := tenant.TenantIDs(req.Context())
+	if err != nil || len(tenantIDs) == 0 {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondError(logger, w, "no valid org id found")
+		return
+	}
+	if len(tenantIDs) > 1 && !a.ruler.cfg.TenantFederation.Enabled {
+		http.Error(w, ErrTenantFederationDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := parseRulesAPIVersion(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	rgs, err := a.getTenantsRules(req.Context(), tenantIDs)
 
 	if err != nil {
 		respondError(logger, w, err.Error())
 		return
 	}
 
+	// The alerts are returned in the order of their rule groups, rules and labels.
+	sortGroupStateDescs(rgs)
 	alerts := []*Alert{}
 
 	for _, g := range rgs {
 		for _, rl := range g.ActiveRules {
 			if rl.Rule.Alert != "" {
 				for _, a := range rl.Alerts {
+					lbls := mimirpb.FromLabelAdaptersToLabels(a.Labels)
+					// As for the queries of multiple tenants, the alerts are identified by their tenant label.
+					if len(tenantIDs) > 1 {
+						lbls = labels.NewBuilder(lbls).Set(federatedTenantLabel, g.Group.GetUser()).Labels()
+					}
 					alerts = append(alerts, &Alert{
-						Labels:      mimirpb.FromLabelAdaptersToLabels(a.Labels),
+						Labels:      lbls,
 						Annotations: mimirpb.FromLabelAdaptersToLabels(a.Annotations),
 						State:       a.GetState(),
 						ActiveAt:    &a.ActiveAt,
@@ -271,9 +616,13 @@ func (a *API) PrometheusAlerts(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	var data interface{} = &AlertDiscovery{Alerts: alerts}
+	if version == rulesAPIVersion2 {
+		data = newAlertDiscoveryV2(alerts)
+	}
 	b, err := json.Marshal(&response{
 		Status: "success",
-		Data:   &AlertDiscovery{Alerts: alerts},
+		Data:   data,
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
@@ -296,8 +645,144 @@ var (
 	ErrNoRuleGroups = errors.New("no rule groups found")
 	// ErrBadRuleGroup is returned when the provided rule group can not be unmarshalled
 	ErrBadRuleGroup = errors.New("unable to decoded rule group")
+	// ErrRecordingRuleCycle is returned when the provided rule group would introduce a cycle among recording rules
+	ErrRecordingRuleCycle = errors.New("the rule group would introduce a cycle among recording rules")
+	// ErrNoSearchQuery is returned when no search query was specified in the request
+	ErrNoSearchQuery = errors.New("a search query must be provided in the q parameter")
+	// ErrInvalidRuleGraphFormat is returned when the requested rule graph output format is not supported
+	ErrInvalidRuleGraphFormat = errors.New("invalid rule graph format, supported values are: " + ruleGraphFormatJSON + ", " + ruleGraphFormatDOT)
+	// ErrNoMetric is returned when no metric name was specified in the request
+	ErrNoMetric = errors.New("a metric name must be provided in the metric parameter")
+	// ErrInvalidSearchRegex is returned when the regex query parameter is not a boolean
+	ErrInvalidSearchRegex = errors.New("invalid regex parameter, must be a boolean")
+	// ErrInvalidChecksums is returned when the checksums query parameter is not a boolean
+	ErrInvalidChecksums = errors.New("invalid checksums parameter, must be a boolean")
+	// ErrInvalidRuleGroupFormat is returned when the requested rule group output format is not supported
+	ErrInvalidRuleGroupFormat = errors.New("invalid rule group format, supported values are: " + ruleGroupFormatCanonical + ", " + ruleGroupFormatRaw)
+	// ErrTenantFederationDisabled is returned when the rules of multiple tenants are requested while the tenant federation is disabled
+	ErrTenantFederationDisabled = errors.New("the rules of multiple tenants can't be read unless the tenant federation is enabled")
+	// ErrInvalidRulesAPIVersion is returned when the requested version of the rules and alerts API output is not supported
+	ErrInvalidRulesAPIVersion = errors.New("invalid api_version parameter, supported values are: " + rulesAPIVersion1 + ", " + rulesAPIVersion2)
+	// ErrInvalidStrictRuleGroupParsing is returned when the strict rule group parsing header is not a boolean
+	ErrInvalidStrictRuleGroupParsing = errors.New("invalid " + strictRuleGroupParsingHeader + " header, must be a boolean")
+	// ErrInvalidWait is returned when the wait query parameter is not supported
+	ErrInvalidWait = errors.New("invalid wait parameter, supported values are: " + waitPropagated)
+)
+
+const (
+	// ruleGroupFormatCanonical is the format of the rule groups marshaled from their stored description.
+	ruleGroupFormatCanonical = "canonical"
+	// ruleGroupFormatRaw is the format of the rule groups as submitted to the configuration API.
+	ruleGroupFormatRaw = "raw"
+
+	ruleGraphFormatJSON = "json"
+	ruleGraphFormatDOT  = "dot"
+
+	// federatedRulesMaxConcurrency is the maximum number of tenants whose rules are fetched concurrently
+	// by the requests of multiple tenants.
+	federatedRulesMaxConcurrency = 16
+	// federatedTenantLabel is the label identifying the tenant of the alerts returned to the requests of
+	// multiple tenants, as for the series returned to the queries of multiple tenants.
+	federatedTenantLabel = "__tenant_id__"
+
+	// rulesAPIVersion1 is the output of the rules and alerts API compatible with the Prometheus API.
+	rulesAPIVersion1 = "v1"
+	// rulesAPIVersion2 is the output of the rules and alerts API with snake_case field names.
+	rulesAPIVersion2 = "v2"
+
+	// strictRuleGroupParsingHeader is the header overriding whether the submitted rule groups with unknown
+	// fields are rejected.
+	strictRuleGroupParsingHeader = "X-Mimir-Strict-Rule-Group-Parsing"
 )
 
+// parseRulesAPIVersion returns the version of the rules and alerts API output requested with the
+// api_version query parameter, which defaults to rulesAPIVersion1.
+func parseRulesAPIVersion(req *http.Request) (string, error) {
+	switch version := req.URL.Query().Get("api_version"); version {
+	case "", rulesAPIVersion1:
+		return rulesAPIVersion1, nil
+	case rulesAPIVersion2:
+		return rulesAPIVersion2, nil
+	default:
+		return "", ErrInvalidRulesAPIVersion
+	}
+}
+
+// parseRuleGroupFormat returns the output format of the rule groups requested with the format query parameter.
+func parseRuleGroupFormat(req *http.Request) (string, error) {
+	switch format := req.URL.Query().Get("format"); format {
+	case "", ruleGroupFormatCanonical:
+		return ruleGroupFormatCanonical, nil
+	case ruleGroupFormatRaw:
+		return format, nil
+	default:
+		return "", ErrInvalidRuleGroupFormat
+	}
+}
+
+// readRuleGroupPayload reads the rule group submitted in the body of the request, in the YAML format. A rule
+// group submitted in the JSON format, with the application/json content type, is converted to the YAML format.
+func readRuleGroupPayload(logger log.Logger, req *http.Request) ([]byte, error) {
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read rule group payload", "err", err.Error())
+		return nil, err
+	}
+	if !isJSONContentType(req) {
+		return payload, nil
+	}
+
+	payload, err = jsonToYAML(payload)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to convert JSON rule group payload", "err", err.Error())
+		return nil, ErrBadRuleGroup
+	}
+	return payload, nil
+}
+
+// unmarshalRuleGroup decodes the rule group submitted in the payload of the request. The rule groups with unknown
+// fields are rejected if enabled by the configuration of the ruler, or by the strict rule group parsing header.
+func (a *API) unmarshalRuleGroup(logger log.Logger, req *http.Request, payload []byte) (rulespb.RuleGroup, error) {
+	rg := rulespb.RuleGroup{}
+	strict := a.ruler.cfg.StrictRuleGroupParsing
+	if v := req.Header.Get(strictRuleGroupParsingHeader); v != "" {
+		var err error
+		if strict, err = strconv.ParseBool(v); err != nil {
+			return rg, ErrInvalidStrictRuleGroupParsing
+		}
+	}
+
+	var err error
+	if strict {
+		err = rulespb.UnmarshalStrict(payload, &rg)
+	} else {
+		err = yaml.Unmarshal(payload, &rg)
+	}
+	var unknownFieldsErr *rulespb.UnknownFieldsError
+	if errors.As(err, &unknownFieldsErr) {
+		level.Error(logger).Log("msg", "rule group payload has unknown fields", "err", err.Error())
+		return rg, err
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
+		return rg, ErrBadRuleGroup
+	}
+	return rg, nil
+}
+
+// parseChecksums returns whether the checksums of the rule groups are requested with the checksums query parameter.
+func parseChecksums(req *http.Request) (bool, error) {
+	v := req.URL.Query().Get("checksums")
+	if v == "" {
+		return false, nil
+	}
+	checksums, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, ErrInvalidChecksums
+	}
+	return checksums, nil
+}
+
 func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger) {
 	d, err := yaml.Marshal(&output)
 	if err != nil {
@@ -313,9 +798,11 @@ func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger
 	}
 }
 
-func respondAccepted(w http.ResponseWriter, logger log.Logger) {
+func respondAccepted(w http.ResponseWriter, logger log.Logger, data interface{}, warnings []string) {
 	b, err := json.Marshal(&response{
-		Status: "success",
+		Status:   "success",
+		Data:     data,
+		Warnings: warnings,
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
@@ -400,12 +887,25 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	format, err := parseRuleGroupFormat(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	checksums, err := parseChecksums(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	level.Debug(logger).Log("msg", "retrieving rule groups with namespace", "userID", userID, "namespace", namespace)
 	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, namespace)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	rgs = filterAuthorizedNamespaces(req.Context(), rgs)
 
 	if len(rgs) == 0 {
 		level.Info(logger).Log("msg", "no rule groups found", "userID", userID)
@@ -422,71 +922,492 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "retrieved rule groups from rule store", "userID", userID, "num_namespaces", len(rgs))
 
-	formatted := rgs.Formatted()
-	marshalAndSend(formatted, w, logger)
-}
-
-func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
-	logger := util_log.WithContext(req.Context(), a.logger)
-	userID, namespace, groupName, err := parseRequest(req, true, true)
-	if err != nil {
-		respondError(logger, w, err.Error())
+	if format == ruleGroupFormatRaw {
+		nodes, err := rgs.FormattedRaw()
+		if err == nil && checksums {
+			err = addRawChecksums(rgs, nodes)
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to format the raw rule groups", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		marshalAndSend(nodes, w, logger)
 		return
 	}
 
-	rg, err := a.store.GetRuleGroup(req.Context(), userID, namespace, groupName)
-	if err != nil {
-		if errors.Is(err, rulestore.ErrGroupNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+	if checksums {
+		formatted, err := formattedWithChecksums(rgs)
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to compute the checksums of the rule groups", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		marshalAndSend(formatted, w, logger)
 		return
 	}
 
-	formatted := rulespb.FromProto(rg)
+	formatted := rgs.Formatted()
 	marshalAndSend(formatted, w, logger)
 }
 
-func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
+// ruleGroupWithChecksum is a formatted rule group along with its checksum.
+type ruleGroupWithChecksum struct {
+	rulespb.RuleGroup `yaml:",inline"`
+	Checksum          string `yaml:"checksum"`
+}
+
+// MarshalYAML implements yaml.Marshaler, overriding the one of the embedded rule group.
+func (g ruleGroupWithChecksum) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(g.RuleGroup); err != nil {
+		return nil, err
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "checksum"},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: g.Checksum},
+	)
+	return node, nil
+}
+
+// formattedWithChecksums returns the rule groups as formatted rule groups along with their checksums,
+// mapped by namespace.
+func formattedWithChecksums(rgs rulespb.RuleGroupList) (map[string][]ruleGroupWithChecksum, error) {
+	ruleMap := map[string][]ruleGroupWithChecksum{}
+	for _, g := range rgs {
+		checksum, err := rulespb.Checksum(g)
+		if err != nil {
+			return nil, err
+		}
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], ruleGroupWithChecksum{RuleGroup: rulespb.FromProto(g), Checksum: checksum})
+	}
+	return ruleMap, nil
+}
+
+// addRawChecksums adds the checksum of each rule group to its node, as returned by RuleGroupList.FormattedRaw.
+func addRawChecksums(rgs rulespb.RuleGroupList, nodes map[string][]*yaml.Node) error {
+	// The nodes of each namespace are in the same order as the rule groups.
+	idx := map[string]int{}
+	for _, g := range rgs {
+		node := nodes[g.Namespace][idx[g.Namespace]]
+		idx[g.Namespace]++
+
+		if node.Kind != yaml.MappingNode {
+			return errors.Errorf("rule group %s of namespace %s is not a YAML mapping", g.Name, g.Namespace)
+		}
+		checksum, err := rulespb.Checksum(g)
+		if err != nil {
+			return err
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "checksum"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: checksum},
+		)
+	}
+	return nil
+}
+
+// RuleNamespaces has info for all the rule namespaces of a tenant.
+type RuleNamespaces struct {
+	Namespaces []RuleNamespace `yaml:"namespaces"`
+}
+
+// RuleNamespace has info for a rule namespace, without its rule groups.
+type RuleNamespace struct {
+	Name   string `yaml:"name"`
+	Groups int    `yaml:"groups"`
+}
+
+// ListRuleNamespaces returns the names of the rule namespaces of the tenant, along with their
+// number of rule groups. The rule groups are not loaded from the rule store.
+func (a *API) ListRuleNamespaces(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), a.logger)
-	userID, namespace, _, err := parseRequest(req, true, false)
+
+	userID, _, _, err := parseRequest(req, false, false)
 	if err != nil {
 		respondError(logger, w, err.Error())
 		return
 	}
 
-	payload, err := ioutil.ReadAll(req.Body)
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
 	if err != nil {
-		level.Error(logger).Log("msg", "unable to read rule group payload", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	rgs = filterAuthorizedNamespaces(req.Context(), rgs)
 
-	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
+	counts := map[string]int{}
+	for _, g := range rgs {
+		counts[g.Namespace]++
+	}
 
-	rg := rulefmt.RuleGroup{}
-	err = yaml.Unmarshal(payload, &rg)
-	if err != nil {
-		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
-		http.Error(w, ErrBadRuleGroup.Error(), http.StatusBadRequest)
-		return
+	namespaces := make([]RuleNamespace, 0, len(counts))
+	for name, groups := range counts {
+		namespaces = append(namespaces, RuleNamespace{Name: name, Groups: groups})
 	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
 
-	errs := a.ruler.manager.ValidateRuleGroup(rg)
-	if len(errs) > 0 {
-		e := []string{}
-		for _, err := range errs {
-			level.Error(logger).Log("msg", "unable to validate rule group payload", "err", err.Error())
-			e = append(e, err.Error())
+	marshalAndSend(RuleNamespaces{Namespaces: namespaces}, w, logger)
+}
+
+// RuleSearchResults has info for the rules matching a search.
+type RuleSearchResults struct {
+	Rules []RuleSearchResult `yaml:"rules"`
+}
+
+// RuleSearchResult has info for a rule matching a search.
+type RuleSearchResult struct {
+	Namespace string            `yaml:"namespace"`
+	Group     string            `yaml:"group"`
+	Record    string            `yaml:"record,omitempty"`
+	Alert     string            `yaml:"alert,omitempty"`
+	Expr      string            `yaml:"expr"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// SearchRules returns the rules of the tenant whose name, expression, label names or label values
+// match the query, either as a case-insensitive substring or as a regular expression.
+func (a *API) SearchRules(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, _, _, err := parseRequest(req, false, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	match, err := parseRuleSearchQuery(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgs, err := a.loadSortedRuleGroups(req.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := []RuleSearchResult{}
+	for _, g := range rgs {
+		for _, r := range g.Rules {
+			if ruleMatches(r, match) {
+				results = append(results, newRuleSearchResult(g, r))
+			}
 		}
+	}
 
-		http.Error(w, strings.Join(e, ", "), http.StatusBadRequest)
+	marshalAndSend(RuleSearchResults{Rules: results}, w, logger)
+}
+
+// loadSortedRuleGroups loads all the rule groups of the tenant, sorted by namespace and name.
+func (a *API) loadSortedRuleGroups(ctx context.Context, userID string) (rulespb.RuleGroupList, error) {
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+	if err != nil {
+		return nil, err
+	}
+	rgs = filterAuthorizedNamespaces(ctx, rgs)
+	if err := a.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: rgs}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rgs, func(i, j int) bool {
+		if rgs[i].Namespace != rgs[j].Namespace {
+			return rgs[i].Namespace < rgs[j].Namespace
+		}
+		return rgs[i].Name < rgs[j].Name
+	})
+	return rgs, nil
+}
+
+func newRuleSearchResult(g *rulespb.RuleGroupDesc, r *rulespb.RuleDesc) RuleSearchResult {
+	result := RuleSearchResult{Namespace: g.Namespace, Group: g.Name, Record: r.Record, Alert: r.Alert, Expr: r.Expr}
+	if len(r.Labels) > 0 {
+		result.Labels = make(map[string]string, len(r.Labels))
+		for _, l := range r.Labels {
+			result.Labels[l.Name] = l.Value
+		}
+	}
+	return result
+}
+
+// MetricUsage has info for the rules producing and using a metric.
+type MetricUsage struct {
+	Metric     string             `yaml:"metric"`
+	ProducedBy []RuleSearchResult `yaml:"produced_by"`
+	UsedBy     []RuleSearchResult `yaml:"used_by"`
+}
+
+// MetricUsage returns the rules of the tenant producing the metric of the metric query parameter,
+// and the rules whose expression selects it by name.
+func (a *API) MetricUsage(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, _, _, err := parseRequest(req, false, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
 		return
 	}
 
-	if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
-		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+	metric := req.URL.Query().Get("metric")
+	if metric == "" {
+		http.Error(w, ErrNoMetric.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgs, err := a.loadSortedRuleGroups(req.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	usage := MetricUsage{Metric: metric, ProducedBy: []RuleSearchResult{}, UsedBy: []RuleSearchResult{}}
+	for _, g := range rgs {
+		for _, r := range g.Rules {
+			if ruleProducesMetric(r, metric) {
+				usage.ProducedBy = append(usage.ProducedBy, newRuleSearchResult(g, r))
+			}
+
+			expr, err := parser.ParseExpr(r.Expr)
+			if err != nil {
+				level.Warn(logger).Log("msg", "unable to parse rule expression", "namespace", g.Namespace, "group", g.Name, "expr", r.Expr, "err", err)
+				continue
+			}
+			if exprSelectsMetric(expr, metric) {
+				usage.UsedBy = append(usage.UsedBy, newRuleSearchResult(g, r))
+			}
+		}
+	}
+
+	marshalAndSend(usage, w, logger)
+}
+
+// ruleProducesMetric returns whether the rule writes the metric: the recording rules write their
+// recorded metric, and the alerting rules write the metrics of the alerts.
+func ruleProducesMetric(r *rulespb.RuleDesc, metric string) bool {
+	if r.Alert != "" {
+		return metric == alertMetricName || metric == alertForStateMetricName
+	}
+	return r.Record == metric
+}
+
+// exprSelectsMetric returns whether a selector of the expression selects the metric by name. The
+// selectors without a metric name matcher are ignored, even if they may select the metric.
+func exprSelectsMetric(expr parser.Expr, metric string) bool {
+	selects := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok || selects {
+			return nil
+		}
+
+		hasNameMatcher := false
+		for _, m := range vs.LabelMatchers {
+			if m.Name != labels.MetricName {
+				continue
+			}
+			hasNameMatcher = true
+			if !m.Matches(metric) {
+				return nil
+			}
+		}
+		selects = hasNameMatcher
+		return nil
+	})
+	return selects
+}
+
+// parseRuleSearchQuery returns the function matching the strings searched by the q query parameter,
+// which is a regular expression if the regex query parameter is true.
+func parseRuleSearchQuery(req *http.Request) (func(string) bool, error) {
+	q := req.URL.Query().Get("q")
+	if q == "" {
+		return nil, ErrNoSearchQuery
+	}
+
+	if v := req.URL.Query().Get("regex"); v != "" {
+		isRegex, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, ErrInvalidSearchRegex
+		}
+		if isRegex {
+			re, err := regexp.Compile(q)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid search regular expression")
+			}
+			return re.MatchString, nil
+		}
+	}
+
+	q = strings.ToLower(q)
+	return func(s string) bool {
+		return strings.Contains(strings.ToLower(s), q)
+	}, nil
+}
+
+// ruleMatches returns whether the name, the expression, or a label name or value of the rule matches.
+func ruleMatches(r *rulespb.RuleDesc, match func(string) bool) bool {
+	// The unset name of the rule is not matched.
+	name := r.Record
+	if r.Alert != "" {
+		name = r.Alert
+	}
+	if match(name) || match(r.Expr) {
+		return true
+	}
+	for _, l := range r.Labels {
+		if match(l.Name) || match(l.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleGraph returns the dependency graph of the rules of the tenant, in the JSON format or in the
+// DOT format of Graphviz, depending on the format query parameter.
+func (a *API) RuleGraph(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, _, _, err := parseRequest(req, false, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = ruleGraphFormatJSON
+	}
+	if format != ruleGraphFormatJSON && format != ruleGraphFormatDOT {
+		http.Error(w, ErrInvalidRuleGraphFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgs, err := a.loadSortedRuleGroups(req.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	graph := newRuleGraph(rgs)
+
+	if format == ruleGraphFormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		if err := graph.writeDOT(w); err != nil {
+			level.Error(logger).Log("msg", "error writing response", "err", err)
+		}
+		return
+	}
+
+	b, err := json.Marshal(graph)
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "err", err)
+	}
+}
+
+func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	format, err := parseRuleGroupFormat(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rg, err := a.store.GetRuleGroup(req.Context(), userID, namespace, groupName)
+	if err != nil {
+		if errors.Is(err, rulestore.ErrGroupNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The rule groups not created through the configuration API have no raw content.
+	body := rg.Raw
+	if format != ruleGroupFormatRaw || len(body) == 0 {
+		formatted := rulespb.FromProto(rg)
+		body, err = yaml.Marshal(&formatted)
+		if err != nil {
+			level.Error(logger).Log("msg", "error marshalling yaml rule group", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	contentType := "application/yaml"
+	if acceptsJSON(req) {
+		contentType = jsonContentType
+		body, err = yamlToJSON(body)
+		if err != nil {
+			level.Error(logger).Log("msg", "error converting yaml rule group to json", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The entity tag is the checksum of the response, so that clients polling for changes of the rule
+	// group can skip unchanged ones with the If-None-Match header. In the canonical YAML format, it's the
+	// checksum returned by the list rule groups endpoints.
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Vary", "Accept")
+
+	// ServeContent handles the HEAD requests and the conditional requests, with the If-None-Match and
+	// If-Modified-Since headers, the last modification being the last update of the rule group, if known.
+	var lastModified time.Time
+	if rg.UpdatedAt != nil {
+		lastModified = *rg.UpdatedAt
+	}
+	http.ServeContent(w, req, "", lastModified, bytes.NewReader(body))
+}
+
+func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	wait, err := parseWait(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload, err := readRuleGroupPayload(logger, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
+
+	rg, err := a.unmarshalRuleGroup(logger, req, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The templated rules are expanded before the validation, so that the expanded rules are validated and stored.
+	rg, err = expandRuleGroupTemplate(rg)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to expand rule group template", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -498,23 +1419,215 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := a.ruler.AssertMaxRuleGroups(userID, len(rgs)+1); err != nil {
-		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+	if err := a.store.LoadRuleGroups(req.Context(), map[string]rulespb.RuleGroupList{userID: rgs}); err != nil {
+		level.Error(logger).Log("msg", "unable to load current rule groups for validation", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	warnings, err := a.validateRuleGroup(req.Context(), logger, userID, namespace, rg, rgs)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	now := time.Now()
 	rgProto := rulespb.ToProto(userID, namespace, rg)
+	rgProto.Raw = payload
+	rgProto.UpdatedAt = &now
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
 	err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), setRuleGroupErrorStatus(err))
 		return
 	}
 
-	respondAccepted(w, logger)
+	a.notifyRulesChange(userID, namespace, rg.Name, rulesChangeActionCreate)
+
+	var propagated *bool
+	if wait {
+		loaded := a.waitPropagation(req.Context(), logger, userID, namespace, rg.Name)
+		if !loaded {
+			warnings = append(warnings, a.propagationWarning())
+		}
+		propagated = &loaded
+	}
+
+	// The stored rule group is returned as by the get rule group endpoint in the canonical format, so that
+	// the clients don't need to get it to know what was stored.
+	stored, err := newStoredRuleGroup(rgProto)
+	if err != nil {
+		level.Warn(logger).Log("msg", "unable to marshal the stored rule group", "err", err.Error())
+		respondAccepted(w, logger, nil, warnings)
+		return
+	}
+	stored.Propagated = propagated
+	w.Header().Set("ETag", `"`+stored.Checksum+`"`)
+	w.Header().Set("Last-Modified", now.UTC().Format(http.TimeFormat))
+	respondAccepted(w, logger, stored, warnings)
+}
+
+// storedRuleGroup is the rule group stored by the set rule group endpoint.
+type storedRuleGroup struct {
+	// Group is the rule group in the canonical format, in JSON.
+	Group json.RawMessage `json:"group"`
+	// Checksum is the checksum of the rule group in the canonical YAML format, which is the entity tag of the
+	// rule group returned by the get rule group endpoint.
+	Checksum string `json:"checksum"`
+	// Propagated is whether the rulers loaded the rule group, if the request waited for it.
+	Propagated *bool `json:"propagated,omitempty"`
+}
+
+func newStoredRuleGroup(rg *rulespb.RuleGroupDesc) (*storedRuleGroup, error) {
+	formatted := rulespb.FromProto(rg)
+	canonical, err := yaml.Marshal(&formatted)
+	if err != nil {
+		return nil, err
+	}
+	group, err := yamlToJSON(canonical)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	return &storedRuleGroup{Group: bytes.TrimSpace(group), Checksum: hex.EncodeToString(sum[:])}, nil
+}
+
+// setRuleGroupErrorStatus returns the HTTP status code of an error storing a rule group.
+func setRuleGroupErrorStatus(err error) int {
+	var versionErr *rulespb.FormatVersionError
+	if errors.As(err, &versionErr) {
+		// The rule group must be written by a newer version of Mimir, for example after a rolling upgrade.
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// validateRuleGroup validates the rule group to store in the namespace, given the current rule groups of the tenant.
+// It returns the warnings about the rule group, or the reason why it's rejected.
+func (a *API) validateRuleGroup(ctx context.Context, logger log.Logger, userID, namespace string, rg rulespb.RuleGroup, existing rulespb.RuleGroupList) ([]string, error) {
+	// The rules are validated with the labels of the group, as they're evaluated.
+	ruleFile := rg.RuleFile()
+
+	errs := a.ruler.manager.ValidateRuleGroup(ruleFile)
+	if len(errs) > 0 {
+		e := []string{}
+		for _, err := range errs {
+			level.Error(logger).Log("msg", "unable to validate rule group payload", "err", err.Error())
+			e = append(e, err.Error())
+		}
+		return nil, errors.New(strings.Join(e, ", "))
+	}
+
+	if err := validateGroupDependencies(namespace, rg); err != nil {
+		level.Error(logger).Log("msg", "unable to validate rule group dependencies", "err", err.Error())
+		return nil, err
+	}
+
+	if err := validateDestTenants(rg); err != nil {
+		level.Error(logger).Log("msg", "unable to validate rule group destination tenants", "err", err.Error())
+		return nil, err
+	}
+	if err := authorizeDestTenants(a.ruler.limits, userID, rg.DestTenants); err != nil {
+		level.Error(logger).Log("msg", "destination tenants authorization failure", "err", err.Error(), "user", userID)
+		return nil, err
+	}
+	if err := validateSourceTenantLabel(rg); err != nil {
+		level.Error(logger).Log("msg", "unable to validate rule group source tenant label", "err", err.Error())
+		return nil, err
+	}
+	if a.ruler.federationConsents != nil {
+		if err := a.ruler.federationConsents.Check(ctx, userID, rg.SourceTenants); err != nil {
+			level.Error(logger).Log("msg", "federation consent validation failure", "err", err.Error(), "user", userID)
+			return nil, err
+		}
+	}
+
+	groupInterval := time.Duration(rg.Interval)
+	if groupInterval == 0 {
+		groupInterval = a.ruler.cfg.EvaluationInterval
+	}
+	if err := validateRuleIntervals(rg, groupInterval); err != nil {
+		level.Error(logger).Log("msg", "unable to validate rule intervals", "err", err.Error())
+		return nil, err
+	}
+
+	if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		return nil, err
+	}
+
+	if err := a.ruler.AssertMaxRecordingRuleLabels(userID, ruleFile); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		return nil, err
+	}
+
+	if err := a.ruler.AssertMaxRuleGroups(userID, len(existing)+1); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		return nil, err
+	}
+
+	if err := findRecordingRuleCycle(namespace, ruleFile, existing); err != nil {
+		level.Error(logger).Log("msg", "recording rule cycle validation failure", "err", err.Error(), "user", userID)
+		return nil, err
+	}
+
+	var warnings []string
+	if policy := a.ruler.cfg.DuplicateRecordingRulesPolicy; policy != duplicateRecordingRulesPolicyDisabled {
+		duplicates := findDuplicateRecordingRules(namespace, ruleFile, existing)
+		if len(duplicates) > 0 && policy == duplicateRecordingRulesPolicyReject {
+			level.Error(logger).Log("msg", "duplicate recording rules validation failure", "err", strings.Join(duplicates, ", "), "user", userID)
+			return nil, errors.New(strings.Join(duplicates, ", "))
+		}
+		for _, d := range duplicates {
+			level.Warn(logger).Log("msg", "duplicate recording rule", "warning", d, "user", userID)
+		}
+		warnings = duplicates
+	}
+	return warnings, nil
+}
+
+// PreviewRuleGroupTemplate returns the rule group of the request with its templated rules expanded,
+// as it would be stored by the CreateRuleGroup endpoint.
+func (a *API) PreviewRuleGroupTemplate(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondError(logger, w, "no valid org id found")
+		return
+	}
+
+	payload, err := readRuleGroupPayload(logger, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rg, err := a.unmarshalRuleGroup(logger, req, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rg, err = expandRuleGroupTemplate(rg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errs := a.ruler.manager.ValidateRuleGroup(rg.RuleFile()); len(errs) > 0 {
+		e := []string{}
+		for _, err := range errs {
+			e = append(e, err.Error())
+		}
+		http.Error(w, strings.Join(e, ", "), http.StatusBadRequest)
+		return
+	}
+
+	formatted := rulespb.FromProto(rulespb.ToProto(userID, "", rg))
+	marshalAndSend(&formatted, w, logger)
 }
 
 func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
@@ -526,7 +1639,7 @@ func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = a.store.DeleteNamespace(req.Context(), userID, namespace)
+	err = a.deleteNamespace(req.Context(), logger, userID, namespace)
 	if err != nil {
 		if err == rulestore.ErrGroupNamespaceNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -536,7 +1649,63 @@ func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	respondAccepted(w, logger)
+	a.notifyRulesChange(userID, namespace, "", rulesChangeActionDeleteNamespace)
+	respondAccepted(w, logger, nil, nil)
+}
+
+// EvaluateRuleGroup evaluates the requested rule group once, out of band, and returns the outcome
+// of the evaluation.
+func (a *API) EvaluateRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	rg, err := a.store.GetRuleGroup(req.Context(), userID, namespace, groupName)
+	if err != nil {
+		if errors.Is(err, rulestore.ErrGroupNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state, err := a.ruler.EvaluateRuleGroup(req.Context(), userID, rg)
+	switch {
+	case errors.Is(err, errOnDemandEvaluationDisabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, errOnDemandEvaluationRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, errOnDemandEvaluationUnavailable):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	grp := newRuleGroup(state)
+	grp.Federation = a.ruler.ruleGroupFederation(req.Context(), state.Group)
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   grp,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
 }
 
 func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
@@ -548,7 +1717,13 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName)
+	wait, err := parseWait(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = a.deleteRuleGroup(req.Context(), logger, userID, namespace, groupName)
 	if err != nil {
 		if err == rulestore.ErrGroupNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -558,5 +1733,23 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	respondAccepted(w, logger)
+	a.notifyRulesChange(userID, namespace, groupName, rulesChangeActionDelete)
+
+	if wait {
+		result := propagationResult{Propagated: a.waitPropagation(req.Context(), logger, userID, namespace, groupName)}
+		var warnings []string
+		if !result.Propagated {
+			warnings = append(warnings, a.propagationWarning())
+		}
+		respondAccepted(w, logger, result, warnings)
+		return
+	}
+	respondAccepted(w, logger, nil, nil)
+}
+
+// notifyRulesChange notifies the change of the rule groups to the changes webhook, if enabled.
+func (a *API) notifyRulesChange(userID, namespace, group, action string) {
+	if a.ruler.changesWebhook != nil {
+		a.ruler.changesWebhook.notify(userID, namespace, group, action)
+	}
 }
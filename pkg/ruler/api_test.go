@@ -7,21 +7,32 @@ package ruler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
 
@@ -144,6 +155,13 @@ func TestRuler(t *testing.T) {
 								},
 							},
 							Interval: 60,
+							Federation: &RuleGroupFederation{
+								Enabled:             true,
+								SourceTenants:       []string{"tenant-1"},
+								DestTenants:         []string{"user1"},
+								SourceTenantLabel:   "__tenant_id__",
+								SourceTenantsStatus: []FederationSourceTenantStatus{{Tenant: "tenant-1", Consent: "not_required"}},
+							},
 						},
 					},
 				},
@@ -193,6 +211,336 @@ func TestRuler(t *testing.T) {
 	}
 }
 
+func TestWriteRuleDiscovery(t *testing.T) {
+	activeAt := time.Unix(1000, 0).UTC()
+	rgs := []*GroupStateDesc{
+		{
+			Group: &rulespb.RuleGroupDesc{Name: "group1", Namespace: "namespace1", Interval: time.Minute, SourceTenants: []string{"tenant-a"}},
+			ActiveRules: []*RuleStateDesc{
+				{
+					Rule:   &rulespb.RuleDesc{Record: "job:up:sum", Expr: "sum by(job) (up)", Labels: []mimirpb.LabelAdapter{{Name: "team", Value: "a&b"}}},
+					Health: "ok",
+				},
+				{
+					Rule:   &rulespb.RuleDesc{Alert: "Down", Expr: "up < 1", For: time.Minute},
+					State:  "firing",
+					Health: "ok",
+					Alerts: []*AlertStateDesc{{State: "firing", Labels: []mimirpb.LabelAdapter{{Name: "job", Value: "<test>"}}, Value: 0, ActiveAt: activeAt}},
+				},
+			},
+			EvaluationTimestamp: activeAt,
+			EvaluationDuration:  time.Second,
+		},
+		{
+			Group: &rulespb.RuleGroupDesc{Name: "group2", Namespace: "namespace2", Interval: time.Minute},
+		},
+	}
+
+	for name, groups := range map[string][]*GroupStateDesc{
+		"no rule groups":   nil,
+		"some rule groups": rgs,
+	} {
+		t.Run(name, func(t *testing.T) {
+			converted := make([]*RuleGroup, 0, len(groups))
+			convertedV2 := make([]*RuleGroupV2, 0, len(groups))
+			for _, g := range groups {
+				converted = append(converted, newRuleGroup(g))
+				convertedV2 = append(convertedV2, newRuleGroupV2(newRuleGroup(g)))
+			}
+
+			expected, err := json.Marshal(&response{Status: "success", Data: &RuleDiscovery{RuleGroups: converted}})
+			require.NoError(t, err)
+			var b strings.Builder
+			require.NoError(t, writeRuleDiscovery(&b, groups, rulesAPIVersion1, false, nil))
+			assert.Equal(t, string(expected), b.String())
+
+			expected, err = json.Marshal(&response{Status: "success", Data: &RuleDiscoveryV2{RuleGroups: convertedV2}})
+			require.NoError(t, err)
+			b.Reset()
+			require.NoError(t, writeRuleDiscovery(&b, groups, rulesAPIVersion2, false, nil))
+			assert.Equal(t, string(expected), b.String())
+		})
+	}
+}
+
+func TestRuler_PrometheusRulesOrderAndVersion(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	groups := rulespb.RuleGroupList{}
+	for _, ns := range []string{"namespace2", "namespace1"} {
+		for _, name := range []string{"group2", "group1"} {
+			groups = append(groups, &rulespb.RuleGroupDesc{
+				Name:      name,
+				Namespace: ns,
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{Record: "z_rule", Expr: "up"},
+					{Alert: "A_ALERT", Expr: "up < 1"},
+				},
+				Interval: interval,
+			})
+		}
+	}
+
+	rulerAddrMap := map[string]*Ruler{}
+	r := buildRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{"user1": groups}), rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+	// Ensure all rules are loaded before usage
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	t.Run("rule groups are sorted by namespace and name, rules are kept in declaration order", func(t *testing.T) {
+		for _, version := range []string{"", rulesAPIVersion1, rulesAPIVersion2} {
+			w := httptest.NewRecorder()
+			a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules?api_version="+version, nil, "user1"))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp struct {
+				Data struct {
+					Groups []struct {
+						Name  string `json:"name"`
+						File  string `json:"file"`
+						Rules []struct {
+							Name string `json:"name"`
+						} `json:"rules"`
+					} `json:"groups"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			var names []string
+			for _, g := range resp.Data.Groups {
+				names = append(names, g.File+"/"+g.Name)
+				require.Len(t, g.Rules, 2)
+				assert.Equal(t, "z_rule", g.Rules[0].Name)
+				assert.Equal(t, "A_ALERT", g.Rules[1].Name)
+			}
+			assert.Equal(t, []string{"namespace1/group1", "namespace1/group2", "namespace2/group1", "namespace2/group2"}, names)
+		}
+	})
+
+	t.Run("v2 field names are snake_case", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules?api_version=v2", nil, "user1"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data struct {
+				Groups []map[string]json.RawMessage `json:"groups"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotEmpty(t, resp.Data.Groups)
+
+		group := resp.Data.Groups[0]
+		for _, field := range []string{"name", "file", "rules", "interval", "last_evaluation", "evaluation_time", "source_tenants"} {
+			assert.Contains(t, group, field)
+		}
+		var rules []map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(group["rules"], &rules))
+		for _, rl := range rules {
+			for _, field := range []string{"last_error", "last_evaluation", "evaluation_time"} {
+				assert.Contains(t, rl, field)
+			}
+		}
+	})
+
+	t.Run("alerts v2", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		a.PrometheusAlerts(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/alerts?api_version=v2", nil, "user1"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		expected, err := json.Marshal(response{Status: "success", Data: &AlertDiscoveryV2{Alerts: []*AlertV2{}}})
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), w.Body.String())
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules?api_version=v3", nil, "user1"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), ErrInvalidRulesAPIVersion.Error())
+
+		w = httptest.NewRecorder()
+		a.PrometheusAlerts(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/alerts?api_version=v3", nil, "user1"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRuler_PrometheusRulesLastConfigUpdate(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	updatedAt := time.Unix(1000, 0).UTC()
+
+	rulerAddrMap := map[string]*Ruler{}
+	r := buildRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules:     []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}},
+				Interval:  interval,
+				UpdatedAt: &updatedAt,
+			},
+		},
+	}), rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+	// Ensure all rules are loaded before usage
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	w := httptest.NewRecorder()
+	a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"lastConfigUpdate":"1970-01-01T00:16:40Z"`)
+}
+
+func TestRuler_PrometheusRulesMultipleTenants(t *testing.T) {
+	// Set a multi tenant resolver, as done when the tenant federation is enabled.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	for _, federationEnabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("tenant federation enabled: %t", federationEnabled), func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.TenantFederation.Enabled = federationEnabled
+
+			rulerAddrMap := map[string]*Ruler{}
+			r := buildRuler(t, cfg, newMockRuleStore(mockRules), rulerAddrMap)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+			rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+			// Ensure all rules are loaded before usage
+			r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			w := httptest.NewRecorder()
+			a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules", nil, "user2|user1"))
+			if !federationEnabled {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), ErrTenantFederationDisabled.Error())
+
+				w = httptest.NewRecorder()
+				a.PrometheusAlerts(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/alerts", nil, "user2|user1"))
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				return
+			}
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp struct {
+				Data struct {
+					Groups []struct {
+						Name   string `json:"name"`
+						File   string `json:"file"`
+						Tenant string `json:"tenant"`
+					} `json:"groups"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			var groups []string
+			for _, g := range resp.Data.Groups {
+				groups = append(groups, g.Tenant+"/"+g.File+"/"+g.Name)
+			}
+			assert.Equal(t, []string{"user1/namespace1/group1", "user2/namespace1/group1"}, groups)
+
+			w = httptest.NewRecorder()
+			a.PrometheusAlerts(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/alerts", nil, "user2|user1"))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			// The tenant is only returned to the requests of multiple tenants.
+			w = httptest.NewRecorder()
+			a.PrometheusRules(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules", nil, "user1"))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.NotContains(t, w.Body.String(), `"tenant"`)
+		})
+	}
+}
+
+func TestNewRuleGroup_LastConfigUpdate(t *testing.T) {
+	updatedAt := time.Unix(1000, 0).UTC()
+
+	grp := newRuleGroup(&GroupStateDesc{Group: &rulespb.RuleGroupDesc{Name: "group1", Namespace: "namespace1", UpdatedAt: &updatedAt}})
+	require.NotNil(t, grp.LastConfigUpdate)
+	assert.Equal(t, updatedAt, *grp.LastConfigUpdate)
+
+	b, err := json.Marshal(grp)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"lastConfigUpdate":"1970-01-01T00:16:40Z"`)
+	b, err = json.Marshal(newRuleGroupV2(grp))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"last_config_update":"1970-01-01T00:16:40Z"`)
+
+	// The update time is unknown for the rule groups stored before it was tracked.
+	grp = newRuleGroup(&GroupStateDesc{Group: &rulespb.RuleGroupDesc{Name: "group1", Namespace: "namespace1"}})
+	b, err = json.Marshal(grp)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "lastConfigUpdate")
+}
+
+func TestNewRuleHealthDiscovery(t *testing.T) {
+	evaluatedAt := time.Unix(1000, 0).UTC()
+	rgs := []*GroupStateDesc{
+		{
+			Group: &rulespb.RuleGroupDesc{Name: "group2", Namespace: "namespace1"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "up:sum", Expr: "sum(up)"}, Health: "err", LastError: "query timed out", EvaluationTimestamp: evaluatedAt},
+			},
+		},
+		{
+			Group: &rulespb.RuleGroupDesc{Name: "group1", Namespace: "namespace2"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "job:up:sum", Expr: "sum by(job) (up)"}, Health: "ok"},
+			},
+		},
+		{
+			Group: &rulespb.RuleGroupDesc{Name: "group1", Namespace: "namespace1"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "job:up:sum", Expr: "sum by(job) (up)"}, Health: "ok"},
+				{Rule: &rulespb.RuleDesc{Alert: "Down", Expr: "up < 1"}, Health: "err", LastError: "many-to-many matching not allowed", EvaluationTimestamp: evaluatedAt},
+				{Rule: &rulespb.RuleDesc{Alert: "New", Expr: "up < 1"}, Health: "unknown"},
+			},
+		},
+	}
+
+	assert.Equal(t, &RuleHealthDiscovery{Namespaces: []*NamespaceRuleHealth{
+		{
+			Namespace: "namespace1",
+			Healthy:   1,
+			Failing:   2,
+			Unknown:   1,
+			FailingRules: []*FailingRule{
+				{Group: "group1", Name: "Down", Type: v1.RuleTypeAlerting, LastError: "many-to-many matching not allowed", LastEvaluation: evaluatedAt},
+				{Group: "group2", Name: "up:sum", Type: v1.RuleTypeRecording, LastError: "query timed out", LastEvaluation: evaluatedAt},
+			},
+		},
+		{
+			Namespace:    "namespace2",
+			Healthy:      1,
+			FailingRules: []*FailingRule{},
+		},
+	}}, newRuleHealthDiscovery(rgs))
+
+	b, err := json.Marshal(newRuleHealthDiscovery(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"namespaces":[]}`, string(b))
+}
+
 func TestRuler_alerts(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -295,6 +643,62 @@ rules:
     test: test
 `,
 			output: "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up{}\n    - alert: up_alert\n      expr: sum(up{}) > 1\n      for: 30s\n      labels:\n        test: test\n      annotations:\n        test: test\n",
+		}, {
+			name:   "with active time intervals",
+			status: 202,
+			input: `
+name: test
+rules:
+- alert: up_alert
+  expr: sum(up{}) > 1
+active_time_intervals:
+- times:
+  - start_time: "09:00"
+    end_time: "17:00"
+  weekdays: ["monday:friday"]
+`,
+			output: "name: test\nrules:\n    - alert: up_alert\n      expr: sum(up{}) > 1\nactive_time_intervals:\n    - times:\n        - start_time: \"09:00\"\n          end_time: \"17:00\"\n      weekdays: ['monday:friday']\n",
+		},
+		{
+			name:   "with invalid active time intervals",
+			status: 400,
+			input: `
+name: test
+rules:
+- alert: up_alert
+  expr: sum(up{}) > 1
+active_time_intervals:
+- weekdays: ["someday"]
+`,
+			err: ErrBadRuleGroup,
+		},
+		{
+			name:   "with group labels",
+			status: 202,
+			input: `
+name: test
+labels:
+  team: api
+rules:
+- alert: up_alert
+  expr: sum(up{}) > 1
+  labels:
+    severity: critical
+`,
+			output: "name: test\nrules:\n    - alert: up_alert\n      expr: sum(up{}) > 1\n      labels:\n        severity: critical\nlabels:\n    team: api\n",
+		},
+		{
+			name:   "with invalid group labels",
+			status: 400,
+			input: `
+name: test
+labels:
+  0team: api
+rules:
+- alert: up_alert
+  expr: sum(up{}) > 1
+`,
+			err: errors.New(`group "test", rule 0, "up_alert": invalid label name: 0team`),
 		},
 	}
 
@@ -307,10 +711,17 @@ rules:
 			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
 			w := httptest.NewRecorder()
 
+			createdAt := time.Now()
 			router.ServeHTTP(w, req)
 			require.Equal(t, tt.status, w.Code)
 
 			if tt.err == nil {
+				// The update time of the rule group is stored along with it.
+				stored, err := a.store.GetRuleGroup(context.Background(), "user1", "namespace", "test")
+				require.NoError(t, err)
+				require.NotNil(t, stored.UpdatedAt)
+				assert.False(t, stored.UpdatedAt.Before(createdAt))
+
 				// GET
 				req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
 				w = httptest.NewRecorder()
@@ -325,126 +736,1018 @@ rules:
 	}
 }
 
-func TestRuler_DeleteNamespace(t *testing.T) {
+func TestRuler_GetRuleGroupFormat(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
-	// Keep this inside the test, not as global var, otherwise running tests with -count higher than 1 fails,
-	// as newMockRuleStore modifies the underlying map.
-	mockRulesNamespaces := map[string]rulespb.RuleGroupList{
-		"user1": {
-			&rulespb.RuleGroupDesc{
-				Name:      "group1",
-				Namespace: "namespace1",
-				User:      "user1",
-				Rules: []*rulespb.RuleDesc{
-					{
-						Record: "UP_RULE",
-						Expr:   "up",
-					},
-					{
-						Alert: "UP_ALERT",
-						Expr:  "up < 1",
-					},
-				},
-				Interval: interval,
-			},
-			&rulespb.RuleGroupDesc{
-				Name:      "fail",
-				Namespace: "namespace2",
-				User:      "user1",
-				Rules: []*rulespb.RuleDesc{
-					{
-						Record: "UP2_RULE",
-						Expr:   "up",
-					},
-					{
-						Alert: "UP2_ALERT",
-						Expr:  "up < 1",
-					},
-				},
-				Interval: interval,
-			},
-		},
-	}
-
-	r := newTestRuler(t, cfg, newMockRuleStore(mockRulesNamespaces))
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {{User: "user1", Namespace: "namespace", Name: "provisioned", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up_rule", Expr: "up"}}}},
+	})
+	r := newTestRuler(t, cfg, store)
 	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
 
 	a := NewAPI(r, r.store, log.NewNopLogger())
-
 	router := mux.NewRouter()
-	router.Path("/api/v1/rules/{namespace}").Methods(http.MethodDelete).HandlerFunc(a.DeleteNamespace)
-	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroup)
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
 
-	// Verify namespace1 rules are there.
-	req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace1/group1", nil, "user1")
+	const input = `# Recording rules.
+name: test
+rules:
+  - record: up_rule # The up metric.
+    expr: up
+`
+	req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(input), "user1")
 	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-	require.Equal(t, http.StatusOK, w.Code)
-	require.Equal(t, "name: group1\ninterval: 1m\nrules:\n    - record: UP_RULE\n      expr: up\n    - alert: UP_ALERT\n      expr: up < 1\n", w.Body.String())
-
-	// Delete namespace1
-	req = requestFor(t, http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace1", nil, "user1")
-	w = httptest.NewRecorder()
-
 	router.ServeHTTP(w, req)
 	require.Equal(t, http.StatusAccepted, w.Code)
-	require.Equal(t, "{\"status\":\"success\",\"data\":null,\"errorType\":\"\",\"error\":\"\"}", w.Body.String())
-
-	// On Partial failures
-	req = requestFor(t, http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace2", nil, "user1")
-	w = httptest.NewRecorder()
 
-	router.ServeHTTP(w, req)
-	require.Equal(t, http.StatusInternalServerError, w.Code)
-	require.Equal(t, "{\"status\":\"error\",\"data\":null,\"errorType\":\"server_error\",\"error\":\"unable to delete rg\"}", w.Body.String())
+	const canonical = "name: test\nrules:\n    - record: up_rule\n      expr: up\n"
+	for name, tc := range map[string]struct {
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"default format": {
+			url:            "/api/v1/rules/namespace/test",
+			expectedStatus: http.StatusOK,
+			expectedBody:   canonical,
+		},
+		"canonical format": {
+			url:            "/api/v1/rules/namespace/test?format=canonical",
+			expectedStatus: http.StatusOK,
+			expectedBody:   canonical,
+		},
+		"raw format": {
+			url:            "/api/v1/rules/namespace/test?format=raw",
+			expectedStatus: http.StatusOK,
+			expectedBody:   input,
+		},
+		"raw format of a rule group not created through the API": {
+			url:            "/api/v1/rules/namespace/provisioned?format=raw",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "name: provisioned\ninterval: 1m\nrules:\n    - record: up_rule\n      expr: up\n",
+		},
+		"invalid format": {
+			url:            "/api/v1/rules/namespace/test?format=json",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidRuleGroupFormat.Error() + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080"+tc.url, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
 }
 
-func TestRuler_LimitsPerGroup(t *testing.T) {
+func TestRuler_GetRuleGroupConditional(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
 	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
 	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
 
-	r.limits = &ruleLimits{maxRuleGroups: 1, maxRulesPerRuleGroup: 1}
-
 	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET", "HEAD").HandlerFunc(a.GetRuleGroup)
 
-	tc := []struct {
-		name   string
+	create := func(input string) {
+		req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(input), "user1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code)
+	}
+	get := func(method, url string, headers map[string]string) *httptest.ResponseRecorder {
+		req := requestFor(t, method, "https://localhost:8080"+url, nil, "user1")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	create("name: test\nrules:\n- record: up_rule\n  expr: up\n")
+
+	w := get(http.MethodGet, "/api/v1/rules/namespace/test", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+
+	// The entity tag of the canonical format is the checksum of the rule group.
+	stored, err := a.store.GetRuleGroup(context.Background(), "user1", "namespace", "test")
+	require.NoError(t, err)
+	checksum, err := rulespb.Checksum(stored)
+	require.NoError(t, err)
+	assert.Equal(t, `"`+checksum+`"`, etag)
+
+	t.Run("HEAD", func(t *testing.T) {
+		w := get(http.MethodHead, "/api/v1/rules/namespace/test", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, lastModified, w.Header().Get("Last-Modified"))
+		assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("If-None-Match matching", func(t *testing.T) {
+		w := get(http.MethodGet, "/api/v1/rules/namespace/test", map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("If-None-Match of another format", func(t *testing.T) {
+		w := get(http.MethodGet, "/api/v1/rules/namespace/test?format=raw", map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		w := get(http.MethodGet, "/api/v1/rules/namespace/test", map[string]string{"If-Modified-Since": lastModified})
+		require.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("changed rule group", func(t *testing.T) {
+		create("name: test\nrules:\n- record: up_rule\n  expr: up > 0\n")
+
+		w := get(http.MethodGet, "/api/v1/rules/namespace/test", map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, "name: test\nrules:\n    - record: up_rule\n      expr: up > 0\n", w.Body.String())
+	})
+
+	t.Run("HEAD of a missing rule group", func(t *testing.T) {
+		w := get(http.MethodHead, "/api/v1/rules/namespace/missing", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestRuler_ListRulesRawFormat(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+	router.Path("/api/v1/rules/{namespace}").Methods("GET").HandlerFunc(a.ListRules)
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+	req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(`name: test
+rules:
+  # Runbook: https://example.com/runbook
+  - alert: down # Paging.
+    expr: up == 0
+`), "user1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	for _, url := range []string{"/api/v1/rules?format=raw", "/api/v1/rules/namespace?format=raw"} {
+		req = requestFor(t, http.MethodGet, "https://localhost:8080"+url, nil, "user1")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `namespace:
+    - name: test
+      rules:
+        # Runbook: https://example.com/runbook
+        - alert: down # Paging.
+          expr: up == 0
+`, w.Body.String())
+	}
+}
+
+func TestRuler_ListRulesChecksums(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules").Methods("GET").HandlerFunc(a.ListRules)
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+
+	req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(`name: test
+rules:
+  - record: up_rule # The up metric.
+    expr: up
+`), "user1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// The checksum is the SHA-256 of the rule group in the canonical format.
+	req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	sum := sha256.Sum256(w.Body.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	for name, tc := range map[string]struct {
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"no checksums": {
+			url:            "/api/v1/rules?checksums=false",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "namespace:\n    - name: test\n      rules:\n        - record: up_rule\n          expr: up\n",
+		},
+		"canonical format": {
+			url:            "/api/v1/rules?checksums=true",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "namespace:\n    - name: test\n      rules:\n        - record: up_rule\n          expr: up\n      checksum: " + checksum + "\n",
+		},
+		"raw format": {
+			url:            "/api/v1/rules?checksums=true&format=raw",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "namespace:\n    - name: test\n      rules:\n        - record: up_rule # The up metric.\n          expr: up\n      checksum: " + checksum + "\n",
+		},
+		"invalid checksums": {
+			url:            "/api/v1/rules?checksums=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidChecksums.Error() + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080"+tc.url, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_ListRuleNamespaces(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	group := func(namespace, name string) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{User: "user1", Namespace: namespace, Name: name, Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up_rule", Expr: "up"}}}
+	}
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {group("namespace2", "group1"), group("namespace1", "group1"), group("namespace2", "group2")},
+	})
+	r := newTestRuler(t, cfg, store)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rule_namespaces").Methods("GET").HandlerFunc(a.ListRuleNamespaces)
+
+	for userID, expected := range map[string]string{
+		"user1": "namespaces:\n    - name: namespace1\n      groups: 1\n    - name: namespace2\n      groups: 2\n",
+		"user2": "namespaces: []\n",
+	} {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rule_namespaces", nil, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, expected, w.Body.String())
+	}
+}
+
+func TestRuler_SearchRules(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			{User: "user1", Namespace: "namespace2", Name: "group1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+				{Record: "job:http_requests:rate5m", Expr: "sum by (job) (rate(http_requests_total[5m]))"},
+				{Alert: "HighErrorRate", Expr: "job:http_errors:rate5m > 10", Labels: []mimirpb.LabelAdapter{{Name: "team", Value: "Frontend"}}},
+			}},
+			{User: "user1", Namespace: "namespace1", Name: "group1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+				{Record: "job:http_errors:rate5m", Expr: `sum by (job) (rate(http_requests_total{code=~"5.."}[5m]))`},
+			}},
+		},
+	})
+	r := newTestRuler(t, cfg, store)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rule_search").Methods("GET").HandlerFunc(a.SearchRules)
+
+	for name, tc := range map[string]struct {
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"substring matching expressions": {
+			query:          "q=HTTP_REQUESTS_TOTAL",
+			expectedStatus: http.StatusOK,
+			expectedBody: `rules:
+    - namespace: namespace1
+      group: group1
+      record: job:http_errors:rate5m
+      expr: sum by (job) (rate(http_requests_total{code=~"5.."}[5m]))
+    - namespace: namespace2
+      group: group1
+      record: job:http_requests:rate5m
+      expr: sum by (job) (rate(http_requests_total[5m]))
+`,
+		},
+		"substring matching names and expressions": {
+			query:          "q=http_errors",
+			expectedStatus: http.StatusOK,
+			expectedBody: `rules:
+    - namespace: namespace1
+      group: group1
+      record: job:http_errors:rate5m
+      expr: sum by (job) (rate(http_requests_total{code=~"5.."}[5m]))
+    - namespace: namespace2
+      group: group1
+      alert: HighErrorRate
+      expr: job:http_errors:rate5m > 10
+      labels:
+        team: Frontend
+`,
+		},
+		"regular expression matching labels": {
+			query:          "q=^Front&regex=true",
+			expectedStatus: http.StatusOK,
+			expectedBody: `rules:
+    - namespace: namespace2
+      group: group1
+      alert: HighErrorRate
+      expr: job:http_errors:rate5m > 10
+      labels:
+        team: Frontend
+`,
+		},
+		"regular expression not matching the unset rule names": {
+			query:          "q=^$&regex=true",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "rules: []\n",
+		},
+		"no match": {
+			query:          "q=unknown",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "rules: []\n",
+		},
+		"no query": {
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrNoSearchQuery.Error() + "\n",
+		},
+		"invalid regex parameter": {
+			query:          "q=up&regex=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidSearchRegex.Error() + "\n",
+		},
+		"invalid regular expression": {
+			query:          "q=(&regex=true",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid search regular expression: error parsing regexp: missing closing ): `(`\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rule_search?"+tc.query, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_MetricUsage(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			{User: "user1", Namespace: "namespace", Name: "group", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+				{Record: "job:http_requests:rate5m", Expr: "sum by (job) (rate(http_requests_total[5m]))"},
+				{Record: "http_requests:rate5m", Expr: "sum(job:http_requests:rate5m)"},
+				{Alert: "TooManyRequests", Expr: `{__name__=~"job:http_requests:.+"} > 100`},
+				{Alert: "NoRequests", Expr: `absent({job="api"})`},
+				{Alert: "ManyAlerts", Expr: "count(ALERTS) > 10"},
+			}},
+		},
+	})
+	r := newTestRuler(t, cfg, store)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rule_metric_usage").Methods("GET").HandlerFunc(a.MetricUsage)
+
+	for name, tc := range map[string]struct {
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"recorded metric": {
+			query:          "metric=job:http_requests:rate5m",
+			expectedStatus: http.StatusOK,
+			expectedBody: `metric: job:http_requests:rate5m
+produced_by:
+    - namespace: namespace
+      group: group
+      record: job:http_requests:rate5m
+      expr: sum by (job) (rate(http_requests_total[5m]))
+used_by:
+    - namespace: namespace
+      group: group
+      record: http_requests:rate5m
+      expr: sum(job:http_requests:rate5m)
+    - namespace: namespace
+      group: group
+      alert: TooManyRequests
+      expr: '{__name__=~"job:http_requests:.+"} > 100'
+`,
+		},
+		"alerts metric": {
+			query:          "metric=ALERTS",
+			expectedStatus: http.StatusOK,
+			expectedBody: `metric: ALERTS
+produced_by:
+    - namespace: namespace
+      group: group
+      alert: TooManyRequests
+      expr: '{__name__=~"job:http_requests:.+"} > 100'
+    - namespace: namespace
+      group: group
+      alert: NoRequests
+      expr: absent({job="api"})
+    - namespace: namespace
+      group: group
+      alert: ManyAlerts
+      expr: count(ALERTS) > 10
+used_by:
+    - namespace: namespace
+      group: group
+      alert: ManyAlerts
+      expr: count(ALERTS) > 10
+`,
+		},
+		"unused metric": {
+			query:          "metric=unknown",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "metric: unknown\nproduced_by: []\nused_by: []\n",
+		},
+		"no metric": {
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrNoMetric.Error() + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rule_metric_usage?"+tc.query, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_RuleGraph(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			{User: "user1", Namespace: "namespace", Name: "group", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Alert: "Down", Expr: "job:up:sum == 0"},
+			}},
+		},
+	})
+	r := newTestRuler(t, cfg, store)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/rule_graph").Methods("GET").HandlerFunc(a.RuleGraph)
+
+	for name, tc := range map[string]struct {
+		query               string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		"default format": {
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"nodes":[{"id":"namespace/group/0","namespace":"namespace","group":"group","record":"job:up:sum"},{"id":"namespace/group/1","namespace":"namespace","group":"group","alert":"Down"}],"edges":[{"from":"namespace/group/0","to":"namespace/group/1","metric":"job:up:sum"}],"cycles":[]}`,
+		},
+		"dot format": {
+			query:               "format=dot",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/vnd.graphviz",
+			expectedBody: `digraph rules {
+  "namespace/group/0" [label="job:up:sum\nnamespace/group", shape=box];
+  "namespace/group/1" [label="Down\nnamespace/group", shape=ellipse];
+  "namespace/group/0" -> "namespace/group/1" [label="job:up:sum"];
+}
+`,
+		},
+		"invalid format": {
+			query:               "format=svg",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        ErrInvalidRuleGraphFormat.Error() + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rule_graph?"+tc.query, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_DeleteNamespace(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	// Keep this inside the test, not as global var, otherwise running tests with -count higher than 1 fails,
+	// as newMockRuleStore modifies the underlying map.
+	mockRulesNamespaces := map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{
+						Record: "UP_RULE",
+						Expr:   "up",
+					},
+					{
+						Alert: "UP_ALERT",
+						Expr:  "up < 1",
+					},
+				},
+				Interval: interval,
+			},
+			&rulespb.RuleGroupDesc{
+				Name:      "fail",
+				Namespace: "namespace2",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{
+						Record: "UP2_RULE",
+						Expr:   "up",
+					},
+					{
+						Alert: "UP2_ALERT",
+						Expr:  "up < 1",
+					},
+				},
+				Interval: interval,
+			},
+		},
+	}
+
+	r := newTestRuler(t, cfg, newMockRuleStore(mockRulesNamespaces))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods(http.MethodDelete).HandlerFunc(a.DeleteNamespace)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroup)
+
+	// Verify namespace1 rules are there.
+	req := requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace1/group1", nil, "user1")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "name: group1\ninterval: 1m\nrules:\n    - record: UP_RULE\n      expr: up\n    - alert: UP_ALERT\n      expr: up < 1\n", w.Body.String())
+
+	// Delete namespace1
+	req = requestFor(t, http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace1", nil, "user1")
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, "{\"status\":\"success\",\"data\":null,\"errorType\":\"\",\"error\":\"\"}", w.Body.String())
+
+	// On Partial failures
+	req = requestFor(t, http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace2", nil, "user1")
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "{\"status\":\"error\",\"data\":null,\"errorType\":\"server_error\",\"error\":\"unable to delete rg\"}", w.Body.String())
+}
+
+func TestRuler_LimitsPerGroup(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = &ruleLimits{maxRuleGroups: 1, maxRulesPerRuleGroup: 1, maxRecordingLabels: 1}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		err    error
+		status int
+	}{
+		{
+			name:   "when exceeding the rules per rule group limit",
+			status: 400,
+			input: `
+name: test
+interval: 15s
+rules:
+- record: up_rule
+  expr: up{}
+- alert: up_alert
+  expr: sum(up{}) > 1
+  for: 30s
+  annotations:
+    test: test
+  labels:
+    test: test
+`,
+			output: "per-user rules per rule group limit (limit: 1 actual: 2) exceeded\n",
+		},
+		{
+			name:   "when exceeding the recording rule labels limit",
+			status: 400,
+			input: `
+name: test
+interval: 15s
+rules:
+- record: up_rule
+  expr: up{}
+  labels:
+    team: a
+    env: prod
+`,
+			output: "per-user recording rule labels limit (limit: 1 actual: 2) exceeded by the recording rule up_rule\n",
+		},
+		{
+			name:   "when adding a reserved label",
+			status: 400,
+			input: `
+name: test
+interval: 15s
+rules:
+- record: up_rule
+  expr: up{}
+  labels:
+    __tenant_id__: user2
+`,
+			output: "the recording rule up_rule adds the label __tenant_id__, which is reserved\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_RulerGroupLimits(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = &ruleLimits{maxRuleGroups: 1, maxRulesPerRuleGroup: 1}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
 		input  string
 		output string
 		err    error
 		status int
 	}{
-		{
-			name:   "when exceeding the rules per rule group limit",
-			status: 400,
+		{
+			name:   "when pushing the first group within bounds of the limit",
+			status: 202,
+			input: `
+name: test_first_group_will_succeed
+interval: 15s
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "{\"status\":\"success\",\"data\":{\"group\":{\"name\":\"test_first_group_will_succeed\",\"interval\":\"15s\",\"rules\":[{\"record\":\"up_rule\",\"expr\":\"up{}\"}]},\"checksum\":\"92a5972d26d934c51d8c9bbcb8ef4cab060048ab534fac28a1e217c30f956139\"},\"errorType\":\"\",\"error\":\"\"}",
+		},
+		{
+			name:   "when exceeding the rule group limit after sending the first group",
+			status: 400,
+			input: `
+name: test_second_group_will_fail
+interval: 15s
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "per-user rule groups limit (limit: 1 actual: 2) exceeded\n",
+		},
+	}
+
+	// define once so the requests build on each other so the number of rules can be tested
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			// POST
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateDuplicateRecordingRules(t *testing.T) {
+	existing := map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "existing",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{
+						Record: "job:up:sum",
+						Expr:   "sum by (job) (up)",
+						Labels: []mimirpb.LabelAdapter{{Name: "team", Value: "a"}},
+					},
+				},
+				Interval: interval,
+			},
+		},
+	}
+
+	tc := map[string]struct {
+		policy    string
+		namespace string
+		input     string
+		status    int
+		output    string
+	}{
+		"duplicate in another group with policy disabled": {
+			policy:    duplicateRecordingRulesPolicyDisabled,
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    team: a
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"test","rules":[{"record":"job:up:sum","expr":"sum by (job) (up)","labels":{"team":"a"}}]},"checksum":"91685581a817a675e9cbf01cd5e417ff1a076454b4ac78ee5d4fe5e00859beb7"},"errorType":"","error":""}`,
+		},
+		"duplicate in another group with policy warn": {
+			policy:    duplicateRecordingRulesPolicyWarn,
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    team: a
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"test","rules":[{"record":"job:up:sum","expr":"sum by (job) (up)","labels":{"team":"a"}}]},"checksum":"91685581a817a675e9cbf01cd5e417ff1a076454b4ac78ee5d4fe5e00859beb7"},"errorType":"","error":"","warnings":["recording rule \"job:up:sum{team=\\\"a\\\"}\" records to the same series as another recording rule in namespace \"namespace1\", group \"existing\""]}`,
+		},
+		"duplicate in another group with policy reject": {
+			policy:    duplicateRecordingRulesPolicyReject,
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    team: a
+`,
+			status: http.StatusBadRequest,
+			output: "recording rule \"job:up:sum{team=\\\"a\\\"}\" records to the same series as another recording rule in namespace \"namespace1\", group \"existing\"\n",
+		},
+		"duplicate within the same group with policy reject": {
+			policy:    duplicateRecordingRulesPolicyReject,
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: up:sum
+  expr: sum(up)
+- record: up:sum
+  expr: sum(up{job="a"})
+`,
+			status: http.StatusBadRequest,
+			output: "recording rule \"up:sum\" records to the same series as another recording rule in namespace \"namespace2\", group \"test\"\n",
+		},
+		"duplicate with the labels of the group with policy reject": {
+			policy:    duplicateRecordingRulesPolicyReject,
+			namespace: "namespace2",
+			input: `
+name: test
+labels:
+  team: a
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+`,
+			status: http.StatusBadRequest,
+			output: "recording rule \"job:up:sum{team=\\\"a\\\"}\" records to the same series as another recording rule in namespace \"namespace1\", group \"existing\"\n",
+		},
+		"same metric name with different labels with policy reject": {
+			policy:    duplicateRecordingRulesPolicyReject,
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    team: b
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"test","rules":[{"record":"job:up:sum","expr":"sum by (job) (up)","labels":{"team":"b"}}]},"checksum":"09eff2c8ee56a98063196f77e3b9ffef610d7d7c3864aab855be6604dd914f26"},"errorType":"","error":""}`,
+		},
+		"replacing the group owning the recording rule with policy reject": {
+			policy:    duplicateRecordingRulesPolicyReject,
+			namespace: "namespace1",
+			input: `
+name: existing
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+  labels:
+    team: a
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"existing","rules":[{"record":"job:up:sum","expr":"sum by (job) (up)","labels":{"team":"a"}}]},"checksum":"387d1a3d042ce1014d574462e4be82a25b53325ad97b17d8c2f8ff471e367272"},"errorType":"","error":""}`,
+		},
+	}
+
+	for name, tt := range tc {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.DuplicateRecordingRulesPolicy = tt.policy
+
+			// Copy the existing rules, because newMockRuleStore modifies the underlying map.
+			rules := map[string]rulespb.RuleGroupList{}
+			for u, groups := range existing {
+				for _, g := range groups {
+					cp := *g
+					rules[u] = append(rules[u], &cp)
+				}
+			}
+
+			r := newTestRuler(t, cfg, newMockRuleStore(rules))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/"+tt.namespace, strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_CreateRecordingRuleCycle(t *testing.T) {
+	existing := map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "existing",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{Record: "job:up:sum", Expr: "sum by (job) (up:ratio)"},
+				},
+				Interval: interval,
+			},
+		},
+	}
+
+	tc := map[string]struct {
+		namespace string
+		input     string
+		status    int
+		output    string
+	}{
+		"no cycle": {
+			namespace: "namespace2",
 			input: `
 name: test
-interval: 15s
 rules:
-- record: up_rule
-  expr: up{}
-- alert: up_alert
-  expr: sum(up{}) > 1
-  for: 30s
-  annotations:
-    test: test
-  labels:
-    test: test
+- record: up:sum
+  expr: sum(job:up:sum)
 `,
-			output: "per-user rules per rule group limit (limit: 1 actual: 2) exceeded\n",
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"test","rules":[{"record":"up:sum","expr":"sum(job:up:sum)"}]},"checksum":"4b3ab9dc064952b78d031c7bc68636392f7b1d7ab17e3ce911c5e5cfed46ac38"},"errorType":"","error":""}`,
+		},
+		"cycle with another group": {
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: up:ratio
+  expr: job:up:sum / 10
+`,
+			status: http.StatusBadRequest,
+			output: "the rule group would introduce a cycle among recording rules: \"up:ratio\" (namespace \"namespace2\", group \"test\") -> \"job:up:sum\" (namespace \"namespace1\", group \"existing\") -> \"up:ratio\" (namespace \"namespace2\", group \"test\")\n",
+		},
+		"rule depending on itself": {
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- record: up:max
+  expr: max(up:max)
+`,
+			status: http.StatusBadRequest,
+			output: "the rule group would introduce a cycle among recording rules: \"up:max\" (namespace \"namespace2\", group \"test\") -> \"up:max\" (namespace \"namespace2\", group \"test\")\n",
+		},
+		"cycle through an alerting rule": {
+			namespace: "namespace2",
+			input: `
+name: test
+rules:
+- alert: Alert
+  expr: count(alerts:count) > 1
+- record: alerts:count
+  expr: count(ALERTS)
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"test","rules":[{"alert":"Alert","expr":"count(alerts:count) \u003e 1"},{"record":"alerts:count","expr":"count(ALERTS)"}]},"checksum":"081641364561c62fc6f868779c4a435767fc5cd27a4a5a13a7797255e8c9320f"},"errorType":"","error":""}`,
+		},
+		"replacing the group closing the cycle": {
+			namespace: "namespace1",
+			input: `
+name: existing
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+- record: up:ratio
+  expr: job:up:sum / 10
+`,
+			status: http.StatusAccepted,
+			output: `{"status":"success","data":{"group":{"name":"existing","rules":[{"record":"job:up:sum","expr":"sum by (job) (up)"},{"record":"up:ratio","expr":"job:up:sum / 10"}]},"checksum":"ccd982a1ade5a465b02ac0094498c4661fad1e73ae5c342ad8ca69da0865a421"},"errorType":"","error":""}`,
 		},
 	}
 
-	for _, tt := range tc {
-		t.Run(tt.name, func(t *testing.T) {
+	for name, tt := range tc {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+
+			// Copy the existing rules, because newMockRuleStore modifies the underlying map.
+			rules := map[string]rulespb.RuleGroupList{}
+			for u, groups := range existing {
+				for _, g := range groups {
+					cp := *g
+					rules[u] = append(rules[u], &cp)
+				}
+			}
+
+			r := newTestRuler(t, cfg, newMockRuleStore(rules))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
 			router := mux.NewRouter()
 			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
-			// POST
-			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/"+tt.namespace, strings.NewReader(tt.input), "user1")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
@@ -454,67 +1757,386 @@ rules:
 	}
 }
 
-func TestRuler_RulerGroupLimits(t *testing.T) {
-	cfg := defaultRulerConfig(t)
+func TestRuler_CreateRuleGroupTemplate(t *testing.T) {
+	const input = `
+name: test
+bindings:
+- service: api
+- service: db
+rules:
+- record: ${service}:up:sum
+  expr: sum(up{job="${service}"})
+`
+	const expected = `name: test
+rules:
+    - record: api:up:sum
+      expr: sum(up{job="api"})
+    - record: db:up:sum
+      expr: sum(up{job="db"})
+`
 
-	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
 	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
 
-	r.limits = &ruleLimits{maxRuleGroups: 1, maxRulesPerRuleGroup: 1}
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rule_template_preview").Methods("POST").HandlerFunc(a.PreviewRuleGroupTemplate)
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/prometheus/config/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+
+	// The preview returns the expanded rule group, without storing it.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rule_template_preview", strings.NewReader(input), "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+
+	stored, err := r.store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "")
+	require.NoError(t, err)
+	require.Empty(t, stored)
+
+	// The rule group is stored expanded, while its raw content is the template.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1", strings.NewReader(input), "user1"))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test?format=raw", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, input, w.Body.String())
+
+	// A rule group whose rules reference an unset variable is rejected.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rule_template_preview", strings.NewReader(strings.Replace(input, "- service: db", "- other: db", 1)), "user1"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "references the variable service, which is not set by binding 2")
+}
+
+func TestRuler_CreateRuleGroupWithAliases(t *testing.T) {
+	const input = `
+name: test
+x-defaults: &defaults
+  for: 5m
+  labels:
+    team: api
+rules:
+- alert: APIDown
+  expr: &expr up{job="api"} == 0
+  <<: *defaults
+- alert: APIDownCritical
+  expr: *expr
+  <<: *defaults
+  for: 15m
+`
+	const expected = `name: test
+rules:
+    - alert: APIDown
+      expr: up{job="api"} == 0
+      for: 5m
+      labels:
+        team: api
+    - alert: APIDownCritical
+      expr: up{job="api"} == 0
+      for: 15m
+      labels:
+        team: api
+`
+
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
 
 	a := NewAPI(r, r.store, log.NewNopLogger())
 
-	tc := []struct {
-		name   string
-		input  string
-		output string
-		err    error
-		status int
-	}{
-		{
-			name:   "when pushing the first group within bounds of the limit",
-			status: 202,
-			input: `
-name: test_first_group_will_succeed
-interval: 15s
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/prometheus/config/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+
+	// The rule group is stored with its aliases and merge keys expanded, while its raw content keeps them.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1", strings.NewReader(input), "user1"))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `{"status":"success","data":{"group":{"name":"test","rules":[{"alert":"APIDown","expr":"up{job=\"api\"} == 0","for":"5m","labels":{"team":"api"}},{"alert":"APIDownCritical","expr":"up{job=\"api\"} == 0","for":"15m","labels":{"team":"api"}}]},"checksum":"`+strings.Trim(etag, `"`)+`"},"errorType":"","error":""}`, w.Body.String())
+
+	// The response returns the stored rule group as the get rule group endpoint, with the same entity tag.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test?format=raw", nil, "user1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, input, w.Body.String())
+}
+
+func TestRuler_CreateRuleGroupStrictParsing(t *testing.T) {
+	const input = `
+name: test
 rules:
-- record: up_rule
-  expr: up{}
-`,
-			output: "{\"status\":\"success\",\"data\":null,\"errorType\":\"\",\"error\":\"\"}",
+- alert: APIDown
+  expr: up{job="api"} == 0
+  anotations:
+    summary: The API is down.
+`
+
+	tests := map[string]struct {
+		strict         bool
+		header         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"lenient": {
+			expectedStatus: http.StatusAccepted,
 		},
-		{
-			name:   "when exceeding the rule group limit after sending the first group",
-			status: 400,
-			input: `
-name: test_second_group_will_fail
-interval: 15s
+		"strict": {
+			strict:         true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "the rule group has unknown fields: rules[0].anotations",
+		},
+		"strict enabled by the header": {
+			header:         "true",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "the rule group has unknown fields: rules[0].anotations",
+		},
+		"strict disabled by the header": {
+			strict:         true,
+			header:         "false",
+			expectedStatus: http.StatusAccepted,
+		},
+		"invalid header": {
+			header:         "maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidStrictRuleGroupParsing.Error(),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.StrictRuleGroupParsing = tc.strict
+			r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+			router := mux.NewRouter()
+			router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1", strings.NewReader(input), "user1")
+			if tc.header != "" {
+				req.Header.Set(strictRuleGroupParsingHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody+"\n", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRuler_CreateRuleGroupJSON(t *testing.T) {
+	const input = `{
+  "name": "test",
+  "interval": "1m",
+  "rules": [
+    {"alert": "APIDown", "expr": "up{job=\"api\"} == 0", "for": "5m", "labels": {"team": "api"}},
+    {"record": "job:up:sum", "expr": "sum by (job) (up)"}
+  ]
+}`
+	const expectedJSON = `{"name":"test","interval":"1m","rules":[{"alert":"APIDown","expr":"up{job=\"api\"} == 0","for":"5m","labels":{"team":"api"}},{"record":"job:up:sum","expr":"sum by (job) (up)"}]}
+`
+	const expectedYAML = `name: test
+interval: 1m
 rules:
-- record: up_rule
-  expr: up{}
+    - alert: APIDown
+      expr: up{job="api"} == 0
+      for: 5m
+      labels:
+        team: api
+    - record: job:up:sum
+      expr: sum by (job) (up)
+`
+
+	cfg := defaultRulerConfig(t)
+	cfg.StrictRuleGroupParsing = true
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/prometheus/config/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1", strings.NewReader(body), "user1")
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		req := requestFor(t, http.MethodGet, url, nil, "user1")
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(input)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	// The rule group is returned in the JSON format if requested, with the same fields as in the YAML format.
+	for _, format := range []string{"", "?format=raw"} {
+		w = get("https://localhost:8080/prometheus/config/v1/rules/namespace1/test"+format, "application/json")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, expectedJSON, w.Body.String())
+
+		w = get("https://localhost:8080/prometheus/config/v1/rules/namespace1/test"+format, "application/yaml, application/json")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+		assert.Equal(t, expectedYAML, w.Body.String())
+	}
+
+	// The rule groups in the JSON format are validated as in the YAML format.
+	w = post(strings.Replace(input, `"labels"`, `"lables"`, 1))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "the rule group has unknown fields: rules[0].lables\n", w.Body.String())
+
+	w = post(`{"name": "test", "rules": [}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ErrBadRuleGroup.Error()+"\n", w.Body.String())
+
+	w = post(`{"name": "test", "rules": []} {}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ErrBadRuleGroup.Error()+"\n", w.Body.String())
+}
+
+func TestRuler_ImportRuleGroups(t *testing.T) {
+	bundle := func(main string) io.Reader {
+		payload, err := json.Marshal(jsonnetBundle{
+			Main:  main,
+			Files: map[string]string{"lib.libsonnet": `{ rule(job):: { record: job + ':up:sum', expr: 'sum(up{job="%s"})' % job } }`},
+		})
+		require.NoError(t, err)
+		return strings.NewReader(string(payload))
+	}
+
+	tests := map[string]struct {
+		enabled        bool
+		format         string
+		main           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedGroups []string
+	}{
+		"import disabled": {
+			format:         "jsonnet",
+			main:           `{ groups: [] }`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   errJsonnetImportDisabled.Error(),
+		},
+		"unsupported format": {
+			enabled:        true,
+			format:         "unknown",
+			main:           `{ groups: [] }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   errInvalidRuleImportFormat.Error(),
+		},
+		"no rule groups": {
+			enabled:        true,
+			format:         "jsonnet",
+			main:           `{ groups: [] }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   errNoImportedRuleGroups.Error(),
+		},
+		"duplicate rule group names": {
+			enabled:        true,
+			format:         "jsonnet",
+			main:           `local lib = import 'lib.libsonnet'; { groups: [{ name: 'g', rules: [lib.rule('api')] }, { name: 'g', rules: [lib.rule('db')] }] }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "the import contains several rule groups named g",
+		},
+		"invalid rule group is rejected with the whole import": {
+			enabled:        true,
+			format:         "jsonnet",
+			main:           `local lib = import 'lib.libsonnet'; { groups: [{ name: 'api', rules: [lib.rule('api')] }, { name: 'invalid', rules: [{ record: 'invalid', expr: 'sum(' }] }] }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "rule group invalid:",
+		},
+		"rule groups imported": {
+			enabled:        true,
+			format:         "jsonnet",
+			main:           `local lib = import 'lib.libsonnet'; { groups: [{ name: 'api', rules: [lib.rule('api')] }, { name: 'db', rules: [lib.rule('db')] }] }`,
+			expectedStatus: http.StatusAccepted,
+			expectedGroups: []string{"api", "db"},
+		},
+		"PrometheusRule resources imported": {
+			format: "prometheus_rule",
+			body: `
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: api
+spec:
+  groups:
+  - name: api
+    rules:
+    - record: api:up:sum
+      expr: sum(up{job="api"})
 `,
-			output: "per-user rule groups limit (limit: 1 actual: 2) exceeded\n",
+			expectedStatus: http.StatusAccepted,
+			expectedGroups: []string{"api"},
 		},
 	}
 
-	// define once so the requests build on each other so the number of rules can be tested
-	router := mux.NewRouter()
-	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			cfg.JsonnetImport = JsonnetImportConfig{Enabled: tc.enabled, MaxBundleSizeBytes: 1024 * 1024, MaxFiles: 10, MaxStackDepth: 500, MaxOutputSizeBytes: 1024 * 1024, EvaluationTimeout: 10 * time.Second, MaxConcurrentEvaluations: 1}
+			r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+
+			router := mux.NewRouter()
+			router.Path("/prometheus/config/v1/rule_import/{namespace}").Methods("POST").HandlerFunc(a.ImportRuleGroups)
+
+			body := bundle(tc.main)
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
 
-	for _, tt := range tc {
-		t.Run(tt.name, func(t *testing.T) {
-			// POST
-			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
 			w := httptest.NewRecorder()
+			router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rule_import/namespace1?format="+tc.format, body, "user1"))
+			require.Equal(t, tc.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
 
-			router.ServeHTTP(w, req)
-			require.Equal(t, tt.status, w.Code)
-			require.Equal(t, tt.output, w.Body.String())
+			stored, err := r.store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "namespace1")
+			require.NoError(t, err)
+
+			var names []string
+			for _, g := range stored {
+				names = append(names, g.Name)
+			}
+			assert.ElementsMatch(t, tc.expectedGroups, names)
 		})
 	}
 }
 
-func requestFor(t *testing.T, method string, url string, body io.Reader, userID string) *http.Request {
+func requestFor(t testing.TB, method string, url string, body io.Reader, userID string) *http.Request {
 	t.Helper()
 
 	req := httptest.NewRequest(method, url, body)
@@ -522,3 +2144,147 @@ func requestFor(t *testing.T, method string, url string, body io.Reader, userID
 
 	return req.WithContext(ctx)
 }
+
+func TestRuler_EvaluateRuleGroup(t *testing.T) {
+	// The mock rule store modifies the input map, so a new one is built for each test case.
+	mockRules := func() map[string]rulespb.RuleGroupList {
+		return map[string]rulespb.RuleGroupList{"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{
+						Record: "job:up:sum",
+						Expr:   "sum by (job) (up)",
+					},
+					{
+						Alert: "UP_ALERT",
+						Expr:  "up < 1",
+					},
+					{
+						Record: "failing",
+						Expr:   "vector(1)",
+					},
+				},
+				Interval: interval,
+			},
+		}}
+	}
+
+	queryFunc := func(_ context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		switch qs {
+		case "vector(1)":
+			return nil, errors.New("query failed")
+		default:
+			return promql.Vector{{Point: promql.Point{T: ts.UnixMilli(), V: 0}, Metric: labels.FromStrings("job", "test")}}, nil
+		}
+	}
+
+	tests := map[string]struct {
+		limit      int
+		queryFunc  promRules.QueryFunc
+		group      string
+		requests   int
+		lastStatus int
+	}{
+		"on-demand evaluation disabled for the tenant": {
+			limit:      0,
+			queryFunc:  queryFunc,
+			group:      "group1",
+			requests:   1,
+			lastStatus: http.StatusForbidden,
+		},
+		"on-demand evaluation not available": {
+			limit:      1,
+			group:      "group1",
+			requests:   1,
+			lastStatus: http.StatusNotImplemented,
+		},
+		"rule group not found": {
+			limit:      1,
+			queryFunc:  queryFunc,
+			group:      "unknown",
+			requests:   1,
+			lastStatus: http.StatusNotFound,
+		},
+		"rate limited": {
+			limit:      1,
+			queryFunc:  queryFunc,
+			group:      "group1",
+			requests:   2,
+			lastStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := buildRuler(t, defaultRulerConfig(t), newMockRuleStore(mockRules()), nil)
+			r.limits = ruleLimits{onDemandEvaluations: tc.limit}
+			r.queryFunc = tc.queryFunc
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}/{groupName}/evaluate").Methods(http.MethodPost).HandlerFunc(a.EvaluateRuleGroup)
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace1/"+tc.group+"/evaluate", nil, "user1"))
+			}
+			require.Equal(t, tc.lastStatus, w.Code)
+		})
+	}
+
+	t.Run("successful evaluation", func(t *testing.T) {
+		r := buildRuler(t, defaultRulerConfig(t), newMockRuleStore(mockRules()), nil)
+		r.limits = ruleLimits{onDemandEvaluations: 1}
+		r.queryFunc = queryFunc
+
+		a := NewAPI(r, r.store, log.NewNopLogger())
+		router := mux.NewRouter()
+		router.Path("/api/v1/rules/{namespace}/{groupName}/evaluate").Methods(http.MethodPost).HandlerFunc(a.EvaluateRuleGroup)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace1/group1/evaluate", nil, "user1"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res struct {
+			Status string `json:"status"`
+			Data   struct {
+				Name  string `json:"name"`
+				File  string `json:"file"`
+				Rules []struct {
+					Name      string `json:"name"`
+					Health    string `json:"health"`
+					LastError string `json:"lastError"`
+					State     string `json:"state"`
+					Alerts    []struct {
+						State string `json:"state"`
+					} `json:"alerts"`
+				} `json:"rules"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		require.Equal(t, "success", res.Status)
+		require.Equal(t, "group1", res.Data.Name)
+		require.Equal(t, "namespace1", res.Data.File)
+		require.Len(t, res.Data.Rules, 3)
+
+		assert.Equal(t, "job:up:sum", res.Data.Rules[0].Name)
+		assert.Equal(t, string(promRules.HealthGood), res.Data.Rules[0].Health)
+
+		assert.Equal(t, "UP_ALERT", res.Data.Rules[1].Name)
+		assert.Equal(t, string(promRules.HealthGood), res.Data.Rules[1].Health)
+		assert.Equal(t, "firing", res.Data.Rules[1].State)
+		require.Len(t, res.Data.Rules[1].Alerts, 1)
+		assert.Equal(t, "firing", res.Data.Rules[1].Alerts[0].State)
+
+		assert.Equal(t, "failing", res.Data.Rules[2].Name)
+		assert.Equal(t, string(promRules.HealthBad), res.Data.Rules[2].Health)
+		assert.Equal(t, "query failed", res.Data.Rules[2].LastError)
+
+		// The evaluation doesn't affect the rule groups evaluated by the ruler.
+		assert.Empty(t, r.manager.GetRules("user1"))
+	})
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// The v2 output of the rules and alerts API has the same content as the v1 output, which is compatible
+// with the Prometheus API, but its field names are snake_case. The field names of the v2 output are
+// stable: fields may be added but they are never renamed or removed.
+
+// AlertDiscoveryV2 has info for all active alerts, in the v2 output of the alerts API.
+type AlertDiscoveryV2 struct {
+	Alerts []*AlertV2 `json:"alerts"`
+}
+
+// AlertV2 has info for an alert, in the v2 output of the rules and alerts API.
+type AlertV2 struct {
+	Labels      labels.Labels `json:"labels"`
+	Annotations labels.Labels `json:"annotations"`
+	State       string        `json:"state"`
+	ActiveAt    *time.Time    `json:"active_at"`
+	Value       string        `json:"value"`
+}
+
+// RuleDiscoveryV2 has info for all rules, in the v2 output of the rules API.
+type RuleDiscoveryV2 struct {
+	RuleGroups []*RuleGroupV2 `json:"groups"`
+}
+
+// RuleGroupV2 has info for rules which are part of a group, in the v2 output of the rules API.
+type RuleGroupV2 struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	// In order to preserve rule ordering, while exposing type (alerting or recording)
+	// specific properties, both alerting and recording rules are exposed in the
+	// same array.
+	Rules          []rule    `json:"rules"`
+	Interval       float64   `json:"interval"`
+	LastEvaluation time.Time `json:"last_evaluation"`
+	EvaluationTime float64   `json:"evaluation_time"`
+	SourceTenants  []string  `json:"source_tenants"`
+	Limit          int64     `json:"limit"`
+
+	MissedIterations int64      `json:"missed_iterations"`
+	Tenant           string     `json:"tenant,omitempty"`
+	LastConfigUpdate *time.Time `json:"last_config_update,omitempty"`
+
+	Federation *RuleGroupFederationV2 `json:"federation,omitempty"`
+}
+
+// RuleGroupFederationV2 has the federation settings of a federated rule group, in the v2 output of the rules API.
+type RuleGroupFederationV2 struct {
+	Enabled             bool                             `json:"enabled"`
+	SourceTenants       []string                         `json:"source_tenants"`
+	DestTenants         []string                         `json:"dest_tenants"`
+	SourceTenantLabel   string                           `json:"source_tenant_label"`
+	SourceTenantsStatus []FederationSourceTenantStatusV2 `json:"source_tenants_status"`
+}
+
+// FederationSourceTenantStatusV2 has the restrictions of a source tenant of a federated rule group, in the v2
+// output of the rules API.
+type FederationSourceTenantStatusV2 struct {
+	Tenant         string `json:"tenant"`
+	Consent        string `json:"consent"`
+	AllowedMetrics string `json:"allowed_metrics,omitempty"`
+	BlockedMetrics string `json:"blocked_metrics,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+type alertingRuleV2 struct {
+	State          string        `json:"state"`
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Duration       float64       `json:"duration"`
+	Labels         labels.Labels `json:"labels"`
+	Annotations    labels.Labels `json:"annotations"`
+	Alerts         []*AlertV2    `json:"alerts"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"last_error"`
+	Type           v1.RuleType   `json:"type"`
+	LastEvaluation time.Time     `json:"last_evaluation"`
+	EvaluationTime float64       `json:"evaluation_time"`
+	Interval       float64       `json:"interval,omitempty"`
+	DroppedAlerts  int64         `json:"dropped_alerts,omitempty"`
+}
+
+type recordingRuleV2 struct {
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Labels         labels.Labels `json:"labels"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"last_error"`
+	Type           v1.RuleType   `json:"type"`
+	LastEvaluation time.Time     `json:"last_evaluation"`
+	EvaluationTime float64       `json:"evaluation_time"`
+	Interval       float64       `json:"interval,omitempty"`
+}
+
+func newAlertV2(a *Alert) *AlertV2 {
+	return &AlertV2{
+		Labels:      a.Labels,
+		Annotations: a.Annotations,
+		State:       a.State,
+		ActiveAt:    a.ActiveAt,
+		Value:       a.Value,
+	}
+}
+
+func newAlertDiscoveryV2(alerts []*Alert) *AlertDiscoveryV2 {
+	discovery := &AlertDiscoveryV2{Alerts: make([]*AlertV2, 0, len(alerts))}
+	for _, a := range alerts {
+		discovery.Alerts = append(discovery.Alerts, newAlertV2(a))
+	}
+	return discovery
+}
+
+// newRuleGroupV2 converts a rule group from the v1 to the v2 output of the rules API.
+func newRuleGroupV2(g *RuleGroup) *RuleGroupV2 {
+	grp := &RuleGroupV2{
+		Name:           g.Name,
+		File:           g.File,
+		Rules:          make([]rule, 0, len(g.Rules)),
+		Interval:       g.Interval,
+		LastEvaluation: g.LastEvaluation,
+		EvaluationTime: g.EvaluationTime,
+		SourceTenants:  g.SourceTenants,
+		Limit:          g.Limit,
+
+		MissedIterations: g.MissedIterations,
+		Tenant:           g.Tenant,
+		LastConfigUpdate: g.LastConfigUpdate,
+	}
+
+	if f := g.Federation; f != nil {
+		grp.Federation = &RuleGroupFederationV2{
+			Enabled:             f.Enabled,
+			SourceTenants:       f.SourceTenants,
+			DestTenants:         f.DestTenants,
+			SourceTenantLabel:   f.SourceTenantLabel,
+			SourceTenantsStatus: make([]FederationSourceTenantStatusV2, 0, len(f.SourceTenantsStatus)),
+		}
+		for _, st := range f.SourceTenantsStatus {
+			grp.Federation.SourceTenantsStatus = append(grp.Federation.SourceTenantsStatus, FederationSourceTenantStatusV2(st))
+		}
+	}
+
+	for _, r := range g.Rules {
+		switch r := r.(type) {
+		case alertingRule:
+			alerts := make([]*AlertV2, 0, len(r.Alerts))
+			for _, a := range r.Alerts {
+				alerts = append(alerts, newAlertV2(a))
+			}
+			grp.Rules = append(grp.Rules, alertingRuleV2{
+				State:          r.State,
+				Name:           r.Name,
+				Query:          r.Query,
+				Duration:       r.Duration,
+				Labels:         r.Labels,
+				Annotations:    r.Annotations,
+				Alerts:         alerts,
+				Health:         r.Health,
+				LastError:      r.LastError,
+				Type:           r.Type,
+				LastEvaluation: r.LastEvaluation,
+				EvaluationTime: r.EvaluationTime,
+				Interval:       r.Interval,
+				DroppedAlerts:  r.DroppedAlerts,
+			})
+		case recordingRule:
+			grp.Rules = append(grp.Rules, recordingRuleV2(r))
+		}
+	}
+	return grp
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// batchingPusher batches the write requests of the results of the rule evaluations of a tenant, so that the
+// results of the rule groups evaluated around the same time are written with a single request. Push returns as
+// soon as the series are added to the current batch, so that the rule evaluations don't wait for the batch to
+// be written: the errors of the write requests of the batches are logged and tracked by the failed writes
+// metric, instead of being returned to the rule evaluations, so the health and the last error of the rules
+// don't reflect them. The pending batch is written, and the in-flight writes are waited for, by drain.
+type batchingPusher struct {
+	pusher       Pusher
+	userID       string
+	limits       RulesLimits
+	batches      prometheus.Counter
+	failedWrites prometheus.Counter
+	logger       log.Logger
+
+	mtx     sync.Mutex
+	current *writeBatch
+	// Number of in-flight writes of the batches, and channel closed once there's none anymore.
+	writes     int
+	writesDone chan struct{}
+}
+
+type writeBatch struct {
+	req   *mimirpb.WriteRequest
+	timer *time.Timer
+}
+
+func newBatchingPusher(pusher Pusher, userID string, limits RulesLimits, batches, failedWrites prometheus.Counter, logger log.Logger) *batchingPusher {
+	return &batchingPusher{
+		pusher:       pusher,
+		userID:       userID,
+		limits:       limits,
+		batches:      batches,
+		failedWrites: failedWrites,
+		logger:       logger,
+	}
+}
+
+// Push adds the series of req to the current batch, which is written once it reaches the max number
+// of series of the tenant, or after the flush timeout of the tenant. The requests are written right away
+// when the tenant's write requests aren't batched, or when they're for another tenant, like the
+// destination tenants of the rule groups.
+func (p *batchingPusher) Push(ctx context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	if userID, err := user.ExtractOrgID(ctx); err == nil && userID != p.userID {
+		return p.pusher.Push(ctx, req)
+	}
+
+	maxSeries := p.limits.RulerWriteBatchSize(p.userID)
+	if maxSeries <= 0 {
+		return p.pusher.Push(ctx, req)
+	}
+
+	p.mtx.Lock()
+	b := p.current
+	if b == nil {
+		b = &writeBatch{req: &mimirpb.WriteRequest{Source: req.Source}}
+		b.timer = time.AfterFunc(p.limits.RulerWriteBatchFlushTimeout(p.userID), func() { p.flush(b) })
+		p.current = b
+	}
+	b.req.Timeseries = append(b.req.Timeseries, req.Timeseries...)
+
+	full := len(b.req.Timeseries) >= maxSeries
+	if full {
+		p.current = nil
+		p.startWrite()
+	}
+	p.mtx.Unlock()
+
+	if full {
+		b.timer.Stop()
+		go p.write(b)
+	}
+	return &mimirpb.WriteResponse{}, nil
+}
+
+// flush writes b if it's still the current batch.
+func (p *batchingPusher) flush(b *writeBatch) {
+	p.mtx.Lock()
+	if p.current != b {
+		p.mtx.Unlock()
+		return
+	}
+	p.current = nil
+	p.startWrite()
+	p.mtx.Unlock()
+
+	p.write(b)
+}
+
+// drain writes the current batch without waiting for the flush timeout, and waits for the in-flight writes
+// of the batches to complete. It returns false if ctx is done before they complete. It must be called once
+// the rule evaluations don't push anymore.
+func (p *batchingPusher) drain(ctx context.Context) bool {
+	p.mtx.Lock()
+	b := p.current
+	if b != nil {
+		p.current = nil
+		p.startWrite()
+	}
+	done := p.writesDone
+	p.mtx.Unlock()
+
+	if b != nil {
+		b.timer.Stop()
+		go p.write(b)
+	}
+	if done == nil {
+		return true
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// startWrite tracks a new in-flight write. It must be called with the lock held.
+func (p *batchingPusher) startWrite() {
+	if p.writes == 0 {
+		p.writesDone = make(chan struct{})
+	}
+	p.writes++
+}
+
+func (p *batchingPusher) endWrite() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.writes--
+	if p.writes == 0 {
+		close(p.writesDone)
+		p.writesDone = nil
+	}
+}
+
+func (p *batchingPusher) write(b *writeBatch) {
+	defer p.endWrite()
+
+	p.batches.Inc()
+	series := len(b.req.Timeseries)
+
+	// The batch is shared by several rule evaluations, so it isn't written with the context of any of them.
+	_, err := p.pusher.Push(user.InjectOrgID(context.Background(), p.userID), b.req)
+	if err == nil {
+		return
+	}
+
+	// Like the writes of each rule evaluation, the errors with a 4xx HTTP status code (series limits,
+	// duplicate samples, out of order, etc.) aren't failures.
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); !ok || resp.Code/100 != 4 {
+		p.failedWrites.Inc()
+	}
+	level.Warn(p.logger).Log("msg", "failed to write the batched results of the rule evaluations", "user", p.userID, "series", series, "err", err)
+}
+
+// batchWritingRulesManager is a RulesManager which, once stopped, writes the pending batch of the results of the
+// rule evaluations, and waits for the in-flight writes of the batches until ctx is done.
+type batchWritingRulesManager struct {
+	RulesManager
+
+	ctx    context.Context
+	pusher *batchingPusher
+}
+
+func (m *batchWritingRulesManager) Stop() {
+	m.RulesManager.Stop()
+
+	if !m.pusher.drain(m.ctx) {
+		level.Warn(m.pusher.logger).Log("msg", "batched results of the rule evaluations not written at the end of the shutdown grace period", "user", m.pusher.userID)
+	}
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// countingPusher is a Pusher keeping the number of series of each pushed request.
+type countingPusher struct {
+	mtx      sync.Mutex
+	requests []int
+	tenants  []string
+	err      error
+}
+
+func (p *countingPusher) Push(ctx context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	tenantID, _ := user.ExtractOrgID(ctx)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.requests = append(p.requests, len(req.Timeseries))
+	p.tenants = append(p.tenants, tenantID)
+	return &mimirpb.WriteResponse{}, p.err
+}
+
+func TestBatchingPusher(t *testing.T) {
+	newRequest := func(series int) *mimirpb.WriteRequest {
+		lbls := make([]labels.Labels, 0, series)
+		samples := make([]mimirpb.Sample, 0, series)
+		for i := 0; i < series; i++ {
+			lbls = append(lbls, labels.FromStrings(labels.MetricName, "up"))
+			samples = append(samples, mimirpb.Sample{TimestampMs: 1, Value: 1})
+		}
+		return mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.RULE)
+	}
+
+	for name, tc := range map[string]struct {
+		maxSeries        int
+		flushTimeout     time.Duration
+		pushes           []int
+		pushErr          error
+		expectedRequests []int
+		expectedBatches  int
+		expectedFailures int
+	}{
+		"requests batched until the flush timeout": {
+			maxSeries:        100,
+			flushTimeout:     200 * time.Millisecond,
+			pushes:           []int{2, 3, 5},
+			expectedRequests: []int{10},
+			expectedBatches:  1,
+		},
+		"requests batched until the max number of series": {
+			maxSeries:        5,
+			flushTimeout:     time.Hour,
+			pushes:           []int{2, 3},
+			expectedRequests: []int{5},
+			expectedBatches:  1,
+		},
+		"failed write of a batch tracked instead of returned": {
+			maxSeries:        100,
+			flushTimeout:     200 * time.Millisecond,
+			pushes:           []int{2, 3},
+			pushErr:          errors.New("write failed"),
+			expectedRequests: []int{5},
+			expectedBatches:  1,
+			expectedFailures: 1,
+		},
+		"requests not batched when the batch size is 0": {
+			maxSeries:        0,
+			flushTimeout:     time.Hour,
+			pushes:           []int{2, 3},
+			expectedRequests: []int{2, 3},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			upstream := &countingPusher{err: tc.pushErr}
+			batches := prometheus.NewCounter(prometheus.CounterOpts{})
+			failedWrites := prometheus.NewCounter(prometheus.CounterOpts{})
+			limits := ruleLimits{writeBatchSize: tc.maxSeries, writeBatchTimeout: tc.flushTimeout}
+			p := newBatchingPusher(upstream, "user-1", limits, batches, failedWrites, log.NewNopLogger())
+
+			for _, series := range tc.pushes {
+				_, err := p.Push(user.InjectOrgID(context.Background(), "user-1"), newRequest(series))
+				// The errors of the batched writes aren't returned to the rule evaluations.
+				if tc.maxSeries > 0 {
+					require.NoError(t, err)
+				}
+			}
+
+			require.Eventually(t, func() bool {
+				upstream.mtx.Lock()
+				defer upstream.mtx.Unlock()
+				return len(upstream.requests) == len(tc.expectedRequests)
+			}, time.Second, 10*time.Millisecond)
+			require.Eventually(t, func() bool {
+				return testutil.ToFloat64(failedWrites) == float64(tc.expectedFailures)
+			}, time.Second, 10*time.Millisecond)
+
+			upstream.mtx.Lock()
+			defer upstream.mtx.Unlock()
+			assert.Equal(t, tc.expectedRequests, upstream.requests)
+			for _, tenantID := range upstream.tenants {
+				assert.Equal(t, "user-1", tenantID)
+			}
+			assert.Equal(t, float64(tc.expectedBatches), testutil.ToFloat64(batches))
+		})
+	}
+}
+
+func TestBatchingPusher_DoesNotWaitForTheBatch(t *testing.T) {
+	upstream := &countingPusher{}
+	limits := ruleLimits{writeBatchSize: 100, writeBatchTimeout: time.Hour}
+	p := newBatchingPusher(upstream, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}), log.NewNopLogger())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := p.Push(user.InjectOrgID(context.Background(), "user-1"), mimirpb.ToWriteRequest([]labels.Labels{labels.FromStrings(labels.MetricName, "up")}, []mimirpb.Sample{{TimestampMs: 1, Value: 1}}, nil, nil, mimirpb.RULE))
+		assert.NoError(t, err)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "the push waited for the batch to be written")
+	}
+
+	upstream.mtx.Lock()
+	defer upstream.mtx.Unlock()
+	assert.Empty(t, upstream.requests)
+}
+
+// blockingPusher is a Pusher blocking until release is closed.
+type blockingPusher struct {
+	release chan struct{}
+}
+
+func (p *blockingPusher) Push(context.Context, *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	<-p.release
+	return &mimirpb.WriteResponse{}, nil
+}
+
+func TestBatchingPusher_Drain(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	req := mimirpb.ToWriteRequest([]labels.Labels{labels.FromStrings(labels.MetricName, "up")}, []mimirpb.Sample{{TimestampMs: 1, Value: 1}}, nil, nil, mimirpb.RULE)
+	limits := ruleLimits{writeBatchSize: 100, writeBatchTimeout: time.Hour}
+
+	t.Run("writes the pending batch", func(t *testing.T) {
+		upstream := &countingPusher{}
+		p := newBatchingPusher(upstream, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}), log.NewNopLogger())
+
+		_, err := p.Push(ctx, req)
+		require.NoError(t, err)
+		require.True(t, p.drain(context.Background()))
+
+		upstream.mtx.Lock()
+		defer upstream.mtx.Unlock()
+		assert.Equal(t, []int{1}, upstream.requests)
+	})
+
+	t.Run("nothing to write", func(t *testing.T) {
+		p := newBatchingPusher(&countingPusher{}, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}), log.NewNopLogger())
+
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.True(t, p.drain(canceled))
+	})
+
+	t.Run("stops waiting for the in-flight writes once the context is done", func(t *testing.T) {
+		upstream := &blockingPusher{release: make(chan struct{})}
+		t.Cleanup(func() { close(upstream.release) })
+		p := newBatchingPusher(upstream, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}), log.NewNopLogger())
+
+		_, err := p.Push(ctx, req)
+		require.NoError(t, err)
+
+		timeout, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.False(t, p.drain(timeout))
+	})
+}
+
+func TestBatchWritingRulesManager_Stop(t *testing.T) {
+	upstream := &countingPusher{}
+	limits := ruleLimits{writeBatchSize: 100, writeBatchTimeout: time.Hour}
+	p := newBatchingPusher(upstream, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}), log.NewNopLogger())
+
+	_, err := p.Push(user.InjectOrgID(context.Background(), "user-1"), mimirpb.ToWriteRequest([]labels.Labels{labels.FromStrings(labels.MetricName, "up")}, []mimirpb.Sample{{TimestampMs: 1, Value: 1}}, nil, nil, mimirpb.RULE))
+	require.NoError(t, err)
+
+	// The pending batch is written once the manager stops, without waiting for the flush timeout.
+	m := &batchWritingRulesManager{RulesManager: &mockRulesManager{done: make(chan struct{})}, ctx: context.Background(), pusher: p}
+	m.Stop()
+
+	upstream.mtx.Lock()
+	defer upstream.mtx.Unlock()
+	assert.Equal(t, []int{1}, upstream.requests)
+}
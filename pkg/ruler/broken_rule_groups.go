@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// invalidRuleGroups returns the loaded rule groups having invalid rules, which would fail the update of all
+// the rule groups of their tenant by the rules manager.
+func invalidRuleGroups(configs map[string]rulespb.RuleGroupList, broken map[string]bool) []rulestore.BrokenRuleGroup {
+	var invalid []rulestore.BrokenRuleGroup
+	for userID, groups := range configs {
+		for _, g := range groups {
+			if broken[brokenRuleGroupKey(userID, g.Namespace, g.Name)] {
+				continue
+			}
+			var msgs []string
+			for i, rule := range rulespb.ToRuleFile(g).Rules {
+				for _, err := range rule.Validate() {
+					msgs = append(msgs, fmt.Sprintf("rule %d: %s", i, err.Error()))
+				}
+			}
+			if len(msgs) > 0 {
+				invalid = append(invalid, rulestore.BrokenRuleGroup{User: userID, Namespace: g.Namespace, Name: g.Name, Err: strings.Join(msgs, ", ")})
+			}
+		}
+	}
+	return invalid
+}
+
+func brokenRuleGroupKey(userID, namespace, group string) string {
+	return userID + "/" + namespace + "/" + group
+}
+
+// withoutBrokenRuleGroups returns the rule groups of the user which aren't broken.
+func withoutBrokenRuleGroups(userID string, groups rulespb.RuleGroupList, broken map[string]bool) rulespb.RuleGroupList {
+	filtered := make(rulespb.RuleGroupList, 0, len(groups))
+	for _, g := range groups {
+		if !broken[brokenRuleGroupKey(userID, g.Namespace, g.Name)] {
+			filtered = append(filtered, g)
+		}
+	}
+	return filtered
+}
+
+func brokenRuleGroupKeys(broken []rulestore.BrokenRuleGroup) map[string]bool {
+	keys := make(map[string]bool, len(broken))
+	for _, g := range broken {
+		keys[brokenRuleGroupKey(g.User, g.Namespace, g.Name)] = true
+	}
+	return keys
+}
+
+// quarantineRuleGroups removes the broken rule groups from configs, so that the other rule groups of their
+// tenants are still evaluated, and exposes them in the metrics and the broken rule groups endpoint.
+func (r *Ruler) quarantineRuleGroups(configs map[string]rulespb.RuleGroupList, broken []rulestore.BrokenRuleGroup) {
+	broken = append(broken, invalidRuleGroups(configs, brokenRuleGroupKeys(broken))...)
+	keys := brokenRuleGroupKeys(broken)
+
+	byUser := map[string][]rulestore.BrokenRuleGroup{}
+	for _, g := range broken {
+		level.Warn(r.logger).Log("msg", "quarantining rule group which can't be loaded", "user", g.User, "namespace", g.Namespace, "group", g.Name, "err", g.Err)
+		byUser[g.User] = append(byUser[g.User], g)
+	}
+	for userID := range byUser {
+		configs[userID] = withoutBrokenRuleGroups(userID, configs[userID], keys)
+	}
+
+	r.brokenRuleGroupsMtx.Lock()
+	defer r.brokenRuleGroupsMtx.Unlock()
+
+	r.brokenRuleGroups = byUser
+	r.metrics.brokenRuleGroups.Reset()
+	for userID, groups := range byUser {
+		r.metrics.brokenRuleGroups.WithLabelValues(userID).Set(float64(len(groups)))
+	}
+}
+
+// BrokenRuleGroupsHandler lists the rule groups quarantined by this ruler at the last sync, because they
+// can't be decoded or don't pass the validation.
+func (r *Ruler) BrokenRuleGroupsHandler(w http.ResponseWriter, _ *http.Request) {
+	r.brokenRuleGroupsMtx.Lock()
+	groups := []rulestore.BrokenRuleGroup{}
+	for _, userGroups := range r.brokenRuleGroups {
+		groups = append(groups, userGroups...)
+	}
+	r.brokenRuleGroupsMtx.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		return brokenRuleGroupKey(groups[i].User, groups[i].Namespace, groups[i].Name) < brokenRuleGroupKey(groups[j].User, groups[j].Namespace, groups[j].Name)
+	})
+	util.WriteYAMLResponse(w, map[string][]rulestore.BrokenRuleGroup{"broken_groups": groups})
+}
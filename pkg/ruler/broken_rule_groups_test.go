@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestRuler_BrokenRuleGroups(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	store := bucketclient.NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	for _, rg := range []*rulespb.RuleGroupDesc{
+		{Name: "valid", Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+		{Name: "corrupted", Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+		{Name: "invalid", Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up"}}},
+	} {
+		rg.User, rg.Namespace, rg.Interval = "user1", "namespace", time.Minute
+		require.NoError(t, store.SetRuleGroup(ctx, "user1", "namespace", rg))
+	}
+	require.NoError(t, bkt.Upload(ctx, "rules/user1/bmFtZXNwYWNl/Y29ycnVwdGVk", bytes.NewReader([]byte("not a rule group"))))
+
+	r := newTestRuler(t, defaultRulerConfig(t), store)
+	defer services.StopAndAwaitTerminated(ctx, r) //nolint:errcheck
+
+	// The other rule groups of the tenant are still evaluated.
+	groups := r.manager.GetRules("user1")
+	require.Len(t, groups, 1)
+	assert.Equal(t, "valid", groups[0].Name())
+
+	assert.NoError(t, prom_testutil.GatherAndCompare(r.registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_ruler_broken_rule_groups Number of rule groups skipped at the last sync because they can't be decoded or don't pass the validation.
+		# TYPE cortex_ruler_broken_rule_groups gauge
+		cortex_ruler_broken_rule_groups{user="user1"} 2
+	`), "cortex_ruler_broken_rule_groups"))
+
+	rec := httptest.NewRecorder()
+	r.BrokenRuleGroupsHandler(rec, httptest.NewRequest(http.MethodGet, "/ruler/broken_groups", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "name: corrupted")
+	assert.Contains(t, body, "failed to unmarshal rule group")
+	assert.Contains(t, body, "name: invalid")
+	assert.NotContains(t, body, "name: valid")
+
+	// The rule groups are evaluated again once fixed.
+	require.NoError(t, store.DeleteRuleGroup(ctx, "user1", "namespace", "corrupted"))
+	require.NoError(t, store.SetRuleGroup(ctx, "user1", "namespace", &rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "invalid", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}}))
+	// The rule storage is polled for all the users by the syncs on ring changes.
+	r.syncRules(ctx, rulerSyncReasonRingChange)
+
+	assert.Len(t, r.manager.GetRules("user1"), 2)
+	assert.NoError(t, prom_testutil.GatherAndCompare(r.registry.(*prometheus.Registry), strings.NewReader(""), "cortex_ruler_broken_rule_groups"))
+}
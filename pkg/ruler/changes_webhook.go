@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	rulesChangeActionCreate          = "create"
+	rulesChangeActionDelete          = "delete"
+	rulesChangeActionDeleteNamespace = "delete_namespace"
+	rulesChangeActionRestore         = "restore"
+
+	// rulesChangeSignatureHeader is the header carrying the HMAC-SHA256 signature of the webhook payload.
+	rulesChangeSignatureHeader = "X-Mimir-Signature-256"
+)
+
+var (
+	errInvalidChangesWebhookTimeout       = errors.New("invalid ruler changes webhook timeout, must be greater than zero")
+	errInvalidChangesWebhookQueueCapacity = errors.New("invalid ruler changes webhook queue capacity, must be greater than zero")
+)
+
+type ChangesWebhookConfig struct {
+	URL           flagext.URLValue `yaml:"url"`
+	Secret        flagext.Secret   `yaml:"secret"`
+	Timeout       time.Duration    `yaml:"timeout"`
+	QueueCapacity int              `yaml:"queue_capacity"`
+}
+
+func (cfg *ChangesWebhookConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.URL, "ruler.changes-webhook.url", "URL of the webhook to POST to after each successful change of the rule groups through the configuration API. The webhook is disabled if empty.")
+	f.Var(&cfg.Secret, "ruler.changes-webhook.secret", "Secret used to sign the webhook payload with HMAC-SHA256. The signature is sent in the "+rulesChangeSignatureHeader+" header. The payload is not signed if empty.")
+	f.DurationVar(&cfg.Timeout, "ruler.changes-webhook.timeout", 10*time.Second, "Timeout for each webhook request.")
+	f.IntVar(&cfg.QueueCapacity, "ruler.changes-webhook.queue-capacity", 1000, "Capacity of the queue of the changes to send to the webhook. The changes are sent one at a time, in the order they're made, and the ones made while the queue is full are dropped. The queued changes are sent before the ruler stops.")
+}
+
+func (cfg *ChangesWebhookConfig) Validate() error {
+	if cfg.URL.URL == nil {
+		return nil
+	}
+	if cfg.Timeout <= 0 {
+		return errInvalidChangesWebhookTimeout
+	}
+	if cfg.QueueCapacity <= 0 {
+		return errInvalidChangesWebhookQueueCapacity
+	}
+	return nil
+}
+
+// rulesChangeEvent is the payload of the rules changes webhook.
+type rulesChangeEvent struct {
+	Tenant    string    `json:"tenant"`
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group,omitempty"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// rulesChangesWebhook notifies an external system of the changes of the rule groups made through
+// the configuration API. The changes are queued and sent one at a time, so that the receiver gets
+// them in the order they're made.
+type rulesChangesWebhook struct {
+	services.Service
+
+	cfg    ChangesWebhookConfig
+	client *http.Client
+	logger log.Logger
+	queue  chan rulesChangeEvent
+
+	sent    prometheus.Counter
+	failed  prometheus.Counter
+	dropped prometheus.Counter
+}
+
+func newRulesChangesWebhook(cfg ChangesWebhookConfig, logger log.Logger, reg prometheus.Registerer) *rulesChangesWebhook {
+	w := &rulesChangesWebhook{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		queue:  make(chan rulesChangeEvent, cfg.QueueCapacity),
+		sent: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_changes_webhook_sent_total",
+			Help: "Total number of rule changes notified to the changes webhook.",
+		}),
+		failed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_changes_webhook_failed_total",
+			Help: "Total number of rule changes which failed to be notified to the changes webhook.",
+		}),
+		dropped: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_changes_webhook_dropped_total",
+			Help: "Total number of rule changes not notified to the changes webhook because its queue was full.",
+		}),
+	}
+	w.Service = services.NewBasicService(nil, w.running, w.stopping)
+	return w
+}
+
+func (w *rulesChangesWebhook) running(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-w.queue:
+			w.deliver(event)
+		}
+	}
+}
+
+// stopping sends the queued changes.
+func (w *rulesChangesWebhook) stopping(_ error) error {
+	for {
+		select {
+		case event := <-w.queue:
+			w.deliver(event)
+		default:
+			return nil
+		}
+	}
+}
+
+// notify queues the change to send to the webhook, so that the configuration API doesn't wait
+// for it. The change is dropped if the queue is full.
+func (w *rulesChangesWebhook) notify(userID, namespace, group, action string) {
+	event := rulesChangeEvent{
+		Tenant:    userID,
+		Namespace: namespace,
+		Group:     group,
+		Action:    action,
+		Timestamp: time.Now().UTC(),
+	}
+
+	select {
+	case w.queue <- event:
+	default:
+		w.dropped.Inc()
+		level.Warn(w.logger).Log("msg", "dropped rule change because the changes webhook queue is full", "user", userID, "namespace", namespace, "group", group, "action", action)
+	}
+}
+
+// deliver sends the change to the webhook, tracking the outcome.
+func (w *rulesChangesWebhook) deliver(event rulesChangeEvent) {
+	// The request is bounded by the client timeout, and isn't canceled when the ruler stops.
+	if err := w.send(context.Background(), event); err != nil {
+		w.failed.Inc()
+		level.Warn(w.logger).Log("msg", "failed to notify rule change to the changes webhook", "user", event.Tenant, "namespace", event.Namespace, "group", event.Group, "action", event.Action, "err", err)
+		return
+	}
+	w.sent.Inc()
+}
+
+func (w *rulesChangesWebhook) send(ctx context.Context, event rulesChangeEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := w.cfg.Secret.String(); secret != "" {
+		req.Header.Set(rulesChangeSignatureHeader, signRulesChange(secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signRulesChange returns the HMAC-SHA256 signature of the payload, in the "sha256=<hex>" format.
+func signRulesChange(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
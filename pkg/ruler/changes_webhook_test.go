@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestRulesChangesWebhook(t *testing.T) {
+	type received struct {
+		body      []byte
+		signature string
+	}
+	receivedCh := make(chan received, 1)
+	statusCode := atomic.NewInt64(http.StatusOK)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		receivedCh <- received{body: body, signature: r.Header.Get(rulesChangeSignatureHeader)}
+		w.WriteHeader(int(statusCode.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cfg := ChangesWebhookConfig{URL: flagext.URLValue{URL: u}, Secret: flagext.SecretWithValue("secret"), Timeout: time.Second, QueueCapacity: 10}
+
+	reg := prometheus.NewPedanticRegistry()
+	w := newRulesChangesWebhook(cfg, log.NewNopLogger(), reg)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), w))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), w))
+	})
+
+	w.notify("user-1", "namespace", "group", rulesChangeActionCreate)
+
+	r := <-receivedCh
+	assert.Equal(t, signRulesChange("secret", r.body), r.signature)
+
+	var event rulesChangeEvent
+	require.NoError(t, json.Unmarshal(r.body, &event))
+	assert.Equal(t, "user-1", event.Tenant)
+	assert.Equal(t, "namespace", event.Namespace)
+	assert.Equal(t, "group", event.Group)
+	assert.Equal(t, rulesChangeActionCreate, event.Action)
+	assert.False(t, event.Timestamp.IsZero())
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(w.sent) == 1 }, time.Second, 10*time.Millisecond)
+
+	// A failed webhook request is tracked.
+	statusCode.Store(http.StatusInternalServerError)
+	w.notify("user-1", "namespace", "", rulesChangeActionDeleteNamespace)
+	<-receivedCh
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(w.failed) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRulesChangesWebhook_Queue(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		received []rulesChangeEvent
+		unblock  = make(chan struct{})
+		started  = make(chan struct{}, 10)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event rulesChangeEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		mtx.Lock()
+		received = append(received, event)
+		mtx.Unlock()
+		started <- struct{}{}
+		<-unblock
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cfg := ChangesWebhookConfig{URL: flagext.URLValue{URL: u}, Timeout: 5 * time.Second, QueueCapacity: 2}
+
+	w := newRulesChangesWebhook(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), w))
+
+	// The first change is being sent, the next two are queued and the last one is dropped.
+	w.notify("user-1", "namespace", "group", rulesChangeActionCreate)
+	<-started
+	w.notify("user-1", "namespace", "group", rulesChangeActionDelete)
+	w.notify("user-1", "namespace", "group", rulesChangeActionRestore)
+	w.notify("user-1", "namespace", "", rulesChangeActionDeleteNamespace)
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.dropped))
+
+	// The queued changes are sent in order when the webhook stops.
+	close(unblock)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), w))
+
+	var actions []string
+	mtx.Lock()
+	defer mtx.Unlock()
+	for _, event := range received {
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{rulesChangeActionCreate, rulesChangeActionDelete, rulesChangeActionRestore}, actions)
+	assert.Equal(t, float64(3), testutil.ToFloat64(w.sent))
+}
+
+func TestSignRulesChange(t *testing.T) {
+	// Expected signature computed with: echo -n '{"tenant":"user-1"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=e535473b8209ccd9cfc81a2f9d781c286cf27213f4178222f320124c59956d70", signRulesChange("secret", []byte(`{"tenant":"user-1"}`)))
+}
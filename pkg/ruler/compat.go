@@ -8,27 +8,32 @@ package ruler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gogo/status"
+	"github.com/grafana/dskit/multierror"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/querier"
 	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 // Pusher is an ingester server that accepts pushes.
@@ -45,9 +50,27 @@ type PusherAppender struct {
 	labels  []labels.Labels
 	samples []mimirpb.Sample
 	userID  string
+
+	// Used to validate the labels of the appended series. Nil to skip the validation.
+	limits RulesLimits
+
+	// Whether the ALERTS and ALERTS_FOR_STATE series should be written.
+	writeAlertsSeries bool
 }
 
 func (a *PusherAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if !a.writeAlertsSeries && isAlertsSeries(l) {
+		return 0, nil
+	}
+
+	// The series rejected by the distributor would fail the whole write request, so they're dropped
+	// here, and the error is reported by the rule evaluation as the one of the rule producing them.
+	if a.limits != nil {
+		if err := validateAppendedSeries(a.limits, a.userID, l); err != nil {
+			return 0, err
+		}
+	}
+
 	a.labels = append(a.labels, l)
 	a.samples = append(a.samples, mimirpb.Sample{
 		TimestampMs: t,
@@ -61,11 +84,62 @@ func (a *PusherAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _
 }
 
 func (a *PusherAppender) Commit() error {
+	var err error
+	if destTenants := evaluatedRuleGroupDesc(a.ctx).GetDestTenants(); len(destTenants) > 0 {
+		err = a.commitToDestTenants(destTenants)
+	} else {
+		err = a.push(a.userID, a.labels, a.samples)
+	}
+
+	a.labels = nil
+	a.samples = nil
+	return err
+}
+
+// commitToDestTenants writes the series of the recording rules to each destination tenant allowed by the limits of
+// the tenant owning the rule group, and the other series to the tenant owning the rule group. A write failing for a
+// tenant doesn't prevent the writes to the others.
+func (a *PusherAppender) commitToDestTenants(destTenants []string) error {
+	var (
+		ownedLabels, destLabels   []labels.Labels
+		ownedSamples, destSamples []mimirpb.Sample
+	)
+	for i, l := range a.labels {
+		if isRuleGroupOwnedSeries(l) {
+			ownedLabels = append(ownedLabels, l)
+			ownedSamples = append(ownedSamples, a.samples[i])
+		} else {
+			destLabels = append(destLabels, l)
+			destSamples = append(destSamples, a.samples[i])
+		}
+	}
+
+	errs := multierror.New()
+	if len(ownedLabels) > 0 {
+		errs.Add(a.push(a.userID, ownedLabels, ownedSamples))
+	}
+	if len(destLabels) > 0 {
+		for _, destTenant := range destTenants {
+			// The allowed destination tenants may have changed since the rule group was created.
+			if err := authorizeDestTenants(a.limits, a.userID, []string{destTenant}); err != nil {
+				errs.Add(err)
+				continue
+			}
+			if err := a.push(destTenant, destLabels, destSamples); err != nil {
+				errs.Add(fmt.Errorf("failed to write to the destination tenant %s: %w", destTenant, err))
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// push writes the series to the tenant.
+func (a *PusherAppender) push(userID string, lbls []labels.Labels, samples []mimirpb.Sample) error {
 	a.totalWrites.Inc()
 
 	// Since a.pusher is distributor, client.ReuseSlice will be called in a.pusher.Push.
 	// We shouldn't call client.ReuseSlice here.
-	_, err := a.pusher.Push(user.InjectOrgID(a.ctx, a.userID), mimirpb.ToWriteRequest(a.labels, a.samples, nil, nil, mimirpb.RULE))
+	_, err := a.pusher.Push(user.InjectOrgID(a.ctx, userID), mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.RULE))
 
 	if err != nil {
 		// Don't report errors that ended with 4xx HTTP status code (series limits, duplicate samples, out of order, etc.)
@@ -73,9 +147,6 @@ func (a *PusherAppender) Commit() error {
 			a.failedWrites.Inc()
 		}
 	}
-
-	a.labels = nil
-	a.samples = nil
 	return err
 }
 
@@ -85,10 +156,43 @@ func (a *PusherAppender) Rollback() error {
 	return nil
 }
 
+// validateAppendedSeries returns an error if the labels of a series written by the rule evaluations are rejected
+// by the distributor, or if their values are not valid UTF-8.
+func validateAppendedSeries(limits RulesLimits, userID string, l labels.Labels) error {
+	if err := validation.ValidateLabels(limits, userID, mimirpb.FromLabelsToLabelAdapters(l), false); err != nil {
+		return err
+	}
+	for _, lbl := range l {
+		if !utf8.ValidString(lbl.Value) {
+			return fmt.Errorf("the value of the label %s of the series %s is not valid UTF-8", lbl.Name, l.Get(labels.MetricName))
+		}
+	}
+	return nil
+}
+
+// isRuleGroupOwnedSeries returns whether l are the labels of a series about the evaluated rule group itself, like
+// the series of its alerts and its evaluation metrics, which are always written to the tenant owning the group.
+func isRuleGroupOwnedSeries(l labels.Labels) bool {
+	switch l.Get(labels.MetricName) {
+	case alertMetricName, alertForStateMetricName, ruleGroupLastDurationMetricName, ruleGroupLastEvaluationMetricName,
+		ruleGroupIntervalMetricName, ruleGroupRulesMetricName, ruleGroupRulesFailedMetricName:
+		return true
+	default:
+		return false
+	}
+}
+
+// isAlertsSeries returns whether l are the labels of a series written by the evaluation of alerting rules.
+func isAlertsSeries(l labels.Labels) bool {
+	name := l.Get(labels.MetricName)
+	return name == alertMetricName || name == alertForStateMetricName
+}
+
 // PusherAppendable fulfills the storage.Appendable interface for prometheus manager
 type PusherAppendable struct {
 	pusher Pusher
 	userID string
+	limits RulesLimits
 
 	totalWrites  prometheus.Counter
 	failedWrites prometheus.Counter
@@ -98,6 +202,7 @@ func NewPusherAppendable(pusher Pusher, userID string, limits RulesLimits, total
 	return &PusherAppendable{
 		pusher:       pusher,
 		userID:       userID,
+		limits:       limits,
 		totalWrites:  totalWrites,
 		failedWrites: failedWrites,
 	}
@@ -112,6 +217,9 @@ func (t *PusherAppendable) Appender(ctx context.Context) storage.Appender {
 		ctx:    ctx,
 		pusher: t.pusher,
 		userID: t.userID,
+		limits: t.limits,
+
+		writeAlertsSeries: t.limits == nil || t.limits.RulerAlertsSeriesEnabled(t.userID),
 	}
 }
 
@@ -121,48 +229,105 @@ type RulesLimits interface {
 	RulerTenantShardSize(userID string) int
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
+	RulerMaxRecordingRuleLabels(userID string) int
+	RulerMaxAlertsPerRule(userID string) int
+	MaxLabelNamesPerSeries(userID string) int
+	MaxLabelNameLength(userID string) int
+	MaxLabelValueLength(userID string) int
+	RulerOnDemandEvaluationsPerMinute(userID string) int
+	RulerMaxConcurrentQueries(userID string) int
+	RulerWriteBatchSize(userID string) int
+	RulerWriteBatchFlushTimeout(userID string) time.Duration
+	RulerAlertsSeriesEnabled(userID string) bool
+	RulerEvaluationMetricsEnabled(userID string) bool
+	RulerConfigAPIWriteRateLimit(userID string) float64
+	RulerConfigAPIWriteRateLimitBurst(userID string) int
+	RulerEvaluationPool(userID string) string
+	RulerPollInterval(userID string) time.Duration
+	RulerQueryBackendURL(userID string) string
+	RulerQueryBackendBasicAuthUsername(userID string) string
+	RulerQueryBackendBasicAuthPassword(userID string) string
+	RulerQueryBackendBearerToken(userID string) string
+	RulerFederationAllowedMetrics(userID string) string
+	RulerFederationBlockedMetrics(userID string) string
+	RulerFederationDestTenants(userID string) []string
+	RulerAlertGeneratorURL(userID string) string
+	RulerNotificationQueueCapacity(userID string) int
+	RulerNotificationTimeout(userID string) time.Duration
+	RulerNotificationMaxRetries(userID string) int
+	RulerNotificationRateLimit(userID string) float64
+	RulerNotificationRateLimitBurst(userID string) int
+	RulerNotificationDeduplicationWindow(userID string) time.Duration
+	RulerAlertmanagerClientTLSCertPath(userID string) string
+	RulerAlertmanagerClientTLSKeyPath(userID string) string
+	RulerAlertmanagerClientTLSCAPath(userID string) string
+	RulerAlertmanagerClientTLSServerName(userID string) string
+	RulerAlertmanagerClientSigV4Region(userID string) string
+	RulerAlertmanagerClientSigV4AccessKey(userID string) string
+	RulerAlertmanagerClientSigV4SecretKey(userID string) string
+	RulerAlertmanagerClientSigV4Profile(userID string) string
+	RulerAlertmanagerClientSigV4RoleARN(userID string) string
+	RulerAlertmanagerClientOAuth2ClientID(userID string) string
+	RulerAlertmanagerClientOAuth2ClientSecret(userID string) string
+	RulerAlertmanagerClientOAuth2TokenURL(userID string) string
+	RulerAlertmanagerClientOAuth2Scopes(userID string) string
 }
 
 func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Counter) rules.QueryFunc {
 	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
 		queries.Inc()
 		result, err := qf(ctx, qs, t)
+		if isFailedQuery(err) {
+			failedQueries.Inc()
+		}
 
-		// We only care about errors returned by underlying Queryable. Errors returned by PromQL engine are "user-errors",
-		// and not interesting here.
+		// Return unwrapped error.
 		qerr := QueryableError{}
 		if err != nil && errors.As(err, &qerr) {
-			origErr := qerr.Unwrap()
-
-			// Not all errors returned by Queryable are interesting, only those that would result in 500 status code.
-			//
-			// We rely on TranslateToPromqlApiError to do its job here... it returns nil, if err is nil.
-			// It returns promql.ErrStorage, if error should be reported back as 500.
-			// Other errors it returns are either for canceled or timed-out queriers (we're not reporting those as failures),
-			// or various user-errors (limits, duplicate samples, etc. ... also not failures).
-			//
-			// All errors will still be counted towards "evaluation failures" metrics and logged by Prometheus Ruler,
-			// but we only want internal errors here.
-			if _, ok := querier.TranslateToPromqlAPIError(origErr).(promql.ErrStorage); ok {
-				failedQueries.Inc()
-			}
-
-			// Return unwrapped error.
-			return result, origErr
-
-		} else if err != nil {
-			// When remote querier enabled, only consider failed queries those returning a 500 status code.
-			st, ok := status.FromError(err)
-			if ok && st.Code() == http.StatusInternalServerError {
-				failedQueries.Inc()
-			}
+			return result, qerr.Unwrap()
 		}
 		return result, err
 	}
 }
 
-func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Counter, logger log.Logger) rules.QueryFunc {
-	if queryTime == nil {
+// isFailedQuery returns whether err, returned by a query run by a rule evaluation, is an internal failure of the read path.
+func isFailedQuery(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// We only care about errors returned by underlying Queryable. Errors returned by PromQL engine are "user-errors",
+	// and not interesting here.
+	qerr := QueryableError{}
+	if errors.As(err, &qerr) {
+		// Not all errors returned by Queryable are interesting, only those that would result in 500 status code.
+		//
+		// We rely on TranslateToPromqlApiError to do its job here... it returns nil, if err is nil.
+		// It returns promql.ErrStorage, if error should be reported back as 500.
+		// Other errors it returns are either for canceled or timed-out queriers (we're not reporting those as failures),
+		// or various user-errors (limits, duplicate samples, etc. ... also not failures).
+		//
+		// All errors will still be counted towards "evaluation failures" metrics and logged by Prometheus Ruler,
+		// but we only want internal errors here.
+		_, ok := querier.TranslateToPromqlAPIError(qerr.Unwrap()).(promql.ErrStorage)
+		return ok
+	}
+
+	// When remote querier enabled, only consider failed queries those returning a 500 status code.
+	st, ok := status.FromError(err)
+	return ok && st.Code() == http.StatusInternalServerError
+}
+
+// queryStatsMetrics are the per-tenant metrics tracking the read path load of rule evaluations.
+type queryStatsMetrics struct {
+	querySeconds      prometheus.Counter
+	fetchedSeries     prometheus.Counter
+	fetchedChunks     prometheus.Counter
+	fetchedChunkBytes prometheus.Counter
+}
+
+func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, metrics *queryStatsMetrics, logger log.Logger) rules.QueryFunc {
+	if metrics == nil {
 		return qf
 	}
 
@@ -174,8 +339,11 @@ func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Co
 		// If we've been passed a counter we want to record the wall time spent executing this request.
 		timer := prometheus.NewTimer(nil)
 		defer func() {
-			// Update stats wall time based on the timer created above.
-			stats.AddWallTime(timer.ObserveDuration())
+			// Update stats wall time based on the timer created above, unless the wall time has been
+			// reported by the queriers (remote evaluation).
+			if elapsed := timer.ObserveDuration(); stats.LoadWallTime() == 0 {
+				stats.AddWallTime(elapsed)
+			}
 
 			wallTime := stats.LoadWallTime()
 			numSeries := stats.LoadFetchedSeries()
@@ -183,7 +351,10 @@ func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Co
 			numChunks := stats.LoadFetchedChunks()
 			shardedQueries := stats.LoadShardedQueries()
 
-			queryTime.Add(wallTime.Seconds())
+			metrics.querySeconds.Add(wallTime.Seconds())
+			metrics.fetchedSeries.Add(float64(numSeries))
+			metrics.fetchedChunks.Add(float64(numChunks))
+			metrics.fetchedChunkBytes.Add(float64(numBytes))
 
 			// Log ruler query stats.
 			logMessage := []interface{}{
@@ -194,8 +365,11 @@ func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Co
 				"fetched_chunk_bytes", numBytes,
 				"fetched_chunks_count", numChunks,
 				"sharded_queries", shardedQueries,
-				"query", qs,
 			}
+			if g, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo); ok {
+				logMessage = append(logMessage, "rule_group_namespace", g.namespace, "rule_group", g.name)
+			}
+			logMessage = append(logMessage, "query", qs)
 			level.Info(util_log.WithContext(ctx, logger)).Log(logMessage...)
 		}()
 
@@ -219,8 +393,8 @@ type RulesManager interface {
 	RuleGroups() []*rules.Group
 }
 
-// ManagerFactory is a function that creates new RulesManager for given user and notifier.Manager.
-type ManagerFactory func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager
+// ManagerFactory is a function that creates new RulesManager for given user and notifier.
+type ManagerFactory func(ctx context.Context, userID string, notifier sender, logger log.Logger, reg prometheus.Registerer) RulesManager
 
 func DefaultTenantManagerFactory(
 	cfg Config,
@@ -238,6 +412,12 @@ func DefaultTenantManagerFactory(
 		Name: "cortex_ruler_write_requests_failed_total",
 		Help: "Number of failed write requests to ingesters.",
 	})
+	writeBatches := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_write_batches_total",
+		Help: "Number of write requests to ingesters sent for the batched results of the rule evaluations of a tenant.",
+	}, []string{"user"})
+
+	queryBackends := newQueryBackends(cfg.Query.BackendTimeout, cfg.Query.BackendMaxResponseSize, util_log.Logger)
 
 	totalQueries := promauto.With(reg).NewCounter(prometheus.CounterOpts{
 		Name: "cortex_ruler_queries_total",
@@ -247,42 +427,213 @@ func DefaultTenantManagerFactory(
 		Name: "cortex_ruler_queries_failed_total",
 		Help: "Number of failed queries by ruler.",
 	})
-	var rulerQuerySeconds *prometheus.CounterVec
+	rateLimitedQueries := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_queries_rate_limited_total",
+		Help: "Number of queries run by rule evaluations rejected because the tenant exceeded the per-tenant rule queries rate limit.",
+	}, []string{"user"})
+	shortCircuitedQueries := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_queries_short_circuited_total",
+		Help: "Number of queries run by rule evaluations failed immediately because the circuit breaker of the tenant was open after consecutive failures of the read path.",
+	}, []string{"user"})
+	queryWaitSeconds := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_query_wait_seconds_total",
+		Help: "Total time spent by the queries run by rule evaluations waiting for a running query of the tenant to complete, because the tenant reached the per-tenant limit of concurrent queries.",
+	}, []string{"user"})
+	mutedNotifications := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_notifications_muted_total",
+		Help: "Number of alert notifications not sent because their rule group was outside of its active time intervals.",
+	}, []string{"user"})
+	warmUpNotifications := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_notifications_warm_up_suppressed_total",
+		Help: "Number of alert notifications not sent because they happened during the resend grace period after the ruler started evaluating the rules of the tenant.",
+	}, []string{"user"})
+	concurrentQueries := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_ruler_independent_rule_queries_concurrent_total",
+		Help: "Number of queries of independent rules run concurrently with the other rules of their rule group.",
+	})
+	queryCacheHits := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_ruler_query_results_cache_hits_total",
+		Help: "Number of queries run by rule evaluations whose result was returned by the query results cache.",
+	})
+	queryCacheMisses := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_ruler_query_results_cache_misses_total",
+		Help: "Number of queries run by rule evaluations whose result was not in the query results cache.",
+	})
+	followerDiscardedSamples := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_follower_samples_discarded_total",
+		Help: "Number of samples written by rule evaluations discarded because their rule group is replicated and the leader of its replicas is another ruler.",
+	}, []string{"user"})
+	followerSkippedNotifications := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_follower_notifications_skipped_total",
+		Help: "Number of alert notifications not sent because their rule group is replicated and the leader of its replicas is another ruler.",
+	}, []string{"user"})
+	backfilledIterations := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_group_iterations_backfilled_total",
+		Help: "Number of missed rule group iterations whose recording rules were evaluated and written before the next evaluation of the group.",
+	}, []string{"user"})
+	droppedAlerts := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_alerts_dropped_total",
+		Help: "Number of alerts of alerting rules dropped because the rule exceeded the maximum number of alerts per rule of the tenant.",
+	}, []string{"user"})
+	var independentRuleSlots *semaphore.Weighted
+	if cfg.MaxIndependentRuleConcurrency > 0 {
+		independentRuleSlots = semaphore.NewWeighted(int64(cfg.MaxIndependentRuleConcurrency))
+	}
+	var rulerQuerySeconds, rulerFetchedSeries, rulerFetchedChunks, rulerFetchedChunkBytes *prometheus.CounterVec
 	if cfg.EnableQueryStats {
 		rulerQuerySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_ruler_query_seconds_total",
 			Help: "Total amount of wall clock time spent processing queries by the ruler.",
 		}, []string{"user"})
+		rulerFetchedSeries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_series_total",
+			Help: "Number of series fetched to execute queries by the ruler.",
+		}, []string{"user"})
+		rulerFetchedChunks = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_chunks_total",
+			Help: "Number of chunks fetched to execute queries by the ruler.",
+		}, []string{"user"})
+		rulerFetchedChunkBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_chunks_bytes_total",
+			Help: "Size of all chunks fetched to execute queries by the ruler, in bytes.",
+		}, []string{"user"})
 	}
-	return func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager {
-		var queryTime prometheus.Counter = nil
+	return func(ctx context.Context, userID string, notifier sender, logger log.Logger, reg prometheus.Registerer) RulesManager {
+		var queryStats *queryStatsMetrics
 		if rulerQuerySeconds != nil {
-			queryTime = rulerQuerySeconds.WithLabelValues(userID)
+			queryStats = &queryStatsMetrics{
+				querySeconds:      rulerQuerySeconds.WithLabelValues(userID),
+				fetchedSeries:     rulerFetchedSeries.WithLabelValues(userID),
+				fetchedChunks:     rulerFetchedChunks.WithLabelValues(userID),
+				fetchedChunkBytes: rulerFetchedChunkBytes.WithLabelValues(userID),
+			}
 		}
 		var wrappedQueryFunc rules.QueryFunc
 
-		wrappedQueryFunc = MetricsQueryFunc(queryFunc, totalQueries, failedQueries)
-		wrappedQueryFunc = RecordAndReportRuleQueryMetrics(wrappedQueryFunc, queryTime, logger)
-
-		return rules.NewManager(&rules.ManagerOptions{
-			Appendable:                 NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
-			Queryable:                  embeddedQueryable,
-			QueryFunc:                  wrappedQueryFunc,
-			Context:                    user.InjectOrgID(ctx, userID),
-			GroupEvaluationContextFunc: FederatedGroupContextFunc,
-			ExternalURL:                cfg.ExternalURL.URL,
-			NotifyFunc:                 SendAlerts(notifier, cfg.ExternalURL.URL.String()),
-			Logger:                     log.With(logger, "user", userID),
-			Registerer:                 reg,
-			OutageTolerance:            cfg.OutageTolerance,
-			ForGracePeriod:             cfg.ForGracePeriod,
-			ResendDelay:                cfg.ResendDelay,
+		// The queries of the tenants evaluated by an external query API are tracked and limited like the others.
+		wrappedQueryFunc = QueryBackendQueryFunc(queryFunc, userID, overrides, queryBackends)
+
+		// The circuit breaker wraps the query function first, because it needs the errors of the Queryable.
+		wrappedQueryFunc = CircuitBreakerQueryFunc(wrappedQueryFunc, newQueryCircuitBreaker(cfg.Query.CircuitBreakerFailureThreshold, cfg.Query.CircuitBreakerCooldown), shortCircuitedQueries.WithLabelValues(userID))
+		wrappedQueryFunc = MetricsQueryFunc(wrappedQueryFunc, totalQueries, failedQueries)
+		wrappedQueryFunc = RecordAndReportRuleQueryMetrics(wrappedQueryFunc, queryStats, logger)
+		wrappedQueryFunc = SlowQueryLogFunc(wrappedQueryFunc, userID, cfg.Query.LogSlowerThan, logger)
+		wrappedQueryFunc = ConcurrencyLimitedQueryFunc(wrappedQueryFunc, newTenantQuerySlots(func() int {
+			return overrides.RulerMaxConcurrentQueries(userID)
+		}), queryWaitSeconds.WithLabelValues(userID))
+
+		var queryLimiter *rate.Limiter
+		if cfg.Query.TenantQPS > 0 {
+			queryLimiter = rate.NewLimiter(rate.Limit(cfg.Query.TenantQPS), cfg.Query.tenantBurst())
+		}
+		wrappedQueryFunc = RateLimitedQueryFunc(wrappedQueryFunc, queryLimiter, rateLimitedQueries.WithLabelValues(userID))
+		wrappedQueryFunc = TracingQueryFunc(wrappedQueryFunc)
+
+		// The write requests are batched when the batch size limit of the tenant is greater than 0.
+		pusher := newBatchingPusher(p, userID, overrides, writeBatches.WithLabelValues(userID), failedWrites, log.With(logger, "user", userID))
+		// Only the leader of the replicas of a rule group writes its results.
+		appendable := ReplicatedAppendable(NewPusherAppendable(pusher, userID, overrides, totalWrites, failedWrites), followerDiscardedSamples.WithLabelValues(userID))
+
+		// The missed iterations are backfilled bypassing the query results cache, because
+		// their timestamps are never queried again.
+		var backfiller *groupBackfiller
+		if cfg.MissedIterationsPolicy == missedIterationsPolicyBackfill {
+			backfiller = &groupBackfiller{
+				queryFunc:     wrappedQueryFunc,
+				appendable:    appendable,
+				externalURL:   cfg.ExternalURL.URL,
+				maxIterations: cfg.MaxBackfilledIterations,
+				backfilled:    backfilledIterations.WithLabelValues(userID),
+				logger:        log.With(logger, "user", userID),
+			}
+		}
+
+		var queryCache *queryResultsCache
+		if cfg.Query.CacheTTL > 0 {
+			queryCache = newQueryResultsCache(cfg.Query.CacheTTL, queryCacheHits, queryCacheMisses)
+		}
+		wrappedQueryFunc = CachedQueryFunc(wrappedQueryFunc, queryCache)
+		wrappedQueryFunc = GroupDependenciesQueryFunc(wrappedQueryFunc)
+		wrappedQueryFunc = ConcurrentQueryFunc(wrappedQueryFunc)
+		// The rule intervals are applied to the queries run by the rules, with their own context,
+		// rather than to the queries of the independent rules run concurrently.
+		wrappedQueryFunc = RuleIntervalsQueryFunc(wrappedQueryFunc)
+		wrappedQueryFunc = MissedIterationsQueryFunc(wrappedQueryFunc)
+		wrappedQueryFunc = EvaluationMetricsQueryFunc(wrappedQueryFunc)
+		wrappedQueryFunc = AlertsLimitQueryFunc(wrappedQueryFunc, droppedAlerts.WithLabelValues(userID), log.With(logger, "user", userID))
+		wrappedQueryFunc = AlertTemplatesQueryFunc(wrappedQueryFunc)
+		wrappedQueryFunc = EvaluationsStopQueryFunc(wrappedQueryFunc)
+
+		var alertLabels func(ctx context.Context, lbls labels.Labels) labels.Labels
+		if cfg.AlertDeduplicationKeyLabel != "" {
+			alertLabels = AlertDeduplicationKeyFunc(cfg.AlertDeduplicationKeyLabel, userID)
+		}
+		notifyFunc := ActiveTimeIntervalsNotifyFunc(SendAlerts(notifier, TenantAlertGeneratorURLFunc(cfg.ExternalURL.URL.String(), userID, overrides, logger), alertLabels), mutedNotifications.WithLabelValues(userID))
+		notifyFunc = WarmUpNotifyFunc(notifyFunc, time.Now().Add(cfg.ResendGracePeriod), warmUpNotifications.WithLabelValues(userID))
+		notifyFunc = ReplicatedNotifyFunc(notifyFunc, followerSkippedNotifications.WithLabelValues(userID))
+
+		manager := newStatePreservingRulesManager(rules.NewManager(&rules.ManagerOptions{
+			Appendable: RuleIntervalsAppendable(appendable),
+			Queryable:  embeddedQueryable,
+			QueryFunc:  wrappedQueryFunc,
+			Context:    user.InjectOrgID(ctx, userID),
+			GroupEvaluationContextFunc: ChainGroupEvaluationContextFuncs(
+				FederatedGroupContextFunc,
+				RuleGroupContextFunc,
+				AlertTemplatesContextFunc,
+				EvaluationsStopContextFunc,
+				GroupDependenciesContextFunc,
+				RuleIntervalsContextFunc,
+				IndependentRulesContextFunc(independentRuleSlots, concurrentQueries),
+				MissedIterationsContextFunc(backfiller),
+				EvaluationMetricsContextFunc(appendable, func() bool {
+					return overrides.RulerEvaluationMetricsEnabled(userID)
+				}, log.With(logger, "user", userID)),
+				AlertsLimitContextFunc(func() int {
+					return overrides.RulerMaxAlertsPerRule(userID)
+				}),
+			),
+			ExternalURL:     cfg.ExternalURL.URL,
+			NotifyFunc:      notifyFunc,
+			Logger:          log.With(logger, "user", userID),
+			Registerer:      reg,
+			OutageTolerance: cfg.OutageTolerance,
+			ForGracePeriod:  cfg.ForGracePeriod,
+			ResendDelay:     cfg.ResendDelay,
 			DefaultEvaluationDelay: func() time.Duration {
 				// Delay the evaluation of all rules by a set interval to give a buffer
 				// to metric that haven't been forwarded to Mimir yet.
 				return overrides.EvaluationDelay(userID)
 			},
-		})
+		}))
+
+		// The pending batch of the results is written once the manager stops, and waited for until the
+		// context of the evaluations is canceled at the end of the shutdown grace period.
+		return &batchWritingRulesManager{RulesManager: manager, ctx: ctx, pusher: pusher}
+	}
+}
+
+// ActiveTimeIntervalsNotifyFunc returns a rules.NotifyFunc which doesn't send the alerts of the rule
+// groups outside of their active time intervals.
+func ActiveTimeIntervalsNotifyFunc(notify rules.NotifyFunc, muted prometheus.Counter) rules.NotifyFunc {
+	return func(ctx context.Context, expr string, alerts ...*rules.Alert) {
+		if g := evaluatedRuleGroupDesc(ctx); g != nil && !g.ActiveAt(time.Now()) {
+			muted.Add(float64(len(alerts)))
+			return
+		}
+		notify(ctx, expr, alerts...)
+	}
+}
+
+// WarmUpNotifyFunc returns a rules.NotifyFunc which doesn't send the alerts before until. The alerts are
+// still evaluated meanwhile, so that the notifications sent afterwards reflect their accumulated state.
+func WarmUpNotifyFunc(notify rules.NotifyFunc, until time.Time, suppressed prometheus.Counter) rules.NotifyFunc {
+	return func(ctx context.Context, expr string, alerts ...*rules.Alert) {
+		if time.Now().Before(until) {
+			suppressed.Add(float64(len(alerts)))
+			return
+		}
+		notify(ctx, expr, alerts...)
 	}
 }
 
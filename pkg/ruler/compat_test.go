@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/notifier"
@@ -26,8 +27,10 @@ import (
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
 
@@ -96,6 +99,113 @@ func TestPusherAppendable(t *testing.T) {
 	}
 }
 
+func TestPusherAppendable_AlertsSeriesDisabled(t *testing.T) {
+	pusher := &fakePusher{response: &mimirpb.WriteResponse{}}
+	pa := NewPusherAppendable(pusher, "user-1", ruleLimits{disableAlertsSeries: true}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+
+	a := pa.Appender(context.Background())
+	for _, series := range []string{`foo_bar`, `ALERTS{alertname="boop", alertstate="firing"}`, `ALERTS_FOR_STATE{alertname="boop"}`} {
+		lbls, err := parser.ParseMetric(series)
+		require.NoError(t, err)
+
+		_, err = a.Append(0, lbls, 120_000, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, a.Commit())
+
+	require.Len(t, pusher.request.Timeseries, 1)
+	require.Equal(t, "foo_bar", mimirpb.FromLabelAdaptersToLabels(pusher.request.Timeseries[0].Labels).Get(labels.MetricName))
+}
+
+// tenantsPusher records the last write request of each tenant, failing the writes of the tenants in errs.
+type tenantsPusher struct {
+	requests map[string]*mimirpb.WriteRequest
+	errs     map[string]error
+}
+
+func (p *tenantsPusher) Push(ctx context.Context, r *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.requests[userID] = r
+	if err := p.errs[userID]; err != nil {
+		return nil, err
+	}
+	return &mimirpb.WriteResponse{}, nil
+}
+
+func TestPusherAppendable_DestTenants(t *testing.T) {
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{{User: "user-1", Namespace: "namespace", Name: "group", DestTenants: []string{"team-a", "global", "team-b"}}})
+	ctx := context.WithValue(context.Background(), tenantRuleGroups, registry)
+	ctx = context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace", name: "group"})
+
+	failedWrites := prometheus.NewCounter(prometheus.CounterOpts{})
+	pusher := &tenantsPusher{requests: map[string]*mimirpb.WriteRequest{}, errs: map[string]error{"team-a": errors.New("unavailable")}}
+	limits := ruleLimits{destTenants: map[string][]string{"user-1": {"team-a", "global"}}}
+	pa := NewPusherAppendable(pusher, "user-1", limits, prometheus.NewCounter(prometheus.CounterOpts{}), failedWrites)
+
+	a := pa.Appender(ctx)
+	for _, series := range []string{`job:up:sum`, `ALERTS{alertname="boop", alertstate="firing"}`, `mimir_rule_group_rules{rule_group="group"}`} {
+		lbls, err := parser.ParseMetric(series)
+		require.NoError(t, err)
+
+		_, err = a.Append(0, lbls, 120_000, 1)
+		require.NoError(t, err)
+	}
+
+	// The write failing for a destination tenant doesn't prevent the writes to the other tenants, and the
+	// series aren't written to the destination tenants which the tenant isn't allowed to write to.
+	err := a.Commit()
+	require.EqualError(t, err, "2 errors: failed to write to the destination tenant team-a: unavailable; the tenant user-1 is not allowed to write to the destination tenant team-b")
+	require.Equal(t, float64(1), testutil.ToFloat64(failedWrites))
+
+	seriesNames := func(req *mimirpb.WriteRequest) []string {
+		var names []string
+		for _, ts := range req.Timeseries {
+			names = append(names, mimirpb.FromLabelAdaptersToLabels(ts.Labels).Get(labels.MetricName))
+		}
+		return names
+	}
+	require.Len(t, pusher.requests, 3)
+	require.Equal(t, []string{"ALERTS", "mimir_rule_group_rules"}, seriesNames(pusher.requests["user-1"]))
+	require.Equal(t, []string{"job:up:sum"}, seriesNames(pusher.requests["team-a"]))
+	require.Equal(t, []string{"job:up:sum"}, seriesNames(pusher.requests["global"]))
+}
+
+func TestPusherAppendable_InvalidSeries(t *testing.T) {
+	pusher := &fakePusher{response: &mimirpb.WriteResponse{}}
+	pa := NewPusherAppendable(pusher, "user-1", ruleLimits{maxLabelNames: 3, maxLabelValueLength: 10}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+
+	a := pa.Appender(context.Background())
+	for series, expectedErr := range map[string]string{
+		`foo_bar{job="api"}`:                     "",
+		`foo_bar{job="a-very-long-job-name"}`:    "label value too long for metric",
+		`foo_bar{job="api", env="prod", az="a"}`: "series has too many labels (actual: 4, limit: 3)",
+		"foo_bar{job=\"\xff\"}":                  "the value of the label job of the series foo_bar is not valid UTF-8",
+	} {
+		lbls, err := parser.ParseMetric(series)
+		if err != nil {
+			// The parser doesn't accept the invalid UTF-8 values.
+			lbls = labels.FromStrings(labels.MetricName, "foo_bar", "job", "\xff")
+		}
+
+		_, err = a.Append(0, lbls, 120_000, 1)
+		if expectedErr == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.Error(t, err)
+		require.Contains(t, err.Error(), expectedErr)
+	}
+	require.NoError(t, a.Commit())
+
+	// Only the valid series is written.
+	require.Len(t, pusher.request.Timeseries, 1)
+	require.Equal(t, `{__name__="foo_bar", job="api"}`, mimirpb.FromLabelAdaptersToLabels(pusher.request.Timeseries[0].Labels).String())
+}
+
 func TestPusherErrors(t *testing.T) {
 	for name, tc := range map[string]struct {
 		returnedError    error
@@ -223,15 +333,51 @@ func TestMetricsQueryFuncErrors(t *testing.T) {
 
 func TestRecordAndReportRuleQueryMetrics(t *testing.T) {
 	queryTime := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	fetchedSeries := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	fetchedChunks := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	fetchedChunkBytes := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	metrics := &queryStatsMetrics{
+		querySeconds:      queryTime.WithLabelValues("userID"),
+		fetchedSeries:     fetchedSeries.WithLabelValues("userID"),
+		fetchedChunks:     fetchedChunks.WithLabelValues("userID"),
+		fetchedChunkBytes: fetchedChunkBytes.WithLabelValues("userID"),
+	}
 
 	mockFunc := func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
 		time.Sleep(1 * time.Second)
+		stats := querier_stats.FromContext(ctx)
+		stats.AddFetchedSeries(2)
+		stats.AddFetchedChunks(3)
+		stats.AddFetchedChunkBytes(4)
 		return promql.Vector{}, nil
 	}
-	qf := RecordAndReportRuleQueryMetrics(mockFunc, queryTime.WithLabelValues("userID"), log.NewNopLogger())
+	qf := RecordAndReportRuleQueryMetrics(mockFunc, metrics, log.NewNopLogger())
 	_, _ = qf(context.Background(), "test", time.Now())
 
 	require.GreaterOrEqual(t, testutil.ToFloat64(queryTime.WithLabelValues("userID")), float64(1))
+	require.Equal(t, float64(2), testutil.ToFloat64(fetchedSeries.WithLabelValues("userID")))
+	require.Equal(t, float64(3), testutil.ToFloat64(fetchedChunks.WithLabelValues("userID")))
+	require.Equal(t, float64(4), testutil.ToFloat64(fetchedChunkBytes.WithLabelValues("userID")))
+}
+
+func TestRecordAndReportRuleQueryMetrics_QuerierWallTime(t *testing.T) {
+	queryTime := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	metrics := &queryStatsMetrics{
+		querySeconds:      queryTime.WithLabelValues("userID"),
+		fetchedSeries:     prometheus.NewCounter(prometheus.CounterOpts{}),
+		fetchedChunks:     prometheus.NewCounter(prometheus.CounterOpts{}),
+		fetchedChunkBytes: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+
+	// When the wall time is reported by the queriers, the time spent by the ruler is not accounted.
+	mockFunc := func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+		querier_stats.FromContext(ctx).AddWallTime(5 * time.Second)
+		return promql.Vector{}, nil
+	}
+	qf := RecordAndReportRuleQueryMetrics(mockFunc, metrics, log.NewNopLogger())
+	_, _ = qf(context.Background(), "test", time.Now())
+
+	require.Equal(t, float64(5), testutil.ToFloat64(queryTime.WithLabelValues("userID")))
 }
 
 // TestManagerFactory_CorrectQueryableUsed ensures that when evaluating a group with non-empty SourceTenants
@@ -303,7 +449,7 @@ func TestManagerFactory_CorrectQueryableUsed(t *testing.T) {
 			regularQueryFunc := rules.EngineQueryFunc(eng, regularQueryable)
 			federatedQueryFunc := rules.EngineQueryFunc(eng, federatedQueryable)
 
-			queryFunc := TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc)
+			queryFunc := TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc, cfg.TenantFederation.SourceTenantLabel)
 
 			// create and use manager factory
 			managerFactory := DefaultTenantManagerFactory(cfg, pusher, federatedQueryable, queryFunc, overrides, nil)
@@ -329,7 +475,7 @@ func TestManagerFactory_CorrectQueryableUsed(t *testing.T) {
 
 func writeRuleGroupToFiles(t *testing.T, path string, logger log.Logger, userID string, ruleGroup rulespb.RuleGroupDesc) []string {
 	_, files, err := newMapper(path, logger).MapRules(userID, map[string][]rulefmt.RuleGroup{
-		"namespace": {rulespb.FromProto(&ruleGroup)},
+		"namespace": {rulespb.ToRuleFile(&ruleGroup)},
 	})
 	require.NoError(t, err)
 	require.Len(t, files, 1, "writing a single namespace, expecting a single file")
@@ -358,3 +504,65 @@ func (m *mockQueryable) Querier(_ context.Context, _, _ int64) (storage.Querier,
 	}
 	return storage.NoopQuerier(), nil
 }
+
+func TestActiveTimeIntervalsNotifyFunc(t *testing.T) {
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{
+		{Namespace: "ns", Name: "always-active"},
+		{Namespace: "ns", Name: "never-active", ActiveTimeIntervals: []rulespb.TimeInterval{
+			{Years: []rulespb.InclusiveRange{{Begin: 2000, End: 2001}}},
+		}},
+	})
+
+	muted := prometheus.NewCounter(prometheus.CounterOpts{})
+	notified := 0
+	notify := ActiveTimeIntervalsNotifyFunc(func(_ context.Context, _ string, alerts ...*rules.Alert) {
+		notified += len(alerts)
+	}, muted)
+
+	ctx := context.WithValue(context.Background(), tenantRuleGroups, registry)
+	alert := &rules.Alert{Labels: labels.FromStrings("alertname", "test")}
+
+	notify(context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "ns", name: "always-active"}), "up", alert)
+	require.Equal(t, 1, notified)
+	require.Equal(t, float64(0), testutil.ToFloat64(muted))
+
+	notify(context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "ns", name: "never-active"}), "up", alert)
+	require.Equal(t, 1, notified)
+	require.Equal(t, float64(1), testutil.ToFloat64(muted))
+
+	// Alerts of unknown rule groups are sent.
+	notify(context.Background(), "up", alert)
+	require.Equal(t, 2, notified)
+}
+
+func TestWarmUpNotifyFunc(t *testing.T) {
+	alert := &rules.Alert{Labels: labels.FromStrings("alertname", "test")}
+
+	for name, tc := range map[string]struct {
+		until              time.Time
+		expectedNotified   int
+		expectedSuppressed float64
+	}{
+		"during the grace period": {
+			until:              time.Now().Add(time.Hour),
+			expectedSuppressed: 2,
+		},
+		"after the grace period": {
+			until:            time.Now().Add(-time.Second),
+			expectedNotified: 2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			suppressed := prometheus.NewCounter(prometheus.CounterOpts{})
+			notified := 0
+			notify := WarmUpNotifyFunc(func(_ context.Context, _ string, alerts ...*rules.Alert) {
+				notified += len(alerts)
+			}, tc.until, suppressed)
+
+			notify(context.Background(), "up", alert, alert)
+			require.Equal(t, tc.expectedNotified, notified)
+			require.Equal(t, tc.expectedSuppressed, testutil.ToFloat64(suppressed))
+		})
+	}
+}
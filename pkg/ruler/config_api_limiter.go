@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var errConfigAPIWriteRateLimited = errors.New("request rejected because the tenant exceeded the per-tenant rate limit of the write requests of the ruler configuration API")
+
+// reserveConfigAPIWrite returns how long the tenant has to wait before its next write request of the
+// configuration API is allowed, or 0 if the request is allowed now.
+func (r *Ruler) reserveConfigAPIWrite(userID string, now time.Time) time.Duration {
+	limit := rate.Limit(r.limits.RulerConfigAPIWriteRateLimit(userID))
+	if limit <= 0 {
+		return 0
+	}
+	burst := r.limits.RulerConfigAPIWriteRateLimitBurst(userID)
+	if burst <= 0 {
+		burst = 1
+	}
+
+	r.configAPILimitersMtx.Lock()
+	limiter, ok := r.configAPILimiters[userID]
+	if !ok {
+		limiter = rate.NewLimiter(limit, burst)
+		r.configAPILimiters[userID] = limiter
+	} else {
+		// The limits may have been changed by a runtime config update.
+		if limiter.Limit() != limit {
+			limiter.SetLimitAt(now, limit)
+		}
+		if limiter.Burst() != burst {
+			limiter.SetBurstAt(now, burst)
+		}
+	}
+	r.configAPILimitersMtx.Unlock()
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// The request is rejected, so it doesn't consume the tokens of the next allowed ones.
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// RateLimitWrites wraps a handler of the configuration API changing the rule groups so that the
+// requests of the tenants exceeding their rate limit are rejected with a 429 response.
+func (a *API) RateLimitWrites(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, err := tenant.TenantID(req.Context())
+		if err != nil {
+			// The handler rejects the request.
+			next(w, req)
+			return
+		}
+
+		if delay := a.ruler.reserveConfigAPIWrite(userID, time.Now()); delay > 0 {
+			level.Warn(util_log.WithContext(req.Context(), a.logger)).Log("msg", "rate limited write request of the configuration API", "user", userID, "retry_after", delay)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, errConfigAPIWriteRateLimited.Error(), http.StatusTooManyRequests)
+			return
+		}
+		next(w, req)
+	}
+}
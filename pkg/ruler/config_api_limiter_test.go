@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRuler_ReserveConfigAPIWrite(t *testing.T) {
+	limits := &ruleLimits{configAPIWriteRate: 0.5, configAPIWriteBurst: 2}
+	r := &Ruler{limits: limits, configAPILimiters: map[string]*rate.Limiter{}}
+	now := time.Now()
+
+	// The burst is allowed.
+	assert.Zero(t, r.reserveConfigAPIWrite("user1", now))
+	assert.Zero(t, r.reserveConfigAPIWrite("user1", now))
+
+	// The next request is allowed once a token is available.
+	assert.Equal(t, 2*time.Second, r.reserveConfigAPIWrite("user1", now))
+	// The rejected requests don't delay the next allowed one.
+	assert.Equal(t, 2*time.Second, r.reserveConfigAPIWrite("user1", now))
+	assert.Zero(t, r.reserveConfigAPIWrite("user1", now.Add(2*time.Second)))
+
+	// The tenants are limited separately.
+	assert.Zero(t, r.reserveConfigAPIWrite("user2", now))
+
+	// The limit is applied when changed by a runtime config update.
+	limits.configAPIWriteRate = 0.1
+	assert.Equal(t, 10*time.Second, r.reserveConfigAPIWrite("user1", now.Add(2*time.Second)))
+	limits.configAPIWriteRate = 0
+	assert.Zero(t, r.reserveConfigAPIWrite("user1", now.Add(2*time.Second)))
+}
+
+func TestAPI_RateLimitWrites(t *testing.T) {
+	a := &API{
+		ruler:  &Ruler{limits: ruleLimits{configAPIWriteRate: 0.1, configAPIWriteBurst: 1}, configAPILimiters: map[string]*rate.Limiter{}},
+		logger: log.NewNopLogger(),
+	}
+	handler := a.RateLimitWrites(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/hello", nil, "user1"))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/hello", nil, "user1"))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, []string{"9", "10"}, w.Header().Get("Retry-After"))
+}
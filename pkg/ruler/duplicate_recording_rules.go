@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const (
+	duplicateRecordingRulesPolicyDisabled = "disabled"
+	duplicateRecordingRulesPolicyWarn     = "warn"
+	duplicateRecordingRulesPolicyReject   = "reject"
+)
+
+var duplicateRecordingRulesPolicies = []string{
+	duplicateRecordingRulesPolicyDisabled,
+	duplicateRecordingRulesPolicyWarn,
+	duplicateRecordingRulesPolicyReject,
+}
+
+// recordingRuleOutput identifies the series written by a recording rule: the recorded
+// metric name and the static labels configured on the rule.
+type recordingRuleOutput struct {
+	record string
+	labels string
+}
+
+func (o recordingRuleOutput) String() string {
+	if o.labels == "{}" {
+		return o.record
+	}
+	return o.record + o.labels
+}
+
+func newRecordingRuleOutput(record string, lbls labels.Labels) recordingRuleOutput {
+	return recordingRuleOutput{record: record, labels: lbls.String()}
+}
+
+// findDuplicateRecordingRules returns a message for each recording rule in group which records
+// to the same metric name with identical labels as another recording rule in the same group or in
+// one of the existing groups. Existing groups with the same namespace and name as group are
+// ignored, because they're going to be replaced by group.
+func findDuplicateRecordingRules(namespace string, group rulefmt.RuleGroup, existing rulespb.RuleGroupList) []string {
+	owners := map[recordingRuleOutput]string{}
+	for _, rg := range existing {
+		if rg.GetNamespace() == namespace && rg.GetName() == group.Name {
+			continue
+		}
+		for _, r := range rulespb.ToRuleFile(rg).Rules {
+			if r.Record.Value == "" {
+				continue
+			}
+			owners[newRecordingRuleOutput(r.Record.Value, labels.FromMap(r.Labels))] = fmt.Sprintf("namespace %q, group %q", rg.GetNamespace(), rg.GetName())
+		}
+	}
+
+	var duplicates []string
+	self := fmt.Sprintf("namespace %q, group %q", namespace, group.Name)
+	for _, r := range group.Rules {
+		if r.Record.Value == "" {
+			continue
+		}
+		out := newRecordingRuleOutput(r.Record.Value, labels.FromMap(r.Labels))
+		if owner, ok := owners[out]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("recording rule %q records to the same series as another recording rule in %s", out.String(), owner))
+			continue
+		}
+		owners[out] = self
+	}
+
+	return duplicates
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+var (
+	errOnDemandEvaluationDisabled    = errors.New("on-demand rule group evaluation is disabled for the tenant")
+	errOnDemandEvaluationRateLimited = errors.New("on-demand rule group evaluation rejected because the tenant exceeded the per-tenant on-demand evaluations rate limit")
+	errOnDemandEvaluationUnavailable = errors.New("on-demand rule group evaluation is not available")
+)
+
+// allowOnDemandEvaluation returns an error if the tenant is not allowed to run an on-demand
+// rule group evaluation at this time.
+func (r *Ruler) allowOnDemandEvaluation(userID string) error {
+	perMinute := r.limits.RulerOnDemandEvaluationsPerMinute(userID)
+	if perMinute <= 0 {
+		return errOnDemandEvaluationDisabled
+	}
+
+	limit := rate.Limit(float64(perMinute) / time.Minute.Seconds())
+
+	r.onDemandLimitersMtx.Lock()
+	limiter, ok := r.onDemandLimiters[userID]
+	if !ok {
+		limiter = rate.NewLimiter(limit, 1)
+		r.onDemandLimiters[userID] = limiter
+	} else if limiter.Limit() != limit {
+		// The limit has been changed by a runtime config update.
+		limiter.SetLimit(limit)
+	}
+	r.onDemandLimitersMtx.Unlock()
+
+	if !limiter.Allow() {
+		return errOnDemandEvaluationRateLimited
+	}
+	return nil
+}
+
+// EvaluateRuleGroup evaluates the rules of rg once, at the current time, and returns their state.
+// The evaluation is out of band: it doesn't affect the state of the rule group evaluated by the
+// ruler owning it, the results are not written to the ingesters and the alerts are not sent to the
+// Alertmanager.
+func (r *Ruler) EvaluateRuleGroup(ctx context.Context, userID string, rg *rulespb.RuleGroupDesc) (*GroupStateDesc, error) {
+	if r.queryFunc == nil {
+		return nil, errOnDemandEvaluationUnavailable
+	}
+	if err := r.allowOnDemandEvaluation(userID); err != nil {
+		return nil, err
+	}
+
+	rules, err := newOnDemandEvaluationRules(rg, r.cfg.ExternalURL.String(), r.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluationDelay := r.limits.EvaluationDelay(userID)
+	g := promRules.NewGroup(promRules.GroupOptions{
+		Name: rg.Name,
+		// The file name is escaped the same way the mapper does for groups loaded by the ruler.
+		File:            url.PathEscape(rg.Namespace),
+		Interval:        rg.Interval,
+		Limit:           int(rg.Limit),
+		Rules:           rules,
+		SourceTenants:   rg.SourceTenants,
+		EvaluationDelay: &evaluationDelay,
+		Opts:            &promRules.ManagerOptions{Logger: r.logger},
+	})
+
+	ctx = ChainGroupEvaluationContextFuncs(FederatedGroupContextFunc, RuleGroupContextFunc, AlertTemplatesContextFunc)(ctx, g)
+	queryFunc := AlertTemplatesQueryFunc(TracingQueryFunc(r.queryFunc))
+
+	start := time.Now()
+	for _, rule := range g.Rules() {
+		ruleStart := time.Now()
+		_, err := rule.Eval(ctx, evaluationDelay, start, queryFunc, r.cfg.ExternalURL.URL, g.Limit())
+		if err != nil {
+			rule.SetHealth(promRules.HealthBad)
+			rule.SetLastError(err)
+		} else {
+			rule.SetHealth(promRules.HealthGood)
+			rule.SetLastError(nil)
+		}
+		rule.SetEvaluationDuration(time.Since(ruleStart))
+		rule.SetEvaluationTimestamp(start)
+	}
+	evaluationTime := time.Since(start)
+
+	groupDesc, err := newGroupStateDesc(userID, rg.Namespace, g)
+	if err != nil {
+		return nil, err
+	}
+	groupDesc.EvaluationTimestamp = start
+	groupDesc.EvaluationDuration = evaluationTime
+	return groupDesc, nil
+}
+
+// newOnDemandEvaluationRules builds the rules of rg, without any state.
+func newOnDemandEvaluationRules(rg *rulespb.RuleGroupDesc, externalURL string, logger log.Logger) ([]promRules.Rule, error) {
+	rules := make([]promRules.Rule, 0, len(rg.Rules))
+	for _, r := range rg.Rules {
+		expr, err := parser.ParseExpr(r.Expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the expression of rule %d in group %q", len(rules), rg.Name)
+		}
+
+		lbls := mimirpb.FromLabelAdaptersToLabels(r.Labels)
+		if len(rg.Labels) > 0 {
+			lbls = labels.FromMap(rulespb.MergeGroupLabels(mimirpb.FromLabelAdaptersToLabels(rg.Labels).Map(), lbls.Map()))
+		}
+		if r.Alert != "" {
+			annotations := mimirpb.FromLabelAdaptersToLabels(r.Annotations)
+			rules = append(rules, promRules.NewAlertingRule(r.Alert, expr, r.For, lbls, annotations, nil, externalURL, true, log.With(logger, "alert", r.Alert)))
+			continue
+		}
+		rules = append(rules, promRules.NewRecordingRule(r.Record, expr, lbls))
+	}
+	return rules, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+)
+
+const evaluationMetricsWriterKey contextKey = 9
+
+// Names of the series written to the tenant with the metrics of the evaluations of its rule groups.
+const (
+	ruleGroupLastDurationMetricName   = "mimir_rule_group_last_duration_seconds"
+	ruleGroupLastEvaluationMetricName = "mimir_rule_group_last_evaluation_timestamp_seconds"
+	ruleGroupIntervalMetricName       = "mimir_rule_group_interval_seconds"
+	ruleGroupRulesMetricName          = "mimir_rule_group_rules"
+	ruleGroupRulesFailedMetricName    = "mimir_rule_group_rules_failed"
+	ruleGroupMetricsNamespaceLabel    = "namespace"
+	ruleGroupMetricsRuleGroupLabel    = "rule_group"
+)
+
+// EvaluationMetricsContextFunc returns a rules.ContextWrapFunc injecting in the context of each rule group
+// a writer used by EvaluationMetricsQueryFunc to write the metrics of the evaluations of the group to appendable,
+// when enabled returns true.
+func EvaluationMetricsContextFunc(appendable storage.Appendable, enabled func() bool, logger log.Logger) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		return context.WithValue(ctx, evaluationMetricsWriterKey, &evaluationMetricsWriter{
+			group:      g,
+			appendable: appendable,
+			enabled:    enabled,
+			logger:     logger,
+		})
+	}
+}
+
+// EvaluationMetricsQueryFunc returns a rules.QueryFunc which, at the first query of each evaluation of a rule
+// group, writes the metrics of the previous evaluation of the group. The Prometheus rules manager doesn't
+// notify the end of an evaluation, so its metrics are written at the start of the next one, with the
+// timestamp of the end of the evaluation.
+func EvaluationMetricsQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if w, ok := ctx.Value(evaluationMetricsWriterKey).(*evaluationMetricsWriter); ok {
+			w.write(ctx)
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+// evaluatedGroup is the part of rules.Group used by evaluationMetricsWriter.
+type evaluatedGroup interface {
+	Name() string
+	File() string
+	Interval() time.Duration
+	Rules() []rules.Rule
+	GetLastEvaluation() time.Time
+	GetEvaluationTime() time.Duration
+}
+
+type evaluationMetricsWriter struct {
+	group      evaluatedGroup
+	appendable storage.Appendable
+	enabled    func() bool
+	logger     log.Logger
+
+	mtx sync.Mutex
+	// Start of the latest evaluation whose metrics were written.
+	written time.Time
+}
+
+func (w *evaluationMetricsWriter) write(ctx context.Context) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	lastEvaluation := w.group.GetLastEvaluation()
+	if lastEvaluation.IsZero() || !lastEvaluation.After(w.written) {
+		return
+	}
+	w.written = lastEvaluation
+	if !w.enabled() {
+		return
+	}
+
+	namespace := filepath.Base(w.group.File())
+	if info, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo); ok {
+		namespace = info.namespace
+	}
+
+	failed := 0
+	for _, r := range w.group.Rules() {
+		if r.Health() == rules.HealthBad {
+			failed++
+		}
+	}
+
+	duration := w.group.GetEvaluationTime()
+	ts := lastEvaluation.Add(duration).UnixMilli()
+	app := w.appendable.Appender(ctx)
+	for _, m := range []struct {
+		name  string
+		value float64
+	}{
+		{name: ruleGroupLastDurationMetricName, value: duration.Seconds()},
+		{name: ruleGroupLastEvaluationMetricName, value: float64(lastEvaluation.UnixNano()) / 1e9},
+		{name: ruleGroupIntervalMetricName, value: w.group.Interval().Seconds()},
+		{name: ruleGroupRulesMetricName, value: float64(len(w.group.Rules()))},
+		{name: ruleGroupRulesFailedMetricName, value: float64(failed)},
+	} {
+		l := labels.FromStrings(labels.MetricName, m.name, ruleGroupMetricsNamespaceLabel, namespace, ruleGroupMetricsRuleGroupLabel, w.group.Name())
+		if _, err := app.Append(0, l, ts, m.value); err != nil {
+			level.Warn(w.logger).Log("msg", "failed to append the evaluation metrics of the rule group", "group", w.group.Name(), "err", err)
+			_ = app.Rollback()
+			return
+		}
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(w.logger).Log("msg", "failed to write the evaluation metrics of the rule group", "group", w.group.Name(), "err", err)
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+type mockEvaluatedGroup struct {
+	rules          []rules.Rule
+	lastEvaluation time.Time
+	evaluationTime time.Duration
+}
+
+func (g *mockEvaluatedGroup) Name() string                     { return "group-1" }
+func (g *mockEvaluatedGroup) File() string                     { return "/rules/user-1/namespace-1" }
+func (g *mockEvaluatedGroup) Interval() time.Duration          { return time.Minute }
+func (g *mockEvaluatedGroup) Rules() []rules.Rule              { return g.rules }
+func (g *mockEvaluatedGroup) GetLastEvaluation() time.Time     { return g.lastEvaluation }
+func (g *mockEvaluatedGroup) GetEvaluationTime() time.Duration { return g.evaluationTime }
+
+// seriesPusher is a Pusher keeping the pushed series.
+type seriesPusher struct {
+	series []mimirpb.PreallocTimeseries
+}
+
+func (p *seriesPusher) Push(_ context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	p.series = append(p.series, req.Timeseries...)
+	return &mimirpb.WriteResponse{}, nil
+}
+
+func TestEvaluationMetricsQueryFunc(t *testing.T) {
+	expr, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+	failing := rules.NewRecordingRule("up:sum:failing", expr, nil)
+	failing.SetHealth(rules.HealthBad)
+
+	for name, tc := range map[string]struct {
+		enabled        bool
+		expectedSeries int
+	}{
+		"disabled": {},
+		"enabled":  {enabled: true, expectedSeries: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := &mockEvaluatedGroup{rules: []rules.Rule{rules.NewRecordingRule("up:sum", expr, nil), failing}}
+			pusher := &seriesPusher{}
+			appendable := NewPusherAppendable(pusher, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+			ctx := context.WithValue(context.Background(), evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace 1", name: "group-1"})
+			ctx = context.WithValue(ctx, evaluationMetricsWriterKey, &evaluationMetricsWriter{
+				group:      g,
+				appendable: appendable,
+				enabled:    func() bool { return tc.enabled },
+				logger:     log.NewNopLogger(),
+			})
+
+			queries := 0
+			qf := EvaluationMetricsQueryFunc(func(context.Context, string, time.Time) (promql.Vector, error) {
+				queries++
+				return nil, nil
+			})
+
+			// Nothing is written before the first evaluation completes.
+			_, err := qf(ctx, "sum(up)", time.Now())
+			require.NoError(t, err)
+			assert.Empty(t, pusher.series)
+
+			// The metrics of each evaluation are written once, at the first query of the next one.
+			g.lastEvaluation = time.Unix(3600, 0)
+			g.evaluationTime = 2500 * time.Millisecond
+			for i := 0; i < 2; i++ {
+				_, err := qf(ctx, "sum(up)", time.Now())
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 3, queries)
+			require.Len(t, pusher.series, tc.expectedSeries)
+			if !tc.enabled {
+				return
+			}
+
+			values := map[string]float64{}
+			for _, s := range pusher.series {
+				l := mimirpb.FromLabelAdaptersToLabels(s.Labels)
+				assert.Equal(t, "namespace 1", l.Get("namespace"))
+				assert.Equal(t, "group-1", l.Get("rule_group"))
+				require.Len(t, s.Samples, 1)
+				assert.Equal(t, time.Unix(3602, 5e8).UnixMilli(), s.Samples[0].TimestampMs)
+				values[l.Get(labels.MetricName)] = s.Samples[0].Value
+			}
+			assert.Equal(t, map[string]float64{
+				"mimir_rule_group_last_duration_seconds":             2.5,
+				"mimir_rule_group_last_evaluation_timestamp_seconds": 3600,
+				"mimir_rule_group_interval_seconds":                  60,
+				"mimir_rule_group_rules":                             2,
+				"mimir_rule_group_rules_failed":                      1,
+			}, values)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"go.uber.org/atomic"
+)
+
+const (
+	evaluationsStopKey      contextKey = 15
+	evaluationsStopGroupKey contextKey = 16
+)
+
+var errEvaluationsStopped = errors.New("the evaluation of the rule group was started while the ruler is stopping")
+
+// evaluationsStop tells the rule groups of the rules managers to not start new evaluations.
+type evaluationsStop struct {
+	stopped atomic.Bool
+}
+
+// stop prevents the evaluations not started yet from running. The in-flight evaluations complete.
+func (s *evaluationsStop) stop() {
+	s.stopped.Store(true)
+}
+
+// EvaluationsStopContextFunc injects in the context of each rule group the state used by EvaluationsStopQueryFunc
+// to tell the evaluations of the group started after the rules manager started stopping.
+func EvaluationsStopContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	s, ok := ctx.Value(evaluationsStopKey).(*evaluationsStop)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, evaluationsStopGroupKey, &groupEvaluationsStop{stop: s, group: g})
+}
+
+// EvaluationsStopQueryFunc returns a rules.QueryFunc failing the queries of the evaluations of the rule groups
+// started after the rules manager started stopping. The Prometheus rules manager stops the rule groups of a
+// tenant one after the other, waiting for the in-flight evaluation of each group, and the groups not stopped
+// yet keep starting new evaluations meanwhile, whose alerts would be sent in addition to the alerts sent by
+// the ruler taking over the groups.
+func EvaluationsStopQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if s, ok := ctx.Value(evaluationsStopGroupKey).(*groupEvaluationsStop); ok && !s.allowed() {
+			return nil, errEvaluationsStopped
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+type groupEvaluationsStop struct {
+	stop  *evaluationsStop
+	group interface{ GetLastEvaluation() time.Time }
+
+	mtx sync.Mutex
+	// Whether an evaluation was seen, the last evaluation of the group as of the first query of the
+	// current evaluation, and whether the current evaluation was started before the rules manager
+	// started stopping.
+	seen           bool
+	lastEvaluation time.Time
+	startedBefore  bool
+}
+
+// allowed returns whether the evaluation running the query was started before the rules manager started
+// stopping. The Prometheus rules manager doesn't notify the start of an evaluation, so an evaluation is
+// told by the last evaluation of the group, updated at the end of each evaluation.
+func (s *groupEvaluationsStop) allowed() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	lastEvaluation := s.group.GetLastEvaluation()
+	if !s.seen || !lastEvaluation.Equal(s.lastEvaluation) {
+		s.seen = true
+		s.lastEvaluation = lastEvaluation
+		s.startedBefore = !s.stop.stopped.Load()
+	}
+	return s.startedBefore
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lastEvaluationGroup struct {
+	lastEvaluation time.Time
+}
+
+func (g *lastEvaluationGroup) GetLastEvaluation() time.Time {
+	return g.lastEvaluation
+}
+
+func TestEvaluationsStopQueryFunc(t *testing.T) {
+	stop := &evaluationsStop{}
+	g := &lastEvaluationGroup{}
+	ctx := context.WithValue(context.Background(), evaluationsStopGroupKey, &groupEvaluationsStop{stop: stop, group: g})
+
+	queries := 0
+	qf := EvaluationsStopQueryFunc(func(context.Context, string, time.Time) (promql.Vector, error) {
+		queries++
+		return nil, nil
+	})
+
+	// First evaluation of the group.
+	_, err := qf(ctx, "up", time.Now())
+	require.NoError(t, err)
+
+	// The evaluation in-flight when the manager starts stopping completes.
+	stop.stop()
+	_, err = qf(ctx, "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, queries)
+
+	// The next evaluation of the group doesn't run.
+	g.lastEvaluation = time.Now()
+	_, err = qf(ctx, "up", time.Now())
+	require.ErrorIs(t, err, errEvaluationsStopped)
+	_, err = qf(ctx, "sum(up)", time.Now())
+	require.ErrorIs(t, err, errEvaluationsStopped)
+	assert.Equal(t, 2, queries)
+
+	// The queries run outside of the evaluations of the rule groups are unchanged.
+	_, err = qf(context.Background(), "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 3, queries)
+}
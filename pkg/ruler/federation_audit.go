@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const federationAuditPrefix = "federation-audit"
+
+var errInvalidFederationAuditFlushInterval = errors.New("invalid ruler tenant federation audit flush interval, must be greater than zero")
+
+type FederationAuditConfig struct {
+	LogEnabled    bool          `yaml:"log_enabled"`
+	StoreEnabled  bool          `yaml:"store_enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+func (cfg *FederationAuditConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.LogEnabled, "ruler.tenant-federation.audit.log-enabled", false, "Log an audit event for each query run by the evaluations of the federated rule groups, with the tenant owning the rule group, its source and destination tenants, the rules and the number of series returned.")
+	f.BoolVar(&cfg.StoreEnabled, "ruler.tenant-federation.audit.store-enabled", false, "Write the audit events of the queries run by the evaluations of the federated rule groups to the ruler storage. Requires an object storage backend for the ruler storage.")
+	f.DurationVar(&cfg.FlushInterval, "ruler.tenant-federation.audit.flush-interval", time.Minute, "How frequently the recorded audit events are flushed to the ruler storage.")
+}
+
+func (cfg *FederationAuditConfig) Validate() error {
+	if cfg.StoreEnabled && cfg.FlushInterval <= 0 {
+		return errInvalidFederationAuditFlushInterval
+	}
+	return nil
+}
+
+// Enabled returns whether the audit events are logged or stored.
+func (cfg *FederationAuditConfig) Enabled() bool {
+	return cfg.LogEnabled || cfg.StoreEnabled
+}
+
+// FederationAuditEvent is a query run by the evaluation of a federated rule group.
+type FederationAuditEvent struct {
+	Timestamp          time.Time `json:"timestamp"`
+	ConsumerTenant     string    `json:"consumer_tenant"`
+	SourceTenants      []string  `json:"source_tenants"`
+	DestTenants        []string  `json:"dest_tenants"`
+	RuleGroupNamespace string    `json:"rule_group_namespace"`
+	RuleGroup          string    `json:"rule_group"`
+	Rules              []string  `json:"rules"`
+	Query              string    `json:"query"`
+	SeriesCount        int       `json:"series_count"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// FederationAudit records the queries run by the evaluations of the federated rule groups, so that
+// the accesses to the series of other tenants can be audited. The events are logged, and stored in
+// the object storage by tenant owning the rule group if a bucket is given.
+type FederationAudit struct {
+	services.Service
+
+	cfg        FederationAuditConfig
+	bucket     objstore.Bucket
+	instanceID string
+	logger     log.Logger
+
+	mtx sync.Mutex
+	// Events not flushed to the object storage yet, by consumer tenant.
+	pending map[string][]FederationAuditEvent
+
+	eventsTotal   *prometheus.CounterVec
+	flushesFailed prometheus.Counter
+}
+
+// NewFederationAudit returns a FederationAudit. The bucket is nil if the events are not stored.
+func NewFederationAudit(cfg FederationAuditConfig, bucket objstore.Bucket, instanceID string, logger log.Logger, reg prometheus.Registerer) *FederationAudit {
+	a := &FederationAudit{
+		cfg:        cfg,
+		bucket:     bucket,
+		instanceID: instanceID,
+		logger:     logger,
+		pending:    map[string][]FederationAuditEvent{},
+		eventsTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_federation_audit_events_total",
+			Help: "Total number of audit events recorded for the queries run by the evaluations of the federated rule groups.",
+		}, []string{"user"}),
+		flushesFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_federation_audit_flushes_failed_total",
+			Help: "Total number of failed flushes of the federation audit events to the ruler storage.",
+		}),
+	}
+
+	if bucket == nil {
+		a.Service = services.NewIdleService(nil, nil)
+	} else {
+		a.Service = services.NewTimerService(cfg.FlushInterval, nil, a.iteration, a.stopping)
+	}
+	return a
+}
+
+func (a *FederationAudit) iteration(ctx context.Context) error {
+	a.flush(ctx)
+	return nil
+}
+
+func (a *FederationAudit) stopping(_ error) error {
+	// Flush the events recorded since the last iteration.
+	a.flush(context.Background())
+	return nil
+}
+
+// QueryFunc returns a QueryFunc recording an audit event for each query of the federated rule groups run by qf.
+func (a *FederationAudit) QueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		sourceTenants, _ := ctx.Value(federatedGroupSourceTenants).([]string)
+		if len(sourceTenants) == 0 {
+			return qf(ctx, qs, t)
+		}
+
+		result, err := qf(ctx, qs, t)
+
+		consumerTenant, _ := tenant.TenantID(ctx)
+		event := FederationAuditEvent{
+			Timestamp:      time.Now().UTC(),
+			ConsumerTenant: consumerTenant,
+			SourceTenants:  sourceTenants,
+			DestTenants:    []string{consumerTenant},
+			Query:          qs,
+			SeriesCount:    len(result),
+		}
+		if destTenants := evaluatedRuleGroupDesc(ctx).GetDestTenants(); len(destTenants) > 0 {
+			event.DestTenants = destTenants
+		}
+		if g, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo); ok {
+			event.RuleGroupNamespace = g.namespace
+			event.RuleGroup = g.name
+			event.Rules = g.ruleNames(qs)
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		a.record(event)
+
+		return result, err
+	}
+}
+
+func (a *FederationAudit) record(event FederationAuditEvent) {
+	a.eventsTotal.WithLabelValues(event.ConsumerTenant).Inc()
+
+	if a.cfg.LogEnabled {
+		logMessage := []interface{}{
+			"msg", "federated rule evaluation query",
+			"user", event.ConsumerTenant,
+			"source_tenants", tenant.JoinTenantIDs(event.SourceTenants),
+			"dest_tenants", tenant.JoinTenantIDs(event.DestTenants),
+			"rule_group_namespace", event.RuleGroupNamespace,
+			"rule_group", event.RuleGroup,
+		}
+		for _, name := range event.Rules {
+			logMessage = append(logMessage, "rule", name)
+		}
+		logMessage = append(logMessage, "series_count", event.SeriesCount, "query", event.Query)
+		if event.Error != "" {
+			logMessage = append(logMessage, "err", event.Error)
+		}
+		level.Info(a.logger).Log(logMessage...)
+	}
+
+	if a.bucket != nil {
+		a.mtx.Lock()
+		a.pending[event.ConsumerTenant] = append(a.pending[event.ConsumerTenant], event)
+		a.mtx.Unlock()
+	}
+}
+
+// flush uploads the pending events of each tenant to the object storage.
+func (a *FederationAudit) flush(ctx context.Context) {
+	a.mtx.Lock()
+	pending := a.pending
+	a.pending = map[string][]FederationAuditEvent{}
+	a.mtx.Unlock()
+
+	for userID, events := range pending {
+		if err := a.upload(ctx, userID, events); err != nil {
+			a.flushesFailed.Inc()
+			level.Warn(a.logger).Log("msg", "failed to flush federation audit events", "user", userID, "events", len(events), "err", err)
+
+			// Retry at the next flush.
+			a.mtx.Lock()
+			a.pending[userID] = append(events, a.pending[userID]...)
+			a.mtx.Unlock()
+		}
+	}
+}
+
+func (a *FederationAudit) upload(ctx context.Context, userID string, events []FederationAuditEvent) error {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	minT, maxT := events[0].Timestamp, events[0].Timestamp
+	for _, e := range events[1:] {
+		if e.Timestamp.Before(minT) {
+			minT = e.Timestamp
+		}
+		if e.Timestamp.After(maxT) {
+			maxT = e.Timestamp
+		}
+	}
+
+	return a.bucket.Upload(ctx, federationAuditObjectName(userID, minT, maxT, a.instanceID), &buf)
+}
+
+// federationAuditObjectName returns the name of the object storing the events between minT and maxT, in the
+// same layout as the alert history objects.
+func federationAuditObjectName(userID string, minT, maxT time.Time, instanceID string) string {
+	return path.Join(federationAuditPrefix, userID, fmt.Sprintf("%d-%d-%s.json", util.TimeToMillis(minT), util.TimeToMillis(maxT), instanceID))
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestFederationAudit_QueryFunc(t *testing.T) {
+	logs := &bytes.Buffer{}
+	bucket := objstore.NewInMemBucket()
+	reg := prometheus.NewPedanticRegistry()
+	audit := NewFederationAudit(FederationAuditConfig{LogEnabled: true, StoreEnabled: true, FlushInterval: time.Minute}, bucket, "ruler-1", log.NewLogfmtLogger(logs), reg)
+
+	queryErr := errors.New("query failed")
+	queryFunc := audit.QueryFunc(func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		if qs == "failing" {
+			return nil, queryErr
+		}
+		return promql.Vector{{}, {}}, nil
+	})
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{{User: "tenant-1", Namespace: "namespace", Name: "group", SourceTenants: []string{"tenant-2", "tenant-3"}, DestTenants: []string{"tenant-4"}}})
+	ctx := user.InjectOrgID(context.Background(), "tenant-1")
+	ctx = context.WithValue(ctx, tenantRuleGroups, registry)
+
+	// The queries of the non-federated rule groups are not audited.
+	_, err := queryFunc(ctx, "sum(up)", time.Now())
+	require.NoError(t, err)
+
+	ctx = context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace", name: "group", rulesByQuery: map[string][]string{"sum(up)": {"up:sum"}}})
+	ctx = context.WithValue(ctx, federatedGroupSourceTenants, []string{"tenant-2", "tenant-3"})
+	_, err = queryFunc(ctx, "sum(up)", time.Now())
+	require.NoError(t, err)
+	_, err = queryFunc(ctx, "failing", time.Now())
+	require.ErrorIs(t, err, queryErr)
+
+	assert.Equal(t, 2, strings.Count(logs.String(), "federated rule evaluation query"))
+	assert.Contains(t, logs.String(), "user=tenant-1 source_tenants=tenant-2|tenant-3 dest_tenants=tenant-4 rule_group_namespace=namespace rule_group=group rule=up:sum series_count=2 query=sum(up)")
+	assert.Contains(t, logs.String(), "series_count=0 query=failing err=\"query failed\"")
+	assert.Equal(t, float64(2), testutil.ToFloat64(audit.eventsTotal.WithLabelValues("tenant-1")))
+
+	// The events are stored by tenant owning the rule group once flushed.
+	audit.flush(context.Background())
+
+	var events []FederationAuditEvent
+	require.NoError(t, bucket.Iter(context.Background(), "federation-audit/tenant-1/", func(name string) error {
+		assert.True(t, strings.HasSuffix(name, "-ruler-1.json"))
+		r, err := bucket.Get(context.Background(), name)
+		require.NoError(t, err)
+		defer r.Close()
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var e FederationAuditEvent
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+			events = append(events, e)
+		}
+		return scanner.Err()
+	}))
+	require.Len(t, events, 2)
+	assert.Equal(t, "tenant-1", events[0].ConsumerTenant)
+	assert.Equal(t, []string{"tenant-2", "tenant-3"}, events[0].SourceTenants)
+	assert.Equal(t, []string{"tenant-4"}, events[0].DestTenants)
+	assert.Equal(t, []string{"up:sum"}, events[0].Rules)
+	assert.Equal(t, 2, events[0].SeriesCount)
+	assert.Equal(t, "query failed", events[1].Error)
+
+	// The flushed events are not uploaded again.
+	audit.flush(context.Background())
+	objects := 0
+	require.NoError(t, bucket.Iter(context.Background(), "federation-audit/tenant-1/", func(string) error {
+		objects++
+		return nil
+	}))
+	assert.Equal(t, 1, objects)
+}
+
+func TestFederationAudit_DestTenantsDefaultToTheConsumerTenant(t *testing.T) {
+	logs := &bytes.Buffer{}
+	audit := NewFederationAudit(FederationAuditConfig{LogEnabled: true}, nil, "ruler-1", log.NewLogfmtLogger(logs), nil)
+	queryFunc := audit.QueryFunc(func(context.Context, string, time.Time) (promql.Vector, error) {
+		return nil, nil
+	})
+
+	ctx := user.InjectOrgID(context.Background(), "tenant-1")
+	ctx = context.WithValue(ctx, federatedGroupSourceTenants, []string{"tenant-2"})
+	_, err := queryFunc(ctx, "sum(up)", time.Now())
+	require.NoError(t, err)
+
+	assert.Contains(t, logs.String(), "user=tenant-1 source_tenants=tenant-2 dest_tenants=tenant-1")
+	assert.Empty(t, audit.pending)
+}
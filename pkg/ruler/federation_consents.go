@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const federationConsentsPrefix = "federation-consents"
+
+var errFederationConsentsDisabled = errors.New("federation consents are disabled")
+
+// federationConsentsCacheTTL is how long the consents of a source tenant read from the object storage are
+// cached, so that the consents granted or revoked through another replica apply within this delay.
+var federationConsentsCacheTTL = time.Minute
+
+// FederationConsentError is returned when a source tenant of a federated rule group hasn't granted its
+// consent to the tenant owning the rule group.
+type FederationConsentError struct {
+	SourceTenant   string
+	ConsumerTenant string
+}
+
+func (e *FederationConsentError) Error() string {
+	return fmt.Sprintf("the source tenant %s has not granted its consent to federate its series to the tenant %s", e.SourceTenant, e.ConsumerTenant)
+}
+
+// federationConsentsObject is the object storing the consents of a source tenant.
+type federationConsentsObject struct {
+	ConsumerTenants []string `json:"consumer_tenants"`
+}
+
+type cachedFederationConsents struct {
+	consumerTenants []string
+	fetchedAt       time.Time
+}
+
+// FederationConsents stores the consents granted by the source tenants to the tenants whose federated rule
+// groups read their series, in the object storage.
+type FederationConsents struct {
+	bucket objstore.Bucket
+
+	// Serializes the updates of the consents, and protects the cache.
+	mtx   sync.Mutex
+	cache map[string]cachedFederationConsents
+}
+
+func NewFederationConsents(bucket objstore.Bucket) *FederationConsents {
+	return &FederationConsents{
+		bucket: bucket,
+		cache:  map[string]cachedFederationConsents{},
+	}
+}
+
+// ConsumerTenants returns the tenants the source tenant consents to federate its series to.
+func (c *FederationConsents) ConsumerTenants(ctx context.Context, sourceTenant string) ([]string, error) {
+	c.mtx.Lock()
+	cached, ok := c.cache[sourceTenant]
+	c.mtx.Unlock()
+	if ok && time.Since(cached.fetchedAt) < federationConsentsCacheTTL {
+		return cached.consumerTenants, nil
+	}
+
+	consumerTenants, err := c.read(ctx, sourceTenant)
+	if err != nil {
+		return nil, err
+	}
+	c.mtx.Lock()
+	c.cache[sourceTenant] = cachedFederationConsents{consumerTenants: consumerTenants, fetchedAt: time.Now()}
+	c.mtx.Unlock()
+	return consumerTenants, nil
+}
+
+// Check returns a FederationConsentError if a source tenant other than the consumer tenant itself hasn't
+// granted its consent to the consumer tenant.
+func (c *FederationConsents) Check(ctx context.Context, consumerTenant string, sourceTenants []string) error {
+	for _, sourceTenant := range sourceTenants {
+		if sourceTenant == consumerTenant {
+			continue
+		}
+		consumerTenants, err := c.ConsumerTenants(ctx, sourceTenant)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the federation consents of the source tenant %s", sourceTenant)
+		}
+		if !containsString(consumerTenants, consumerTenant) {
+			return &FederationConsentError{SourceTenant: sourceTenant, ConsumerTenant: consumerTenant}
+		}
+	}
+	return nil
+}
+
+// Grant grants the consent of the source tenant to the consumer tenants.
+func (c *FederationConsents) Grant(ctx context.Context, sourceTenant string, consumerTenants []string) ([]string, error) {
+	return c.update(ctx, sourceTenant, func(current []string) []string {
+		for _, consumerTenant := range consumerTenants {
+			if !containsString(current, consumerTenant) {
+				current = append(current, consumerTenant)
+			}
+		}
+		return current
+	})
+}
+
+// Revoke revokes the consent of the source tenant to the consumer tenant.
+func (c *FederationConsents) Revoke(ctx context.Context, sourceTenant, consumerTenant string) ([]string, error) {
+	return c.update(ctx, sourceTenant, func(current []string) []string {
+		updated := current[:0]
+		for _, t := range current {
+			if t != consumerTenant {
+				updated = append(updated, t)
+			}
+		}
+		return updated
+	})
+}
+
+// update updates the consents of the source tenant in the object storage. The concurrent updates of the
+// consents of a tenant through different replicas may be lost.
+func (c *FederationConsents) update(ctx context.Context, sourceTenant string, fn func(current []string) []string) ([]string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	current, err := c.read(ctx, sourceTenant)
+	if err != nil {
+		return nil, err
+	}
+	updated := fn(current)
+	sort.Strings(updated)
+
+	objectName := path.Join(federationConsentsPrefix, sourceTenant)
+	if len(updated) == 0 {
+		if err := c.bucket.Delete(ctx, objectName); err != nil && !c.bucket.IsObjNotFoundErr(err) {
+			return nil, errors.Wrapf(err, "failed to delete the federation consents object %q", objectName)
+		}
+	} else {
+		data, err := json.Marshal(federationConsentsObject{ConsumerTenants: updated})
+		if err != nil {
+			return nil, err
+		}
+		if err := c.bucket.Upload(ctx, objectName, bytes.NewReader(data)); err != nil {
+			return nil, errors.Wrapf(err, "failed to upload the federation consents object %q", objectName)
+		}
+	}
+
+	c.cache[sourceTenant] = cachedFederationConsents{consumerTenants: updated, fetchedAt: time.Now()}
+	return updated, nil
+}
+
+func (c *FederationConsents) read(ctx context.Context, sourceTenant string) ([]string, error) {
+	objectName := path.Join(federationConsentsPrefix, sourceTenant)
+	r, err := c.bucket.Get(ctx, objectName)
+	if c.bucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the federation consents object %q", objectName)
+	}
+	defer func() { _ = r.Close() }()
+
+	var obj federationConsentsObject
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the federation consents object %q", objectName)
+	}
+	return obj.ConsumerTenants, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSourceTenantConsentsQueryable wraps the queryable used to fetch the series of each source tenant of the
+// federated rule groups, failing the queries of the source tenants which haven't granted their consent to the
+// tenant owning the rule group.
+func NewSourceTenantConsentsQueryable(q storage.Queryable, consents *FederationConsents) storage.Queryable {
+	return &sourceTenantConsentsQueryable{Queryable: q, consents: consents}
+}
+
+type sourceTenantConsentsQueryable struct {
+	storage.Queryable
+	consents *FederationConsents
+}
+
+func (q *sourceTenantConsentsQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	if sourceTenant, ok := federatedSourceTenant(ctx); ok {
+		if err := q.consents.Check(ctx, evaluatedRuleGroupDesc(ctx).GetUser(), []string{sourceTenant}); err != nil {
+			return nil, err
+		}
+	}
+	return q.Queryable.Querier(ctx, mint, maxt)
+}
+
+// FederationConsentsDiscovery has the tenants a source tenant consents to federate its series to.
+type FederationConsentsDiscovery struct {
+	ConsumerTenants []string `json:"consumer_tenants"`
+}
+
+// GetFederationConsents returns the tenants the tenant consents to federate its series to.
+func (a *API) GetFederationConsents(w http.ResponseWriter, req *http.Request) {
+	a.handleFederationConsents(w, req, func(ctx context.Context, userID string) ([]string, error) {
+		return a.ruler.federationConsents.ConsumerTenants(ctx, userID)
+	})
+}
+
+// GrantFederationConsents grants the consent of the tenant to federate its series to the tenants of the request.
+func (a *API) GrantFederationConsents(w http.ResponseWriter, req *http.Request) {
+	a.handleFederationConsents(w, req, func(ctx context.Context, userID string) ([]string, error) {
+		if !authorizedAllNamespaces(ctx) {
+			return nil, httpError{err: errTenantNotAuthorized, status: http.StatusForbidden}
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, httpError{err: err, status: http.StatusBadRequest}
+		}
+		var consents FederationConsentsDiscovery
+		if err := json.Unmarshal(body, &consents); err != nil {
+			return nil, httpError{err: errors.Wrap(err, "invalid request body"), status: http.StatusBadRequest}
+		}
+		if len(consents.ConsumerTenants) == 0 {
+			return nil, httpError{err: errors.New("no consumer tenants in the request body"), status: http.StatusBadRequest}
+		}
+		for _, consumerTenant := range consents.ConsumerTenants {
+			if err := validateConsumerTenant(consumerTenant); err != nil {
+				return nil, httpError{err: err, status: http.StatusBadRequest}
+			}
+		}
+		return a.ruler.federationConsents.Grant(ctx, userID, consents.ConsumerTenants)
+	})
+}
+
+// RevokeFederationConsent revokes the consent of the tenant to federate its series to the tenant of the request.
+func (a *API) RevokeFederationConsent(w http.ResponseWriter, req *http.Request) {
+	a.handleFederationConsents(w, req, func(ctx context.Context, userID string) ([]string, error) {
+		if !authorizedAllNamespaces(ctx) {
+			return nil, httpError{err: errTenantNotAuthorized, status: http.StatusForbidden}
+		}
+		consumerTenant := mux.Vars(req)["consumerTenant"]
+		if err := validateConsumerTenant(consumerTenant); err != nil {
+			return nil, httpError{err: err, status: http.StatusBadRequest}
+		}
+		return a.ruler.federationConsents.Revoke(ctx, userID, consumerTenant)
+	})
+}
+
+func validateConsumerTenant(consumerTenant string) error {
+	if consumerTenant == "" {
+		return errors.New("empty consumer tenant")
+	}
+	if err := tenant.ValidTenantID(consumerTenant); err != nil {
+		return errors.Wrapf(err, "invalid consumer tenant %q", consumerTenant)
+	}
+	return nil
+}
+
+// httpError is an error replied with its status code.
+type httpError struct {
+	err    error
+	status int
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+
+// handleFederationConsents replies with the consents of the tenant returned by fn.
+func (a *API) handleFederationConsents(w http.ResponseWriter, req *http.Request, fn func(ctx context.Context, userID string) ([]string, error)) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondError(logger, w, "no valid org id found")
+		return
+	}
+
+	if a.ruler.federationConsents == nil {
+		http.Error(w, errFederationConsentsDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	consumerTenants, err := fn(req.Context(), userID)
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		http.Error(w, httpErr.Error(), httpErr.status)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to access the federation consents", "err", err, "user", userID)
+		respondError(logger, w, err.Error())
+		return
+	}
+	if consumerTenants == nil {
+		consumerTenants = []string{}
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   &FederationConsentsDiscovery{ConsumerTenants: consumerTenants},
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
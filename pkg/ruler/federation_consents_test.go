@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/querier/tenantfederation"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestFederationConsents(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	consents := NewFederationConsents(bucket)
+
+	require.NoError(t, consents.Check(ctx, "tenant-1", []string{"tenant-1"}))
+	require.ErrorAs(t, consents.Check(ctx, "tenant-1", []string{"tenant-1", "tenant-2"}), new(*FederationConsentError))
+
+	consumerTenants, err := consents.Grant(ctx, "tenant-2", []string{"tenant-3", "tenant-1", "tenant-3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant-1", "tenant-3"}, consumerTenants)
+	require.NoError(t, consents.Check(ctx, "tenant-1", []string{"tenant-1", "tenant-2"}))
+
+	// The consents granted through another replica apply once the cached consents expire.
+	other := NewFederationConsents(bucket)
+	require.NoError(t, other.Check(ctx, "tenant-1", []string{"tenant-2"}))
+	_, err = consents.Revoke(ctx, "tenant-2", "tenant-1")
+	require.NoError(t, err)
+	require.NoError(t, other.Check(ctx, "tenant-1", []string{"tenant-2"}))
+
+	prevTTL := federationConsentsCacheTTL
+	federationConsentsCacheTTL = 0
+	t.Cleanup(func() { federationConsentsCacheTTL = prevTTL })
+	require.ErrorAs(t, other.Check(ctx, "tenant-1", []string{"tenant-2"}), new(*FederationConsentError))
+
+	// The object is deleted once all the consents are revoked.
+	_, err = consents.Revoke(ctx, "tenant-2", "tenant-3")
+	require.NoError(t, err)
+	exists, err := bucket.Exists(ctx, "federation-consents/tenant-2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestAPI_FederationConsents(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	newRouter := func(consents *FederationConsents) *mux.Router {
+		r.federationConsents = consents
+		a := NewAPI(r, r.store, log.NewNopLogger())
+
+		router := mux.NewRouter()
+		router.Path("/api/v1/federation/consents").Methods(http.MethodGet).HandlerFunc(a.GetFederationConsents)
+		router.Path("/api/v1/federation/consents").Methods(http.MethodPost).HandlerFunc(a.GrantFederationConsents)
+		router.Path("/api/v1/federation/consents/{consumerTenant}").Methods(http.MethodDelete).HandlerFunc(a.RevokeFederationConsent)
+		router.Path("/config/v1/rules/{namespace}").Methods(http.MethodPost).HandlerFunc(a.CreateRuleGroup)
+		return router
+	}
+	do := func(router *mux.Router, method, path, body, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, method, "https://localhost:8080"+path, strings.NewReader(body), userID))
+		return w
+	}
+	consumerTenants := func(w *httptest.ResponseRecorder) []string {
+		var resp struct {
+			Data FederationConsentsDiscovery `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.ConsumerTenants
+	}
+	const federatedGroup = `
+name: federated
+source_tenants: [user1, user2]
+rules:
+- record: up:sum
+  expr: sum(up)
+`
+
+	t.Run("consents disabled", func(t *testing.T) {
+		router := newRouter(nil)
+		assert.Equal(t, http.StatusNotImplemented, do(router, http.MethodGet, "/api/v1/federation/consents", "", "user2").Code)
+		assert.Equal(t, http.StatusNotImplemented, do(router, http.MethodPost, "/api/v1/federation/consents", `{"consumer_tenants": ["user1"]}`, "user2").Code)
+		assert.Equal(t, http.StatusAccepted, do(router, http.MethodPost, "/config/v1/rules/namespace", federatedGroup, "user1").Code)
+	})
+
+	router := newRouter(NewFederationConsents(objstore.NewInMemBucket()))
+
+	t.Run("federated rule groups are rejected without the consents of their source tenants", func(t *testing.T) {
+		w := do(router, http.MethodPost, "/config/v1/rules/namespace", federatedGroup, "user1")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "the source tenant user2 has not granted its consent to federate its series to the tenant user1")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do(router, http.MethodPost, "/api/v1/federation/consents", `{"consumer_tenants": []}`, "user2").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, http.MethodPost, "/api/v1/federation/consents", `{"consumer_tenants": ["user1", ""]}`, "user2").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, http.MethodPost, "/api/v1/federation/consents", `consumer_tenants`, "user2").Code)
+		assert.Equal(t, http.StatusBadRequest, do(router, http.MethodDelete, "/api/v1/federation/consents/user1%23", "", "user2").Code)
+	})
+
+	t.Run("grant a consent", func(t *testing.T) {
+		w := do(router, http.MethodPost, "/api/v1/federation/consents", `{"consumer_tenants": ["user1", "user3"]}`, "user2")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"user1", "user3"}, consumerTenants(w))
+
+		w = do(router, http.MethodGet, "/api/v1/federation/consents", "", "user2")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"user1", "user3"}, consumerTenants(w))
+
+		assert.Equal(t, http.StatusAccepted, do(router, http.MethodPost, "/config/v1/rules/namespace", federatedGroup, "user1").Code)
+	})
+
+	t.Run("revoke a consent", func(t *testing.T) {
+		w := do(router, http.MethodDelete, "/api/v1/federation/consents/user1", "", "user2")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"user3"}, consumerTenants(w))
+
+		assert.Equal(t, http.StatusBadRequest, do(router, http.MethodPost, "/config/v1/rules/namespace", federatedGroup, "user1").Code)
+	})
+}
+
+func TestSourceTenantConsentsQueryable(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	upstream := teststorage.New(t)
+	t.Cleanup(func() { _ = upstream.Close() })
+	app := upstream.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings(labels.MetricName, "up"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	consents := NewFederationConsents(objstore.NewInMemBucket())
+	eng := promql.NewEngine(promql.EngineOpts{Logger: log.NewNopLogger(), MaxSamples: 1e6, Timeout: time.Minute})
+	queryFunc := rules.EngineQueryFunc(eng, tenantfederation.NewQueryable(NewSourceTenantConsentsQueryable(upstream, consents), false, log.NewNopLogger()))
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{{User: "tenant-1", Namespace: "namespace", Name: "group", SourceTenants: []string{"tenant-1", "tenant-2"}}})
+	ctx := user.InjectOrgID(context.Background(), "tenant-1|tenant-2")
+	ctx = context.WithValue(ctx, tenantRuleGroups, registry)
+	ctx = context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace", name: "group"})
+	ctx = context.WithValue(ctx, federatedGroupSourceTenants, []string{"tenant-1", "tenant-2"})
+
+	// The source tenants other than the tenant owning the rule group must grant their consent.
+	_, err = queryFunc(ctx, "count(up)", time.Unix(1, 0))
+	require.ErrorAs(t, err, new(*FederationConsentError))
+
+	_, err = consents.Grant(context.Background(), "tenant-2", []string{"tenant-1"})
+	require.NoError(t, err)
+	vector, err := queryFunc(ctx, "count(up)", time.Unix(1, 0))
+	require.NoError(t, err)
+	require.Len(t, vector, 1)
+	assert.Equal(t, float64(2), vector[0].V)
+
+	// The queries of the non-federated rule groups aren't checked.
+	_, err = consents.Revoke(context.Background(), "tenant-2", "tenant-1")
+	require.NoError(t, err)
+	_, err = queryFunc(user.InjectOrgID(context.Background(), "tenant-2"), "count(up)", time.Unix(1, 0))
+	require.NoError(t, err)
+}
+
+func TestAPI_FederationConsents_AuthorizeToken(t *testing.T) {
+	validator, err := NewFileTokenValidator(writeTokensFile(t, `tokens:
+- {token: writer, tenant: user2, verbs: [read, write]}
+- {token: reader, tenant: user2, verbs: [read]}
+- {token: team-a-writer, tenant: user2, namespaces: [team-a], verbs: [read, write]}
+`))
+	require.NoError(t, err)
+
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	r.federationConsents = NewFederationConsents(objstore.NewInMemBucket())
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	a.SetTokenValidator(validator)
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/federation/consents").Methods(http.MethodGet).Handler(a.AuthorizeToken(a.AdminOverride(a.GetFederationConsents)))
+	router.Path("/api/v1/federation/consents").Methods(http.MethodPost).Handler(a.AuthorizeToken(a.AdminOverride(a.RateLimitWrites(a.GrantFederationConsents))))
+	router.Path("/api/v1/federation/consents/{consumerTenant}").Methods(http.MethodDelete).Handler(a.AuthorizeToken(a.AdminOverride(a.RateLimitWrites(a.RevokeFederationConsent))))
+
+	do := func(method, path, body, token string) int {
+		req := requestFor(t, method, "https://localhost:8080"+path, strings.NewReader(body), "user2")
+		if token != "" {
+			req.Header.Set(rulesTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	const grant = `{"consumer_tenants": ["user1"]}`
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/federation/consents", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/v1/federation/consents", grant, ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodDelete, "/api/v1/federation/consents/user1", "", ""))
+
+	// The consents can't be granted or revoked without the write verb, nor with a token scoped to some namespaces.
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/federation/consents", grant, "reader"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/federation/consents", grant, "team-a-writer"))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/federation/consents", grant, "writer"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/federation/consents", "", "reader"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/api/v1/federation/consents/user1", "", "reader"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/api/v1/federation/consents/user1", "", "team-a-writer"))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/federation/consents/user1", "", "writer"))
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const (
+	evaluatedRuleGroup contextKey = 2
+	tenantRuleGroups   contextKey = 3
+)
+
+// ruleGroupInfo identifies the rule group being evaluated.
+type ruleGroupInfo struct {
+	namespace string
+	name      string
+
+	// rulesByQuery maps the expression of each rule in the group to the names of the rules
+	// evaluating it, so that the queries run by the evaluation can be attributed to their rule.
+	rulesByQuery map[string][]string
+}
+
+// ruleNames returns the names of the rules of the group evaluating the query qs.
+func (g ruleGroupInfo) ruleNames(qs string) []string {
+	return g.rulesByQuery[qs]
+}
+
+// RuleGroupContextFunc injects information about g in to the context, so that it can be attached
+// to the spans and the logs of the rule evaluations.
+func RuleGroupContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	// The group file is the namespace path escaped by the mapper.
+	namespace, err := url.PathUnescape(filepath.Base(g.File()))
+	if err != nil {
+		namespace = filepath.Base(g.File())
+	}
+
+	rulesByQuery := make(map[string][]string, len(g.Rules()))
+	for _, r := range g.Rules() {
+		qs := r.Query().String()
+		rulesByQuery[qs] = append(rulesByQuery[qs], r.Name())
+	}
+	return context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: namespace, name: g.Name(), rulesByQuery: rulesByQuery})
+}
+
+// ChainGroupEvaluationContextFuncs returns a rules.ContextWrapFunc which calls all fns in order.
+func ChainGroupEvaluationContextFuncs(fns ...rules.ContextWrapFunc) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		for _, fn := range fns {
+			ctx = fn(ctx, g)
+		}
+		return ctx
+	}
+}
+
+// ruleGroupsRegistry holds the rule groups of a tenant as stored in the rule store, so that the
+// Mimir specific fields of a rule group, which are not part of the rule files loaded by the
+// Prometheus rules manager, can be looked up while evaluating it.
+type ruleGroupsRegistry struct {
+	mtx    sync.RWMutex
+	groups map[string]map[string]*rulespb.RuleGroupDesc // By namespace and name.
+}
+
+func newRuleGroupsRegistry() *ruleGroupsRegistry {
+	return &ruleGroupsRegistry{groups: map[string]map[string]*rulespb.RuleGroupDesc{}}
+}
+
+func (r *ruleGroupsRegistry) set(groups rulespb.RuleGroupList) {
+	byNamespace := make(map[string]map[string]*rulespb.RuleGroupDesc, len(groups))
+	for _, g := range groups {
+		if byNamespace[g.Namespace] == nil {
+			byNamespace[g.Namespace] = map[string]*rulespb.RuleGroupDesc{}
+		}
+		byNamespace[g.Namespace][g.Name] = g
+	}
+
+	r.mtx.Lock()
+	r.groups = byNamespace
+	r.mtx.Unlock()
+}
+
+// changes returns the number of rule groups added, removed and updated by groups, compared to the rule
+// groups of the registry.
+func (r *ruleGroupsRegistry) changes(groups rulespb.RuleGroupList) (added, removed, updated int) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	kept := 0
+	for _, g := range groups {
+		current, ok := r.groups[g.Namespace][g.Name]
+		switch {
+		case !ok:
+			added++
+		case !current.Equal(g):
+			updated++
+			kept++
+		default:
+			kept++
+		}
+	}
+	for _, namespace := range r.groups {
+		removed += len(namespace)
+	}
+	return added, removed - kept, updated
+}
+
+func (r *ruleGroupsRegistry) get(namespace, name string) *rulespb.RuleGroupDesc {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.groups[namespace][name]
+}
+
+// evaluatedRuleGroupDesc returns the rule group being evaluated, as stored in the rule store,
+// or nil if unknown.
+func evaluatedRuleGroupDesc(ctx context.Context) *rulespb.RuleGroupDesc {
+	registry, _ := ctx.Value(tenantRuleGroups).(*ruleGroupsRegistry)
+	g, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo)
+	if registry == nil || !ok {
+		return nil
+	}
+	return registry.get(g.namespace, g.name)
+}
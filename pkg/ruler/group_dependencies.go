@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const (
+	groupDependenciesWaiterKey contextKey = 5
+	tenantEvaluatingGroups     contextKey = 17
+)
+
+// How frequently the completion of the evaluation of the dependencies of a rule group is checked.
+var groupDependenciesPollInterval = 100 * time.Millisecond
+
+// parseGroupDependency parses a rule group dependency in the namespace/group format.
+func parseGroupDependency(dep string) (namespace, group string, err error) {
+	idx := strings.Index(dep, "/")
+	if idx <= 0 || idx == len(dep)-1 {
+		return "", "", fmt.Errorf("invalid rule group dependency %q, must be in the namespace/group format", dep)
+	}
+	return dep[:idx], dep[idx+1:], nil
+}
+
+// validateGroupDependencies validates the dependencies of the rule group rg of the namespace.
+func validateGroupDependencies(namespace string, rg rulespb.RuleGroup) error {
+	for _, dep := range rg.DependsOn {
+		depNamespace, depGroup, err := parseGroupDependency(dep)
+		if err != nil {
+			return err
+		}
+		if depNamespace == namespace && depGroup == rg.Name {
+			return errors.Errorf("invalid rule group dependency %q, a rule group can't depend on itself", dep)
+		}
+	}
+	return nil
+}
+
+// evaluatingGroups tracks the rule groups being evaluated by the rules manager of a tenant, by file and name.
+// The groups are added when they start, and are removed with retain once the rules manager stopped them.
+type evaluatingGroups struct {
+	mtx    sync.RWMutex
+	groups map[string]evaluatingGroup
+}
+
+type evaluatingGroup struct {
+	*rules.Group
+
+	// Expected timestamp of the first evaluation of the group.
+	firstEvaluation time.Time
+}
+
+func newEvaluatingGroups() *evaluatingGroups {
+	return &evaluatingGroups{groups: map[string]evaluatingGroup{}}
+}
+
+func (e *evaluatingGroups) add(g *rules.Group) {
+	// The rules manager evaluates a new group for the first time at the evaluation timestamp following its start.
+	first := g.EvalTimestamp(time.Now().UnixNano()).Add(g.Interval())
+
+	e.mtx.Lock()
+	e.groups[rules.GroupKey(g.File(), g.Name())] = evaluatingGroup{Group: g, firstEvaluation: first}
+	e.mtx.Unlock()
+}
+
+func (e *evaluatingGroups) get(file, name string) (evaluatingGroup, bool) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	g, ok := e.groups[rules.GroupKey(file, name)]
+	return g, ok
+}
+
+// running returns whether g is still evaluated, that is it hasn't been removed or replaced by another group.
+func (e *evaluatingGroups) running(g *rules.Group) bool {
+	current, ok := e.get(g.File(), g.Name())
+	return ok && current.Group == g
+}
+
+// retain removes the rule groups not in groups, which have been stopped by the rules manager.
+func (e *evaluatingGroups) retain(groups []*rules.Group) {
+	keep := make(map[*rules.Group]struct{}, len(groups))
+	for _, g := range groups {
+		keep[g] = struct{}{}
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for key, g := range e.groups {
+		if _, ok := keep[g.Group]; !ok {
+			delete(e.groups, key)
+		}
+	}
+}
+
+// GroupDependenciesContextFunc is a rules.ContextWrapFunc injecting in the context of each rule group a waiter
+// used by GroupDependenciesQueryFunc to wait for the evaluation of its dependencies, among the rule groups of
+// the tenant tracked in the context.
+func GroupDependenciesContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	groups, ok := ctx.Value(tenantEvaluatingGroups).(*evaluatingGroups)
+	if !ok {
+		return ctx
+	}
+
+	groups.add(g)
+	return context.WithValue(ctx, groupDependenciesWaiterKey, &groupDependenciesWaiter{group: g, groups: groups})
+}
+
+// GroupDependenciesQueryFunc returns a rules.QueryFunc which, at the start of each evaluation of a rule
+// group with dependencies, waits for the completion of the evaluation of its dependencies, and runs the
+// queries of the evaluation as of when the wait ended, so that they read the latest results of the dependencies.
+func GroupDependenciesQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if w, ok := ctx.Value(groupDependenciesWaiterKey).(*groupDependenciesWaiter); ok {
+			t = w.queryTime(ctx, t)
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+type groupDependenciesWaiter struct {
+	group  *rules.Group
+	groups *evaluatingGroups
+
+	mtx sync.Mutex
+	// Evaluation timestamp of the current evaluation.
+	ts time.Time
+	// How long the current evaluation waited for the dependencies, delaying its queries.
+	delay time.Duration
+}
+
+func (w *groupDependenciesWaiter) queryTime(ctx context.Context, t time.Time) time.Time {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if !t.Equal(w.ts) {
+		// First query of a new evaluation of the group.
+		w.ts = t
+		w.delay = w.wait(ctx, evaluatedRuleGroupDesc(ctx).GetDependsOn())
+	}
+	return t.Add(w.delay)
+}
+
+// wait waits for the completion of the evaluation of the dependencies of the rule group, and returns
+// for how long it waited. The dependencies not evaluated by this ruler, or not anymore, are ignored.
+// The wait never lasts beyond the expected start of the next evaluation of the rule group.
+func (w *groupDependenciesWaiter) wait(ctx context.Context, dependsOn []string) time.Duration {
+	if len(dependsOn) == 0 {
+		return 0
+	}
+
+	// The duration of the previous evaluation includes the previous wait.
+	evaluationTime := w.group.GetEvaluationTime() - w.delay
+	if evaluationTime < 0 {
+		evaluationTime = 0
+	}
+	start := time.Now()
+	deadline := start.Add(w.group.Interval() - evaluationTime)
+
+	var targets []dependencyEvaluation
+	for _, dep := range dependsOn {
+		namespace, name, err := parseGroupDependency(dep)
+		if err != nil {
+			continue
+		}
+
+		// The rule files are mapped to the same directory, named after the escaped namespace.
+		file := filepath.Join(filepath.Dir(w.group.File()), url.PathEscape(namespace))
+		g, ok := w.groups.get(file, name)
+		if !ok || g.Group == w.group || !w.groups.running(g.Group) {
+			continue
+		}
+
+		// Wait for the latest evaluation of the dependency if it's still running. Otherwise, wait for the
+		// next one if it's expected to complete before the next evaluation of the rule group, which can't
+		// be known until the dependency has been evaluated once.
+		next := g.EvalTimestamp(start.UnixNano())
+		if next.Before(g.firstEvaluation) {
+			next = g.firstEvaluation
+		}
+		lastEvaluation := g.GetLastEvaluation()
+		if !lastEvaluation.Before(next) {
+			next = next.Add(g.Interval())
+		}
+		if next.After(start) && (lastEvaluation.IsZero() || next.Add(g.GetEvaluationTime()).After(deadline)) {
+			continue
+		}
+		targets = append(targets, dependencyEvaluation{group: g.Group, start: next})
+	}
+
+	if len(targets) == 0 {
+		return 0
+	}
+
+	for _, target := range targets {
+		for target.group.GetLastEvaluation().Before(target.start) && time.Now().Before(deadline) && w.groups.running(target.group) {
+			select {
+			case <-ctx.Done():
+				return time.Since(start)
+			case <-time.After(groupDependenciesPollInterval):
+			}
+		}
+	}
+	return time.Since(start)
+}
+
+// dependencyEvaluation is the evaluation of a rule group dependency starting at start.
+type dependencyEvaluation struct {
+	group *rules.Group
+	start time.Time
+}
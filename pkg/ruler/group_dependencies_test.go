@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestValidateGroupDependencies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		dependsOn []string
+		expectErr bool
+	}{
+		"no dependencies":             {},
+		"valid dependencies":          {dependsOn: []string{"ns/other", "other-ns/group", "ns/with/slash"}},
+		"missing namespace":           {dependsOn: []string{"/group"}, expectErr: true},
+		"missing group":               {dependsOn: []string{"ns/"}, expectErr: true},
+		"missing namespace separator": {dependsOn: []string{"group"}, expectErr: true},
+		"depends on itself":           {dependsOn: []string{"ns/group"}, expectErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := validateGroupDependencies("ns", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}, DependsOn: tc.dependsOn})
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGroupDependenciesQueryFunc(t *testing.T) {
+	prevPollInterval := groupDependenciesPollInterval
+	groupDependenciesPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { groupDependenciesPollInterval = prevPollInterval })
+
+	dir := filepath.Join(t.TempDir(), "user-1")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	file := filepath.Join(dir, "ns")
+	require.NoError(t, os.WriteFile(file, []byte(`
+groups:
+  - name: upstream
+    interval: 1s
+    rules:
+      - record: upstream
+        expr: vector(1)
+  - name: downstream
+    interval: 1s
+    rules:
+      - record: downstream
+        expr: upstream
+`), 0o644))
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{
+		{Namespace: "ns", Name: "upstream"},
+		{Namespace: "ns", Name: "downstream", DependsOn: []string{"ns/upstream"}},
+	})
+
+	var (
+		mtx             sync.Mutex
+		upstreamRunning bool
+		upstreamLastT   time.Time
+		downstreamRuns  int
+	)
+	qf := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		switch qs {
+		case "vector(1)":
+			mtx.Lock()
+			upstreamRunning = true
+			upstreamLastT = ts
+			mtx.Unlock()
+
+			time.Sleep(300 * time.Millisecond)
+
+			mtx.Lock()
+			upstreamRunning = false
+			mtx.Unlock()
+		case "upstream":
+			mtx.Lock()
+			// The downstream group reads the latest results of the upstream group.
+			assert.False(t, upstreamRunning, "the downstream group has been evaluated while the upstream group was being evaluated")
+			assert.False(t, ts.Before(upstreamLastT), "the downstream group queried older data than the latest results of the upstream group")
+			downstreamRuns++
+			mtx.Unlock()
+		}
+		return promql.Vector{}, nil
+	}
+
+	manager := rules.NewManager(&rules.ManagerOptions{
+		Appendable: NewPusherAppendable(nopPusher{}, "user-1", nil, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+		Queryable: storage.QueryableFunc(func(context.Context, int64, int64) (storage.Querier, error) {
+			return storage.NoopQuerier(), nil
+		}),
+		QueryFunc:  GroupDependenciesQueryFunc(qf),
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+		Context:    context.WithValue(context.WithValue(context.Background(), tenantRuleGroups, registry), tenantEvaluatingGroups, newEvaluatingGroups()),
+		GroupEvaluationContextFunc: ChainGroupEvaluationContextFuncs(
+			RuleGroupContextFunc,
+			GroupDependenciesContextFunc,
+		),
+		Logger: log.NewNopLogger(),
+	})
+	go manager.Run()
+	t.Cleanup(manager.Stop)
+	require.NoError(t, manager.Update(time.Second, []string{file}, nil, ""))
+
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return downstreamRuns >= 4
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestGroupDependenciesQueryFunc_RemovedDependency(t *testing.T) {
+	prevPollInterval := groupDependenciesPollInterval
+	groupDependenciesPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { groupDependenciesPollInterval = prevPollInterval })
+
+	dir := filepath.Join(t.TempDir(), "user-1")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	downstreamFile := filepath.Join(dir, "downstream")
+	require.NoError(t, os.WriteFile(downstreamFile, []byte(`
+groups:
+  - name: downstream
+    interval: 1s
+    rules:
+      - record: downstream
+        expr: upstream
+`), 0o644))
+	upstreamFile := filepath.Join(dir, "upstream")
+	require.NoError(t, os.WriteFile(upstreamFile, []byte(`
+groups:
+  - name: upstream
+    interval: 1s
+    rules:
+      - record: upstream
+        expr: vector(1)
+`), 0o644))
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{
+		{Namespace: "upstream", Name: "upstream"},
+		{Namespace: "downstream", Name: "downstream", DependsOn: []string{"upstream/upstream"}},
+	})
+
+	var (
+		mtx              sync.Mutex
+		removed          time.Time
+		downstreamDelays []time.Duration
+	)
+	groups := newEvaluatingGroups()
+	qf := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		switch qs {
+		case "vector(1)":
+			time.Sleep(300 * time.Millisecond)
+		case "upstream":
+			g, ok := groups.get(downstreamFile, "downstream")
+			require.True(t, ok)
+
+			// Only track the evaluations which started after the removal of the upstream group.
+			evaluation := g.EvalTimestamp(ts.UnixNano())
+			mtx.Lock()
+			if !removed.IsZero() && evaluation.After(removed) {
+				downstreamDelays = append(downstreamDelays, ts.Sub(evaluation))
+			}
+			mtx.Unlock()
+		}
+		return promql.Vector{}, nil
+	}
+
+	manager := rules.NewManager(&rules.ManagerOptions{
+		Appendable: NewPusherAppendable(nopPusher{}, "user-1", nil, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+		Queryable: storage.QueryableFunc(func(context.Context, int64, int64) (storage.Querier, error) {
+			return storage.NoopQuerier(), nil
+		}),
+		QueryFunc:  GroupDependenciesQueryFunc(qf),
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+		Context:    context.WithValue(context.WithValue(context.Background(), tenantRuleGroups, registry), tenantEvaluatingGroups, groups),
+		GroupEvaluationContextFunc: ChainGroupEvaluationContextFuncs(
+			RuleGroupContextFunc,
+			GroupDependenciesContextFunc,
+		),
+		Logger: log.NewNopLogger(),
+	})
+	go manager.Run()
+	t.Cleanup(manager.Stop)
+	require.NoError(t, manager.Update(time.Second, []string{downstreamFile, upstreamFile}, nil, ""))
+
+	// Wait for the upstream group to be evaluated, so that the downstream group waits for it.
+	var upstream evaluatingGroup
+	require.Eventually(t, func() bool {
+		var ok bool
+		upstream, ok = groups.get(upstreamFile, "upstream")
+		return ok && !upstream.GetLastEvaluation().IsZero()
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.True(t, groups.running(upstream.Group))
+
+	// Remove the upstream group, while the downstream group still depends on it.
+	require.NoError(t, manager.Update(time.Second, []string{downstreamFile}, nil, ""))
+	groups.retain(manager.RuleGroups())
+
+	_, ok := groups.get(upstreamFile, "upstream")
+	assert.False(t, ok, "the removed rule group is still tracked")
+	assert.False(t, groups.running(upstream.Group))
+
+	mtx.Lock()
+	removed = time.Now()
+	mtx.Unlock()
+
+	// The evaluations of the downstream group don't wait for the removed group anymore.
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(downstreamDelays) >= 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	for _, delay := range downstreamDelays {
+		assert.Less(t, delay, 100*time.Millisecond)
+	}
+}
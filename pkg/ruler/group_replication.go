@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const followedRuleGroupsKey contextKey = 14
+
+type followedRuleGroup struct {
+	user      string
+	namespace string
+	name      string
+}
+
+// followedRuleGroups holds the rule groups whose leader is another ruler, when the rule groups are
+// replicated. The replicas evaluate the rule groups to take over without losing the state of their alerts
+// when the leader changes, but only the leader writes their results and sends their alerts.
+type followedRuleGroups struct {
+	mtx    sync.RWMutex
+	groups map[followedRuleGroup]struct{}
+}
+
+func newFollowedRuleGroups() *followedRuleGroups {
+	return &followedRuleGroups{groups: map[followedRuleGroup]struct{}{}}
+}
+
+func (f *followedRuleGroups) set(followed map[string]rulespb.RuleGroupList) {
+	groups := map[followedRuleGroup]struct{}{}
+	for userID, list := range followed {
+		for _, g := range list {
+			groups[followedRuleGroup{user: userID, namespace: g.Namespace, name: g.Name}] = struct{}{}
+		}
+	}
+
+	f.mtx.Lock()
+	f.groups = groups
+	f.mtx.Unlock()
+}
+
+func (f *followedRuleGroups) contains(userID, namespace, name string) bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	_, ok := f.groups[followedRuleGroup{user: userID, namespace: namespace, name: name}]
+	return ok
+}
+
+// isFollowedRuleGroup returns whether the leader of the rule group being evaluated is another ruler.
+func isFollowedRuleGroup(ctx context.Context) bool {
+	followed, _ := ctx.Value(followedRuleGroupsKey).(*followedRuleGroups)
+	g := evaluatedRuleGroupDesc(ctx)
+	if followed == nil || g == nil {
+		return false
+	}
+	return followed.contains(g.User, g.Namespace, g.Name)
+}
+
+// ReplicatedAppendable returns a storage.Appendable which discards the samples of the rule groups whose
+// leader is another ruler, and appends the others to appendable.
+func ReplicatedAppendable(appendable storage.Appendable, discarded prometheus.Counter) storage.Appendable {
+	return &replicatedAppendable{appendable: appendable, discarded: discarded}
+}
+
+type replicatedAppendable struct {
+	appendable storage.Appendable
+	discarded  prometheus.Counter
+}
+
+func (a *replicatedAppendable) Appender(ctx context.Context) storage.Appender {
+	if isFollowedRuleGroup(ctx) {
+		return &discardingAppender{discarded: a.discarded}
+	}
+	return a.appendable.Appender(ctx)
+}
+
+// discardingAppender is a storage.Appender which discards the appended samples, counting them if discarded isn't nil.
+type discardingAppender struct {
+	discarded prometheus.Counter
+}
+
+func (a *discardingAppender) Append(_ storage.SeriesRef, _ labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	if a.discarded != nil {
+		a.discarded.Inc()
+	}
+	return 0, nil
+}
+
+func (a *discardingAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *discardingAppender) Commit() error {
+	return nil
+}
+
+func (a *discardingAppender) Rollback() error {
+	return nil
+}
+
+// ReplicatedNotifyFunc returns a rules.NotifyFunc which doesn't send the alerts of the rule groups whose
+// leader is another ruler.
+func ReplicatedNotifyFunc(notify rules.NotifyFunc, skipped prometheus.Counter) rules.NotifyFunc {
+	return func(ctx context.Context, expr string, alerts ...*rules.Alert) {
+		if isFollowedRuleGroup(ctx) {
+			skipped.Add(float64(len(alerts)))
+			return
+		}
+		notify(ctx, expr, alerts...)
+	}
+}
+
+// dedupeReplicatedGroupStates returns the states of the rule groups with a single state for each rule group
+// evaluated by several rulers, the one of the latest evaluation.
+func dedupeReplicatedGroupStates(states []*GroupStateDesc) []*GroupStateDesc {
+	type groupKey struct {
+		namespace string
+		name      string
+	}
+
+	deduped := make([]*GroupStateDesc, 0, len(states))
+	indexes := make(map[groupKey]int, len(states))
+	for _, state := range states {
+		key := groupKey{namespace: state.Group.GetNamespace(), name: state.Group.GetName()}
+		i, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(deduped)
+			deduped = append(deduped, state)
+			continue
+		}
+		if state.EvaluationTimestamp.After(deduped[i].EvaluationTimestamp) {
+			deduped[i] = state
+		}
+	}
+	return deduped
+}
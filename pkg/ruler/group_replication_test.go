@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestReplicatedAppendableAndNotifyFunc(t *testing.T) {
+	led := &rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "led"}
+	followed := &rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "followed"}
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{led, followed})
+	followedGroups := newFollowedRuleGroups()
+	followedGroups.set(map[string]rulespb.RuleGroupList{"user1": {followed}})
+
+	groupCtx := func(g *rulespb.RuleGroupDesc) context.Context {
+		ctx := context.WithValue(context.Background(), tenantRuleGroups, registry)
+		ctx = context.WithValue(ctx, followedRuleGroupsKey, followedGroups)
+		return context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: g.Namespace, name: g.Name})
+	}
+
+	pusher := &fakePusher{}
+	discarded := prometheus.NewCounter(prometheus.CounterOpts{})
+	appendable := ReplicatedAppendable(NewPusherAppendable(pusher, "user1", nil, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})), discarded)
+
+	notified := 0
+	skipped := prometheus.NewCounter(prometheus.CounterOpts{})
+	notify := ReplicatedNotifyFunc(func(_ context.Context, _ string, alerts ...*rules.Alert) {
+		notified += len(alerts)
+	}, skipped)
+
+	for _, g := range []*rulespb.RuleGroupDesc{led, followed} {
+		ctx := groupCtx(g)
+		app := appendable.Appender(ctx)
+		_, err := app.Append(0, labels.FromStrings("__name__", "test"), 0, 1)
+		require.NoError(t, err)
+		require.NoError(t, app.Commit())
+		notify(ctx, "up", &rules.Alert{}, &rules.Alert{})
+	}
+
+	// Only the samples and the alerts of the rule group led by the ruler are sent.
+	require.NotNil(t, pusher.request)
+	assert.Len(t, pusher.request.Timeseries, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(discarded))
+	assert.Equal(t, 2, notified)
+	assert.Equal(t, 2.0, testutil.ToFloat64(skipped))
+}
+
+func TestRuler_ReplicatedRuleGroups(t *testing.T) {
+	const replicationFactor = 2
+
+	groups := rulespb.RuleGroupList{
+		&rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "first", Interval: 10 * time.Second},
+		&rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "second", Interval: 10 * time.Second},
+		&rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "third", Interval: 10 * time.Second},
+	}
+
+	kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{"user1": groups})
+	rulerAddrMap := map[string]*Ruler{}
+
+	// Each ruler owns the token of one rule group.
+	rulerIDs := []string{"ruler1", "ruler2", "ruler3"}
+	for _, id := range rulerIDs {
+		cfg := defaultRulerConfig(t)
+		cfg.Ring = RingConfig{
+			InstanceID:        id,
+			InstanceAddr:      id,
+			KVStore:           kv.Config{Mock: kvStore},
+			ReplicationFactor: replicationFactor,
+		}
+
+		r := buildRuler(t, cfg, store, rulerAddrMap)
+		r.limits = ruleLimits{}
+		rulerAddrMap[id] = r
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), r.ring))
+		t.Cleanup(r.ring.StopAsync)
+	}
+
+	require.NoError(t, kvStore.CAS(context.Background(), RulerRingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		d := ring.NewDesc()
+		for i, id := range rulerIDs {
+			d.AddIngester(id, rulerAddrMap[id].lifecycler.GetInstanceAddr(), "", generateTokenForGroups(groups[i:i+1], 1), ring.ACTIVE, time.Now())
+		}
+		return d, true, nil
+	}))
+	for _, r := range rulerAddrMap {
+		test.Poll(t, time.Second, len(rulerIDs), func() interface{} {
+			rs, _ := r.ring.GetAllHealthy(RingOp)
+			return len(rs.Instances)
+		})
+		r.syncRules(context.Background(), rulerSyncReasonInitial)
+	}
+
+	// Each rule group is evaluated by as many rulers as the replication factor, and led by one of them.
+	loaded := map[string]int{}
+	led := map[string]int{}
+	for _, r := range rulerAddrMap {
+		configs, err := r.listRules(context.Background())
+		require.NoError(t, err)
+		for _, g := range configs["user1"] {
+			loaded[g.Name]++
+			if !r.manager.(*DefaultMultiTenantManager).followedRuleGroups.contains("user1", g.Namespace, g.Name) {
+				led[g.Name]++
+			}
+		}
+	}
+	for _, g := range groups {
+		assert.Equal(t, replicationFactor, loaded[g.Name], g.Name)
+		assert.Equal(t, 1, led[g.Name], g.Name)
+	}
+
+	// The rule groups evaluated by several rulers are listed once.
+	states, err := rulerAddrMap["ruler1"].GetRules(user.InjectOrgID(context.Background(), "user1"))
+	require.NoError(t, err)
+	assert.Len(t, states, len(groups))
+}
@@ -7,9 +7,15 @@ package ruler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -20,10 +26,10 @@ import (
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/promql/parser"
 	promRules "github.com/prometheus/prometheus/rules"
 	"github.com/thanos-io/thanos/pkg/cacheutil"
 	"github.com/weaveworks/common/user"
-	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -31,7 +37,9 @@ import (
 type DefaultMultiTenantManager struct {
 	cfg            Config
 	notifierCfg    *config.Config
+	dnsResolver    cacheutil.AddressProvider
 	managerFactory ManagerFactory
+	limits         RulesLimits
 
 	mapper *mapper
 
@@ -41,6 +49,32 @@ type DefaultMultiTenantManager struct {
 	userManagers       map[string]RulesManager
 	userManagerMetrics *ManagerMetrics
 
+	// Per-user rule groups, looked up by the rule evaluations.
+	userRuleGroups map[string]*ruleGroupsRegistry
+
+	// Rule groups whose leader is another ruler, looked up by the rule evaluations.
+	followedRuleGroups *followedRuleGroups
+
+	// Per-user missed iterations of the rule groups.
+	userMissedIterations map[string]*missedIterations
+
+	// Per-user alerts dropped by the alerting rules.
+	userDroppedAlerts map[string]*droppedAlerts
+
+	// Per-user rule groups being evaluated, looked up to wait for the rule group dependencies.
+	userEvaluatingGroups map[string]*evaluatingGroups
+
+	// Per-user checksums of the rule groups last applied to the managers, to skip the users whose
+	// rule groups didn't change since the previous sync.
+	userRuleGroupsChecksums map[string]string
+
+	// Context of the evaluations of the rule groups, canceled when the manager stops, after
+	// the shutdown grace period if any.
+	evalCtx    context.Context
+	evalCancel context.CancelFunc
+	// Prevents new evaluations of the rule groups from starting when the manager stops.
+	evalStop *evaluationsStop
+
 	// Per-user notifiers with separate queues.
 	notifiersMtx sync.Mutex
 	notifiers    map[string]*rulerNotifier
@@ -49,11 +83,17 @@ type DefaultMultiTenantManager struct {
 	lastReloadSuccessful          *prometheus.GaugeVec
 	lastReloadSuccessfulTimestamp *prometheus.GaugeVec
 	configUpdatesTotal            *prometheus.CounterVec
+	syncFailures                  *prometheus.CounterVec
+	ruleGroupChanges              *prometheus.CounterVec
+	notificationsResent           *prometheus.CounterVec
+	notificationsDropped          *prometheus.CounterVec
+	notificationsRateLimited      *prometheus.CounterVec
+	notificationsDeduplicated     *prometheus.CounterVec
 	registry                      prometheus.Registerer
 	logger                        log.Logger
 }
 
-func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
+func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, limits RulesLimits, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
 	ncfg, err := buildNotifierConfig(&cfg, dnsResolver)
 	if err != nil {
 		return nil, err
@@ -64,14 +104,26 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 		reg.MustRegister(userManagerMetrics)
 	}
 
+	evalCtx, evalCancel := context.WithCancel(context.Background())
 	return &DefaultMultiTenantManager{
-		cfg:                cfg,
-		notifierCfg:        ncfg,
-		managerFactory:     managerFactory,
-		notifiers:          map[string]*rulerNotifier{},
-		mapper:             newMapper(cfg.RulePath, logger),
-		userManagers:       map[string]RulesManager{},
-		userManagerMetrics: userManagerMetrics,
+		cfg:                     cfg,
+		notifierCfg:             ncfg,
+		dnsResolver:             dnsResolver,
+		managerFactory:          managerFactory,
+		limits:                  limits,
+		notifiers:               map[string]*rulerNotifier{},
+		mapper:                  newMapper(cfg.RulePath, logger),
+		userManagers:            map[string]RulesManager{},
+		userRuleGroups:          map[string]*ruleGroupsRegistry{},
+		followedRuleGroups:      newFollowedRuleGroups(),
+		userMissedIterations:    map[string]*missedIterations{},
+		userDroppedAlerts:       map[string]*droppedAlerts{},
+		userEvaluatingGroups:    map[string]*evaluatingGroups{},
+		userRuleGroupsChecksums: map[string]string{},
+		userManagerMetrics:      userManagerMetrics,
+		evalCtx:                 evalCtx,
+		evalCancel:              evalCancel,
+		evalStop:                &evaluationsStop{},
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Namespace: "cortex",
 			Name:      "ruler_managers_total",
@@ -92,12 +144,36 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 			Name:      "ruler_config_updates_total",
 			Help:      "Total number of config updates triggered by a user",
 		}, []string{"user"}),
+		syncFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_tenant_sync_failures_total",
+			Help: "Total number of failures to apply the rule groups of a tenant to its rule manager.",
+		}, []string{"user"}),
+		ruleGroupChanges: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_group_changes_total",
+			Help: "Total number of rule groups added, removed or updated by the syncs of the rule groups.",
+		}, []string{"change"}),
+		notificationsResent: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_persisted_notifications_resent_total",
+			Help: "Total number of persisted notifications resent to the Alertmanager after the notifier of the tenant was restarted.",
+		}, []string{"user"}),
+		notificationsDropped: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_persisted_notifications_dropped_total",
+			Help: "Total number of persisted notifications dropped after the notifier of the tenant was restarted, because they expired in the meantime.",
+		}, []string{"user"}),
+		notificationsRateLimited: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_notifications_rate_limited_total",
+			Help: "Total number of notifications dropped because the tenant exceeded the notifications rate limit.",
+		}, []string{"user"}),
+		notificationsDeduplicated: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_notifications_deduplicated_total",
+			Help: "Total number of notifications dropped because an identical notification was sent within the deduplication window.",
+		}, []string{"user"}),
 		registry: reg,
 		logger:   logger,
 	}, nil
 }
 
-func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGroups map[string]rulespb.RuleGroupList) {
+func (r *DefaultMultiTenantManager) SyncRuleGroups(_ context.Context, ruleGroups map[string]rulespb.RuleGroupList) {
 	// A lock is taken to ensure if this function is called concurrently, then each call
 	// returns after the call map files and check for updates
 	r.userManagerMtx.Lock()
@@ -108,19 +184,29 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 	}
 
 	for userID, ruleGroup := range ruleGroups {
-		r.syncRulesToManager(ctx, userID, ruleGroup)
+		r.syncRulesToManager(userID, ruleGroup)
 	}
 
 	// Check for deleted users and remove them
 	for userID, mngr := range r.userManagers {
 		if _, exists := ruleGroups[userID]; !exists {
+			if registry, ok := r.userRuleGroups[userID]; ok {
+				_, removed, _ := registry.changes(nil)
+				r.ruleGroupChanges.WithLabelValues("removed").Add(float64(removed))
+			}
 			go mngr.Stop()
 			delete(r.userManagers, userID)
+			delete(r.userRuleGroups, userID)
+			delete(r.userMissedIterations, userID)
+			delete(r.userDroppedAlerts, userID)
+			delete(r.userEvaluatingGroups, userID)
+			delete(r.userRuleGroupsChecksums, userID)
 
 			r.mapper.cleanupUser(userID)
 			r.lastReloadSuccessful.DeleteLabelValues(userID)
 			r.lastReloadSuccessfulTimestamp.DeleteLabelValues(userID)
 			r.configUpdatesTotal.DeleteLabelValues(userID)
+			r.syncFailures.DeleteLabelValues(userID)
 			r.userManagerMetrics.RemoveUserRegistry(userID)
 			level.Info(r.logger).Log("msg", "deleted rule manager and local rule files", "user", userID)
 		}
@@ -130,26 +216,78 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 }
 
 // syncRulesToManager maps the rule files to disk, detects any changes and will create/update the
-// the users Prometheus Rules Manager.
-func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user string, groups rulespb.RuleGroupList) {
+// the users Prometheus Rules Manager. The users whose rule groups didn't change since the previous
+// sync are skipped, without mapping their rule files again.
+func (r *DefaultMultiTenantManager) syncRulesToManager(user string, groups rulespb.RuleGroupList) {
+	checksum := ruleGroupsChecksum(groups)
+	if _, exists := r.userManagers[user]; exists && checksum != "" && checksum == r.userRuleGroupsChecksums[user] {
+		r.updateNotifierConfig(user)
+		return
+	}
+	// The checksum is set again once the rule groups are applied, so that a failed sync is retried.
+	_, synced := r.userRuleGroupsChecksums[user]
+	delete(r.userRuleGroupsChecksums, user)
+
+	registry, ok := r.userRuleGroups[user]
+	if !ok {
+		registry = newRuleGroupsRegistry()
+		r.userRuleGroups[user] = registry
+	}
+	added, removed, updated := registry.changes(groups)
+	r.ruleGroupChanges.WithLabelValues("added").Add(float64(added))
+	r.ruleGroupChanges.WithLabelValues("removed").Add(float64(removed))
+	r.ruleGroupChanges.WithLabelValues("updated").Add(float64(updated))
+	registry.set(groups)
+
+	missed, ok := r.userMissedIterations[user]
+	if !ok {
+		missed = newMissedIterations()
+		r.userMissedIterations[user] = missed
+	}
+
+	dropped, ok := r.userDroppedAlerts[user]
+	if !ok {
+		dropped = newDroppedAlerts()
+		r.userDroppedAlerts[user] = dropped
+	}
+
+	evaluating, ok := r.userEvaluatingGroups[user]
+	if !ok {
+		evaluating = newEvaluatingGroups()
+		r.userEvaluatingGroups[user] = evaluating
+	}
+
 	// Map the files to disk and return the file names to be passed to the users manager if they
 	// have been updated
-	update, files, err := r.mapper.MapRules(user, groups.Formatted())
+	update, files, err := r.mapper.MapRules(user, groups.RuleFiles())
 	if err != nil {
 		r.lastReloadSuccessful.WithLabelValues(user).Set(0)
+		r.syncFailures.WithLabelValues(user).Inc()
 		level.Error(r.logger).Log("msg", "unable to map rule files", "user", user, "err", err)
 		return
 	}
 
 	manager, exists := r.userManagers[user]
-	if !exists || update {
+	if exists {
+		r.updateNotifierConfig(user)
+	}
+	// The rules manager is updated again if the previous sync of the user failed, even if the rule files didn't change.
+	if !exists || update || !synced {
 		level.Debug(r.logger).Log("msg", "updating rules", "user", user)
 		r.configUpdatesTotal.WithLabelValues(user).Inc()
 		if !exists {
 			level.Debug(r.logger).Log("msg", "creating rule manager for user", "user", user)
-			manager, err = r.newManager(ctx, user)
+			// The evaluations aren't canceled with the context of the sync, but when the manager stops.
+			managerCtx := context.WithValue(r.evalCtx, tenantRuleGroups, registry)
+			managerCtx = context.WithValue(managerCtx, tenantMissedIterations, missed)
+			managerCtx = context.WithValue(managerCtx, followedRuleGroupsKey, r.followedRuleGroups)
+			managerCtx = context.WithValue(managerCtx, tenantDroppedAlerts, dropped)
+			managerCtx = context.WithValue(managerCtx, tenantEvaluatingGroups, evaluating)
+			managerCtx = context.WithValue(managerCtx, evaluationsStopKey, r.evalStop)
+			manager, err = r.newManager(managerCtx, user)
 			if err != nil {
 				r.lastReloadSuccessful.WithLabelValues(user).Set(0)
+				r.syncFailures.WithLabelValues(user).Inc()
 				level.Error(r.logger).Log("msg", "unable to create rule manager", "user", user, "err", err)
 				return
 			}
@@ -161,13 +299,43 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user
 		err = manager.Update(r.cfg.EvaluationInterval, files, nil, r.cfg.ExternalURL.String())
 		if err != nil {
 			r.lastReloadSuccessful.WithLabelValues(user).Set(0)
+			r.syncFailures.WithLabelValues(user).Inc()
 			level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 			return
 		}
+		missed.retain(manager.RuleGroups())
+		dropped.retain(manager.RuleGroups())
+		evaluating.retain(manager.RuleGroups())
 
 		r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 		r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
 	}
+	r.userRuleGroupsChecksums[user] = checksum
+}
+
+// ruleGroupsChecksum returns a checksum of the rule groups of a user, which doesn't depend on their order,
+// or an empty string if it can't be computed.
+func ruleGroupsChecksum(groups rulespb.RuleGroupList) string {
+	sorted := append(rulespb.RuleGroupList(nil), groups...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	h := sha256.New()
+	size := make([]byte, 8)
+	for _, g := range sorted {
+		data, err := g.Marshal()
+		if err != nil {
+			return ""
+		}
+		binary.BigEndian.PutUint64(size, uint64(len(data)))
+		_, _ = h.Write(size)
+		_, _ = h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // newManager creates a prometheus rule manager wrapped with a user id
@@ -186,19 +354,24 @@ func (r *DefaultMultiTenantManager) newManager(ctx context.Context, userID strin
 	return r.managerFactory(ctx, userID, notifier, r.logger, reg), nil
 }
 
-func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifier.Manager, error) {
+func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*rulerNotifier, error) {
 	r.notifiersMtx.Lock()
 	defer r.notifiersMtx.Unlock()
 
 	n, ok := r.notifiers[userID]
 	if ok {
-		return n.notifier, nil
+		return n, nil
+	}
+
+	var journal *notificationJournal
+	if r.cfg.NotificationQueueDir != "" {
+		journal = newNotificationJournal(r.cfg.NotificationQueueDir, userID, r.limits.RulerNotificationQueueCapacity(userID), r.notificationsResent.WithLabelValues(userID), r.notificationsDropped.WithLabelValues(userID))
 	}
 
 	reg := prometheus.WrapRegistererWith(prometheus.Labels{"user": userID}, r.registry)
 	reg = prometheus.WrapRegistererWithPrefix("cortex_", reg)
 	n = newRulerNotifier(&notifier.Options{
-		QueueCapacity: r.cfg.NotificationQueueCapacity,
+		QueueCapacity: r.limits.RulerNotificationQueueCapacity(userID),
 		Registerer:    reg,
 		Do: func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
 			// Note: The passed-in context comes from the Prometheus notifier
@@ -213,19 +386,88 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 			defer sp.Finish()
 			ctx = ot.ContextWithSpan(ctx, sp)
 			_ = ot.GlobalTracer().Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(req.Header))
-			return ctxhttp.Do(ctx, client, req)
+			client, err := n.httpClient(client)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := doWithRetries(ctx, client, req, r.limits.RulerNotificationMaxRetries(userID))
+			if journal != nil && err == nil && resp.StatusCode/100 == 2 {
+				ackNotifications(journal, req, log.With(r.logger, "user", userID))
+			}
+			return resp, err
 		},
-	}, log.With(r.logger, "user", userID))
+	}, journal, newNotificationLimiter(userID, r.limits, r.notificationsRateLimited.WithLabelValues(userID), r.notificationsDeduplicated.WithLabelValues(userID)), log.With(r.logger, "user", userID))
 
 	n.run()
 
 	// This should never fail, unless there's a programming mistake.
-	if err := n.applyConfig(r.notifierCfg); err != nil {
+	if err := n.applyConfig(r.notifierCfg, newNotifierTenantSettings(userID, r.limits)); err != nil {
 		return nil, err
 	}
 
 	r.notifiers[userID] = n
-	return n.notifier, nil
+	return n, nil
+}
+
+// ackNotifications records the alerts sent by the request as acknowledged by the Alertmanager.
+func ackNotifications(journal *notificationJournal, req *http.Request, logger log.Logger) {
+	if req.GetBody == nil {
+		return
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to read sent notifications", "err", err)
+		return
+	}
+	defer body.Close()
+
+	buf, err := io.ReadAll(body)
+	if err == nil {
+		err = journal.ack(buf)
+	}
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to acknowledge sent notifications", "err", err)
+	}
+}
+
+// ApplyNotifierConfig applies the Alertmanager notifier settings of cfg to the notifiers of all the users,
+// and to the notifiers created afterwards. The alerts queued for sending are kept.
+func (r *DefaultMultiTenantManager) ApplyNotifierConfig(cfg Config) error {
+	ncfg, err := buildNotifierConfig(&cfg, r.dnsResolver)
+	if err != nil {
+		return err
+	}
+
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	r.notifierCfg = ncfg
+	for userID, n := range r.notifiers {
+		if err := n.applyConfig(ncfg, n.tenantSettings()); err != nil {
+			level.Error(r.logger).Log("msg", "unable to update notifier config", "user", userID, "err", err)
+		}
+	}
+	return nil
+}
+
+// updateNotifierConfig applies the notifier config of the user again if their notifier settings changed.
+func (r *DefaultMultiTenantManager) updateNotifierConfig(userID string) {
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	n, ok := r.notifiers[userID]
+	if !ok {
+		return
+	}
+	if settings := newNotifierTenantSettings(userID, r.limits); settings != n.tenantSettings() {
+		if err := n.applyConfig(r.notifierCfg, settings); err != nil {
+			level.Error(r.logger).Log("msg", "unable to update notifier config", "user", userID, "err", err)
+		}
+	}
+}
+
+func (r *DefaultMultiTenantManager) SetFollowedRuleGroups(followed map[string]rulespb.RuleGroupList) {
+	r.followedRuleGroups.set(followed)
 }
 
 func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
@@ -238,13 +480,47 @@ func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	return groups
 }
 
+func (r *DefaultMultiTenantManager) GetRuleGroupDesc(userID, namespace, group string) *rulespb.RuleGroupDesc {
+	r.userManagerMtx.Lock()
+	registry, exists := r.userRuleGroups[userID]
+	r.userManagerMtx.Unlock()
+	if !exists {
+		return nil
+	}
+	return registry.get(namespace, group)
+}
+
+func (r *DefaultMultiTenantManager) GetMissedIterations(userID string, g *promRules.Group) int64 {
+	r.userManagerMtx.Lock()
+	missed, exists := r.userMissedIterations[userID]
+	r.userManagerMtx.Unlock()
+	if !exists {
+		return 0
+	}
+	return missed.get(promRules.GroupKey(g.File(), g.Name()))
+}
+
+func (r *DefaultMultiTenantManager) GetDroppedAlerts(userID string, g *promRules.Group) []int64 {
+	r.userManagerMtx.Lock()
+	dropped, exists := r.userDroppedAlerts[userID]
+	r.userManagerMtx.Unlock()
+	if !exists {
+		return nil
+	}
+	return dropped.get(promRules.GroupKey(g.File(), g.Name()))
+}
+
 func (r *DefaultMultiTenantManager) Stop() {
-	r.notifiersMtx.Lock()
-	for _, n := range r.notifiers {
-		n.stop()
+	gracePeriod := r.cfg.ShutdownGracePeriod
+	deadline := time.Now().Add(gracePeriod)
+	if gracePeriod <= 0 {
+		r.evalCancel()
 	}
-	r.notifiersMtx.Unlock()
 
+	// The user managers are stopped first, so that no new evaluation is started, while the in-flight
+	// evaluations complete and send their alerts to the notifiers. The user managers stop their rule
+	// groups one after the other, so the evaluations not started yet are prevented from running first.
+	r.evalStop.stop()
 	level.Info(r.logger).Log("msg", "stopping user managers")
 	wg := sync.WaitGroup{}
 	r.userManagerMtx.Lock()
@@ -257,15 +533,45 @@ func (r *DefaultMultiTenantManager) Stop() {
 			level.Debug(r.logger).Log("msg", "user manager shut down", "user", user)
 		}(manager, user)
 	}
-	wg.Wait()
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	if gracePeriod > 0 {
+		select {
+		case <-stopped:
+		case <-time.After(time.Until(deadline)):
+			level.Warn(r.logger).Log("msg", "canceling the in-flight evaluations of the rule groups at the end of the shutdown grace period", "grace_period", gracePeriod)
+			r.evalCancel()
+		}
+	}
+	<-stopped
+	r.evalCancel()
 	r.userManagerMtx.Unlock()
 	level.Info(r.logger).Log("msg", "all user managers stopped")
 
+	r.notifiersMtx.Lock()
+	if gracePeriod > 0 {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		for user, n := range r.notifiers {
+			if !n.drain(ctx) {
+				level.Warn(r.logger).Log("msg", "notifications not sent to the Alertmanager at the end of the shutdown grace period", "user", user, "queued", n.pendingAlerts())
+			}
+		}
+		cancel()
+	}
+	for _, n := range r.notifiers {
+		n.stop()
+	}
+	r.notifiersMtx.Unlock()
+
 	// cleanup user rules directories
 	r.mapper.cleanup()
 }
 
-func (*DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error {
+func (r *DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error {
 	var errs []error
 
 	if g.Name == "" {
@@ -278,6 +584,11 @@ func (*DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error
 		return errs
 	}
 
+	if g.Limit < 0 {
+		errs = append(errs, fmt.Errorf("invalid rules config: rule group '%s' has a negative limit", g.Name))
+		return errs
+	}
+
 	for i, r := range g.Rules {
 		for _, err := range r.Validate() {
 			var ruleName string
@@ -294,6 +605,21 @@ func (*DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error
 			})
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// All expressions are valid at this point, so they can be parsed without errors.
+	for i, rule := range g.Rules {
+		expr, _ := parser.ParseExpr(rule.Expr.Value)
+		if err := r.cfg.QueryEngine.validateExprFeatures(expr); err != nil {
+			ruleName := rule.Alert.Value
+			if ruleName == "" {
+				ruleName = rule.Record.Value
+			}
+			errs = append(errs, errors.Wrapf(err, "group %q, rule %d, %q", g.Name, i, ruleName))
+		}
+	}
 
 	return errs
 }
@@ -7,17 +7,22 @@ package ruler
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
+	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -25,7 +30,7 @@ import (
 func TestSyncRuleGroups(t *testing.T) {
 	dir := t.TempDir()
 
-	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, nil, log.NewNopLogger(), nil)
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, ruleLimits{}, nil, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	const user = "testUser"
@@ -97,6 +102,289 @@ func TestSyncRuleGroups(t *testing.T) {
 	})
 }
 
+func TestSyncRuleGroups_SkipsUnchangedUsers(t *testing.T) {
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, factory, ruleLimits{}, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	userRules := func(interval time.Duration) map[string]rulespb.RuleGroupList {
+		return map[string]rulespb.RuleGroupList{
+			"user-1": {
+				&rulespb.RuleGroupDesc{Name: "group1", Namespace: "ns", Interval: interval, User: "user-1"},
+				&rulespb.RuleGroupDesc{Name: "group2", Namespace: "ns", Interval: time.Minute, User: "user-1"},
+			},
+			"user-2": {
+				&rulespb.RuleGroupDesc{Name: "group1", Namespace: "ns", Interval: time.Minute, User: "user-2"},
+			},
+		}
+	}
+	updates := func(user string) int64 {
+		return getManager(m, user).(*mockRulesManager).updates.Load()
+	}
+
+	m.SyncRuleGroups(context.Background(), userRules(time.Minute))
+	require.Equal(t, int64(1), updates("user-1"))
+	require.Equal(t, int64(1), updates("user-2"))
+
+	// The users whose rule groups didn't change are skipped, whatever the order of their rule groups.
+	rules := userRules(time.Minute)
+	rules["user-1"][0], rules["user-1"][1] = rules["user-1"][1], rules["user-1"][0]
+	m.SyncRuleGroups(context.Background(), rules)
+	require.Equal(t, int64(1), updates("user-1"))
+	require.Equal(t, int64(1), updates("user-2"))
+
+	// Only the users whose rule groups changed are updated.
+	m.SyncRuleGroups(context.Background(), userRules(2*time.Minute))
+	require.Equal(t, int64(2), updates("user-1"))
+	require.Equal(t, int64(1), updates("user-2"))
+
+	// A user removed and added again is updated.
+	m.SyncRuleGroups(context.Background(), map[string]rulespb.RuleGroupList{"user-1": userRules(2 * time.Minute)["user-1"]})
+	m.SyncRuleGroups(context.Background(), userRules(2*time.Minute))
+	require.Equal(t, int64(2), updates("user-1"))
+	require.Equal(t, int64(1), updates("user-2"))
+}
+
+func TestSyncRuleGroups_RetriesFailedUsers(t *testing.T) {
+	failingFactory := func(_ context.Context, _ string, _ sender, _ log.Logger, _ prometheus.Registerer) RulesManager {
+		return &failingRulesManager{mockRulesManager: mockRulesManager{done: make(chan struct{})}}
+	}
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, failingFactory, ruleLimits{}, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	userRules := map[string]rulespb.RuleGroupList{
+		"user-1": {&rulespb.RuleGroupDesc{Name: "group1", Namespace: "ns", Interval: time.Minute, User: "user-1"}},
+	}
+	updates := func() int64 {
+		return getManager(m, "user-1").(*failingRulesManager).updates.Load()
+	}
+
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, int64(1), updates())
+
+	// The rules manager failed to load the rule groups, so it's updated again although they didn't change.
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, int64(2), updates())
+}
+
+func TestSyncRuleGroups_Metrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, factory, ruleLimits{}, reg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	group := func(name string, interval time.Duration) *rulespb.RuleGroupDesc {
+		return &rulespb.RuleGroupDesc{Name: name, Namespace: "ns", Interval: interval, User: "user-1"}
+	}
+	changes := func() map[string]float64 {
+		return map[string]float64{
+			"added":   testutil.ToFloat64(m.ruleGroupChanges.WithLabelValues("added")),
+			"removed": testutil.ToFloat64(m.ruleGroupChanges.WithLabelValues("removed")),
+			"updated": testutil.ToFloat64(m.ruleGroupChanges.WithLabelValues("updated")),
+		}
+	}
+
+	m.SyncRuleGroups(context.Background(), map[string]rulespb.RuleGroupList{
+		"user-1": {group("group1", time.Minute), group("group2", time.Minute)},
+	})
+	assert.Equal(t, map[string]float64{"added": 2, "removed": 0, "updated": 0}, changes())
+
+	m.SyncRuleGroups(context.Background(), map[string]rulespb.RuleGroupList{
+		"user-1": {group("group1", 2*time.Minute), group("group3", time.Minute)},
+	})
+	assert.Equal(t, map[string]float64{"added": 3, "removed": 1, "updated": 1}, changes())
+
+	// The rule groups of the removed users are removed.
+	m.SyncRuleGroups(context.Background(), nil)
+	assert.Equal(t, map[string]float64{"added": 3, "removed": 3, "updated": 1}, changes())
+}
+
+func TestSyncRuleGroups_TenantSyncFailures(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	failingFactory := func(_ context.Context, _ string, _ sender, _ log.Logger, _ prometheus.Registerer) RulesManager {
+		return &failingRulesManager{mockRulesManager: mockRulesManager{done: make(chan struct{})}}
+	}
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, failingFactory, ruleLimits{}, reg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	userRules := map[string]rulespb.RuleGroupList{
+		"user-1": {&rulespb.RuleGroupDesc{Name: "group1", Namespace: "ns", Interval: time.Minute, User: "user-1"}},
+	}
+	m.SyncRuleGroups(context.Background(), userRules)
+	// The failed syncs are retried.
+	m.SyncRuleGroups(context.Background(), userRules)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_tenant_sync_failures_total Total number of failures to apply the rule groups of a tenant to its rule manager.
+		# TYPE cortex_ruler_tenant_sync_failures_total counter
+		cortex_ruler_tenant_sync_failures_total{user="user-1"} 2
+	`), "cortex_ruler_tenant_sync_failures_total"))
+
+	// The failures of the removed users are removed.
+	m.SyncRuleGroups(context.Background(), nil)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(""), "cortex_ruler_tenant_sync_failures_total"))
+}
+
+func TestDefaultMultiTenantManager_StopWithShutdownGracePeriod(t *testing.T) {
+	const user = "testUser"
+	userRules := map[string]rulespb.RuleGroupList{
+		user: {&rulespb.RuleGroupDesc{Name: "group1", Namespace: "ns", Interval: time.Minute, User: user}},
+	}
+
+	for name, tc := range map[string]struct {
+		gracePeriod      time.Duration
+		evaluationTime   time.Duration
+		expectedCanceled bool
+	}{
+		"no grace period": {
+			gracePeriod:      0,
+			evaluationTime:   time.Hour,
+			expectedCanceled: true,
+		},
+		"in-flight evaluation completing within the grace period": {
+			gracePeriod:      time.Minute,
+			evaluationTime:   100 * time.Millisecond,
+			expectedCanceled: false,
+		},
+		"in-flight evaluation exceeding the grace period": {
+			gracePeriod:      100 * time.Millisecond,
+			evaluationTime:   time.Hour,
+			expectedCanceled: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var mgr *evaluatingRulesManager
+			evaluatingFactory := func(ctx context.Context, _ string, _ sender, _ log.Logger, _ prometheus.Registerer) RulesManager {
+				mgr = &evaluatingRulesManager{mockRulesManager: mockRulesManager{done: make(chan struct{})}, ctx: ctx, evaluationTime: tc.evaluationTime}
+				return mgr
+			}
+
+			m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir(), ShutdownGracePeriod: tc.gracePeriod}, evaluatingFactory, ruleLimits{}, nil, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			// The evaluations aren't canceled with the context of the sync.
+			ctx, cancel := context.WithCancel(context.Background())
+			m.SyncRuleGroups(ctx, userRules)
+			cancel()
+			require.NotNil(t, mgr)
+			require.NoError(t, mgr.ctx.Err())
+
+			start := time.Now()
+			m.Stop()
+			require.Less(t, time.Since(start), 10*time.Second)
+			require.Equal(t, tc.expectedCanceled, mgr.canceled.Load())
+			require.Error(t, mgr.ctx.Err())
+		})
+	}
+}
+
+func TestValidateRuleGroup_QueryEngineFeatures(t *testing.T) {
+	tests := map[string]struct {
+		expr                 string
+		enableAtModifier     bool
+		enableNegativeOffset bool
+		enableExperimental   bool
+		expectedErr          error
+		expectedErrMsg       string
+	}{
+		"plain expression": {
+			expr: "sum(up)",
+		},
+		"@ modifier disabled": {
+			expr:        "up @ 1609746000",
+			expectedErr: errAtModifierDisabled,
+		},
+		"@ modifier disabled, used in range selector": {
+			expr:        "rate(up[5m] @ end())",
+			expectedErr: errAtModifierDisabled,
+		},
+		"@ modifier disabled, used in subquery": {
+			expr:        "max_over_time(up[1h:1m] @ start())",
+			expectedErr: errAtModifierDisabled,
+		},
+		"@ modifier enabled": {
+			expr:             "up @ 1609746000",
+			enableAtModifier: true,
+		},
+		"negative offset disabled": {
+			expr:        "up offset -5m",
+			expectedErr: errNegativeOffsetDisabled,
+		},
+		"negative offset disabled, used in range selector": {
+			expr:        "rate(up[5m] offset -1m)",
+			expectedErr: errNegativeOffsetDisabled,
+		},
+		"negative offset enabled": {
+			expr:                 "up offset -5m",
+			enableNegativeOffset: true,
+		},
+		"positive offset": {
+			expr: "up offset 5m",
+		},
+		"experimental functions disabled": {
+			expr:           "sum(holt_winters(up[5m], 0.5, 0.5))",
+			expectedErrMsg: `function "holt_winters" is experimental and disabled`,
+		},
+		"experimental functions enabled": {
+			expr:               "sum(holt_winters(up[5m], 0.5, 0.5))",
+			enableExperimental: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{RulePath: t.TempDir()}
+			cfg.QueryEngine.EnableAtModifier = tc.enableAtModifier
+			cfg.QueryEngine.EnableNegativeOffset = tc.enableNegativeOffset
+			cfg.QueryEngine.ExperimentalFunctionsEnabled = tc.enableExperimental
+
+			m, err := NewDefaultMultiTenantManager(cfg, factory, ruleLimits{}, nil, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			group := rulefmt.RuleGroup{
+				Name: "group",
+				Rules: []rulefmt.RuleNode{{
+					Record: yaml.Node{Kind: yaml.ScalarNode, Value: "test:record"},
+					Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: tc.expr},
+				}},
+			}
+
+			errs := m.ValidateRuleGroup(group)
+			if tc.expectedErr == nil && tc.expectedErrMsg == "" {
+				require.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, errs[0], tc.expectedErr)
+			} else {
+				require.Contains(t, errs[0].Error(), tc.expectedErrMsg)
+			}
+		})
+	}
+}
+
+func TestValidateRuleGroup_Limit(t *testing.T) {
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, factory, ruleLimits{}, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	group := rulefmt.RuleGroup{
+		Name: "group",
+		Rules: []rulefmt.RuleNode{{
+			Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "TestAlert"},
+			Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "up == 0"},
+		}},
+	}
+
+	group.Limit = 10
+	require.Empty(t, m.ValidateRuleGroup(group))
+
+	group.Limit = -1
+	require.Len(t, m.ValidateRuleGroup(group), 1)
+}
+
 func getManager(m *DefaultMultiTenantManager, user string) RulesManager {
 	m.userManagerMtx.Lock()
 	defer m.userManagerMtx.Unlock()
@@ -104,12 +392,13 @@ func getManager(m *DefaultMultiTenantManager, user string) RulesManager {
 	return m.userManagers[user]
 }
 
-func factory(_ context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ prometheus.Registerer) RulesManager {
+func factory(_ context.Context, _ string, _ sender, _ log.Logger, _ prometheus.Registerer) RulesManager {
 	return &mockRulesManager{done: make(chan struct{})}
 }
 
 type mockRulesManager struct {
 	running atomic.Bool
+	updates atomic.Int64
 	done    chan struct{}
 }
 
@@ -123,7 +412,36 @@ func (m *mockRulesManager) Stop() {
 	close(m.done)
 }
 
+// evaluatingRulesManager is a mockRulesManager with an evaluation in flight when it stops, which takes
+// evaluationTime to complete unless the context of the evaluations is canceled first.
+type evaluatingRulesManager struct {
+	mockRulesManager
+	ctx            context.Context
+	evaluationTime time.Duration
+	canceled       atomic.Bool
+}
+
+func (m *evaluatingRulesManager) Stop() {
+	select {
+	case <-time.After(m.evaluationTime):
+	case <-m.ctx.Done():
+		m.canceled.Store(true)
+	}
+	m.mockRulesManager.Stop()
+}
+
+// failingRulesManager is a mockRulesManager failing to update its rule groups.
+type failingRulesManager struct {
+	mockRulesManager
+}
+
+func (m *failingRulesManager) Update(_ time.Duration, _ []string, _ labels.Labels, _ string) error {
+	m.updates.Inc()
+	return errors.New("failed to load the rule groups")
+}
+
 func (m *mockRulesManager) Update(_ time.Duration, _ []string, _ labels.Labels, _ string) error {
+	m.updates.Inc()
 	return nil
 }
 
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	_ "embed" // Used to embed the meta-monitoring rule pack.
+	"flag"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// metaMonitoringNamespace is the namespace of the meta-monitoring rule groups in the meta-monitoring tenant.
+const metaMonitoringNamespace = "mimir-ruler-meta-monitoring"
+
+var (
+	//go:embed meta_monitoring_rules.yaml
+	metaMonitoringRules []byte
+
+	errMissingMetaMonitoringTenant = errors.New("the ruler meta-monitoring tenant must be set when the meta-monitoring is enabled")
+)
+
+type MetaMonitoringConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Tenant  string `yaml:"tenant"`
+}
+
+func (cfg *MetaMonitoringConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.meta-monitoring.enabled", false, "Install the built-in meta-monitoring rule groups into the meta-monitoring tenant. They alert on the rule evaluation failures, the missed rule group iterations and the notification errors of every tenant, from the ruler metrics ingested into the meta-monitoring tenant. The rule groups are stored in the "+metaMonitoringNamespace+" namespace, which is reconciled at every rules poll.")
+	f.StringVar(&cfg.Tenant, "ruler.meta-monitoring.tenant", "", "Tenant to install the meta-monitoring rule groups into.")
+}
+
+func (cfg *MetaMonitoringConfig) Validate() error {
+	if cfg.Enabled && cfg.Tenant == "" {
+		return errMissingMetaMonitoringTenant
+	}
+	return nil
+}
+
+// loadMetaMonitoringRuleGroups returns the built-in meta-monitoring rule groups of the tenant.
+func loadMetaMonitoringRuleGroups(userID string) (rulespb.RuleGroupList, error) {
+	var pack struct {
+		Groups []rulespb.RuleGroup `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal(metaMonitoringRules, &pack); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the meta-monitoring rule groups")
+	}
+
+	groups := make(rulespb.RuleGroupList, 0, len(pack.Groups))
+	for _, g := range pack.Groups {
+		groups = append(groups, rulespb.ToProto(userID, metaMonitoringNamespace, g))
+	}
+	return groups, nil
+}
+
+// metaMonitoringInstaller periodically installs the built-in meta-monitoring rule groups into the rule store.
+type metaMonitoringInstaller struct {
+	services.Service
+
+	tenant string
+	groups rulespb.RuleGroupList
+	store  rulestore.RuleStore
+	logger log.Logger
+
+	installationsFailed prometheus.Counter
+}
+
+func newMetaMonitoringInstaller(cfg MetaMonitoringConfig, interval time.Duration, store rulestore.RuleStore, logger log.Logger, reg prometheus.Registerer) (*metaMonitoringInstaller, error) {
+	groups, err := loadMetaMonitoringRuleGroups(cfg.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &metaMonitoringInstaller{
+		tenant: cfg.Tenant,
+		groups: groups,
+		store:  store,
+		logger: logger,
+		installationsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_meta_monitoring_installations_failed_total",
+			Help: "Total number of failed installations of the meta-monitoring rule groups into the rule store.",
+		}),
+	}
+
+	i.Service = services.NewTimerService(interval, i.starting, i.iteration, nil).WithName("ruler meta-monitoring rules installer")
+	return i, nil
+}
+
+func (i *metaMonitoringInstaller) starting(ctx context.Context) error {
+	// Install the rule groups as soon as possible.
+	_ = i.iteration(ctx)
+	return nil
+}
+
+func (i *metaMonitoringInstaller) iteration(ctx context.Context) error {
+	if _, err := reconcileNamespaceRuleGroups(ctx, i.store, i.logger, i.tenant, metaMonitoringNamespace, i.groups); err != nil {
+		i.installationsFailed.Inc()
+		level.Warn(i.logger).Log("msg", "failed to install the meta-monitoring rule groups", "user", i.tenant, "err", err)
+	}
+	return nil
+}
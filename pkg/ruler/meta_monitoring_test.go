@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestLoadMetaMonitoringRuleGroups(t *testing.T) {
+	// The rule pack is a valid Prometheus rule file.
+	_, errs := rulefmt.Parse(metaMonitoringRules)
+	require.Empty(t, errs)
+
+	groups, err := loadMetaMonitoringRuleGroups("admin")
+	require.NoError(t, err)
+	require.NotEmpty(t, groups)
+	for _, g := range groups {
+		assert.Equal(t, "admin", g.User)
+		assert.Equal(t, metaMonitoringNamespace, g.Namespace)
+		assert.NotEmpty(t, g.Rules)
+	}
+}
+
+func TestMetaMonitoringInstaller(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	// A rule group of the meta-monitoring namespace which isn't part of the rule pack is removed,
+	// while the other namespaces of the tenant are left untouched.
+	require.NoError(t, store.SetRuleGroup(ctx, "admin", metaMonitoringNamespace, &rulespb.RuleGroupDesc{
+		Name: "unexpected", Namespace: metaMonitoringNamespace, User: "admin",
+		Rules: []*rulespb.RuleDesc{{Record: "unexpected:sum", Expr: "sum(up)"}},
+	}))
+	require.NoError(t, store.SetRuleGroup(ctx, "admin", "other", &rulespb.RuleGroupDesc{
+		Name: "other", Namespace: "other", User: "admin",
+		Rules: []*rulespb.RuleDesc{{Record: "other:sum", Expr: "sum(up)"}},
+	}))
+
+	reg := prometheus.NewPedanticRegistry()
+	i, err := newMetaMonitoringInstaller(MetaMonitoringConfig{Enabled: true, Tenant: "admin"}, time.Minute, store, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, i.iteration(ctx))
+
+	groups, err := store.ListRuleGroupsForUserAndNamespace(ctx, "admin", "")
+	require.NoError(t, err)
+	require.NoError(t, store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{"admin": groups}))
+
+	expected, err := loadMetaMonitoringRuleGroups("admin")
+	require.NoError(t, err)
+	require.Len(t, groups, len(expected)+1)
+
+	installed := map[string]*rulespb.RuleGroupDesc{}
+	for _, g := range groups {
+		installed[g.Namespace+"/"+g.Name] = g
+	}
+	assert.Contains(t, installed, "other/other")
+	for _, g := range expected {
+		require.Contains(t, installed, metaMonitoringNamespace+"/"+g.Name)
+		assert.Equal(t, g.Rules, installed[metaMonitoringNamespace+"/"+g.Name].Rules)
+	}
+
+	// The installed rule groups are not stored again when they're up to date.
+	updatedAt := *installed[metaMonitoringNamespace+"/"+expected[0].Name].UpdatedAt
+	require.NoError(t, i.iteration(ctx))
+	current, err := store.GetRuleGroup(ctx, "admin", metaMonitoringNamespace, expected[0].Name)
+	require.NoError(t, err)
+	assert.Equal(t, updatedAt, *current.UpdatedAt)
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(i.installationsFailed))
+}
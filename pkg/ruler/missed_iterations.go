@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+)
+
+const (
+	tenantMissedIterations      contextKey = 6
+	missedIterationsObserverKey contextKey = 7
+)
+
+const (
+	missedIterationsPolicySkip     = "skip"
+	missedIterationsPolicyBackfill = "backfill"
+)
+
+var missedIterationsPolicies = []string{
+	missedIterationsPolicySkip,
+	missedIterationsPolicyBackfill,
+}
+
+// missedIterations holds the number of iterations missed by each rule group of a tenant, because
+// a previous evaluation of the group took longer than its interval.
+type missedIterations struct {
+	mtx    sync.Mutex
+	groups map[string]int64 // By group key.
+}
+
+func newMissedIterations() *missedIterations {
+	return &missedIterations{groups: map[string]int64{}}
+}
+
+func (m *missedIterations) add(key string, missed int64) {
+	m.mtx.Lock()
+	m.groups[key] += missed
+	m.mtx.Unlock()
+}
+
+func (m *missedIterations) get(key string) int64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.groups[key]
+}
+
+// retain removes the missed iterations of the rule groups not in groups.
+func (m *missedIterations) retain(groups []*rules.Group) {
+	keep := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		keep[rules.GroupKey(g.File(), g.Name())] = struct{}{}
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for key := range m.groups {
+		if _, ok := keep[key]; !ok {
+			delete(m.groups, key)
+		}
+	}
+}
+
+// groupBackfiller evaluates the recording rules of a rule group at the timestamps of its missed
+// iterations, and writes their results.
+type groupBackfiller struct {
+	queryFunc     rules.QueryFunc
+	appendable    storage.Appendable
+	externalURL   *url.URL
+	maxIterations int
+	backfilled    prometheus.Counter
+	logger        log.Logger
+}
+
+// backfill evaluates the recording rules of g at the evaluation timestamps of the iterations missed after
+// the evaluation at previous, up to the most recent maxIterations of them. The alerting rules aren't evaluated,
+// because their state can only move forward.
+func (b *groupBackfiller) backfill(ctx context.Context, g *rules.Group, previous time.Time, missed int64) {
+	first := int64(1)
+	if missed > int64(b.maxIterations) {
+		first = missed - int64(b.maxIterations) + 1
+	}
+
+	evaluationDelay := g.EvaluationDelay()
+	for i := first; i <= missed; i++ {
+		ts := previous.Add(time.Duration(i) * g.Interval())
+		app := b.appendable.Appender(ctx)
+		for _, r := range g.Rules() {
+			rule, ok := r.(*rules.RecordingRule)
+			if !ok {
+				continue
+			}
+
+			vector, err := rule.Eval(ctx, evaluationDelay, ts, b.queryFunc, b.externalURL, g.Limit())
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to backfill a missed iteration of the rule", "group", g.Name(), "rule", rule.Name(), "ts", ts, "err", err)
+				continue
+			}
+			for _, s := range vector {
+				if _, err := app.Append(0, s.Metric, s.T, s.V); err != nil {
+					level.Warn(b.logger).Log("msg", "failed to append the backfilled result of the rule", "group", g.Name(), "rule", rule.Name(), "ts", ts, "err", err)
+				}
+			}
+		}
+		if err := app.Commit(); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to write the backfilled results of a missed iteration", "group", g.Name(), "ts", ts, "err", err)
+			continue
+		}
+		b.backfilled.Inc()
+	}
+}
+
+// MissedIterationsContextFunc returns a rules.ContextWrapFunc injecting in the context of each rule
+// group an observer used by MissedIterationsQueryFunc to detect the missed iterations of the group.
+// The missed iterations are backfilled by backfiller, unless nil.
+func MissedIterationsContextFunc(backfiller *groupBackfiller) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		counts, _ := ctx.Value(tenantMissedIterations).(*missedIterations)
+		return context.WithValue(ctx, missedIterationsObserverKey, &missedIterationsObserver{group: g, counts: counts, backfiller: backfiller})
+	}
+}
+
+// MissedIterationsQueryFunc returns a rules.QueryFunc which, at the first query of each evaluation of a rule
+// group, detects the iterations missed since the previous evaluation and backfills them if configured to.
+// The current evaluation waits for the backfill to complete, so that it writes its results after them.
+func MissedIterationsQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if o, ok := ctx.Value(missedIterationsObserverKey).(*missedIterationsObserver); ok {
+			o.observe(ctx, t)
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+type missedIterationsObserver struct {
+	group      *rules.Group
+	counts     *missedIterations
+	backfiller *groupBackfiller
+
+	mtx sync.Mutex
+	// Query timestamp of the latest evaluation.
+	ts time.Time
+}
+
+func (o *missedIterationsObserver) observe(ctx context.Context, t time.Time) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if !t.After(o.ts) {
+		return
+	}
+	previous := o.ts
+	o.ts = t
+	if previous.IsZero() {
+		return
+	}
+
+	// The queries are run at the evaluation timestamp of the group, minus the evaluation delay.
+	missed := int64(t.Sub(previous)/o.group.Interval()) - 1
+	if missed <= 0 {
+		return
+	}
+	if o.counts != nil {
+		o.counts.add(rules.GroupKey(o.group.File(), o.group.Name()), missed)
+	}
+
+	if o.backfiller != nil {
+		o.backfiller.backfill(ctx, o.group, previous.Add(o.group.EvaluationDelay()), missed)
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// collectingPusher is a Pusher keeping all the pushed samples.
+type collectingPusher struct {
+	mtx     sync.Mutex
+	samples []mimirpb.Sample
+}
+
+func (p *collectingPusher) Push(_ context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, ts := range req.Timeseries {
+		p.samples = append(p.samples, ts.Samples...)
+	}
+	return &mimirpb.WriteResponse{}, nil
+}
+
+func TestMissedIterationsQueryFunc(t *testing.T) {
+	recording, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+	alerting, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+
+	evaluationDelay := time.Minute
+	newGroup := func() *rules.Group {
+		return rules.NewGroup(rules.GroupOptions{
+			Name:            "group-1",
+			File:            "/rules/user-1/namespace-1",
+			Interval:        time.Minute,
+			EvaluationDelay: &evaluationDelay,
+			Opts:            &rules.ManagerOptions{},
+			Rules: []rules.Rule{
+				rules.NewRecordingRule("up:sum", recording, nil),
+				rules.NewAlertingRule("InstanceDown", alerting, 0, nil, nil, nil, "", false, log.NewNopLogger()),
+			},
+		})
+	}
+
+	// The evaluations query at their timestamp minus the evaluation delay.
+	start := time.Unix(3600, 0).UTC()
+	evaluations := []time.Time{start, start.Add(time.Minute), start.Add(5 * time.Minute), start.Add(6 * time.Minute)}
+
+	for name, tc := range map[string]struct {
+		maxIterations      int
+		expectedBackfilled []int64
+	}{
+		"skip": {},
+		"backfill all the missed iterations": {
+			maxIterations:      10,
+			expectedBackfilled: []int64{2, 3, 4},
+		},
+		"backfill the most recent missed iterations": {
+			maxIterations:      2,
+			expectedBackfilled: []int64{3, 4},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := newGroup()
+			counts := newMissedIterations()
+
+			var queried []time.Time
+			var mtx sync.Mutex
+			inner := func(_ context.Context, _ string, t time.Time) (promql.Vector, error) {
+				mtx.Lock()
+				queried = append(queried, t)
+				mtx.Unlock()
+				return promql.Vector{{Point: promql.Point{T: t.UnixMilli(), V: 1}, Metric: labels.Labels{}}}, nil
+			}
+
+			pusher := &collectingPusher{}
+			backfilled := prometheus.NewCounter(prometheus.CounterOpts{})
+			var backfiller *groupBackfiller
+			if tc.maxIterations > 0 {
+				backfiller = &groupBackfiller{
+					queryFunc:     inner,
+					appendable:    NewPusherAppendable(pusher, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+					maxIterations: tc.maxIterations,
+					backfilled:    backfilled,
+					logger:        log.NewNopLogger(),
+				}
+			}
+
+			ctx := context.WithValue(context.Background(), tenantMissedIterations, counts)
+			ctx = MissedIterationsContextFunc(backfiller)(ctx, g)
+			qf := MissedIterationsQueryFunc(inner)
+
+			for _, ts := range evaluations {
+				// Two queries per evaluation, only the first one detects the missed iterations.
+				for i := 0; i < 2; i++ {
+					_, err := qf(ctx, "sum(up)", ts.Add(-evaluationDelay))
+					require.NoError(t, err)
+				}
+			}
+
+			assert.Equal(t, int64(3), counts.get(rules.GroupKey(g.File(), g.Name())))
+			assert.Equal(t, float64(len(tc.expectedBackfilled)), testutil.ToFloat64(backfilled))
+
+			// The recording rule is backfilled at the query timestamps of the missed iterations, before
+			// the query of the evaluation detecting them. Like the evaluations, it writes its results at them.
+			require.Len(t, pusher.samples, len(tc.expectedBackfilled))
+			require.Len(t, queried, 2*len(evaluations)+len(tc.expectedBackfilled))
+			for i, iteration := range tc.expectedBackfilled {
+				expected := start.Add(time.Duration(iteration) * time.Minute).Add(-evaluationDelay)
+				assert.Equal(t, expected, queried[2*2+i])
+				assert.Equal(t, expected.UnixMilli(), pusher.samples[i].TimestampMs)
+			}
+
+			counts.retain(nil)
+			assert.Equal(t, int64(0), counts.get(rules.GroupKey(g.File(), g.Name())))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// rulesTokenHeader is the header of the API token of the requests to the ruler configuration API.
+const rulesTokenHeader = "X-Mimir-Rules-Token"
+
+const tokenScopeKey contextKey = 10
+
+// Verbs of the API tokens scopes.
+const (
+	TokenVerbRead  = "read"
+	TokenVerbWrite = "write"
+)
+
+var tokenVerbs = []string{TokenVerbRead, TokenVerbWrite}
+
+var (
+	errMissingRulesToken       = errors.New("missing API token in the " + rulesTokenHeader + " header")
+	errNamespaceNotAuthorized  = errors.New("the API token is not allowed to access the namespace")
+	errTenantNotAuthorized     = errors.New("the API token is not allowed to access all the namespaces of the tenant")
+	errVerbNotAuthorized       = errors.New("the API token is not allowed to perform the request")
+	errInvalidRulesToken       = errors.New("invalid API token")
+	errEmptyRulesToken         = errors.New("the API tokens must not be empty")
+	errMissingRulesTokenTenant = errors.New("the API tokens must have a tenant")
+	errDuplicateRulesToken     = errors.New("duplicate API token")
+	errInvalidRulesTokenVerb   = errors.New("invalid API token verb, supported values are: read, write")
+	errMissingRulesTokenVerbs  = errors.New("the API tokens must have at least one verb")
+)
+
+type NamespaceAuthorizationConfig struct {
+	TokensFile string `yaml:"tokens_file"`
+}
+
+func (cfg *NamespaceAuthorizationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.TokensFile, "ruler.namespace-authorization.tokens-file", "", "Path to the YAML file of the API tokens allowed to use the ruler configuration API, each scoped to a tenant, and optionally to some of its namespaces and to the read or write verbs. When set, the requests to the ruler configuration API must have a valid API token in the "+rulesTokenHeader+" header.")
+}
+
+// TokenScope is the scope of an API token within a tenant.
+type TokenScope struct {
+	// Namespaces the token can access. Empty for all the namespaces of the tenant.
+	Namespaces []string `yaml:"namespaces"`
+	// Verbs the token can perform, among read and write.
+	Verbs []string `yaml:"verbs"`
+}
+
+func (s TokenScope) allowsNamespace(namespace string) bool {
+	return len(s.Namespaces) == 0 || util.StringsContain(s.Namespaces, namespace)
+}
+
+func (s TokenScope) allowsVerb(verb string) bool {
+	return util.StringsContain(s.Verbs, verb)
+}
+
+// TokenValidator validates the API tokens of the requests to the ruler configuration API.
+type TokenValidator interface {
+	// ValidateToken returns the scope of token within the tenant, or an error if the token isn't valid for the tenant.
+	ValidateToken(ctx context.Context, tenantID, token string) (TokenScope, error)
+}
+
+type fileToken struct {
+	Token      string `yaml:"token"`
+	Tenant     string `yaml:"tenant"`
+	TokenScope `yaml:",inline"`
+}
+
+// fileTokenValidator is a TokenValidator of the API tokens listed in a YAML file.
+type fileTokenValidator struct {
+	tokens map[string]fileToken // By token.
+}
+
+// NewFileTokenValidator returns a TokenValidator of the API tokens listed in the YAML file at path, in the format:
+//
+//	tokens:
+//	  - token: <token>
+//	    tenant: <tenant ID>
+//	    namespaces: [<namespace>, ...]
+//	    verbs: [read, write]
+func NewFileTokenValidator(path string) (TokenValidator, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the API tokens file")
+	}
+
+	var file struct {
+		Tokens []fileToken `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the API tokens file")
+	}
+
+	v := &fileTokenValidator{tokens: make(map[string]fileToken, len(file.Tokens))}
+	for _, t := range file.Tokens {
+		if t.Token == "" {
+			return nil, errEmptyRulesToken
+		}
+		if t.Tenant == "" {
+			return nil, errMissingRulesTokenTenant
+		}
+		if _, ok := v.tokens[t.Token]; ok {
+			return nil, errDuplicateRulesToken
+		}
+		if len(t.Verbs) == 0 {
+			return nil, errMissingRulesTokenVerbs
+		}
+		for _, verb := range t.Verbs {
+			if !util.StringsContain(tokenVerbs, verb) {
+				return nil, errInvalidRulesTokenVerb
+			}
+		}
+		v.tokens[t.Token] = t
+	}
+	return v, nil
+}
+
+func (v *fileTokenValidator) ValidateToken(_ context.Context, tenantID, token string) (TokenScope, error) {
+	t, ok := v.tokens[token]
+	if !ok || t.Tenant != tenantID {
+		return TokenScope{}, errInvalidRulesToken
+	}
+	return t.TokenScope, nil
+}
+
+// SetTokenValidator sets the validator of the API tokens of the requests wrapped by AuthorizeToken.
+func (a *API) SetTokenValidator(v TokenValidator) {
+	a.tokenValidator = v
+}
+
+// AuthorizeToken wraps a handler of the configuration API so that, when a TokenValidator is set, the
+// requests are only allowed if their API token is valid for their tenant, allows their verb, and
+// allows their namespace, if any. The GET and HEAD requests need the read verb, the others the write verb.
+// The rule groups of the requests without a namespace are filtered by the namespaces allowed by the token.
+func (a *API) AuthorizeToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if a.tokenValidator == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		token := req.Header.Get(rulesTokenHeader)
+		if token == "" {
+			http.Error(w, errMissingRulesToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		userID, err := tenant.TenantID(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		scope, err := a.tokenValidator.ValidateToken(req.Context(), userID, token)
+		if err != nil {
+			level.Warn(util_log.WithContext(req.Context(), a.logger)).Log("msg", "rejected API token", "err", err)
+			http.Error(w, errInvalidRulesToken.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		verb := TokenVerbWrite
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			verb = TokenVerbRead
+		}
+		if !scope.allowsVerb(verb) {
+			http.Error(w, errVerbNotAuthorized.Error(), http.StatusForbidden)
+			return
+		}
+
+		namespace, err := parseNamespace(mux.Vars(req))
+		if err != nil && err != ErrNoNamespace {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == nil && !scope.allowsNamespace(namespace) {
+			http.Error(w, errNamespaceNotAuthorized.Error(), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), tokenScopeKey, scope)))
+	})
+}
+
+// authorizedNamespace returns whether the namespace is allowed by the API token of the request, if any.
+func authorizedNamespace(ctx context.Context, namespace string) bool {
+	scope, ok := ctx.Value(tokenScopeKey).(TokenScope)
+	return !ok || scope.allowsNamespace(namespace)
+}
+
+// authorizedAllNamespaces returns whether the API token of the request, if any, allows all the namespaces
+// of the tenant, as required by the requests acting on the tenant as a whole.
+func authorizedAllNamespaces(ctx context.Context) bool {
+	scope, ok := ctx.Value(tokenScopeKey).(TokenScope)
+	return !ok || len(scope.Namespaces) == 0
+}
+
+// filterAuthorizedNamespaces returns the rule groups of rgs whose namespace is allowed by the
+// API token of the request, if any.
+func filterAuthorizedNamespaces(ctx context.Context, rgs rulespb.RuleGroupList) rulespb.RuleGroupList {
+	scope, ok := ctx.Value(tokenScopeKey).(TokenScope)
+	if !ok || len(scope.Namespaces) == 0 {
+		return rgs
+	}
+
+	filtered := make(rulespb.RuleGroupList, 0, len(rgs))
+	for _, g := range rgs {
+		if scope.allowsNamespace(g.Namespace) {
+			filtered = append(filtered, g)
+		}
+	}
+	return filtered
+}
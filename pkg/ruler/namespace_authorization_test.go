@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func writeTokensFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestNewFileTokenValidator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content     string
+		expectedErr error
+	}{
+		"valid tokens": {
+			content: "tokens:\n- {token: a, tenant: user1, namespaces: [team-a], verbs: [read, write]}\n- {token: b, tenant: user1, verbs: [read]}\n",
+		},
+		"empty token": {
+			content:     "tokens:\n- {tenant: user1, verbs: [read]}\n",
+			expectedErr: errEmptyRulesToken,
+		},
+		"missing tenant": {
+			content:     "tokens:\n- {token: a, verbs: [read]}\n",
+			expectedErr: errMissingRulesTokenTenant,
+		},
+		"duplicate token": {
+			content:     "tokens:\n- {token: a, tenant: user1, verbs: [read]}\n- {token: a, tenant: user2, verbs: [read]}\n",
+			expectedErr: errDuplicateRulesToken,
+		},
+		"missing verbs": {
+			content:     "tokens:\n- {token: a, tenant: user1}\n",
+			expectedErr: errMissingRulesTokenVerbs,
+		},
+		"invalid verb": {
+			content:     "tokens:\n- {token: a, tenant: user1, verbs: [delete]}\n",
+			expectedErr: errInvalidRulesTokenVerb,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFileTokenValidator(writeTokensFile(t, tc.content))
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAPI_AuthorizeToken(t *testing.T) {
+	validator, err := NewFileTokenValidator(writeTokensFile(t, `tokens:
+- {token: team-a-writer, tenant: user1, namespaces: [team-a], verbs: [read, write]}
+- {token: team-a-reader, tenant: user1, namespaces: [team-a], verbs: [read]}
+- {token: admin, tenant: user1, verbs: [read, write]}
+`))
+	require.NoError(t, err)
+
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			{User: "user1", Namespace: "team-a", Name: "group-a"},
+			{User: "user1", Namespace: "team-b", Name: "group-b"},
+		},
+	})
+
+	for name, tc := range map[string]struct {
+		validator          TokenValidator
+		method             string
+		path               string
+		token              string
+		expectedStatus     int
+		expectedNamespaces []string
+	}{
+		"authorization disabled": {
+			method:             http.MethodGet,
+			path:               "/api/v1/rules",
+			expectedStatus:     http.StatusOK,
+			expectedNamespaces: []string{"team-a", "team-b"},
+		},
+		"missing token": {
+			validator:      validator,
+			method:         http.MethodGet,
+			path:           "/api/v1/rules",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"token of another tenant": {
+			validator:      validator,
+			method:         http.MethodGet,
+			path:           "/api/v1/rules",
+			token:          "unknown",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"all the namespaces with an unscoped token": {
+			validator:          validator,
+			method:             http.MethodGet,
+			path:               "/api/v1/rules",
+			token:              "admin",
+			expectedStatus:     http.StatusOK,
+			expectedNamespaces: []string{"team-a", "team-b"},
+		},
+		"all the namespaces filtered by a scoped token": {
+			validator:          validator,
+			method:             http.MethodGet,
+			path:               "/api/v1/rules",
+			token:              "team-a-reader",
+			expectedStatus:     http.StatusOK,
+			expectedNamespaces: []string{"team-a"},
+		},
+		"allowed namespace": {
+			validator:          validator,
+			method:             http.MethodGet,
+			path:               "/api/v1/rules/team-a",
+			token:              "team-a-reader",
+			expectedStatus:     http.StatusOK,
+			expectedNamespaces: []string{"team-a"},
+		},
+		"not allowed namespace": {
+			validator:      validator,
+			method:         http.MethodGet,
+			path:           "/api/v1/rules/team-b",
+			token:          "team-a-reader",
+			expectedStatus: http.StatusForbidden,
+		},
+		"write with a read-only token": {
+			validator:      validator,
+			method:         http.MethodDelete,
+			path:           "/api/v1/rules/team-a",
+			token:          "team-a-reader",
+			expectedStatus: http.StatusForbidden,
+		},
+		"write with a write token": {
+			validator:      validator,
+			method:         http.MethodDelete,
+			path:           "/api/v1/rules/team-a",
+			token:          "team-a-writer",
+			expectedStatus: http.StatusAccepted,
+		},
+		"write in a not allowed namespace": {
+			validator:      validator,
+			method:         http.MethodDelete,
+			path:           "/api/v1/rules/team-b",
+			token:          "team-a-writer",
+			expectedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := &API{store: store, logger: log.NewNopLogger()}
+			a.SetTokenValidator(tc.validator)
+
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules").Methods(http.MethodGet).Handler(a.AuthorizeToken(http.HandlerFunc(a.ListRules)))
+			router.Path("/api/v1/rules/{namespace}").Methods(http.MethodGet).Handler(a.AuthorizeToken(http.HandlerFunc(a.ListRules)))
+			router.Path("/api/v1/rules/{namespace}").Methods(http.MethodDelete).Handler(a.AuthorizeToken(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})))
+
+			req := requestFor(t, tc.method, "https://localhost:8080"+tc.path, nil, "user1")
+			if tc.token != "" {
+				req.Header.Set(rulesTokenHeader, tc.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+
+			if tc.expectedNamespaces != nil {
+				groups := map[string]interface{}{}
+				require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &groups))
+				namespaces := make([]string, 0, len(groups))
+				for ns := range groups {
+					namespaces = append(namespaces, ns)
+				}
+				assert.ElementsMatch(t, tc.expectedNamespaces, namespaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+)
+
+const notificationJournalFilename = "notifications.json"
+
+// notificationJournal tracks the alerts queued for sending to the Alertmanager which have not
+// been acknowledged yet, so that they can be persisted periodically and when the notifier stops,
+// and resent when the notifier of the tenant is started again, for example after a ruler restart.
+//
+// Like the queue of the notifier, the journal keeps up to capacity alerts, dropping the oldest ones
+// first, and the alerts whose notification expired are pruned.
+type notificationJournal struct {
+	path     string
+	capacity int
+
+	mtx sync.Mutex
+	// Latest not acknowledged notification of each alert, by alert labels, and the elements of the
+	// list of the notifications in the order they were queued.
+	pending map[string]*list.Element
+	order   *list.List
+
+	resent  prometheus.Counter
+	dropped prometheus.Counter
+}
+
+func newNotificationJournal(dir, userID string, capacity int, resent, dropped prometheus.Counter) *notificationJournal {
+	return &notificationJournal{
+		path:     filepath.Join(dir, userID, notificationJournalFilename),
+		capacity: capacity,
+		pending:  map[string]*list.Element{},
+		order:    list.New(),
+		resent:   resent,
+		dropped:  dropped,
+	}
+}
+
+// add records the alerts as queued.
+func (j *notificationJournal) add(alerts ...*notifier.Alert) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	for _, a := range alerts {
+		// The notifier modifies the queued alerts, so a copy is recorded.
+		c := *a
+		key := c.Labels.String()
+		if e, ok := j.pending[key]; ok {
+			j.order.Remove(e)
+		}
+		j.pending[key] = j.order.PushBack(&c)
+	}
+
+	// The notifier drops the oldest alerts once its queue is full.
+	for j.capacity > 0 && j.order.Len() > j.capacity {
+		j.remove(j.order.Front())
+	}
+}
+
+// prune removes the alerts whose notification expired before now, which aren't resent.
+func (j *notificationJournal) prune(now time.Time) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	for e := j.order.Front(); e != nil; {
+		next := e.Next()
+		if a := e.Value.(*notifier.Alert); !a.EndsAt.IsZero() && a.EndsAt.Before(now) {
+			j.remove(e)
+		}
+		e = next
+	}
+}
+
+// remove removes the alert of the list element. It must be called with the lock held.
+func (j *notificationJournal) remove(e *list.Element) {
+	delete(j.pending, e.Value.(*notifier.Alert).Labels.String())
+	j.order.Remove(e)
+}
+
+// postedAlert is the subset of the Alertmanager v2 API payload needed to acknowledge alerts.
+type postedAlert struct {
+	Labels map[string]string `json:"labels"`
+	EndsAt time.Time         `json:"endsAt"`
+}
+
+// ack records the alerts in the body of a request successfully sent to the Alertmanager
+// as acknowledged. An alert whose notification changed since the request was sent is not
+// acknowledged, because its latest notification is still queued.
+func (j *notificationJournal) ack(body []byte) error {
+	var posted []postedAlert
+	if err := json.Unmarshal(body, &posted); err != nil {
+		return err
+	}
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	for _, p := range posted {
+		e, ok := j.pending[labels.FromMap(p.Labels).String()]
+		if ok && e.Value.(*notifier.Alert).EndsAt.Truncate(time.Millisecond).Equal(p.EndsAt.Truncate(time.Millisecond)) {
+			j.remove(e)
+		}
+	}
+	return nil
+}
+
+// save persists the alerts not acknowledged yet.
+func (j *notificationJournal) save() error {
+	j.mtx.Lock()
+	alerts := make([]*notifier.Alert, 0, j.order.Len())
+	for e := j.order.Front(); e != nil; e = e.Next() {
+		alerts = append(alerts, e.Value.(*notifier.Alert))
+	}
+	j.mtx.Unlock()
+
+	if len(alerts) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o777); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a crash doesn't leave a partially written journal.
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// load returns the persisted alerts which should be resent, and removes them from the disk.
+// Alerts whose notification expired in the meantime are dropped.
+func (j *notificationJournal) load(now time.Time) ([]*notifier.Alert, error) {
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*notifier.Alert
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode notification journal %s", j.path)
+	}
+	if err := os.Remove(j.path); err != nil {
+		return nil, err
+	}
+
+	resend := alerts[:0]
+	for _, a := range alerts {
+		if !a.EndsAt.IsZero() && a.EndsAt.Before(now) {
+			j.dropped.Inc()
+			continue
+		}
+		resend = append(resend, a)
+	}
+	j.resent.Add(float64(len(resend)))
+	return resend, nil
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationJournal(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	firing := &notifier.Alert{Labels: labels.FromStrings("alertname", "firing"), EndsAt: now.Add(time.Hour)}
+	resolved := &notifier.Alert{Labels: labels.FromStrings("alertname", "resolved"), EndsAt: now.Add(-time.Minute)}
+	acked := &notifier.Alert{Labels: labels.FromStrings("alertname", "acked"), EndsAt: now.Add(time.Hour)}
+
+	resent, dropped := prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})
+	j := newNotificationJournal(dir, "user-1", 0, resent, dropped)
+	j.add(firing, resolved, acked)
+
+	// The notification of an alert which changed since it was sent is not acknowledged.
+	changed := *firing
+	changed.EndsAt = now.Add(2 * time.Hour)
+	body, err := json.Marshal([]*notifier.Alert{acked, &changed})
+	require.NoError(t, err)
+	require.NoError(t, j.ack(body))
+
+	require.NoError(t, j.save())
+
+	j = newNotificationJournal(dir, "user-1", 0, resent, dropped)
+	alerts, err := j.load(now)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, firing.Labels, alerts[0].Labels)
+	assert.Equal(t, float64(1), testutil.ToFloat64(resent))
+	assert.Equal(t, float64(1), testutil.ToFloat64(dropped))
+
+	// The persisted notifications are loaded only once.
+	alerts, err = j.load(now)
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+}
+
+func TestNotifier_ShouldResendPersistedNotificationsAfterRestart(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.NotificationQueueDir = t.TempDir()
+
+	// No Alertmanager is configured, so the notifications are never sent.
+	manager := newManager(t, cfg)
+	n, err := manager.getOrCreateNotifier("user-1")
+	require.NoError(t, err)
+	n.Send(&notifier.Alert{Labels: labels.FromStrings("alertname", "test"), EndsAt: time.Now().Add(time.Hour)})
+	manager.Stop()
+
+	manager = newManager(t, cfg)
+	defer manager.Stop()
+	_, err = manager.getOrCreateNotifier("user-1")
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(manager.registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_ruler_persisted_notifications_resent_total Total number of persisted notifications resent to the Alertmanager after the notifier of the tenant was restarted.
+		# TYPE cortex_ruler_persisted_notifications_resent_total counter
+		cortex_ruler_persisted_notifications_resent_total{user="user-1"} 1
+	`), "cortex_ruler_persisted_notifications_resent_total"))
+}
+
+func TestNotificationJournal_Prune(t *testing.T) {
+	now := time.Now()
+	j := newNotificationJournal(t.TempDir(), "user-1", 2, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+
+	alert := func(name string, endsAt time.Time) *notifier.Alert {
+		return &notifier.Alert{Labels: labels.FromStrings("alertname", name), EndsAt: endsAt}
+	}
+	names := func() []string {
+		var names []string
+		for e := j.order.Front(); e != nil; e = e.Next() {
+			names = append(names, e.Value.(*notifier.Alert).Labels.Get("alertname"))
+		}
+		return names
+	}
+
+	// The oldest alerts are dropped once the capacity is exceeded, like the notifier does.
+	j.add(alert("first", now.Add(time.Hour)), alert("second", now.Add(time.Minute)))
+	j.add(alert("first", now.Add(time.Hour)), alert("third", now.Add(time.Hour)))
+	assert.Equal(t, []string{"first", "third"}, names())
+	assert.Len(t, j.pending, 2)
+
+	// The alerts whose notification expired are pruned.
+	j.add(alert("second", now.Add(-time.Minute)))
+	assert.Equal(t, []string{"third", "second"}, names())
+	j.prune(now)
+	assert.Equal(t, []string{"third"}, names())
+	assert.Len(t, j.pending, 1)
+}
+
+func TestNotifier_ShouldPersistNotificationsPeriodically(t *testing.T) {
+	prevSaveInterval := notificationJournalSaveInterval
+	notificationJournalSaveInterval = 10 * time.Millisecond
+	t.Cleanup(func() { notificationJournalSaveInterval = prevSaveInterval })
+
+	cfg := defaultRulerConfig(t)
+	cfg.NotificationQueueDir = t.TempDir()
+
+	// No Alertmanager is configured, so the notifications are never sent.
+	manager := newManager(t, cfg)
+	defer manager.Stop()
+	n, err := manager.getOrCreateNotifier("user-1")
+	require.NoError(t, err)
+	n.Send(&notifier.Alert{Labels: labels.FromStrings("alertname", "test"), EndsAt: time.Now().Add(time.Hour)})
+
+	// The queued notifications are persisted while the notifier is running.
+	path := filepath.Join(cfg.NotificationQueueDir, "user-1", notificationJournalFilename)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var alerts []*notifier.Alert
+	require.NoError(t, json.Unmarshal(data, &alerts))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, labels.FromStrings("alertname", "test"), alerts[0].Labels)
+}
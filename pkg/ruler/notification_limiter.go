@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/notifier"
+	"golang.org/x/time/rate"
+)
+
+// notificationLimiter enforces the per-tenant notifications rate limit and deduplication window,
+// to protect the Alertmanager from a tenant whose rules suddenly fire for many series.
+type notificationLimiter struct {
+	userID string
+	limits RulesLimits
+
+	mtx     sync.Mutex
+	limiter *rate.Limiter
+	// Last time each notification has been sent, by deduplication key.
+	sent      map[string]time.Time
+	lastPrune time.Time
+
+	rateLimited  prometheus.Counter
+	deduplicated prometheus.Counter
+}
+
+func newNotificationLimiter(userID string, limits RulesLimits, rateLimited, deduplicated prometheus.Counter) *notificationLimiter {
+	return &notificationLimiter{
+		userID:       userID,
+		limits:       limits,
+		sent:         map[string]time.Time{},
+		rateLimited:  rateLimited,
+		deduplicated: deduplicated,
+	}
+}
+
+// filter returns the alerts whose notification should be sent, dropping the notifications
+// which are identical to one sent within the deduplication window, and the ones exceeding
+// the rate limit.
+func (l *notificationLimiter) filter(now time.Time, alerts []*notifier.Alert) []*notifier.Alert {
+	window := l.limits.RulerNotificationDeduplicationWindow(l.userID)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.updateRateLimit()
+	if window > 0 && now.Sub(l.lastPrune) > window {
+		l.prune(now, window)
+	}
+
+	allowed := make([]*notifier.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		var key string
+		if window > 0 {
+			key = notificationDeduplicationKey(now, a)
+			if sentAt, ok := l.sent[key]; ok && now.Sub(sentAt) < window {
+				l.deduplicated.Inc()
+				continue
+			}
+		}
+
+		if !l.limiter.AllowN(now, 1) {
+			l.rateLimited.Inc()
+			continue
+		}
+
+		if window > 0 {
+			l.sent[key] = now
+		}
+		allowed = append(allowed, a)
+	}
+	return allowed
+}
+
+// updateRateLimit applies the current limits, which may have been changed by a runtime config update.
+func (l *notificationLimiter) updateRateLimit() {
+	limit := rate.Inf
+	burst := 0
+	if perSecond := l.limits.RulerNotificationRateLimit(l.userID); perSecond > 0 {
+		limit = rate.Limit(perSecond)
+		burst = l.limits.RulerNotificationRateLimitBurst(l.userID)
+	}
+
+	if l.limiter == nil {
+		l.limiter = rate.NewLimiter(limit, burst)
+		return
+	}
+	if l.limiter.Limit() != limit {
+		l.limiter.SetLimit(limit)
+	}
+	if l.limiter.Burst() != burst {
+		l.limiter.SetBurst(burst)
+	}
+}
+
+// prune removes the notifications sent before the deduplication window.
+func (l *notificationLimiter) prune(now time.Time, window time.Duration) {
+	for key, sentAt := range l.sent {
+		if now.Sub(sentAt) >= window {
+			delete(l.sent, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// notificationDeduplicationKey returns the key identifying identical notifications. The end time of
+// firing alerts is not part of it, because it's extended each time their notification is resent.
+func notificationDeduplicationKey(now time.Time, a *notifier.Alert) string {
+	sb := strings.Builder{}
+	sb.WriteString(a.Labels.String())
+	sb.WriteString(a.Annotations.String())
+	sb.WriteString(strconv.FormatInt(a.StartsAt.UnixNano(), 10))
+	if a.ResolvedAt(now) {
+		sb.WriteString("resolved")
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationLimiter(t *testing.T) {
+	now := time.Now()
+	firing := func(name string) *notifier.Alert {
+		return &notifier.Alert{Labels: labels.FromStrings("alertname", name), StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}
+	}
+	resolved := func(name string) *notifier.Alert {
+		a := firing(name)
+		a.EndsAt = now.Add(-time.Second)
+		return a
+	}
+
+	tests := map[string]struct {
+		limits               ruleLimits
+		batches              [][]*notifier.Alert
+		expectedSent         int
+		expectedRateLimited  int
+		expectedDeduplicated int
+	}{
+		"no limits": {
+			batches:      [][]*notifier.Alert{{firing("a"), firing("b")}, {firing("a"), firing("b")}},
+			expectedSent: 4,
+		},
+		"rate limited": {
+			limits:              ruleLimits{notificationRate: 0.001, notificationBurst: 3},
+			batches:             [][]*notifier.Alert{{firing("a"), firing("b")}, {firing("a"), firing("b")}},
+			expectedSent:        3,
+			expectedRateLimited: 1,
+		},
+		"deduplicated within window": {
+			limits:               ruleLimits{notificationDedup: time.Minute},
+			batches:              [][]*notifier.Alert{{firing("a"), firing("b")}, {firing("a"), resolved("b")}},
+			expectedSent:         3,
+			expectedDeduplicated: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rateLimited, deduplicated := prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})
+			l := newNotificationLimiter("user-1", tc.limits, rateLimited, deduplicated)
+
+			sent := 0
+			for i, batch := range tc.batches {
+				allowed := l.filter(now.Add(time.Duration(i)*time.Second), batch)
+				sent += len(allowed)
+			}
+
+			assert.Equal(t, tc.expectedSent, sent)
+			assert.Equal(t, float64(tc.expectedRateLimited), testutil.ToFloat64(rateLimited))
+			assert.Equal(t, float64(tc.expectedDeduplicated), testutil.ToFloat64(deduplicated))
+		})
+	}
+}
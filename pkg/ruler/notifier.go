@@ -7,20 +7,31 @@ package ruler
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"hash/fnv"
+	"io"
+	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	gklog "github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/sigv4"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/thanos-io/thanos/pkg/cacheutil"
+	"go.uber.org/atomic"
+	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/grafana/mimir/pkg/util"
 )
@@ -28,11 +39,16 @@ import (
 type NotifierConfig struct {
 	TLS       tls.ClientConfig `yaml:",inline"`
 	BasicAuth util.BasicAuth   `yaml:",inline"`
+
+	ProxyURL             flagext.URLValue `yaml:"proxy_url" category:"advanced"`
+	ProxyFromEnvironment bool             `yaml:"proxy_from_environment" category:"advanced"`
 }
 
 func (cfg *NotifierConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.TLS.RegisterFlagsWithPrefix("ruler.alertmanager-client", f)
 	cfg.BasicAuth.RegisterFlagsWithPrefix("ruler.alertmanager-client.", f)
+	f.Var(&cfg.ProxyURL, "ruler.alertmanager-client.proxy-url", "URL of the HTTP proxy to send notifications to the Alertmanager through.")
+	f.BoolVar(&cfg.ProxyFromEnvironment, "ruler.alertmanager-client.proxy-from-environment", false, "Send notifications to the Alertmanager through the proxy configured by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. Ignored if -ruler.alertmanager-client.proxy-url is set.")
 }
 
 // rulerNotifier bundles a notifier.Manager together with an associated
@@ -44,18 +60,132 @@ type rulerNotifier struct {
 	sdManager *discovery.Manager
 	wg        sync.WaitGroup
 	logger    gklog.Logger
+
+	// Optional journal of the queued notifications, persisted periodically and when the notifier stops.
+	journal *notificationJournal
+	done    chan struct{}
+	limiter *notificationLimiter
+
+	// Number of alerts queued for sending to the Alertmanager, and number of requests being sent.
+	queueCapacity int
+	pendingMtx    sync.Mutex
+	pending       int
+	lastBatch     uint64
+	sending       atomic.Int64
+
+	// Per-tenant settings of the applied config, and HTTP clients signing the requests with SigV4 with
+	// these settings, by the client they wrap. They're read while the notifications are sent, and
+	// replaced when a config is applied.
+	settingsMtx  sync.Mutex
+	settings     notifierTenantSettings
+	sigV4Clients map[*http.Client]*http.Client
 }
 
-func newRulerNotifier(o *notifier.Options, l gklog.Logger) *rulerNotifier {
+func newRulerNotifier(o *notifier.Options, journal *notificationJournal, limiter *notificationLimiter, l gklog.Logger) *rulerNotifier {
 	sdCtx, sdCancel := context.WithCancel(context.Background())
-	return &rulerNotifier{
-		notifier:  notifier.NewManager(o, l),
-		sdCancel:  sdCancel,
-		sdManager: discovery.NewManager(sdCtx, l),
-		logger:    l,
+	rn := &rulerNotifier{
+		sdCancel:      sdCancel,
+		sdManager:     discovery.NewManager(sdCtx, l),
+		logger:        l,
+		journal:       journal,
+		done:          make(chan struct{}),
+		limiter:       limiter,
+		queueCapacity: o.QueueCapacity,
+	}
+
+	opts := *o
+	do := o.Do
+	if do == nil {
+		do = ctxhttp.Do
+	}
+	opts.Do = func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+		rn.sending.Inc()
+		defer rn.sending.Dec()
+		rn.dequeue(req)
+		return do(ctx, client, req)
+	}
+	rn.notifier = notifier.NewManager(&opts, l)
+	return rn
+}
+
+// Send queues the alerts for sending to the Alertmanager.
+func (rn *rulerNotifier) Send(alerts ...*notifier.Alert) {
+	if rn.limiter != nil {
+		alerts = rn.limiter.filter(time.Now(), alerts)
+		if len(alerts) == 0 {
+			return
+		}
+	}
+	if rn.journal != nil {
+		rn.journal.add(alerts...)
+	}
+	rn.enqueue(len(alerts))
+	rn.notifier.Send(alerts...)
+}
+
+// enqueue records n alerts as queued. The alerts sent while no Alertmanager is discovered aren't recorded,
+// because the notifier drops them without sending them.
+func (rn *rulerNotifier) enqueue(n int) {
+	if len(rn.notifier.Alertmanagers()) == 0 {
+		return
+	}
+
+	rn.pendingMtx.Lock()
+	defer rn.pendingMtx.Unlock()
+
+	rn.pending += n
+	// The notifier drops the oldest alerts once its queue is full.
+	if rn.queueCapacity > 0 && rn.pending > rn.queueCapacity {
+		rn.pending = rn.queueCapacity
+	}
+}
+
+// dequeue records the alerts of the request as no longer queued. The notifier sends each batch of alerts
+// to all the Alertmanagers, one batch at a time, so the requests with the same body as the previous request
+// are the same batch sent to another Alertmanager.
+func (rn *rulerNotifier) dequeue(req *http.Request) {
+	if req.GetBody == nil {
+		return
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	defer body.Close()
+
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		return
+	}
+	var alerts []json.RawMessage
+	if err := json.Unmarshal(buf, &alerts); err != nil {
+		level.Warn(rn.logger).Log("msg", "failed to count sent notifications", "err", err)
+		return
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(buf)
+	batch := h.Sum64()
+
+	rn.pendingMtx.Lock()
+	defer rn.pendingMtx.Unlock()
+
+	if batch == rn.lastBatch {
+		return
+	}
+	rn.lastBatch = batch
+	rn.pending -= len(alerts)
+	if rn.pending < 0 {
+		rn.pending = 0
 	}
 }
 
+// pendingAlerts returns the number of alerts queued for sending to the Alertmanager.
+func (rn *rulerNotifier) pendingAlerts() int {
+	rn.pendingMtx.Lock()
+	defer rn.pendingMtx.Unlock()
+	return rn.pending
+}
+
 // run starts the notifier. This function doesn't block and returns immediately.
 func (rn *rulerNotifier) run() {
 	rn.wg.Add(2)
@@ -69,13 +199,55 @@ func (rn *rulerNotifier) run() {
 		rn.notifier.Run(rn.sdManager.SyncCh())
 		rn.wg.Done()
 	}()
+
+	if rn.journal != nil {
+		// Resend the notifications which were still queued when the previous notifier of the tenant stopped.
+		alerts, err := rn.journal.load(time.Now())
+		if err != nil {
+			level.Error(rn.logger).Log("msg", "failed to load persisted notifications", "err", err)
+		} else if len(alerts) > 0 {
+			level.Info(rn.logger).Log("msg", "resending persisted notifications", "alerts", len(alerts))
+			rn.Send(alerts...)
+		}
+
+		// Persist the queued notifications periodically, so that they're not lost if the ruler crashes.
+		rn.wg.Add(1)
+		go func() {
+			defer rn.wg.Done()
+			rn.saveJournal()
+		}()
+	}
+}
+
+// saveJournal persists the queued notifications every notificationJournalSaveInterval, until the notifier stops.
+func (rn *rulerNotifier) saveJournal() {
+	ticker := time.NewTicker(notificationJournalSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rn.done:
+			return
+		case <-ticker.C:
+			rn.journal.prune(time.Now())
+			if err := rn.journal.save(); err != nil {
+				level.Warn(rn.logger).Log("msg", "failed to persist queued notifications", "err", err)
+			}
+		}
+	}
 }
 
-func (rn *rulerNotifier) applyConfig(cfg *config.Config) error {
+func (rn *rulerNotifier) applyConfig(cfg *config.Config, settings notifierTenantSettings) error {
+	cfg = settings.apply(cfg)
 	if err := rn.notifier.ApplyConfig(cfg); err != nil {
 		return err
 	}
 
+	rn.settingsMtx.Lock()
+	rn.settings = settings
+	rn.sigV4Clients = map[*http.Client]*http.Client{}
+	rn.settingsMtx.Unlock()
+
 	sdCfgs := make(map[string]discovery.Configs)
 	for k, v := range cfg.AlertingConfig.AlertmanagerConfigs.ToMap() {
 		sdCfgs[k] = v.ServiceDiscoveryConfigs
@@ -83,10 +255,210 @@ func (rn *rulerNotifier) applyConfig(cfg *config.Config) error {
 	return rn.sdManager.ApplyConfig(sdCfgs)
 }
 
+// tenantSettings returns the per-tenant settings of the applied config.
+func (rn *rulerNotifier) tenantSettings() notifierTenantSettings {
+	rn.settingsMtx.Lock()
+	defer rn.settingsMtx.Unlock()
+	return rn.settings
+}
+
+// drain waits until the queued notifications have been sent to the Alertmanager, or dropped after failing to
+// be sent, or until the context is done. It returns whether the notifications have all been sent or dropped.
+func (rn *rulerNotifier) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+	// The notifier sends a batch to each Alertmanager in a separate request, so no alert must be found queued,
+	// with no request in flight, by two consecutive checks.
+	idleChecks := 0
+	for {
+		if rn.pendingAlerts() == 0 && rn.sending.Load() == 0 {
+			idleChecks++
+		} else {
+			idleChecks = 0
+		}
+		if idleChecks == 2 {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
 func (rn *rulerNotifier) stop() {
 	rn.sdCancel()
 	rn.notifier.Stop()
+	close(rn.done)
 	rn.wg.Wait()
+
+	if rn.journal != nil {
+		rn.journal.prune(time.Now())
+		if err := rn.journal.save(); err != nil {
+			level.Error(rn.logger).Log("msg", "failed to persist queued notifications", "err", err)
+		}
+	}
+}
+
+// drainCheckInterval is the interval between the checks of the queue of a notifier being drained.
+const drainCheckInterval = 50 * time.Millisecond
+
+// notificationJournalSaveInterval is the interval between the periodic saves of the journal of the queued notifications.
+var notificationJournalSaveInterval = 30 * time.Second
+
+// httpClient returns the HTTP client to use to send notifications instead of client, which
+// is the client created by the Prometheus notifier from the applied config.
+func (rn *rulerNotifier) httpClient(client *http.Client) (*http.Client, error) {
+	rn.settingsMtx.Lock()
+	defer rn.settingsMtx.Unlock()
+
+	sigV4 := rn.settings.sigV4
+	if sigV4.Region == "" {
+		return client, nil
+	}
+	if c, ok := rn.sigV4Clients[client]; ok {
+		return c, nil
+	}
+
+	rt, err := sigv4.NewSigV4RoundTripper(&sigV4, client.Transport)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create SigV4 round tripper")
+	}
+	c := &http.Client{Transport: rt, CheckRedirect: client.CheckRedirect, Jar: client.Jar, Timeout: client.Timeout}
+	rn.sigV4Clients[client] = c
+	return c, nil
+}
+
+// notifierTenantSettings are the per-tenant settings of the notifier.
+type notifierTenantSettings struct {
+	timeout time.Duration
+	// Overrides of the TLS settings of the Alertmanager client. Empty values are not overridden.
+	tls    tls.ClientConfig
+	sigV4  sigv4.SigV4Config
+	oauth2 oauth2Settings
+}
+
+// oauth2Settings are the settings of the OAuth2 client credentials authentication.
+type oauth2Settings struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	// Comma-separated list of scopes.
+	scopes string
+}
+
+func (s oauth2Settings) scopesList() []string {
+	var scopes []string
+	for _, scope := range strings.Split(s.scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func newNotifierTenantSettings(userID string, limits RulesLimits) notifierTenantSettings {
+	return notifierTenantSettings{
+		timeout: limits.RulerNotificationTimeout(userID),
+		tls: tls.ClientConfig{
+			CertPath:   limits.RulerAlertmanagerClientTLSCertPath(userID),
+			KeyPath:    limits.RulerAlertmanagerClientTLSKeyPath(userID),
+			CAPath:     limits.RulerAlertmanagerClientTLSCAPath(userID),
+			ServerName: limits.RulerAlertmanagerClientTLSServerName(userID),
+		},
+		sigV4: sigv4.SigV4Config{
+			Region:    limits.RulerAlertmanagerClientSigV4Region(userID),
+			AccessKey: limits.RulerAlertmanagerClientSigV4AccessKey(userID),
+			SecretKey: config_util.Secret(limits.RulerAlertmanagerClientSigV4SecretKey(userID)),
+			Profile:   limits.RulerAlertmanagerClientSigV4Profile(userID),
+			RoleARN:   limits.RulerAlertmanagerClientSigV4RoleARN(userID),
+		},
+		oauth2: oauth2Settings{
+			clientID:     limits.RulerAlertmanagerClientOAuth2ClientID(userID),
+			clientSecret: limits.RulerAlertmanagerClientOAuth2ClientSecret(userID),
+			tokenURL:     limits.RulerAlertmanagerClientOAuth2TokenURL(userID),
+			scopes:       limits.RulerAlertmanagerClientOAuth2Scopes(userID),
+		},
+	}
+}
+
+// apply returns a copy of cfg with the settings applied to all Alertmanager configs.
+func (s notifierTenantSettings) apply(cfg *config.Config) *config.Config {
+	c := *cfg
+	c.AlertingConfig.AlertmanagerConfigs = make(config.AlertmanagerConfigs, 0, len(cfg.AlertingConfig.AlertmanagerConfigs))
+	for _, am := range cfg.AlertingConfig.AlertmanagerConfigs {
+		amCopy := *am
+		amCopy.Timeout = model.Duration(s.timeout)
+
+		tlsCfg := &amCopy.HTTPClientConfig.TLSConfig
+		if s.tls.CertPath != "" {
+			tlsCfg.CertFile = s.tls.CertPath
+		}
+		if s.tls.KeyPath != "" {
+			tlsCfg.KeyFile = s.tls.KeyPath
+		}
+		if s.tls.CAPath != "" {
+			tlsCfg.CAFile = s.tls.CAPath
+		}
+		if s.tls.ServerName != "" {
+			tlsCfg.ServerName = s.tls.ServerName
+		}
+
+		if s.oauth2.clientID != "" {
+			amCopy.HTTPClientConfig.BasicAuth = nil
+			amCopy.HTTPClientConfig.OAuth2 = &config_util.OAuth2{
+				ClientID:     s.oauth2.clientID,
+				ClientSecret: config_util.Secret(s.oauth2.clientSecret),
+				TokenURL:     s.oauth2.tokenURL,
+				Scopes:       s.oauth2.scopesList(),
+				// The token endpoint is reached with the same TLS settings as the Alertmanager.
+				TLSConfig: amCopy.HTTPClientConfig.TLSConfig,
+			}
+		}
+
+		c.AlertingConfig.AlertmanagerConfigs = append(c.AlertingConfig.AlertmanagerConfigs, &amCopy)
+	}
+	return &c
+}
+
+// doWithRetries sends the request to the Alertmanager, retrying up to maxRetries times
+// on network errors, and on 5xx and 429 responses.
+func doWithRetries(ctx context.Context, client *http.Client, req *http.Request, maxRetries int) (*http.Response, error) {
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	})
+
+	for attempt := 0; ; attempt++ {
+		resp, err := ctxhttp.Do(ctx, client, req)
+		if attempt >= maxRetries || req.GetBody == nil || !isRetriableNotificationResponse(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		retries.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// The body of the request has been consumed by the previous attempt.
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isRetriableNotificationResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests
 }
 
 // Builds a Prometheus config.Config from a ruler.Config with just the required
@@ -130,7 +502,6 @@ func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config
 		APIVersion:              config.AlertmanagerAPIVersionV2,
 		Scheme:                  url.Scheme,
 		PathPrefix:              url.Path,
-		Timeout:                 model.Duration(rulerConfig.NotificationTimeout),
 		ServiceDiscoveryConfigs: discovery.Configs{sdConfig},
 		HTTPClientConfig: config_util.HTTPClientConfig{
 			TLSConfig: config_util.TLSConfig{
@@ -143,6 +514,16 @@ func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config
 		},
 	}
 
+	if proxyURL := rulerConfig.Notifier.ProxyURL.URL; proxyURL != nil {
+		amConfig.HTTPClientConfig.ProxyURL = config_util.URL{URL: proxyURL}
+	} else if rulerConfig.Notifier.ProxyFromEnvironment {
+		// The proxy is resolved once for the configured URL, because the Prometheus notifier
+		// doesn't support resolving it from the environment for each request.
+		if proxyURL, err := http.ProxyFromEnvironment(&http.Request{URL: url}); err == nil && proxyURL != nil {
+			amConfig.HTTPClientConfig.ProxyURL = config_util.URL{URL: proxyURL}
+		}
+	}
+
 	// Check the URL for basic authentication information first
 	if url.User != nil {
 		amConfig.HTTPClientConfig.BasicAuth = &config_util.BasicAuth{
@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/services"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	otlpMetricsPath = "/v1/metrics"
+	otlpTracesPath  = "/v1/traces"
+
+	otlpInstrumentationName = "github.com/grafana/mimir/pkg/ruler"
+)
+
+var (
+	errInvalidOTLPExportInterval = errors.New("invalid ruler OTLP export interval, must be greater than zero")
+	errInvalidOTLPExportEndpoint = errors.New("invalid ruler OTLP export endpoint, must be an http or https URL")
+
+	// otlpExportedMetricsPrefixes are the prefixes of the metric families exported by the ruler over OTLP.
+	otlpExportedMetricsPrefixes = []string{
+		"cortex_ruler_",
+		"cortex_prometheus_rule_",
+		"cortex_prometheus_notifications_",
+	}
+
+	// otlpExportedRootSpans are the names of the root spans of the traces exported by the ruler over OTLP:
+	// the evaluations of the rules, the notifications sent to the Alertmanager and the syncs of the rules.
+	otlpExportedRootSpans = map[string]struct{}{
+		"rule":            {},
+		"notify":          {},
+		"ruler.syncRules": {},
+	}
+)
+
+type OTLPExportConfig struct {
+	Endpoint string           `yaml:"endpoint"`
+	Interval time.Duration    `yaml:"interval"`
+	Timeout  time.Duration    `yaml:"timeout"`
+	TLS      tls.ClientConfig `yaml:",inline"`
+}
+
+func (cfg *OTLPExportConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, "ruler.otlp-export.endpoint", "", "Base URL of the OTLP/HTTP endpoint to push the ruler's own metrics and traces to, for example http://otel-collector:4318. Metrics are periodically pushed to the /v1/metrics path of the endpoint, and the spans of the rule evaluations, of the notifications and of the rules syncs to the /v1/traces path, unless Jaeger tracing is configured. The export is disabled if empty.")
+	f.DurationVar(&cfg.Interval, "ruler.otlp-export.interval", 15*time.Second, "How frequently to push the ruler's own metrics to the OTLP endpoint.")
+	f.DurationVar(&cfg.Timeout, "ruler.otlp-export.timeout", 10*time.Second, "Timeout for each push of the ruler's own metrics or traces to the OTLP endpoint.")
+	cfg.TLS.RegisterFlagsWithPrefix("ruler.otlp-export", f)
+}
+
+func (cfg *OTLPExportConfig) Validate() error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errInvalidOTLPExportEndpoint
+	}
+	if cfg.Interval <= 0 {
+		return errInvalidOTLPExportInterval
+	}
+	return nil
+}
+
+// otlpExporter pushes the ruler telemetry to an OTLP/HTTP endpoint with the OpenTelemetry exporters: the ruler
+// metrics, gathered from a prometheus.Gatherer, periodically, and the spans of the ruler traces as they end.
+type otlpExporter struct {
+	services.Service
+
+	cfg            OTLPExportConfig
+	gatherer       prometheus.Gatherer
+	metricsClient  otlpmetric.Client
+	tracesExporter *otlptrace.Exporter
+	tracerProvider *sdktrace.TracerProvider
+	resource       *resourcepb.Resource
+	logger         log.Logger
+	exportsTotal   prometheus.Counter
+	exportsFailed  prometheus.Counter
+}
+
+func newOTLPExporter(cfg OTLPExportConfig, instanceID string, gatherer prometheus.Gatherer, logger log.Logger, reg prometheus.Registerer) (*otlpExporter, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errInvalidOTLPExportEndpoint
+	}
+	basePath := strings.TrimSuffix(endpoint.Path, "/")
+
+	metricsOpts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(endpoint.Host),
+		otlpmetrichttp.WithURLPath(basePath + otlpMetricsPath),
+		otlpmetrichttp.WithTimeout(cfg.Timeout),
+		// The metrics are cumulative, so a failed push is superseded by the next one.
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{Enabled: false}),
+	}
+	tracesOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(basePath + otlpTracesPath),
+		otlptracehttp.WithTimeout(cfg.Timeout),
+	}
+	if endpoint.Scheme == "http" {
+		metricsOpts = append(metricsOpts, otlpmetrichttp.WithInsecure())
+		tracesOpts = append(tracesOpts, otlptracehttp.WithInsecure())
+	} else {
+		tlsCfg, err := cfg.TLS.GetTLSConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ruler OTLP export TLS config")
+		}
+		metricsOpts = append(metricsOpts, otlpmetrichttp.WithTLSClientConfig(tlsCfg))
+		tracesOpts = append(tracesOpts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceNameKey.String("mimir-ruler"),
+		semconv.ServiceInstanceIDKey.String(instanceID),
+	)
+
+	tracesExporter := otlptrace.NewUnstarted(otlptracehttp.NewClient(tracesOpts...))
+	e := &otlpExporter{
+		cfg:            cfg,
+		gatherer:       gatherer,
+		metricsClient:  otlpmetrichttp.NewClient(metricsOpts...),
+		tracesExporter: tracesExporter,
+		tracerProvider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(tracesExporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(otlpRootSpansSampler{})),
+		),
+		resource: otlpResource(res),
+		logger:   logger,
+		exportsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_otlp_exports_total",
+			Help: "Number of times the ruler tried to push its own metrics to the OTLP endpoint.",
+		}),
+		exportsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_otlp_exports_failed_total",
+			Help: "Number of times the ruler failed to push its own metrics to the OTLP endpoint.",
+		}),
+	}
+
+	e.Service = services.NewTimerService(cfg.Interval, e.starting, e.iteration, e.stopping).WithName("ruler OTLP exporter")
+	return e, nil
+}
+
+func (e *otlpExporter) starting(ctx context.Context) error {
+	if err := e.metricsClient.Start(ctx); err != nil {
+		return errors.Wrap(err, "start the OTLP metrics client")
+	}
+	if err := e.tracesExporter.Start(ctx); err != nil {
+		return errors.Wrap(err, "start the OTLP traces exporter")
+	}
+
+	// The ruler spans are created with the global OpenTracing tracer, which is the Jaeger tracer if Jaeger
+	// tracing is configured.
+	if opentracing.IsGlobalTracerRegistered() {
+		level.Warn(e.logger).Log("msg", "the ruler traces are not pushed to the OTLP endpoint because a tracer is already configured", "endpoint", e.cfg.Endpoint)
+		return nil
+	}
+	tracer, _ := otelbridge.NewTracerPair(e.tracerProvider.Tracer(otlpInstrumentationName))
+	tracer.SetTextMapPropagator(propagation.TraceContext{})
+	tracer.SetWarningHandler(func(msg string) {
+		level.Debug(e.logger).Log("msg", "OpenTracing bridge warning", "warning", msg)
+	})
+	opentracing.SetGlobalTracer(tracer)
+	return nil
+}
+
+func (e *otlpExporter) iteration(ctx context.Context) error {
+	if err := e.export(ctx, time.Now()); err != nil {
+		level.Warn(e.logger).Log("msg", "failed to push ruler metrics to the OTLP endpoint", "endpoint", e.cfg.Endpoint, "err", err)
+	}
+	return nil
+}
+
+func (e *otlpExporter) stopping(_ error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	defer cancel()
+
+	// The spans ended but not pushed yet are pushed before stopping.
+	if err := e.tracerProvider.Shutdown(ctx); err != nil {
+		level.Warn(e.logger).Log("msg", "failed to push ruler traces to the OTLP endpoint", "endpoint", e.cfg.Endpoint, "err", err)
+	}
+	return e.metricsClient.Stop(ctx)
+}
+
+func (e *otlpExporter) export(ctx context.Context, now time.Time) (err error) {
+	e.exportsTotal.Inc()
+	defer func() {
+		if err != nil {
+			e.exportsFailed.Inc()
+		}
+	}()
+
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, "gather metrics")
+	}
+
+	return e.metricsClient.UploadMetrics(ctx, []*metricpb.ResourceMetrics{{
+		Resource: e.resource,
+		InstrumentationLibraryMetrics: []*metricpb.InstrumentationLibraryMetrics{{
+			InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: otlpInstrumentationName},
+			Metrics:                otlpMetricsFromFamilies(families, now),
+		}},
+	}})
+}
+
+// otlpRootSpansSampler samples the traces whose root span is a span of the ruler listed in otlpExportedRootSpans,
+// so that only the ruler traces are pushed, even if the global tracer is used by the other components of the process.
+type otlpRootSpansSampler struct{}
+
+func (otlpRootSpansSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if _, ok := otlpExportedRootSpans[p.Name]; ok {
+		return sdktrace.SamplingResult{Decision: sdktrace.RecordAndSample}
+	}
+	return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+}
+
+func (otlpRootSpansSampler) Description() string {
+	return "RulerRootSpans"
+}
+
+// otlpMetricsFromFamilies converts the ruler metric families to OTLP metrics. Families not
+// owned by the ruler are skipped.
+func otlpMetricsFromFamilies(families []*dto.MetricFamily, now time.Time) []*metricpb.Metric {
+	ts := uint64(now.UnixNano())
+
+	var metrics []*metricpb.Metric
+	for _, mf := range families {
+		if !isOTLPExportedMetric(mf.GetName()) {
+			continue
+		}
+
+		m := &metricpb.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &metricpb.Sum{AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, IsMonotonic: true}
+			for _, s := range mf.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, otlpNumberDataPoint(s, ts, s.GetCounter().GetValue()))
+			}
+			m.Data = &metricpb.Metric_Sum{Sum: sum}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &metricpb.Gauge{}
+			for _, s := range mf.GetMetric() {
+				v := s.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					v = s.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, otlpNumberDataPoint(s, ts, v))
+			}
+			m.Data = &metricpb.Metric_Gauge{Gauge: gauge}
+		case dto.MetricType_HISTOGRAM:
+			histogram := &metricpb.Histogram{AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+			for _, s := range mf.GetMetric() {
+				h := s.GetHistogram()
+				dp := &metricpb.HistogramDataPoint{
+					Attributes:   otlpAttributes(s),
+					TimeUnixNano: ts,
+					Count:        h.GetSampleCount(),
+					Sum:          h.GetSampleSum(),
+				}
+				// Prometheus buckets are cumulative, while OTLP ones are not.
+				prev := uint64(0)
+				for _, b := range h.GetBucket() {
+					dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+					dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prev)
+					prev = b.GetCumulativeCount()
+				}
+				dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prev)
+				histogram.DataPoints = append(histogram.DataPoints, dp)
+			}
+			m.Data = &metricpb.Metric_Histogram{Histogram: histogram}
+		case dto.MetricType_SUMMARY:
+			summary := &metricpb.Summary{}
+			for _, s := range mf.GetMetric() {
+				sm := s.GetSummary()
+				dp := &metricpb.SummaryDataPoint{
+					Attributes:   otlpAttributes(s),
+					TimeUnixNano: ts,
+					Count:        sm.GetSampleCount(),
+					Sum:          sm.GetSampleSum(),
+				}
+				for _, q := range sm.GetQuantile() {
+					dp.QuantileValues = append(dp.QuantileValues, &metricpb.SummaryDataPoint_ValueAtQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				summary.DataPoints = append(summary.DataPoints, dp)
+			}
+			m.Data = &metricpb.Metric_Summary{Summary: summary}
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func isOTLPExportedMetric(name string) bool {
+	for _, p := range otlpExportedMetricsPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func otlpNumberDataPoint(m *dto.Metric, ts uint64, v float64) *metricpb.NumberDataPoint {
+	return &metricpb.NumberDataPoint{
+		Attributes:   otlpAttributes(m),
+		TimeUnixNano: ts,
+		Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: v},
+	}
+}
+
+func otlpAttributes(m *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		attrs = append(attrs, otlpStringAttribute(l.GetName(), l.GetValue()))
+	}
+	return attrs
+}
+
+func otlpStringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// otlpResource converts the resource of the ruler traces to the resource of the ruler metrics.
+func otlpResource(res *resource.Resource) *resourcepb.Resource {
+	attrs := make([]*commonpb.KeyValue, 0, res.Len())
+	for _, kv := range res.Attributes() {
+		attrs = append(attrs, otlpStringAttribute(string(kv.Key), kv.Value.Emit()))
+	}
+	return &resourcepb.Resource{Attributes: attrs}
+}
@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExporter_Export(t *testing.T) {
+	var received collectormetricpb.ExportMetricsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/otlp/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(body, &received))
+	}))
+	t.Cleanup(server.Close)
+
+	source := prometheus.NewRegistry()
+	promauto.With(source).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_test_total",
+		Help: "Test counter.",
+	}, []string{"user"}).WithLabelValues("user-1").Add(3)
+	promauto.With(source).NewGauge(prometheus.GaugeOpts{
+		Name: "cortex_prometheus_rule_group_rules",
+		Help: "Test gauge.",
+	}).Set(5)
+	histogram := promauto.With(source).NewHistogram(prometheus.HistogramOpts{
+		Name:    "cortex_ruler_test_seconds",
+		Help:    "Test histogram.",
+		Buckets: []float64{1, 5},
+	})
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	histogram.Observe(10)
+	promauto.With(source).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_ingester_test_total",
+		Help: "Not a ruler metric.",
+	}).Inc()
+
+	reg := prometheus.NewPedanticRegistry()
+	cfg := OTLPExportConfig{Endpoint: server.URL + "/otlp/", Interval: time.Minute, Timeout: time.Second}
+	e, err := newOTLPExporter(cfg, "ruler-1", source, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	now := time.Unix(10, 0)
+	require.NoError(t, e.export(context.Background(), now))
+
+	require.Len(t, received.ResourceMetrics, 1)
+	assert.Equal(t, []*commonpb.KeyValue{
+		otlpStringAttribute("service.instance.id", "ruler-1"),
+		otlpStringAttribute("service.name", "mimir-ruler"),
+	}, received.ResourceMetrics[0].Resource.Attributes)
+
+	require.Len(t, received.ResourceMetrics[0].InstrumentationLibraryMetrics, 1)
+	metrics := map[string]*metricpb.Metric{}
+	for _, m := range received.ResourceMetrics[0].InstrumentationLibraryMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Len(t, metrics, 3)
+
+	assert.True(t, proto.Equal(&metricpb.Sum{
+		AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		IsMonotonic:            true,
+		DataPoints: []*metricpb.NumberDataPoint{{
+			Attributes:   []*commonpb.KeyValue{otlpStringAttribute("user", "user-1")},
+			TimeUnixNano: 10000000000,
+			Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: 3},
+		}},
+	}, metrics["cortex_ruler_test_total"].GetSum()))
+
+	assert.True(t, proto.Equal(&metricpb.Gauge{
+		DataPoints: []*metricpb.NumberDataPoint{{
+			TimeUnixNano: 10000000000,
+			Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: 5},
+		}},
+	}, metrics["cortex_prometheus_rule_group_rules"].GetGauge()))
+
+	assert.True(t, proto.Equal(&metricpb.Histogram{
+		AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		DataPoints: []*metricpb.HistogramDataPoint{{
+			TimeUnixNano:   10000000000,
+			Count:          3,
+			Sum:            12.5,
+			BucketCounts:   []uint64{1, 1, 1},
+			ExplicitBounds: []float64{1, 5},
+		}},
+	}, metrics["cortex_ruler_test_seconds"].GetHistogram()))
+
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(e.exportsTotal))
+	assert.Equal(t, float64(0), prom_testutil.ToFloat64(e.exportsFailed))
+}
+
+func TestOTLPExporter_Traces(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		spans []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req collectortracepb.ExportTraceServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				for _, s := range ils.Spans {
+					spans = append(spans, s.Name)
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previous := opentracing.GlobalTracer()
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(previous)
+	})
+
+	cfg := OTLPExportConfig{Endpoint: server.URL, Interval: time.Minute, Timeout: time.Second}
+	e, err := newOTLPExporter(cfg, "ruler-1", prometheus.NewRegistry(), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), e))
+
+	// The traces of the ruler are pushed, the other ones aren't.
+	sp, ctx := opentracing.StartSpanFromContext(context.Background(), "ruler.syncRules")
+	child, _ := opentracing.StartSpanFromContext(ctx, "ruler.query")
+	child.Finish()
+	sp.LogKV("event", "rules synced")
+	sp.Finish()
+	opentracing.StartSpan("other").Finish()
+
+	// The ended spans are pushed when the exporter stops.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), e))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.ElementsMatch(t, []string{"ruler.syncRules", "ruler.query"}, spans)
+}
+
+func TestOTLPExporter_ExportFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := OTLPExportConfig{Endpoint: server.URL, Interval: time.Minute, Timeout: time.Second}
+	e, err := newOTLPExporter(cfg, "ruler-1", prometheus.NewRegistry(), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	require.Error(t, e.export(context.Background(), time.Now()))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(e.exportsTotal))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(e.exportsFailed))
+}
+
+func TestOTLPExporter_ExportWithTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	cfg := OTLPExportConfig{Endpoint: server.URL, Interval: time.Minute, Timeout: time.Second}
+	cfg.TLS.CAPath = caPath
+	e, err := newOTLPExporter(cfg, "ruler-1", prometheus.NewRegistry(), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	require.NoError(t, e.export(context.Background(), time.Now()))
+
+	cfg.TLS.CAPath = filepath.Join(t.TempDir(), "missing.crt")
+	_, err = newOTLPExporter(cfg, "ruler-1", prometheus.NewRegistry(), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.Error(t, err)
+}
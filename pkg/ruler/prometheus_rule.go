@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const (
+	// ruleImportFormatPrometheusRule is the format of the imports of rule groups from PrometheusRule
+	// custom resources of the Prometheus Operator.
+	ruleImportFormatPrometheusRule = "prometheus_rule"
+
+	prometheusRuleAPIVersion = "monitoring.coreos.com/v1"
+	prometheusRuleKind       = "PrometheusRule"
+)
+
+// prometheusRule is a PrometheusRule custom resource of the Prometheus Operator, or a list of them.
+// The fields of the rule groups which aren't supported by the ruler, such as the Thanos partial
+// response strategy, are ignored.
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Groups []rulefmt.RuleGroup `yaml:"groups"`
+	} `yaml:"spec"`
+
+	// Items are the resources of a List, as returned by kubectl when getting several resources.
+	Items []prometheusRule `yaml:"items"`
+}
+
+// decodePrometheusRules decodes the PrometheusRule resources of the YAML documents read from r,
+// flattening the lists of resources.
+func decodePrometheusRules(r io.Reader) ([]prometheusRule, error) {
+	var rules []prometheusRule
+
+	dec := yaml.NewDecoder(r)
+	for doc := 1; ; doc++ {
+		var pr prometheusRule
+		err := dec.Decode(&pr)
+		if errors.Is(err, io.EOF) {
+			return rules, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode document %d", doc)
+		}
+
+		resources, err := pr.resources()
+		if err != nil {
+			return nil, errors.Wrapf(err, "document %d", doc)
+		}
+		rules = append(rules, resources...)
+	}
+}
+
+// resources returns the PrometheusRule resources of pr, which is either a PrometheusRule or a list of them.
+func (pr prometheusRule) resources() ([]prometheusRule, error) {
+	if pr.Kind == "List" || pr.Kind == prometheusRuleKind+"List" {
+		var resources []prometheusRule
+		for i, item := range pr.Items {
+			itemResources, err := item.resources()
+			if err != nil {
+				return nil, errors.Wrapf(err, "item %d", i+1)
+			}
+			resources = append(resources, itemResources...)
+		}
+		return resources, nil
+	}
+
+	if pr.APIVersion != prometheusRuleAPIVersion || pr.Kind != prometheusRuleKind {
+		return nil, fmt.Errorf("unsupported resource %s of kind %q and API version %q, expected kind %q and API version %q", pr.Metadata.Name, pr.Kind, pr.APIVersion, prometheusRuleKind, prometheusRuleAPIVersion)
+	}
+	return []prometheusRule{pr}, nil
+}
+
+// ruleGroups returns the rule groups of the PrometheusRule resource.
+func (pr prometheusRule) ruleGroups() []rulespb.RuleGroup {
+	groups := make([]rulespb.RuleGroup, 0, len(pr.Spec.Groups))
+	for _, g := range pr.Spec.Groups {
+		groups = append(groups, rulespb.RuleGroup{RuleGroup: g})
+	}
+	return groups
+}
+
+// decodePrometheusRuleGroups decodes the rule groups of all the PrometheusRule resources read from r.
+func decodePrometheusRuleGroups(r io.Reader) ([]rulespb.RuleGroup, error) {
+	resources, err := decodePrometheusRules(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []rulespb.RuleGroup
+	for _, pr := range resources {
+		groups = append(groups, pr.ruleGroups()...)
+	}
+	return groups, nil
+}
@@ -0,0 +1,409 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+const (
+	// prometheusRuleNamespacePrefix is the prefix of the rule namespaces synced from the PrometheusRule
+	// resources, which are named <prefix><Kubernetes namespace>.<resource name>. Kubernetes namespaces
+	// can't contain dots, so the rule namespaces of different resources never collide.
+	prometheusRuleNamespacePrefix = "k8s."
+
+	// The annotations of the PrometheusRule resources the sync status is reported to.
+	prometheusRuleStatusAnnotation  = "ruler.mimir.grafana.com/sync-status"
+	prometheusRuleMessageAnnotation = "ruler.mimir.grafana.com/sync-message"
+
+	prometheusRuleStatusSynced = "Synced"
+	prometheusRuleStatusFailed = "Failed"
+)
+
+var (
+	errInvalidPrometheusRuleControllerSyncInterval = errors.New("invalid ruler PrometheusRule controller sync interval, must be greater than zero")
+	errMissingPrometheusRuleControllerTenantLabel  = errors.New("the ruler PrometheusRule controller tenant label must be set")
+)
+
+type PrometheusRuleControllerConfig struct {
+	Enabled         bool                   `yaml:"enabled"`
+	APIServerURL    string                 `yaml:"api_server_url"`
+	BearerTokenFile string                 `yaml:"bearer_token_file"`
+	CAFile          string                 `yaml:"ca_file"`
+	Namespaces      flagext.StringSliceCSV `yaml:"namespaces"`
+	TenantLabel     string                 `yaml:"tenant_label"`
+	SyncInterval    time.Duration          `yaml:"sync_interval"`
+}
+
+func (cfg *PrometheusRuleControllerConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.prometheus-rule-controller.enabled", false, "Enable the sync of the PrometheusRule resources of the Prometheus Operator from a Kubernetes cluster into the rule store. The rule groups of each resource labeled with the tenant label are synced into the k8s.<namespace>.<name> namespace of the tenant, and the rule namespaces prefixed with k8s. which don't match a resource are deleted. The sync status is reported in the annotations of the resources. The resources are polled from the Kubernetes API server at each sync, rather than watched, by a single ruler: the ruler of the default pool owning a fixed key of the ring. Requires an object storage backend for the ruler storage.")
+	f.StringVar(&cfg.APIServerURL, "ruler.prometheus-rule-controller.api-server-url", "https://kubernetes.default.svc", "URL of the Kubernetes API server.")
+	f.StringVar(&cfg.BearerTokenFile, "ruler.prometheus-rule-controller.bearer-token-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "Path to the file containing the bearer token used to authenticate to the Kubernetes API server. No token is sent if empty.")
+	f.StringVar(&cfg.CAFile, "ruler.prometheus-rule-controller.ca-file", "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt", "Path to the CA certificates file used to verify the Kubernetes API server certificate. The system CA certificates are used if empty.")
+	f.Var(&cfg.Namespaces, "ruler.prometheus-rule-controller.namespaces", "Comma-separated list of Kubernetes namespaces to sync the PrometheusRule resources from. All namespaces are synced if empty.")
+	f.StringVar(&cfg.TenantLabel, "ruler.prometheus-rule-controller.tenant-label", "mimir.grafana.com/tenant", "Label of the PrometheusRule resources set to the tenant to sync their rule groups into. The resources without this label are ignored.")
+	f.DurationVar(&cfg.SyncInterval, "ruler.prometheus-rule-controller.sync-interval", time.Minute, "How frequently the PrometheusRule resources are polled from the Kubernetes API server and synced into the rule store.")
+}
+
+func (cfg *PrometheusRuleControllerConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.SyncInterval <= 0 {
+		return errInvalidPrometheusRuleControllerSyncInterval
+	}
+	if cfg.TenantLabel == "" {
+		return errMissingPrometheusRuleControllerTenantLabel
+	}
+	return nil
+}
+
+// prometheusRuleController periodically syncs the PrometheusRule resources of a Kubernetes cluster into the rule store.
+// It polls the resources from the Kubernetes API server at each sync, rather than watching them. Only the ruler for
+// which owned returns true syncs the resources, so that the rulers don't sync the same resources concurrently.
+type prometheusRuleController struct {
+	services.Service
+
+	cfg      PrometheusRuleControllerConfig
+	client   *kubernetesClient
+	store    rulestore.RuleStore
+	validate func(userID string, rg rulefmt.RuleGroup) error
+	owned    func() (bool, error)
+	logger   log.Logger
+
+	syncsTotal  prometheus.Counter
+	syncsFailed prometheus.Counter
+	resources   *prometheus.GaugeVec
+}
+
+func newPrometheusRuleController(cfg PrometheusRuleControllerConfig, store rulestore.RuleStore, validate func(userID string, rg rulefmt.RuleGroup) error, owned func() (bool, error), logger log.Logger, reg prometheus.Registerer) (*prometheusRuleController, error) {
+	client, err := newKubernetesClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the Kubernetes client of the PrometheusRule controller")
+	}
+
+	c := &prometheusRuleController{
+		cfg:      cfg,
+		client:   client,
+		store:    store,
+		validate: validate,
+		owned:    owned,
+		logger:   logger,
+		syncsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_prometheus_rule_controller_syncs_total",
+			Help: "Total number of syncs of the PrometheusRule resources into the rule store.",
+		}),
+		syncsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_prometheus_rule_controller_syncs_failed_total",
+			Help: "Total number of failed syncs of the PrometheusRule resources into the rule store.",
+		}),
+		resources: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ruler_prometheus_rule_controller_resources",
+			Help: "Number of PrometheusRule resources labeled with a tenant at the last sync, by sync status.",
+		}, []string{"status"}),
+	}
+
+	c.Service = services.NewTimerService(cfg.SyncInterval, c.starting, c.iteration, nil).WithName("ruler PrometheusRule controller")
+	return c, nil
+}
+
+func (c *prometheusRuleController) starting(ctx context.Context) error {
+	// Sync the resources as soon as possible.
+	_ = c.iteration(ctx)
+	return nil
+}
+
+func (c *prometheusRuleController) iteration(ctx context.Context) error {
+	owned, err := c.owned()
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to check whether the ruler syncs the PrometheusRule resources", "err", err)
+		return nil
+	}
+	if !owned {
+		// The resources are synced by another ruler.
+		c.resources.Reset()
+		return nil
+	}
+
+	c.syncsTotal.Inc()
+	if err := c.sync(ctx); err != nil {
+		c.syncsFailed.Inc()
+		level.Warn(c.logger).Log("msg", "failed to sync the PrometheusRule resources", "err", err)
+	}
+	return nil
+}
+
+// sync makes the rule namespaces synced from the PrometheusRule resources of each tenant in the rule store
+// match the resources. The rule groups of an invalid resource are left as they were last synced.
+func (c *prometheusRuleController) sync(ctx context.Context) error {
+	var resources []prometheusRule
+	if len(c.cfg.Namespaces) == 0 {
+		rs, err := c.client.listPrometheusRules(ctx, "")
+		if err != nil {
+			return err
+		}
+		resources = rs
+	}
+	for _, ns := range c.cfg.Namespaces {
+		rs, err := c.client.listPrometheusRules(ctx, ns)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, rs...)
+	}
+
+	// The rule namespaces of each tenant, with the rule groups of the valid resources only. The rule
+	// namespaces of the invalid resources are kept, so that they aren't deleted.
+	desired := map[string]map[string]rulespb.RuleGroupList{}
+	statuses := map[string]int{}
+	for _, pr := range resources {
+		userID, ok := pr.Metadata.Labels[c.cfg.TenantLabel]
+		if !ok {
+			continue
+		}
+		namespace := prometheusRuleNamespacePrefix + pr.Metadata.Namespace + "." + pr.Metadata.Name
+
+		var groups rulespb.RuleGroupList
+		err := tenant.ValidTenantID(userID)
+		if err != nil {
+			err = errors.Wrapf(err, "invalid tenant %s", userID)
+		} else {
+			groups, err = c.ruleGroups(userID, namespace, pr)
+			if desired[userID] == nil {
+				desired[userID] = map[string]rulespb.RuleGroupList{}
+			}
+			// The rule groups of an invalid resource are nil.
+			desired[userID][namespace] = groups
+		}
+
+		status, message := prometheusRuleStatusSynced, fmt.Sprintf("%d rule groups synced into the namespace %s of the tenant %s", len(groups), namespace, userID)
+		if err != nil {
+			status, message = prometheusRuleStatusFailed, err.Error()
+			level.Warn(c.logger).Log("msg", "invalid PrometheusRule resource", "namespace", pr.Metadata.Namespace, "name", pr.Metadata.Name, "err", err)
+		}
+		statuses[status]++
+		c.reportStatus(ctx, pr, status, message)
+	}
+
+	users, err := c.store.ListAllUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range users {
+		if _, ok := desired[userID]; !ok {
+			desired[userID] = map[string]rulespb.RuleGroupList{}
+		}
+	}
+
+	for userID, namespaces := range desired {
+		if err := c.syncUser(ctx, userID, namespaces); err != nil {
+			return errors.Wrapf(err, "failed to sync the PrometheusRule resources of user %s", userID)
+		}
+	}
+
+	c.resources.Reset()
+	for status, n := range statuses {
+		c.resources.WithLabelValues(status).Set(float64(n))
+	}
+	return nil
+}
+
+// ruleGroups returns the validated rule groups of the PrometheusRule resource synced into the namespace of the tenant.
+func (c *prometheusRuleController) ruleGroups(userID, namespace string, pr prometheusRule) (rulespb.RuleGroupList, error) {
+	groups := make(rulespb.RuleGroupList, 0, len(pr.Spec.Groups))
+	names := make(map[string]struct{}, len(pr.Spec.Groups))
+	for _, rg := range pr.ruleGroups() {
+		if _, ok := names[rg.Name]; ok {
+			return nil, fmt.Errorf("several rule groups are named %s", rg.Name)
+		}
+		names[rg.Name] = struct{}{}
+
+		if err := c.validate(userID, rg.RuleFile()); err != nil {
+			return nil, errors.Wrapf(err, "rule group %s", rg.Name)
+		}
+		groups = append(groups, rulespb.ToProto(userID, namespace, rg))
+	}
+	return groups, nil
+}
+
+// syncUser reconciles the rule namespaces synced from the PrometheusRule resources of the tenant. The rule
+// namespaces with the synced prefix which aren't desired are deleted, the ones of invalid resources are left
+// untouched.
+func (c *prometheusRuleController) syncUser(ctx context.Context, userID string, desired map[string]rulespb.RuleGroupList) error {
+	current, err := c.store.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+
+	namespaces := map[string]rulespb.RuleGroupList{}
+	for namespace := range groupsByNamespace(current) {
+		if strings.HasPrefix(namespace, prometheusRuleNamespacePrefix) {
+			namespaces[namespace] = nil
+		}
+	}
+	for namespace, groups := range desired {
+		namespaces[namespace] = groups
+	}
+
+	for namespace, groups := range namespaces {
+		if groups == nil {
+			if _, ok := desired[namespace]; ok {
+				// The resource is invalid: its rule groups are left as they were last synced.
+				continue
+			}
+		}
+		if _, err := reconcileNamespaceRuleGroups(ctx, c.store, c.logger, userID, namespace, groups); err != nil {
+			return errors.Wrapf(err, "namespace %s", namespace)
+		}
+	}
+	return nil
+}
+
+// reportStatus sets the sync status annotations of the PrometheusRule resource, unless they're already up to date.
+func (c *prometheusRuleController) reportStatus(ctx context.Context, pr prometheusRule, status, message string) {
+	if pr.Metadata.Annotations[prometheusRuleStatusAnnotation] == status && pr.Metadata.Annotations[prometheusRuleMessageAnnotation] == message {
+		return
+	}
+
+	annotations := map[string]string{
+		prometheusRuleStatusAnnotation:  status,
+		prometheusRuleMessageAnnotation: message,
+	}
+	if err := c.client.annotatePrometheusRule(ctx, pr.Metadata.Namespace, pr.Metadata.Name, annotations); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to report the sync status of the PrometheusRule resource", "namespace", pr.Metadata.Namespace, "name", pr.Metadata.Name, "err", err)
+	}
+}
+
+// kubernetesClient is a minimal client of the PrometheusRule resources of the Kubernetes API.
+type kubernetesClient struct {
+	apiServerURL    string
+	bearerTokenFile string
+	client          *http.Client
+}
+
+func newKubernetesClient(cfg PrometheusRuleControllerConfig) (*kubernetesClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no CA certificates found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &kubernetesClient{
+		apiServerURL:    strings.TrimSuffix(cfg.APIServerURL, "/"),
+		bearerTokenFile: cfg.BearerTokenFile,
+		client:          &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// listPrometheusRules returns the PrometheusRule resources of the namespace, or of all namespaces if empty.
+func (k *kubernetesClient) listPrometheusRules(ctx context.Context, namespace string) ([]prometheusRule, error) {
+	p := "/apis/monitoring.coreos.com/v1/prometheusrules"
+	if namespace != "" {
+		p = path.Join("/apis/monitoring.coreos.com/v1/namespaces", url.PathEscape(namespace), "prometheusrules")
+	}
+
+	body, err := k.do(ctx, http.MethodGet, p, "", nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the PrometheusRule resources of namespace %q", namespace)
+	}
+
+	// The JSON response is valid YAML.
+	return decodePrometheusRules(bytes.NewReader(body))
+}
+
+// annotatePrometheusRule merges the annotations into the ones of the PrometheusRule resource.
+func (k *kubernetesClient) annotatePrometheusRule(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	p := path.Join("/apis/monitoring.coreos.com/v1/namespaces", url.PathEscape(namespace), "prometheusrules", url.PathEscape(name))
+	_, err = k.do(ctx, http.MethodPatch, p, "application/merge-patch+json", bytes.NewReader(patch))
+	return err
+}
+
+func (k *kubernetesClient) do(ctx context.Context, method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, k.apiServerURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if k.bearerTokenFile != "" {
+		// The token is read for each request, since it's rotated by Kubernetes.
+		token, err := ioutil.ReadFile(k.bearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected status code %d from the Kubernetes API server: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// validateSyncedRuleGroup validates a rule group synced into the rule store by a controller, as the
+// configuration API does.
+func (r *Ruler) validateSyncedRuleGroup(userID string, rg rulefmt.RuleGroup) error {
+	if errs := r.manager.ValidateRuleGroup(rg); len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return errors.New(strings.Join(msgs, ", "))
+	}
+	if err := r.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+		return err
+	}
+	return r.AssertMaxRecordingRuleLabels(userID, rg)
+}
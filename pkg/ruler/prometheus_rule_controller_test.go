@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+const prometheusRuleListResponse = `{
+  "apiVersion": "monitoring.coreos.com/v1",
+  "kind": "PrometheusRuleList",
+  "items": [
+    {
+      "apiVersion": "monitoring.coreos.com/v1",
+      "kind": "PrometheusRule",
+      "metadata": {"name": "api", "namespace": "monitoring", "labels": {"mimir.grafana.com/tenant": "user1"}},
+      "spec": {"groups": [{"name": "api", "rules": [{"record": "api:up:sum", "expr": "sum(up{job=\"api\"})"}]}]}
+    },
+    {
+      "apiVersion": "monitoring.coreos.com/v1",
+      "kind": "PrometheusRule",
+      "metadata": {"name": "invalid", "namespace": "monitoring", "labels": {"mimir.grafana.com/tenant": "user1"}},
+      "spec": {"groups": [{"name": "invalid", "rules": [{"record": "invalid", "expr": "sum("}]}]}
+    },
+    {
+      "apiVersion": "monitoring.coreos.com/v1",
+      "kind": "PrometheusRule",
+      "metadata": {"name": "unlabeled", "namespace": "monitoring"},
+      "spec": {"groups": [{"name": "unlabeled", "rules": [{"record": "unlabeled", "expr": "sum(up)"}]}]}
+    }
+  ]
+}`
+
+func TestPrometheusRuleController(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		patches = map[string]map[string]map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/monitoring.coreos.com/v1/namespaces/monitoring/prometheusrules":
+			_, _ = w.Write([]byte(prometheusRuleListResponse))
+		case r.Method == http.MethodPatch:
+			assert.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			var patch struct {
+				Metadata struct {
+					Annotations map[string]string `json:"annotations"`
+				} `json:"metadata"`
+			}
+			require.NoError(t, json.Unmarshal(body, &patch))
+
+			mtx.Lock()
+			patches[r.URL.Path] = map[string]map[string]string{"annotations": patch.Metadata.Annotations}
+			mtx.Unlock()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	// The synced namespace of a resource which doesn't exist anymore is deleted, while the synced
+	// namespace of an invalid resource and the other namespaces are left untouched.
+	for _, namespace := range []string{"k8s.monitoring.deleted", "k8s.monitoring.invalid", "other"} {
+		require.NoError(t, store.SetRuleGroup(ctx, "user1", namespace, &rulespb.RuleGroupDesc{
+			Name: "group", Namespace: namespace, User: "user1",
+			Rules: []*rulespb.RuleDesc{{Record: "group:sum", Expr: "sum(up)"}},
+		}))
+	}
+
+	validate := func(_ string, rg rulefmt.RuleGroup) error {
+		if rg.Name == "invalid" {
+			return errors.New("invalid expression")
+		}
+		return nil
+	}
+
+	cfg := PrometheusRuleControllerConfig{
+		Enabled:      true,
+		APIServerURL: server.URL,
+		Namespaces:   []string{"monitoring"},
+		TenantLabel:  "mimir.grafana.com/tenant",
+		SyncInterval: time.Minute,
+	}
+	owned := false
+	reg := prometheus.NewPedanticRegistry()
+	c, err := newPrometheusRuleController(cfg, store, validate, func() (bool, error) { return owned, nil }, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	// The resources aren't synced by the rulers which don't own the controller.
+	require.NoError(t, c.iteration(ctx))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.syncsTotal))
+	groups, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	owned = true
+	require.NoError(t, c.iteration(ctx))
+
+	groups, err = store.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	require.NoError(t, store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{"user1": groups}))
+
+	synced := map[string]*rulespb.RuleGroupDesc{}
+	for _, g := range groups {
+		synced[g.Namespace+"/"+g.Name] = g
+	}
+	require.Len(t, synced, 3)
+	require.Contains(t, synced, "k8s.monitoring.api/api")
+	assert.Equal(t, `sum(up{job="api"})`, synced["k8s.monitoring.api/api"].Rules[0].Expr)
+	assert.Contains(t, synced, "k8s.monitoring.invalid/group")
+	assert.Contains(t, synced, "other/group")
+
+	// The sync status is reported to the labeled resources only.
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, map[string]map[string]map[string]string{
+		"/apis/monitoring.coreos.com/v1/namespaces/monitoring/prometheusrules/api": {"annotations": {
+			prometheusRuleStatusAnnotation:  prometheusRuleStatusSynced,
+			prometheusRuleMessageAnnotation: "1 rule groups synced into the namespace k8s.monitoring.api of the tenant user1",
+		}},
+		"/apis/monitoring.coreos.com/v1/namespaces/monitoring/prometheusrules/invalid": {"annotations": {
+			prometheusRuleStatusAnnotation:  prometheusRuleStatusFailed,
+			prometheusRuleMessageAnnotation: "rule group invalid: invalid expression",
+		}},
+	}, patches)
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.syncsFailed))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.resources.WithLabelValues(prometheusRuleStatusSynced)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.resources.WithLabelValues(prometheusRuleStatusFailed)))
+}
+
+func TestRuler_OwnsPrometheusRuleController(t *testing.T) {
+	kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{})
+	rulerAddrMap := map[string]*Ruler{}
+
+	rulerIDs := []string{"ruler1", "ruler2", "ruler3"}
+	for _, id := range rulerIDs {
+		cfg := defaultRulerConfig(t)
+		cfg.Ring = RingConfig{
+			InstanceID:        id,
+			InstanceAddr:      id,
+			KVStore:           kv.Config{Mock: kvStore},
+			ReplicationFactor: 1,
+		}
+
+		r := buildRuler(t, cfg, store, rulerAddrMap)
+		rulerAddrMap[id] = r
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), r.ring))
+		t.Cleanup(r.ring.StopAsync)
+	}
+
+	require.NoError(t, kvStore.CAS(context.Background(), RulerRingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		d := ring.NewDesc()
+		for i, id := range rulerIDs {
+			d.AddIngester(id, rulerAddrMap[id].lifecycler.GetInstanceAddr(), "", []uint32{uint32(i+1) * (math.MaxUint32 / 4)}, ring.ACTIVE, time.Now())
+		}
+		return d, true, nil
+	}))
+
+	// A single ruler syncs the PrometheusRule resources.
+	owners := 0
+	for _, r := range rulerAddrMap {
+		test.Poll(t, time.Second, len(rulerIDs), func() interface{} {
+			rs, _ := r.ring.GetAllHealthy(RingOp)
+			return len(rs.Instances)
+		})
+		owned, err := r.ownsPrometheusRuleController()
+		require.NoError(t, err)
+		if owned {
+			owners++
+		}
+	}
+	assert.Equal(t, 1, owners)
+
+	// A single ruler reconciles the provisioned rule groups of each tenant.
+	for _, userID := range []string{"user-1", "user-2", "user-3", "user-4"} {
+		owners := 0
+		for _, r := range rulerAddrMap {
+			owned, err := r.ownsProvisionedTenant(userID)
+			require.NoError(t, err)
+			if owned {
+				owners++
+			}
+		}
+		assert.Equal(t, 1, owners, userID)
+	}
+
+	// The rulers of the other pools, or with the evaluation disabled, don't.
+	for _, r := range rulerAddrMap {
+		r.cfg.Ring.Pool = "other"
+		owned, err := r.ownsPrometheusRuleController()
+		require.NoError(t, err)
+		assert.False(t, owned)
+
+		owned, err = r.ownsProvisionedTenant("user-1")
+		require.NoError(t, err)
+		assert.False(t, owned)
+
+		r.cfg.Ring.Pool = ""
+		r.cfg.EnableEvaluation = false
+		owned, err = r.ownsPrometheusRuleController()
+		require.NoError(t, err)
+		assert.False(t, owned)
+		owned, err = r.ownsProvisionedTenant("user-1")
+		require.NoError(t, err)
+		assert.False(t, owned)
+	}
+}
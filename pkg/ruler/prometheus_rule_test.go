@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePrometheusRuleGroups(t *testing.T) {
+	type decodedGroup struct {
+		Name     string
+		Interval model.Duration
+		Exprs    []string
+	}
+
+	tests := map[string]struct {
+		input          string
+		expectedGroups []decodedGroup
+		expectedErr    string
+	}{
+		"several documents": {
+			input: `
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: api
+  namespace: monitoring
+spec:
+  groups:
+  - name: api
+    interval: 1m
+    partial_response_strategy: warn
+    rules:
+    - record: api:up:sum
+      expr: sum(up{job="api"})
+---
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: always-firing
+spec:
+  groups:
+  - name: always-firing
+    rules:
+    - alert: AlwaysFiring
+      expr: 1
+      for: 5m
+`,
+			expectedGroups: []decodedGroup{
+				{Name: "api", Interval: model.Duration(time.Minute), Exprs: []string{`sum(up{job="api"})`}},
+				{Name: "always-firing", Exprs: []string{"1"}},
+			},
+		},
+		"list of resources": {
+			input: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: monitoring.coreos.com/v1
+  kind: PrometheusRule
+  metadata:
+    name: api
+  spec:
+    groups:
+    - name: api
+      rules:
+      - record: api:up:sum
+        expr: sum(up{job="api"})
+- apiVersion: monitoring.coreos.com/v1
+  kind: PrometheusRule
+  metadata:
+    name: db
+  spec:
+    groups:
+    - name: db
+      rules:
+      - record: db:up:sum
+        expr: sum(up{job="db"})
+`,
+			expectedGroups: []decodedGroup{
+				{Name: "api", Exprs: []string{`sum(up{job="api"})`}},
+				{Name: "db", Exprs: []string{`sum(up{job="db"})`}},
+			},
+		},
+		"unsupported kind": {
+			input: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: rules
+`,
+			expectedErr: `document 1: unsupported resource rules of kind "ConfigMap" and API version "v1", expected kind "PrometheusRule" and API version "monitoring.coreos.com/v1"`,
+		},
+		"invalid YAML": {
+			input:       "apiVersion: [",
+			expectedErr: "unable to decode document 1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			groups, err := decodePrometheusRuleGroups(strings.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var actual []decodedGroup
+			for _, g := range groups {
+				dg := decodedGroup{Name: g.Name, Interval: g.Interval}
+				for _, r := range g.Rules {
+					dg.Exprs = append(dg.Exprs, r.Expr.Value)
+				}
+				actual = append(actual, dg)
+			}
+			assert.Equal(t, tc.expectedGroups, actual)
+		})
+	}
+}
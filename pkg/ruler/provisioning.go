@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/local"
+)
+
+var errInvalidProvisioningInterval = errors.New("invalid ruler provisioning interval, must be greater than zero")
+
+type ProvisioningConfig struct {
+	Directory string        `yaml:"directory"`
+	Interval  time.Duration `yaml:"interval"`
+}
+
+func (cfg *ProvisioningConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Directory, "ruler.provisioning.directory", "", "Directory to load the provisioned rule groups from. The rule groups of each namespace are read from the <directory>/<tenant>/<namespace> file, in the Prometheus rule file format. The provisioned namespaces of each tenant are periodically reconciled into the rule store by a single ruler of the default pool with the evaluation enabled: any rule group of these namespaces which differs from the provisioned ones is overwritten or deleted. Requires an object storage backend for the ruler storage. The provisioning is disabled if empty.")
+	f.DurationVar(&cfg.Interval, "ruler.provisioning.interval", time.Minute, "How frequently the provisioned rule groups are reconciled into the rule store.")
+}
+
+func (cfg *ProvisioningConfig) Validate() error {
+	if cfg.Directory != "" && cfg.Interval <= 0 {
+		return errInvalidProvisioningInterval
+	}
+	return nil
+}
+
+// rulesProvisioner periodically reconciles the rule groups provisioned in a directory into the rule store.
+// The provisioned rule groups of a tenant are only reconciled by the ruler for which owned returns true, so
+// that the rulers don't write the same rule groups concurrently.
+type rulesProvisioner struct {
+	services.Service
+
+	source *local.Client
+	store  rulestore.RuleStore
+	owned  func(userID string) (bool, error)
+	logger log.Logger
+
+	reconciliationsTotal  prometheus.Counter
+	reconciliationsFailed prometheus.Counter
+	driftedGroups         *prometheus.GaugeVec
+}
+
+func newRulesProvisioner(cfg ProvisioningConfig, store rulestore.RuleStore, owned func(userID string) (bool, error), logger log.Logger, reg prometheus.Registerer) (*rulesProvisioner, error) {
+	source, err := local.NewLocalRulesClient(local.Config{Directory: cfg.Directory}, promRules.FileLoader{})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rulesProvisioner{
+		source: source,
+		store:  store,
+		owned:  owned,
+		logger: logger,
+		reconciliationsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_provisioning_reconciliations_total",
+			Help: "Total number of reconciliations of the provisioned rule groups into the rule store.",
+		}),
+		reconciliationsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_provisioning_reconciliations_failed_total",
+			Help: "Total number of failed reconciliations of the provisioned rule groups into the rule store.",
+		}),
+		driftedGroups: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ruler_provisioning_drifted_rule_groups",
+			Help: "Number of rule groups of the provisioned namespaces which were missing, different or unexpected in the rule store at the last reconciliation.",
+		}, []string{"user"}),
+	}
+
+	p.Service = services.NewTimerService(cfg.Interval, p.starting, p.iteration, nil).WithName("ruler rules provisioner")
+	return p, nil
+}
+
+func (p *rulesProvisioner) starting(ctx context.Context) error {
+	// Provision the rule groups as soon as possible.
+	_ = p.iteration(ctx)
+	return nil
+}
+
+func (p *rulesProvisioner) iteration(ctx context.Context) error {
+	p.reconciliationsTotal.Inc()
+	if err := p.reconcile(ctx); err != nil {
+		p.reconciliationsFailed.Inc()
+		level.Warn(p.logger).Log("msg", "failed to reconcile the provisioned rule groups", "err", err)
+	}
+	return nil
+}
+
+// reconcile makes the rule groups of the provisioned namespaces of each tenant in the rule store
+// match the provisioned ones. The tenants owned by other rulers are skipped.
+func (p *rulesProvisioner) reconcile(ctx context.Context) error {
+	users, err := p.source.ListAllUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	drifted := map[string]int{}
+	for _, userID := range users {
+		owned, err := p.owned(userID)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			// The provisioned rule groups of the tenant are reconciled by another ruler.
+			continue
+		}
+
+		desired, err := p.source.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to load the provisioned rule groups of user %s", userID)
+		}
+
+		for namespace, groups := range groupsByNamespace(desired) {
+			n, err := reconcileNamespaceRuleGroups(ctx, p.store, p.logger, userID, namespace, groups)
+			if err != nil {
+				return errors.Wrapf(err, "failed to reconcile the provisioned rule groups of user %s and namespace %s", userID, namespace)
+			}
+			drifted[userID] += n
+		}
+	}
+
+	p.driftedGroups.Reset()
+	for userID, n := range drifted {
+		p.driftedGroups.WithLabelValues(userID).Set(float64(n))
+	}
+	return nil
+}
+
+// reconcileNamespaceRuleGroups stores the desired rule groups of the namespace which are missing or different
+// in the rule store, and deletes the unexpected ones. It returns the number of drifted rule groups.
+func reconcileNamespaceRuleGroups(ctx context.Context, store rulestore.RuleStore, logger log.Logger, userID, namespace string, desired rulespb.RuleGroupList) (int, error) {
+	current, err := store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err != nil {
+		return 0, err
+	}
+	if err := store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: current}); err != nil {
+		return 0, err
+	}
+
+	currentByName := make(map[string]*rulespb.RuleGroupDesc, len(current))
+	for _, g := range current {
+		currentByName[g.Name] = g
+	}
+
+	drifted := 0
+	for _, g := range desired {
+		if c, ok := currentByName[g.Name]; ok {
+			delete(currentByName, g.Name)
+			// The provisioned rule groups have no raw content, and the update times and format
+			// versions of the provisioned and stored rule groups are not part of their configuration.
+			current, provisioned := *c, *g
+			current.Raw = nil
+			current.UpdatedAt, provisioned.UpdatedAt = nil, nil
+			current.FormatVersion, provisioned.FormatVersion = 0, 0
+			if current.Equal(&provisioned) {
+				continue
+			}
+		}
+
+		drifted++
+		level.Info(logger).Log("msg", "provisioning rule group", "user", userID, "namespace", namespace, "group", g.Name)
+		now := time.Now()
+		provisioned := *g
+		provisioned.UpdatedAt = &now
+		if err := store.SetRuleGroup(ctx, userID, namespace, &provisioned); err != nil {
+			return drifted, err
+		}
+	}
+
+	for name := range currentByName {
+		drifted++
+		level.Info(logger).Log("msg", "deleting rule group not provisioned", "user", userID, "namespace", namespace, "group", name)
+		if err := store.DeleteRuleGroup(ctx, userID, namespace, name); err != nil {
+			return drifted, err
+		}
+	}
+
+	return drifted, nil
+}
+
+func groupsByNamespace(groups rulespb.RuleGroupList) map[string]rulespb.RuleGroupList {
+	byNamespace := map[string]rulespb.RuleGroupList{}
+	for _, g := range groups {
+		byNamespace[g.Namespace] = append(byNamespace[g.Namespace], g)
+	}
+	return byNamespace
+}
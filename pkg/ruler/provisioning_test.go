@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestRulesProvisioner_Reconcile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "user-1"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user-1", "provisioned"), []byte(`
+groups:
+  - name: unchanged
+    rules:
+      - record: unchanged:sum
+        expr: sum(up)
+  - name: changed
+    interval: 30s
+    rules:
+      - alert: Down
+        expr: up == 0
+        for: 5m
+  - name: missing
+    rules:
+      - record: missing:sum
+        expr: sum(up)
+`), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "user-2"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user-2", "provisioned"), []byte(`
+groups:
+  - name: missing
+    rules:
+      - record: missing:sum
+        expr: sum(up)
+`), 0o644))
+
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	// Store the current rule groups: an up to date one, a different one, an unexpected one, and one in a namespace which is not provisioned.
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "provisioned", &rulespb.RuleGroupDesc{
+		Name: "unchanged", Namespace: "provisioned", User: "user-1",
+		Rules: []*rulespb.RuleDesc{{Record: "unchanged:sum", Expr: "sum(up)"}},
+	}))
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "provisioned", &rulespb.RuleGroupDesc{
+		Name: "changed", Namespace: "provisioned", User: "user-1", Interval: time.Minute,
+		Rules: []*rulespb.RuleDesc{{Alert: "Down", Expr: "up == 0", For: 5 * time.Minute}},
+	}))
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "provisioned", &rulespb.RuleGroupDesc{
+		Name: "unexpected", Namespace: "provisioned", User: "user-1",
+		Rules: []*rulespb.RuleDesc{{Record: "unexpected:sum", Expr: "sum(up)"}},
+	}))
+	require.NoError(t, store.SetRuleGroup(ctx, "user-1", "other", &rulespb.RuleGroupDesc{
+		Name: "other", Namespace: "other", User: "user-1",
+		Rules: []*rulespb.RuleDesc{{Record: "other:sum", Expr: "sum(up)"}},
+	}))
+
+	reg := prometheus.NewPedanticRegistry()
+	owned := func(userID string) (bool, error) { return userID == "user-1", nil }
+	p, err := newRulesProvisioner(ProvisioningConfig{Directory: dir, Interval: time.Minute}, store, owned, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, p.reconcile(ctx))
+
+	groups, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user-1", "")
+	require.NoError(t, err)
+	require.NoError(t, store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{"user-1": groups}))
+
+	byName := map[string]*rulespb.RuleGroupDesc{}
+	for _, g := range groups {
+		byName[g.Name] = g
+	}
+	require.Len(t, byName, 4)
+	assert.Contains(t, byName, "unchanged")
+	assert.Contains(t, byName, "missing")
+	assert.Contains(t, byName, "other")
+	assert.Equal(t, 30*time.Second, byName["changed"].Interval)
+
+	// The rule groups of the tenants owned by other rulers are left untouched.
+	groups, err = store.ListRuleGroupsForUserAndNamespace(ctx, "user-2", "")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_provisioning_drifted_rule_groups Number of rule groups of the provisioned namespaces which were missing, different or unexpected in the rule store at the last reconciliation.
+		# TYPE cortex_ruler_provisioning_drifted_rule_groups gauge
+		cortex_ruler_provisioning_drifted_rule_groups{user="user-1"} 3
+	`), "cortex_ruler_provisioning_drifted_rule_groups"))
+
+	// Once reconciled, there's no drift anymore.
+	require.NoError(t, p.reconcile(ctx))
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.driftedGroups.WithLabelValues("user-1")))
+}
@@ -29,3 +29,10 @@ func (m *pusherMock) Push(ctx context.Context, req *mimirpb.WriteRequest) (*mimi
 func (m *pusherMock) MockPush(res *mimirpb.WriteResponse, err error) {
 	m.On("Push", mock.Anything, mock.Anything).Return(res, err)
 }
+
+// nopPusher is a Pusher discarding the pushed series.
+type nopPusher struct{}
+
+func (nopPusher) Push(context.Context, *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	return &mimirpb.WriteResponse{}, nil
+}
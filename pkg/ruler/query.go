@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/querier/engine"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var (
+	errInvalidQueryTenantQPS    = errors.New("invalid ruler query tenant QPS, must be greater or equal to 0")
+	errInvalidQueryTenantBurst  = errors.New("invalid ruler query tenant burst, must be greater or equal to 0")
+	errInvalidQueryCacheTTL     = errors.New("invalid ruler query results cache TTL, must be greater or equal to 0")
+	errInvalidCircuitBreaker    = errors.New("invalid ruler query circuit breaker, the failure threshold and cooldown must be greater or equal to 0")
+	errInvalidQueryBackend      = errors.New("invalid ruler query backend config, the timeout and max response size must be greater or equal to 0")
+	errInvalidQueryEngineConfig = errors.New("invalid ruler query engine config, max samples, timeout and lookback delta must be greater or equal to 0")
+	errAtModifierDisabled       = errors.New("@ modifier is disabled")
+	errNegativeOffsetDisabled   = errors.New("negative offsets are disabled")
+	errRuleQueryRateLimited     = errors.New("rule evaluation query rejected because the tenant exceeded the per-tenant rule queries rate limit")
+	errRuleQueryCircuitOpen     = errors.New("rule evaluation query short-circuited because the previous rule queries of the tenant failed, the read path is queried again after the circuit breaker cooldown")
+)
+
+// QueryConfig configures the queries run by rule evaluations.
+type QueryConfig struct {
+	LogSlowerThan time.Duration `yaml:"log_slower_than" category:"experimental"`
+	TenantQPS     float64       `yaml:"tenant_qps" category:"experimental"`
+	TenantBurst   int           `yaml:"tenant_burst" category:"experimental"`
+	CacheTTL      time.Duration `yaml:"results_cache_ttl" category:"experimental"`
+
+	CircuitBreakerFailureThreshold int           `yaml:"circuit_breaker_failure_threshold" category:"experimental"`
+	CircuitBreakerCooldown         time.Duration `yaml:"circuit_breaker_cooldown" category:"experimental"`
+
+	BackendTimeout         time.Duration `yaml:"backend_timeout" category:"experimental"`
+	BackendMaxResponseSize int64         `yaml:"backend_max_response_size_bytes" category:"experimental"`
+}
+
+func (cfg *QueryConfig) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.LogSlowerThan, "ruler.query.log-slower-than", 0, "Log the queries run by rule evaluations which are slower than the specified duration, along with the tenant, rule group and rule they belong to. Set to 0 to disable.")
+	f.Float64Var(&cfg.TenantQPS, "ruler.query.tenant-qps", 0, "Maximum number of queries per second that rule evaluations can run for each tenant. Rule evaluations exceeding the rate fail and are retried at the next evaluation interval. 0 to disable.")
+	f.IntVar(&cfg.TenantBurst, "ruler.query.tenant-burst", 0, "Maximum number of queries that rule evaluations can run at once for each tenant, when -ruler.query.tenant-qps is enabled. 0 to use the per-tenant queries per second, rounded up.")
+	f.DurationVar(&cfg.CacheTTL, "ruler.query.results-cache-ttl", 0, "How long the results of the queries run by rule evaluations are cached for each tenant, so that identical queries run at the same timestamp by different rules are only executed once. Set to 0 to disable.")
+	f.IntVar(&cfg.CircuitBreakerFailureThreshold, "ruler.query.circuit-breaker-failure-threshold", 0, "Number of consecutive rule evaluation queries of a tenant failing because of the read path after which the following queries of the tenant fail immediately, until -ruler.query.circuit-breaker-cooldown has elapsed. 0 to disable.")
+	f.DurationVar(&cfg.CircuitBreakerCooldown, "ruler.query.circuit-breaker-cooldown", time.Minute, "How long the rule evaluation queries of a tenant fail immediately once the circuit breaker is open. A single query is then run to probe the read path, closing the circuit breaker if it succeeds.")
+	f.DurationVar(&cfg.BackendTimeout, "ruler.query.backend-timeout", 2*time.Minute, "Timeout of the requests to the external query APIs of the tenants configured with -ruler.query-backend-url, including the read of their response. 0 for no timeout.")
+	f.Int64Var(&cfg.BackendMaxResponseSize, "ruler.query.backend-max-response-size-bytes", 100<<20, "Maximum size of the responses of the external query APIs of the tenants configured with -ruler.query-backend-url. The queries whose response is larger fail. 0 for no limit.")
+}
+
+func (cfg *QueryConfig) Validate() error {
+	if cfg.TenantQPS < 0 {
+		return errInvalidQueryTenantQPS
+	}
+	if cfg.TenantBurst < 0 {
+		return errInvalidQueryTenantBurst
+	}
+	if cfg.CacheTTL < 0 {
+		return errInvalidQueryCacheTTL
+	}
+	if cfg.CircuitBreakerFailureThreshold < 0 || cfg.CircuitBreakerCooldown < 0 {
+		return errInvalidCircuitBreaker
+	}
+	if cfg.BackendTimeout < 0 || cfg.BackendMaxResponseSize < 0 {
+		return errInvalidQueryBackend
+	}
+	return nil
+}
+
+// tenantBurst returns the burst of the per-tenant queries rate limiter.
+func (cfg *QueryConfig) tenantBurst() int {
+	if cfg.TenantBurst > 0 {
+		return cfg.TenantBurst
+	}
+	return int(math.Ceil(cfg.TenantQPS))
+}
+
+// QueryEngineConfig overrides the PromQL engine config of the querier for rule evaluations.
+// Zero values inherit the querier config. It's only used when rules are evaluated by the ruler
+// itself, not when using remote evaluation.
+type QueryEngineConfig struct {
+	MaxSamples    int           `yaml:"max_samples" category:"experimental"`
+	Timeout       time.Duration `yaml:"timeout" category:"experimental"`
+	LookbackDelta time.Duration `yaml:"lookback_delta" category:"experimental"`
+
+	EnableAtModifier     bool `yaml:"at_modifier_enabled" category:"experimental"`
+	EnableNegativeOffset bool `yaml:"negative_offset_enabled" category:"experimental"`
+
+	// ExperimentalFunctionsEnabled is inherited from the querier config: rule expressions can call
+	// experimental PromQL functions only if queriers accept them.
+	ExperimentalFunctionsEnabled bool `yaml:"-"`
+}
+
+func (cfg *QueryEngineConfig) RegisterFlags(f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxSamples, "ruler.query-engine.max-samples", 0, "Maximum number of samples a single query run by rule evaluations can load into memory. 0 to use -querier.max-samples.")
+	f.DurationVar(&cfg.Timeout, "ruler.query-engine.timeout", 0, "The timeout for a query run by rule evaluations. 0 to use -querier.timeout.")
+	f.DurationVar(&cfg.LookbackDelta, "ruler.query-engine.lookback-delta", 0, "Time since the last sample after which a time series is considered stale and ignored by rule evaluations. 0 to use -querier.lookback-delta.")
+	f.BoolVar(&cfg.EnableAtModifier, "ruler.query-engine.at-modifier-enabled", true, "Allow the @ modifier in rule expressions. Rule groups using it are rejected by the ruler config API when disabled.")
+	f.BoolVar(&cfg.EnableNegativeOffset, "ruler.query-engine.negative-offset-enabled", false, "Allow negative offsets in rule expressions. Rule groups using them are rejected by the ruler config API when disabled. Rule expressions evaluated remotely are subject to the query-frontend and querier settings instead, which do not allow negative offsets.")
+}
+
+func (cfg *QueryEngineConfig) Validate() error {
+	if cfg.MaxSamples < 0 || cfg.Timeout < 0 || cfg.LookbackDelta < 0 {
+		return errInvalidQueryEngineConfig
+	}
+	return nil
+}
+
+// Apply returns the querier engine config overridden by the non-zero settings of cfg.
+func (cfg *QueryEngineConfig) Apply(querierCfg engine.Config) engine.Config {
+	if cfg.MaxSamples > 0 {
+		querierCfg.MaxSamples = cfg.MaxSamples
+	}
+	if cfg.Timeout > 0 {
+		querierCfg.Timeout = cfg.Timeout
+	}
+	if cfg.LookbackDelta > 0 {
+		querierCfg.LookbackDelta = cfg.LookbackDelta
+	}
+	querierCfg.EnableAtModifier = cfg.EnableAtModifier
+	querierCfg.EnableNegativeOffset = cfg.EnableNegativeOffset
+	return querierCfg
+}
+
+// validateExprFeatures returns an error if expr uses a PromQL feature disabled in cfg.
+func (cfg *QueryEngineConfig) validateExprFeatures(expr parser.Expr) (err error) {
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		// Matrix selectors are covered by their inner vector selector.
+		var atModifierUsed, negativeOffsetUsed bool
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			atModifierUsed = n.Timestamp != nil || n.StartOrEnd != 0
+			negativeOffsetUsed = n.OriginalOffset < 0
+		case *parser.SubqueryExpr:
+			atModifierUsed = n.Timestamp != nil || n.StartOrEnd != 0
+			negativeOffsetUsed = n.OriginalOffset < 0
+		}
+
+		if atModifierUsed && !cfg.EnableAtModifier {
+			err = errAtModifierDisabled
+		} else if negativeOffsetUsed && !cfg.EnableNegativeOffset {
+			err = errNegativeOffsetDisabled
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return engine.Config{PromQLExperimentalFunctionsEnabled: cfg.ExperimentalFunctionsEnabled}.ValidateExperimentalFunctions(expr)
+}
+
+// ExperimentalFunctionsQueryFunc fails the queries calling experimental PromQL functions when they're
+// disabled in cfg. Such rule expressions are rejected by the ruler config API, but the rule groups
+// stored before the functions were disabled are still loaded.
+func ExperimentalFunctionsQueryFunc(qf rules.QueryFunc, cfg engine.Config) rules.QueryFunc {
+	if cfg.PromQLExperimentalFunctionsEnabled {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if expr, err := parser.ParseExpr(qs); err == nil {
+			if err := cfg.ValidateExperimentalFunctions(expr); err != nil {
+				return nil, err
+			}
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+// RateLimitedQueryFunc rejects the queries run by rule evaluations once limiter has been exhausted.
+// A rejected query fails the rule evaluation, which is retried at the next evaluation interval.
+func RateLimitedQueryFunc(qf rules.QueryFunc, limiter *rate.Limiter, rateLimitedQueries prometheus.Counter) rules.QueryFunc {
+	if limiter == nil {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if !limiter.Allow() {
+			rateLimitedQueries.Inc()
+			return nil, errRuleQueryRateLimited
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+// queryCircuitBreaker tracks the consecutive failures of the rule evaluation queries of a tenant.
+// Once failureThreshold is reached, the circuit breaker is open: queries are short-circuited until
+// cooldown has elapsed, after which a single query probes the read path.
+type queryCircuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mtx       sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newQueryCircuitBreaker(failureThreshold int, cooldown time.Duration) *queryCircuitBreaker {
+	if failureThreshold <= 0 {
+		return nil
+	}
+	return &queryCircuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+// allow returns whether a query can run.
+func (b *queryCircuitBreaker) allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.failures < b.failureThreshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a query which was allowed to run.
+func (b *queryCircuitBreaker) record(failed bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	if b.failures++; b.failures >= b.failureThreshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// CircuitBreakerQueryFunc short-circuits the queries run by rule evaluations while breaker is open,
+// failing the rule evaluations with errRuleQueryCircuitOpen. Only the internal failures of the read
+// path, as counted by MetricsQueryFunc, open the circuit breaker.
+func CircuitBreakerQueryFunc(qf rules.QueryFunc, breaker *queryCircuitBreaker, shortCircuitedQueries prometheus.Counter) rules.QueryFunc {
+	if breaker == nil {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if !breaker.allow() {
+			shortCircuitedQueries.Inc()
+			return nil, errRuleQueryCircuitOpen
+		}
+		result, err := qf(ctx, qs, t)
+		breaker.record(isFailedQuery(err))
+		return result, err
+	}
+}
+
+// SlowQueryLogFunc logs the queries run by rule evaluations taking longer than threshold.
+func SlowQueryLogFunc(qf rules.QueryFunc, userID string, threshold time.Duration, logger log.Logger) rules.QueryFunc {
+	if threshold <= 0 {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		start := time.Now()
+		result, err := qf(ctx, qs, t)
+
+		if elapsed := time.Since(start); elapsed > threshold {
+			logMessage := []interface{}{
+				"msg", "slow rule evaluation query detected",
+				"user", userID,
+			}
+			if g, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo); ok {
+				logMessage = append(logMessage, "rule_group_namespace", g.namespace, "rule_group", g.name, "rules", strings.Join(g.ruleNames(qs), ","))
+			}
+			logMessage = append(logMessage, "time_taken", elapsed.String(), "eval_time", t, "query", qs)
+			if err != nil {
+				logMessage = append(logMessage, "err", err)
+			}
+			level.Info(util_log.WithContext(ctx, logger)).Log(logMessage...)
+		}
+
+		return result, err
+	}
+}
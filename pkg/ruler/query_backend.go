@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/httpgrpc"
+	"google.golang.org/grpc"
+)
+
+// queryBackendSettings are the settings of the external query API evaluating the rules of a tenant.
+type queryBackendSettings struct {
+	url         string
+	username    string
+	password    string
+	bearerToken string
+}
+
+func queryBackendSettingsForTenant(limits RulesLimits, userID string) queryBackendSettings {
+	return queryBackendSettings{
+		url:         limits.RulerQueryBackendURL(userID),
+		username:    limits.RulerQueryBackendBasicAuthUsername(userID),
+		password:    limits.RulerQueryBackendBasicAuthPassword(userID),
+		bearerToken: limits.RulerQueryBackendBearerToken(userID),
+	}
+}
+
+// queryBackends holds the queriers of the external query APIs of the tenants, by settings, so that the
+// tenants with the same settings share a querier.
+type queryBackends struct {
+	client          *http.Client
+	maxResponseSize int64
+	logger          log.Logger
+
+	mtx      sync.Mutex
+	queriers map[queryBackendSettings]*RemoteQuerier
+}
+
+// newQueryBackends returns the queryBackends whose requests time out after timeout, and fail if the response
+// is larger than maxResponseSize bytes. 0 disables the timeout and the limit respectively.
+func newQueryBackends(timeout time.Duration, maxResponseSize int64, logger log.Logger) *queryBackends {
+	return &queryBackends{
+		client:          &http.Client{Timeout: timeout},
+		maxResponseSize: maxResponseSize,
+		logger:          logger,
+		queriers:        map[queryBackendSettings]*RemoteQuerier{},
+	}
+}
+
+func (b *queryBackends) querier(settings queryBackendSettings) (*RemoteQuerier, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if q, ok := b.queriers[settings]; ok {
+		return q, nil
+	}
+
+	u, err := url.Parse(settings.url)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid query backend URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("invalid query backend URL %q: the scheme must be http or https", settings.url)
+	}
+
+	client := &httpQueryBackendClient{client: b.client, baseURL: strings.TrimSuffix(u.String(), "/"), maxResponseSize: b.maxResponseSize}
+	q := NewRemoteQuerier(client, "", b.logger, withQueryBackendAuthMiddleware(settings), WithTracingMiddleware)
+	b.queriers[settings] = q
+	return q, nil
+}
+
+// QueryBackendQueryFunc returns a QueryFunc running the queries of the tenant against its external query API,
+// if any, and with qf otherwise. The settings of the tenant are read on each query, so that they can be changed
+// at runtime.
+func QueryBackendQueryFunc(qf rules.QueryFunc, userID string, limits RulesLimits, backends *queryBackends) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		settings := queryBackendSettingsForTenant(limits, userID)
+		if settings.url == "" {
+			return qf(ctx, qs, t)
+		}
+
+		q, err := backends.querier(settings)
+		if err != nil {
+			return nil, err
+		}
+		return q.Query(ctx, qs, t)
+	}
+}
+
+// withQueryBackendAuthMiddleware returns a Middleware authenticating the requests to the external query API.
+func withQueryBackendAuthMiddleware(settings queryBackendSettings) Middleware {
+	return func(_ context.Context, req *httpgrpc.HTTPRequest) error {
+		var auth string
+		switch {
+		case settings.bearerToken != "":
+			auth = "Bearer " + settings.bearerToken
+		case settings.username != "":
+			r := http.Request{Header: http.Header{}}
+			r.SetBasicAuth(settings.username, settings.password)
+			auth = r.Header.Get("Authorization")
+		default:
+			return nil
+		}
+		req.Headers = append(req.Headers, &httpgrpc.Header{Key: textproto.CanonicalMIMEHeaderKey("Authorization"), Values: []string{auth}})
+		return nil
+	}
+}
+
+// httpQueryBackendClient is a httpgrpc.HTTPClient sending the requests to an external HTTP API, replying
+// with an error to the 5xx responses like the httpgrpc server does, and to the responses larger than
+// maxResponseSize bytes, unless 0.
+type httpQueryBackendClient struct {
+	client          *http.Client
+	baseURL         string
+	maxResponseSize int64
+}
+
+func (c *httpQueryBackendClient) Handle(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, c.baseURL+req.Url, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range req.Headers {
+		httpReq.Header[h.Key] = h.Values
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	var body []byte
+	if c.maxResponseSize > 0 {
+		// Read one more byte than the limit to know whether the response exceeds it.
+		body, err = ioutil.ReadAll(io.LimitReader(httpResp.Body, c.maxResponseSize+1))
+		if err == nil && int64(len(body)) > c.maxResponseSize {
+			err = errors.Errorf("the response of the query backend exceeds the max response size of %d bytes", c.maxResponseSize)
+		}
+	} else {
+		body, err = ioutil.ReadAll(httpResp.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := &httpgrpc.HTTPResponse{Code: int32(httpResp.StatusCode), Body: body}
+	for k, v := range httpResp.Header {
+		resp.Headers = append(resp.Headers, &httpgrpc.Header{Key: k, Values: v})
+	}
+	if resp.Code/100 == 5 {
+		return nil, httpgrpc.ErrorFromHTTPResponse(resp)
+	}
+	return resp, nil
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestQueryBackendQueryFunc(t *testing.T) {
+	type request struct {
+		path, query, auth, orgID string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		requests = append(requests, request{path: r.URL.Path, query: r.Form.Get("query"), auth: r.Header.Get("Authorization"), orgID: r.Header.Get(user.OrgIDHeaderName)})
+
+		if r.Form.Get("query") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"backend"},"value":[1,"2"]}]}}`))
+	}))
+	defer server.Close()
+
+	internalResult := promql.Vector{{Metric: labels.FromStrings("job", "internal"), Point: promql.Point{T: 1000, V: 1}}}
+	internal := func(context.Context, string, time.Time) (promql.Vector, error) {
+		return internalResult, nil
+	}
+	backendResult := promql.Vector{{Metric: labels.FromStrings("job", "backend"), Point: promql.Point{T: 1000, V: 2}}}
+
+	tests := map[string]struct {
+		settings         queryBackendSettings
+		query            string
+		expectedResult   promql.Vector
+		expectedRequests []request
+		expectedErr      bool
+	}{
+		"should run the queries with the read path of Mimir without query backend": {
+			query:          "up",
+			expectedResult: internalResult,
+		},
+		"should run the queries against the query backend": {
+			settings:         queryBackendSettings{url: server.URL + "/prefix/"},
+			query:            "up",
+			expectedResult:   backendResult,
+			expectedRequests: []request{{path: "/prefix/api/v1/query", query: "up"}},
+		},
+		"should authenticate the requests with basic authentication": {
+			settings:         queryBackendSettings{url: server.URL, username: "user", password: "pass"},
+			query:            "up",
+			expectedResult:   backendResult,
+			expectedRequests: []request{{path: "/api/v1/query", query: "up", auth: "Basic dXNlcjpwYXNz"}},
+		},
+		"should authenticate the requests with the bearer token rather than basic authentication": {
+			settings:         queryBackendSettings{url: server.URL, username: "user", password: "pass", bearerToken: "token"},
+			query:            "up",
+			expectedResult:   backendResult,
+			expectedRequests: []request{{path: "/api/v1/query", query: "up", auth: "Bearer token"}},
+		},
+		"should fail the queries failing on the query backend": {
+			settings:         queryBackendSettings{url: server.URL},
+			query:            "fail",
+			expectedRequests: []request{{path: "/api/v1/query", query: "fail"}},
+			expectedErr:      true,
+		},
+		"should fail the queries with an invalid query backend URL": {
+			settings:    queryBackendSettings{url: "ftp://backend"},
+			query:       "up",
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			requests = nil
+			limits := ruleLimits{queryBackend: testData.settings}
+			qf := QueryBackendQueryFunc(internal, "user-1", limits, newQueryBackends(0, 0, log.NewNopLogger()))
+
+			result, err := qf(user.InjectOrgID(context.Background(), "user-1"), testData.query, time.Unix(1, 0))
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testData.expectedResult, result)
+			}
+			// The tenant ID isn't sent to the query backend.
+			assert.Equal(t, testData.expectedRequests, requests)
+		})
+	}
+}
+
+func TestQueryBackendQueryFunc_Limits(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.Form.Get("query") == "slow" {
+			<-release
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"backend"},"value":[1,"2"]}]}}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	internal := func(context.Context, string, time.Time) (promql.Vector, error) {
+		return nil, nil
+	}
+	limits := ruleLimits{queryBackend: queryBackendSettings{url: server.URL}}
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	t.Run("should fail the queries whose response exceeds the max response size", func(t *testing.T) {
+		qf := QueryBackendQueryFunc(internal, "user-1", limits, newQueryBackends(0, 10, log.NewNopLogger()))
+
+		_, err := qf(ctx, "up", time.Unix(1, 0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the max response size of 10 bytes")
+	})
+
+	t.Run("should run the queries whose response doesn't exceed the max response size", func(t *testing.T) {
+		qf := QueryBackendQueryFunc(internal, "user-1", limits, newQueryBackends(0, 1024, log.NewNopLogger()))
+
+		_, err := qf(ctx, "up", time.Unix(1, 0))
+		require.NoError(t, err)
+	})
+
+	t.Run("should fail the queries timing out", func(t *testing.T) {
+		qf := QueryBackendQueryFunc(internal, "user-1", limits, newQueryBackends(100*time.Millisecond, 0, log.NewNopLogger()))
+
+		start := time.Now()
+		_, err := qf(ctx, "slow", time.Unix(1, 0))
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+)
+
+// queryResultsCache caches the results of the queries run by rule evaluations for a short time, so that
+// identical queries run at the same timestamp by different rules are only executed once.
+type queryResultsCache struct {
+	ttl    time.Duration
+	hits   prometheus.Counter
+	misses prometheus.Counter
+
+	mtx       sync.Mutex
+	entries   map[queryResultsCacheKey]*queryResultsCacheEntry
+	lastPurge time.Time
+}
+
+type queryResultsCacheKey struct {
+	// Tenants queried, which differ from the tenant of the rule group for federated rule groups.
+	tenant string
+	query  string
+	ts     int64
+}
+
+type queryResultsCacheEntry struct {
+	done    chan struct{}
+	vector  promql.Vector
+	err     error
+	expires time.Time
+}
+
+func newQueryResultsCache(ttl time.Duration, hits, misses prometheus.Counter) *queryResultsCache {
+	return &queryResultsCache{
+		ttl:     ttl,
+		hits:    hits,
+		misses:  misses,
+		entries: map[queryResultsCacheKey]*queryResultsCacheEntry{},
+	}
+}
+
+// CachedQueryFunc returns a rules.QueryFunc returning the cached result of an identical query run at
+// the same timestamp for the same tenants, if any. A query identical to a running one waits for its
+// result instead of being executed again. It's a no-op if cache is nil.
+func CachedQueryFunc(qf rules.QueryFunc, cache *queryResultsCache) rules.QueryFunc {
+	if cache == nil {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		tenant, err := user.ExtractOrgID(ctx)
+		if err != nil {
+			return qf(ctx, qs, t)
+		}
+		return cache.query(ctx, queryResultsCacheKey{tenant: tenant, query: qs, ts: t.UnixMilli()}, func() (promql.Vector, error) {
+			return qf(ctx, qs, t)
+		})
+	}
+}
+
+func (c *queryResultsCache) query(ctx context.Context, key queryResultsCacheKey, run func() (promql.Vector, error)) (promql.Vector, error) {
+	now := time.Now()
+
+	c.mtx.Lock()
+	c.purgeExpired(now)
+	entry, ok := c.entries[key]
+	if ok && entry.expired(now) {
+		ok = false
+	}
+	if !ok {
+		entry = &queryResultsCacheEntry{done: make(chan struct{}), expires: now.Add(c.ttl)}
+		c.entries[key] = entry
+	}
+	c.mtx.Unlock()
+
+	if !ok {
+		c.misses.Inc()
+		entry.vector, entry.err = run()
+		if entry.err != nil {
+			// Failed queries are not cached, but they're returned to the identical queries waiting for them.
+			c.mtx.Lock()
+			if c.entries[key] == entry {
+				delete(c.entries, key)
+			}
+			c.mtx.Unlock()
+		}
+		close(entry.done)
+		return copyVector(entry.vector), entry.err
+	}
+
+	c.hits.Inc()
+	select {
+	case <-entry.done:
+		return copyVector(entry.vector), entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// purgeExpired removes the expired entries, at most once per TTL. It must be called with the lock held.
+func (c *queryResultsCache) purgeExpired(now time.Time) {
+	if now.Sub(c.lastPurge) < c.ttl {
+		return
+	}
+	c.lastPurge = now
+
+	for key, entry := range c.entries {
+		if entry.expired(now) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// expired returns whether the entry has expired. The entries of running queries never expire.
+func (e *queryResultsCacheEntry) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return now.After(e.expires)
+	default:
+		return false
+	}
+}
+
+// copyVector returns a copy of the vector, because the recording rules overwrite the labels of the
+// samples returned by their query.
+func copyVector(v promql.Vector) promql.Vector {
+	if v == nil {
+		return nil
+	}
+	c := make(promql.Vector, len(v))
+	copy(c, v)
+	return c
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+)
+
+func TestCachedQueryFunc(t *testing.T) {
+	var executed atomic.Int64
+	failing := atomic.NewBool(false)
+	qf := func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		executed.Inc()
+		if failing.Load() {
+			return nil, errors.New("query failed")
+		}
+		return promql.Vector{{Metric: labels.FromStrings("query", qs), Point: promql.Point{V: 1}}}, nil
+	}
+
+	hits := prometheus.NewCounter(prometheus.CounterOpts{})
+	misses := prometheus.NewCounter(prometheus.CounterOpts{})
+	cached := CachedQueryFunc(qf, newQueryResultsCache(time.Minute, hits, misses))
+
+	ctx1 := user.InjectOrgID(context.Background(), "user-1")
+	ctx2 := user.InjectOrgID(context.Background(), "user-2")
+	ts := time.Unix(100, 0)
+
+	res, err := cached(ctx1, "up", ts)
+	require.NoError(t, err)
+	assert.Equal(t, labels.FromStrings("query", "up"), res[0].Metric)
+
+	// Overwriting the labels of a result, as recording rules do, doesn't alter the cached result.
+	res[0].Metric = labels.FromStrings("__name__", "recorded")
+
+	res, err = cached(ctx1, "up", ts)
+	require.NoError(t, err)
+	assert.Equal(t, labels.FromStrings("query", "up"), res[0].Metric)
+	assert.Equal(t, int64(1), executed.Load())
+
+	// Different tenants, queries and timestamps are not cached together.
+	_, err = cached(ctx2, "up", ts)
+	require.NoError(t, err)
+	_, err = cached(ctx1, "down", ts)
+	require.NoError(t, err)
+	_, err = cached(ctx1, "up", ts.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), executed.Load())
+
+	// Failed queries are not cached.
+	failing.Store(true)
+	_, err = cached(ctx1, "error", ts)
+	require.Error(t, err)
+	failing.Store(false)
+	_, err = cached(ctx1, "error", ts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), executed.Load())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(hits))
+	assert.Equal(t, float64(6), testutil.ToFloat64(misses))
+}
+
+func TestCachedQueryFunc_ConcurrentIdenticalQueries(t *testing.T) {
+	const numQueries = 10
+
+	var executed atomic.Int64
+	release := make(chan struct{})
+	qf := func(context.Context, string, time.Time) (promql.Vector, error) {
+		executed.Inc()
+		<-release
+		return promql.Vector{{Point: promql.Point{V: 1}}}, nil
+	}
+
+	hits := prometheus.NewCounter(prometheus.CounterOpts{})
+	misses := prometheus.NewCounter(prometheus.CounterOpts{})
+	cached := CachedQueryFunc(qf, newQueryResultsCache(time.Minute, hits, misses))
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	wg := sync.WaitGroup{}
+	wg.Add(numQueries)
+	for i := 0; i < numQueries; i++ {
+		go func() {
+			defer wg.Done()
+			res, err := cached(ctx, "up", time.Unix(100, 0))
+			assert.NoError(t, err)
+			assert.Len(t, res, 1)
+		}()
+	}
+
+	// Wait for all the queries to be running or waiting before returning the result.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(hits)+testutil.ToFloat64(misses) == numQueries
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), executed.Load())
+}
+
+func TestQueryResultsCache_Expiration(t *testing.T) {
+	c := newQueryResultsCache(time.Millisecond, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{}))
+	key := queryResultsCacheKey{tenant: "user-1", query: "up", ts: 100}
+	run := func() (promql.Vector, error) { return promql.Vector{}, nil }
+
+	_, err := c.query(context.Background(), key, run)
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+
+	// The expired entries are purged once another query is run.
+	_, err = c.query(context.Background(), queryResultsCacheKey{tenant: "user-1", query: "down", ts: 100}, run)
+	require.NoError(t, err)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	assert.NotContains(t, c.entries, key)
+}
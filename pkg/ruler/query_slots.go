@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// tenantQuerySlots bounds the number of queries that the rule evaluations of a tenant run concurrently.
+//
+// The Prometheus rules manager runs each rule group in its own goroutine, so the rule groups of all the
+// tenants compete for the same PromQL engine, whose number of concurrent queries is limited. Bounding the
+// queries of each tenant prevents the tenants with many rule groups from delaying the rule evaluations
+// of the other tenants.
+type tenantQuerySlots struct {
+	// limit returns the maximum number of concurrent queries, which is read whenever a query starts
+	// so that the changes of the limit are applied without reloading the rules of the tenant.
+	limit func() int
+
+	mtx      sync.Mutex
+	inFlight int
+	// Queries waiting for a slot, in arrival order. A slot is given to a query by closing its channel.
+	waiting []chan struct{}
+}
+
+func newTenantQuerySlots(limit func() int) *tenantQuerySlots {
+	return &tenantQuerySlots{limit: limit}
+}
+
+// available returns whether a slot is available. It must be called with the mutex locked.
+func (s *tenantQuerySlots) available() bool {
+	limit := s.limit()
+	return limit <= 0 || s.inFlight < limit
+}
+
+// acquire waits for a slot to be available, returning whether the query had to wait. It returns an
+// error if the context is canceled while waiting.
+func (s *tenantQuerySlots) acquire(ctx context.Context) (bool, error) {
+	s.mtx.Lock()
+	if len(s.waiting) == 0 && s.available() {
+		s.inFlight++
+		s.mtx.Unlock()
+		return false, nil
+	}
+	ready := make(chan struct{})
+	s.waiting = append(s.waiting, ready)
+	s.mtx.Unlock()
+
+	select {
+	case <-ready:
+		return true, nil
+	case <-ctx.Done():
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		for i, w := range s.waiting {
+			if w == ready {
+				s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+				return true, ctx.Err()
+			}
+		}
+		// The slot was given to the query while the context was canceled.
+		s.releaseLocked()
+		return true, ctx.Err()
+	}
+}
+
+// release frees the slot of a completed query, giving it to the next waiting queries.
+func (s *tenantQuerySlots) release() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.releaseLocked()
+}
+
+func (s *tenantQuerySlots) releaseLocked() {
+	s.inFlight--
+	for len(s.waiting) > 0 && s.available() {
+		s.inFlight++
+		close(s.waiting[0])
+		s.waiting = s.waiting[1:]
+	}
+}
+
+// ConcurrencyLimitedQueryFunc runs the queries of the rule evaluations once a slot is available in slots,
+// adding the time spent waiting for it to waitSeconds.
+func ConcurrencyLimitedQueryFunc(qf rules.QueryFunc, slots *tenantQuerySlots, waitSeconds prometheus.Counter) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		start := time.Now()
+		waited, err := slots.acquire(ctx)
+		if waited {
+			waitSeconds.Add(time.Since(start).Seconds())
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer slots.release()
+
+		return qf(ctx, qs, t)
+	}
+}
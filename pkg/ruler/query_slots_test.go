@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestTenantQuerySlots(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		slots := newTenantQuerySlots(func() int { return 0 })
+		for i := 0; i < 10; i++ {
+			waited, err := slots.acquire(context.Background())
+			require.NoError(t, err)
+			assert.False(t, waited)
+		}
+	})
+
+	t.Run("slots are given in arrival order", func(t *testing.T) {
+		slots := newTenantQuerySlots(func() int { return 1 })
+		_, err := slots.acquire(context.Background())
+		require.NoError(t, err)
+
+		var (
+			mtx   sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+		for i := 0; i < 3; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				waited, err := slots.acquire(context.Background())
+				assert.NoError(t, err)
+				assert.True(t, waited)
+
+				mtx.Lock()
+				order = append(order, i)
+				mtx.Unlock()
+				slots.release()
+			}()
+			// Wait for the query to be queued before starting the next one.
+			require.Eventually(t, func() bool {
+				slots.mtx.Lock()
+				defer slots.mtx.Unlock()
+				return len(slots.waiting) == i+1
+			}, time.Second, time.Millisecond)
+		}
+
+		slots.release()
+		wg.Wait()
+		assert.Equal(t, []int{0, 1, 2}, order)
+		assert.Equal(t, 0, slots.inFlight)
+	})
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		slots := newTenantQuerySlots(func() int { return 1 })
+		_, err := slots.acquire(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		waited, err := slots.acquire(ctx)
+		assert.True(t, waited)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, slots.waiting)
+
+		slots.release()
+		assert.Equal(t, 0, slots.inFlight)
+	})
+
+	t.Run("limit changed at runtime", func(t *testing.T) {
+		limit := atomic.NewInt64(1)
+		slots := newTenantQuerySlots(func() int { return int(limit.Load()) })
+		_, err := slots.acquire(context.Background())
+		require.NoError(t, err)
+
+		limit.Store(2)
+		waited, err := slots.acquire(context.Background())
+		require.NoError(t, err)
+		assert.False(t, waited)
+		assert.Equal(t, 2, slots.inFlight)
+	})
+}
+
+func TestConcurrencyLimitedQueryFunc(t *testing.T) {
+	const limit = 2
+
+	var (
+		running    = atomic.NewInt64(0)
+		maxRunning = atomic.NewInt64(0)
+	)
+	qf := func(context.Context, string, time.Time) (promql.Vector, error) {
+		n := running.Inc()
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CAS(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Dec()
+		return promql.Vector{}, nil
+	}
+
+	waitSeconds := prometheus.NewCounter(prometheus.CounterOpts{})
+	limited := ConcurrencyLimitedQueryFunc(qf, newTenantQuerySlots(func() int { return limit }), waitSeconds)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limited(context.Background(), "up", time.Now())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(limit), maxRunning.Load())
+	assert.Greater(t, testutil.ToFloat64(waitSeconds), 0.0)
+}
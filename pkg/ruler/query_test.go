@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/querier/engine"
+)
+
+func TestSlowQueryLogFunc(t *testing.T) {
+	expr, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+
+	g := rules.NewGroup(rules.GroupOptions{
+		Name: "group-1",
+		File: "/rules/user-1/namespace-1",
+		Opts: &rules.ManagerOptions{},
+		Rules: []rules.Rule{
+			rules.NewRecordingRule("up:sum", expr, nil),
+			rules.NewRecordingRule("up:sum:copy", expr, nil),
+		},
+	})
+	ctx := RuleGroupContextFunc(context.Background(), g)
+
+	for name, tc := range map[string]struct {
+		threshold   time.Duration
+		queryTime   time.Duration
+		expectedLog bool
+	}{
+		"disabled": {
+			threshold: 0,
+			queryTime: 10 * time.Millisecond,
+		},
+		"query faster than the threshold": {
+			threshold: time.Hour,
+			queryTime: 0,
+		},
+		"query slower than the threshold": {
+			threshold:   time.Millisecond,
+			queryTime:   10 * time.Millisecond,
+			expectedLog: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			qf := SlowQueryLogFunc(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+				time.Sleep(tc.queryTime)
+				return nil, nil
+			}, "user-1", tc.threshold, log.NewLogfmtLogger(buf))
+
+			_, err := qf(ctx, "sum(up)", time.Unix(0, 0).UTC())
+			require.NoError(t, err)
+
+			if !tc.expectedLog {
+				assert.Empty(t, buf.String())
+				return
+			}
+			assert.Contains(t, buf.String(), `msg="slow rule evaluation query detected" user=user-1 rule_group_namespace=namespace-1 rule_group=group-1 rules=up:sum,up:sum:copy time_taken=`)
+			assert.Contains(t, buf.String(), `query=sum(up)`)
+		})
+	}
+}
+
+func TestRateLimitedQueryFunc(t *testing.T) {
+	queries := 0
+	qf := func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		queries++
+		return nil, nil
+	}
+
+	rateLimited := prometheus.NewCounter(prometheus.CounterOpts{})
+	limited := RateLimitedQueryFunc(qf, rate.NewLimiter(rate.Limit(0.001), 2), rateLimited)
+
+	for i := 0; i < 2; i++ {
+		_, err := limited(context.Background(), "up", time.Now())
+		require.NoError(t, err)
+	}
+
+	_, err := limited(context.Background(), "up", time.Now())
+	require.Equal(t, errRuleQueryRateLimited, err)
+
+	assert.Equal(t, 2, queries)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rateLimited))
+}
+
+func TestCircuitBreakerQueryFunc(t *testing.T) {
+	var (
+		queries  = 0
+		queryErr error
+	)
+	qf := func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		queries++
+		return nil, queryErr
+	}
+
+	now := time.Now()
+	breaker := newQueryCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	shortCircuited := prometheus.NewCounter(prometheus.CounterOpts{})
+	wrapped := CircuitBreakerQueryFunc(qf, breaker, shortCircuited)
+
+	// User errors don't open the circuit breaker.
+	queryErr = WrapQueryableErrors(promql.ErrTooManySamples("test"))
+	for i := 0; i < 3; i++ {
+		_, err := wrapped(context.Background(), "up", now)
+		require.Equal(t, queryErr, err)
+	}
+
+	// Consecutive failures of the read path open the circuit breaker.
+	queryErr = WrapQueryableErrors(promql.ErrStorage{Err: errors.New("test")})
+	for i := 0; i < 2; i++ {
+		_, err := wrapped(context.Background(), "up", now)
+		require.Equal(t, queryErr, err)
+	}
+	_, err := wrapped(context.Background(), "up", now)
+	require.Equal(t, errRuleQueryCircuitOpen, err)
+	assert.Equal(t, 5, queries)
+
+	// After the cooldown, a failing probe opens the circuit breaker again.
+	now = now.Add(time.Minute)
+	_, err = wrapped(context.Background(), "up", now)
+	require.Equal(t, queryErr, err)
+	_, err = wrapped(context.Background(), "up", now)
+	require.Equal(t, errRuleQueryCircuitOpen, err)
+	assert.Equal(t, 6, queries)
+
+	// A successful probe closes the circuit breaker.
+	now = now.Add(time.Minute)
+	queryErr = nil
+	for i := 0; i < 2; i++ {
+		_, err = wrapped(context.Background(), "up", now)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 8, queries)
+	assert.Equal(t, float64(2), testutil.ToFloat64(shortCircuited))
+
+	// The circuit breaker is disabled with a zero failure threshold.
+	assert.Nil(t, newQueryCircuitBreaker(0, time.Minute))
+}
+
+func TestQueryConfig_TenantBurst(t *testing.T) {
+	assert.Equal(t, 3, (&QueryConfig{TenantQPS: 2.5}).tenantBurst())
+	assert.Equal(t, 10, (&QueryConfig{TenantQPS: 2.5, TenantBurst: 10}).tenantBurst())
+}
+
+func TestQueryEngineConfig_Apply(t *testing.T) {
+	querierCfg := engine.Config{
+		MaxConcurrent: 20,
+		Timeout:       2 * time.Minute,
+		MaxSamples:    50e6,
+		LookbackDelta: 5 * time.Minute,
+
+		EnableAtModifier: true,
+	}
+
+	// Zero values inherit the querier config.
+	assert.Equal(t, querierCfg, (&QueryEngineConfig{EnableAtModifier: true}).Apply(querierCfg))
+
+	assert.Equal(t, engine.Config{
+		MaxConcurrent: 20,
+		Timeout:       30 * time.Second,
+		MaxSamples:    1000,
+		LookbackDelta: 10 * time.Minute,
+
+		EnableNegativeOffset: true,
+	}, (&QueryEngineConfig{MaxSamples: 1000, Timeout: 30 * time.Second, LookbackDelta: 10 * time.Minute, EnableNegativeOffset: true}).Apply(querierCfg))
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"io"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/crypto/tls"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/evaluatorpb"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// RemoteEvaluatorConfig defines the transport configuration of the rule evaluators of the queriers.
+type RemoteEvaluatorConfig struct {
+	// The address of the queriers to connect to.
+	Address string `yaml:"address"`
+
+	// TLSEnabled tells whether TLS should be used to establish remote connection.
+	TLSEnabled bool `yaml:"tls_enabled"`
+
+	// TLS is the config for client TLS.
+	TLS tls.ClientConfig `yaml:",inline"`
+}
+
+func (c *RemoteEvaluatorConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.Address,
+		"ruler.remote-evaluator.address",
+		"",
+		"GRPC listen address of the queriers exposing the rule evaluator, enabled by -querier.rule-evaluator.enabled. "+
+			"When set, the expressions of the rules are evaluated by the queriers instead of the ruler. Must be a DNS address (prefixed with dns:///) "+
+			"to enable client side load balancing.")
+
+	f.BoolVar(&c.TLSEnabled, "ruler.remote-evaluator.tls-enabled", false, "Set to true if the querier connection requires TLS.")
+
+	c.TLS.RegisterFlagsWithPrefix("ruler.remote-evaluator", f)
+}
+
+// DialRemoteEvaluator creates and initializes a new evaluatorpb.EvaluatorClient taking a RemoteEvaluatorConfig configuration.
+func DialRemoteEvaluator(cfg RemoteEvaluatorConfig) (evaluatorpb.EvaluatorClient, error) {
+	tlsDialOptions, err := cfg.TLS.GetGRPCDialOptions(cfg.TLSEnabled)
+	if err != nil {
+		return nil, err
+	}
+	dialOptions := append(
+		[]grpc.DialOption{
+			grpc.WithKeepaliveParams(
+				keepalive.ClientParameters{
+					Time:                keepAlive,
+					Timeout:             keepAliveTimeout,
+					PermitWithoutStream: true,
+				},
+			),
+			grpc.WithStreamInterceptor(
+				grpc_middleware.ChainStreamClient(
+					otgrpc.OpenTracingStreamClientInterceptor(opentracing.GlobalTracer()),
+					middleware.StreamClientUserHeaderInterceptor,
+				),
+			),
+			grpc.WithDefaultServiceConfig(serviceConfig),
+		},
+		tlsDialOptions...,
+	)
+
+	conn, err := grpc.Dial(cfg.Address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return evaluatorpb.NewEvaluatorClient(conn), nil
+}
+
+// RemoteEvaluator evaluates the expressions of the rules on the queriers, through their rule evaluators.
+type RemoteEvaluator struct {
+	client evaluatorpb.EvaluatorClient
+	logger log.Logger
+}
+
+// NewRemoteEvaluator creates and initializes a new RemoteEvaluator instance.
+func NewRemoteEvaluator(client evaluatorpb.EvaluatorClient, logger log.Logger) *RemoteEvaluator {
+	return &RemoteEvaluator{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Query evaluates the expression at the given time. It satisfies rules.QueryFunc.
+// The batches streamed by the querier are accumulated into the full result vector, which the
+// rules need as a whole: the memory used by the ruler is bounded only by the max response size
+// of the queriers. The batches already received are discarded if the evaluation fails.
+func (e *RemoteEvaluator) Query(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+	logger, ctx := spanlogger.NewWithLogger(ctx, e.logger, "ruler.RemoteEvaluator.Query")
+	defer logger.Span.Finish()
+
+	// The querier reads the tenant from the context, which is the source tenants of a federated rule.
+	orgID, err := ExtractTenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(user.InjectOrgID(ctx, orgID))
+	defer cancel()
+
+	stream, err := e.client.Evaluate(ctx, &evaluatorpb.EvaluateRequest{
+		Expr:        qs,
+		TimestampMs: t.UnixMilli(),
+	})
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to remotely evaluate query expression", "err", err, "qs", qs, "tm", t)
+		return nil, err
+	}
+
+	var vector promql.Vector
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to remotely evaluate query expression", "err", err, "qs", qs, "tm", t)
+			return nil, err
+		}
+		for _, s := range resp.Samples {
+			vector = append(vector, promql.Sample{
+				Point:  promql.Point{T: s.TimestampMs, V: s.Value},
+				Metric: mimirpb.FromLabelAdaptersToLabelsWithCopy(s.Labels),
+			})
+		}
+	}
+	level.Debug(logger).Log("msg", "query expression successfully evaluated", "qs", qs, "tm", t, "samples", len(vector))
+	return vector, nil
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/evaluatorpb"
+)
+
+type mockEvaluatorServer struct {
+	batches [][]evaluatorpb.Sample
+	err     error
+
+	orgIDs []string
+	reqs   []*evaluatorpb.EvaluateRequest
+}
+
+func (m *mockEvaluatorServer) Evaluate(req *evaluatorpb.EvaluateRequest, stream evaluatorpb.Evaluator_EvaluateServer) error {
+	orgID, err := user.ExtractOrgID(stream.Context())
+	if err != nil {
+		return err
+	}
+	m.orgIDs = append(m.orgIDs, orgID)
+	m.reqs = append(m.reqs, req)
+
+	for _, batch := range m.batches {
+		if err := stream.Send(&evaluatorpb.EvaluateResponse{Samples: batch}); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func newRemoteEvaluatorTestClient(t *testing.T, server evaluatorpb.EvaluatorServer) evaluatorpb.EvaluatorClient {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer(grpc.StreamInterceptor(middleware.StreamServerUserHeaderInterceptor))
+	evaluatorpb.RegisterEvaluatorServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	client, err := DialRemoteEvaluator(RemoteEvaluatorConfig{Address: listener.Addr().String()})
+	require.NoError(t, err)
+	return client
+}
+
+func TestRemoteEvaluator_Query(t *testing.T) {
+	ts := time.Unix(1000, 0)
+	sample := func(instance string) evaluatorpb.Sample {
+		return evaluatorpb.Sample{
+			Labels:      mimirpb.FromLabelsToLabelAdapters(labels.FromStrings("__name__", "up", "instance", instance)),
+			Value:       1,
+			TimestampMs: ts.UnixMilli(),
+		}
+	}
+
+	t.Run("should concatenate the streamed batches of samples", func(t *testing.T) {
+		server := &mockEvaluatorServer{batches: [][]evaluatorpb.Sample{{sample("a"), sample("b")}, {sample("c")}}}
+		evaluator := NewRemoteEvaluator(newRemoteEvaluatorTestClient(t, server), log.NewNopLogger())
+
+		vector, err := evaluator.Query(user.InjectOrgID(context.Background(), "user-1"), "up", ts)
+		require.NoError(t, err)
+		assert.Equal(t, promql.Vector{
+			{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("__name__", "up", "instance", "a")},
+			{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("__name__", "up", "instance", "b")},
+			{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("__name__", "up", "instance", "c")},
+		}, vector)
+
+		assert.Equal(t, []string{"user-1"}, server.orgIDs)
+		require.Len(t, server.reqs, 1)
+		assert.Equal(t, "up", server.reqs[0].Expr)
+		assert.Equal(t, ts.UnixMilli(), server.reqs[0].TimestampMs)
+	})
+
+	t.Run("should evaluate federated rules on behalf of the source tenants", func(t *testing.T) {
+		server := &mockEvaluatorServer{}
+		evaluator := NewRemoteEvaluator(newRemoteEvaluatorTestClient(t, server), log.NewNopLogger())
+
+		ctx := context.WithValue(user.InjectOrgID(context.Background(), "user-1"), federatedGroupSourceTenants, []string{"tenant-b", "tenant-a"})
+		vector, err := evaluator.Query(ctx, "up", ts)
+		require.NoError(t, err)
+		assert.Empty(t, vector)
+		assert.Equal(t, []string{"tenant-a|tenant-b"}, server.orgIDs)
+	})
+
+	t.Run("should fail if the evaluation fails after some batches are streamed", func(t *testing.T) {
+		server := &mockEvaluatorServer{
+			batches: [][]evaluatorpb.Sample{{sample("a")}},
+			err:     status.Error(codes.ResourceExhausted, "the result of the rule expression exceeds the max response size of 100 bytes"),
+		}
+		evaluator := NewRemoteEvaluator(newRemoteEvaluatorTestClient(t, server), log.NewNopLogger())
+
+		_, err := evaluator.Query(user.InjectOrgID(context.Background(), "user-1"), "up", ts)
+		require.Error(t, err)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
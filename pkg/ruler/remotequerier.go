@@ -3,15 +3,19 @@
 package ruler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"net/textproto"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
@@ -19,6 +23,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/flagext"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
@@ -34,6 +39,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/version"
 )
@@ -50,6 +56,13 @@ const (
 	mimeTypeFormPost = "application/x-www-form-urlencoded"
 
 	statusError = "error"
+
+	// The Server-Timing header, and its metrics, used by the query-frontend to report the stats of the queriers.
+	serverTimingHeaderName              = "Server-Timing"
+	querierWallTimeServerTimingMetric   = "querier_wall_time"
+	fetchedSeriesServerTimingMetric     = "fetched_series_count"
+	fetchedChunksServerTimingMetric     = "fetched_chunks_count"
+	fetchedChunkBytesServerTimingMetric = "fetched_chunk_bytes"
 )
 
 var userAgent = fmt.Sprintf("mimir/%s", version.Version)
@@ -64,6 +77,9 @@ type QueryFrontendConfig struct {
 
 	// TLS is the config for client TLS.
 	TLS tls.ClientConfig `yaml:",inline"`
+
+	// ProxyURL is the URL of the HTTP proxy to connect through.
+	ProxyURL flagext.URLValue `yaml:"proxy_url" category:"advanced"`
 }
 
 func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
@@ -76,6 +92,8 @@ func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.TLSEnabled, "ruler.query-frontend.tls-enabled", false, "Set to true if query-frontend connection requires TLS.")
 
 	c.TLS.RegisterFlagsWithPrefix("ruler.query-frontend", f)
+
+	f.Var(&c.ProxyURL, "ruler.query-frontend.proxy-url", "URL of the HTTP proxy to connect to the query-frontend through, using the HTTP CONNECT method. If empty, the proxy configured by the HTTPS_PROXY and NO_PROXY environment variables is used.")
 }
 
 // DialQueryFrontend creates and initializes a new httpgrpc.HTTPClient taking a QueryFrontendConfig configuration.
@@ -103,6 +121,11 @@ func DialQueryFrontend(cfg QueryFrontendConfig) (httpgrpc.HTTPClient, error) {
 		},
 		tlsDialOptions...,
 	)
+	if proxyURL := cfg.ProxyURL.URL; proxyURL != nil {
+		dialOptions = append(dialOptions, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialThroughProxy(ctx, proxyURL, addr)
+		}))
+	}
 
 	conn, err := grpc.Dial(cfg.Address, dialOptions...)
 	if err != nil {
@@ -111,6 +134,49 @@ func DialQueryFrontend(cfg QueryFrontendConfig) (httpgrpc.HTTPClient, error) {
 	return httpgrpc.NewHTTPClient(conn), nil
 }
 
+// dialThroughProxy connects to addr through the HTTP proxy at proxyURL, using the HTTP CONNECT method.
+func dialThroughProxy(ctx context.Context, proxyURL *url.URL, addr string) (_ net.Conn, err error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the proxy")
+	}
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{"User-Agent": []string{userAgent}},
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, errors.Wrap(err, "failed to send the CONNECT request to the proxy")
+	}
+
+	// The server doesn't send anything before the client, so nothing past the response is buffered.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the CONNECT response from the proxy")
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("the proxy refused to connect to %s: %s", addr, resp.Status)
+	}
+	return conn, nil
+}
+
 // Middleware provides a mechanism to inspect outgoing remote querier requests.
 type Middleware func(ctx context.Context, req *httpgrpc.HTTPRequest) error
 
@@ -246,6 +312,11 @@ func (q *RemoteQuerier) query(ctx context.Context, query string, ts time.Time, l
 	}
 	level.Debug(logger).Log("msg", "query expression successfully evaluated", "qs", query, "tm", ts)
 
+	// Propagate the stats of the queriers, reported by the query-frontend, to the query stats.
+	if stats := querier_stats.FromContext(ctx); stats != nil {
+		stats.Merge(querierStatsFromHeaders(resp.Headers))
+	}
+
 	var apiResp struct {
 		Status    string          `json:"status"`
 		Data      json.RawMessage `json:"data"`
@@ -319,6 +390,57 @@ func scalarToPromQLVector(sc *prommodel.Scalar) promql.Vector {
 	}}
 }
 
+// querierStatsFromHeaders returns the stats of the queriers reported by the query-frontend in the
+// Server-Timing response header: the querier wall time, and the number of fetched series and chunks, and
+// the size of the fetched chunks. The stats not reported, or invalid, are zero.
+func querierStatsFromHeaders(headers []*httpgrpc.Header) *querier_stats.Stats {
+	stats := &querier_stats.Stats{}
+	for _, h := range headers {
+		if textproto.CanonicalMIMEHeaderKey(h.Key) != serverTimingHeaderName {
+			continue
+		}
+		for _, v := range h.Values {
+			for _, metric := range strings.Split(v, ",") {
+				parts := strings.Split(strings.TrimSpace(metric), ";")
+				for _, param := range parts[1:] {
+					key, value, ok := cutParam(param)
+					if !ok {
+						continue
+					}
+					switch {
+					case parts[0] == querierWallTimeServerTimingMetric && key == "dur":
+						if ms, err := strconv.ParseFloat(value, 64); err == nil {
+							stats.AddWallTime(time.Duration(ms * float64(time.Millisecond)))
+						}
+					case parts[0] == fetchedSeriesServerTimingMetric && key == "desc":
+						if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+							stats.AddFetchedSeries(n)
+						}
+					case parts[0] == fetchedChunksServerTimingMetric && key == "desc":
+						if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+							stats.AddFetchedChunks(n)
+						}
+					case parts[0] == fetchedChunkBytesServerTimingMetric && key == "desc":
+						if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+							stats.AddFetchedChunkBytes(n)
+						}
+					}
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// cutParam splits a Server-Timing metric parameter into its key and value.
+func cutParam(param string) (key, value string, ok bool) {
+	idx := strings.Index(param, "=")
+	if idx < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(param[:idx]), strings.Trim(strings.TrimSpace(param[idx+1:]), `"`), true
+}
+
 // WithOrgIDMiddleware attaches 'X-Scope-OrgID' header value to the outgoing request by inspecting the passed context.
 // In case the expression to evaluate corresponds to a federated rule, the ExtractTenantIDs function will take care
 // of normalizing and concatenating source tenants by separating them with a '|' character.
@@ -3,8 +3,13 @@
 package ruler
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -16,6 +21,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
 	"google.golang.org/grpc"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 )
 
 type mockHTTPGRPCClient func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error)
@@ -74,3 +81,112 @@ func TestRemoteQuerier_QueryReq(t *testing.T) {
 	require.Equal(t, "query=qs&time="+url.QueryEscape(tm.Format(time.RFC3339Nano)), string(inReq.Body))
 	require.Equal(t, "/prometheus/api/v1/query", inReq.Url)
 }
+
+func TestRemoteQuerier_QuerierStats(t *testing.T) {
+	mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+		return &httpgrpc.HTTPResponse{
+			Code: http.StatusOK,
+			Headers: []*httpgrpc.Header{
+				{Key: "Server-Timing", Values: []string{"querier_wall_time;dur=1500.5, response_time;dur=2000, fetched_series_count;desc=10, fetched_chunks_count;desc=20, fetched_chunk_bytes;desc=3000"}},
+			},
+			Body: []byte(`{"status": "success","data": {"resultType":"vector","result":[]}}`),
+		}, nil
+	}
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), "/prometheus", log.NewNopLogger())
+
+	stats, ctx := querier_stats.ContextWithEmptyStats(context.Background())
+	_, err := q.Query(ctx, "qs", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1500500*time.Microsecond, stats.LoadWallTime())
+	require.Equal(t, uint64(10), stats.LoadFetchedSeries())
+	require.Equal(t, uint64(20), stats.LoadFetchedChunks())
+	require.Equal(t, uint64(3000), stats.LoadFetchedChunkBytes())
+}
+
+func TestQuerierStatsFromHeaders(t *testing.T) {
+	for name, tc := range map[string]struct {
+		headers        []*httpgrpc.Header
+		expectedWall   time.Duration
+		expectedSeries uint64
+	}{
+		"no headers": {},
+		"no querier stats": {
+			headers: []*httpgrpc.Header{{Key: "Server-Timing", Values: []string{"response_time;dur=2000"}}},
+		},
+		"querier wall time": {
+			headers:      []*httpgrpc.Header{{Key: "server-timing", Values: []string{"response_time;dur=2000, querier_wall_time;dur=12"}}},
+			expectedWall: 12 * time.Millisecond,
+		},
+		"invalid querier wall time": {
+			headers: []*httpgrpc.Header{{Key: "Server-Timing", Values: []string{"querier_wall_time;dur=abc"}}},
+		},
+		"fetched series": {
+			headers:        []*httpgrpc.Header{{Key: "Server-Timing", Values: []string{`querier_wall_time;dur=12, fetched_series_count;desc="7"`}}},
+			expectedWall:   12 * time.Millisecond,
+			expectedSeries: 7,
+		},
+		"invalid fetched series": {
+			headers: []*httpgrpc.Header{{Key: "Server-Timing", Values: []string{"fetched_series_count;desc=-1"}}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stats := querierStatsFromHeaders(tc.headers)
+			require.Equal(t, tc.expectedWall, stats.LoadWallTime())
+			require.Equal(t, tc.expectedSeries, stats.LoadFetchedSeries())
+		})
+	}
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	// A minimal proxy accepting a single CONNECT request, and echoing what's sent through the tunnel.
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	var connectReq *http.Request
+	go func() {
+		conn, err := proxy.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		if connectReq, err = http.ReadRequest(r); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		_, _ = io.Copy(conn, r)
+	}()
+
+	proxyURL, err := url.Parse("http://user:pass@" + proxy.Addr().String())
+	require.NoError(t, err)
+
+	conn, err := dialThroughProxy(context.Background(), proxyURL, "query-frontend:9095")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+
+	require.Equal(t, http.MethodConnect, connectReq.Method)
+	require.Equal(t, "query-frontend:9095", connectReq.Host)
+	require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), connectReq.Header.Get("Proxy-Authorization"))
+}
+
+func TestDialThroughProxy_Refused(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	_, err = dialThroughProxy(context.Background(), proxyURL, "query-frontend:9095")
+	require.EqualError(t, err, "the proxy refused to connect to query-frontend:9095: 403 Forbidden")
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var errNoRuleGroupsToDelete = errors.New("the rule groups to delete must be selected by name with the groups field, or by labels with the selector field")
+
+// DeleteRuleGroupsRequest selects the rule groups of a namespace to delete in one request.
+type DeleteRuleGroupsRequest struct {
+	// Groups are the names of the rule groups to delete.
+	Groups []string `yaml:"groups"`
+	// Selector selects the rule groups to delete by their labels, like {team="api"}.
+	Selector string `yaml:"selector"`
+}
+
+// DeletedRuleGroups has the results of the deletion of the rule groups of a namespace.
+type DeletedRuleGroups struct {
+	Deleted []string `yaml:"deleted"`
+	// Not deleted because they don't exist.
+	NotFound []string `yaml:"not_found,omitempty"`
+	// Not deleted because of an error.
+	Failed []FailedRuleGroupDeletion `yaml:"failed,omitempty"`
+}
+
+// FailedRuleGroupDeletion has the error deleting a rule group.
+type FailedRuleGroupDeletion struct {
+	Name  string `yaml:"name"`
+	Error string `yaml:"error"`
+}
+
+// DeleteRuleGroups deletes the rule groups of the requested namespace selected by name or by labels in
+// the request body, and returns the result of the deletion of each rule group. The deletion of the other
+// rule groups goes on if a rule group can't be deleted.
+func (a *API) DeleteRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read the rule groups to delete", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The YAML format is a superset of the JSON format.
+	var request DeleteRuleGroupsRequest
+	if err := yaml.Unmarshal(payload, &request); err != nil {
+		http.Error(w, errors.Wrap(err, "unable to decode the rule groups to delete").Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Groups) == 0 && request.Selector == "" {
+		http.Error(w, errNoRuleGroupsToDelete.Error(), http.StatusBadRequest)
+		return
+	}
+
+	names := map[string]struct{}{}
+	for _, name := range request.Groups {
+		names[name] = struct{}{}
+	}
+	if request.Selector != "" {
+		matchers, err := parser.ParseMetricSelector(request.Selector)
+		if err != nil {
+			http.Error(w, errors.Wrap(err, "invalid selector").Error(), http.StatusBadRequest)
+			return
+		}
+
+		rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, namespace)
+		if err == nil {
+			err = a.store.LoadRuleGroups(req.Context(), map[string]rulespb.RuleGroupList{userID: rgs})
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "unable to load the rule groups of the namespace", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, rg := range rgs {
+			if matchesLabels(matchers, mimirpb.FromLabelAdaptersToLabels(rg.Labels)) {
+				names[rg.Name] = struct{}{}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	deleted := DeletedRuleGroups{Deleted: []string{}}
+	for _, name := range sorted {
+		err := a.deleteRuleGroup(req.Context(), logger, userID, namespace, name)
+		switch {
+		case errors.Is(err, rulestore.ErrGroupNotFound):
+			deleted.NotFound = append(deleted.NotFound, name)
+		case err != nil:
+			level.Error(logger).Log("msg", "unable to delete the rule group", "err", err.Error(), "user", userID, "group", name)
+			deleted.Failed = append(deleted.Failed, FailedRuleGroupDeletion{Name: name, Error: err.Error()})
+		default:
+			deleted.Deleted = append(deleted.Deleted, name)
+			a.notifyRulesChange(userID, namespace, name, rulesChangeActionDelete)
+		}
+	}
+
+	level.Info(logger).Log("msg", "deleted rule groups", "user", userID, "namespace", namespace, "deleted", len(deleted.Deleted), "not_found", len(deleted.NotFound), "failed", len(deleted.Failed))
+	marshalAndSend(deleted, w, logger)
+}
+
+// matchesLabels returns whether the labels match all the matchers.
+func matchesLabels(matchers []*labels.Matcher, lbls labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestAPI_DeleteRuleGroups(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	for _, g := range []struct {
+		namespace, name string
+		labels          map[string]string
+	}{
+		{"hello", "first", map[string]string{"team": "api"}},
+		{"hello", "second", map[string]string{"team": "db"}},
+		{"hello", "third", map[string]string{"team": "api", "tier": "critical"}},
+		{"hello", "fourth", nil},
+		{"world", "fifth", map[string]string{"team": "api"}},
+	} {
+		desc := rulespb.ToProto("user1", g.namespace, rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: g.name}, Labels: g.labels})
+		require.NoError(t, store.SetRuleGroup(ctx, "user1", g.namespace, desc))
+	}
+
+	a := &API{ruler: &Ruler{}, store: store, logger: log.NewNopLogger()}
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}/delete").Methods(http.MethodPost).HandlerFunc(a.DeleteRuleGroups)
+
+	deleteGroups := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/hello/delete", strings.NewReader(body), "user1"))
+		return w
+	}
+	names := func(namespace string) []string {
+		rgs, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user1", namespace)
+		require.NoError(t, err)
+		var names []string
+		for _, rg := range rgs {
+			names = append(names, rg.Name)
+		}
+		return names
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		w := deleteGroups("groups: []\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, errNoRuleGroupsToDelete.Error()+"\n", w.Body.String())
+
+		w = deleteGroups(`selector: '{team=}'`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid selector")
+
+		w = deleteGroups("groups: first\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unable to decode the rule groups to delete")
+
+		assert.ElementsMatch(t, []string{"first", "second", "third", "fourth"}, names("hello"))
+	})
+
+	t.Run("delete by selector", func(t *testing.T) {
+		w := deleteGroups(`{"selector": "{team=\"api\", tier=\"critical\"}"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var deleted DeletedRuleGroups
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &deleted))
+		assert.Equal(t, DeletedRuleGroups{Deleted: []string{"third"}}, deleted)
+
+		assert.ElementsMatch(t, []string{"first", "second", "fourth"}, names("hello"))
+	})
+
+	t.Run("delete by name and selector", func(t *testing.T) {
+		w := deleteGroups("groups: [fourth, missing]\nselector: '{team=\"api\"}'\n")
+		require.Equal(t, http.StatusOK, w.Code)
+		var deleted DeletedRuleGroups
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &deleted))
+		assert.Equal(t, DeletedRuleGroups{Deleted: []string{"first", "fourth"}, NotFound: []string{"missing"}}, deleted)
+
+		// The rule groups of the other namespaces are kept.
+		assert.Equal(t, []string{"second"}, names("hello"))
+		assert.Equal(t, []string{"fifth"}, names("world"))
+	})
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"golang.org/x/sync/semaphore"
+)
+
+const independentRulesEvaluator contextKey = 4
+
+// ruleOutputMetrics returns the names of the metrics written by the rule.
+func ruleOutputMetrics(r rules.Rule) []string {
+	if _, ok := r.(*rules.AlertingRule); ok {
+		return []string{alertMetricName, alertForStateMetricName}
+	}
+	return []string{r.Name()}
+}
+
+// ruleInputMetrics returns the names of the metrics read by the rule. It returns false if the rule may
+// read any metric, because one of its selectors doesn't select a single metric name.
+func ruleInputMetrics(r rules.Rule) ([]string, bool) {
+	var (
+		names []string
+		known = true
+	)
+	parser.Inspect(r.Query(), func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				names = append(names, m.Value)
+				return nil
+			}
+		}
+		known = false
+		return nil
+	})
+	return names, known
+}
+
+// independentRuleQueries returns the queries of the rules of the group which don't read the metrics
+// written by a preceding rule of the group. These rules can be evaluated concurrently, because their
+// result doesn't depend on the evaluation order.
+func independentRuleQueries(rs []rules.Rule) map[string]struct{} {
+	var (
+		written   = map[string]struct{}{}
+		queries   = map[string]struct{}{}
+		dependent = map[string]struct{}{}
+	)
+	for i, r := range rs {
+		qs := r.Query().String()
+
+		inputs, known := ruleInputMetrics(r)
+		independent := known || i == 0
+		for _, name := range inputs {
+			if _, ok := written[name]; ok {
+				independent = false
+			}
+		}
+
+		// A query run by several rules must be run by each of them in order if any of them is dependent.
+		if independent {
+			queries[qs] = struct{}{}
+		} else {
+			dependent[qs] = struct{}{}
+		}
+
+		for _, name := range ruleOutputMetrics(r) {
+			written[name] = struct{}{}
+		}
+	}
+
+	for qs := range dependent {
+		delete(queries, qs)
+	}
+	return queries
+}
+
+// IndependentRulesContextFunc returns a rules.ContextWrapFunc injecting in the context of the rule
+// groups with several independent rules an evaluator running their queries concurrently, limited
+// by slots. It's a no-op if slots is nil.
+func IndependentRulesContextFunc(slots *semaphore.Weighted, concurrentQueries prometheus.Counter) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		if slots == nil {
+			return ctx
+		}
+
+		queries := independentRuleQueries(g.Rules())
+		if len(queries) < 2 {
+			return ctx
+		}
+		return context.WithValue(ctx, independentRulesEvaluator, &independentRulesQuerier{
+			queries:           queries,
+			slots:             slots,
+			concurrentQueries: concurrentQueries,
+		})
+	}
+}
+
+// ConcurrentQueryFunc returns a rules.QueryFunc running the queries of the independent rules of the
+// evaluated rule group concurrently, when enabled by IndependentRulesContextFunc.
+func ConcurrentQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if q, ok := ctx.Value(independentRulesEvaluator).(*independentRulesQuerier); ok {
+			return q.query(ctx, qs, t, qf)
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+// independentRulesQuerier runs the queries of the independent rules of a rule group concurrently.
+//
+// The Prometheus rules manager evaluates the rules of a group sequentially, so the queries of all the
+// independent rules are started when the first query of an evaluation is run, and each rule gets
+// the result of its query once it's evaluated. The queries are started in the context of the group
+// evaluation rather than in the one of the first rule, each in its own span tagged with its rules.
+type independentRulesQuerier struct {
+	queries           map[string]struct{}
+	slots             *semaphore.Weighted
+	concurrentQueries prometheus.Counter
+
+	mtx sync.Mutex
+	// Evaluation timestamp of the started queries.
+	ts time.Time
+	// Results of the started queries not returned yet, by query.
+	results map[string]*concurrentQueryResult
+}
+
+type concurrentQueryResult struct {
+	done   chan struct{}
+	vector promql.Vector
+	err    error
+}
+
+func (q *independentRulesQuerier) query(ctx context.Context, qs string, t time.Time, qf rules.QueryFunc) (promql.Vector, error) {
+	if _, ok := q.queries[qs]; !ok {
+		return qf(ctx, qs, t)
+	}
+
+	q.mtx.Lock()
+	if !t.Equal(q.ts) {
+		// First query of a new evaluation of the group.
+		q.ts = t
+		q.results = q.start(ctx, qs, t, qf)
+	}
+	res, ok := q.results[qs]
+	delete(q.results, qs)
+	q.mtx.Unlock()
+
+	if !ok {
+		// The query couldn't be started concurrently, or it's run by several rules
+		// and its result has already been returned to another one.
+		return qf(ctx, qs, t)
+	}
+
+	select {
+	case <-res.done:
+		if sp := opentracing.SpanFromContext(ctx); sp != nil {
+			sp.LogKV("event", "concurrent rule query result", "query", qs)
+		}
+		return res.vector, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start runs the queries of the independent rules other than current and without an interval
+// concurrently, as long as there are free slots.
+func (q *independentRulesQuerier) start(ctx context.Context, current string, t time.Time, qf rules.QueryFunc) map[string]*concurrentQueryResult {
+	// The context of the current rule is the one of the group evaluation with the span of the rule,
+	// which the queries of the other rules must not be attributed to.
+	ctx = opentracing.ContextWithSpan(ctx, nil)
+	group, _ := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo)
+
+	results := make(map[string]*concurrentQueryResult, len(q.queries))
+	for qs := range q.queries {
+		// The queries of the rules with an interval aren't run at every evaluation.
+		if qs == current || hasRuleInterval(ctx, qs) || !q.slots.TryAcquire(1) {
+			continue
+		}
+		q.concurrentQueries.Inc()
+
+		res := &concurrentQueryResult{done: make(chan struct{})}
+		results[qs] = res
+		go func(qs string) {
+			defer q.slots.Release(1)
+			defer close(res.done)
+
+			sp, ctx := opentracing.StartSpanFromContext(ctx, "ruler.concurrent_rule_query")
+			defer sp.Finish()
+			sp.SetTag("rule", strings.Join(group.ruleNames(qs), ","))
+			res.vector, res.err = qf(ctx, qs, t)
+		}(qs)
+	}
+	return results
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestIndependentRuleQueries(t *testing.T) {
+	parse := func(expr string) parser.Expr {
+		e, err := parser.ParseExpr(expr)
+		require.NoError(t, err)
+		return e
+	}
+	recording := func(name, expr string) rules.Rule {
+		return rules.NewRecordingRule(name, parse(expr), nil)
+	}
+	alerting := func(name, expr string) rules.Rule {
+		return rules.NewAlertingRule(name, parse(expr), 0, nil, nil, nil, "", false, log.NewNopLogger())
+	}
+
+	queries := independentRuleQueries([]rules.Rule{
+		recording("job:up:sum", `sum by (job) (up)`),
+		recording("job:requests:rate5m", `sum by (job) (rate(requests_total[5m]))`),
+		// Reads the metric recorded by a preceding rule.
+		recording("up:sum", `sum(job:up:sum)`),
+		// May read any metric.
+		recording("job:metrics:count", `count by (job) ({job="test"})`),
+		alerting("JobDown", `up == 0`),
+		// Reads the ALERTS written by a preceding alerting rule.
+		alerting("ManyAlerts", `count(ALERTS) > 10`),
+		// Reads a metric recorded by a following rule.
+		recording("errors:rate5m", `sum(job:errors:rate5m)`),
+		recording("job:errors:rate5m", `sum by (job) (rate(errors_total[5m]))`),
+	})
+
+	assert.Equal(t, map[string]struct{}{
+		`sum by(job) (up)`:                       {},
+		`sum by(job) (rate(requests_total[5m]))`: {},
+		`up == 0`:                                {},
+		`sum(job:errors:rate5m)`:                 {},
+		`sum by(job) (rate(errors_total[5m]))`:   {},
+	}, queries)
+}
+
+func TestIndependentRulesQuerier(t *testing.T) {
+	const numQueries = 3
+
+	// Each query waits for all the queries to be running, so they must run concurrently.
+	running := sync.WaitGroup{}
+	running.Add(numQueries)
+	qf := func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		running.Done()
+		running.Wait()
+		return promql.Vector{{Point: promql.Point{V: float64(len(qs))}}}, nil
+	}
+
+	concurrentQueries := prometheus.NewCounter(prometheus.CounterOpts{})
+	q := &independentRulesQuerier{
+		queries:           map[string]struct{}{"a": {}, "bb": {}, "ccc": {}},
+		slots:             semaphore.NewWeighted(numQueries),
+		concurrentQueries: concurrentQueries,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ts := time.Now()
+		for _, qs := range []string{"a", "bb", "ccc"} {
+			v, err := q.query(context.Background(), qs, ts, qf)
+			require.NoError(t, err)
+			require.Equal(t, float64(len(qs)), v[0].V)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queries of the independent rules have not been run concurrently")
+	}
+	assert.Equal(t, float64(numQueries-1), testutil.ToFloat64(concurrentQueries))
+
+	// The queries not started concurrently for lack of slots are run sequentially.
+	q.slots = semaphore.NewWeighted(0)
+	qf = func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		return promql.Vector{{Point: promql.Point{V: float64(len(qs))}}}, nil
+	}
+	ts := time.Now().Add(time.Minute)
+	for _, qs := range []string{"a", "bb", "ccc", "dddd"} {
+		v, err := q.query(context.Background(), qs, ts, qf)
+		require.NoError(t, err)
+		require.Equal(t, float64(len(qs)), v[0].V)
+	}
+	assert.Equal(t, float64(numQueries-1), testutil.ToFloat64(concurrentQueries))
+}
+
+func TestIndependentRulesQuerier_Spans(t *testing.T) {
+	tracer := mocktracer.New()
+	previous := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(previous) })
+
+	var rs []rules.Rule
+	for _, r := range []struct{ name, expr string }{{"first", "up"}, {"second", "sum(up)"}} {
+		expr, err := parser.ParseExpr(r.expr)
+		require.NoError(t, err)
+		rs = append(rs, rules.NewRecordingRule(r.name, expr, nil))
+	}
+	g := rules.NewGroup(rules.GroupOptions{Name: "group-1", File: "/rules/user-1/namespace", Opts: &rules.ManagerOptions{}, Rules: rs})
+	ctx := RuleGroupContextFunc(context.Background(), g)
+
+	mtx := sync.Mutex{}
+	querySpans := map[string]*mocktracer.MockSpan{}
+	qf := func(ctx context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		querySpans[qs], _ = opentracing.SpanFromContext(ctx).(*mocktracer.MockSpan)
+		return nil, nil
+	}
+	q := &independentRulesQuerier{
+		queries:           map[string]struct{}{"up": {}, "sum(up)": {}},
+		slots:             semaphore.NewWeighted(2),
+		concurrentQueries: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+
+	ts := time.Now()
+	firstSpan := tracer.StartSpan("rule")
+	_, err := q.query(opentracing.ContextWithSpan(ctx, firstSpan), "up", ts, qf)
+	require.NoError(t, err)
+	firstSpan.Finish()
+	secondSpan := tracer.StartSpan("rule")
+	_, err = q.query(opentracing.ContextWithSpan(ctx, secondSpan), "sum(up)", ts, qf)
+	require.NoError(t, err)
+	secondSpan.Finish()
+
+	// The query of the second rule is run concurrently in its own span, not in the one of the first rule.
+	assert.Equal(t, firstSpan, querySpans["up"])
+	concurrentSpan := querySpans["sum(up)"]
+	require.NotNil(t, concurrentSpan)
+	assert.Equal(t, "ruler.concurrent_rule_query", concurrentSpan.OperationName)
+	assert.Equal(t, 0, concurrentSpan.ParentID)
+	assert.Equal(t, "second", concurrentSpan.Tag("rule"))
+	assert.Len(t, secondSpan.(*mocktracer.MockSpan).Logs(), 1)
+	assert.Empty(t, firstSpan.(*mocktracer.MockSpan).Logs())
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// ruleGraph is the dependency graph of the rules of a tenant: there is an edge from a rule to each
+// rule whose expression selects a metric written by the first one.
+type ruleGraph struct {
+	Nodes  []ruleGraphNode `json:"nodes"`
+	Edges  []ruleGraphEdge `json:"edges"`
+	Cycles [][]string      `json:"cycles"`
+}
+
+type ruleGraphNode struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+	Record    string `json:"record,omitempty"`
+	Alert     string `json:"alert,omitempty"`
+}
+
+type ruleGraphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Metric string `json:"metric"`
+}
+
+// ruleGraphNodeID returns the ID of the rule at index idx of the rule group.
+func ruleGraphNodeID(g *rulespb.RuleGroupDesc, idx int) string {
+	return g.Namespace + "/" + g.Name + "/" + strconv.Itoa(idx)
+}
+
+// newRuleGraph builds the dependency graph of the rules of the rule groups. The rules whose
+// expression can't be parsed don't depend on any rule.
+func newRuleGraph(rgs rulespb.RuleGroupList) *ruleGraph {
+	type producer struct {
+		id     string
+		metric string
+	}
+
+	graph := &ruleGraph{Nodes: []ruleGraphNode{}, Edges: []ruleGraphEdge{}, Cycles: [][]string{}}
+	var producers []producer
+	for _, g := range rgs {
+		for i, r := range g.Rules {
+			id := ruleGraphNodeID(g, i)
+			graph.Nodes = append(graph.Nodes, ruleGraphNode{ID: id, Namespace: g.Namespace, Group: g.Name, Record: r.Record, Alert: r.Alert})
+
+			if r.Alert != "" {
+				producers = append(producers, producer{id: id, metric: alertMetricName}, producer{id: id, metric: alertForStateMetricName})
+			} else {
+				producers = append(producers, producer{id: id, metric: r.Record})
+			}
+		}
+	}
+
+	for _, g := range rgs {
+		for i, r := range g.Rules {
+			expr, err := parser.ParseExpr(r.Expr)
+			if err != nil {
+				continue
+			}
+			id := ruleGraphNodeID(g, i)
+			for _, p := range producers {
+				if exprSelectsMetric(expr, p.metric) {
+					graph.Edges = append(graph.Edges, ruleGraphEdge{From: p.id, To: id, Metric: p.metric})
+				}
+			}
+		}
+	}
+
+	graph.Cycles = graph.findCycles()
+	return graph
+}
+
+// findCycles returns the sets of rules depending on each other, directly or not, as the strongly
+// connected components of the graph with more than one rule or with a rule depending on itself.
+func (g *ruleGraph) findCycles() [][]string {
+	successors := map[string][]string{}
+	selfLoops := map[string]bool{}
+	for _, e := range g.Edges {
+		successors[e.From] = append(successors[e.From], e.To)
+		if e.From == e.To {
+			selfLoops[e.From] = true
+		}
+	}
+
+	// Tarjan's strongly connected components algorithm.
+	var (
+		index   = 0
+		indexes = map[string]int{}
+		lowlink = map[string]int{}
+		onStack = map[string]bool{}
+		stack   []string
+		cycles  = [][]string{}
+	)
+	var visit func(id string)
+	visit = func(id string) {
+		indexes[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, next := range successors[id] {
+			if _, ok := indexes[next]; !ok {
+				visit(next)
+				if lowlink[next] < lowlink[id] {
+					lowlink[id] = lowlink[next]
+				}
+			} else if onStack[next] && indexes[next] < lowlink[id] {
+				lowlink[id] = indexes[next]
+			}
+		}
+
+		if lowlink[id] != indexes[id] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoops[id] {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if _, ok := indexes[n.ID]; !ok {
+			visit(n.ID)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// writeDOT writes the graph in the DOT format of Graphviz. The rules part of a cycle are colored in red.
+func (g *ruleGraph) writeDOT(w io.Writer) error {
+	inCycle := map[string]bool{}
+	for _, c := range g.Cycles {
+		for _, id := range c {
+			inCycle[id] = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph rules {\n")
+	for _, n := range g.Nodes {
+		name, shape := n.Record, "box"
+		if n.Alert != "" {
+			name, shape = n.Alert, "ellipse"
+		}
+		attrs := fmt.Sprintf("label=%s, shape=%s", strconv.Quote(name+"\n"+n.Namespace+"/"+n.Group), shape)
+		if inCycle[n.ID] {
+			attrs += ", color=red"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", strconv.Quote(n.ID), attrs)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Metric))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cyclePath returns a path going from the start rule back to it through the rules of the cycle.
+func (g *ruleGraph) cyclePath(start string, cycle []string) []string {
+	inCycle := make(map[string]bool, len(cycle))
+	for _, id := range cycle {
+		inCycle[id] = true
+	}
+	successors := map[string][]string{}
+	for _, e := range g.Edges {
+		if inCycle[e.From] && inCycle[e.To] {
+			successors[e.From] = append(successors[e.From], e.To)
+		}
+	}
+
+	// Breadth-first search of the shortest path back to the start rule.
+	parents := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range successors[id] {
+			if next == start {
+				path := []string{start}
+				for n := id; n != start; n = parents[n] {
+					path = append(path, n)
+				}
+				path = append(path, start)
+				// The path has been built backwards.
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, ok := parents[next]; !ok {
+				parents[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// findRecordingRuleCycle returns an error describing the cycle among the recording rules of the tenant that
+// the rule group would introduce if stored in the namespace, replacing the existing rule group with the same
+// name, or nil if there is none.
+func findRecordingRuleCycle(namespace string, group rulefmt.RuleGroup, existing rulespb.RuleGroupList) error {
+	recordingRules := func(g *rulespb.RuleGroupDesc) *rulespb.RuleGroupDesc {
+		filtered := &rulespb.RuleGroupDesc{Namespace: g.Namespace, Name: g.Name}
+		for _, r := range g.Rules {
+			if r.Record != "" {
+				filtered.Rules = append(filtered.Rules, r)
+			}
+		}
+		return filtered
+	}
+
+	created := &rulespb.RuleGroupDesc{Namespace: namespace, Name: group.Name}
+	for _, r := range group.Rules {
+		created.Rules = append(created.Rules, &rulespb.RuleDesc{Record: r.Record.Value, Alert: r.Alert.Value, Expr: r.Expr.Value})
+	}
+	rgs := rulespb.RuleGroupList{recordingRules(created)}
+	for _, g := range existing {
+		if g.GetNamespace() == namespace && g.GetName() == group.Name {
+			continue
+		}
+		rgs = append(rgs, recordingRules(g))
+	}
+
+	graph := newRuleGraph(rgs)
+	nodes := make(map[string]ruleGraphNode, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodes[n.ID] = n
+	}
+
+	// Only the cycles including a rule of the created rule group are introduced by it.
+	for _, c := range graph.Cycles {
+		for _, id := range c {
+			if n := nodes[id]; n.Namespace != namespace || n.Group != group.Name {
+				continue
+			}
+
+			path := graph.cyclePath(id, c)
+			steps := make([]string, 0, len(path))
+			for _, p := range path {
+				n := nodes[p]
+				steps = append(steps, fmt.Sprintf("%q (namespace %q, group %q)", n.Record, n.Namespace, n.Group))
+			}
+			return fmt.Errorf("%w: %s", ErrRecordingRuleCycle, strings.Join(steps, " -> "))
+		}
+	}
+	return nil
+}
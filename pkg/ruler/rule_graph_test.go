@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestNewRuleGraph(t *testing.T) {
+	rgs := rulespb.RuleGroupList{
+		{Namespace: "namespace", Name: "chain", Rules: []*rulespb.RuleDesc{
+			{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+			{Record: "requests:rate5m", Expr: "sum(job:requests:rate5m)"},
+			{Alert: "TooManyRequests", Expr: "requests:rate5m > 100"},
+			{Alert: "ManyAlerts", Expr: "count(ALERTS) > 10"},
+		}},
+		{Namespace: "namespace", Name: "cycle", Rules: []*rulespb.RuleDesc{
+			{Record: "a", Expr: "b"},
+			{Record: "b", Expr: `{__name__=~"a|c"}`},
+			{Record: "c", Expr: "c + 1"},
+			{Record: "invalid", Expr: "sum("},
+		}},
+	}
+
+	graph := newRuleGraph(rgs)
+
+	assert.Len(t, graph.Nodes, 8)
+	assert.Equal(t, ruleGraphNode{ID: "namespace/chain/2", Namespace: "namespace", Group: "chain", Alert: "TooManyRequests"}, graph.Nodes[2])
+	assert.ElementsMatch(t, []ruleGraphEdge{
+		{From: "namespace/chain/0", To: "namespace/chain/1", Metric: "job:requests:rate5m"},
+		{From: "namespace/chain/1", To: "namespace/chain/2", Metric: "requests:rate5m"},
+		{From: "namespace/chain/2", To: "namespace/chain/3", Metric: "ALERTS"},
+		{From: "namespace/chain/3", To: "namespace/chain/3", Metric: "ALERTS"},
+		{From: "namespace/cycle/1", To: "namespace/cycle/0", Metric: "b"},
+		{From: "namespace/cycle/0", To: "namespace/cycle/1", Metric: "a"},
+		{From: "namespace/cycle/2", To: "namespace/cycle/1", Metric: "c"},
+		{From: "namespace/cycle/2", To: "namespace/cycle/2", Metric: "c"},
+	}, graph.Edges)
+	assert.Equal(t, [][]string{
+		{"namespace/chain/3"},
+		{"namespace/cycle/0", "namespace/cycle/1"},
+		{"namespace/cycle/2"},
+	}, graph.Cycles)
+}
+
+func TestRuleGraph_WriteDOT(t *testing.T) {
+	graph := newRuleGraph(rulespb.RuleGroupList{
+		{Namespace: "namespace", Name: "group", Rules: []*rulespb.RuleDesc{
+			{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			{Alert: "Down", Expr: "job:up:sum == 0"},
+			{Record: "loop", Expr: "loop"},
+		}},
+	})
+
+	var b strings.Builder
+	require.NoError(t, graph.writeDOT(&b))
+	assert.Equal(t, `digraph rules {
+  "namespace/group/0" [label="job:up:sum\nnamespace/group", shape=box];
+  "namespace/group/1" [label="Down\nnamespace/group", shape=ellipse];
+  "namespace/group/2" [label="loop\nnamespace/group", shape=box, color=red];
+  "namespace/group/0" -> "namespace/group/1" [label="job:up:sum"];
+  "namespace/group/2" -> "namespace/group/2" [label="loop"];
+}
+`, b.String())
+}
+
+func TestRuleGraph_CyclePath(t *testing.T) {
+	graph := newRuleGraph(rulespb.RuleGroupList{
+		{Namespace: "namespace", Name: "group", Rules: []*rulespb.RuleDesc{
+			{Record: "a", Expr: "c"},
+			{Record: "b", Expr: "a"},
+			{Record: "c", Expr: "b or a"},
+		}},
+	})
+	require.Len(t, graph.Cycles, 1)
+
+	// The shortest path is taken, skipping b.
+	assert.Equal(t, []string{"namespace/group/0", "namespace/group/2", "namespace/group/0"}, graph.cyclePath("namespace/group/0", graph.Cycles[0]))
+	assert.Equal(t, []string{"namespace/group/1", "namespace/group/2", "namespace/group/0", "namespace/group/1"}, graph.cyclePath("namespace/group/1", graph.Cycles[0]))
+}
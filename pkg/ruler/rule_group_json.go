@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const jsonContentType = "application/json"
+
+// isJSONContentType returns whether the body of the request is in the JSON format.
+func isJSONContentType(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == jsonContentType
+}
+
+// acceptsJSON returns whether the response to the request is requested in the JSON format rather than in
+// the YAML format, with the Accept header.
+func acceptsJSON(req *http.Request) bool {
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case jsonContentType:
+			return true
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return false
+		}
+	}
+	return false
+}
+
+// jsonToYAML converts a document in the JSON format to the YAML format, keeping the order of the keys of
+// the objects.
+func jsonToYAML(in []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+	node, err := jsonNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected content after the JSON document")
+	}
+	return yaml.Marshal(node)
+}
+
+// jsonNode returns the YAML node of the next JSON value read from the decoder.
+func jsonNode(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if v == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+			value, err := jsonNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		// The closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}, nil
+	case bool:
+		value := "false"
+		if v {
+			value = "true"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// yamlToJSON converts a document in the YAML format to the JSON format, keeping the order of the keys of the
+// mappings. The aliases and merge keys are expanded.
+func yamlToJSON(in []byte) ([]byte, error) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 {
+		return nil, errors.New("not a single YAML document")
+	}
+	node, err := rulespb.ExpandAliases(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	if err := writeJSON(&buf, node); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		out, err := jsonScalar(node)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	}
+	return nil
+}
+
+// jsonScalar returns the JSON value of the YAML scalar node. The scalars which aren't numbers, booleans nor
+// null, like the durations and the timestamps, are strings.
+func jsonScalar(node *yaml.Node) ([]byte, error) {
+	switch node.ShortTag() {
+	case "!!int", "!!float", "!!bool", "!!null":
+		var v interface{}
+		if err := node.Decode(&v); err == nil {
+			// The values not representable in JSON, like the infinite floats, are strings.
+			if out, err := json.Marshal(v); err == nil {
+				return out, nil
+			}
+		}
+	}
+	return json.Marshal(node.Value)
+}
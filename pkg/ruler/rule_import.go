@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/go-jsonnet"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	// ruleImportFormatJsonnet is the format of the imports of rule groups evaluated from a Jsonnet bundle.
+	ruleImportFormatJsonnet = "jsonnet"
+
+	// jsonnetBundleMainFile is the name of the main file of a Jsonnet bundle, in the error messages.
+	jsonnetBundleMainFile = "main.jsonnet"
+)
+
+var (
+	errInvalidRuleImportFormat   = errors.New("invalid format parameter, supported values are: " + ruleImportFormatJsonnet + ", " + ruleImportFormatPrometheusRule)
+	errJsonnetImportDisabled     = errors.New("the Jsonnet import of rule groups is disabled")
+	errNoImportedRuleGroups      = errors.New("the import contains no rule groups")
+	errTooManyJsonnetEvaluations = errors.New("too many concurrent evaluations of Jsonnet bundles, try again later")
+
+	errInvalidJsonnetImportMaxBundleSize            = errors.New("invalid ruler Jsonnet import max bundle size, must be greater than zero")
+	errInvalidJsonnetImportMaxOutputSize            = errors.New("invalid ruler Jsonnet import max output size, must be greater than zero")
+	errInvalidJsonnetImportMaxStackDepth            = errors.New("invalid ruler Jsonnet import max stack depth, must be greater than zero")
+	errInvalidJsonnetImportEvaluationTimeout        = errors.New("invalid ruler Jsonnet import evaluation timeout, must be greater than zero")
+	errInvalidJsonnetImportMaxConcurrentEvaluations = errors.New("invalid ruler Jsonnet import max concurrent evaluations, must be greater than zero")
+)
+
+type JsonnetImportConfig struct {
+	Enabled                  bool          `yaml:"enabled"`
+	MaxBundleSizeBytes       int           `yaml:"max_bundle_size_bytes"`
+	MaxFiles                 int           `yaml:"max_files"`
+	MaxStackDepth            int           `yaml:"max_stack_depth"`
+	MaxOutputSizeBytes       int           `yaml:"max_output_size_bytes"`
+	EvaluationTimeout        time.Duration `yaml:"evaluation_timeout"`
+	MaxConcurrentEvaluations int           `yaml:"max_concurrent_evaluations"`
+}
+
+func (cfg *JsonnetImportConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.jsonnet-import.enabled", false, "Enable the import of rule groups evaluated by the ruler from a Jsonnet bundle, through the configuration API. The bundle can only import its own files. The bundles are evaluated in the ruler process without limiting their memory usage: a bundle can exhaust the memory of the ruler and crash it, so only enable the import for trusted tenants.")
+	f.IntVar(&cfg.MaxBundleSizeBytes, "ruler.jsonnet-import.max-bundle-size-bytes", 1024*1024, "Maximum size of a Jsonnet bundle, including all its files.")
+	f.IntVar(&cfg.MaxFiles, "ruler.jsonnet-import.max-files", 100, "Maximum number of files that a Jsonnet bundle can contain in addition to its main file.")
+	f.IntVar(&cfg.MaxStackDepth, "ruler.jsonnet-import.max-stack-depth", 500, "Maximum depth of the stack of the evaluation of a Jsonnet bundle, which bounds its recursion.")
+	f.IntVar(&cfg.MaxOutputSizeBytes, "ruler.jsonnet-import.max-output-size-bytes", 10*1024*1024, "Maximum size of the rule file a Jsonnet bundle evaluates to. The imports of the larger rule files are rejected before the rule file is parsed.")
+	f.DurationVar(&cfg.EvaluationTimeout, "ruler.jsonnet-import.evaluation-timeout", 10*time.Second, "Maximum duration of the evaluation of a Jsonnet bundle. The evaluation can't be interrupted: the import fails once the timeout is exceeded, but the evaluation goes on until it ends, counting towards -ruler.jsonnet-import.max-concurrent-evaluations.")
+	f.IntVar(&cfg.MaxConcurrentEvaluations, "ruler.jsonnet-import.max-concurrent-evaluations", 4, "Maximum number of Jsonnet bundles evaluated concurrently by a ruler. The imports exceeding it are rejected with 429 status code.")
+}
+
+func (cfg *JsonnetImportConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxBundleSizeBytes <= 0 {
+		return errInvalidJsonnetImportMaxBundleSize
+	}
+	if cfg.MaxStackDepth <= 0 {
+		return errInvalidJsonnetImportMaxStackDepth
+	}
+	if cfg.MaxOutputSizeBytes <= 0 {
+		return errInvalidJsonnetImportMaxOutputSize
+	}
+	if cfg.EvaluationTimeout <= 0 {
+		return errInvalidJsonnetImportEvaluationTimeout
+	}
+	if cfg.MaxConcurrentEvaluations <= 0 {
+		return errInvalidJsonnetImportMaxConcurrentEvaluations
+	}
+	return nil
+}
+
+// jsonnetBundle is the request body of the Jsonnet imports: the main file evaluated into a rule file,
+// and the files it can import, by their import path.
+type jsonnetBundle struct {
+	Main  string            `json:"main"`
+	Files map[string]string `json:"files"`
+}
+
+// ImportRuleGroups creates or updates the rule groups of a namespace evaluated from the request body, in the
+// format set by the format parameter. The rule groups are validated as a whole before any of them is stored.
+func (a *API) ImportRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	var groups []rulespb.RuleGroup
+	switch req.URL.Query().Get("format") {
+	case ruleImportFormatJsonnet:
+		if !a.ruler.cfg.JsonnetImport.Enabled {
+			http.Error(w, errJsonnetImportDisabled.Error(), http.StatusForbidden)
+			return
+		}
+		groups, err = evaluateJsonnetBundle(req.Body, a.ruler.cfg.JsonnetImport, a.ruler.jsonnetEvaluations)
+		if errors.Is(err, errTooManyJsonnetEvaluations) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+	case ruleImportFormatPrometheusRule:
+		groups, err = decodePrometheusRuleGroups(req.Body)
+	default:
+		err = errInvalidRuleImportFormat
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to import rule groups", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.storeImportedRuleGroups(w, req, userID, namespace, groups)
+}
+
+// storeImportedRuleGroups validates and stores the rule groups imported into the namespace.
+func (a *API) storeImportedRuleGroups(w http.ResponseWriter, req *http.Request, userID, namespace string, groups []rulespb.RuleGroup) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	if len(groups) == 0 {
+		http.Error(w, errNoImportedRuleGroups.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.store.LoadRuleGroups(req.Context(), map[string]rulespb.RuleGroupList{userID: rgs}); err != nil {
+		level.Error(logger).Log("msg", "unable to load current rule groups for validation", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Each rule group is validated against the current rule groups of the tenant, updated with
+	// the imported rule groups validated before it.
+	var (
+		warnings []string
+		imported = make(rulespb.RuleGroupList, 0, len(groups))
+		names    = make(map[string]struct{}, len(groups))
+	)
+	for _, rg := range groups {
+		if _, ok := names[rg.Name]; ok {
+			http.Error(w, fmt.Sprintf("the import contains several rule groups named %s", rg.Name), http.StatusBadRequest)
+			return
+		}
+		names[rg.Name] = struct{}{}
+
+		rg, err = expandRuleGroupTemplate(rg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		groupWarnings, err := a.validateRuleGroup(req.Context(), logger, userID, namespace, rg, rgs)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("rule group %s: %s", rg.Name, err), http.StatusBadRequest)
+			return
+		}
+		warnings = append(warnings, groupWarnings...)
+
+		rgProto := rulespb.ToProto(userID, namespace, rg)
+		imported = append(imported, rgProto)
+		rgs = replaceRuleGroup(rgs, rgProto)
+	}
+
+	now := time.Now()
+	for _, rgProto := range imported {
+		rgProto.UpdatedAt = &now
+		if err := a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto); err != nil {
+			level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
+			http.Error(w, err.Error(), setRuleGroupErrorStatus(err))
+			return
+		}
+		a.notifyRulesChange(userID, namespace, rgProto.Name, rulesChangeActionCreate)
+	}
+
+	level.Info(logger).Log("msg", "imported rule groups", "user", userID, "namespace", namespace, "groups", len(imported))
+	respondAccepted(w, logger, nil, warnings)
+}
+
+// replaceRuleGroup returns the rule groups with the one of the same namespace and name replaced by rg, or rg appended.
+func replaceRuleGroup(rgs rulespb.RuleGroupList, rg *rulespb.RuleGroupDesc) rulespb.RuleGroupList {
+	for i, g := range rgs {
+		if g.GetNamespace() == rg.GetNamespace() && g.GetName() == rg.GetName() {
+			rgs[i] = rg
+			return rgs
+		}
+	}
+	return append(rgs, rg)
+}
+
+// evaluateJsonnetBundle evaluates the Jsonnet bundle read from r into the rule groups of a rule file.
+// The evaluation is sandboxed: the bundle can only import its own files, and its stack depth, duration and
+// output size are limited. It runs in one of the slots, and is rejected if there's no free slot. Its memory
+// usage isn't limited though, nor the stack used by the tail calls, so a bundle can still crash the ruler.
+func evaluateJsonnetBundle(r io.Reader, cfg JsonnetImportConfig, slots *semaphore.Weighted) ([]rulespb.RuleGroup, error) {
+	payload, err := ioutil.ReadAll(io.LimitReader(r, int64(cfg.MaxBundleSizeBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > cfg.MaxBundleSizeBytes {
+		return nil, fmt.Errorf("the Jsonnet bundle exceeds the maximum size of %d bytes", cfg.MaxBundleSizeBytes)
+	}
+
+	var bundle jsonnetBundle
+	if err := json.Unmarshal(payload, &bundle); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal the Jsonnet bundle")
+	}
+	if len(bundle.Files) > cfg.MaxFiles {
+		return nil, fmt.Errorf("the Jsonnet bundle has %d files, exceeding the maximum of %d", len(bundle.Files), cfg.MaxFiles)
+	}
+
+	files := make(map[string]jsonnet.Contents, len(bundle.Files))
+	for path, content := range bundle.Files {
+		files[path] = jsonnet.MakeContents(content)
+	}
+	vm := jsonnet.MakeVM()
+	vm.MaxStack = cfg.MaxStackDepth
+	vm.Importer(&jsonnet.MemoryImporter{Data: files})
+
+	out, err := evaluateJsonnetSnippet(vm, bundle.Main, cfg.EvaluationTimeout, slots)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > cfg.MaxOutputSizeBytes {
+		return nil, fmt.Errorf("the Jsonnet bundle evaluates to %d bytes, exceeding the maximum of %d bytes", len(out), cfg.MaxOutputSizeBytes)
+	}
+
+	// The JSON output is valid YAML, which is unmarshaled as the rule groups submitted to the CreateRuleGroup endpoint.
+	var ruleFile struct {
+		Groups []rulespb.RuleGroup `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &ruleFile); err != nil {
+		return nil, errors.Wrap(err, "the Jsonnet bundle doesn't evaluate to a rule file")
+	}
+	return ruleFile.Groups, nil
+}
+
+// evaluateJsonnetSnippet evaluates the main file of a Jsonnet bundle in one of the slots, for up to the timeout.
+// The Jsonnet evaluation can't be interrupted, so an evaluation exceeding the timeout is abandoned but keeps
+// its slot until it ends, so that the abandoned evaluations can't use more than the slots.
+func evaluateJsonnetSnippet(vm *jsonnet.VM, snippet string, timeout time.Duration, slots *semaphore.Weighted) (string, error) {
+	if !slots.TryAcquire(1) {
+		return "", errTooManyJsonnetEvaluations
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer slots.Release(1)
+		out, err := vm.EvaluateAnonymousSnippet(jsonnetBundleMainFile, snippet)
+		done <- result{out: out, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return "", errors.Wrap(res.err, "unable to evaluate the Jsonnet bundle")
+		}
+		return res.out, nil
+	case <-timer.C:
+		return "", fmt.Errorf("the evaluation of the Jsonnet bundle exceeded the timeout of %s", timeout)
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestEvaluateJsonnetBundle(t *testing.T) {
+	cfg := JsonnetImportConfig{Enabled: true, MaxBundleSizeBytes: 4096, MaxFiles: 1, MaxStackDepth: 500, MaxOutputSizeBytes: 4096, EvaluationTimeout: 10 * time.Second, MaxConcurrentEvaluations: 1}
+
+	tests := map[string]struct {
+		bundle         jsonnetBundle
+		cfg            JsonnetImportConfig
+		noFreeSlot     bool
+		expectedGroups map[string][]string
+		expectedErr    string
+	}{
+		"bundle with an imported library": {
+			bundle: jsonnetBundle{
+				Main: `
+local lib = import 'lib/rules.libsonnet';
+{
+  groups: [
+    lib.group('api', 'api'),
+    lib.group('db', 'db'),
+  ],
+}`,
+				Files: map[string]string{
+					"lib/rules.libsonnet": `{
+  group(name, job):: {
+    name: name,
+    rules: [{ record: job + ':up:sum', expr: 'sum(up{job="%s"})' % job }],
+  },
+}`,
+				},
+			},
+			cfg: cfg,
+			expectedGroups: map[string][]string{
+				"api": {"api:up:sum"},
+				"db":  {"db:up:sum"},
+			},
+		},
+		"import of a file not in the bundle": {
+			bundle: jsonnetBundle{
+				Main: `import '/etc/passwd'`,
+			},
+			cfg:         cfg,
+			expectedErr: "unable to evaluate the Jsonnet bundle",
+		},
+		"invalid Jsonnet": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: [ }`,
+			},
+			cfg:         cfg,
+			expectedErr: "unable to evaluate the Jsonnet bundle",
+		},
+		"output which isn't a rule file": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: 'invalid' }`,
+			},
+			cfg:         cfg,
+			expectedErr: "the Jsonnet bundle doesn't evaluate to a rule file",
+		},
+		"too many files": {
+			bundle: jsonnetBundle{
+				Main:  `{ groups: [] }`,
+				Files: map[string]string{"a.libsonnet": "{}", "b.libsonnet": "{}"},
+			},
+			cfg:         cfg,
+			expectedErr: "the Jsonnet bundle has 2 files, exceeding the maximum of 1",
+		},
+		"bundle too large": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: [] }`,
+			},
+			cfg:         JsonnetImportConfig{Enabled: true, MaxBundleSizeBytes: 10, MaxFiles: 1, MaxStackDepth: 500, MaxOutputSizeBytes: 4096, EvaluationTimeout: 10 * time.Second, MaxConcurrentEvaluations: 1},
+			expectedErr: "the Jsonnet bundle exceeds the maximum size of 10 bytes",
+		},
+		"unbounded recursion": {
+			bundle: jsonnetBundle{
+				Main: `local f(n) = f(n + 1) + 1; { groups: f(0) }`,
+			},
+			cfg:         cfg,
+			expectedErr: "max stack frames exceeded",
+		},
+		"output exceeding the max size": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: [{ name: "group-" + i, rules: [{ record: "rule", expr: "up" }] } for i in std.range(1, 1000)] }`,
+			},
+			cfg:         cfg,
+			expectedErr: "exceeding the maximum of 4096 bytes",
+		},
+		"evaluation exceeding the timeout": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: [], n: std.length([x for x in std.range(1, 20000) if x % 7 == 0]) }`,
+			},
+			cfg:         JsonnetImportConfig{Enabled: true, MaxBundleSizeBytes: 4096, MaxFiles: 1, MaxStackDepth: 500, MaxOutputSizeBytes: 4096, EvaluationTimeout: time.Nanosecond, MaxConcurrentEvaluations: 1},
+			expectedErr: "the evaluation of the Jsonnet bundle exceeded the timeout of 1ns",
+		},
+		"no free evaluation slot": {
+			bundle: jsonnetBundle{
+				Main: `{ groups: [] }`,
+			},
+			cfg:         cfg,
+			noFreeSlot:  true,
+			expectedErr: errTooManyJsonnetEvaluations.Error(),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			payload, err := json.Marshal(tc.bundle)
+			require.NoError(t, err)
+
+			slots := semaphore.NewWeighted(1)
+			if tc.noFreeSlot {
+				require.True(t, slots.TryAcquire(1))
+			}
+			groups, err := evaluateJsonnetBundle(strings.NewReader(string(payload)), tc.cfg, slots)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actual := map[string][]string{}
+			for _, g := range groups {
+				for _, r := range g.Rules {
+					actual[g.Name] = append(actual[g.Name], r.Record.Value)
+				}
+			}
+			assert.Equal(t, tc.expectedGroups, actual)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const ruleIntervalsKey contextKey = 8
+
+// validateRuleIntervals validates the intervals of the rules of rg, given the interval of rg.
+func validateRuleIntervals(rg rulespb.RuleGroup, groupInterval time.Duration) error {
+	for i, interval := range rg.RuleIntervals {
+		if interval != 0 && time.Duration(interval) <= groupInterval {
+			return errors.Errorf("invalid interval %s of rule %d of group %s, must be longer than the interval %s of the group", interval, i+1, rg.Name, groupInterval)
+		}
+	}
+	return nil
+}
+
+// RuleIntervalsContextFunc injects in the context of each rule group the state used by
+// RuleIntervalsQueryFunc to evaluate the rules with an interval less often than their group.
+func RuleIntervalsContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	queries := make([]string, 0, len(g.Rules()))
+	recording := make([]bool, 0, len(g.Rules()))
+	for _, r := range g.Rules() {
+		_, ok := r.(*rules.RecordingRule)
+		queries = append(queries, r.Query().String())
+		recording = append(recording, ok)
+	}
+	return context.WithValue(ctx, ruleIntervalsKey, &ruleIntervalsState{
+		groupInterval: g.Interval(),
+		queries:       queries,
+		recording:     recording,
+		rule:          -1,
+		results:       make([]heldRuleResult, len(queries)),
+	})
+}
+
+// RuleIntervalsQueryFunc returns a rules.QueryFunc which runs the query of a rule with an interval
+// only at the first evaluation of its group at least one interval after its previous query. At the
+// other evaluations of the group, the previous result of the rule is returned, with its original
+// timestamps, so that the state of the alerts of an alerting rule is kept, and the samples of a
+// recording rule are discarded by RuleIntervalsAppendable rather than written again.
+func RuleIntervalsQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		s, ok := ctx.Value(ruleIntervalsKey).(*ruleIntervalsState)
+		if !ok {
+			return qf(ctx, qs, t)
+		}
+		rule, ok := s.evaluatedRule(ctx, qs, t)
+		if !ok {
+			return qf(ctx, qs, t)
+		}
+		// The rule intervals are looked up in the rule group as stored in the rule store, because
+		// they aren't part of the rule files loaded by the Prometheus rules manager.
+		interval := s.interval(evaluatedRuleGroupDesc(ctx), rule)
+		if interval <= s.groupInterval {
+			return qf(ctx, qs, t)
+		}
+
+		if vector, ok := s.held(ctx, rule, t, interval); ok {
+			return vector, nil
+		}
+		vector, err := qf(ctx, qs, t)
+		if err == nil {
+			s.hold(rule, t, vector)
+		}
+		return vector, err
+	}
+}
+
+// RuleIntervalsAppendable returns a storage.Appendable which discards the samples of the recording
+// rules whose previous result is returned by RuleIntervalsQueryFunc, because they've already been
+// written, and appends the others to appendable.
+func RuleIntervalsAppendable(appendable storage.Appendable) storage.Appendable {
+	return &ruleIntervalsAppendable{appendable: appendable}
+}
+
+type ruleIntervalsAppendable struct {
+	appendable storage.Appendable
+}
+
+func (a *ruleIntervalsAppendable) Appender(ctx context.Context) storage.Appender {
+	if s, ok := ctx.Value(ruleIntervalsKey).(*ruleIntervalsState); ok && s.heldRecordingRule(ctx) {
+		return &discardingAppender{}
+	}
+	return a.appendable.Appender(ctx)
+}
+
+// hasRuleInterval returns whether the query qs is evaluated by a rule with an interval in the rule
+// group evaluated with ctx.
+func hasRuleInterval(ctx context.Context, qs string) bool {
+	s, ok := ctx.Value(ruleIntervalsKey).(*ruleIntervalsState)
+	if !ok {
+		return false
+	}
+	desc := evaluatedRuleGroupDesc(ctx)
+	for i, q := range s.queries {
+		if q == qs && s.interval(desc, i) > s.groupInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleIntervalsState tracks the rule being evaluated in a rule group, and the previous results of
+// the rules with an interval.
+//
+// The Prometheus rules manager evaluates the rules of a group one at a time, in order, each with its
+// own context, and a rule runs its query before the queries of its templates, with the same context.
+// So the rule running a query is the next rule of the group evaluating this query if the context of
+// the query isn't the one of the rule evaluated last.
+type ruleIntervalsState struct {
+	groupInterval time.Duration
+	// The queries of the rules of the group, and whether they're recording rules, by index of the rule.
+	queries   []string
+	recording []bool
+
+	mtx sync.Mutex
+	// Context, index and query timestamp of the rule evaluated last, and whether its previous result
+	// was returned. The index is -1 if the rule is unknown.
+	ruleCtx  context.Context
+	rule     int
+	ruleTime time.Time
+	ruleHeld bool
+	// The previous results of the rules, by index of the rule.
+	results []heldRuleResult
+}
+
+type heldRuleResult struct {
+	ts     time.Time
+	vector promql.Vector
+}
+
+// evaluatedRule returns the index of the rule running the query qs with ctx at t. It returns false if
+// the query is run by the templates of the rule evaluated last, or if the rule is unknown.
+func (s *ruleIntervalsState) evaluatedRule(ctx context.Context, qs string, t time.Time) (int, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if ctx == s.ruleCtx {
+		return 0, false
+	}
+
+	// The rules of an evaluation of the group run their queries at the same timestamp.
+	next := 0
+	if s.rule >= 0 && t.Equal(s.ruleTime) {
+		next = s.rule + 1
+	}
+	s.ruleCtx = ctx
+	s.ruleTime = t
+	s.ruleHeld = false
+	s.rule = -1
+	for i := next; i < len(s.queries); i++ {
+		if s.queries[i] == qs {
+			s.rule = i
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// interval returns the interval of the rule of the group at index rule, 0 if it doesn't have one.
+func (s *ruleIntervalsState) interval(desc *rulespb.RuleGroupDesc, rule int) time.Duration {
+	if desc == nil || len(desc.Rules) != len(s.queries) {
+		return 0
+	}
+	return desc.Rules[rule].Interval
+}
+
+// held returns a copy of the previous result of the rule if it was evaluated less than interval before t.
+func (s *ruleIntervalsState) held(ctx context.Context, rule int, t time.Time, interval time.Duration) (promql.Vector, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	res := s.results[rule]
+	if res.vector == nil || !t.Before(res.ts.Add(interval)) || t.Before(res.ts) {
+		return nil, false
+	}
+	if ctx == s.ruleCtx {
+		s.ruleHeld = true
+	}
+	// The rules override the labels of the returned samples.
+	vector := make(promql.Vector, len(res.vector))
+	copy(vector, res.vector)
+	return vector, true
+}
+
+func (s *ruleIntervalsState) hold(rule int, t time.Time, vector promql.Vector) {
+	held := make(promql.Vector, len(vector))
+	copy(held, vector)
+
+	s.mtx.Lock()
+	s.results[rule] = heldRuleResult{ts: t, vector: held}
+	s.mtx.Unlock()
+}
+
+// heldRecordingRule returns whether ctx is the context of a recording rule whose previous result was
+// returned at its latest evaluation.
+func (s *ruleIntervalsState) heldRecordingRule(ctx context.Context) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return ctx == s.ruleCtx && s.ruleHeld && s.recording[s.rule]
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestValidateRuleIntervals(t *testing.T) {
+	for name, tc := range map[string]struct {
+		intervals []model.Duration
+		expectErr bool
+	}{
+		"no intervals":                        {},
+		"rules without interval":              {intervals: []model.Duration{0, 0}},
+		"interval longer than the group":      {intervals: []model.Duration{0, model.Duration(5 * time.Minute)}},
+		"interval equal to the group":         {intervals: []model.Duration{model.Duration(time.Minute), 0}, expectErr: true},
+		"interval shorter than the group":     {intervals: []model.Duration{model.Duration(30 * time.Second), 0}, expectErr: true},
+		"interval of the last rule too short": {intervals: []model.Duration{model.Duration(5 * time.Minute), model.Duration(time.Second)}, expectErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := validateRuleIntervals(rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}, RuleIntervals: tc.intervals}, time.Minute)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// recordedSamples is a storage.Appendable recording the timestamps of the committed samples, by metric name.
+type recordedSamples struct {
+	mtx     sync.Mutex
+	samples map[string][]int64
+}
+
+func (r *recordedSamples) Appender(context.Context) storage.Appender {
+	return &recordedSamplesAppender{recorded: r}
+}
+
+type recordedSamplesAppender struct {
+	discardingAppender
+	recorded *recordedSamples
+	pending  []labels.Labels
+	ts       []int64
+}
+
+func (a *recordedSamplesAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if value.IsStaleNaN(v) {
+		l = labels.NewBuilder(l).Set(labels.MetricName, l.Get(labels.MetricName)+":stale").Labels()
+	}
+	a.pending = append(a.pending, l)
+	a.ts = append(a.ts, t)
+	return 0, nil
+}
+
+func (a *recordedSamplesAppender) Commit() error {
+	a.recorded.mtx.Lock()
+	defer a.recorded.mtx.Unlock()
+	for i, l := range a.pending {
+		a.recorded.samples[l.Get(labels.MetricName)] = append(a.recorded.samples[l.Get(labels.MetricName)], a.ts[i])
+	}
+	return nil
+}
+
+func TestRuleIntervalsQueryFunc(t *testing.T) {
+	cheap, err := parser.ParseExpr("sum(up)")
+	require.NoError(t, err)
+	expensive, err := parser.ParseExpr("sum by (job) (rate(http_requests_total[1h]))")
+	require.NoError(t, err)
+
+	queried := map[string][]time.Time{}
+	inner := func(_ context.Context, qs string, t time.Time) (promql.Vector, error) {
+		queried[qs] = append(queried[qs], t)
+		return promql.Vector{{Point: promql.Point{T: t.UnixMilli(), V: float64(len(queried[qs]))}, Metric: labels.FromStrings("job", "api")}}, nil
+	}
+	// The timestamps of the results of the expensive expression, in the order of the rules.
+	var returned []int64
+	qf := RuleIntervalsQueryFunc(inner)
+	recordingQF := func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		vector, err := qf(ctx, qs, t)
+		if qs == expensive.String() {
+			returned = append(returned, vector[0].T)
+		}
+		return vector, err
+	}
+	recorded := &recordedSamples{samples: map[string][]int64{}}
+
+	// The expensive expression is evaluated by three rules: the first and the last one with an
+	// interval, and the second one at every evaluation of the group.
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:     "group-1",
+		File:     "/rules/user-1/namespace-1",
+		Interval: time.Minute,
+		Opts: &rules.ManagerOptions{
+			QueryFunc:  recordingQF,
+			Appendable: RuleIntervalsAppendable(recorded),
+			Logger:     log.NewNopLogger(),
+		},
+		Rules: []rules.Rule{
+			rules.NewRecordingRule("up:sum", cheap, nil),
+			rules.NewRecordingRule("job:http_requests:rate1h_3m", expensive, nil),
+			rules.NewRecordingRule("job:http_requests:rate1h", expensive, nil),
+			rules.NewRecordingRule("job:http_requests:rate1h_2m", expensive, nil),
+		},
+	})
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{{
+		Namespace: "namespace-1",
+		Name:      "group-1",
+		Rules: []*rulespb.RuleDesc{
+			{Record: "up:sum", Expr: cheap.String()},
+			{Record: "job:http_requests:rate1h_3m", Expr: expensive.String(), Interval: 3 * time.Minute},
+			{Record: "job:http_requests:rate1h", Expr: expensive.String()},
+			{Record: "job:http_requests:rate1h_2m", Expr: expensive.String(), Interval: 2 * time.Minute},
+		},
+	}})
+	ctx := context.WithValue(context.Background(), tenantRuleGroups, registry)
+	ctx = ChainGroupEvaluationContextFuncs(RuleGroupContextFunc, RuleIntervalsContextFunc)(ctx, g)
+
+	start := time.Unix(3600, 0).UTC()
+	at := func(minutes ...int) []int64 {
+		ts := make([]int64, 0, len(minutes))
+		for _, m := range minutes {
+			ts = append(ts, start.Add(time.Duration(m)*time.Minute).UnixMilli())
+		}
+		return ts
+	}
+	for i := 0; i < 7; i++ {
+		g.Eval(ctx, start.Add(time.Duration(i)*time.Minute))
+		for _, r := range g.Rules() {
+			require.NoError(t, r.LastError(), r.Name())
+		}
+	}
+
+	assert.Len(t, queried[cheap.String()], 7)
+	assert.Len(t, queried[expensive.String()], 3+7+4)
+
+	// The recording rules with an interval write their samples once per interval, without stale markers
+	// in between, and the rule sharing their expression at every evaluation of the group.
+	assert.Equal(t, map[string][]int64{
+		"up:sum":                      at(0, 1, 2, 3, 4, 5, 6),
+		"job:http_requests:rate1h_3m": at(0, 3, 6),
+		"job:http_requests:rate1h":    at(0, 1, 2, 3, 4, 5, 6),
+		"job:http_requests:rate1h_2m": at(0, 2, 4, 6),
+	}, recorded.samples)
+
+	// Between its evaluations, the previous result of a rule is returned with its original timestamp.
+	assert.Equal(t, at(
+		0, 0, 0,
+		0, 1, 0,
+		0, 2, 2,
+		3, 3, 2,
+		3, 4, 4,
+		3, 5, 4,
+		6, 6, 6,
+	), returned)
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	// waitPropagated is the value of the wait parameter of the config API requests waiting for the rulers to
+	// load their change.
+	waitPropagated = "propagated"
+
+	// propagationCheckInterval is how often the requests waiting for the rulers to load their change check it.
+	propagationCheckInterval = time.Second
+)
+
+// RuleGroupStatus is the status of the propagation of a rule group to the rulers evaluating it.
+type RuleGroupStatus struct {
+	// Stored is whether the rule group is in the rule store.
+	Stored bool `yaml:"stored"`
+	// UpdatedAt is the time of the last update of the stored rule group, if known.
+	UpdatedAt *time.Time `yaml:"updated_at,omitempty"`
+	// Loaded is whether a version of the rule group is loaded by a ruler.
+	Loaded bool `yaml:"loaded"`
+	// LoadedUpdatedAt is the time of the last update of the loaded version of the rule group, if known.
+	LoadedUpdatedAt *time.Time `yaml:"loaded_updated_at,omitempty"`
+	// Propagated is whether the stored version of the rule group is loaded, or no version is loaded if the
+	// rule group isn't stored.
+	Propagated bool `yaml:"propagated"`
+	// LastEvaluation is the time of the last evaluation of the loaded rule group, if evaluated yet.
+	LastEvaluation *time.Time `yaml:"last_evaluation,omitempty"`
+}
+
+// propagationResult is the result of the config API requests waiting for the rulers to load their change,
+// which have no other data.
+type propagationResult struct {
+	Propagated bool `json:"propagated"`
+}
+
+// parseWait returns whether the request waits for the rulers to load its change, with the wait parameter.
+func parseWait(req *http.Request) (bool, error) {
+	switch req.URL.Query().Get("wait") {
+	case "":
+		return false, nil
+	case waitPropagated:
+		return true, nil
+	default:
+		return false, ErrInvalidWait
+	}
+}
+
+// GetRuleGroupStatus returns whether the requested rule group is loaded by the rulers evaluating it, and when
+// it was last evaluated.
+func (a *API) GetRuleGroupStatus(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	status, err := a.ruleGroupStatus(req.Context(), userID, namespace, groupName)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to get the status of the rule group", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !status.Stored && !status.Loaded {
+		http.Error(w, rulestore.ErrGroupNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	marshalAndSend(status, w, logger)
+}
+
+// ruleGroupStatus returns the status of the propagation of the rule group to the rulers evaluating it.
+func (a *API) ruleGroupStatus(ctx context.Context, userID, namespace, groupName string) (RuleGroupStatus, error) {
+	status := RuleGroupStatus{}
+
+	rg, err := a.store.GetRuleGroup(ctx, userID, namespace, groupName)
+	switch {
+	case err == nil:
+		status.Stored = true
+		status.UpdatedAt = rg.UpdatedAt
+	case !errors.Is(err, rulestore.ErrGroupNotFound):
+		return status, err
+	}
+
+	states, err := a.ruler.GetRules(user.InjectOrgID(ctx, userID))
+	if err != nil {
+		return status, err
+	}
+	for _, state := range states {
+		if state.Group.Namespace != namespace || state.Group.Name != groupName {
+			continue
+		}
+		// The version of a loaded rule group is the time of its last update, which the rulers record right
+		// before loading the rule group, so the loaded version may be ahead of the evaluated one for a moment.
+		status.Loaded = true
+		status.LoadedUpdatedAt = state.Group.UpdatedAt
+		if !state.EvaluationTimestamp.IsZero() {
+			lastEvaluation := state.EvaluationTimestamp
+			status.LastEvaluation = &lastEvaluation
+		}
+		break
+	}
+
+	if status.Stored {
+		status.Propagated = status.Loaded && sameUpdateTime(status.UpdatedAt, status.LoadedUpdatedAt)
+	} else {
+		status.Propagated = !status.Loaded
+	}
+	return status, nil
+}
+
+// waitPropagation waits until the change of the rule group is loaded by the rulers evaluating it, for up to
+// the propagation wait timeout. It returns whether the change is loaded.
+func (a *API) waitPropagation(ctx context.Context, logger log.Logger, userID, namespace, groupName string) bool {
+	ctx, cancel := context.WithTimeout(ctx, a.ruler.cfg.PropagationWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(propagationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := a.ruleGroupStatus(ctx, userID, namespace, groupName)
+		if err != nil {
+			level.Debug(logger).Log("msg", "unable to check the propagation of the rule group", "err", err.Error(), "user", userID, "namespace", namespace, "group", groupName)
+		} else if status.Propagated {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			level.Warn(logger).Log("msg", "the change of the rule group isn't loaded by the rulers yet", "user", userID, "namespace", namespace, "group", groupName, "timeout", a.ruler.cfg.PropagationWaitTimeout)
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// propagationWarning is the warning of the requests whose change isn't loaded by the rulers within the
+// propagation wait timeout.
+func (a *API) propagationWarning() string {
+	return fmt.Sprintf("the change isn't loaded by the rulers after %s, it's loaded when they next poll the rule groups", a.ruler.cfg.PropagationWaitTimeout)
+}
+
+func sameUpdateTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestAPI_WaitPropagated(t *testing.T) {
+	const group = `
+name: test
+rules:
+- record: up:sum
+  expr: sum(up)
+`
+
+	cfg := defaultRulerConfig(t)
+	cfg.PropagationWaitTimeout = 10 * time.Second
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	rulerAddrMap := map[string]*Ruler{}
+	r := buildRuler(t, cfg, store, rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// The status of the rule groups is read from the rulers of the ring with the mock gRPC client.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods(http.MethodPost).HandlerFunc(a.CreateRuleGroup)
+	router.Path("/prometheus/config/v1/rules/{namespace}/{groupName}").Methods(http.MethodDelete).HandlerFunc(a.DeleteRuleGroup)
+	router.Path("/prometheus/config/v1/rules/{namespace}/{groupName}/status").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroupStatus)
+
+	// serve serves the request while the ruler loads the rule groups once the request changed them.
+	serve := func(t *testing.T, req *http.Request, changed func() bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			router.ServeHTTP(w, req)
+		}()
+		require.Eventually(t, changed, 5*time.Second, 10*time.Millisecond)
+		r.syncRules(context.Background(), rulerSyncReasonRingChange)
+		<-done
+		return w
+	}
+	stored := func() bool {
+		_, err := store.GetRuleGroup(context.Background(), "user1", "namespace1", "test")
+		return err == nil
+	}
+	getStatus := func(t *testing.T) (int, RuleGroupStatus) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test/status", nil, "user1"))
+		status := RuleGroupStatus{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &status))
+		}
+		return w.Code, status
+	}
+	type response struct {
+		Data struct {
+			Propagated *bool `json:"propagated"`
+		} `json:"data"`
+		Warnings []string `json:"warnings"`
+	}
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) response {
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		resp := response{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("invalid wait parameter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1?wait=stored", strings.NewReader(group), "user1"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, ErrInvalidWait.Error()+"\n", w.Body.String())
+		assert.False(t, stored())
+
+		code, _ := getStatus(t)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("create waits for the rule group to be loaded", func(t *testing.T) {
+		req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1?wait=propagated", strings.NewReader(group), "user1")
+		resp := decode(t, serve(t, req, stored))
+		require.NotNil(t, resp.Data.Propagated)
+		assert.True(t, *resp.Data.Propagated)
+		assert.Empty(t, resp.Warnings)
+
+		code, status := getStatus(t)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, status.Stored)
+		assert.True(t, status.Loaded)
+		assert.True(t, status.Propagated)
+		require.NotNil(t, status.UpdatedAt)
+		require.NotNil(t, status.LoadedUpdatedAt)
+		assert.True(t, status.UpdatedAt.Equal(*status.LoadedUpdatedAt))
+	})
+
+	t.Run("update not loaded within the timeout", func(t *testing.T) {
+		a.ruler.cfg.PropagationWaitTimeout = 100 * time.Millisecond
+		defer func() { a.ruler.cfg.PropagationWaitTimeout = 10 * time.Second }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1?wait=propagated", strings.NewReader(group), "user1"))
+		resp := decode(t, w)
+		require.NotNil(t, resp.Data.Propagated)
+		assert.False(t, *resp.Data.Propagated)
+		assert.Equal(t, []string{a.propagationWarning()}, resp.Warnings)
+
+		// The previous version of the rule group is still loaded.
+		code, status := getStatus(t)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, status.Stored)
+		assert.True(t, status.Loaded)
+		assert.False(t, status.Propagated)
+
+		r.syncRules(context.Background(), rulerSyncReasonRingChange)
+		_, status = getStatus(t)
+		assert.True(t, status.Propagated)
+	})
+
+	t.Run("delete waits for the rule group to be unloaded", func(t *testing.T) {
+		req := requestFor(t, http.MethodDelete, "https://localhost:8080/prometheus/config/v1/rules/namespace1/test?wait=propagated", nil, "user1")
+		resp := decode(t, serve(t, req, func() bool { return !stored() }))
+		require.NotNil(t, resp.Data.Propagated)
+		assert.True(t, *resp.Data.Propagated)
+		assert.Empty(t, resp.Warnings)
+
+		code, _ := getStatus(t)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("no wait", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace1", strings.NewReader(group), "user1"))
+		resp := decode(t, w)
+		assert.Nil(t, resp.Data.Propagated)
+
+		code, status := getStatus(t)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, status.Stored)
+		assert.False(t, status.Loaded)
+		assert.False(t, status.Propagated)
+	})
+}
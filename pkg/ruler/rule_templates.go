@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// templateVariableRegexp matches the ${name} references to the variables of the rule group bindings.
+// The $name form isn't supported, so that the $labels and $value references of the alert templates are preserved.
+var templateVariableRegexp = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// expandRuleGroupTemplate returns the rule group with its templated rules expanded once for each
+// of its bindings, in the order of the bindings. A rule is templated if its record, alert, expr,
+// labels or annotations reference a variable, the other rules are kept as they are.
+func expandRuleGroupTemplate(rg rulespb.RuleGroup) (rulespb.RuleGroup, error) {
+	if len(rg.Bindings) == 0 {
+		return rg, nil
+	}
+
+	expanded := make([]rulefmt.RuleNode, 0, len(rg.Rules)*len(rg.Bindings))
+	var expandedIntervals []model.Duration
+	appendInterval := func(ruleIdx int) {
+		if len(rg.RuleIntervals) > 0 {
+			expandedIntervals = append(expandedIntervals, rg.RuleIntervals[ruleIdx])
+		}
+	}
+	for ruleIdx, r := range rg.Rules {
+		if !isTemplatedRule(r) {
+			expanded = append(expanded, r)
+			appendInterval(ruleIdx)
+			continue
+		}
+
+		for bindingIdx, binding := range rg.Bindings {
+			e := &templateExpander{binding: binding}
+			rule := rulefmt.RuleNode{
+				Record:      e.expandNode(r.Record),
+				Alert:       e.expandNode(r.Alert),
+				Expr:        e.expandNode(r.Expr),
+				For:         r.For,
+				Labels:      e.expandMap(r.Labels),
+				Annotations: e.expandMap(r.Annotations),
+			}
+			if e.missing != "" {
+				return rg, fmt.Errorf("rule %d of group %s references the variable %s, which is not set by binding %d", ruleIdx+1, rg.Name, e.missing, bindingIdx+1)
+			}
+			expanded = append(expanded, rule)
+			appendInterval(ruleIdx)
+		}
+	}
+
+	rg.Rules = expanded
+	rg.RuleIntervals = expandedIntervals
+	rg.Bindings = nil
+	return rg, nil
+}
+
+func isTemplatedRule(r rulefmt.RuleNode) bool {
+	values := []string{r.Record.Value, r.Alert.Value, r.Expr.Value}
+	for _, m := range []map[string]string{r.Labels, r.Annotations} {
+		for _, v := range m {
+			values = append(values, v)
+		}
+	}
+	for _, v := range values {
+		if templateVariableRegexp.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateExpander replaces the variables references with their value in a binding. The first
+// variable not set by the binding is recorded in missing.
+type templateExpander struct {
+	binding map[string]string
+	missing string
+}
+
+func (e *templateExpander) expand(s string) string {
+	return templateVariableRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		name := templateVariableRegexp.FindStringSubmatch(ref)[1]
+		value, ok := e.binding[name]
+		if !ok && e.missing == "" {
+			e.missing = name
+		}
+		return value
+	})
+}
+
+func (e *templateExpander) expandNode(n yaml.Node) yaml.Node {
+	n.Value = e.expand(n.Value)
+	return n
+}
+
+func (e *templateExpander) expandMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	expanded := make(map[string]string, len(m))
+	for k, v := range m {
+		expanded[k] = e.expand(v)
+	}
+	return expanded
+}
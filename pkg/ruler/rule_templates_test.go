@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestExpandRuleGroupTemplate(t *testing.T) {
+	type expandedRule struct {
+		Record      string
+		Alert       string
+		Expr        string
+		Labels      map[string]string
+		Annotations map[string]string
+	}
+
+	tests := map[string]struct {
+		input         string
+		expectedRules []expandedRule
+		expectedErr   string
+	}{
+		"no bindings": {
+			input: `
+name: test
+rules:
+- record: job:up:sum
+  expr: sum by (job) (up)
+`,
+			expectedRules: []expandedRule{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			},
+		},
+		"templated and regular rules": {
+			input: `
+name: test
+bindings:
+- service: api
+  threshold: "0.1"
+- service: db
+  threshold: "0.05"
+rules:
+- record: job:errors:rate5m
+  expr: sum by (job) (rate(errors_total[5m]))
+- alert: ${service}HighErrorRate
+  expr: job:errors:rate5m{job="${service}"} > ${threshold}
+  labels:
+    service: ${service}
+  annotations:
+    message: The error rate of {{ $labels.job }} is {{ $value }}, above ${threshold}.
+`,
+			expectedRules: []expandedRule{
+				{Record: "job:errors:rate5m", Expr: "sum by (job) (rate(errors_total[5m]))"},
+				{
+					Alert:       "apiHighErrorRate",
+					Expr:        `job:errors:rate5m{job="api"} > 0.1`,
+					Labels:      map[string]string{"service": "api"},
+					Annotations: map[string]string{"message": "The error rate of {{ $labels.job }} is {{ $value }}, above 0.1."},
+				},
+				{
+					Alert:       "dbHighErrorRate",
+					Expr:        `job:errors:rate5m{job="db"} > 0.05`,
+					Labels:      map[string]string{"service": "db"},
+					Annotations: map[string]string{"message": "The error rate of {{ $labels.job }} is {{ $value }}, above 0.05."},
+				},
+			},
+		},
+		"variable not set by a binding": {
+			input: `
+name: test
+bindings:
+- service: api
+  threshold: "0.1"
+- service: db
+rules:
+- alert: HighErrorRate
+  expr: rate(errors_total{job="${service}"}[5m]) > ${threshold}
+`,
+			expectedErr: "rule 1 of group test references the variable threshold, which is not set by binding 2",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rg := rulespb.RuleGroup{}
+			require.NoError(t, yaml.Unmarshal([]byte(tc.input), &rg))
+
+			expanded, err := expandRuleGroupTemplate(rg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, expanded.Bindings)
+
+			var actual []expandedRule
+			for _, r := range expanded.Rules {
+				actual = append(actual, expandedRule{
+					Record:      r.Record.Value,
+					Alert:       r.Alert.Value,
+					Expr:        r.Expr.Value,
+					Labels:      r.Labels,
+					Annotations: r.Annotations,
+				})
+			}
+			assert.Equal(t, tc.expectedRules, actual)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var errRuleTrashDisabled = errors.New("the deleted rule groups are not kept, the trash is disabled")
+
+// TrashedRuleGroups has info for the rule groups of a tenant in the trash.
+type TrashedRuleGroups struct {
+	RuleGroups []TrashedRuleGroup `yaml:"rule_groups"`
+}
+
+// TrashedRuleGroup has info for a rule group in the trash.
+type TrashedRuleGroup struct {
+	Namespace string    `yaml:"namespace"`
+	Name      string    `yaml:"name"`
+	DeletedAt time.Time `yaml:"deleted_at"`
+	ExpiresAt time.Time `yaml:"expires_at"`
+}
+
+// RestoredRuleGroups has info for the rule groups restored from the trash.
+type RestoredRuleGroups struct {
+	Restored []string `yaml:"restored"`
+	// Not restored because a rule group with the same name exists.
+	Conflicting []string `yaml:"conflicting,omitempty"`
+}
+
+// trashStore returns the rule store as a rulestore.TrashRuleStore if the deleted rule
+// groups are kept in the trash.
+func (a *API) trashStore() (rulestore.TrashRuleStore, bool) {
+	if a.ruler.cfg.DeletedRuleGroupsRetention <= 0 {
+		return nil, false
+	}
+	store, ok := a.store.(rulestore.TrashRuleStore)
+	return store, ok
+}
+
+// deleteRuleGroup deletes the rule group, moving it to the trash if enabled.
+func (a *API) deleteRuleGroup(ctx context.Context, logger log.Logger, userID, namespace, group string) error {
+	store, ok := a.trashStore()
+	if !ok {
+		return a.store.DeleteRuleGroup(ctx, userID, namespace, group)
+	}
+	if err := store.TrashRuleGroup(ctx, userID, namespace, group); err != nil {
+		return err
+	}
+	a.purgeTrash(ctx, logger, store, userID)
+	return nil
+}
+
+// deleteNamespace deletes the rule groups of the namespace, moving them to the trash if enabled.
+func (a *API) deleteNamespace(ctx context.Context, logger log.Logger, userID, namespace string) error {
+	store, ok := a.trashStore()
+	if !ok {
+		return a.store.DeleteNamespace(ctx, userID, namespace)
+	}
+	if err := store.TrashNamespace(ctx, userID, namespace); err != nil {
+		return err
+	}
+	a.purgeTrash(ctx, logger, store, userID)
+	return nil
+}
+
+// purgeTrash permanently deletes the rule groups of the tenant in the trash for longer than the retention.
+// The failures are only logged, because the rule groups are purged again at the next deletion.
+func (a *API) purgeTrash(ctx context.Context, logger log.Logger, store rulestore.TrashRuleStore, userID string) {
+	if err := store.PurgeTrash(ctx, userID, time.Now().Add(-a.ruler.cfg.DeletedRuleGroupsRetention)); err != nil {
+		level.Warn(logger).Log("msg", "unable to purge the expired rule groups from the trash", "user", userID, "err", err)
+	}
+}
+
+// ListTrashedRuleGroups returns the rule groups of the tenant in the trash, which can be restored.
+func (a *API) ListTrashedRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, _, _, err := parseRequest(req, false, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	store, ok := a.trashStore()
+	if !ok {
+		http.Error(w, errRuleTrashDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	retention := a.ruler.cfg.DeletedRuleGroupsRetention
+	trashed, err := store.ListTrashedRuleGroups(req.Context(), userID, "", time.Now().Add(-retention))
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to list the rule groups in the trash", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	groups := make([]TrashedRuleGroup, 0, len(trashed))
+	for _, g := range trashed {
+		if !authorizedNamespace(req.Context(), g.Namespace) {
+			continue
+		}
+		groups = append(groups, TrashedRuleGroup{Namespace: g.Namespace, Name: g.Name, DeletedAt: g.DeletedAt, ExpiresAt: g.DeletedAt.Add(retention)})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].Name < groups[j].Name
+	})
+
+	marshalAndSend(TrashedRuleGroups{RuleGroups: groups}, w, logger)
+}
+
+// RestoreRuleGroup restores the requested rule group from the trash.
+func (a *API) RestoreRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	store, ok := a.trashStore()
+	if !ok {
+		http.Error(w, errRuleTrashDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	if err := a.assertMaxRestoredRuleGroups(req.Context(), userID, 1); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = store.RestoreRuleGroup(req.Context(), userID, namespace, groupName, time.Now().Add(-a.ruler.cfg.DeletedRuleGroupsRetention))
+	switch {
+	case errors.Is(err, rulestore.ErrGroupNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, rulestore.ErrGroupAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		level.Error(logger).Log("msg", "unable to restore the rule group", "err", err.Error(), "user", userID)
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	a.notifyRulesChange(userID, namespace, groupName, rulesChangeActionRestore)
+	marshalAndSend(RestoredRuleGroups{Restored: []string{groupName}}, w, logger)
+}
+
+// RestoreNamespace restores the rule groups of the requested namespace from the trash. The rule groups
+// with the same name as an existing rule group of the namespace are not restored.
+func (a *API) RestoreNamespace(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	store, ok := a.trashStore()
+	if !ok {
+		http.Error(w, errRuleTrashDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	since := time.Now().Add(-a.ruler.cfg.DeletedRuleGroupsRetention)
+	trashed, err := store.ListTrashedRuleGroups(req.Context(), userID, namespace, since)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to list the rule groups in the trash", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(trashed) == 0 {
+		http.Error(w, rulestore.ErrGroupNamespaceNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := a.assertMaxRestoredRuleGroups(req.Context(), userID, len(trashed)); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	restored := RestoredRuleGroups{Restored: []string{}}
+	for _, g := range trashed {
+		err := store.RestoreRuleGroup(req.Context(), userID, namespace, g.Name, since)
+		switch {
+		case errors.Is(err, rulestore.ErrGroupNotFound):
+			// Restored or purged meanwhile.
+		case errors.Is(err, rulestore.ErrGroupAlreadyExists):
+			restored.Conflicting = append(restored.Conflicting, g.Name)
+		case err != nil:
+			level.Error(logger).Log("msg", "unable to restore the rule group", "err", err.Error(), "user", userID, "group", g.Name)
+			respondError(logger, w, err.Error())
+			return
+		default:
+			restored.Restored = append(restored.Restored, g.Name)
+		}
+	}
+	sort.Strings(restored.Restored)
+	sort.Strings(restored.Conflicting)
+
+	for _, g := range restored.Restored {
+		a.notifyRulesChange(userID, namespace, g, rulesChangeActionRestore)
+	}
+	marshalAndSend(restored, w, logger)
+}
+
+// assertMaxRestoredRuleGroups returns an error if restoring n rule groups would exceed the
+// maximum number of rule groups of the tenant.
+func (a *API) assertMaxRestoredRuleGroups(ctx context.Context, userID string, n int) error {
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+	return a.ruler.AssertMaxRuleGroups(userID, len(rgs)+n)
+}
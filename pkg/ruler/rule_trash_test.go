@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestAPI_RuleTrash(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	for _, g := range []struct{ namespace, name string }{{"hello", "first"}, {"hello", "second"}, {"world", "third"}} {
+		desc := rulespb.ToProto("user1", g.namespace, rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: g.name}})
+		require.NoError(t, store.SetRuleGroup(ctx, "user1", g.namespace, desc))
+	}
+
+	newRouter := func(retention time.Duration, maxRuleGroups int) *mux.Router {
+		cfg := Config{DeletedRuleGroupsRetention: retention}
+		a := &API{ruler: &Ruler{cfg: cfg, limits: ruleLimits{maxRuleGroups: maxRuleGroups}}, store: store, logger: log.NewNopLogger()}
+
+		router := mux.NewRouter()
+		router.Path("/api/v1/rule_trash").Methods(http.MethodGet).HandlerFunc(a.ListTrashedRuleGroups)
+		router.Path("/api/v1/rules/{namespace}/restore").Methods(http.MethodPost).HandlerFunc(a.RestoreNamespace)
+		router.Path("/api/v1/rules/{namespace}/{groupName}/restore").Methods(http.MethodPost).HandlerFunc(a.RestoreRuleGroup)
+		router.Path("/api/v1/rules/{namespace}").Methods(http.MethodDelete).HandlerFunc(a.DeleteNamespace)
+		router.Path("/api/v1/rules/{namespace}/{groupName}").Methods(http.MethodDelete).HandlerFunc(a.DeleteRuleGroup)
+		return router
+	}
+	do := func(router *mux.Router, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, requestFor(t, method, "https://localhost:8080"+path, nil, "user1"))
+		return w
+	}
+
+	t.Run("trash disabled", func(t *testing.T) {
+		router := newRouter(0, 0)
+		assert.Equal(t, http.StatusNotImplemented, do(router, http.MethodGet, "/api/v1/rule_trash").Code)
+		assert.Equal(t, http.StatusNotImplemented, do(router, http.MethodPost, "/api/v1/rules/hello/restore").Code)
+	})
+
+	router := newRouter(time.Hour, 0)
+
+	t.Run("deleted rule groups are moved to the trash", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted, do(router, http.MethodDelete, "/api/v1/rules/hello").Code)
+		require.Equal(t, http.StatusAccepted, do(router, http.MethodDelete, "/api/v1/rules/world/third").Code)
+
+		rgs, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+		require.NoError(t, err)
+		assert.Empty(t, rgs)
+
+		w := do(router, http.MethodGet, "/api/v1/rule_trash")
+		require.Equal(t, http.StatusOK, w.Code)
+		var trashed TrashedRuleGroups
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &trashed))
+		require.Len(t, trashed.RuleGroups, 3)
+		for i, expected := range []string{"first", "second", "third"} {
+			g := trashed.RuleGroups[i]
+			assert.Equal(t, expected, g.Name)
+			assert.Equal(t, time.Hour, g.ExpiresAt.Sub(g.DeletedAt))
+		}
+	})
+
+	t.Run("restore limited by the max number of rule groups", func(t *testing.T) {
+		w := do(newRouter(time.Hour, 1), http.MethodPost, "/api/v1/rules/hello/restore")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("restore a namespace", func(t *testing.T) {
+		// A rule group with the same name as a deleted one was created meanwhile.
+		desc := rulespb.ToProto("user1", "hello", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "second"}})
+		require.NoError(t, store.SetRuleGroup(ctx, "user1", "hello", desc))
+
+		w := do(router, http.MethodPost, "/api/v1/rules/hello/restore")
+		require.Equal(t, http.StatusOK, w.Code)
+		var restored RestoredRuleGroups
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &restored))
+		assert.Equal(t, RestoredRuleGroups{Restored: []string{"first"}, Conflicting: []string{"second"}}, restored)
+
+		assert.Equal(t, http.StatusNotFound, do(router, http.MethodPost, "/api/v1/rules/missing/restore").Code)
+	})
+
+	t.Run("restore a rule group", func(t *testing.T) {
+		w := do(router, http.MethodPost, "/api/v1/rules/world/third/restore")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.Contains(w.Body.String(), "third"))
+
+		_, err := store.GetRuleGroup(ctx, "user1", "world", "third")
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, do(router, http.MethodPost, "/api/v1/rules/world/third/restore").Code)
+		assert.Equal(t, http.StatusConflict, do(router, http.MethodPost, "/api/v1/rules/hello/second/restore").Code)
+	})
+}
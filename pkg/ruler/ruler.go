@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -29,23 +30,39 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	promRules "github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/util/strutil"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 var (
-	errInvalidTenantShardSize = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidTenantShardSize               = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidMaxIndependentRuleConcurrency = errors.New("invalid max independent rule concurrency, the value must be greater or equal to 0")
+	errInvalidAlertDeduplicationKeyLabel    = errors.New("invalid alert deduplication key label, the value must be a valid label name")
+	errInvalidDuplicateRecordingRulesPolicy = fmt.Errorf("invalid duplicate recording rules policy, supported values are: %s", strings.Join(duplicateRecordingRulesPolicies, ", "))
+	errInvalidRingReplicationFactor         = errors.New("invalid ruler ring replication factor, the value must be greater than 0")
+	errInvalidMissedIterationsPolicy        = fmt.Errorf("invalid missed iterations policy, supported values are: %s", strings.Join(missedIterationsPolicies, ", "))
+	errInvalidMaxBackfilledIterations       = errors.New("invalid max backfilled iterations, the value must be greater than 0")
+	errInvalidDeletedRuleGroupsRetention    = errors.New("invalid deleted rule groups retention, the value must not be negative")
+	errInvalidShutdownGracePeriod           = errors.New("invalid shutdown grace period, the value must not be negative")
+	errRemoteEvaluatorWithQueryFrontend     = errors.New("the ruler remote evaluator and query-frontend addresses are mutually exclusive")
+	errRulerAPIAndEvaluationDisabled        = errors.New("the ruler config API and the evaluation of the rule groups can't be both disabled")
 )
 
 const (
@@ -65,9 +82,15 @@ const (
 	// Limit errors
 	errMaxRuleGroupsPerUserLimitExceeded        = "per-user rule groups limit (limit: %d actual: %d) exceeded"
 	errMaxRulesPerRuleGroupPerUserLimitExceeded = "per-user rules per rule group limit (limit: %d actual: %d) exceeded"
+	errMaxRecordingRuleLabelsLimitExceeded      = "per-user recording rule labels limit (limit: %d actual: %d) exceeded by the recording rule %s"
+	errReservedRecordingRuleLabel               = "the recording rule %s adds the label %s, which is reserved"
 
 	// errors
 	errListAllUser = "unable to list the ruler users"
+
+	// reservedLabelPrefix is the prefix of the label names reserved for internal use, such as
+	// the tenant label of the federated alerts, which can't be added by the recording rules.
+	reservedLabelPrefix = "__"
 )
 
 // Config is the configuration for the recording rules server.
@@ -87,10 +110,8 @@ type Config struct {
 	AlertmanagerURL string `yaml:"alertmanager_url"`
 	// How long to wait between refreshing the list of Alertmanager based on DNS service discovery.
 	AlertmanagerRefreshInterval time.Duration `yaml:"alertmanager_refresh_interval" category:"advanced"`
-	// Capacity of the queue for notifications to be sent to the Alertmanager.
-	NotificationQueueCapacity int `yaml:"notification_queue_capacity" category:"advanced"`
-	// HTTP timeout duration when sending notifications to the Alertmanager.
-	NotificationTimeout time.Duration `yaml:"notification_timeout" category:"advanced"`
+	// Directory to persist the notifications queued for sending to the Alertmanager.
+	NotificationQueueDir string `yaml:"notification_queue_dir" category:"experimental"`
 	// Client configs for interacting with the Alertmanager
 	Notifier NotifierConfig `yaml:"alertmanager_client"`
 
@@ -100,13 +121,27 @@ type Config struct {
 	ForGracePeriod time.Duration `yaml:"for_grace_period" category:"advanced"`
 	// Minimum amount of time to wait before resending an alert to Alertmanager.
 	ResendDelay time.Duration `yaml:"resend_delay" category:"advanced"`
+	// Period after the start of the rules manager of a tenant during which the alert notifications aren't sent.
+	ResendGracePeriod time.Duration `yaml:"resend_grace_period" category:"experimental"`
+	// Name of the label with the deduplication key of the alerts sent to the Alertmanager.
+	AlertDeduplicationKeyLabel string `yaml:"alert_deduplication_key_label" category:"experimental"`
+	// Maximum number of independent rules evaluated concurrently.
+	MaxIndependentRuleConcurrency int `yaml:"max_independent_rule_concurrency" category:"experimental"`
 
 	// Enable sharding rule groups.
 	SearchPendingFor time.Duration `yaml:"search_pending_for" category:"advanced"`
 	Ring             RingConfig    `yaml:"ring"`
 	FlushCheckPeriod time.Duration `yaml:"flush_period" category:"advanced"`
 
-	EnableAPI bool `yaml:"enable_api"`
+	EnableAPI        bool `yaml:"enable_api"`
+	EnableEvaluation bool `yaml:"enable_evaluation" category:"experimental"`
+	// Reject the rule groups with unknown fields submitted to the config API.
+	StrictRuleGroupParsing bool `yaml:"strict_rule_group_parsing" category:"experimental"`
+	// Maximum time the config API requests wait for the rulers to load their changes.
+	PropagationWaitTimeout time.Duration `yaml:"propagation_wait_timeout" category:"experimental"`
+
+	ReadyAfterInitialSync bool          `yaml:"ready_after_initial_sync" category:"experimental"`
+	ShutdownGracePeriod   time.Duration `yaml:"shutdown_grace_period" category:"experimental"`
 
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants" category:"advanced"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants" category:"advanced"`
@@ -117,7 +152,37 @@ type Config struct {
 
 	QueryFrontend QueryFrontendConfig `yaml:"query_frontend" category:"experimental"`
 
+	RemoteEvaluator RemoteEvaluatorConfig `yaml:"remote_evaluator" category:"experimental"`
+
 	TenantFederation TenantFederationConfig `yaml:"tenant_federation"`
+
+	DuplicateRecordingRulesPolicy string `yaml:"duplicate_recording_rules_policy" category:"experimental"`
+
+	MissedIterationsPolicy  string `yaml:"missed_iterations_policy" category:"experimental"`
+	MaxBackfilledIterations int    `yaml:"max_backfilled_iterations" category:"experimental"`
+
+	DeletedRuleGroupsRetention time.Duration `yaml:"deleted_rule_groups_retention" category:"experimental"`
+
+	OTLPExport OTLPExportConfig `yaml:"otlp_export" category:"experimental"`
+
+	Query       QueryConfig       `yaml:"query"`
+	QueryEngine QueryEngineConfig `yaml:"query_engine"`
+
+	AlertHistory AlertHistoryConfig `yaml:"alert_history" category:"experimental"`
+
+	Provisioning ProvisioningConfig `yaml:"provisioning" category:"experimental"`
+
+	ChangesWebhook ChangesWebhookConfig `yaml:"changes_webhook" category:"experimental"`
+
+	AdminOverride AdminOverrideConfig `yaml:"admin_override" category:"experimental"`
+
+	NamespaceAuthorization NamespaceAuthorizationConfig `yaml:"namespace_authorization" category:"experimental"`
+
+	MetaMonitoring MetaMonitoringConfig `yaml:"meta_monitoring" category:"experimental"`
+
+	JsonnetImport JsonnetImportConfig `yaml:"jsonnet_import" category:"experimental"`
+
+	PrometheusRuleController PrometheusRuleControllerConfig `yaml:"prometheus_rule_controller" category:"experimental"`
 }
 
 // Validate config and returns error on failure
@@ -126,9 +191,89 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 		return errInvalidTenantShardSize
 	}
 
+	if cfg.Ring.ReplicationFactor <= 0 {
+		return errInvalidRingReplicationFactor
+	}
+
 	if err := cfg.ClientTLSConfig.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ruler gRPC client config")
 	}
+
+	if cfg.MaxIndependentRuleConcurrency < 0 {
+		return errInvalidMaxIndependentRuleConcurrency
+	}
+
+	if cfg.AlertDeduplicationKeyLabel != "" && !model.LabelName(cfg.AlertDeduplicationKeyLabel).IsValid() {
+		return errInvalidAlertDeduplicationKeyLabel
+	}
+
+	if err := cfg.TenantFederation.Validate(); err != nil {
+		return err
+	}
+
+	if !util.StringsContain(duplicateRecordingRulesPolicies, cfg.DuplicateRecordingRulesPolicy) {
+		return errInvalidDuplicateRecordingRulesPolicy
+	}
+
+	if !util.StringsContain(missedIterationsPolicies, cfg.MissedIterationsPolicy) {
+		return errInvalidMissedIterationsPolicy
+	}
+
+	if cfg.MissedIterationsPolicy == missedIterationsPolicyBackfill && cfg.MaxBackfilledIterations <= 0 {
+		return errInvalidMaxBackfilledIterations
+	}
+
+	if cfg.DeletedRuleGroupsRetention < 0 {
+		return errInvalidDeletedRuleGroupsRetention
+	}
+
+	if cfg.ShutdownGracePeriod < 0 {
+		return errInvalidShutdownGracePeriod
+	}
+
+	if err := cfg.OTLPExport.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Query.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.QueryEngine.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.RemoteEvaluator.Address != "" && cfg.QueryFrontend.Address != "" {
+		return errRemoteEvaluatorWithQueryFrontend
+	}
+
+	if !cfg.EnableAPI && !cfg.EnableEvaluation {
+		return errRulerAPIAndEvaluationDisabled
+	}
+
+	if err := cfg.AlertHistory.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Provisioning.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.ChangesWebhook.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.MetaMonitoring.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.JsonnetImport.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.PrometheusRuleController.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -139,6 +284,18 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.Notifier.RegisterFlags(f)
 	cfg.TenantFederation.RegisterFlags(f)
 	cfg.QueryFrontend.RegisterFlags(f)
+	cfg.RemoteEvaluator.RegisterFlags(f)
+	cfg.OTLPExport.RegisterFlags(f)
+	cfg.Query.RegisterFlags(f)
+	cfg.QueryEngine.RegisterFlags(f)
+	cfg.AlertHistory.RegisterFlags(f)
+	cfg.Provisioning.RegisterFlags(f)
+	cfg.ChangesWebhook.RegisterFlags(f)
+	cfg.AdminOverride.RegisterFlags(f)
+	cfg.NamespaceAuthorization.RegisterFlags(f)
+	cfg.MetaMonitoring.RegisterFlags(f)
+	cfg.JsonnetImport.RegisterFlags(f)
+	cfg.PrometheusRuleController.RegisterFlags(f)
 
 	cfg.ExternalURL.URL, _ = url.Parse("") // Must be non-nil
 	f.Var(&cfg.ExternalURL, "ruler.external.url", "URL of alerts return path.")
@@ -147,21 +304,33 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 
 	f.StringVar(&cfg.AlertmanagerURL, "ruler.alertmanager-url", "", "Comma-separated list of URL(s) of the Alertmanager(s) to send notifications to. Each URL is treated as a separate group. Multiple Alertmanagers in HA per group can be supported by using DNS service discovery format. Basic auth is supported as part of the URL.")
 	f.DurationVar(&cfg.AlertmanagerRefreshInterval, "ruler.alertmanager-refresh-interval", 1*time.Minute, "How long to wait between refreshing DNS resolutions of Alertmanager hosts.")
-	f.IntVar(&cfg.NotificationQueueCapacity, "ruler.notification-queue-capacity", 10000, "Capacity of the queue for notifications to be sent to the Alertmanager.")
-	f.DurationVar(&cfg.NotificationTimeout, "ruler.notification-timeout", 10*time.Second, "HTTP timeout duration when sending notifications to the Alertmanager.")
+	f.StringVar(&cfg.NotificationQueueDir, "ruler.notification-queue-dir", "", "Directory to persist the notifications which are queued for sending to the Alertmanager, and not acknowledged yet, every 30s and when the ruler stops. The persisted notifications are resent when the ruler restarts, unless they expired in the meantime. This directory must be persisted between restarts. If empty, the queued notifications are not persisted.")
 
 	f.DurationVar(&cfg.SearchPendingFor, "ruler.search-pending-for", 5*time.Minute, "Time to spend searching for a pending ruler when shutting down.")
 	f.DurationVar(&cfg.FlushCheckPeriod, "ruler.flush-period", 1*time.Minute, "Period with which to attempt to flush rule groups.")
 	f.StringVar(&cfg.RulePath, "ruler.rule-path", "./data-ruler/", "Directory to store temporary rule files loaded by the Prometheus rule managers. This directory is not required to be persisted between restarts.")
 	f.BoolVar(&cfg.EnableAPI, "ruler.enable-api", true, "Enable the ruler config API.")
+	f.BoolVar(&cfg.StrictRuleGroupParsing, "ruler.strict-rule-group-parsing", false, "Reject with 400 status code the rule groups submitted to the ruler config API which have unknown fields, instead of ignoring these fields. The fields whose key starts with x- are always ignored. The requests can override this setting with the X-Mimir-Strict-Rule-Group-Parsing header set to true or false.")
+	f.DurationVar(&cfg.PropagationWaitTimeout, "ruler.propagation-wait-timeout", 20*time.Second, "Maximum time the requests of the ruler config API with the wait=propagated parameter wait for the rulers to load the created or deleted rule group. The rulers load the changes when they poll the rule groups, see -ruler.poll-interval. It should be lower than -server.http-write-timeout.")
+	f.BoolVar(&cfg.EnableEvaluation, "ruler.enable-evaluation", true, "Enable the evaluation of the rule groups. When disabled, the ruler doesn't join the ring and doesn't evaluate any rule group, but still serves the ruler config API if enabled, and the rules and alerts of the rulers of the ring evaluating the rule groups.")
+	f.BoolVar(&cfg.ReadyAfterInitialSync, "ruler.ready-after-initial-sync", false, "Report the ruler as not ready until its first sync of the rule groups completed, with the rule groups it evaluates loaded and scheduled, so that the load balancers don't route the requests of the rules API to a ruler returning incomplete results. Ignored when the evaluation of the rule groups is disabled.")
+	f.DurationVar(&cfg.ShutdownGracePeriod, "ruler.shutdown-grace-period", 0, "Maximum time to wait, when the ruler stops, for the in-flight evaluations of the rule groups, with the appends and the batched writes of their results, and the notifications queued for sending to the Alertmanager to complete. No new evaluation is started meanwhile. 0 to cancel the in-flight evaluations and drop the queued notifications immediately.")
 	f.DurationVar(&cfg.OutageTolerance, "ruler.for-outage-tolerance", time.Hour, `Max time to tolerate outage for restoring "for" state of alert.`)
 	f.DurationVar(&cfg.ForGracePeriod, "ruler.for-grace-period", 10*time.Minute, `Minimum duration between alert and restored "for" state. This is maintained only for alerts with configured "for" time greater than grace period.`)
 	f.DurationVar(&cfg.ResendDelay, "ruler.resend-delay", time.Minute, `Minimum amount of time to wait before resending an alert to Alertmanager.`)
+	f.DurationVar(&cfg.ResendGracePeriod, "ruler.resend-grace-period", 0, "Period after the ruler starts evaluating the rules of a tenant, at startup or when the tenant is moved to the ruler, during which the alerts are evaluated and accumulate their state but no notifications are sent to the Alertmanager. Prevents notification storms after restarts. 0 to disable.")
+	f.StringVar(&cfg.AlertDeduplicationKeyLabel, "ruler.alert-deduplication-key-label", "", "Name of a label added to the alerts sent to the Alertmanager, whose value is a key derived from the tenant, the rule group and the labels of the alert. The key is the same whichever ruler evaluates the rule group, so the Alertmanager deduplicates the alerts sent by multiple rulers, for example while the rule groups are resharded, and tells apart the alerts of different rule groups with the same labels. Empty to disable.")
+	f.IntVar(&cfg.MaxIndependentRuleConcurrency, "ruler.max-independent-rule-concurrency", 0, "Maximum number of independent rules evaluated concurrently across all the rule groups of the ruler. A rule is independent if it doesn't read the metrics written by a preceding rule of its group. The rules of each group are evaluated sequentially if 0.")
 
 	f.Var(&cfg.EnabledTenants, "ruler.enabled-tenants", "Comma separated list of tenants whose rules this ruler can evaluate. If specified, only these tenants will be handled by ruler, otherwise this ruler can process rules from all tenants. Subject to sharding.")
 	f.Var(&cfg.DisabledTenants, "ruler.disabled-tenants", "Comma separated list of tenants whose rules this ruler cannot evaluate. If specified, a ruler that would normally pick the specified tenant(s) for processing will ignore them instead. Subject to sharding.")
 
-	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per-tenant metric and as an info level log message.")
+	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time, the number of fetched series and chunks, and the size of fetched chunks of ruler queries as per-tenant metrics and as an info level log message. When using remote evaluation, the stats are the ones of the queriers, reported by the query-frontend if its query stats are enabled.")
+
+	f.StringVar(&cfg.DuplicateRecordingRulesPolicy, "ruler.duplicate-recording-rules-policy", duplicateRecordingRulesPolicyDisabled, fmt.Sprintf("What to do when a rule group submitted through the ruler config API contains a recording rule that records to the same metric name with identical labels as another recording rule of the tenant. Supported values are: %s.", strings.Join(duplicateRecordingRulesPolicies, ", ")))
+	f.StringVar(&cfg.MissedIterationsPolicy, "ruler.missed-iterations-policy", missedIterationsPolicySkip, fmt.Sprintf("What to do with the iterations of a rule group missed because its previous evaluation took longer than its interval. With %q the missed iterations are skipped. With %q the recording rules of the group are evaluated at the timestamps of the missed iterations before the next evaluation, to fill the gaps in their results. Supported values are: %s.", missedIterationsPolicySkip, missedIterationsPolicyBackfill, strings.Join(missedIterationsPolicies, ", ")))
+	f.IntVar(&cfg.MaxBackfilledIterations, "ruler.max-backfilled-iterations", 10, "Maximum number of the most recent missed iterations of a rule group backfilled before its next evaluation, when -ruler.missed-iterations-policy=backfill.")
+	f.DurationVar(&cfg.DeletedRuleGroupsRetention, "ruler.deleted-rule-groups-retention", 0, "How long the rule groups deleted through the configuration API are kept in a trash, from which they can be restored. The rule groups deleted for longer are purged from the trash when another rule group of the tenant is deleted. 0 to delete the rule groups permanently. Requires a rule store backed by an object storage.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
@@ -171,6 +340,13 @@ type rulerMetrics struct {
 	loadRuleGroups  prometheus.Histogram
 	ringCheckErrors prometheus.Counter
 	rulerSync       *prometheus.CounterVec
+
+	syncDuration      prometheus.Histogram
+	syncFailures      prometheus.Counter
+	syncLastSuccess   prometheus.Gauge
+	syncPolledTenants prometheus.Gauge
+
+	brokenRuleGroups *prometheus.GaugeVec
 }
 
 func newRulerMetrics(reg prometheus.Registerer) *rulerMetrics {
@@ -193,6 +369,27 @@ func newRulerMetrics(reg prometheus.Registerer) *rulerMetrics {
 			Name: "cortex_ruler_sync_rules_total",
 			Help: "Total number of times the ruler sync operation triggered.",
 		}, []string{"reason"}),
+		syncDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_sync_rules_duration_seconds",
+			Help:    "Time spent syncing the rule groups, including polling the rule storage and updating the rule managers.",
+			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 15, 30, 60},
+		}),
+		syncFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_sync_rules_failures_total",
+			Help: "Total number of syncs of the rule groups which failed because the rule storage couldn't be polled.",
+		}),
+		syncLastSuccess: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_sync_rules_last_success_timestamp_seconds",
+			Help: "Timestamp of the last successful sync of the rule groups.",
+		}),
+		syncPolledTenants: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_sync_polled_tenants",
+			Help: "Number of tenants whose rule groups were polled from the rule storage by the last successful sync.",
+		}),
+		brokenRuleGroups: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ruler_broken_rule_groups",
+			Help: "Number of rule groups skipped at the last sync because they can't be decoded or don't pass the validation.",
+		}, []string{"user"}),
 	}
 }
 
@@ -203,6 +400,18 @@ type MultiTenantManager interface {
 	SyncRuleGroups(ctx context.Context, ruleGroups map[string]rulespb.RuleGroupList)
 	// GetRules fetches rules for a particular tenant (userID).
 	GetRules(userID string) []*promRules.Group
+	// GetRuleGroupDesc returns a rule group of a tenant as last synced from the RuleStore, or nil if unknown.
+	GetRuleGroupDesc(userID, namespace, group string) *rulespb.RuleGroupDesc
+	// SetFollowedRuleGroups sets the rule groups of each tenant whose leader is another ruler, which are
+	// evaluated without writing their results or sending their alerts.
+	SetFollowedRuleGroups(followed map[string]rulespb.RuleGroupList)
+	// GetMissedIterations returns the number of iterations missed by a rule group of a tenant.
+	GetMissedIterations(userID string, g *promRules.Group) int64
+	// GetDroppedAlerts returns the number of alerts dropped at the latest evaluation of each rule of a rule
+	// group of a tenant, by rule index, because the rule exceeded the maximum number of alerts per rule.
+	GetDroppedAlerts(userID string, g *promRules.Group) []int64
+	// ApplyNotifierConfig applies the Alertmanager notifier settings of cfg to the notifiers of all the tenants.
+	ApplyNotifierConfig(cfg Config) error
 	// Stop stops all Manager components.
 	Stop()
 	// ValidateRuleGroup validates a rulegroup
@@ -210,6 +419,7 @@ type MultiTenantManager interface {
 }
 
 // Ruler evaluates rules.
+//
 //	+---------------------------------------------------------------+
 //	|                                                               |
 //	|                   Query       +-------------+                 |
@@ -241,27 +451,90 @@ type Ruler struct {
 	cfg        Config
 	lifecycler *ring.BasicLifecycler
 	ring       *ring.Ring
-	store      rulestore.RuleStore
-	manager    MultiTenantManager
-	limits     RulesLimits
+	ringStore  kv.Client
+
+	// The rings of the other pools of rulers, started when the rules of a tenant assigned to the pool are read.
+	poolRingsMtx sync.Mutex
+	poolRings    map[string]*ring.Ring
+
+	store   rulestore.RuleStore
+	manager MultiTenantManager
+	limits  RulesLimits
 
 	metrics *rulerMetrics
 
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 
+	// Pushes the ruler metrics and traces to an OTLP endpoint. Nil if disabled.
+	otlpExporter *otlpExporter
+
 	// Pool of clients used to connect to other ruler replicas.
 	clientsPool ClientsPool
 
+	// Records the alert state transitions. Nil if disabled.
+	alertHistory *AlertHistory
+
+	// Stores the consents of the source tenants of the federated rule groups. Nil if disabled.
+	federationConsents *FederationConsents
+
+	// Records the queries of the federated rule groups. Nil if disabled.
+	federationAudit *FederationAudit
+
+	// Reconciles the provisioned rule groups into the rule store. Nil if disabled.
+	provisioner *rulesProvisioner
+
+	// Installs the meta-monitoring rule groups into the rule store. Nil if disabled.
+	metaMonitoring *metaMonitoringInstaller
+
+	// Syncs the PrometheusRule resources of a Kubernetes cluster into the rule store. Nil if disabled.
+	prometheusRuleController *prometheusRuleController
+
+	// Notifies the rule groups changes made through the API. Nil if disabled.
+	changesWebhook *rulesChangesWebhook
+
+	// Used by the on-demand rule group evaluations. Nil if not available.
+	queryFunc           promRules.QueryFunc
+	onDemandLimitersMtx sync.Mutex
+	onDemandLimiters    map[string]*rate.Limiter
+
+	// Used to rate limit the write requests of the configuration API.
+	configAPILimitersMtx sync.Mutex
+	configAPILimiters    map[string]*rate.Limiter
+
+	// Slots of the evaluations of the Jsonnet bundles imported through the configuration API.
+	jsonnetEvaluations *semaphore.Weighted
+
+	// The rule groups skipped at the last sync, by user.
+	brokenRuleGroupsMtx sync.Mutex
+	brokenRuleGroups    map[string][]rulestore.BrokenRuleGroup
+
 	allowedTenants *util.AllowedTenants
 
+	// Whether a sync of the rule groups completed since the ruler started.
+	rulesSynced atomic.Bool
+
+	// The rule groups of each user loaded at the latest poll of the rule storage for the user, reused by
+	// the syncs until the user is polled again.
+	polledRulesMtx sync.Mutex
+	polledRules    map[string]polledUserRules
+
 	registry prometheus.Registerer
 	logger   log.Logger
 }
 
-// NewRuler creates a new ruler from a distributor and chunk store.
-func NewRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits) (*Ruler, error) {
-	return newRuler(cfg, manager, reg, logger, ruleStore, limits, newRulerClientPool(cfg.ClientTLSConfig, logger, reg))
+// NewRuler creates a new ruler from a distributor and chunk store. The queryFunc is used to run
+// on-demand rule group evaluations. The alertHistory, the federationConsents and the federationAudit are optional.
+func NewRuler(cfg Config, manager MultiTenantManager, queryFunc promRules.QueryFunc, alertHistory *AlertHistory, federationConsents *FederationConsents, federationAudit *FederationAudit, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits) (*Ruler, error) {
+	ruler, err := newRuler(cfg, manager, reg, logger, ruleStore, limits, newRulerClientPool(cfg.ClientTLSConfig, logger, reg))
+	if err != nil {
+		return nil, err
+	}
+	ruler.queryFunc = queryFunc
+	ruler.alertHistory = alertHistory
+	ruler.federationConsents = federationConsents
+	ruler.federationAudit = federationAudit
+	return ruler, nil
 }
 
 func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits, clientPool ClientsPool) (*Ruler, error) {
@@ -275,6 +548,13 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		clientsPool:    clientPool,
 		allowedTenants: util.NewAllowedTenants(cfg.EnabledTenants, cfg.DisabledTenants),
 		metrics:        newRulerMetrics(reg),
+
+		onDemandLimiters:  map[string]*rate.Limiter{},
+		configAPILimiters: map[string]*rate.Limiter{},
+		poolRings:         map[string]*ring.Ring{},
+		polledRules:       map[string]polledUserRules{},
+
+		jsonnetEvaluations: semaphore.NewWeighted(int64(cfg.JsonnetImport.MaxConcurrentEvaluations)),
 	}
 
 	if len(cfg.EnabledTenants) > 0 {
@@ -298,6 +578,39 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		return nil, errors.Wrap(err, "setup ruler sharding ring")
 	}
 
+	if cfg.OTLPExport.Endpoint != "" {
+		gatherer, ok := reg.(prometheus.Gatherer)
+		if !ok {
+			return nil, errors.New("the ruler OTLP export requires a registerer which is also a gatherer")
+		}
+		ruler.otlpExporter, err = newOTLPExporter(cfg.OTLPExport, cfg.Ring.InstanceID, gatherer, logger, reg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Provisioning.Directory != "" {
+		if ruler.provisioner, err = newRulesProvisioner(cfg.Provisioning, ruleStore, ruler.ownsProvisionedTenant, logger, reg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.MetaMonitoring.Enabled {
+		if ruler.metaMonitoring, err = newMetaMonitoringInstaller(cfg.MetaMonitoring, cfg.PollInterval, ruleStore, logger, reg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.PrometheusRuleController.Enabled {
+		if ruler.prometheusRuleController, err = newPrometheusRuleController(cfg.PrometheusRuleController, ruleStore, ruler.validateSyncedRuleGroup, ruler.ownsPrometheusRuleController, logger, reg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ChangesWebhook.URL.URL != nil {
+		ruler.changesWebhook = newRulesChangesWebhook(cfg.ChangesWebhook, logger, reg)
+	}
+
 	ruler.Service = services.NewBasicService(ruler.starting, ruler.run, ruler.stopping)
 	return ruler, nil
 }
@@ -314,13 +627,13 @@ func enableSharding(r *Ruler, ringStore kv.Client) error {
 	delegate = ring.NewLeaveOnStoppingDelegate(delegate, r.logger)
 	delegate = ring.NewAutoForgetDelegate(r.cfg.Ring.HeartbeatTimeout*ringAutoForgetUnhealthyPeriods, delegate, r.logger)
 
-	rulerRingName := "ruler"
-	r.lifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, rulerRingName, RulerRingKey, ringStore, delegate, r.logger, prometheus.WrapRegistererWithPrefix("cortex_", r.registry))
+	r.ringStore = ringStore
+	r.lifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, ringNameForPool(r.cfg.Ring.Pool), ringKeyForPool(r.cfg.Ring.Pool), ringStore, delegate, r.logger, prometheus.WrapRegistererWithPrefix("cortex_", r.registry))
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize ruler's lifecycler")
 	}
 
-	r.ring, err = ring.NewWithStoreClientAndStrategy(r.cfg.Ring.ToRingConfig(), rulerRingName, RulerRingKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", r.registry), r.logger)
+	r.ring, err = r.newPoolRing(r.cfg.Ring.Pool)
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize ruler's ring")
 	}
@@ -328,10 +641,66 @@ func enableSharding(r *Ruler, ringStore kv.Client) error {
 	return nil
 }
 
+func (r *Ruler) newPoolRing(pool string) (*ring.Ring, error) {
+	return ring.NewWithStoreClientAndStrategy(r.cfg.Ring.ToRingConfig(), ringNameForPool(pool), ringKeyForPool(pool), r.ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", r.registry), r.logger)
+}
+
+// getPoolRing returns the ring of the rulers of the pool, starting it if it's the ring of another pool read for the first time.
+func (r *Ruler) getPoolRing(ctx context.Context, pool string) (*ring.Ring, error) {
+	if pool == r.cfg.Ring.Pool {
+		return r.ring, nil
+	}
+
+	r.poolRingsMtx.Lock()
+	defer r.poolRingsMtx.Unlock()
+
+	if poolRing, ok := r.poolRings[pool]; ok {
+		return poolRing, nil
+	}
+
+	poolRing, err := r.newPoolRing(pool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to initialize the ring of the ruler pool %s", pool)
+	}
+	if err := services.StartAndAwaitRunning(ctx, poolRing); err != nil {
+		return nil, errors.Wrapf(err, "failed to start the ring of the ruler pool %s", pool)
+	}
+	r.poolRings[pool] = poolRing
+	return poolRing, nil
+}
+
 func (r *Ruler) starting(ctx context.Context) error {
 	var err error
 
-	if r.subservices, err = services.NewManager(r.lifecycler, r.ring, r.clientsPool); err != nil {
+	// The ruler joins the ring only if it evaluates rule groups.
+	var subservices []services.Service
+	if r.cfg.EnableEvaluation {
+		subservices = append(subservices, r.lifecycler)
+	}
+	subservices = append(subservices, r.ring, r.clientsPool)
+	if r.otlpExporter != nil {
+		subservices = append(subservices, r.otlpExporter)
+	}
+	if r.alertHistory != nil {
+		subservices = append(subservices, r.alertHistory)
+	}
+	if r.federationAudit != nil {
+		subservices = append(subservices, r.federationAudit)
+	}
+	if r.provisioner != nil {
+		subservices = append(subservices, r.provisioner)
+	}
+	if r.metaMonitoring != nil {
+		subservices = append(subservices, r.metaMonitoring)
+	}
+	if r.prometheusRuleController != nil {
+		subservices = append(subservices, r.prometheusRuleController)
+	}
+	if r.changesWebhook != nil {
+		subservices = append(subservices, r.changesWebhook)
+	}
+
+	if r.subservices, err = services.NewManager(subservices...); err != nil {
 		return errors.Wrap(err, "unable to start ruler subservices")
 	}
 
@@ -342,6 +711,11 @@ func (r *Ruler) starting(ctx context.Context) error {
 		return errors.Wrap(err, "unable to start ruler subservices")
 	}
 
+	if !r.cfg.EnableEvaluation {
+		level.Info(r.logger).Log("msg", "evaluation of the rule groups disabled, not joining the ring")
+		return nil
+	}
+
 	// Wait until the ring client detected this instance in the ACTIVE state to
 	// make sure that when we'll run the initial sync we already know  the tokens
 	// assigned to this instance.
@@ -363,27 +737,61 @@ func (r *Ruler) stopping(_ error) error {
 	if r.subservices != nil {
 		_ = services.StopManagerAndAwaitStopped(context.Background(), r.subservices)
 	}
+
+	r.poolRingsMtx.Lock()
+	defer r.poolRingsMtx.Unlock()
+	for _, poolRing := range r.poolRings {
+		_ = services.StopAndAwaitTerminated(context.Background(), poolRing)
+	}
+	return nil
+}
+
+// ApplyNotifierConfig applies the Alertmanager notifier settings of cfg, the ruler configuration read again
+// while the ruler is running, without restarting the evaluation of the rules: the Alertmanager URLs and
+// their refresh interval, and the Alertmanager client settings. The other settings of cfg are ignored.
+func (r *Ruler) ApplyNotifierConfig(cfg Config) error {
+	if err := r.manager.ApplyNotifierConfig(cfg); err != nil {
+		return errors.Wrap(err, "failed to apply the Alertmanager notifier settings")
+	}
+	level.Info(r.logger).Log("msg", "applied the Alertmanager notifier settings", "alertmanager_url", cfg.AlertmanagerURL)
 	return nil
 }
 
+// WithNotifierConfig returns a copy of cfg with the Alertmanager notifier settings of other.
+func (cfg Config) WithNotifierConfig(other Config) Config {
+	cfg.AlertmanagerURL = other.AlertmanagerURL
+	cfg.AlertmanagerRefreshInterval = other.AlertmanagerRefreshInterval
+	cfg.Notifier = other.Notifier
+	return cfg
+}
+
 type sender interface {
 	Send(alerts ...*notifier.Alert)
 }
 
 // SendAlerts implements a rules.NotifyFunc for a Notifier.
-// It filters any non-firing alerts from the input.
+// It filters any non-firing alerts from the input. The generator URL of the alerts is prefixed
+// with the external URL returned by externalURL for the context of the rule group evaluation, and
+// their labels are transformed by alertLabels, unless nil.
 //
 // Copied from Prometheus's main.go.
-func SendAlerts(n sender, externalURL string) promRules.NotifyFunc {
+func SendAlerts(n sender, externalURL func(ctx context.Context) string, alertLabels func(ctx context.Context, lbls labels.Labels) labels.Labels) promRules.NotifyFunc {
 	return func(ctx context.Context, expr string, alerts ...*promRules.Alert) {
-		var res []*notifier.Alert
+		if len(alerts) == 0 {
+			return
+		}
 
+		var res []*notifier.Alert
+		generatorURL := externalURL(ctx) + strutil.TableLinkForExpression(expr)
 		for _, alert := range alerts {
 			a := &notifier.Alert{
 				StartsAt:     alert.FiredAt,
 				Labels:       alert.Labels,
 				Annotations:  alert.Annotations,
-				GeneratorURL: externalURL + strutil.TableLinkForExpression(expr),
+				GeneratorURL: generatorURL,
+			}
+			if alertLabels != nil {
+				a.Labels = alertLabels(ctx, alert.Labels)
 			}
 			if !alert.ResolvedAt.IsZero() {
 				a.EndsAt = alert.ResolvedAt
@@ -393,9 +801,7 @@ func SendAlerts(n sender, externalURL string) promRules.NotifyFunc {
 			res = append(res, a)
 		}
 
-		if len(alerts) > 0 {
-			n.Send(res...)
-		}
+		n.Send(res...)
 	}
 }
 
@@ -414,15 +820,86 @@ func tokenForGroup(g *rulespb.RuleGroupDesc) uint32 {
 	return ringHasher.Sum32()
 }
 
-func instanceOwnsRuleGroup(r ring.ReadRing, g *rulespb.RuleGroupDesc, instanceAddr string) (bool, error) {
+// ruleGroupReplicas returns the rulers evaluating the rule group, in the order of the ring, so that the first
+// one is the leader of the replicas of the rule group.
+func ruleGroupReplicas(r ring.ReadRing, g *rulespb.RuleGroupDesc) ([]ring.InstanceDesc, error) {
 	hash := tokenForGroup(g)
 
 	rlrs, err := r.Get(hash, RingOp, nil, nil, nil)
 	if err != nil {
-		return false, errors.Wrap(err, "error reading ring to verify rule group ownership")
+		return nil, errors.Wrap(err, "error reading ring to verify rule group ownership")
+	}
+	if len(rlrs.Instances) == 0 {
+		return nil, errors.New("no ruler owns the rule group")
 	}
+	return rlrs.Instances, nil
+}
 
-	return rlrs.Instances[0].Addr == instanceAddr, nil
+func instanceOwnsRuleGroup(r ring.ReadRing, g *rulespb.RuleGroupDesc, instanceAddr string) (bool, error) {
+	replicas, err := ruleGroupReplicas(r, g)
+	if err != nil {
+		return false, err
+	}
+
+	for _, replica := range replicas {
+		if replica.Addr == instanceAddr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// instanceLeadsRuleGroup returns whether the instance is the leader of the replicas of the rule group, which
+// is the only one writing the results of the rule group and sending its alerts.
+func instanceLeadsRuleGroup(r ring.ReadRing, g *rulespb.RuleGroupDesc, instanceAddr string) (bool, error) {
+	replicas, err := ruleGroupReplicas(r, g)
+	if err != nil {
+		return false, err
+	}
+	return replicas[0].Addr == instanceAddr, nil
+}
+
+// prometheusRuleControllerRingKey is the ring key of the ruler syncing the PrometheusRule resources.
+var prometheusRuleControllerRingKey = func() uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("prometheus-rule-controller"))
+	return h.Sum32()
+}()
+
+// ownsPrometheusRuleController returns whether the ruler is the one syncing the PrometheusRule resources, so
+// that a single ruler writes their rule groups and reports their status: the first ruler owning a fixed key in
+// the ring of the default pool.
+func (r *Ruler) ownsPrometheusRuleController() (bool, error) {
+	owned, err := r.ownsRingKey(prometheusRuleControllerRingKey)
+	return owned, errors.Wrap(err, "error reading ring to verify the PrometheusRule controller ownership")
+}
+
+// ownsProvisionedTenant returns whether the ruler is the one reconciling the provisioned rule groups of the
+// tenant, so that a single ruler writes them and reports their drift: the first ruler owning the key of the
+// tenant in the ring of the default pool.
+func (r *Ruler) ownsProvisionedTenant(userID string) (bool, error) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("rules-provisioner/" + userID))
+
+	owned, err := r.ownsRingKey(h.Sum32())
+	return owned, errors.Wrapf(err, "error reading ring to verify the provisioned rule groups ownership of user %s", userID)
+}
+
+// ownsRingKey returns whether the ruler is the first ruler owning the key in the ring of the default pool.
+// The rulers of the other pools, and the rulers with the evaluation disabled, don't own any key.
+func (r *Ruler) ownsRingKey(key uint32) (bool, error) {
+	if r.cfg.Ring.Pool != "" || !r.cfg.EnableEvaluation {
+		return false, nil
+	}
+
+	rs, err := r.ring.Get(key, RingOp, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(rs.Instances) == 0 {
+		return false, nil
+	}
+	return rs.Instances[0].Addr == r.lifecycler.GetInstanceAddr(), nil
 }
 
 func (r *Ruler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -432,6 +909,15 @@ func (r *Ruler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (r *Ruler) run(ctx context.Context) error {
 	level.Info(r.logger).Log("msg", "ruler up and running")
 
+	if !r.cfg.EnableEvaluation {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-r.subservicesWatcher.Chan():
+			return errors.Wrap(err, "ruler subservice failed")
+		}
+	}
+
 	tick := time.NewTicker(r.cfg.PollInterval)
 	defer tick.Stop()
 
@@ -440,12 +926,14 @@ func (r *Ruler) run(ctx context.Context) error {
 	defer ringTicker.Stop()
 
 	r.syncRules(ctx, rulerSyncReasonInitial)
+	tick.Reset(r.pollCheckInterval())
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-tick.C:
 			r.syncRules(ctx, rulerSyncReasonPeriodic)
+			tick.Reset(r.pollCheckInterval())
 		case <-ringTicker.C:
 			// We ignore the error because in case of error it will return an empty
 			// replication set which we use to compare with the previous state.
@@ -462,82 +950,152 @@ func (r *Ruler) run(ctx context.Context) error {
 }
 
 func (r *Ruler) syncRules(ctx context.Context, reason string) {
-	level.Debug(r.logger).Log("msg", "syncing rules", "reason", reason)
+	logger, ctx := spanlogger.NewWithLogger(ctx, r.logger, "ruler.syncRules")
+	defer logger.Span.Finish()
+	logger.Span.SetTag("reason", reason)
+
+	level.Debug(logger).Log("msg", "syncing rules", "reason", reason)
 	r.metrics.rulerSync.WithLabelValues(reason).Inc()
+	start := time.Now()
+	defer func() {
+		r.metrics.syncDuration.Observe(time.Since(start).Seconds())
+	}()
 
-	configs, err := r.listRules(ctx)
+	r.polledRulesMtx.Lock()
+	defer r.polledRulesMtx.Unlock()
+
+	// The periodic syncs poll the rule storage only for the users whose poll interval elapsed, while
+	// the other syncs poll it for all the users.
+	now := time.Now()
+	var poll func(userID string) bool
+	if reason == rulerSyncReasonPeriodic {
+		poll = func(userID string) bool {
+			return r.pollDue(userID, now)
+		}
+	}
+
+	configs, users, err := r.listRulesPolled(ctx, poll)
 	if err != nil {
-		level.Error(r.logger).Log("msg", "unable to list rules", "err", err)
+		r.metrics.syncFailures.Inc()
+		level.Error(logger).Log("msg", "unable to list rules", "err", err)
 		return
 	}
+	logger.Span.LogKV("event", "rules listed", "tenants", len(configs))
 
-	err = r.loadRuleGroups(ctx, configs)
+	broken, err := r.loadRuleGroups(ctx, configs)
 	if err != nil {
-		level.Error(r.logger).Log("msg", "unable to load rules owned by this ruler", "err", err)
+		r.metrics.syncFailures.Inc()
+		level.Error(logger).Log("msg", "unable to load rules owned by this ruler", "err", err)
 		return
 	}
+	broken = r.updatePolledRules(configs, broken, users, now)
+	r.quarantineRuleGroups(configs, broken)
+	logger.Span.LogKV("event", "rules loaded", "broken_rule_groups", len(broken))
+
+	// The rule groups led by another ruler are set before they're loaded, so that they're never written twice.
+	r.manager.SetFollowedRuleGroups(r.followedRuleGroups(configs))
 
 	// This will also delete local group files for users that are no longer in 'configs' map.
 	r.manager.SyncRuleGroups(ctx, configs)
+	r.rulesSynced.Store(true)
+	logger.Span.LogKV("event", "rules synced")
+
+	polled := 0
+	for _, p := range users {
+		if p {
+			polled++
+		}
+	}
+	r.metrics.syncPolledTenants.Set(float64(polled))
+	r.metrics.syncLastSuccess.SetToCurrentTime()
 }
 
-func (r *Ruler) loadRuleGroups(ctx context.Context, configs map[string]rulespb.RuleGroupList) error {
+// CheckReady returns an error if the ruler is configured to be ready only after its first sync of the
+// rule groups, and the sync hasn't completed yet.
+func (r *Ruler) CheckReady(_ context.Context) error {
+	if !r.cfg.ReadyAfterInitialSync || !r.cfg.EnableEvaluation {
+		return nil
+	}
+	if !r.rulesSynced.Load() {
+		return errors.New("the initial sync of the rule groups hasn't completed")
+	}
+	return nil
+}
+
+// loadRuleGroups loads the rules of the rule groups in configs, and returns the rule groups which can't be loaded.
+func (r *Ruler) loadRuleGroups(ctx context.Context, configs map[string]rulespb.RuleGroupList) ([]rulestore.BrokenRuleGroup, error) {
 	start := time.Now()
 	defer func() {
 		r.metrics.loadRuleGroups.Observe(time.Since(start).Seconds())
 	}()
-	return r.store.LoadRuleGroups(ctx, configs)
+
+	// The broken rule groups are skipped, instead of failing the sync of all the rule groups.
+	if err := r.store.LoadRuleGroups(ctx, configs); err != nil {
+		var brokenErr *rulestore.BrokenRuleGroupsError
+		if !errors.As(err, &brokenErr) {
+			return nil, err
+		}
+		return brokenErr.Groups, nil
+	}
+	return nil, nil
+}
+
+func (r *Ruler) listRules(ctx context.Context) (map[string]rulespb.RuleGroupList, error) {
+	result, _, err := r.listRulesPolled(ctx, nil)
+	return result, err
 }
 
-func (r *Ruler) listRules(ctx context.Context) (result map[string]rulespb.RuleGroupList, err error) {
+// listRulesPolled lists the rule groups owned by this ruler of the users for which poll returns true, or of
+// all the users if poll is nil. It also returns the users whose rule groups this ruler may own, and whether
+// they were polled.
+func (r *Ruler) listRulesPolled(ctx context.Context, poll func(userID string) bool) (map[string]rulespb.RuleGroupList, map[string]bool, error) {
 	start := time.Now()
 	defer func() {
 		r.metrics.listRules.Observe(time.Since(start).Seconds())
 	}()
 
-	result, err = r.listRulesSharded(ctx)
-	if err != nil {
-		return
-	}
-
-	for userID := range result {
-		if !r.allowedTenants.IsAllowed(userID) {
-			level.Debug(r.logger).Log("msg", "ignoring rule groups for user, not allowed", "user", userID)
-			delete(result, userID)
-		}
-	}
-	return
+	return r.listRulesSharded(ctx, poll)
 }
 
-func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGroupList, error) {
+func (r *Ruler) listRulesSharded(ctx context.Context, poll func(userID string) bool) (map[string]rulespb.RuleGroupList, map[string]bool, error) {
 	users, err := r.store.ListAllUsers(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to list users of ruler")
+		return nil, nil, errors.Wrap(err, "unable to list users of ruler")
 	}
 
 	// Only users in userRings will be used in the to load the rules.
 	userRings := map[string]ring.ReadRing{}
+	polled := map[string]bool{}
 	for _, u := range users {
-		if shardSize := r.limits.RulerTenantShardSize(u); shardSize > 0 {
-			subRing := r.ring.ShuffleShard(u, shardSize)
+		if !r.allowedTenants.IsAllowed(u) {
+			level.Debug(r.logger).Log("msg", "ignoring rule groups for user, not allowed", "user", u)
+			continue
+		}
 
-			// Include the user only if it belongs to this ruler shard.
-			if subRing.HasInstance(r.lifecycler.GetInstanceID()) {
-				userRings[u] = subRing
-			}
-		} else {
-			// A shard size of 0 means shuffle sharding is disabled for this specific user.
-			// In that case we use the full ring so that rule groups will be sharded across all rulers.
-			userRings[u] = r.ring
+		// The rule groups of the user are evaluated by the rulers of its pool.
+		if r.limits.RulerEvaluationPool(u) != r.cfg.Ring.Pool {
+			continue
 		}
-	}
 
-	if len(userRings) == 0 {
-		return nil, nil
+		// Include the user only if it belongs to this ruler shard.
+		if userRing := r.userRing(u); userRing.HasInstance(r.lifecycler.GetInstanceID()) {
+			userRings[u] = userRing
+		}
 	}
 
-	userCh := make(chan string, len(userRings))
+	var polledUsers []string
 	for u := range userRings {
+		polled[u] = poll == nil || poll(u)
+		if polled[u] {
+			polledUsers = append(polledUsers, u)
+		}
+	}
+	if len(polledUsers) == 0 {
+		return map[string]rulespb.RuleGroupList{}, polled, nil
+	}
+
+	userCh := make(chan string, len(polledUsers))
+	for _, u := range polledUsers {
 		userCh <- u
 	}
 	close(userCh)
@@ -546,8 +1104,8 @@ func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGr
 	result := map[string]rulespb.RuleGroupList{}
 
 	concurrency := loadRulesConcurrency
-	if len(userRings) < concurrency {
-		concurrency = len(userRings)
+	if len(polledUsers) < concurrency {
+		concurrency = len(polledUsers)
 	}
 
 	g, gctx := errgroup.WithContext(ctx)
@@ -573,7 +1131,44 @@ func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGr
 	}
 
 	err = g.Wait()
-	return result, err
+	return result, polled, err
+}
+
+// userRing returns the ring of the rulers evaluating the rule groups of the user.
+func (r *Ruler) userRing(userID string) ring.ReadRing {
+	if shardSize := r.limits.RulerTenantShardSize(userID); shardSize > 0 {
+		return r.ring.ShuffleShard(userID, shardSize)
+	}
+
+	// A shard size of 0 means shuffle sharding is disabled for this specific user.
+	// In that case we use the full ring so that rule groups will be sharded across all rulers.
+	return r.ring
+}
+
+// followedRuleGroups returns the rule groups of configs whose leader is another ruler. This ruler evaluates
+// them without writing their results or sending their alerts. The rule groups whose leader can't be checked
+// are considered led by this ruler, so that their results are written at least once.
+func (r *Ruler) followedRuleGroups(configs map[string]rulespb.RuleGroupList) map[string]rulespb.RuleGroupList {
+	followed := map[string]rulespb.RuleGroupList{}
+	if r.cfg.Ring.ReplicationFactor <= 1 {
+		return followed
+	}
+
+	for userID, groups := range configs {
+		userRing := r.userRing(userID)
+		for _, g := range groups {
+			leader, err := instanceLeadsRuleGroup(userRing, g, r.lifecycler.GetInstanceAddr())
+			if err != nil {
+				r.metrics.ringCheckErrors.Inc()
+				level.Error(r.logger).Log("msg", "failed to check if the ruler replica leads the rule group", "user", userID, "namespace", g.Namespace, "group", g.Name, "err", err)
+				continue
+			}
+			if !leader {
+				followed[userID] = append(followed[userID], g)
+			}
+		}
+	}
+	return followed
 }
 
 // filterRuleGroups returns map of rule groups that given instance "owns" based on supplied ring.
@@ -610,10 +1205,15 @@ func (r *Ruler) GetRules(ctx context.Context) ([]*GroupStateDesc, error) {
 		return nil, fmt.Errorf("no user id found in context")
 	}
 
-	ring := ring.ReadRing(r.ring)
+	// The rule groups of the user are evaluated by the rulers of its pool.
+	poolRing, err := r.getPoolRing(ctx, r.limits.RulerEvaluationPool(userID))
+	if err != nil {
+		return nil, err
+	}
+	ring := ring.ReadRing(poolRing)
 
 	if shardSize := r.limits.RulerTenantShardSize(userID); shardSize > 0 {
-		ring = r.ring.ShuffleShard(userID, shardSize)
+		ring = poolRing.ShuffleShard(userID, shardSize)
 	}
 
 	rulers, err := ring.GetReplicationSetForOperation(RingOp)
@@ -631,8 +1231,8 @@ func (r *Ruler) GetRules(ctx context.Context) ([]*GroupStateDesc, error) {
 		merged   []*GroupStateDesc
 	)
 
-	// Concurrently fetch rules from all rulers. Since rules are not replicated,
-	// we need all requests to succeed.
+	// Concurrently fetch rules from all rulers. The rule groups are sharded between
+	// the rulers, so we need all requests to succeed.
 	addrs := rulers.GetAddresses()
 	err = concurrency.ForEachJob(ctx, len(addrs), len(addrs), func(ctx context.Context, idx int) error {
 		addr := addrs[idx]
@@ -654,6 +1254,9 @@ func (r *Ruler) GetRules(ctx context.Context) ([]*GroupStateDesc, error) {
 		return nil
 	})
 
+	if r.cfg.Ring.ReplicationFactor > 1 {
+		merged = dedupeReplicatedGroupStates(merged)
+	}
 	return merged, err
 }
 
@@ -679,87 +1282,125 @@ func (r *Ruler) getLocalRules(userID string) ([]*GroupStateDesc, error) {
 	prefix := filepath.Join(r.cfg.RulePath, userID) + "/"
 
 	for _, group := range groups {
-		interval := group.Interval()
-
 		// The mapped filename is url path escaped encoded to make handling `/` characters easier
 		decodedNamespace, err := url.PathUnescape(strings.TrimPrefix(group.File(), prefix))
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to decode rule filename")
 		}
 
-		groupDesc := &GroupStateDesc{
-			Group: &rulespb.RuleGroupDesc{
-				Name:          group.Name(),
-				Namespace:     decodedNamespace,
-				Interval:      interval,
-				User:          userID,
-				SourceTenants: group.SourceTenants(),
-			},
-
-			EvaluationTimestamp: group.GetLastEvaluation(),
-			EvaluationDuration:  group.GetEvaluationTime(),
+		groupDesc, err := newGroupStateDesc(userID, decodedNamespace, group)
+		if err != nil {
+			return nil, err
 		}
-		for _, r := range group.Rules() {
-			lastError := ""
-			if r.LastError() != nil {
-				lastError = r.LastError().Error()
-			}
-
-			var ruleDesc *RuleStateDesc
-			switch rule := r.(type) {
-			case *promRules.AlertingRule:
-				rule.ActiveAlerts()
-				alerts := []*AlertStateDesc{}
-				for _, a := range rule.ActiveAlerts() {
-					alerts = append(alerts, &AlertStateDesc{
-						State:       a.State.String(),
-						Labels:      mimirpb.FromLabelsToLabelAdapters(a.Labels),
-						Annotations: mimirpb.FromLabelsToLabelAdapters(a.Annotations),
-						Value:       a.Value,
-						ActiveAt:    a.ActiveAt,
-						FiredAt:     a.FiredAt,
-						ResolvedAt:  a.ResolvedAt,
-						LastSentAt:  a.LastSentAt,
-						ValidUntil:  a.ValidUntil,
-					})
-				}
-				ruleDesc = &RuleStateDesc{
-					Rule: &rulespb.RuleDesc{
-						Expr:        rule.Query().String(),
-						Alert:       rule.Name(),
-						For:         rule.HoldDuration(),
-						Labels:      mimirpb.FromLabelsToLabelAdapters(rule.Labels()),
-						Annotations: mimirpb.FromLabelsToLabelAdapters(rule.Annotations()),
-					},
-					State:               rule.State().String(),
-					Health:              string(rule.Health()),
-					LastError:           lastError,
-					Alerts:              alerts,
-					EvaluationTimestamp: rule.GetEvaluationTimestamp(),
-					EvaluationDuration:  rule.GetEvaluationDuration(),
+		if desc := r.manager.GetRuleGroupDesc(userID, decodedNamespace, group.Name()); desc != nil {
+			groupDesc.Group.UpdatedAt = desc.UpdatedAt
+			// The rule intervals aren't part of the rule files loaded by the Prometheus rules manager.
+			if len(desc.Rules) == len(groupDesc.ActiveRules) {
+				for i, rl := range desc.Rules {
+					groupDesc.ActiveRules[i].Rule.Interval = rl.Interval
 				}
-			case *promRules.RecordingRule:
-				ruleDesc = &RuleStateDesc{
-					Rule: &rulespb.RuleDesc{
-						Record: rule.Name(),
-						Expr:   rule.Query().String(),
-						Labels: mimirpb.FromLabelsToLabelAdapters(rule.Labels()),
-					},
-					Health:              string(rule.Health()),
-					LastError:           lastError,
-					EvaluationTimestamp: rule.GetEvaluationTimestamp(),
-					EvaluationDuration:  rule.GetEvaluationDuration(),
-				}
-			default:
-				return nil, errors.Errorf("failed to assert type of rule '%v'", rule.Name())
 			}
-			groupDesc.ActiveRules = append(groupDesc.ActiveRules, ruleDesc)
+		}
+		groupDesc.MissedIterations = r.manager.GetMissedIterations(userID, group)
+		if dropped := r.manager.GetDroppedAlerts(userID, group); len(dropped) == len(groupDesc.ActiveRules) {
+			for i, n := range dropped {
+				groupDesc.ActiveRules[i].DroppedAlerts = n
+			}
 		}
 		groupDescs = append(groupDescs, groupDesc)
 	}
 	return groupDescs, nil
 }
 
+// newGroupStateDesc returns the state of the rules of the input group.
+func newGroupStateDesc(userID, namespace string, group *promRules.Group) (*GroupStateDesc, error) {
+	groupDesc := &GroupStateDesc{
+		Group: &rulespb.RuleGroupDesc{
+			Name:          group.Name(),
+			Namespace:     namespace,
+			Interval:      group.Interval(),
+			Limit:         int64(group.Limit()),
+			User:          userID,
+			SourceTenants: group.SourceTenants(),
+		},
+
+		EvaluationTimestamp: group.GetLastEvaluation(),
+		EvaluationDuration:  group.GetEvaluationTime(),
+	}
+
+	// The states of the rules are allocated at once, because groups can have many rules.
+	rules := group.Rules()
+	ruleStates := make([]RuleStateDesc, len(rules))
+	ruleDescs := make([]rulespb.RuleDesc, len(rules))
+	groupDesc.ActiveRules = make([]*RuleStateDesc, 0, len(rules))
+
+	for i, r := range rules {
+		lastError := ""
+		if r.LastError() != nil {
+			lastError = r.LastError().Error()
+		}
+
+		ruleDesc := &ruleStates[i]
+		switch rule := r.(type) {
+		case *promRules.AlertingRule:
+			// The active alerts are kept in a map by the rule, so they're sorted to be returned in a stable order.
+			activeAlerts := rule.ActiveAlerts()
+			sort.Slice(activeAlerts, func(i, j int) bool {
+				return labels.Compare(activeAlerts[i].Labels, activeAlerts[j].Labels) < 0
+			})
+			alertStates := make([]AlertStateDesc, len(activeAlerts))
+			alerts := make([]*AlertStateDesc, 0, len(activeAlerts))
+			for j, a := range activeAlerts {
+				alertStates[j] = AlertStateDesc{
+					State:       a.State.String(),
+					Labels:      mimirpb.FromLabelsToLabelAdapters(a.Labels),
+					Annotations: mimirpb.FromLabelsToLabelAdapters(a.Annotations),
+					Value:       a.Value,
+					ActiveAt:    a.ActiveAt,
+					FiredAt:     a.FiredAt,
+					ResolvedAt:  a.ResolvedAt,
+					LastSentAt:  a.LastSentAt,
+					ValidUntil:  a.ValidUntil,
+				}
+				alerts = append(alerts, &alertStates[j])
+			}
+			ruleDescs[i] = rulespb.RuleDesc{
+				Expr:        rule.Query().String(),
+				Alert:       rule.Name(),
+				For:         rule.HoldDuration(),
+				Labels:      mimirpb.FromLabelsToLabelAdapters(rule.Labels()),
+				Annotations: mimirpb.FromLabelsToLabelAdapters(rule.Annotations()),
+			}
+			*ruleDesc = RuleStateDesc{
+				Rule:                &ruleDescs[i],
+				State:               rule.State().String(),
+				Health:              string(rule.Health()),
+				LastError:           lastError,
+				Alerts:              alerts,
+				EvaluationTimestamp: rule.GetEvaluationTimestamp(),
+				EvaluationDuration:  rule.GetEvaluationDuration(),
+			}
+		case *promRules.RecordingRule:
+			ruleDescs[i] = rulespb.RuleDesc{
+				Record: rule.Name(),
+				Expr:   rule.Query().String(),
+				Labels: mimirpb.FromLabelsToLabelAdapters(rule.Labels()),
+			}
+			*ruleDesc = RuleStateDesc{
+				Rule:                &ruleDescs[i],
+				Health:              string(rule.Health()),
+				LastError:           lastError,
+				EvaluationTimestamp: rule.GetEvaluationTimestamp(),
+				EvaluationDuration:  rule.GetEvaluationDuration(),
+			}
+		default:
+			return nil, errors.Errorf("failed to assert type of rule '%v'", rule.Name())
+		}
+		groupDesc.ActiveRules = append(groupDesc.ActiveRules, ruleDesc)
+	}
+	return groupDesc, nil
+}
+
 // AssertMaxRuleGroups limit has not been reached compared to the current
 // number of total rule groups in input and returns an error if so.
 func (r *Ruler) AssertMaxRuleGroups(userID string, rg int) error {
@@ -791,6 +1432,29 @@ func (r *Ruler) AssertMaxRulesPerRuleGroup(userID string, rules int) error {
 	return fmt.Errorf(errMaxRulesPerRuleGroupPerUserLimitExceeded, limit, rules)
 }
 
+// AssertMaxRecordingRuleLabels checks that none of the recording rules of the rule group adds a reserved
+// label, or more labels than the limit, and returns an error if so.
+func (r *Ruler) AssertMaxRecordingRuleLabels(userID string, rg rulefmt.RuleGroup) error {
+	limit := r.limits.RulerMaxRecordingRuleLabels(userID)
+
+	for _, rule := range rg.Rules {
+		if rule.Record.Value == "" {
+			continue
+		}
+
+		for name := range rule.Labels {
+			if strings.HasPrefix(name, reservedLabelPrefix) {
+				return fmt.Errorf(errReservedRecordingRuleLabel, rule.Record.Value, name)
+			}
+		}
+
+		if limit > 0 && len(rule.Labels) > limit {
+			return fmt.Errorf(errMaxRecordingRuleLabelsLimitExceeded, limit, len(rule.Labels), rule.Record.Value)
+		}
+	}
+	return nil
+}
+
 func (r *Ruler) DeleteTenantConfiguration(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), r.logger)
 
@@ -837,9 +1501,13 @@ func (r *Ruler) ListAllRules(w http.ResponseWriter, req *http.Request) {
 		}
 		userRules := map[string]rulespb.RuleGroupList{userID: rg}
 		if err := r.store.LoadRuleGroups(ctx, userRules); err != nil {
-			return errors.Wrapf(err, "failed to load ruler config for user %s", userID)
+			var brokenErr *rulestore.BrokenRuleGroupsError
+			if !errors.As(err, &brokenErr) {
+				return errors.Wrapf(err, "failed to load ruler config for user %s", userID)
+			}
+			userRules[userID] = withoutBrokenRuleGroups(userID, userRules[userID], brokenRuleGroupKeys(brokenErr.Groups))
 		}
-		data := map[string]map[string][]rulefmt.RuleGroup{userID: userRules[userID].Formatted()}
+		data := map[string]map[string][]rulespb.RuleGroup{userID: userRules[userID].Formatted()}
 
 		select {
 		case iter <- data:
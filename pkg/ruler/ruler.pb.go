@@ -122,6 +122,7 @@ type GroupStateDesc struct {
 	ActiveRules         []*RuleStateDesc       `protobuf:"bytes,2,rep,name=active_rules,json=activeRules,proto3" json:"active_rules,omitempty"`
 	EvaluationTimestamp time.Time              `protobuf:"bytes,3,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration          `protobuf:"bytes,4,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	MissedIterations    int64                  `protobuf:"varint,5,opt,name=missedIterations,proto3" json:"missedIterations,omitempty"`
 }
 
 func (m *GroupStateDesc) Reset()      { *m = GroupStateDesc{} }
@@ -184,6 +185,13 @@ func (m *GroupStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *GroupStateDesc) GetMissedIterations() int64 {
+	if m != nil {
+		return m.MissedIterations
+	}
+	return 0
+}
+
 // RuleStateDesc is a proto representation of a Prometheus Rule
 type RuleStateDesc struct {
 	Rule                *rulespb.RuleDesc `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
@@ -193,6 +201,9 @@ type RuleStateDesc struct {
 	Alerts              []*AlertStateDesc `protobuf:"bytes,5,rep,name=alerts,proto3" json:"alerts,omitempty"`
 	EvaluationTimestamp time.Time         `protobuf:"bytes,6,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration     `protobuf:"bytes,7,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	// Number of alerts of an alerting rule dropped at its latest evaluation, because it exceeded
+	// the maximum number of alerts per rule of the tenant.
+	DroppedAlerts int64 `protobuf:"varint,8,opt,name=droppedAlerts,proto3" json:"droppedAlerts,omitempty"`
 }
 
 func (m *RuleStateDesc) Reset()      { *m = RuleStateDesc{} }
@@ -276,6 +287,13 @@ func (m *RuleStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *RuleStateDesc) GetDroppedAlerts() int64 {
+	if m != nil {
+		return m.DroppedAlerts
+	}
+	return 0
+}
+
 type AlertStateDesc struct {
 	State       string                                              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
 	Labels      []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,2,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"labels"`
@@ -380,50 +398,52 @@ func init() {
 func init() { proto.RegisterFile("ruler.proto", fileDescriptor_9ecbec0a4cfddea6) }
 
 var fileDescriptor_9ecbec0a4cfddea6 = []byte{
-	// 682 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xcd, 0x6e, 0x13, 0x3b,
-	0x14, 0x1e, 0xa7, 0x4d, 0x9a, 0x38, 0xbd, 0xbd, 0x92, 0x9b, 0x7b, 0x35, 0x44, 0xc8, 0xa9, 0xc2,
-	0xa6, 0x42, 0xea, 0x04, 0x4a, 0x05, 0x62, 0x01, 0x28, 0x55, 0x0b, 0x1b, 0x16, 0x68, 0x0a, 0x6c,
-	0x2b, 0x4f, 0xe2, 0x4c, 0x47, 0x4c, 0xc6, 0x83, 0xed, 0x89, 0x58, 0xf2, 0x08, 0x5d, 0xb2, 0x66,
-	0xc5, 0xa3, 0x74, 0xd9, 0x65, 0x85, 0x50, 0xa1, 0xd3, 0x0d, 0xcb, 0x3e, 0x02, 0xf2, 0xf1, 0x84,
-	0x24, 0x50, 0x10, 0x11, 0xea, 0x26, 0xf1, 0xf9, 0xf9, 0xbe, 0x73, 0xce, 0x77, 0xc6, 0xc6, 0x75,
-	0x99, 0xc5, 0x5c, 0x7a, 0xa9, 0x14, 0x5a, 0x90, 0x32, 0x18, 0xcd, 0x8d, 0x30, 0xd2, 0x07, 0x59,
-	0xe0, 0xf5, 0xc4, 0xb0, 0x13, 0x8a, 0x50, 0x74, 0x20, 0x1a, 0x64, 0x03, 0xb0, 0xc0, 0x80, 0x93,
-	0x45, 0x35, 0x69, 0x28, 0x44, 0x18, 0xf3, 0x49, 0x56, 0x3f, 0x93, 0x4c, 0x47, 0x22, 0x29, 0xe2,
-	0xad, 0x1f, 0xe3, 0x3a, 0x1a, 0x72, 0xa5, 0xd9, 0x30, 0x2d, 0x12, 0x6e, 0x4d, 0xd7, 0x93, 0x6c,
-	0xc0, 0x12, 0xd6, 0x19, 0x46, 0xc3, 0x48, 0x76, 0xd2, 0x57, 0xa1, 0x3d, 0xa5, 0x81, 0xfd, 0x2f,
-	0x10, 0x77, 0x7f, 0x8b, 0x80, 0x29, 0xe0, 0x57, 0xa5, 0x81, 0xfd, 0xb7, 0xb8, 0xf6, 0x0a, 0x5e,
-	0xf6, 0x8d, 0xe9, 0xf3, 0xd7, 0x19, 0x57, 0xba, 0xfd, 0x10, 0xff, 0x53, 0xd8, 0x2a, 0x15, 0x89,
-	0xe2, 0x64, 0x03, 0x57, 0x42, 0x29, 0xb2, 0x54, 0xb9, 0x68, 0x6d, 0x61, 0xbd, 0xbe, 0xf9, 0x9f,
-	0x67, 0xf5, 0x79, 0x62, 0x9c, 0x7b, 0x9a, 0x69, 0xbe, 0xc3, 0x55, 0xcf, 0x2f, 0x92, 0xda, 0xef,
-	0x4b, 0x78, 0x65, 0x36, 0x44, 0x6e, 0xe2, 0x32, 0x04, 0x5d, 0xb4, 0x86, 0xd6, 0xeb, 0x9b, 0x0d,
-	0xcf, 0xd6, 0x37, 0x65, 0x20, 0x13, 0xf0, 0x36, 0x85, 0xdc, 0xc3, 0xcb, 0xac, 0xa7, 0xa3, 0x11,
-	0xdf, 0x87, 0x24, 0xb7, 0x04, 0x35, 0x1b, 0x45, 0x4d, 0x03, 0x99, 0x94, 0xac, 0xdb, 0x4c, 0x68,
-	0x97, 0xbc, 0xc4, 0xab, 0x7c, 0xc4, 0xe2, 0x0c, 0x64, 0x7e, 0x3e, 0x96, 0xd3, 0x5d, 0x80, 0x92,
-	0x4d, 0xcf, 0x0a, 0xee, 0x8d, 0x05, 0xf7, 0xbe, 0x67, 0x6c, 0x57, 0x8f, 0x4e, 0x5b, 0xce, 0xe1,
-	0xe7, 0x16, 0xf2, 0x2f, 0x23, 0x20, 0x7b, 0x98, 0x4c, 0xdc, 0x3b, 0xc5, 0x1a, 0xdd, 0x45, 0xa0,
-	0xbd, 0xf6, 0x13, 0xed, 0x38, 0xc1, 0xb2, 0xbe, 0x33, 0xac, 0x97, 0xc0, 0xdb, 0x9f, 0x4a, 0x56,
-	0xe5, 0x89, 0x46, 0x37, 0xf0, 0xa2, 0x19, 0xb1, 0x90, 0xe8, 0xdf, 0x29, 0x89, 0x60, 0x54, 0x08,
-	0x92, 0x06, 0x2e, 0x2b, 0x83, 0x70, 0x4b, 0x6b, 0x68, 0xbd, 0xe6, 0x5b, 0x83, 0xfc, 0x8f, 0x2b,
-	0x07, 0x9c, 0xc5, 0xfa, 0x00, 0x86, 0xad, 0xf9, 0x85, 0x45, 0xae, 0xe3, 0x5a, 0xcc, 0x94, 0xde,
-	0x95, 0x52, 0x48, 0x68, 0xb8, 0xe6, 0x4f, 0x1c, 0x66, 0xad, 0x2c, 0xe6, 0x52, 0x2b, 0xb7, 0x3c,
-	0xb3, 0xd6, 0xae, 0x71, 0x4e, 0xad, 0xd5, 0x26, 0xfd, 0x4a, 0xde, 0xca, 0xd5, 0xc8, 0xbb, 0xf4,
-	0x77, 0xf2, 0x5e, 0x2c, 0xe2, 0x95, 0xd9, 0x39, 0x26, 0xd2, 0xa1, 0x69, 0xe9, 0x06, 0xb8, 0x12,
-	0xb3, 0x80, 0xc7, 0xe3, 0xef, 0x6c, 0xd5, 0xeb, 0x09, 0xa9, 0xf9, 0x9b, 0x34, 0xf0, 0x9e, 0x1a,
-	0xff, 0x33, 0x16, 0xc9, 0xed, 0xfb, 0xa6, 0xd6, 0xc7, 0xd3, 0xd6, 0xed, 0x3f, 0xb9, 0x93, 0x16,
-	0xd7, 0xed, 0xb3, 0x54, 0x73, 0xe9, 0x17, 0xec, 0x24, 0xc5, 0x75, 0x96, 0x24, 0x42, 0x43, 0x7b,
-	0xca, 0x5d, 0xb8, 0x92, 0x62, 0xd3, 0x25, 0xcc, 0xbc, 0x46, 0x17, 0x0e, 0x8b, 0x47, 0xbe, 0x35,
-	0x48, 0x17, 0xd7, 0x8a, 0xdb, 0xc5, 0xb4, 0x5b, 0x9e, 0x63, 0x77, 0x55, 0x0b, 0xeb, 0x6a, 0xf2,
-	0x08, 0x57, 0x07, 0x91, 0xe4, 0x7d, 0xc3, 0x30, 0xcf, 0xf6, 0x97, 0x00, 0xd5, 0xd5, 0x64, 0x17,
-	0xd7, 0x25, 0x57, 0x22, 0x1e, 0x59, 0x8e, 0xa5, 0x39, 0x38, 0xf0, 0x18, 0xd8, 0xd5, 0xe4, 0x31,
-	0x5e, 0x36, 0x1f, 0xf3, 0xbe, 0xe2, 0x89, 0x36, 0x3c, 0xd5, 0x79, 0x78, 0x0c, 0x72, 0x8f, 0x27,
-	0xda, 0xb6, 0x33, 0x62, 0x71, 0xd4, 0xdf, 0xcf, 0x12, 0x1d, 0xc5, 0x6e, 0x6d, 0x1e, 0x1a, 0x00,
-	0xbe, 0x30, 0xb8, 0xcd, 0x07, 0xb8, 0x6c, 0x2e, 0xab, 0x24, 0x5b, 0xf6, 0xa0, 0xc8, 0xea, 0xd4,
-	0x9b, 0x35, 0x7e, 0x5d, 0x9b, 0x8d, 0x59, 0xa7, 0x7d, 0x62, 0xdb, 0xce, 0xf6, 0xd6, 0xf1, 0x19,
-	0x75, 0x4e, 0xce, 0xa8, 0x73, 0x71, 0x46, 0xd1, 0xdb, 0x9c, 0xa2, 0x0f, 0x39, 0x45, 0x47, 0x39,
-	0x45, 0xc7, 0x39, 0x45, 0x5f, 0x72, 0x8a, 0xbe, 0xe6, 0xd4, 0xb9, 0xc8, 0x29, 0x3a, 0x3c, 0xa7,
-	0xce, 0xf1, 0x39, 0x75, 0x4e, 0xce, 0xa9, 0x13, 0x54, 0xa0, 0xbd, 0x3b, 0xdf, 0x02, 0x00, 0x00,
-	0xff, 0xff, 0x0c, 0x0b, 0x21, 0x72, 0xb2, 0x06, 0x00, 0x00,
+	// 720 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0x4f, 0x6f, 0xd3, 0x3e,
+	0x18, 0x8e, 0xd7, 0xb5, 0x6b, 0xdd, 0x6d, 0xbf, 0x9f, 0xbc, 0x82, 0x42, 0x85, 0xdc, 0xaa, 0x70,
+	0xa8, 0x26, 0x2d, 0x85, 0x31, 0x81, 0x38, 0x00, 0xea, 0xb4, 0x81, 0x90, 0x38, 0xa0, 0x0c, 0xb8,
+	0x4e, 0x6e, 0xe3, 0x66, 0x11, 0x69, 0x1c, 0x6c, 0xa7, 0xe2, 0x88, 0xf8, 0x04, 0x3b, 0xf2, 0x11,
+	0xf8, 0x28, 0x3b, 0xa1, 0x1d, 0x27, 0x0e, 0x83, 0x65, 0x17, 0x8e, 0xfb, 0x08, 0xc8, 0x76, 0x4a,
+	0x53, 0x36, 0xd0, 0x2a, 0xb4, 0x4b, 0xe2, 0xf7, 0xcf, 0xf3, 0xbc, 0xf6, 0xf3, 0xbe, 0x36, 0xac,
+	0xf2, 0x24, 0xa4, 0xdc, 0x89, 0x39, 0x93, 0x0c, 0x15, 0xb5, 0x51, 0x5f, 0xf3, 0x03, 0xb9, 0x97,
+	0xf4, 0x9c, 0x3e, 0x1b, 0x76, 0x7c, 0xe6, 0xb3, 0x8e, 0x8e, 0xf6, 0x92, 0x81, 0xb6, 0xb4, 0xa1,
+	0x57, 0x06, 0x55, 0xc7, 0x3e, 0x63, 0x7e, 0x48, 0x27, 0x59, 0x5e, 0xc2, 0x89, 0x0c, 0x58, 0x94,
+	0xc5, 0x1b, 0xbf, 0xc7, 0x65, 0x30, 0xa4, 0x42, 0x92, 0x61, 0x9c, 0x25, 0xdc, 0xc9, 0xd7, 0xe3,
+	0x64, 0x40, 0x22, 0xd2, 0x19, 0x06, 0xc3, 0x80, 0x77, 0xe2, 0xb7, 0xbe, 0x59, 0xc5, 0x3d, 0xf3,
+	0xcf, 0x10, 0xf7, 0xff, 0x8a, 0xd0, 0xa7, 0xd0, 0x5f, 0x11, 0xf7, 0xcc, 0xdf, 0xe0, 0x5a, 0xcb,
+	0x70, 0xd1, 0x55, 0xa6, 0x4b, 0xdf, 0x25, 0x54, 0xc8, 0xd6, 0x63, 0xb8, 0x94, 0xd9, 0x22, 0x66,
+	0x91, 0xa0, 0x68, 0x0d, 0x96, 0x7c, 0xce, 0x92, 0x58, 0xd8, 0xa0, 0x59, 0x68, 0x57, 0xd7, 0xaf,
+	0x39, 0x46, 0x9f, 0x67, 0xca, 0xb9, 0x23, 0x89, 0xa4, 0x5b, 0x54, 0xf4, 0xdd, 0x2c, 0xa9, 0xf5,
+	0x65, 0x0e, 0x2e, 0x4f, 0x87, 0xd0, 0x2a, 0x2c, 0xea, 0xa0, 0x0d, 0x9a, 0xa0, 0x5d, 0x5d, 0xaf,
+	0x39, 0xa6, 0xbe, 0x2a, 0xa3, 0x33, 0x35, 0xde, 0xa4, 0xa0, 0x07, 0x70, 0x91, 0xf4, 0x65, 0x30,
+	0xa2, 0xbb, 0x3a, 0xc9, 0x9e, 0xd3, 0x35, 0x6b, 0x59, 0x4d, 0x05, 0x99, 0x94, 0xac, 0x9a, 0x4c,
+	0xbd, 0x5d, 0xf4, 0x06, 0xae, 0xd0, 0x11, 0x09, 0x13, 0x2d, 0xf3, 0xab, 0xb1, 0x9c, 0x76, 0x41,
+	0x97, 0xac, 0x3b, 0x46, 0x70, 0x67, 0x2c, 0xb8, 0xf3, 0x2b, 0x63, 0xb3, 0x7c, 0x70, 0xdc, 0xb0,
+	0xf6, 0xbf, 0x35, 0x80, 0x7b, 0x11, 0x01, 0xda, 0x81, 0x68, 0xe2, 0xde, 0xca, 0xda, 0x68, 0xcf,
+	0x6b, 0xda, 0x1b, 0xe7, 0x68, 0xc7, 0x09, 0x86, 0xf5, 0x93, 0x62, 0xbd, 0x00, 0x8e, 0x56, 0xe1,
+	0xff, 0xc3, 0x40, 0x08, 0xea, 0x3d, 0x97, 0xd4, 0xb8, 0x84, 0x5d, 0x6c, 0x82, 0x76, 0xc1, 0x3d,
+	0xe7, 0x6f, 0x7d, 0x2c, 0x98, 0x8e, 0x4c, 0xf4, 0xbc, 0x05, 0xe7, 0x95, 0x1c, 0x99, 0x9c, 0xff,
+	0xe5, 0xe4, 0xd4, 0xb2, 0xe8, 0x20, 0xaa, 0xc1, 0xa2, 0x50, 0x08, 0x7b, 0xae, 0x09, 0xda, 0x15,
+	0xd7, 0x18, 0xe8, 0x3a, 0x2c, 0xed, 0x51, 0x12, 0xca, 0x3d, 0x2d, 0x4c, 0xc5, 0xcd, 0x2c, 0x74,
+	0x13, 0x56, 0x42, 0x22, 0xe4, 0x36, 0xe7, 0x8c, 0xeb, 0xc3, 0x55, 0xdc, 0x89, 0x43, 0x8d, 0x00,
+	0x09, 0x29, 0x97, 0x6a, 0x93, 0xf9, 0x11, 0xe8, 0x2a, 0x67, 0x6e, 0x04, 0x4c, 0xd2, 0x9f, 0x5a,
+	0x51, 0xba, 0x9a, 0x56, 0x2c, 0xfc, 0x5b, 0x2b, 0x6e, 0xc3, 0x25, 0x8f, 0xb3, 0x38, 0xa6, 0x5e,
+	0xd7, 0x1c, 0xb1, 0xac, 0xfb, 0x30, 0xed, 0x6c, 0x9d, 0xcd, 0xc3, 0xe5, 0xe9, 0xd3, 0x4e, 0x04,
+	0x06, 0x79, 0x81, 0x07, 0xb0, 0x14, 0x92, 0x1e, 0x0d, 0xc7, 0x93, 0xbb, 0xe2, 0xf4, 0x19, 0x97,
+	0xf4, 0x7d, 0xdc, 0x73, 0x5e, 0x28, 0xff, 0x4b, 0x12, 0xf0, 0xcd, 0x87, 0x6a, 0x47, 0x5f, 0x8f,
+	0x1b, 0x77, 0x2f, 0x73, 0xcb, 0x0d, 0xae, 0xeb, 0x91, 0x58, 0x52, 0xee, 0x66, 0xec, 0x28, 0x86,
+	0x55, 0x12, 0x45, 0x4c, 0x66, 0xc3, 0x53, 0xb8, 0x92, 0x62, 0xf9, 0x12, 0xea, 0xbc, 0x4a, 0x3d,
+	0xaa, 0xc7, 0x03, 0xb8, 0xc6, 0x40, 0x5d, 0x58, 0xc9, 0xee, 0x2b, 0x91, 0x7a, 0x84, 0x2f, 0xdb,
+	0xe1, 0xb2, 0x81, 0x75, 0x25, 0x7a, 0x02, 0xcb, 0x83, 0x80, 0x53, 0x4f, 0x31, 0xcc, 0x32, 0x23,
+	0x0b, 0x1a, 0xd5, 0x95, 0x68, 0x1b, 0x56, 0x39, 0x15, 0x2c, 0x1c, 0x19, 0x8e, 0x85, 0x19, 0x38,
+	0xe0, 0x18, 0xd8, 0x95, 0xe8, 0x29, 0x5c, 0x54, 0x23, 0xbf, 0x2b, 0x68, 0x24, 0x15, 0x4f, 0x79,
+	0x16, 0x1e, 0x85, 0xdc, 0xa1, 0x91, 0x34, 0xdb, 0x19, 0x91, 0x30, 0xf0, 0x76, 0x93, 0x48, 0x06,
+	0xa1, 0x5d, 0x99, 0x85, 0x46, 0x03, 0x5f, 0x2b, 0xdc, 0xfa, 0x23, 0x58, 0x54, 0x57, 0x9a, 0xa3,
+	0x0d, 0xb3, 0x10, 0x68, 0x25, 0xf7, 0x0a, 0x8e, 0xdf, 0xeb, 0x7a, 0x6d, 0xda, 0x69, 0x1e, 0xed,
+	0x96, 0xb5, 0xb9, 0x71, 0x78, 0x82, 0xad, 0xa3, 0x13, 0x6c, 0x9d, 0x9d, 0x60, 0xf0, 0x21, 0xc5,
+	0xe0, 0x73, 0x8a, 0xc1, 0x41, 0x8a, 0xc1, 0x61, 0x8a, 0xc1, 0xf7, 0x14, 0x83, 0x1f, 0x29, 0xb6,
+	0xce, 0x52, 0x0c, 0xf6, 0x4f, 0xb1, 0x75, 0x78, 0x8a, 0xad, 0xa3, 0x53, 0x6c, 0xf5, 0x4a, 0x7a,
+	0x7b, 0xf7, 0x7e, 0x06, 0x00, 0x00, 0xff, 0xff, 0x25, 0x6d, 0x16, 0x64, 0x04, 0x07, 0x00, 0x00,
 }
 
 func (this *RulesRequest) Equal(that interface{}) bool {
@@ -512,6 +532,9 @@ func (this *GroupStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if this.MissedIterations != that1.MissedIterations {
+		return false
+	}
 	return true
 }
 func (this *RuleStateDesc) Equal(that interface{}) bool {
@@ -559,6 +582,9 @@ func (this *RuleStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if this.DroppedAlerts != that1.DroppedAlerts {
+		return false
+	}
 	return true
 }
 func (this *AlertStateDesc) Equal(that interface{}) bool {
@@ -644,7 +670,7 @@ func (this *GroupStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 9)
 	s = append(s, "&ruler.GroupStateDesc{")
 	if this.Group != nil {
 		s = append(s, "Group: "+fmt.Sprintf("%#v", this.Group)+",\n")
@@ -654,6 +680,7 @@ func (this *GroupStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "MissedIterations: "+fmt.Sprintf("%#v", this.MissedIterations)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -661,7 +688,7 @@ func (this *RuleStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 12)
 	s = append(s, "&ruler.RuleStateDesc{")
 	if this.Rule != nil {
 		s = append(s, "Rule: "+fmt.Sprintf("%#v", this.Rule)+",\n")
@@ -674,6 +701,7 @@ func (this *RuleStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "DroppedAlerts: "+fmt.Sprintf("%#v", this.DroppedAlerts)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -864,6 +892,11 @@ func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.MissedIterations != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.MissedIterations))
+		i--
+		dAtA[i] = 0x28
+	}
 	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
 	if err1 != nil {
 		return 0, err1
@@ -929,6 +962,11 @@ func (m *RuleStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.DroppedAlerts != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.DroppedAlerts))
+		i--
+		dAtA[i] = 0x40
+	}
 	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
 	if err4 != nil {
 		return 0, err4
@@ -1154,6 +1192,9 @@ func (m *GroupStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	if m.MissedIterations != 0 {
+		n += 1 + sovRuler(uint64(m.MissedIterations))
+	}
 	return n
 }
 
@@ -1189,6 +1230,9 @@ func (m *RuleStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	if m.DroppedAlerts != 0 {
+		n += 1 + sovRuler(uint64(m.DroppedAlerts))
+	}
 	return n
 }
 
@@ -1274,6 +1318,7 @@ func (this *GroupStateDesc) String() string {
 		`ActiveRules:` + repeatedStringForActiveRules + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamp.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`MissedIterations:` + fmt.Sprintf("%v", this.MissedIterations) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1295,6 +1340,7 @@ func (this *RuleStateDesc) String() string {
 		`Alerts:` + repeatedStringForAlerts + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamp.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`DroppedAlerts:` + fmt.Sprintf("%v", this.DroppedAlerts) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1630,6 +1676,25 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MissedIterations", wireType)
+			}
+			m.MissedIterations = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MissedIterations |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
@@ -1915,6 +1980,25 @@ func (m *RuleStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DroppedAlerts", wireType)
+			}
+			m.DroppedAlerts = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DroppedAlerts |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
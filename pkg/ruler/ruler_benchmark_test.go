@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// The benchmarks of this file exercise the main code paths of the ruler with synthetic tenants and rule
+// groups: the sync of the rule groups, their sharding, their evaluation and the configuration API.
+// They report the throughput of each operation along with its allocations, and can be run with:
+//
+//	go test ./pkg/ruler -run '^$' -bench 'BenchmarkRuler' -benchmem
+
+var benchmarkRulerScenarios = []struct {
+	tenants, groups, rules int
+}{
+	{tenants: 1, groups: 100, rules: 10},
+	{tenants: 100, groups: 10, rules: 10},
+	{tenants: 1000, groups: 1, rules: 10},
+}
+
+// generateRuleGroups generates the rule groups of the synthetic tenants. The expressions of the rules
+// include the version, so that rule groups generated with different versions differ.
+func generateRuleGroups(tenants, groups, rules, version int) map[string]rulespb.RuleGroupList {
+	ruleGroups := make(map[string]rulespb.RuleGroupList, tenants)
+	for t := 0; t < tenants; t++ {
+		userID := fmt.Sprintf("tenant-%d", t)
+		for g := 0; g < groups; g++ {
+			rg := &rulespb.RuleGroupDesc{
+				User:      userID,
+				Namespace: fmt.Sprintf("namespace-%d", g%10),
+				Name:      fmt.Sprintf("group-%d", g),
+				Interval:  time.Minute,
+			}
+			for r := 0; r < rules; r++ {
+				rg.Rules = append(rg.Rules, &rulespb.RuleDesc{
+					Record: fmt.Sprintf("job:metric_%d:rate5m", r),
+					Expr:   fmt.Sprintf(`sum by (job) (rate(metric_%d{version="%d"}[5m]))`, r, version),
+				})
+			}
+			ruleGroups[userID] = append(ruleGroups[userID], rg)
+		}
+	}
+	return ruleGroups
+}
+
+func BenchmarkRuler_SyncRules(b *testing.B) {
+	for _, s := range benchmarkRulerScenarios {
+		for _, changed := range []bool{false, true} {
+			b.Run(fmt.Sprintf("tenants=%d,groups=%d,rules=%d,changed=%t", s.tenants, s.groups, s.rules, changed), func(b *testing.B) {
+				stores := []*mockRuleStore{
+					newMockRuleStore(generateRuleGroups(s.tenants, s.groups, s.rules, 0)),
+					newMockRuleStore(generateRuleGroups(s.tenants, s.groups, s.rules, 1)),
+				}
+
+				cfg := defaultRulerConfig(b)
+				r := buildRuler(b, cfg, stores[0], nil)
+				r.limits = ruleLimits{maxRuleGroups: s.groups, maxRulesPerRuleGroup: s.rules}
+				require.NoError(b, services.StartAndAwaitRunning(context.Background(), r))
+				b.Cleanup(func() { require.NoError(b, services.StopAndAwaitTerminated(context.Background(), r)) })
+				r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// Alternate between two versions of the rule groups when they change at each sync.
+					if changed {
+						r.store = stores[(i+1)%2]
+					}
+					// The rule storage is polled for all the tenants by the syncs on ring changes.
+					r.syncRules(context.Background(), rulerSyncReasonRingChange)
+				}
+				b.ReportMetric(float64(b.N*s.tenants*s.groups)/b.Elapsed().Seconds(), "groups/s")
+			})
+		}
+	}
+}
+
+func BenchmarkRuler_ListRulesSharded(b *testing.B) {
+	const (
+		numRulers = 10
+		numTokens = 128
+	)
+
+	for _, s := range benchmarkRulerScenarios {
+		for _, shardSize := range []int{0, 3} {
+			b.Run(fmt.Sprintf("tenants=%d,groups=%d,rules=%d,shard_size=%d", s.tenants, s.groups, s.rules, shardSize), func(b *testing.B) {
+				ruleGroups := generateRuleGroups(s.tenants, s.groups, s.rules, 0)
+
+				kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+				b.Cleanup(func() { require.NoError(b, closer.Close()) })
+
+				cfg := Config{
+					Ring: RingConfig{
+						InstanceID:        "ruler-0",
+						InstanceAddr:      "ruler-0",
+						InstancePort:      9095,
+						KVStore:           kv.Config{Mock: kvStore},
+						HeartbeatTimeout:  time.Minute,
+						ReplicationFactor: 1,
+					},
+				}
+				r := buildRuler(b, cfg, newMockRuleStore(ruleGroups), nil)
+				r.limits = ruleLimits{tenantShard: shardSize}
+
+				// Register the rulers in the ring, with random tokens.
+				require.NoError(b, kvStore.CAS(context.Background(), RulerRingKey, func(interface{}) (interface{}, bool, error) {
+					desc := ring.NewDesc()
+					for i := 0; i < numRulers; i++ {
+						tokens := make([]uint32, 0, numTokens)
+						for j := 0; j < numTokens; j++ {
+							tokens = append(tokens, rand.Uint32())
+						}
+						desc.AddIngester(fmt.Sprintf("ruler-%d", i), fmt.Sprintf("ruler-%d:9095", i), "", sortTokens(tokens), ring.ACTIVE, time.Now())
+					}
+					return desc, true, nil
+				}))
+				require.NoError(b, services.StartAndAwaitRunning(context.Background(), r.ring))
+				b.Cleanup(r.ring.StopAsync)
+				require.Eventually(b, func() bool {
+					return r.ring.InstancesCount() == numRulers
+				}, 5*time.Second, 10*time.Millisecond)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, err := r.listRules(context.Background())
+					require.NoError(b, err)
+				}
+				b.ReportMetric(float64(b.N*s.tenants*s.groups)/b.Elapsed().Seconds(), "groups/s")
+			})
+		}
+	}
+}
+
+func BenchmarkRuler_EvaluateRuleGroup(b *testing.B) {
+	for _, numRules := range []int{1, 10, 100} {
+		for _, numSeries := range []int{1, 100, 1000} {
+			b.Run(fmt.Sprintf("rules=%d,series=%d", numRules, numSeries), func(b *testing.B) {
+				result := make(promql.Vector, 0, numSeries)
+				for i := 0; i < numSeries; i++ {
+					result = append(result, promql.Sample{
+						Metric: labels.FromStrings(labels.MetricName, "metric", "job", fmt.Sprintf("job-%d", i)),
+						Point:  promql.Point{V: float64(i)},
+					})
+				}
+				qf := func(context.Context, string, time.Time) (promql.Vector, error) {
+					return copyVector(result), nil
+				}
+
+				queries := prometheus.NewCounter(prometheus.CounterOpts{})
+				failedQueries := prometheus.NewCounter(prometheus.CounterOpts{})
+				opts := &promRules.ManagerOptions{
+					Appendable: NewPusherAppendable(nopPusher{}, "user-1", ruleLimits{}, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+					Queryable: storage.QueryableFunc(func(context.Context, int64, int64) (storage.Querier, error) {
+						return storage.NoopQuerier(), nil
+					}),
+					QueryFunc:  TracingQueryFunc(MetricsQueryFunc(qf, queries, failedQueries)),
+					Context:    user.InjectOrgID(context.Background(), "user-1"),
+					NotifyFunc: func(context.Context, string, ...*promRules.Alert) {},
+					Logger:     log.NewNopLogger(),
+				}
+
+				rules := make([]promRules.Rule, 0, numRules)
+				for i := 0; i < numRules; i++ {
+					expr, err := parser.ParseExpr(fmt.Sprintf("sum by (job) (rate(metric_%d[5m]))", i))
+					require.NoError(b, err)
+					rules = append(rules, promRules.NewRecordingRule(fmt.Sprintf("job:metric_%d:rate5m", i), expr, nil))
+				}
+				g := promRules.NewGroup(promRules.GroupOptions{
+					Name:          "group",
+					File:          "namespace",
+					Interval:      time.Minute,
+					Rules:         rules,
+					Opts:          opts,
+					ShouldRestore: false,
+				})
+
+				ts := time.Now()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					g.Eval(opts.Context, ts.Add(time.Duration(i)*time.Minute))
+				}
+				b.ReportMetric(float64(b.N*numRules*numSeries)/b.Elapsed().Seconds(), "samples/s")
+			})
+		}
+	}
+}
+
+func BenchmarkRuler_API(b *testing.B) {
+	const group = `
+name: group-0
+interval: 1m
+rules:
+- record: job:metric_0:rate5m
+  expr: sum by (job) (rate(metric_0[5m]))
+- alert: HighRate
+  expr: job:metric_0:rate5m > 10
+  for: 5m
+  labels:
+    severity: warning
+`
+
+	for _, s := range benchmarkRulerScenarios {
+		b.Run(fmt.Sprintf("tenants=%d,groups=%d,rules=%d", s.tenants, s.groups, s.rules), func(b *testing.B) {
+			cfg := defaultRulerConfig(b)
+			r := buildRuler(b, cfg, newMockRuleStore(generateRuleGroups(s.tenants, s.groups, s.rules, 0)), nil)
+			// The rule groups limit is checked as if the created rule group was new.
+			r.limits = ruleLimits{maxRuleGroups: s.groups + 1, maxRulesPerRuleGroup: s.rules}
+
+			a := NewAPI(r, r.store, log.NewNopLogger())
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules").Methods(http.MethodGet).HandlerFunc(a.ListRules)
+			router.Path("/api/v1/rules/{namespace}").Methods(http.MethodPost).HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroup)
+
+			requests := []struct {
+				name    string
+				request func(userID string) *http.Request
+			}{
+				{"list", func(userID string) *http.Request {
+					return requestFor(b, http.MethodGet, "https://localhost:8080/api/v1/rules", nil, userID)
+				}},
+				{"get", func(userID string) *http.Request {
+					return requestFor(b, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace-0/group-0", nil, userID)
+				}},
+				{"create", func(userID string) *http.Request {
+					return requestFor(b, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace-0", strings.NewReader(group), userID)
+				}},
+			}
+
+			for _, req := range requests {
+				request := req.request
+				b.Run(req.name, func(b *testing.B) {
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						w := httptest.NewRecorder()
+						router.ServeHTTP(w, request(fmt.Sprintf("tenant-%d", i%s.tenants)))
+						if w.Code/100 != 2 {
+							b.Fatalf("unexpected status code %d: %s", w.Code, w.Body.String())
+						}
+					}
+					b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "requests/s")
+				})
+			}
+		})
+	}
+}
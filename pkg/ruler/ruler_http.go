@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	_ "embed" // Used to embed html template
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+var (
+	//go:embed rules.gohtml
+	rulesPageHTML     string
+	rulesPageTemplate = template.Must(template.New("webpage").Parse(rulesPageHTML))
+
+	//go:embed alerts.gohtml
+	alertsPageHTML     string
+	alertsPageTemplate = template.Must(template.New("webpage").Parse(alertsPageHTML))
+)
+
+type rulesPageContents struct {
+	Now    time.Time        `json:"now"`
+	Tenant string           `json:"tenant"`
+	Groups []rulesPageGroup `json:"groups"`
+}
+
+type rulesPageGroup struct {
+	Namespace          string          `json:"namespace"`
+	Name               string          `json:"name"`
+	Interval           time.Duration   `json:"interval"`
+	LastEvaluation     time.Time       `json:"lastEvaluation"`
+	EvaluationDuration time.Duration   `json:"evaluationDuration"`
+	Rules              []rulesPageRule `json:"rules"`
+}
+
+type rulesPageRule struct {
+	Name               string        `json:"name"`
+	Type               string        `json:"type"`
+	Expr               string        `json:"expr"`
+	Labels             string        `json:"labels"`
+	Health             string        `json:"health"`
+	LastError          string        `json:"lastError,omitempty"`
+	LastEvaluation     time.Time     `json:"lastEvaluation"`
+	EvaluationDuration time.Duration `json:"evaluationDuration"`
+}
+
+type alertsPageContents struct {
+	Now      time.Time        `json:"now"`
+	Tenant   string           `json:"tenant"`
+	Firing   int              `json:"firing"`
+	Pending  int              `json:"pending"`
+	Inactive int              `json:"inactive"`
+	Rules    []alertsPageRule `json:"rules"`
+}
+
+type alertsPageRule struct {
+	Namespace string            `json:"namespace"`
+	Group     string            `json:"group"`
+	Name      string            `json:"name"`
+	Expr      string            `json:"expr"`
+	For       time.Duration     `json:"for"`
+	State     string            `json:"state"`
+	Alerts    []alertsPageAlert `json:"alerts"`
+}
+
+type alertsPageAlert struct {
+	Labels   string    `json:"labels"`
+	State    string    `json:"state"`
+	ActiveAt time.Time `json:"activeAt"`
+	Value    string    `json:"value"`
+}
+
+// RulesPageHandler renders a web page with the rule groups of the tenant, along with the health and
+// last evaluation of their rules, similar to the rules page of the Prometheus web UI.
+func (r *Ruler) RulesPageHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	rgs, err := r.GetRules(req.Context())
+	if err != nil {
+		level.Error(r.logger).Log("msg", "failed to get the rules of the tenant", "user", userID, "err", err)
+		util.WriteTextResponse(w, fmt.Sprintf("Failed to get the rules: %s", err))
+		return
+	}
+	sortGroupStateDescs(rgs)
+
+	groups := make([]rulesPageGroup, 0, len(rgs))
+	for _, g := range rgs {
+		group := rulesPageGroup{
+			Namespace:          g.Group.Namespace,
+			Name:               g.Group.Name,
+			Interval:           g.Group.Interval,
+			LastEvaluation:     g.EvaluationTimestamp,
+			EvaluationDuration: g.EvaluationDuration,
+			Rules:              make([]rulesPageRule, 0, len(g.ActiveRules)),
+		}
+		for _, rl := range g.ActiveRules {
+			rule := rulesPageRule{
+				Name:               rl.Rule.GetRecord(),
+				Type:               "recording",
+				Expr:               rl.Rule.GetExpr(),
+				Labels:             mimirpb.FromLabelAdaptersToLabels(rl.Rule.Labels).String(),
+				Health:             rl.GetHealth(),
+				LastError:          rl.GetLastError(),
+				LastEvaluation:     rl.GetEvaluationTimestamp(),
+				EvaluationDuration: rl.GetEvaluationDuration(),
+			}
+			if rl.Rule.GetAlert() != "" {
+				rule.Name, rule.Type = rl.Rule.GetAlert(), "alerting"
+			}
+			group.Rules = append(group.Rules, rule)
+		}
+		groups = append(groups, group)
+	}
+
+	util.RenderHTTPResponse(w, rulesPageContents{
+		Now:    time.Now(),
+		Tenant: userID,
+		Groups: groups,
+	}, rulesPageTemplate, req)
+}
+
+// AlertsPageHandler renders a web page with the alerting rules of the tenant, the firing ones first,
+// along with their active alerts, similar to the alerts page of the Prometheus web UI.
+func (r *Ruler) AlertsPageHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	rgs, err := r.GetRules(req.Context())
+	if err != nil {
+		level.Error(r.logger).Log("msg", "failed to get the rules of the tenant", "user", userID, "err", err)
+		util.WriteTextResponse(w, fmt.Sprintf("Failed to get the rules: %s", err))
+		return
+	}
+	sortGroupStateDescs(rgs)
+
+	contents := alertsPageContents{Now: time.Now(), Tenant: userID, Rules: []alertsPageRule{}}
+	for _, g := range rgs {
+		for _, rl := range g.ActiveRules {
+			if rl.Rule.GetAlert() == "" {
+				continue
+			}
+
+			rule := alertsPageRule{
+				Namespace: g.Group.Namespace,
+				Group:     g.Group.Name,
+				Name:      rl.Rule.GetAlert(),
+				Expr:      rl.Rule.GetExpr(),
+				For:       rl.Rule.GetFor(),
+				State:     rl.GetState(),
+				Alerts:    make([]alertsPageAlert, 0, len(rl.Alerts)),
+			}
+			for _, a := range rl.Alerts {
+				rule.Alerts = append(rule.Alerts, alertsPageAlert{
+					Labels:   mimirpb.FromLabelAdaptersToLabels(a.Labels).String(),
+					State:    a.GetState(),
+					ActiveAt: a.GetActiveAt(),
+					Value:    fmt.Sprintf("%g", a.GetValue()),
+				})
+			}
+
+			switch rule.State {
+			case promRules.StateFiring.String():
+				contents.Firing++
+			case promRules.StatePending.String():
+				contents.Pending++
+			default:
+				contents.Inactive++
+			}
+			contents.Rules = append(contents.Rules, rule)
+		}
+	}
+
+	// Show the firing alerts first, then the pending ones.
+	stateOrder := map[string]int{promRules.StateFiring.String(): 0, promRules.StatePending.String(): 1}
+	sort.SliceStable(contents.Rules, func(i, j int) bool {
+		oi, ok := stateOrder[contents.Rules[i].State]
+		if !ok {
+			oi = len(stateOrder)
+		}
+		oj, ok := stateOrder[contents.Rules[j].State]
+		if !ok {
+			oj = len(stateOrder)
+		}
+		return oi < oj
+	})
+
+	util.RenderHTTPResponse(w, contents, alertsPageTemplate, req)
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRulerForPages(t *testing.T) *Ruler {
+	cfg := defaultRulerConfig(t)
+
+	rulerAddrMap := map[string]*Ruler{}
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r))
+	})
+
+	// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+	// Ensure all rules are loaded before usage
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+	return r
+}
+
+func TestRuler_RulesPageHandler(t *testing.T) {
+	r := newTestRulerForPages(t)
+
+	t.Run("html", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.RulesPageHandler(w, requestFor(t, http.MethodGet, "https://localhost:8080/ruler/rules", nil, "user1"))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "Showing rules for tenant: user1")
+		assert.Contains(t, w.Body.String(), "<h2>namespace1 &gt; group1</h2>")
+		assert.Contains(t, w.Body.String(), "<td>UP_RULE</td>")
+		assert.Contains(t, w.Body.String(), "<td>UP_ALERT</td>")
+		assert.Contains(t, w.Body.String(), "<td>up &lt; 1</td>")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/rules", nil, "user1")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		r.RulesPageHandler(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var contents rulesPageContents
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &contents))
+		assert.Equal(t, "user1", contents.Tenant)
+		require.Len(t, contents.Groups, 1)
+		assert.Equal(t, "namespace1", contents.Groups[0].Namespace)
+		assert.Equal(t, "group1", contents.Groups[0].Name)
+		require.Len(t, contents.Groups[0].Rules, 2)
+		assert.Equal(t, "UP_RULE", contents.Groups[0].Rules[0].Name)
+		assert.Equal(t, "recording", contents.Groups[0].Rules[0].Type)
+		assert.Equal(t, "UP_ALERT", contents.Groups[0].Rules[1].Name)
+		assert.Equal(t, "alerting", contents.Groups[0].Rules[1].Type)
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.RulesPageHandler(w, httptest.NewRequest(http.MethodGet, "https://localhost:8080/ruler/rules", nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestRuler_AlertsPageHandler(t *testing.T) {
+	r := newTestRulerForPages(t)
+
+	t.Run("html", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.AlertsPageHandler(w, requestFor(t, http.MethodGet, "https://localhost:8080/ruler/alerts", nil, "user1"))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Showing alerts for tenant: user1")
+		assert.Contains(t, w.Body.String(), "Firing: 0, pending: 0, inactive: 1")
+		assert.Contains(t, w.Body.String(), "<h2>UP_ALERT (inactive)</h2>")
+		assert.NotContains(t, w.Body.String(), "UP_RULE")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/alerts", nil, "user2")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		r.AlertsPageHandler(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var contents alertsPageContents
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &contents))
+		assert.Equal(t, "user2", contents.Tenant)
+		assert.Empty(t, contents.Rules)
+	})
+}
+
+func TestRuler_RingPage(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	r := buildRuler(t, cfg, newMockRuleStore(nil), nil)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r))
+	})
+
+	// Register a stuck instance, which stopped heartbeating a long time ago.
+	require.NoError(t, cfg.Ring.KVStore.Mock.CAS(context.Background(), RulerRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.GetOrCreateRingDesc(in)
+		instance := desc.AddIngester("ruler-stuck", "ruler-stuck:9095", "zone-b", []uint32{100}, ring.ACTIVE, time.Now().Add(-time.Hour))
+		instance.Timestamp = time.Now().Add(-time.Hour).Unix()
+		desc.Ingesters["ruler-stuck"] = instance
+		return desc, true, nil
+	}))
+	require.Eventually(t, func() bool {
+		return r.ring.InstancesCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	type ringInstance struct {
+		ID     string   `json:"id"`
+		State  string   `json:"state"`
+		Zone   string   `json:"zone"`
+		Tokens []uint32 `json:"tokens"`
+	}
+	getRing := func() []ringInstance {
+		req := httptest.NewRequest(http.MethodGet, "/ruler/ring", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Instances []ringInstance `json:"shards"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Instances
+	}
+
+	instances := getRing()
+	require.Len(t, instances, 2)
+	assert.Equal(t, "localhost", instances[0].ID)
+	assert.Equal(t, "ACTIVE", instances[0].State)
+	assert.Len(t, instances[0].Tokens, cfg.Ring.NumTokens)
+	assert.Equal(t, ringInstance{ID: "ruler-stuck", State: "UNHEALTHY", Zone: "zone-b", Tokens: []uint32{100}}, instances[1])
+
+	// Forget the stuck instance.
+	req := httptest.NewRequest(http.MethodPost, "/ruler/ring", strings.NewReader("forget=ruler-stuck"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	require.Eventually(t, func() bool {
+		return len(getRing()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "localhost", getRing()[0].ID)
+}
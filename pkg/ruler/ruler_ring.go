@@ -47,6 +47,12 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" category:"advanced"`
 	NumTokens              int      `yaml:"num_tokens" category:"advanced"`
 
+	// Number of rulers evaluating each rule group.
+	ReplicationFactor int `yaml:"replication_factor" category:"experimental"`
+
+	// Pool of rulers of the instance, each pool having its own ring.
+	Pool string `yaml:"pool" category:"experimental"`
+
 	// Injected internally
 	ListenPort int `yaml:"-"`
 
@@ -74,6 +80,8 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.InstancePort, "ruler.ring.instance-port", 0, "Port to advertise in the ring (defaults to -server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, "ruler.ring.instance-id", hostname, "Instance ID to register in the ring.")
 	f.IntVar(&cfg.NumTokens, "ruler.ring.num-tokens", 128, "Number of tokens for each ruler.")
+	f.IntVar(&cfg.ReplicationFactor, "ruler.ring.replication-factor", 1, "Number of rulers evaluating each rule group. Only the first healthy ruler of the replicas of a rule group in the ring writes the results of its recording rules and sends the notifications of its alerting rules, while the others evaluate it to take over without losing the state of its alerts.")
+	f.StringVar(&cfg.Pool, "ruler.ring.pool", "", "Pool of rulers this ruler belongs to. Each pool has its own ring, and the rulers of a pool evaluate only the rule groups of the tenants assigned to the pool with the ruler_evaluation_pool limit. Empty for the default pool.")
 }
 
 // ToLifecyclerConfig returns a LifecyclerConfig based on the ruler
@@ -103,8 +111,25 @@ func (cfg *RingConfig) ToRingConfig() ring.Config {
 	rc.HeartbeatTimeout = cfg.HeartbeatTimeout
 	rc.SubringCacheDisabled = true
 
-	// Each rule group is loaded to *exactly* one ruler.
-	rc.ReplicationFactor = 1
+	// Each rule group is loaded to exactly as many rulers as the replication factor, and only the
+	// leader of its replicas writes its results and sends its alerts.
+	rc.ReplicationFactor = cfg.ReplicationFactor
 
 	return rc
 }
+
+// ringKeyForPool returns the key under which the ring of the rulers of the pool is stored in the KV store.
+func ringKeyForPool(pool string) string {
+	if pool == "" {
+		return RulerRingKey
+	}
+	return RulerRingKey + "-" + pool
+}
+
+// ringNameForPool returns the name of the ring of the rulers of the pool.
+func ringNameForPool(pool string) string {
+	if pool == "" {
+		return "ruler"
+	}
+	return "ruler-" + pool
+}
@@ -22,13 +22,16 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/crypto/tls"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/kv/consul"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/sigv4"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
@@ -49,6 +52,7 @@ import (
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 func defaultRulerConfig(t testing.TB) Config {
@@ -79,7 +83,36 @@ type ruleLimits struct {
 	evalDelay            time.Duration
 	tenantShard          int
 	maxRulesPerRuleGroup int
+	maxRecordingLabels   int
+	maxLabelNames        int
+	maxLabelNameLength   int
+	maxLabelValueLength  int
 	maxRuleGroups        int
+	onDemandEvaluations  int
+	maxConcurrentQueries int
+	writeBatchSize       int
+	writeBatchTimeout    time.Duration
+	disableAlertsSeries  bool
+	evaluationMetrics    bool
+	configAPIWriteRate   float64
+	configAPIWriteBurst  int
+	evaluationPools      map[string]string
+	pollIntervals        map[string]time.Duration
+	queryBackend         queryBackendSettings
+	federationAllowed    map[string]string
+	federationBlocked    map[string]string
+	destTenants          map[string][]string
+	alertGeneratorURL    string
+	maxAlertsPerRule     int
+	notificationQueueCap int
+	notificationTimeout  time.Duration
+	notificationRetries  int
+	notificationRate     float64
+	notificationBurst    int
+	notificationDedup    time.Duration
+	notifierTLS          tls.ClientConfig
+	notifierSigV4        sigv4.SigV4Config
+	notifierOAuth2       oauth2Settings
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
@@ -98,6 +131,185 @@ func (r ruleLimits) RulerMaxRulesPerRuleGroup(_ string) int {
 	return r.maxRulesPerRuleGroup
 }
 
+func (r ruleLimits) RulerMaxRecordingRuleLabels(_ string) int {
+	return r.maxRecordingLabels
+}
+
+func (r ruleLimits) RulerMaxAlertsPerRule(_ string) int {
+	return r.maxAlertsPerRule
+}
+
+// The label limits default to the ones of the distributor when unset, so that the series written by
+// the rule evaluations of the tests aren't rejected.
+func (r ruleLimits) MaxLabelNamesPerSeries(_ string) int {
+	if r.maxLabelNames == 0 {
+		return 30
+	}
+	return r.maxLabelNames
+}
+
+func (r ruleLimits) MaxLabelNameLength(_ string) int {
+	if r.maxLabelNameLength == 0 {
+		return 1024
+	}
+	return r.maxLabelNameLength
+}
+
+func (r ruleLimits) MaxLabelValueLength(_ string) int {
+	if r.maxLabelValueLength == 0 {
+		return 2048
+	}
+	return r.maxLabelValueLength
+}
+
+func (r ruleLimits) RulerOnDemandEvaluationsPerMinute(_ string) int {
+	return r.onDemandEvaluations
+}
+
+func (r ruleLimits) RulerMaxConcurrentQueries(_ string) int {
+	return r.maxConcurrentQueries
+}
+
+func (r ruleLimits) RulerWriteBatchSize(_ string) int {
+	return r.writeBatchSize
+}
+
+func (r ruleLimits) RulerWriteBatchFlushTimeout(_ string) time.Duration {
+	return r.writeBatchTimeout
+}
+
+func (r ruleLimits) RulerAlertsSeriesEnabled(_ string) bool {
+	return !r.disableAlertsSeries
+}
+
+func (r ruleLimits) RulerEvaluationMetricsEnabled(_ string) bool {
+	return r.evaluationMetrics
+}
+
+func (r ruleLimits) RulerConfigAPIWriteRateLimit(_ string) float64 {
+	return r.configAPIWriteRate
+}
+
+func (r ruleLimits) RulerConfigAPIWriteRateLimitBurst(_ string) int {
+	return r.configAPIWriteBurst
+}
+
+func (r ruleLimits) RulerEvaluationPool(userID string) string {
+	return r.evaluationPools[userID]
+}
+
+func (r ruleLimits) RulerPollInterval(userID string) time.Duration {
+	return r.pollIntervals[userID]
+}
+
+func (r ruleLimits) RulerQueryBackendURL(_ string) string {
+	return r.queryBackend.url
+}
+
+func (r ruleLimits) RulerQueryBackendBasicAuthUsername(_ string) string {
+	return r.queryBackend.username
+}
+
+func (r ruleLimits) RulerQueryBackendBasicAuthPassword(_ string) string {
+	return r.queryBackend.password
+}
+
+func (r ruleLimits) RulerQueryBackendBearerToken(_ string) string {
+	return r.queryBackend.bearerToken
+}
+
+func (r ruleLimits) RulerFederationAllowedMetrics(userID string) string {
+	return r.federationAllowed[userID]
+}
+
+func (r ruleLimits) RulerFederationBlockedMetrics(userID string) string {
+	return r.federationBlocked[userID]
+}
+
+func (r ruleLimits) RulerFederationDestTenants(userID string) []string {
+	return r.destTenants[userID]
+}
+
+func (r ruleLimits) RulerAlertGeneratorURL(_ string) string {
+	return r.alertGeneratorURL
+}
+
+func (r ruleLimits) RulerNotificationQueueCapacity(_ string) int {
+	return r.notificationQueueCap
+}
+
+func (r ruleLimits) RulerNotificationTimeout(_ string) time.Duration {
+	return r.notificationTimeout
+}
+
+func (r ruleLimits) RulerNotificationMaxRetries(_ string) int {
+	return r.notificationRetries
+}
+
+func (r ruleLimits) RulerNotificationRateLimit(_ string) float64 {
+	return r.notificationRate
+}
+
+func (r ruleLimits) RulerNotificationRateLimitBurst(_ string) int {
+	return r.notificationBurst
+}
+
+func (r ruleLimits) RulerNotificationDeduplicationWindow(_ string) time.Duration {
+	return r.notificationDedup
+}
+
+func (r ruleLimits) RulerAlertmanagerClientTLSCertPath(_ string) string {
+	return r.notifierTLS.CertPath
+}
+
+func (r ruleLimits) RulerAlertmanagerClientTLSKeyPath(_ string) string {
+	return r.notifierTLS.KeyPath
+}
+
+func (r ruleLimits) RulerAlertmanagerClientTLSCAPath(_ string) string {
+	return r.notifierTLS.CAPath
+}
+
+func (r ruleLimits) RulerAlertmanagerClientTLSServerName(_ string) string {
+	return r.notifierTLS.ServerName
+}
+
+func (r ruleLimits) RulerAlertmanagerClientSigV4Region(_ string) string {
+	return r.notifierSigV4.Region
+}
+
+func (r ruleLimits) RulerAlertmanagerClientSigV4AccessKey(_ string) string {
+	return r.notifierSigV4.AccessKey
+}
+
+func (r ruleLimits) RulerAlertmanagerClientSigV4SecretKey(_ string) string {
+	return string(r.notifierSigV4.SecretKey)
+}
+
+func (r ruleLimits) RulerAlertmanagerClientSigV4Profile(_ string) string {
+	return r.notifierSigV4.Profile
+}
+
+func (r ruleLimits) RulerAlertmanagerClientSigV4RoleARN(_ string) string {
+	return r.notifierSigV4.RoleARN
+}
+
+func (r ruleLimits) RulerAlertmanagerClientOAuth2ClientID(_ string) string {
+	return r.notifierOAuth2.clientID
+}
+
+func (r ruleLimits) RulerAlertmanagerClientOAuth2ClientSecret(_ string) string {
+	return r.notifierOAuth2.clientSecret
+}
+
+func (r ruleLimits) RulerAlertmanagerClientOAuth2TokenURL(_ string) string {
+	return r.notifierOAuth2.tokenURL
+}
+
+func (r ruleLimits) RulerAlertmanagerClientOAuth2Scopes(_ string) string {
+	return r.notifierOAuth2.scopes
+}
+
 func testSetup() (storage.QueryableFunc, promRules.QueryFunc, Pusher, log.Logger, RulesLimits) {
 	noopQueryable := storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 		return storage.NoopQuerier(), nil
@@ -113,14 +325,14 @@ func testSetup() (storage.QueryableFunc, promRules.QueryFunc, Pusher, log.Logger
 	l := log.NewLogfmtLogger(os.Stdout)
 	l = level.NewFilter(l, level.AllowInfo())
 
-	return noopQueryable, noopQueryFunc, pusher, l, ruleLimits{evalDelay: 0, maxRuleGroups: 20, maxRulesPerRuleGroup: 15}
+	return noopQueryable, noopQueryFunc, pusher, l, ruleLimits{evalDelay: 0, maxRuleGroups: 20, maxRulesPerRuleGroup: 15, notificationQueueCap: 10000, notificationTimeout: 10 * time.Second}
 }
 
 func newManager(t *testing.T, cfg Config) *DefaultMultiTenantManager {
 	noopQueryable, noopQueryFunc, pusher, logger, overrides := testSetup()
 
 	mngFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, overrides, nil)
-	manager, err := NewDefaultMultiTenantManager(cfg, mngFactory, prometheus.NewRegistry(), logger, nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, mngFactory, overrides, prometheus.NewRegistry(), logger, nil)
 	require.NoError(t, err)
 
 	return manager
@@ -164,12 +376,12 @@ func newMockClientsPool(cfg Config, logger log.Logger, reg prometheus.Registerer
 	}
 }
 
-func buildRuler(t *testing.T, cfg Config, storage rulestore.RuleStore, rulerAddrMap map[string]*Ruler) *Ruler {
+func buildRuler(t testing.TB, cfg Config, storage rulestore.RuleStore, rulerAddrMap map[string]*Ruler) *Ruler {
 	noopQueryable, noopQueryFunc, pusher, logger, overrides := testSetup()
 
 	reg := prometheus.NewRegistry()
 	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, overrides, reg)
-	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, reg, log.NewNopLogger(), nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, overrides, reg, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	ruler, err := newRuler(cfg, manager, reg, logger, storage, overrides, newMockClientsPool(cfg, logger, reg, rulerAddrMap))
@@ -177,7 +389,7 @@ func buildRuler(t *testing.T, cfg Config, storage rulestore.RuleStore, rulerAddr
 	return ruler
 }
 
-func newTestRuler(t *testing.T, cfg Config, storage rulestore.RuleStore) *Ruler {
+func newTestRuler(t testing.TB, cfg Config, storage rulestore.RuleStore) *Ruler {
 	ruler := buildRuler(t, cfg, storage, nil)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ruler))
 
@@ -213,7 +425,7 @@ func TestNotifierSendsUserIDHeader(t *testing.T) {
 	require.NoError(t, err)
 
 	// Loop until notifier discovery syncs up
-	for len(n.Alertmanagers()) == 0 {
+	for len(n.notifier.Alertmanagers()) == 0 {
 		time.Sleep(10 * time.Millisecond)
 	}
 	n.Send(&notifier.Alert{
@@ -230,6 +442,61 @@ func TestNotifierSendsUserIDHeader(t *testing.T) {
 	`), "cortex_prometheus_notifications_dropped_total"))
 }
 
+func TestDefaultMultiTenantManager_ApplyNotifierConfig(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.AlertmanagerURL = "http://alertmanager-1:8080"
+
+	manager := newManager(t, cfg)
+	defer manager.Stop()
+
+	n, err := manager.getOrCreateNotifier("1")
+	require.NoError(t, err)
+
+	alertmanagerHosts := func() []string {
+		var hosts []string
+		for _, u := range n.notifier.Alertmanagers() {
+			hosts = append(hosts, u.Host)
+		}
+		return hosts
+	}
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"alertmanager-1:8080"}, alertmanagerHosts())
+	}, 15*time.Second, 10*time.Millisecond)
+
+	// The new settings apply to the existing notifiers, and to the ones created afterwards.
+	cfg.AlertmanagerURL = "http://alertmanager-2:8080"
+	require.NoError(t, manager.ApplyNotifierConfig(cfg))
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"alertmanager-2:8080"}, alertmanagerHosts())
+	}, 15*time.Second, 10*time.Millisecond)
+
+	n, err = manager.getOrCreateNotifier("2")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"alertmanager-2:8080"}, alertmanagerHosts())
+	}, 15*time.Second, 10*time.Millisecond)
+
+	cfg.AlertmanagerURL = "alertmanager"
+	require.Error(t, manager.ApplyNotifierConfig(cfg))
+}
+
+func TestRuler_CheckReady(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	// The ruler is ready regardless of the sync of the rule groups by default.
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), nil)
+	require.NoError(t, r.CheckReady(context.Background()))
+
+	cfg.ReadyAfterInitialSync = true
+	r = buildRuler(t, cfg, newMockRuleStore(mockRules), nil)
+	require.Error(t, r.CheckReady(context.Background()))
+
+	r = newTestRuler(t, cfg, newMockRuleStore(mockRules))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	require.NoError(t, r.CheckReady(context.Background()))
+	require.NotEmpty(t, r.manager.GetRules("user1"))
+}
+
 func TestRuler_Rules(t *testing.T) {
 	testCases := map[string]struct {
 		mockRules map[string]rulespb.RuleGroupList
@@ -367,6 +634,7 @@ func TestGetRules(t *testing.T) {
 					KVStore: kv.Config{
 						Mock: kvStore,
 					},
+					ReplicationFactor: 1,
 				}
 
 				r := buildRuler(t, cfg, storage, rulerAddrMap)
@@ -803,7 +1071,8 @@ func TestSharding(t *testing.T) {
 						KVStore: kv.Config{
 							Mock: kvStore,
 						},
-						HeartbeatTimeout: 1 * time.Minute,
+						HeartbeatTimeout:  1 * time.Minute,
+						ReplicationFactor: 1,
 					},
 					FlushCheckPeriod: 0,
 					EnabledTenants:   tc.enabledUsers,
@@ -910,14 +1179,14 @@ func TestDeleteTenantRuleGroups(t *testing.T) {
 
 	// "upload" rule groups
 	for _, key := range ruleGroups {
-		desc := rulespb.ToProto(key.user, key.namespace, rulefmt.RuleGroup{Name: key.group})
+		desc := rulespb.ToProto(key.user, key.namespace, rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: key.group}})
 		require.NoError(t, rs.SetRuleGroup(context.Background(), key.user, key.namespace, desc))
 	}
 
 	require.Equal(t, 3, len(obj.Objects()))
 
 	cfg := defaultRulerConfig(t)
-	api, err := NewRuler(cfg, nil, nil, log.NewNopLogger(), rs, nil)
+	api, err := NewRuler(cfg, nil, nil, nil, nil, nil, nil, log.NewNopLogger(), rs, nil)
 	require.NoError(t, err)
 
 	{
@@ -1001,6 +1270,111 @@ type ruleGroupKey struct {
 	user, namespace, group string
 }
 
+func TestRuler_EvaluationDisabled(t *testing.T) {
+	kvStore, cleanUp := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, cleanUp.Close()) })
+
+	storage := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "first", Interval: 10 * time.Second},
+		},
+	})
+	rulerAddrMap := map[string]*Ruler{}
+
+	createRuler := func(id string, enableEvaluation bool) *Ruler {
+		cfg := defaultRulerConfig(t)
+		cfg.Ring.KVStore.Mock = kvStore
+		cfg.Ring.InstanceID = id
+		cfg.Ring.InstanceAddr = id
+		cfg.EnableEvaluation = enableEvaluation
+
+		r := buildRuler(t, cfg, storage, rulerAddrMap)
+		rulerAddrMap[id] = r
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+		t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r)) })
+		return r
+	}
+
+	evaluator := createRuler("evaluator", true)
+	apiOnly := createRuler("api-only", false)
+
+	// Only the ruler evaluating the rule groups joins the ring.
+	test.Poll(t, time.Second, true, func() interface{} {
+		rs, err := apiOnly.ring.GetAllHealthy(RingOp)
+		return err == nil && len(rs.Instances) == 1 && rs.Instances[0].Addr == evaluator.lifecycler.GetInstanceAddr()
+	})
+
+	evaluator.syncRules(context.Background(), rulerSyncReasonInitial)
+	assert.Len(t, evaluator.manager.GetRules("user1"), 1)
+	assert.Empty(t, apiOnly.manager.GetRules("user1"))
+
+	// The rules are still served by the ruler not evaluating them.
+	rules, err := apiOnly.GetRules(user.InjectOrgID(context.Background(), "user1"))
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "first", rules[0].Group.Name)
+}
+
+func TestRuler_EvaluationPools(t *testing.T) {
+	kvStore, cleanUp := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, cleanUp.Close()) })
+
+	storage := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{User: "user1", Namespace: "namespace", Name: "first", Interval: 10 * time.Second},
+		},
+		"user2": {
+			&rulespb.RuleGroupDesc{User: "user2", Namespace: "namespace", Name: "second", Interval: 10 * time.Second},
+		},
+	})
+	limits := ruleLimits{evaluationPools: map[string]string{"user2": "premium"}}
+	rulerAddrMap := map[string]*Ruler{}
+
+	createRuler := func(id, pool string) *Ruler {
+		cfg := defaultRulerConfig(t)
+		cfg.Ring.KVStore.Mock = kvStore
+		cfg.Ring.InstanceID = id
+		cfg.Ring.InstanceAddr = id
+		cfg.Ring.Pool = pool
+
+		r := buildRuler(t, cfg, storage, rulerAddrMap)
+		r.limits = limits
+		rulerAddrMap[id] = r
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+		t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r)) })
+		r.syncRules(context.Background(), rulerSyncReasonInitial)
+		return r
+	}
+
+	defaultRuler := createRuler("default", "")
+	premiumRuler := createRuler("premium", "premium")
+
+	// Each ruler evaluates only the rule groups of the tenants of its pool.
+	assert.Len(t, defaultRuler.manager.GetRules("user1"), 1)
+	assert.Empty(t, defaultRuler.manager.GetRules("user2"))
+	assert.Empty(t, premiumRuler.manager.GetRules("user1"))
+	assert.Len(t, premiumRuler.manager.GetRules("user2"), 1)
+
+	// The rules of the tenants are read from the rulers of their pool.
+	for _, r := range []*Ruler{defaultRuler, premiumRuler} {
+		for userID, group := range map[string]string{"user1": "first", "user2": "second"} {
+			rules, err := r.GetRules(user.InjectOrgID(context.Background(), userID))
+			require.NoError(t, err)
+			require.Len(t, rules, 1)
+			assert.Equal(t, group, rules[0].Group.Name)
+		}
+	}
+}
+
+func TestConfig_Validate_APIAndEvaluationDisabled(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.EnableAPI = false
+	require.NoError(t, cfg.Validate(validation.Limits{}, log.NewNopLogger()))
+
+	cfg.EnableEvaluation = false
+	require.ErrorIs(t, cfg.Validate(validation.Limits{}, log.NewNopLogger()), errRulerAPIAndEvaluationDisabled)
+}
+
 func TestRuler_ListAllRules(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -1106,7 +1480,7 @@ func TestSendAlerts(t *testing.T) {
 				}
 				require.Equal(t, tc.exp, alerts)
 			})
-			SendAlerts(senderFunc, "http://localhost:9090")(context.TODO(), "up", tc.in...)
+			SendAlerts(senderFunc, func(context.Context) string { return "http://localhost:9090" }, nil)(context.TODO(), "up", tc.in...)
 		})
 	}
 }
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// statePreservingRulesManager is a RulesManager preserving the state of the rules across updates of
+// their rule group, as long as the rules are semantically unchanged. This way changing the interval
+// or other fields of a rule group not affecting a rule doesn't reset its health, last error and last
+// evaluation. The state of the alerts of unchanged alerting rules is preserved by the Prometheus
+// rules manager itself.
+type statePreservingRulesManager struct {
+	RulesManager
+}
+
+func newStatePreservingRulesManager(m RulesManager) *statePreservingRulesManager {
+	return &statePreservingRulesManager{RulesManager: m}
+}
+
+func (m *statePreservingRulesManager) Update(interval time.Duration, files []string, externalLabels labels.Labels, externalURL string) error {
+	previous := m.RulesManager.RuleGroups()
+
+	if err := m.RulesManager.Update(interval, files, externalLabels, externalURL); err != nil {
+		return err
+	}
+
+	copyRulesState(previous, m.RulesManager.RuleGroups())
+	return nil
+}
+
+// copyRulesState copies the state of the rules of the from groups to the matching rules of the
+// re-created to groups.
+func copyRulesState(from, to []*rules.Group) {
+	fromGroups := make(map[string]*rules.Group, len(from))
+	for _, g := range from {
+		fromGroups[rules.GroupKey(g.File(), g.Name())] = g
+	}
+
+	for _, g := range to {
+		prev, ok := fromGroups[rules.GroupKey(g.File(), g.Name())]
+		if !ok || prev == g {
+			// The group is new, or it has not been changed and has been kept as is.
+			continue
+		}
+
+		prevRules := make(map[string][]rules.Rule, len(prev.Rules()))
+		for _, r := range prev.Rules() {
+			key := ruleStateKey(r)
+			prevRules[key] = append(prevRules[key], r)
+		}
+
+		for _, r := range g.Rules() {
+			key := ruleStateKey(r)
+			matches := prevRules[key]
+			if len(matches) == 0 {
+				continue
+			}
+			prevRules[key] = matches[1:]
+
+			// Don't override the state if the rule has been evaluated in the meanwhile.
+			if !r.GetEvaluationTimestamp().IsZero() {
+				continue
+			}
+			r.SetHealth(matches[0].Health())
+			r.SetLastError(matches[0].LastError())
+			r.SetEvaluationTimestamp(matches[0].GetEvaluationTimestamp())
+			r.SetEvaluationDuration(matches[0].GetEvaluationDuration())
+		}
+	}
+}
+
+// ruleStateKey returns a key identifying the semantic of r.
+func ruleStateKey(r rules.Rule) string {
+	return r.Name() + r.Labels().String() + r.Query().String()
+}
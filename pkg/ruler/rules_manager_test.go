@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatePreservingRulesManager_Update(t *testing.T) {
+	queryFunc := func(_ context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		if qs == "vector(1)" {
+			return nil, errors.New("query failed")
+		}
+		return promql.Vector{{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("job", "test")}}, nil
+	}
+
+	m := newStatePreservingRulesManager(rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:  queryFunc,
+		Context:    context.Background(),
+		Logger:     log.NewNopLogger(),
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+	}))
+	go m.Run()
+	t.Cleanup(m.Stop)
+
+	file := filepath.Join(t.TempDir(), "namespace")
+	writeRules := func(interval string) {
+		require.NoError(t, os.WriteFile(file, []byte(`
+groups:
+- name: group
+  interval: `+interval+`
+  rules:
+  - alert: Alert
+    expr: up > 0
+    for: 1h
+  - record: failing
+    expr: vector(1)
+`), 0o600))
+	}
+
+	writeRules("1h")
+	require.NoError(t, m.Update(time.Hour, []string{file}, nil, ""))
+	require.Len(t, m.RuleGroups(), 1)
+	prev := m.RuleGroups()[0]
+
+	// Simulate an evaluation of the rules.
+	evalTime := time.Now()
+	for _, r := range prev.Rules() {
+		_, err := r.Eval(context.Background(), 0, evalTime, queryFunc, nil, 0)
+		if err != nil {
+			r.SetHealth(rules.HealthBad)
+			r.SetLastError(err)
+		} else {
+			r.SetHealth(rules.HealthGood)
+		}
+		r.SetEvaluationTimestamp(evalTime)
+		r.SetEvaluationDuration(time.Second)
+	}
+	prevAlerts := prev.Rules()[0].(*rules.AlertingRule).ActiveAlerts()
+	require.Len(t, prevAlerts, 1)
+	require.Equal(t, rules.StatePending, prevAlerts[0].State)
+
+	// Change the interval of the group only.
+	writeRules("2h")
+	require.NoError(t, m.Update(time.Hour, []string{file}, nil, ""))
+	require.Len(t, m.RuleGroups(), 1)
+	curr := m.RuleGroups()[0]
+	require.NotSame(t, prev, curr)
+	require.Equal(t, 2*time.Hour, curr.Interval())
+
+	alerting := curr.Rules()[0].(*rules.AlertingRule)
+	assert.Equal(t, rules.HealthGood, alerting.Health())
+	assert.Equal(t, evalTime, alerting.GetEvaluationTimestamp())
+	assert.Equal(t, time.Second, alerting.GetEvaluationDuration())
+	currAlerts := alerting.ActiveAlerts()
+	require.Len(t, currAlerts, 1)
+	assert.Equal(t, rules.StatePending, currAlerts[0].State)
+	assert.Equal(t, prevAlerts[0].ActiveAt, currAlerts[0].ActiveAt)
+
+	failing := curr.Rules()[1]
+	assert.Equal(t, rules.HealthBad, failing.Health())
+	assert.EqualError(t, failing.LastError(), "query failed")
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// mergeKeyTag is the tag of the YAML merge keys (<<).
+	mergeKeyTag = "!!merge"
+
+	// The maximum number of nodes of a rule group with its aliases expanded is proportional to the number of
+	// nodes of the rule group as written, so that a small document can't expand to a huge one.
+	maxExpandedNodesRatio = 100
+	minMaxExpandedNodes   = 10000
+)
+
+var errTooManyExpandedNodes = errors.New("the rule group is too large once its YAML aliases are expanded")
+
+// ExpandAliases returns a copy of the YAML node, where the aliases are replaced by a copy of their anchored
+// node and the merge keys by the keys of the merged mappings not set in the mapping. The Prometheus rule
+// format decodes the expressions and names of the rules as YAML nodes, which keep the aliases unresolved.
+func ExpandAliases(node *yaml.Node) (*yaml.Node, error) {
+	e := aliasExpander{maxNodes: countNodes(node) * maxExpandedNodesRatio}
+	if e.maxNodes < minMaxExpandedNodes {
+		e.maxNodes = minMaxExpandedNodes
+	}
+	return e.expand(node)
+}
+
+// countNodes returns the number of nodes of the YAML node, without following the aliases.
+func countNodes(node *yaml.Node) int {
+	n := 1
+	for _, c := range node.Content {
+		n += countNodes(c)
+	}
+	return n
+}
+
+type aliasExpander struct {
+	nodes    int
+	maxNodes int
+}
+
+func (e *aliasExpander) expand(node *yaml.Node) (*yaml.Node, error) {
+	if node.Kind == yaml.AliasNode {
+		if node.Alias == nil {
+			return nil, errors.Errorf("line %d: unknown anchor %q referenced", node.Line, node.Value)
+		}
+		return e.expand(node.Alias)
+	}
+
+	e.nodes++
+	if e.nodes > e.maxNodes {
+		return nil, errTooManyExpandedNodes
+	}
+
+	expanded := *node
+	expanded.Anchor = ""
+	expanded.Content = nil
+	if node.Kind == yaml.MappingNode {
+		return &expanded, e.expandMapping(&expanded, node.Content)
+	}
+	for _, c := range node.Content {
+		ec, err := e.expand(c)
+		if err != nil {
+			return nil, err
+		}
+		expanded.Content = append(expanded.Content, ec)
+	}
+	return &expanded, nil
+}
+
+// expandMapping sets the content of the mapping node to the key and value pairs of content, followed by the
+// keys of the merged mappings which aren't set yet. The keys of the mapping take precedence over the merged
+// keys, and the keys of a merged mapping over those of the mappings merged after it.
+func (e *aliasExpander) expandMapping(mapping *yaml.Node, content []*yaml.Node) error {
+	var merged []*yaml.Node
+	keys := map[string]bool{}
+	for i := 0; i+1 < len(content); i += 2 {
+		key, err := e.expand(content[i])
+		if err != nil {
+			return err
+		}
+		value, err := e.expand(content[i+1])
+		if err != nil {
+			return err
+		}
+
+		if key.Kind == yaml.ScalarNode && key.Tag == mergeKeyTag {
+			switch value.Kind {
+			case yaml.MappingNode:
+				merged = append(merged, value)
+			case yaml.SequenceNode:
+				for _, m := range value.Content {
+					if m.Kind != yaml.MappingNode {
+						return errors.Errorf("line %d: map merge requires map or sequence of maps as the value", value.Line)
+					}
+					merged = append(merged, m)
+				}
+			default:
+				return errors.Errorf("line %d: map merge requires map or sequence of maps as the value", value.Line)
+			}
+			continue
+		}
+
+		keys[key.Value] = true
+		mapping.Content = append(mapping.Content, key, value)
+	}
+
+	for _, m := range merged {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if key := m.Content[i]; !keys[key.Value] {
+				keys[key.Value] = true
+				mapping.Content = append(mapping.Content, key, m.Content[i+1])
+			}
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ package rulespb
 import (
 	"time"
 
+	"github.com/prometheus/alertmanager/timeinterval"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
@@ -16,15 +17,125 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb" //lint:ignore faillint allowed to import other protobuf
 )
 
-// ToProto transforms a formatted prometheus rulegroup to a rule group protobuf
-func ToProto(user string, namespace string, rl rulefmt.RuleGroup) *RuleGroupDesc {
+// RuleGroup is the Mimir rule group format: the Prometheus rule group format, extended with
+// the Mimir specific fields which are not part of the rule files loaded by the Prometheus rules manager.
+type RuleGroup struct {
+	rulefmt.RuleGroup `yaml:",inline"`
+
+	// ActiveTimeIntervals are the time intervals during which the alerts of the group are sent
+	// to the Alertmanager. The alerts are always sent if empty.
+	ActiveTimeIntervals []timeinterval.TimeInterval `yaml:"active_time_intervals,omitempty"`
+
+	// DependsOn are the rule groups of the same tenant, in the namespace/group format, whose
+	// evaluation the group waits for, so that it reads their latest results.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// DestTenants are the tenants the series of the recording rules of the group are written to,
+	// instead of the tenant owning the group.
+	DestTenants []string `yaml:"dest_tenants,omitempty"`
+
+	// SourceTenantLabel is the label added to the series read from each source tenant of the group,
+	// whose value is the source tenant. The label configured in the ruler is used if empty.
+	SourceTenantLabel string `yaml:"source_tenant_label,omitempty"`
+
+	// Labels are added to every rule of the group, the labels of a rule taking precedence.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Bindings are the values of the variables of a templated rule group. The rules referencing
+	// variables are expanded once for each binding when the rule group is stored, so the bindings
+	// are never part of a stored rule group.
+	Bindings []map[string]string `yaml:"bindings,omitempty"`
+
+	// RuleIntervals are the evaluation intervals of the rules, by index in Rules, set by the interval
+	// field of the rules. A rule without interval is evaluated at each evaluation of its group.
+	RuleIntervals []model.Duration `yaml:"-"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. It reads the interval field of the rules, which
+// isn't part of the Prometheus rule format, and expands the YAML aliases and merge keys.
+func (rg *RuleGroup) UnmarshalYAML(value *yaml.Node) error {
+	value, err := ExpandAliases(value)
+	if err != nil {
+		return err
+	}
+
+	type plain RuleGroup
+	if err := value.Decode((*plain)(rg)); err != nil {
+		return err
+	}
+
+	var intervals struct {
+		Rules []struct {
+			Interval model.Duration `yaml:"interval,omitempty"`
+		} `yaml:"rules"`
+	}
+	if err := value.Decode(&intervals); err != nil {
+		return err
+	}
+
+	rg.RuleIntervals = nil
+	for i, r := range intervals.Rules {
+		if r.Interval == 0 {
+			continue
+		}
+		if rg.RuleIntervals == nil {
+			rg.RuleIntervals = make([]model.Duration, len(intervals.Rules))
+		}
+		rg.RuleIntervals[i] = r.Interval
+	}
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. It writes the interval field of the rules.
+func (rg RuleGroup) MarshalYAML() (interface{}, error) {
+	type plain RuleGroup
+	node := &yaml.Node{}
+	if err := node.Encode(plain(rg)); err != nil {
+		return nil, err
+	}
+	if len(rg.RuleIntervals) == 0 {
+		return node, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "rules" {
+			continue
+		}
+		for j, r := range node.Content[i+1].Content {
+			if j >= len(rg.RuleIntervals) || rg.RuleIntervals[j] == 0 {
+				continue
+			}
+			r.Content = append(r.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "interval"},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: rg.RuleIntervals[j].String()},
+			)
+		}
+	}
+	return node, nil
+}
+
+// ToProto transforms a formatted rulegroup to a rule group protobuf
+func ToProto(user string, namespace string, rl RuleGroup) *RuleGroupDesc {
 	rg := RuleGroupDesc{
-		Name:          rl.Name,
-		Namespace:     namespace,
-		Interval:      time.Duration(rl.Interval),
-		Rules:         formattedRuleToProto(rl.Rules),
-		User:          user,
-		SourceTenants: rl.SourceTenants,
+		Name:                rl.Name,
+		Namespace:           namespace,
+		Interval:            time.Duration(rl.Interval),
+		Rules:               formattedRuleToProto(rl.Rules),
+		User:                user,
+		SourceTenants:       rl.SourceTenants,
+		ActiveTimeIntervals: timeIntervalsToProto(rl.ActiveTimeIntervals),
+		DependsOn:           rl.DependsOn,
+		Limit:               int64(rl.Limit),
+		DestTenants:         rl.DestTenants,
+		SourceTenantLabel:   rl.SourceTenantLabel,
+	}
+	if len(rl.Labels) > 0 {
+		rg.Labels = mimirpb.FromLabelsToLabelAdapters(labels.FromMap(rl.Labels))
+	}
+	for i, interval := range rl.RuleIntervals {
+		if i < len(rg.Rules) {
+			rg.Rules[i].Interval = time.Duration(interval)
+		}
 	}
 	return &rg
 }
@@ -45,13 +156,90 @@ func formattedRuleToProto(rls []rulefmt.RuleNode) []*RuleDesc {
 	return rules
 }
 
-// FromProto generates a rulefmt RuleGroup
-func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
+// FromProto generates a formatted RuleGroup
+func FromProto(rg *RuleGroupDesc) RuleGroup {
+	formatted := RuleGroup{
+		RuleGroup:           toRuleGroup(rg),
+		ActiveTimeIntervals: timeIntervalsFromProto(rg.GetActiveTimeIntervals()),
+		DependsOn:           rg.GetDependsOn(),
+		DestTenants:         rg.GetDestTenants(),
+		SourceTenantLabel:   rg.GetSourceTenantLabel(),
+		RuleIntervals:       ruleIntervalsFromProto(rg.GetRules()),
+	}
+	if len(rg.Labels) > 0 {
+		formatted.Labels = mimirpb.FromLabelAdaptersToLabels(rg.Labels).Map()
+	}
+	return formatted
+}
+
+// RuleFile returns the rulefmt RuleGroup of the rule group, with the labels of the group merged in
+// the labels of its rules, as loaded from rule files by the Prometheus rules manager.
+func (rg RuleGroup) RuleFile() rulefmt.RuleGroup {
+	if len(rg.Labels) == 0 {
+		return rg.RuleGroup
+	}
+
+	ruleFile := rg.RuleGroup
+	ruleFile.Rules = make([]rulefmt.RuleNode, len(rg.Rules))
+	for i, r := range rg.Rules {
+		r.Labels = MergeGroupLabels(rg.Labels, r.Labels)
+		ruleFile.Rules[i] = r
+	}
+	return ruleFile
+}
+
+// MergeGroupLabels returns the labels of a rule merged with the labels of its group, the labels of
+// the rule taking precedence.
+func MergeGroupLabels(groupLabels, ruleLabels map[string]string) map[string]string {
+	if len(groupLabels) == 0 {
+		return ruleLabels
+	}
+
+	merged := make(map[string]string, len(groupLabels)+len(ruleLabels))
+	for name, value := range groupLabels {
+		merged[name] = value
+	}
+	for name, value := range ruleLabels {
+		merged[name] = value
+	}
+	return merged
+}
+
+func ruleIntervalsFromProto(rules []*RuleDesc) []model.Duration {
+	var intervals []model.Duration
+	for i, r := range rules {
+		if r.GetInterval() == 0 {
+			continue
+		}
+		if intervals == nil {
+			intervals = make([]model.Duration, len(rules))
+		}
+		intervals[i] = model.Duration(r.GetInterval())
+	}
+	return intervals
+}
+
+// ToRuleFile generates a rulefmt RuleGroup, as loaded from rule files by the Prometheus rules manager.
+// The labels of the group are merged in the labels of its rules.
+func ToRuleFile(rg *RuleGroupDesc) rulefmt.RuleGroup {
+	ruleFile := toRuleGroup(rg)
+	if groupLabels := rg.Labels; len(groupLabels) > 0 {
+		groupLabelsMap := mimirpb.FromLabelAdaptersToLabels(groupLabels).Map()
+		for i := range ruleFile.Rules {
+			ruleFile.Rules[i].Labels = MergeGroupLabels(groupLabelsMap, ruleFile.Rules[i].Labels)
+		}
+	}
+	return ruleFile
+}
+
+// toRuleGroup generates the rulefmt RuleGroup of the rule group, without the Mimir specific fields.
+func toRuleGroup(rg *RuleGroupDesc) rulefmt.RuleGroup {
 	formattedRuleGroup := rulefmt.RuleGroup{
 		Name:          rg.GetName(),
 		Interval:      model.Duration(rg.Interval),
 		Rules:         make([]rulefmt.RuleNode, len(rg.GetRules())),
 		SourceTenants: rg.GetSourceTenants(),
+		Limit:         int(rg.GetLimit()),
 	}
 
 	for i, rl := range rg.GetRules() {
@@ -80,3 +268,85 @@ func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
 
 	return formattedRuleGroup
 }
+
+func timeIntervalsToProto(intervals []timeinterval.TimeInterval) []TimeInterval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	res := make([]TimeInterval, 0, len(intervals))
+	for _, ti := range intervals {
+		p := TimeInterval{}
+		for _, r := range ti.Times {
+			p.Times = append(p.Times, TimeRange{StartMinute: int32(r.StartMinute), EndMinute: int32(r.EndMinute)})
+		}
+		for _, r := range ti.Weekdays {
+			p.Weekdays = append(p.Weekdays, inclusiveRangeToProto(r.InclusiveRange))
+		}
+		for _, r := range ti.DaysOfMonth {
+			p.DaysOfMonth = append(p.DaysOfMonth, inclusiveRangeToProto(r.InclusiveRange))
+		}
+		for _, r := range ti.Months {
+			p.Months = append(p.Months, inclusiveRangeToProto(r.InclusiveRange))
+		}
+		for _, r := range ti.Years {
+			p.Years = append(p.Years, inclusiveRangeToProto(r.InclusiveRange))
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+func inclusiveRangeToProto(r timeinterval.InclusiveRange) InclusiveRange {
+	return InclusiveRange{Begin: int32(r.Begin), End: int32(r.End)}
+}
+
+func timeIntervalsFromProto(intervals []TimeInterval) []timeinterval.TimeInterval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	res := make([]timeinterval.TimeInterval, 0, len(intervals))
+	for _, p := range intervals {
+		ti := timeinterval.TimeInterval{}
+		for _, r := range p.Times {
+			ti.Times = append(ti.Times, timeinterval.TimeRange{StartMinute: int(r.StartMinute), EndMinute: int(r.EndMinute)})
+		}
+		for _, r := range p.Weekdays {
+			ti.Weekdays = append(ti.Weekdays, timeinterval.WeekdayRange{InclusiveRange: inclusiveRangeFromProto(r)})
+		}
+		for _, r := range p.DaysOfMonth {
+			ti.DaysOfMonth = append(ti.DaysOfMonth, timeinterval.DayOfMonthRange{InclusiveRange: inclusiveRangeFromProto(r)})
+		}
+		for _, r := range p.Months {
+			ti.Months = append(ti.Months, timeinterval.MonthRange{InclusiveRange: inclusiveRangeFromProto(r)})
+		}
+		for _, r := range p.Years {
+			ti.Years = append(ti.Years, timeinterval.YearRange{InclusiveRange: inclusiveRangeFromProto(r)})
+		}
+		res = append(res, ti)
+	}
+	return res
+}
+
+func inclusiveRangeFromProto(r InclusiveRange) timeinterval.InclusiveRange {
+	return timeinterval.InclusiveRange{Begin: int(r.Begin), End: int(r.End)}
+}
+
+// ActiveAt returns whether the alerts of the group should be sent to the Alertmanager at t,
+// which is the case if t is within one of its active time intervals, or if it has none.
+// The time intervals are evaluated in UTC.
+func (m *RuleGroupDesc) ActiveAt(t time.Time) bool {
+	intervals := m.GetActiveTimeIntervals()
+	if len(intervals) == 0 {
+		return true
+	}
+
+	t = t.UTC()
+	for _, ti := range timeIntervalsFromProto(intervals) {
+		if ti.ContainsTime(t) {
+			return true
+		}
+	}
+	return false
+}
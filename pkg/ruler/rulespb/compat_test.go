@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRuleGroupDesc_ActiveAt(t *testing.T) {
+	rg := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+rules:
+- alert: test
+  expr: up == 0
+active_time_intervals:
+- times:
+  - start_time: "09:00"
+    end_time: "17:00"
+  weekdays: ["monday:friday"]
+`), &rg))
+
+	desc := ToProto("user", "namespace", rg)
+	assert.Equal(t, rg.ActiveTimeIntervals, FromProto(desc).ActiveTimeIntervals)
+
+	// Monday 2022-04-11.
+	monday := time.Date(2022, 4, 11, 0, 0, 0, 0, time.UTC)
+	assert.True(t, desc.ActiveAt(monday.Add(9*time.Hour)))
+	assert.False(t, desc.ActiveAt(monday.Add(17*time.Hour)))
+	assert.False(t, desc.ActiveAt(monday.Add(-12*time.Hour)))
+
+	// The time intervals are evaluated in UTC.
+	assert.False(t, desc.ActiveAt(monday.Add(9*time.Hour).In(time.FixedZone("UTC+2", 2*3600)).Add(-time.Hour)))
+
+	// A rule group without active time intervals is always active.
+	assert.True(t, (&RuleGroupDesc{}).ActiveAt(monday))
+}
+
+func TestToProto_FromProto_Limit(t *testing.T) {
+	rg := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+limit: 10
+rules:
+- alert: test
+  expr: up == 0
+`), &rg))
+
+	desc := ToProto("user-1", "namespace", rg)
+	assert.Equal(t, int64(10), desc.Limit)
+	assert.Equal(t, 10, FromProto(desc).Limit)
+}
+
+func TestRuleGroup_RuleIntervals(t *testing.T) {
+	rg := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+interval: 1m
+rules:
+- record: up:sum
+  expr: sum(up)
+- record: job:http_requests:rate1h
+  expr: sum by (job) (rate(http_requests_total[1h]))
+  interval: 5m
+`), &rg))
+	assert.Equal(t, []model.Duration{0, model.Duration(5 * time.Minute)}, rg.RuleIntervals)
+
+	desc := ToProto("user-1", "namespace", rg)
+	assert.Equal(t, time.Duration(0), desc.Rules[0].Interval)
+	assert.Equal(t, 5*time.Minute, desc.Rules[1].Interval)
+
+	// The intervals are kept in the YAML format of the rule group.
+	out, err := yaml.Marshal(FromProto(desc))
+	require.NoError(t, err)
+	decoded := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal(out, &decoded))
+	assert.Equal(t, rg.RuleIntervals, decoded.RuleIntervals)
+	require.Len(t, decoded.Rules, 2)
+	assert.Equal(t, "sum by (job) (rate(http_requests_total[1h]))", decoded.Rules[1].Expr.Value)
+
+	// A rule group without intervals has none.
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+rules:
+- record: up:sum
+  expr: sum(up)
+`), &rg))
+	assert.Nil(t, rg.RuleIntervals)
+}
+
+func TestRuleGroup_Labels(t *testing.T) {
+	rg := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+labels:
+  team: api
+  severity: warning
+rules:
+- alert: HighErrorRate
+  expr: job:http_errors:rate5m > 0.1
+- alert: APIDown
+  expr: up{job="api"} == 0
+  labels:
+    severity: critical
+`), &rg))
+
+	// The labels of the group are stored without being merged in the labels of the rules.
+	desc := ToProto("user-1", "namespace", rg)
+	assert.Empty(t, desc.Rules[0].Labels)
+	assert.Equal(t, rg.Labels, FromProto(desc).Labels)
+	assert.Empty(t, FromProto(desc).Rules[0].Labels)
+
+	// The labels of a rule take precedence over the labels of its group.
+	for _, ruleFile := range []rulefmt.RuleGroup{rg.RuleFile(), ToRuleFile(desc)} {
+		require.Len(t, ruleFile.Rules, 2)
+		assert.Equal(t, map[string]string{"team": "api", "severity": "warning"}, ruleFile.Rules[0].Labels)
+		assert.Equal(t, map[string]string{"team": "api", "severity": "critical"}, ruleFile.Rules[1].Labels)
+	}
+	assert.Equal(t, map[string]string{"severity": "critical"}, rg.Rules[1].Labels)
+
+	// A rule group without labels has none.
+	desc = ToProto("user-1", "namespace", RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "test"}})
+	assert.Nil(t, FromProto(desc).Labels)
+}
+
+func TestRuleGroup_Aliases(t *testing.T) {
+	rg := RuleGroup{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: test
+x-defaults: &defaults
+  for: 5m
+  labels: &labels
+    team: api
+rules:
+- alert: APIDown
+  expr: &expr up{job="api"} == 0
+  <<: *defaults
+- alert: APIDownCritical
+  expr: *expr
+  <<: [{labels: {severity: critical}}, *defaults]
+  for: 15m
+- record: api:up
+  expr: *expr
+  labels: *labels
+`), &rg))
+
+	require.Len(t, rg.Rules, 3)
+	assert.Equal(t, `up{job="api"} == 0`, rg.Rules[0].Expr.Value)
+	assert.Equal(t, model.Duration(5*time.Minute), rg.Rules[0].For)
+	assert.Equal(t, map[string]string{"team": "api"}, rg.Rules[0].Labels)
+
+	// The keys of a rule take precedence over the merged keys, and the keys of the first merged mapping
+	// over the following ones.
+	assert.Equal(t, `up{job="api"} == 0`, rg.Rules[1].Expr.Value)
+	assert.Equal(t, model.Duration(15*time.Minute), rg.Rules[1].For)
+	assert.Equal(t, map[string]string{"severity": "critical"}, rg.Rules[1].Labels)
+
+	assert.Equal(t, `up{job="api"} == 0`, rg.Rules[2].Expr.Value)
+	assert.Equal(t, map[string]string{"team": "api"}, rg.Rules[2].Labels)
+
+	// The rule group is stored expanded.
+	desc := ToProto("user-1", "namespace", rg)
+	assert.Equal(t, `up{job="api"} == 0`, desc.Rules[2].Expr)
+	for _, r := range rg.RuleFile().Rules {
+		assert.Empty(t, r.Validate())
+	}
+
+	// The merged values must be mappings.
+	err := yaml.Unmarshal([]byte(`
+name: test
+rules:
+- alert: APIDown
+  expr: &expr up{job="api"} == 0
+  <<: *expr
+`), &rg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "map merge requires map or sequence of maps as the value")
+}
+
+func TestRuleGroup_Aliases_TooManyExpandedNodes(t *testing.T) {
+	// Each level of aliases multiplies the number of expanded nodes by 10.
+	doc := "name: test\nx-0: &a0 [up, up, up, up, up, up, up, up, up, up]\n"
+	for i := 1; i < 10; i++ {
+		doc += fmt.Sprintf("x-%d: &a%d [*a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d, *a%[3]d]\n", i, i, i-1)
+	}
+	doc += "rules:\n- record: up:sum\n  expr: sum(up)\n"
+
+	rg := RuleGroup{}
+	assert.ErrorIs(t, yaml.Unmarshal([]byte(doc), &rg), errTooManyExpandedNodes)
+}
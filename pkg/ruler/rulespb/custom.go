@@ -5,22 +5,93 @@
 
 package rulespb
 
-import "github.com/prometheus/prometheus/model/rulefmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+)
 
 // RuleGroupList contains a set of rule groups
 type RuleGroupList []*RuleGroupDesc
 
 // Formatted returns the rule group list as a set of formatted rule groups mapped
 // by namespace
-func (l RuleGroupList) Formatted() map[string][]rulefmt.RuleGroup {
-	ruleMap := map[string][]rulefmt.RuleGroup{}
+func (l RuleGroupList) Formatted() map[string][]RuleGroup {
+	ruleMap := map[string][]RuleGroup{}
 	for _, g := range l {
-		if _, exists := ruleMap[g.Namespace]; !exists {
-			ruleMap[g.Namespace] = []rulefmt.RuleGroup{FromProto(g)}
-			continue
-		}
 		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], FromProto(g))
+	}
+	return ruleMap
+}
+
+// FormattedRaw returns the rule group list as a set of YAML nodes mapped by namespace. The nodes of the
+// rule groups with a raw content are parsed from it, preserving its comments and keys ordering, while
+// the nodes of the other rule groups are encoded from their formatted rule group.
+func (l RuleGroupList) FormattedRaw() (map[string][]*yaml.Node, error) {
+	nodeMap := map[string][]*yaml.Node{}
+	for _, g := range l {
+		node, err := RawNode(g)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the raw content of rule group %s of namespace %s", g.Name, g.Namespace)
+		}
+		nodeMap[g.Namespace] = append(nodeMap[g.Namespace], node)
+	}
+	return nodeMap, nil
+}
 
+// RawNode returns the YAML node of the rule group, parsed from its raw content if any, or encoded from
+// its formatted rule group otherwise.
+func RawNode(g *RuleGroupDesc) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if len(g.Raw) == 0 {
+		return node, node.Encode(FromProto(g))
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(g.Raw, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 {
+		return nil, errors.New("not a single YAML document")
+	}
+
+	// The comments of the document are moved to its content, since it's embedded in another document.
+	node = doc.Content[0]
+	node.HeadComment = joinComments(doc.HeadComment, node.HeadComment)
+	node.FootComment = joinComments(node.FootComment, doc.FootComment)
+	return node, nil
+}
+
+func joinComments(first, second string) string {
+	if first == "" {
+		return second
+	}
+	if second == "" {
+		return first
+	}
+	return first + "\n\n" + second
+}
+
+// Checksum returns the hex-encoded SHA-256 checksum of the rule group in the YAML format of its
+// formatted rule group. It doesn't depend on the raw content of the rule group.
+func Checksum(g *RuleGroupDesc) (string, error) {
+	out, err := yaml.Marshal(FromProto(g))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RuleFiles returns the rule group list as a set of rulefmt rule groups mapped by namespace,
+// as written to the rule files loaded by the Prometheus rules manager.
+func (l RuleGroupList) RuleFiles() map[string][]rulefmt.RuleGroup {
+	ruleMap := map[string][]rulefmt.RuleGroup{}
+	for _, g := range l {
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], ToRuleFile(g))
 	}
 	return ruleMap
 }
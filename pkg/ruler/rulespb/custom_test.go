@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRuleGroupList_FormattedRaw(t *testing.T) {
+	list := RuleGroupList{
+		{
+			Namespace: "namespace1",
+			Name:      "with-raw",
+			Rules:     []*RuleDesc{{Record: "up_rule", Expr: "up"}},
+			Raw: []byte(`# Runbook: https://example.com/runbook
+
+# Recording rules.
+rules:
+  - record: up_rule # The up metric.
+    expr: up
+name: with-raw
+`),
+		},
+		{
+			Namespace: "namespace1",
+			Name:      "without-raw",
+			Interval:  time.Minute,
+			Rules:     []*RuleDesc{{Record: "down_rule", Expr: "down"}},
+		},
+		{
+			Namespace: "namespace2",
+			Name:      "with-raw",
+			Rules:     []*RuleDesc{{Record: "up_rule", Expr: "up"}},
+			Raw:       []byte("name: with-raw\nrules:\n- record: up_rule\n  expr: up\n"),
+		},
+	}
+
+	nodes, err := list.FormattedRaw()
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(nodes)
+	require.NoError(t, err)
+	assert.Equal(t, `namespace1:
+    # Runbook: https://example.com/runbook
+    - # Recording rules.
+      rules:
+        - record: up_rule # The up metric.
+          expr: up
+      name: with-raw
+    - name: without-raw
+      interval: 1m
+      rules:
+        - record: down_rule
+          expr: down
+namespace2:
+    - name: with-raw
+      rules:
+        - record: up_rule
+          expr: up
+`, string(out))
+}
+
+func TestRuleGroupList_FormattedRaw_InvalidRaw(t *testing.T) {
+	list := RuleGroupList{{Namespace: "namespace", Name: "invalid", Raw: []byte("name: [")}}
+
+	_, err := list.FormattedRaw()
+	require.Error(t, err)
+}
+
+func TestChecksum(t *testing.T) {
+	g := &RuleGroupDesc{Namespace: "namespace", Name: "group", Rules: []*RuleDesc{{Record: "up_rule", Expr: "up"}}}
+
+	checksum, err := Checksum(g)
+	require.NoError(t, err)
+	assert.Len(t, checksum, 64)
+
+	// The raw content doesn't change the checksum.
+	g.Raw = []byte("name: group\nrules:\n- record: up_rule\n  expr: up # Comment.\n")
+	withRaw, err := Checksum(g)
+	require.NoError(t, err)
+	assert.Equal(t, checksum, withRaw)
+
+	// Any change of the rule group changes the checksum.
+	g.Rules[0].Expr = "up == 1"
+	changed, err := Checksum(g)
+	require.NoError(t, err)
+	assert.NotEqual(t, checksum, changed)
+}
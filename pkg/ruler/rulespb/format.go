@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"fmt"
+)
+
+// CurrentFormatVersion is the version of the format of the rule groups written by this version of Mimir.
+//
+// The compatibility policy of the stored rule groups is:
+//   - A field added to RuleGroupDesc or RuleDesc, whose loss when a rule group is rewritten by a version
+//     of Mimir not knowing it changes the configuration of the rule group, increments CurrentFormatVersion.
+//     The fields which can be dropped safely, like caches of other fields, don't.
+//   - The rule groups of any format version are read and evaluated, the unknown fields being ignored.
+//   - The rule groups are always written with CurrentFormatVersion, and a rule group stored with a newer
+//     format version is never overwritten, because its unknown fields would be silently dropped.
+//   - The fields are never renumbered nor reused, the removed ones are reserved.
+//
+// The format versions are:
+//   - 0: the rule groups written before the format was versioned.
+//   - 1: the rule groups with the fields up to limit (15).
+//   - 2: the rule groups with the destination tenants (17).
+//   - 3: the rule groups with the source tenant label (18).
+//   - 4: the rule groups with the group labels (19).
+const CurrentFormatVersion uint32 = 4
+
+// FormatVersionError is returned when overwriting a rule group stored with a newer format version.
+type FormatVersionError struct {
+	Namespace string
+	Group     string
+	Version   uint32
+}
+
+func (e *FormatVersionError) Error() string {
+	return fmt.Sprintf("rule group %s/%s was stored with the format version %d by a newer version of Mimir: this version supports up to the format version %d and can't rewrite it without losing data", e.Namespace, e.Group, e.Version, CurrentFormatVersion)
+}
+
+// CheckOverwrite returns a FormatVersionError if the stored rule group can't be overwritten by this version
+// of Mimir because its format version is newer.
+func CheckOverwrite(stored *RuleGroupDesc) error {
+	if stored.GetFormatVersion() > CurrentFormatVersion {
+		return &FormatVersionError{Namespace: stored.Namespace, Group: stored.Name, Version: stored.FormatVersion}
+	}
+	return nil
+}
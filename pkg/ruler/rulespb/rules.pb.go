@@ -4,12 +4,14 @@
 package rulespb
 
 import (
+	bytes "bytes"
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
 	github_com_gogo_protobuf_types "github.com/gogo/protobuf/types"
 	types "github.com/gogo/protobuf/types"
 	_ "github.com/golang/protobuf/ptypes/duration"
+	_ "github.com/golang/protobuf/ptypes/timestamp"
 	_ "github.com/grafana/mimir/pkg/mimirpb"
 	github_com_grafana_mimir_pkg_mimirpb "github.com/grafana/mimir/pkg/mimirpb"
 	io "io"
@@ -45,6 +47,31 @@ type RuleGroupDesc struct {
 	// to the Prometheus Manager.
 	Options       []*types.Any `protobuf:"bytes,9,rep,name=options,proto3" json:"options,omitempty"`
 	SourceTenants []string     `protobuf:"bytes,10,rep,name=sourceTenants,proto3" json:"sourceTenants,omitempty"`
+	// The time intervals during which the alerts of the group are sent to the Alertmanager.
+	// The alerts are always sent if empty.
+	ActiveTimeIntervals []TimeInterval `protobuf:"bytes,11,rep,name=activeTimeIntervals,proto3" json:"activeTimeIntervals"`
+	// The rule groups of the same tenant, in the namespace/group format, whose evaluation
+	// this group waits for, so that it reads their latest results.
+	DependsOn []string `protobuf:"bytes,12,rep,name=dependsOn,proto3" json:"dependsOn,omitempty"`
+	// The rule group as submitted to the configuration API, in YAML, if any.
+	Raw []byte `protobuf:"bytes,13,opt,name=raw,proto3" json:"raw,omitempty"`
+	// The time the rule group configuration was last updated in the rule store, if known.
+	UpdatedAt *time.Time `protobuf:"bytes,14,opt,name=updatedAt,proto3,stdtime" json:"updatedAt,omitempty"`
+	// The maximum number of alerts or series produced by each rule of the group at each
+	// evaluation. The rules exceeding it fail. No limit if 0.
+	Limit int64 `protobuf:"varint,15,opt,name=limit,proto3" json:"limit,omitempty"`
+	// The version of the format of the rule group, set by the rule store when writing it. The
+	// rule groups written before the format was versioned have version 0. See CurrentFormatVersion.
+	FormatVersion uint32 `protobuf:"varint,16,opt,name=formatVersion,proto3" json:"formatVersion,omitempty"`
+	// The tenants the series of the recording rules of the group are written to, instead of the
+	// tenant owning the group. The series are written to the owning tenant if empty.
+	DestTenants []string `protobuf:"bytes,17,rep,name=destTenants,proto3" json:"destTenants,omitempty"`
+	// The label added to the series read from each source tenant of a federated group, whose value
+	// is the source tenant. The label configured in the ruler is used if empty.
+	SourceTenantLabel string `protobuf:"bytes,18,opt,name=sourceTenantLabel,proto3" json:"sourceTenantLabel,omitempty"`
+	// The labels added to every rule of the group, the labels of a rule taking precedence. They
+	// are merged in the rules of the rule files loaded by the Prometheus rules manager.
+	Labels []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,19,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"labels"`
 }
 
 func (m *RuleGroupDesc) Reset()      { *m = RuleGroupDesc{} }
@@ -128,6 +155,242 @@ func (m *RuleGroupDesc) GetSourceTenants() []string {
 	return nil
 }
 
+func (m *RuleGroupDesc) GetActiveTimeIntervals() []TimeInterval {
+	if m != nil {
+		return m.ActiveTimeIntervals
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetDependsOn() []string {
+	if m != nil {
+		return m.DependsOn
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetRaw() []byte {
+	if m != nil {
+		return m.Raw
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetUpdatedAt() *time.Time {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetLimit() int64 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *RuleGroupDesc) GetFormatVersion() uint32 {
+	if m != nil {
+		return m.FormatVersion
+	}
+	return 0
+}
+
+func (m *RuleGroupDesc) GetDestTenants() []string {
+	if m != nil {
+		return m.DestTenants
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetSourceTenantLabel() string {
+	if m != nil {
+		return m.SourceTenantLabel
+	}
+	return ""
+}
+
+// TimeInterval is a proto representation of an Alertmanager time interval.
+type TimeInterval struct {
+	Times       []TimeRange      `protobuf:"bytes,1,rep,name=times,proto3" json:"times"`
+	Weekdays    []InclusiveRange `protobuf:"bytes,2,rep,name=weekdays,proto3" json:"weekdays"`
+	DaysOfMonth []InclusiveRange `protobuf:"bytes,3,rep,name=daysOfMonth,proto3" json:"daysOfMonth"`
+	Months      []InclusiveRange `protobuf:"bytes,4,rep,name=months,proto3" json:"months"`
+	Years       []InclusiveRange `protobuf:"bytes,5,rep,name=years,proto3" json:"years"`
+}
+
+func (m *TimeInterval) Reset()      { *m = TimeInterval{} }
+func (*TimeInterval) ProtoMessage() {}
+func (*TimeInterval) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e722d3e922f0937, []int{1}
+}
+func (m *TimeInterval) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TimeInterval) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TimeInterval.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TimeInterval) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TimeInterval.Merge(m, src)
+}
+func (m *TimeInterval) XXX_Size() int {
+	return m.Size()
+}
+func (m *TimeInterval) XXX_DiscardUnknown() {
+	xxx_messageInfo_TimeInterval.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TimeInterval proto.InternalMessageInfo
+
+func (m *TimeInterval) GetTimes() []TimeRange {
+	if m != nil {
+		return m.Times
+	}
+	return nil
+}
+
+func (m *TimeInterval) GetWeekdays() []InclusiveRange {
+	if m != nil {
+		return m.Weekdays
+	}
+	return nil
+}
+
+func (m *TimeInterval) GetDaysOfMonth() []InclusiveRange {
+	if m != nil {
+		return m.DaysOfMonth
+	}
+	return nil
+}
+
+func (m *TimeInterval) GetMonths() []InclusiveRange {
+	if m != nil {
+		return m.Months
+	}
+	return nil
+}
+
+func (m *TimeInterval) GetYears() []InclusiveRange {
+	if m != nil {
+		return m.Years
+	}
+	return nil
+}
+
+// TimeRange is a range of minutes within a day, the end being exclusive.
+type TimeRange struct {
+	StartMinute int32 `protobuf:"varint,1,opt,name=startMinute,proto3" json:"startMinute,omitempty"`
+	EndMinute   int32 `protobuf:"varint,2,opt,name=endMinute,proto3" json:"endMinute,omitempty"`
+}
+
+func (m *TimeRange) Reset()      { *m = TimeRange{} }
+func (*TimeRange) ProtoMessage() {}
+func (*TimeRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e722d3e922f0937, []int{2}
+}
+func (m *TimeRange) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TimeRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TimeRange.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TimeRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TimeRange.Merge(m, src)
+}
+func (m *TimeRange) XXX_Size() int {
+	return m.Size()
+}
+func (m *TimeRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_TimeRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TimeRange proto.InternalMessageInfo
+
+func (m *TimeRange) GetStartMinute() int32 {
+	if m != nil {
+		return m.StartMinute
+	}
+	return 0
+}
+
+func (m *TimeRange) GetEndMinute() int32 {
+	if m != nil {
+		return m.EndMinute
+	}
+	return 0
+}
+
+// InclusiveRange is a range of days, months or years, both ends being inclusive.
+type InclusiveRange struct {
+	Begin int32 `protobuf:"varint,1,opt,name=begin,proto3" json:"begin,omitempty"`
+	End   int32 `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+}
+
+func (m *InclusiveRange) Reset()      { *m = InclusiveRange{} }
+func (*InclusiveRange) ProtoMessage() {}
+func (*InclusiveRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e722d3e922f0937, []int{3}
+}
+func (m *InclusiveRange) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *InclusiveRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_InclusiveRange.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *InclusiveRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InclusiveRange.Merge(m, src)
+}
+func (m *InclusiveRange) XXX_Size() int {
+	return m.Size()
+}
+func (m *InclusiveRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_InclusiveRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InclusiveRange proto.InternalMessageInfo
+
+func (m *InclusiveRange) GetBegin() int32 {
+	if m != nil {
+		return m.Begin
+	}
+	return 0
+}
+
+func (m *InclusiveRange) GetEnd() int32 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
 // RuleDesc is a proto representation of a Prometheus Rule
 type RuleDesc struct {
 	Expr        string                                              `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
@@ -136,12 +399,15 @@ type RuleDesc struct {
 	For         time.Duration                                       `protobuf:"bytes,4,opt,name=for,proto3,stdduration" json:"for"`
 	Labels      []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,5,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"labels"`
 	Annotations []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,6,rep,name=annotations,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"annotations"`
+	// The evaluation interval of the rule, longer than the interval of its group. The rule
+	// is evaluated at each evaluation of its group if 0.
+	Interval time.Duration `protobuf:"bytes,13,opt,name=interval,proto3,stdduration" json:"interval"`
 }
 
 func (m *RuleDesc) Reset()      { *m = RuleDesc{} }
 func (*RuleDesc) ProtoMessage() {}
 func (*RuleDesc) Descriptor() ([]byte, []int) {
-	return fileDescriptor_8e722d3e922f0937, []int{1}
+	return fileDescriptor_8e722d3e922f0937, []int{4}
 }
 func (m *RuleDesc) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -198,47 +464,77 @@ func (m *RuleDesc) GetFor() time.Duration {
 	return 0
 }
 
+func (m *RuleDesc) GetInterval() time.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*RuleGroupDesc)(nil), "rules.RuleGroupDesc")
+	proto.RegisterType((*TimeInterval)(nil), "rules.TimeInterval")
+	proto.RegisterType((*TimeRange)(nil), "rules.TimeRange")
+	proto.RegisterType((*InclusiveRange)(nil), "rules.InclusiveRange")
 	proto.RegisterType((*RuleDesc)(nil), "rules.RuleDesc")
 }
 
 func init() { proto.RegisterFile("rules.proto", fileDescriptor_8e722d3e922f0937) }
 
 var fileDescriptor_8e722d3e922f0937 = []byte{
-	// 501 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x52, 0x31, 0x8f, 0xd3, 0x30,
-	0x18, 0x8d, 0xdb, 0x34, 0x4d, 0x5c, 0x55, 0x54, 0xe6, 0x84, 0x72, 0x27, 0xe4, 0x56, 0x27, 0x90,
-	0xba, 0x90, 0xc2, 0x21, 0x06, 0x06, 0x84, 0xae, 0x3a, 0x09, 0xa9, 0x62, 0x40, 0x11, 0x13, 0x9b,
-	0x93, 0xba, 0x21, 0x22, 0xb5, 0x2d, 0xc7, 0x41, 0x77, 0x1b, 0x3f, 0x81, 0x91, 0x3f, 0x80, 0xc4,
-	0x4f, 0xb9, 0xb1, 0xe3, 0x89, 0xe1, 0xa0, 0xe9, 0xc2, 0x78, 0x3f, 0x01, 0xd9, 0x4e, 0xb9, 0x03,
-	0x96, 0x2e, 0x37, 0xf9, 0x7b, 0xdf, 0xfb, 0x9e, 0xbf, 0xe7, 0x27, 0xc3, 0x9e, 0xac, 0x0a, 0x5a,
-	0x46, 0x42, 0x72, 0xc5, 0x51, 0xc7, 0x80, 0x83, 0x47, 0x59, 0xae, 0xde, 0x57, 0x49, 0x94, 0xf2,
-	0xe5, 0x24, 0xe3, 0x19, 0x9f, 0x18, 0x36, 0xa9, 0x16, 0x06, 0x19, 0x60, 0x2a, 0xab, 0x3a, 0xc0,
-	0x19, 0xe7, 0x59, 0x41, 0xaf, 0xa7, 0xe6, 0x95, 0x24, 0x2a, 0xe7, 0xac, 0xe1, 0xf7, 0xff, 0xe5,
-	0x09, 0x3b, 0x6b, 0xa8, 0xc7, 0x37, 0x37, 0x49, 0xb2, 0x20, 0x8c, 0x4c, 0x96, 0xf9, 0x32, 0x97,
-	0x13, 0xf1, 0x21, 0xb3, 0x95, 0x48, 0xec, 0x69, 0x15, 0x87, 0x5f, 0x5b, 0xb0, 0x1f, 0x57, 0x05,
-	0x7d, 0x25, 0x79, 0x25, 0x4e, 0x68, 0x99, 0x22, 0x04, 0x5d, 0x46, 0x96, 0x34, 0x04, 0x23, 0x30,
-	0x0e, 0x62, 0x53, 0xa3, 0xfb, 0x30, 0xd0, 0x67, 0x29, 0x48, 0x4a, 0xc3, 0x96, 0x21, 0xae, 0x1b,
-	0xe8, 0x25, 0xf4, 0x73, 0xa6, 0xa8, 0xfc, 0x48, 0x8a, 0xb0, 0x3d, 0x02, 0xe3, 0xde, 0xd1, 0x7e,
-	0x64, 0x3d, 0x46, 0x5b, 0x8f, 0xd1, 0x49, 0xf3, 0x86, 0xa9, 0x7f, 0x7e, 0x39, 0x74, 0xbe, 0xfc,
-	0x18, 0x82, 0xf8, 0x8f, 0x08, 0x3d, 0x84, 0x36, 0xa9, 0xd0, 0x1d, 0xb5, 0xc7, 0xbd, 0xa3, 0x3b,
-	0x91, 0x0d, 0x51, 0xfb, 0xd2, 0x96, 0x62, 0xcb, 0x6a, 0x67, 0x55, 0x49, 0x65, 0xe8, 0x59, 0x67,
-	0xba, 0x46, 0x11, 0xec, 0x72, 0xa1, 0x2f, 0x2e, 0xc3, 0xc0, 0x88, 0xf7, 0xfe, 0x5b, 0x7d, 0xcc,
-	0xce, 0xe2, 0xed, 0x10, 0x7a, 0x00, 0xfb, 0x25, 0xaf, 0x64, 0x4a, 0xdf, 0x52, 0x46, 0x98, 0x2a,
-	0x43, 0x38, 0x6a, 0x8f, 0x83, 0xf8, 0xef, 0xe6, 0xcc, 0xf5, 0x3b, 0x03, 0x6f, 0xe6, 0xfa, 0xdd,
-	0x81, 0x3f, 0x73, 0x7d, 0x7f, 0x10, 0x1c, 0x6e, 0x5a, 0xd0, 0xdf, 0xfa, 0xd1, 0x46, 0xe8, 0xa9,
-	0x90, 0xdb, 0x88, 0x74, 0x8d, 0xee, 0x41, 0x4f, 0xd2, 0x94, 0xcb, 0x79, 0x93, 0x4f, 0x83, 0xd0,
-	0x1e, 0xec, 0x90, 0x82, 0x4a, 0x65, 0x92, 0x09, 0x62, 0x0b, 0xd0, 0x33, 0xd8, 0x5e, 0x70, 0x19,
-	0xba, 0xbb, 0xa7, 0xa5, 0xe7, 0xd1, 0x02, 0x7a, 0x05, 0x49, 0x68, 0x51, 0x86, 0x1d, 0xf3, 0xd8,
-	0xbb, 0x51, 0xca, 0xa5, 0xa2, 0xa7, 0x22, 0x89, 0x5e, 0xeb, 0xfe, 0x1b, 0x92, 0xcb, 0xe9, 0x73,
-	0xad, 0xf9, 0x7e, 0x39, 0x7c, 0xb2, 0xcb, 0x67, 0xb0, 0xba, 0xe3, 0x39, 0x11, 0x8a, 0xca, 0xb8,
-	0xb9, 0x1d, 0x09, 0xd8, 0x23, 0x8c, 0x71, 0x45, 0x6c, 0xb2, 0xde, 0xad, 0x2c, 0xbb, 0xb9, 0xc2,
-	0x64, 0xdd, 0x9f, 0xbe, 0x58, 0xad, 0xb1, 0x73, 0xb1, 0xc6, 0xce, 0xd5, 0x1a, 0x83, 0x4f, 0x35,
-	0x06, 0xdf, 0x6a, 0x0c, 0xce, 0x6b, 0x0c, 0x56, 0x35, 0x06, 0x3f, 0x6b, 0x0c, 0x7e, 0xd5, 0xd8,
-	0xb9, 0xaa, 0x31, 0xf8, 0xbc, 0xc1, 0xce, 0x6a, 0x83, 0x9d, 0x8b, 0x0d, 0x76, 0xde, 0x75, 0xcd,
-	0xf7, 0x10, 0x49, 0xe2, 0x99, 0x00, 0x9f, 0xfe, 0x0e, 0x00, 0x00, 0xff, 0xff, 0x99, 0x26, 0xa3,
-	0xae, 0x85, 0x03, 0x00, 0x00,
+	// 825 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0x4d, 0x6f, 0xdc, 0x44,
+	0x18, 0x5e, 0x67, 0xed, 0x8d, 0x3d, 0x9b, 0x6d, 0xb7, 0x93, 0x82, 0xa6, 0x11, 0x72, 0xac, 0x15,
+	0x48, 0x7b, 0x28, 0x0e, 0x6d, 0x85, 0x80, 0x43, 0x41, 0x59, 0x55, 0x42, 0x4d, 0xa9, 0x8a, 0xac,
+	0x88, 0x03, 0xb7, 0xb1, 0x3d, 0xeb, 0x5a, 0xb5, 0x67, 0xac, 0x99, 0x71, 0xda, 0xbd, 0xf1, 0x13,
+	0x7a, 0xe4, 0xc0, 0x91, 0x03, 0x3f, 0xa5, 0xc7, 0x9c, 0x50, 0xc5, 0xa1, 0x90, 0xcd, 0x85, 0x63,
+	0x7f, 0x02, 0x9a, 0x8f, 0xcd, 0x7a, 0x1b, 0x44, 0x2b, 0xa4, 0x9e, 0xfc, 0x7e, 0x3d, 0x7e, 0x9f,
+	0x79, 0xbf, 0xc0, 0x90, 0xb7, 0x15, 0x11, 0x71, 0xc3, 0x99, 0x64, 0xd0, 0xd3, 0xca, 0xde, 0xa7,
+	0x45, 0x29, 0x1f, 0xb7, 0x69, 0x9c, 0xb1, 0xfa, 0xa0, 0x60, 0x05, 0x3b, 0xd0, 0xde, 0xb4, 0x9d,
+	0x6b, 0x4d, 0x2b, 0x5a, 0x32, 0xa8, 0xbd, 0xb0, 0x60, 0xac, 0xa8, 0xc8, 0x3a, 0x2a, 0x6f, 0x39,
+	0x96, 0x25, 0xa3, 0xd6, 0x7f, 0xe3, 0x4d, 0x3f, 0xa6, 0x0b, 0xeb, 0xda, 0x7f, 0xd3, 0x25, 0xcb,
+	0x9a, 0x08, 0x89, 0xeb, 0xc6, 0x06, 0x7c, 0xd6, 0xa5, 0xc2, 0xf1, 0x1c, 0x53, 0x7c, 0x50, 0x97,
+	0x75, 0xc9, 0x0f, 0x9a, 0x27, 0x85, 0x91, 0x9a, 0xd4, 0x7c, 0x0d, 0x62, 0xf2, 0xbb, 0x07, 0x46,
+	0x49, 0x5b, 0x91, 0x6f, 0x39, 0x6b, 0x9b, 0x7b, 0x44, 0x64, 0x10, 0x02, 0x97, 0xe2, 0x9a, 0x20,
+	0x27, 0x72, 0xa6, 0x41, 0xa2, 0x65, 0xf8, 0x11, 0x08, 0xd4, 0x57, 0x34, 0x38, 0x23, 0x68, 0x4b,
+	0x3b, 0xd6, 0x06, 0xf8, 0x0d, 0xf0, 0x4b, 0x2a, 0x09, 0x3f, 0xc1, 0x15, 0xea, 0x47, 0xce, 0x74,
+	0x78, 0xfb, 0x46, 0x6c, 0x98, 0xc6, 0x2b, 0xa6, 0xf1, 0x3d, 0xfb, 0xc8, 0x99, 0xff, 0xe2, 0xd5,
+	0x7e, 0xef, 0xe7, 0x3f, 0xf7, 0x9d, 0xe4, 0x02, 0x04, 0x3f, 0x01, 0xa6, 0x94, 0xc8, 0x8d, 0xfa,
+	0xd3, 0xe1, 0xed, 0xab, 0xb1, 0xa9, 0xb2, 0xe2, 0xa5, 0x28, 0x25, 0xc6, 0xab, 0x98, 0xb5, 0x82,
+	0x70, 0x34, 0x30, 0xcc, 0x94, 0x0c, 0x63, 0xb0, 0xcd, 0x1a, 0xf5, 0x63, 0x81, 0x02, 0x0d, 0xbe,
+	0x7e, 0x29, 0xf5, 0x21, 0x5d, 0x24, 0xab, 0x20, 0xf8, 0x31, 0x18, 0x09, 0xd6, 0xf2, 0x8c, 0x1c,
+	0x13, 0x8a, 0xa9, 0x14, 0x08, 0x44, 0xfd, 0x69, 0x90, 0x6c, 0x1a, 0xe1, 0x03, 0xb0, 0x8b, 0x33,
+	0x59, 0x9e, 0x90, 0xe3, 0xb2, 0x26, 0xf7, 0x2d, 0x4d, 0x81, 0x86, 0x3a, 0xc3, 0xae, 0xa5, 0xd7,
+	0xf5, 0xcd, 0x5c, 0xf5, 0xac, 0xe4, 0xdf, 0x50, 0xaa, 0x78, 0x39, 0x69, 0x08, 0xcd, 0xc5, 0x23,
+	0x8a, 0x76, 0x74, 0xba, 0xb5, 0x01, 0x8e, 0x41, 0x9f, 0xe3, 0xa7, 0x68, 0x14, 0x39, 0xd3, 0x9d,
+	0x44, 0x89, 0xf0, 0x6b, 0x10, 0xb4, 0x4d, 0x8e, 0x25, 0xc9, 0x0f, 0x25, 0xba, 0xa2, 0xeb, 0xb9,
+	0x77, 0xe9, 0x51, 0xc7, 0xab, 0xce, 0xcf, 0xdc, 0xe7, 0xaa, 0x98, 0x6b, 0x08, 0xbc, 0x0e, 0xbc,
+	0xaa, 0xac, 0x4b, 0x89, 0xae, 0x46, 0xce, 0xb4, 0x9f, 0x18, 0x45, 0x3d, 0x7c, 0xce, 0x78, 0x8d,
+	0xe5, 0x0f, 0x84, 0x8b, 0x92, 0x51, 0x34, 0x8e, 0x9c, 0xe9, 0x28, 0xd9, 0x34, 0xc2, 0x08, 0x0c,
+	0x73, 0x22, 0xe4, 0xaa, 0x38, 0xd7, 0x34, 0xdb, 0xae, 0x09, 0xde, 0x04, 0xd7, 0xba, 0xb5, 0xfa,
+	0x0e, 0xa7, 0xa4, 0x42, 0x50, 0x77, 0xe4, 0xb2, 0x03, 0xce, 0xc1, 0xa0, 0x52, 0x82, 0x40, 0xbb,
+	0xb6, 0x76, 0x19, 0xe3, 0x92, 0x3c, 0x6b, 0xd2, 0x58, 0x07, 0x7c, 0x8f, 0x4b, 0x3e, 0xfb, 0x4a,
+	0xd5, 0xee, 0x8f, 0x57, 0xfb, 0xb7, 0xde, 0x65, 0x7a, 0x0d, 0xee, 0x30, 0xc7, 0x8d, 0x24, 0x3c,
+	0xb1, 0x7f, 0x3f, 0x72, 0x7d, 0x6f, 0x3c, 0x38, 0x72, 0xfd, 0xed, 0xb1, 0x7f, 0xe4, 0xfa, 0xfe,
+	0x38, 0x98, 0xfc, 0xb2, 0x05, 0x76, 0xba, 0x7d, 0x80, 0x37, 0x81, 0xa7, 0xd7, 0x05, 0x39, 0x9a,
+	0xc9, 0xb8, 0xd3, 0xc5, 0x04, 0xd3, 0x82, 0xd8, 0x16, 0x9a, 0x20, 0xf8, 0x05, 0xf0, 0x9f, 0x12,
+	0xf2, 0x24, 0xc7, 0x0b, 0x81, 0xb6, 0x34, 0xe0, 0x03, 0x0b, 0xb8, 0x4f, 0xb3, 0xaa, 0x15, 0xe5,
+	0xc9, 0x06, 0xea, 0x22, 0x18, 0xde, 0x05, 0x43, 0xf5, 0x7d, 0x34, 0x7f, 0xc8, 0xa8, 0x7c, 0x8c,
+	0xfa, 0x6f, 0xc7, 0x76, 0xe3, 0xe1, 0x1d, 0x30, 0xa8, 0x95, 0xb0, 0xda, 0x85, 0xff, 0x44, 0xda,
+	0x50, 0x78, 0x0b, 0x78, 0x0b, 0x82, 0xb9, 0x40, 0xde, 0xdb, 0x31, 0x26, 0x72, 0xf2, 0x00, 0x04,
+	0x17, 0x2f, 0x57, 0x5d, 0x17, 0x12, 0x73, 0xf9, 0xb0, 0xa4, 0xad, 0x34, 0x9b, 0xef, 0x25, 0x5d,
+	0x93, 0x9a, 0x61, 0x42, 0x73, 0xeb, 0xdf, 0xd2, 0xfe, 0xb5, 0x61, 0xf2, 0x25, 0xb8, 0xb2, 0x99,
+	0x4b, 0xcd, 0x60, 0x4a, 0x8a, 0x92, 0xda, 0x7f, 0x19, 0x45, 0xcd, 0x3a, 0xa1, 0xb9, 0xc5, 0x2b,
+	0x71, 0xf2, 0x6b, 0x1f, 0xf8, 0xab, 0x35, 0x57, 0xfb, 0x4d, 0x9e, 0x35, 0x7c, 0x75, 0x79, 0x94,
+	0x0c, 0x3f, 0x04, 0x03, 0x4e, 0x32, 0xc6, 0x73, 0x7b, 0x76, 0xac, 0xa6, 0x12, 0xe0, 0x8a, 0x70,
+	0xa9, 0x0f, 0x4e, 0x90, 0x18, 0x05, 0x7e, 0x0e, 0xfa, 0x73, 0xc6, 0x91, 0xfb, 0xee, 0x47, 0x48,
+	0xc5, 0x77, 0xa6, 0xd4, 0x7b, 0x9f, 0x53, 0x0a, 0x1b, 0x30, 0xc4, 0x94, 0x32, 0x89, 0xcd, 0xc1,
+	0x1a, 0xbc, 0x97, 0x64, 0xdd, 0x14, 0x1b, 0xa7, 0x79, 0xf4, 0x3f, 0x4e, 0xb3, 0x5e, 0xa9, 0xd1,
+	0xec, 0xee, 0xe9, 0x59, 0xd8, 0x7b, 0x79, 0x16, 0xf6, 0x5e, 0x9f, 0x85, 0xce, 0x4f, 0xcb, 0xd0,
+	0xf9, 0x6d, 0x19, 0x3a, 0x2f, 0x96, 0xa1, 0x73, 0xba, 0x0c, 0x9d, 0xbf, 0x96, 0xa1, 0xf3, 0xf7,
+	0x32, 0xec, 0xbd, 0x5e, 0x86, 0xce, 0xf3, 0xf3, 0xb0, 0x77, 0x7a, 0x1e, 0xf6, 0x5e, 0x9e, 0x87,
+	0xbd, 0x1f, 0xb7, 0xf5, 0x18, 0x36, 0x69, 0x3a, 0xd0, 0xb9, 0xee, 0xfc, 0x13, 0x00, 0x00, 0xff,
+	0xff, 0x6a, 0x54, 0x1f, 0xef, 0x3e, 0x07, 0x00, 0x00,
 }
 
 func (this *RuleGroupDesc) Equal(that interface{}) bool {
@@ -296,16 +592,67 @@ func (this *RuleGroupDesc) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if len(this.ActiveTimeIntervals) != len(that1.ActiveTimeIntervals) {
+		return false
+	}
+	for i := range this.ActiveTimeIntervals {
+		if !this.ActiveTimeIntervals[i].Equal(&that1.ActiveTimeIntervals[i]) {
+			return false
+		}
+	}
+	if len(this.DependsOn) != len(that1.DependsOn) {
+		return false
+	}
+	for i := range this.DependsOn {
+		if this.DependsOn[i] != that1.DependsOn[i] {
+			return false
+		}
+	}
+	if !bytes.Equal(this.Raw, that1.Raw) {
+		return false
+	}
+	if that1.UpdatedAt == nil {
+		if this.UpdatedAt != nil {
+			return false
+		}
+	} else if !this.UpdatedAt.Equal(*that1.UpdatedAt) {
+		return false
+	}
+	if this.Limit != that1.Limit {
+		return false
+	}
+	if this.FormatVersion != that1.FormatVersion {
+		return false
+	}
+	if len(this.DestTenants) != len(that1.DestTenants) {
+		return false
+	}
+	for i := range this.DestTenants {
+		if this.DestTenants[i] != that1.DestTenants[i] {
+			return false
+		}
+	}
+	if this.SourceTenantLabel != that1.SourceTenantLabel {
+		return false
+	}
+	if len(this.Labels) != len(that1.Labels) {
+		return false
+	}
+	for i := range this.Labels {
+		if !this.Labels[i].Equal(that1.Labels[i]) {
+			return false
+		}
+	}
 	return true
 }
-func (this *RuleDesc) Equal(that interface{}) bool {
+func (this *TimeInterval) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
 	}
 
-	that1, ok := that.(*RuleDesc)
+	that1, ok := that.(*TimeInterval)
 	if !ok {
-		that2, ok := that.(RuleDesc)
+		that2, ok := that.(TimeInterval)
 		if ok {
 			that1 = &that2
 		} else {
@@ -317,68 +664,268 @@ func (this *RuleDesc) Equal(that interface{}) bool {
 	} else if this == nil {
 		return false
 	}
-	if this.Expr != that1.Expr {
+	if len(this.Times) != len(that1.Times) {
 		return false
 	}
-	if this.Record != that1.Record {
-		return false
+	for i := range this.Times {
+		if !this.Times[i].Equal(&that1.Times[i]) {
+			return false
+		}
 	}
-	if this.Alert != that1.Alert {
+	if len(this.Weekdays) != len(that1.Weekdays) {
 		return false
 	}
-	if this.For != that1.For {
+	for i := range this.Weekdays {
+		if !this.Weekdays[i].Equal(&that1.Weekdays[i]) {
+			return false
+		}
+	}
+	if len(this.DaysOfMonth) != len(that1.DaysOfMonth) {
 		return false
 	}
-	if len(this.Labels) != len(that1.Labels) {
+	for i := range this.DaysOfMonth {
+		if !this.DaysOfMonth[i].Equal(&that1.DaysOfMonth[i]) {
+			return false
+		}
+	}
+	if len(this.Months) != len(that1.Months) {
 		return false
 	}
-	for i := range this.Labels {
-		if !this.Labels[i].Equal(that1.Labels[i]) {
+	for i := range this.Months {
+		if !this.Months[i].Equal(&that1.Months[i]) {
 			return false
 		}
 	}
-	if len(this.Annotations) != len(that1.Annotations) {
+	if len(this.Years) != len(that1.Years) {
 		return false
 	}
-	for i := range this.Annotations {
-		if !this.Annotations[i].Equal(that1.Annotations[i]) {
+	for i := range this.Years {
+		if !this.Years[i].Equal(&that1.Years[i]) {
 			return false
 		}
 	}
 	return true
 }
-func (this *RuleGroupDesc) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *TimeRange) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 11)
-	s = append(s, "&rulespb.RuleGroupDesc{")
-	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
-	s = append(s, "Namespace: "+fmt.Sprintf("%#v", this.Namespace)+",\n")
-	s = append(s, "Interval: "+fmt.Sprintf("%#v", this.Interval)+",\n")
-	if this.Rules != nil {
-		s = append(s, "Rules: "+fmt.Sprintf("%#v", this.Rules)+",\n")
+
+	that1, ok := that.(*TimeRange)
+	if !ok {
+		that2, ok := that.(TimeRange)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
 	}
-	s = append(s, "User: "+fmt.Sprintf("%#v", this.User)+",\n")
-	if this.Options != nil {
-		s = append(s, "Options: "+fmt.Sprintf("%#v", this.Options)+",\n")
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
 	}
-	s = append(s, "SourceTenants: "+fmt.Sprintf("%#v", this.SourceTenants)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	if this.StartMinute != that1.StartMinute {
+		return false
+	}
+	if this.EndMinute != that1.EndMinute {
+		return false
+	}
+	return true
 }
-func (this *RuleDesc) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *InclusiveRange) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 10)
-	s = append(s, "&rulespb.RuleDesc{")
-	s = append(s, "Expr: "+fmt.Sprintf("%#v", this.Expr)+",\n")
-	s = append(s, "Record: "+fmt.Sprintf("%#v", this.Record)+",\n")
-	s = append(s, "Alert: "+fmt.Sprintf("%#v", this.Alert)+",\n")
+
+	that1, ok := that.(*InclusiveRange)
+	if !ok {
+		that2, ok := that.(InclusiveRange)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Begin != that1.Begin {
+		return false
+	}
+	if this.End != that1.End {
+		return false
+	}
+	return true
+}
+func (this *RuleDesc) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RuleDesc)
+	if !ok {
+		that2, ok := that.(RuleDesc)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Expr != that1.Expr {
+		return false
+	}
+	if this.Record != that1.Record {
+		return false
+	}
+	if this.Alert != that1.Alert {
+		return false
+	}
+	if this.For != that1.For {
+		return false
+	}
+	if len(this.Labels) != len(that1.Labels) {
+		return false
+	}
+	for i := range this.Labels {
+		if !this.Labels[i].Equal(that1.Labels[i]) {
+			return false
+		}
+	}
+	if len(this.Annotations) != len(that1.Annotations) {
+		return false
+	}
+	for i := range this.Annotations {
+		if !this.Annotations[i].Equal(that1.Annotations[i]) {
+			return false
+		}
+	}
+	if this.Interval != that1.Interval {
+		return false
+	}
+	return true
+}
+func (this *RuleGroupDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 20)
+	s = append(s, "&rulespb.RuleGroupDesc{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "Namespace: "+fmt.Sprintf("%#v", this.Namespace)+",\n")
+	s = append(s, "Interval: "+fmt.Sprintf("%#v", this.Interval)+",\n")
+	if this.Rules != nil {
+		s = append(s, "Rules: "+fmt.Sprintf("%#v", this.Rules)+",\n")
+	}
+	s = append(s, "User: "+fmt.Sprintf("%#v", this.User)+",\n")
+	if this.Options != nil {
+		s = append(s, "Options: "+fmt.Sprintf("%#v", this.Options)+",\n")
+	}
+	s = append(s, "SourceTenants: "+fmt.Sprintf("%#v", this.SourceTenants)+",\n")
+	if this.ActiveTimeIntervals != nil {
+		vs := make([]*TimeInterval, len(this.ActiveTimeIntervals))
+		for i := range vs {
+			vs[i] = &this.ActiveTimeIntervals[i]
+		}
+		s = append(s, "ActiveTimeIntervals: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "DependsOn: "+fmt.Sprintf("%#v", this.DependsOn)+",\n")
+	s = append(s, "Raw: "+fmt.Sprintf("%#v", this.Raw)+",\n")
+	s = append(s, "UpdatedAt: "+fmt.Sprintf("%#v", this.UpdatedAt)+",\n")
+	s = append(s, "Limit: "+fmt.Sprintf("%#v", this.Limit)+",\n")
+	s = append(s, "FormatVersion: "+fmt.Sprintf("%#v", this.FormatVersion)+",\n")
+	s = append(s, "DestTenants: "+fmt.Sprintf("%#v", this.DestTenants)+",\n")
+	s = append(s, "SourceTenantLabel: "+fmt.Sprintf("%#v", this.SourceTenantLabel)+",\n")
+	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *TimeInterval) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 9)
+	s = append(s, "&rulespb.TimeInterval{")
+	if this.Times != nil {
+		vs := make([]*TimeRange, len(this.Times))
+		for i := range vs {
+			vs[i] = &this.Times[i]
+		}
+		s = append(s, "Times: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	if this.Weekdays != nil {
+		vs := make([]*InclusiveRange, len(this.Weekdays))
+		for i := range vs {
+			vs[i] = &this.Weekdays[i]
+		}
+		s = append(s, "Weekdays: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	if this.DaysOfMonth != nil {
+		vs := make([]*InclusiveRange, len(this.DaysOfMonth))
+		for i := range vs {
+			vs[i] = &this.DaysOfMonth[i]
+		}
+		s = append(s, "DaysOfMonth: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	if this.Months != nil {
+		vs := make([]*InclusiveRange, len(this.Months))
+		for i := range vs {
+			vs[i] = &this.Months[i]
+		}
+		s = append(s, "Months: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	if this.Years != nil {
+		vs := make([]*InclusiveRange, len(this.Years))
+		for i := range vs {
+			vs[i] = &this.Years[i]
+		}
+		s = append(s, "Years: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *TimeRange) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&rulespb.TimeRange{")
+	s = append(s, "StartMinute: "+fmt.Sprintf("%#v", this.StartMinute)+",\n")
+	s = append(s, "EndMinute: "+fmt.Sprintf("%#v", this.EndMinute)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *InclusiveRange) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&rulespb.InclusiveRange{")
+	s = append(s, "Begin: "+fmt.Sprintf("%#v", this.Begin)+",\n")
+	s = append(s, "End: "+fmt.Sprintf("%#v", this.End)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *RuleDesc) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 11)
+	s = append(s, "&rulespb.RuleDesc{")
+	s = append(s, "Expr: "+fmt.Sprintf("%#v", this.Expr)+",\n")
+	s = append(s, "Record: "+fmt.Sprintf("%#v", this.Record)+",\n")
+	s = append(s, "Alert: "+fmt.Sprintf("%#v", this.Alert)+",\n")
 	s = append(s, "For: "+fmt.Sprintf("%#v", this.For)+",\n")
 	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
 	s = append(s, "Annotations: "+fmt.Sprintf("%#v", this.Annotations)+",\n")
+	s = append(s, "Interval: "+fmt.Sprintf("%#v", this.Interval)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -410,6 +957,94 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Labels) > 0 {
+		for iNdEx := len(m.Labels) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Labels[iNdEx].Size()
+				i -= size
+				if _, err := m.Labels[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x9a
+		}
+	}
+	if len(m.SourceTenantLabel) > 0 {
+		i -= len(m.SourceTenantLabel)
+		copy(dAtA[i:], m.SourceTenantLabel)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.SourceTenantLabel)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x92
+	}
+	if len(m.DestTenants) > 0 {
+		for iNdEx := len(m.DestTenants) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.DestTenants[iNdEx])
+			copy(dAtA[i:], m.DestTenants[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.DestTenants[iNdEx])))
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x8a
+		}
+	}
+	if m.FormatVersion != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.FormatVersion))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x80
+	}
+	if m.Limit != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.UpdatedAt != nil {
+		n1, err1 := github_com_gogo_protobuf_types.StdTimeMarshalTo(*m.UpdatedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(*m.UpdatedAt):])
+		if err1 != nil {
+			return 0, err1
+		}
+		i -= n1
+		i = encodeVarintRules(dAtA, i, uint64(n1))
+		i--
+		dAtA[i] = 0x72
+	}
+	if len(m.Raw) > 0 {
+		i -= len(m.Raw)
+		copy(dAtA[i:], m.Raw)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.Raw)))
+		i--
+		dAtA[i] = 0x6a
+	}
+	if len(m.DependsOn) > 0 {
+		for iNdEx := len(m.DependsOn) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.DependsOn[iNdEx])
+			copy(dAtA[i:], m.DependsOn[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.DependsOn[iNdEx])))
+			i--
+			dAtA[i] = 0x62
+		}
+	}
+	if len(m.ActiveTimeIntervals) > 0 {
+		for iNdEx := len(m.ActiveTimeIntervals) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.ActiveTimeIntervals[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x5a
+		}
+	}
 	if len(m.SourceTenants) > 0 {
 		for iNdEx := len(m.SourceTenants) - 1; iNdEx >= 0; iNdEx-- {
 			i -= len(m.SourceTenants[iNdEx])
@@ -454,12 +1089,12 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 			dAtA[i] = 0x22
 		}
 	}
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.Interval, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval):])
-	if err1 != nil {
-		return 0, err1
+	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.Interval, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval):])
+	if err2 != nil {
+		return 0, err2
 	}
-	i -= n1
-	i = encodeVarintRules(dAtA, i, uint64(n1))
+	i -= n2
+	i = encodeVarintRules(dAtA, i, uint64(n2))
 	i--
 	dAtA[i] = 0x1a
 	if len(m.Namespace) > 0 {
@@ -479,7 +1114,7 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *RuleDesc) Marshal() (dAtA []byte, err error) {
+func (m *TimeInterval) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -489,81 +1124,248 @@ func (m *RuleDesc) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *RuleDesc) MarshalTo(dAtA []byte) (int, error) {
+func (m *TimeInterval) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *RuleDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TimeInterval) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.Annotations) > 0 {
-		for iNdEx := len(m.Annotations) - 1; iNdEx >= 0; iNdEx-- {
+	if len(m.Years) > 0 {
+		for iNdEx := len(m.Years) - 1; iNdEx >= 0; iNdEx-- {
 			{
-				size := m.Annotations[iNdEx].Size()
-				i -= size
-				if _, err := m.Annotations[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+				size, err := m.Years[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
 					return 0, err
 				}
+				i -= size
 				i = encodeVarintRules(dAtA, i, uint64(size))
 			}
 			i--
-			dAtA[i] = 0x32
+			dAtA[i] = 0x2a
 		}
 	}
-	if len(m.Labels) > 0 {
-		for iNdEx := len(m.Labels) - 1; iNdEx >= 0; iNdEx-- {
+	if len(m.Months) > 0 {
+		for iNdEx := len(m.Months) - 1; iNdEx >= 0; iNdEx-- {
 			{
-				size := m.Labels[iNdEx].Size()
-				i -= size
-				if _, err := m.Labels[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+				size, err := m.Months[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
 					return 0, err
 				}
+				i -= size
 				i = encodeVarintRules(dAtA, i, uint64(size))
 			}
 			i--
-			dAtA[i] = 0x2a
+			dAtA[i] = 0x22
 		}
 	}
-	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.For, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.For):])
-	if err2 != nil {
-		return 0, err2
-	}
-	i -= n2
-	i = encodeVarintRules(dAtA, i, uint64(n2))
-	i--
-	dAtA[i] = 0x22
-	if len(m.Alert) > 0 {
-		i -= len(m.Alert)
-		copy(dAtA[i:], m.Alert)
-		i = encodeVarintRules(dAtA, i, uint64(len(m.Alert)))
-		i--
-		dAtA[i] = 0x1a
+	if len(m.DaysOfMonth) > 0 {
+		for iNdEx := len(m.DaysOfMonth) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.DaysOfMonth[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
 	}
-	if len(m.Record) > 0 {
-		i -= len(m.Record)
-		copy(dAtA[i:], m.Record)
-		i = encodeVarintRules(dAtA, i, uint64(len(m.Record)))
-		i--
-		dAtA[i] = 0x12
+	if len(m.Weekdays) > 0 {
+		for iNdEx := len(m.Weekdays) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Weekdays[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
 	}
-	if len(m.Expr) > 0 {
-		i -= len(m.Expr)
-		copy(dAtA[i:], m.Expr)
-		i = encodeVarintRules(dAtA, i, uint64(len(m.Expr)))
-		i--
-		dAtA[i] = 0xa
+	if len(m.Times) > 0 {
+		for iNdEx := len(m.Times) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Times[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
 	}
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintRules(dAtA []byte, offset int, v uint64) int {
-	offset -= sovRules(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
+func (m *TimeRange) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TimeRange) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TimeRange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.EndMinute != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.EndMinute))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.StartMinute != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.StartMinute))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *InclusiveRange) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *InclusiveRange) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *InclusiveRange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.End != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.End))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Begin != 0 {
+		i = encodeVarintRules(dAtA, i, uint64(m.Begin))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *RuleDesc) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RuleDesc) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RuleDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.Interval, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintRules(dAtA, i, uint64(n3))
+	i--
+	dAtA[i] = 0x6a
+	if len(m.Annotations) > 0 {
+		for iNdEx := len(m.Annotations) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Annotations[iNdEx].Size()
+				i -= size
+				if _, err := m.Annotations[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x32
+		}
+	}
+	if len(m.Labels) > 0 {
+		for iNdEx := len(m.Labels) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Labels[iNdEx].Size()
+				i -= size
+				if _, err := m.Labels[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintRules(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.For, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.For):])
+	if err4 != nil {
+		return 0, err4
+	}
+	i -= n4
+	i = encodeVarintRules(dAtA, i, uint64(n4))
+	i--
+	dAtA[i] = 0x22
+	if len(m.Alert) > 0 {
+		i -= len(m.Alert)
+		copy(dAtA[i:], m.Alert)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.Alert)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Record) > 0 {
+		i -= len(m.Record)
+		copy(dAtA[i:], m.Record)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.Record)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Expr) > 0 {
+		i -= len(m.Expr)
+		copy(dAtA[i:], m.Expr)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.Expr)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintRules(dAtA []byte, offset int, v uint64) int {
+	offset -= sovRules(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
 		v >>= 7
 		offset++
 	}
@@ -608,6 +1410,117 @@ func (m *RuleGroupDesc) Size() (n int) {
 			n += 1 + l + sovRules(uint64(l))
 		}
 	}
+	if len(m.ActiveTimeIntervals) > 0 {
+		for _, e := range m.ActiveTimeIntervals {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.DependsOn) > 0 {
+		for _, s := range m.DependsOn {
+			l = len(s)
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	l = len(m.Raw)
+	if l > 0 {
+		n += 1 + l + sovRules(uint64(l))
+	}
+	if m.UpdatedAt != nil {
+		l = github_com_gogo_protobuf_types.SizeOfStdTime(*m.UpdatedAt)
+		n += 1 + l + sovRules(uint64(l))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovRules(uint64(m.Limit))
+	}
+	if m.FormatVersion != 0 {
+		n += 2 + sovRules(uint64(m.FormatVersion))
+	}
+	if len(m.DestTenants) > 0 {
+		for _, s := range m.DestTenants {
+			l = len(s)
+			n += 2 + l + sovRules(uint64(l))
+		}
+	}
+	l = len(m.SourceTenantLabel)
+	if l > 0 {
+		n += 2 + l + sovRules(uint64(l))
+	}
+	if len(m.Labels) > 0 {
+		for _, e := range m.Labels {
+			l = e.Size()
+			n += 2 + l + sovRules(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TimeInterval) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Times) > 0 {
+		for _, e := range m.Times {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.Weekdays) > 0 {
+		for _, e := range m.Weekdays {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.DaysOfMonth) > 0 {
+		for _, e := range m.DaysOfMonth {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.Months) > 0 {
+		for _, e := range m.Months {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	if len(m.Years) > 0 {
+		for _, e := range m.Years {
+			l = e.Size()
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TimeRange) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.StartMinute != 0 {
+		n += 1 + sovRules(uint64(m.StartMinute))
+	}
+	if m.EndMinute != 0 {
+		n += 1 + sovRules(uint64(m.EndMinute))
+	}
+	return n
+}
+
+func (m *InclusiveRange) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Begin != 0 {
+		n += 1 + sovRules(uint64(m.Begin))
+	}
+	if m.End != 0 {
+		n += 1 + sovRules(uint64(m.End))
+	}
 	return n
 }
 
@@ -643,6 +1556,8 @@ func (m *RuleDesc) Size() (n int) {
 			n += 1 + l + sovRules(uint64(l))
 		}
 	}
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.Interval)
+	n += 1 + l + sovRules(uint64(l))
 	return n
 }
 
@@ -666,6 +1581,11 @@ func (this *RuleGroupDesc) String() string {
 		repeatedStringForOptions += strings.Replace(fmt.Sprintf("%v", f), "Any", "types.Any", 1) + ","
 	}
 	repeatedStringForOptions += "}"
+	repeatedStringForActiveTimeIntervals := "[]TimeInterval{"
+	for _, f := range this.ActiveTimeIntervals {
+		repeatedStringForActiveTimeIntervals += strings.Replace(strings.Replace(f.String(), "TimeInterval", "TimeInterval", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForActiveTimeIntervals += "}"
 	s := strings.Join([]string{`&RuleGroupDesc{`,
 		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
 		`Namespace:` + fmt.Sprintf("%v", this.Namespace) + `,`,
@@ -674,6 +1594,76 @@ func (this *RuleGroupDesc) String() string {
 		`User:` + fmt.Sprintf("%v", this.User) + `,`,
 		`Options:` + repeatedStringForOptions + `,`,
 		`SourceTenants:` + fmt.Sprintf("%v", this.SourceTenants) + `,`,
+		`ActiveTimeIntervals:` + repeatedStringForActiveTimeIntervals + `,`,
+		`DependsOn:` + fmt.Sprintf("%v", this.DependsOn) + `,`,
+		`Raw:` + fmt.Sprintf("%v", this.Raw) + `,`,
+		`UpdatedAt:` + strings.Replace(fmt.Sprintf("%v", this.UpdatedAt), "Timestamp", "timestamp.Timestamp", 1) + `,`,
+		`Limit:` + fmt.Sprintf("%v", this.Limit) + `,`,
+		`FormatVersion:` + fmt.Sprintf("%v", this.FormatVersion) + `,`,
+		`DestTenants:` + fmt.Sprintf("%v", this.DestTenants) + `,`,
+		`SourceTenantLabel:` + fmt.Sprintf("%v", this.SourceTenantLabel) + `,`,
+		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *TimeInterval) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForTimes := "[]TimeRange{"
+	for _, f := range this.Times {
+		repeatedStringForTimes += strings.Replace(strings.Replace(f.String(), "TimeRange", "TimeRange", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForTimes += "}"
+	repeatedStringForWeekdays := "[]InclusiveRange{"
+	for _, f := range this.Weekdays {
+		repeatedStringForWeekdays += strings.Replace(strings.Replace(f.String(), "InclusiveRange", "InclusiveRange", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForWeekdays += "}"
+	repeatedStringForDaysOfMonth := "[]InclusiveRange{"
+	for _, f := range this.DaysOfMonth {
+		repeatedStringForDaysOfMonth += strings.Replace(strings.Replace(f.String(), "InclusiveRange", "InclusiveRange", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForDaysOfMonth += "}"
+	repeatedStringForMonths := "[]InclusiveRange{"
+	for _, f := range this.Months {
+		repeatedStringForMonths += strings.Replace(strings.Replace(f.String(), "InclusiveRange", "InclusiveRange", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForMonths += "}"
+	repeatedStringForYears := "[]InclusiveRange{"
+	for _, f := range this.Years {
+		repeatedStringForYears += strings.Replace(strings.Replace(f.String(), "InclusiveRange", "InclusiveRange", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForYears += "}"
+	s := strings.Join([]string{`&TimeInterval{`,
+		`Times:` + repeatedStringForTimes + `,`,
+		`Weekdays:` + repeatedStringForWeekdays + `,`,
+		`DaysOfMonth:` + repeatedStringForDaysOfMonth + `,`,
+		`Months:` + repeatedStringForMonths + `,`,
+		`Years:` + repeatedStringForYears + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *TimeRange) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&TimeRange{`,
+		`StartMinute:` + fmt.Sprintf("%v", this.StartMinute) + `,`,
+		`EndMinute:` + fmt.Sprintf("%v", this.EndMinute) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *InclusiveRange) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&InclusiveRange{`,
+		`Begin:` + fmt.Sprintf("%v", this.Begin) + `,`,
+		`End:` + fmt.Sprintf("%v", this.End) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -682,26 +1672,581 @@ func (this *RuleDesc) String() string {
 	if this == nil {
 		return "nil"
 	}
-	s := strings.Join([]string{`&RuleDesc{`,
-		`Expr:` + fmt.Sprintf("%v", this.Expr) + `,`,
-		`Record:` + fmt.Sprintf("%v", this.Record) + `,`,
-		`Alert:` + fmt.Sprintf("%v", this.Alert) + `,`,
-		`For:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.For), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
-		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
-		`Annotations:` + fmt.Sprintf("%v", this.Annotations) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func valueToStringRules(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+	s := strings.Join([]string{`&RuleDesc{`,
+		`Expr:` + fmt.Sprintf("%v", this.Expr) + `,`,
+		`Record:` + fmt.Sprintf("%v", this.Record) + `,`,
+		`Alert:` + fmt.Sprintf("%v", this.Alert) + `,`,
+		`For:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.For), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`Annotations:` + fmt.Sprintf("%v", this.Annotations) + `,`,
+		`Interval:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.Interval), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringRules(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRules
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RuleGroupDesc: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RuleGroupDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Namespace", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Namespace = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Interval", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.Interval, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rules", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Rules = append(m.Rules, &RuleDesc{})
+			if err := m.Rules[len(m.Rules)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field User", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.User = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Options", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Options = append(m.Options, &types.Any{})
+			if err := m.Options[len(m.Options)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SourceTenants", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SourceTenants = append(m.SourceTenants, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveTimeIntervals", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ActiveTimeIntervals = append(m.ActiveTimeIntervals, TimeInterval{})
+			if err := m.ActiveTimeIntervals[len(m.ActiveTimeIntervals)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DependsOn", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DependsOn = append(m.DependsOn, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Raw", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Raw = append(m.Raw[:0], dAtA[iNdEx:postIndex]...)
+			if m.Raw == nil {
+				m.Raw = []byte{}
+			}
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UpdatedAt", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.UpdatedAt == nil {
+				m.UpdatedAt = new(time.Time)
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(m.UpdatedAt, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FormatVersion", wireType)
+			}
+			m.FormatVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FormatVersion |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DestTenants", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DestTenants = append(m.DestTenants, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SourceTenantLabel", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SourceTenantLabel = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Labels = append(m.Labels, github_com_grafana_mimir_pkg_mimirpb.LabelAdapter{})
+			if err := m.Labels[len(m.Labels)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRules(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRules
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRules
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
+	return nil
 }
-func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
+func (m *TimeInterval) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -724,17 +2269,17 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RuleGroupDesc: wiretype end group for non-group")
+			return fmt.Errorf("proto: TimeInterval: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RuleGroupDesc: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TimeInterval: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Times", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRules
@@ -744,29 +2289,31 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRules
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRules
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Name = string(dAtA[iNdEx:postIndex])
+			m.Times = append(m.Times, TimeRange{})
+			if err := m.Times[len(m.Times)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Namespace", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Weekdays", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRules
@@ -776,27 +2323,29 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRules
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRules
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Namespace = string(dAtA[iNdEx:postIndex])
+			m.Weekdays = append(m.Weekdays, InclusiveRange{})
+			if err := m.Weekdays[len(m.Weekdays)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Interval", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field DaysOfMonth", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -823,13 +2372,14 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.Interval, dAtA[iNdEx:postIndex]); err != nil {
+			m.DaysOfMonth = append(m.DaysOfMonth, InclusiveRange{})
+			if err := m.DaysOfMonth[len(m.DaysOfMonth)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
 		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Rules", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Months", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -856,16 +2406,16 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Rules = append(m.Rules, &RuleDesc{})
-			if err := m.Rules[len(m.Rules)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Months = append(m.Months, InclusiveRange{})
+			if err := m.Months[len(m.Months)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 6:
+		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field User", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Years", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRules
@@ -875,29 +2425,84 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthRules
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRules
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.User = string(dAtA[iNdEx:postIndex])
+			m.Years = append(m.Years, InclusiveRange{})
+			if err := m.Years[len(m.Years)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
-		case 9:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Options", wireType)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRules(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			var msglen int
+			if skippy < 0 {
+				return ErrInvalidLengthRules
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRules
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TimeRange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRules
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TimeRange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TimeRange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartMinute", wireType)
+			}
+			m.StartMinute = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRules
@@ -907,31 +2512,88 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.StartMinute |= int32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndMinute", wireType)
+			}
+			m.EndMinute = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EndMinute |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRules(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthRules
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (iNdEx + skippy) < 0 {
 				return ErrInvalidLengthRules
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Options = append(m.Options, &types.Any{})
-			if err := m.Options[len(m.Options)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *InclusiveRange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRules
 			}
-			iNdEx = postIndex
-		case 10:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SourceTenants", wireType)
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			var stringLen uint64
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: InclusiveRange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: InclusiveRange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Begin", wireType)
+			}
+			m.Begin = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRules
@@ -941,24 +2603,30 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.Begin |= int32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRules
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRules
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.End = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.End |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			m.SourceTenants = append(m.SourceTenants, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRules(dAtA[iNdEx:])
@@ -1209,6 +2877,39 @@ func (m *RuleDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Interval", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.Interval, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRules(dAtA[iNdEx:])
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// extensionKeyPrefix is the prefix of the keys ignored by the strict parsing, for example to define YAML
+// anchors referenced by the rules.
+const extensionKeyPrefix = "x-"
+
+var (
+	ruleGroupType = reflect.TypeOf(RuleGroup{})
+	yamlNodeType  = reflect.TypeOf(yaml.Node{})
+
+	// extraFields are the fields of the types which aren't part of their Go struct, keyed by type.
+	extraFields = map[reflect.Type][]string{
+		// The interval of the rules is read by RuleGroup.UnmarshalYAML.
+		reflect.TypeOf(rulefmt.RuleNode{}): {"interval"},
+	}
+)
+
+// UnknownFieldsError is returned by UnmarshalStrict when the rule group has fields which aren't part of
+// the rule group format.
+type UnknownFieldsError struct {
+	// Paths are the paths of the unknown fields, like rules[0].anotations.
+	Paths []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("the rule group has unknown fields: %s", strings.Join(e.Paths, ", "))
+}
+
+// UnmarshalStrict decodes the rule group in the YAML format like yaml.Unmarshal, which silently ignores the
+// unknown fields, but returns an UnknownFieldsError if the rule group has fields which aren't part of the rule
+// group format, except the fields whose key starts with x-.
+func UnmarshalStrict(in []byte, rg *RuleGroup) error {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+		node, err := ExpandAliases(doc.Content[0])
+		if err != nil {
+			return err
+		}
+		var paths []string
+		unknownStructFields(node, ruleGroupType, "", &paths)
+		if len(paths) > 0 {
+			return &UnknownFieldsError{Paths: paths}
+		}
+	}
+	return yaml.Unmarshal(in, rg)
+}
+
+// unknownFields appends to paths the paths of the fields of the YAML node, at path, which aren't fields of
+// the type t it's decoded to. The values decoded by their own YAML or text unmarshaler aren't checked.
+func unknownFields(node *yaml.Node, t reflect.Type, path string, paths *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == yamlNodeType || hasUnmarshaler(t) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		unknownStructFields(node, t, path, paths)
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			unknownFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), paths)
+		}
+	}
+}
+
+func unknownStructFields(node *yaml.Node, t reflect.Type, path string, paths *[]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	fields := map[string]reflect.Type{}
+	structFields(t, fields)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if strings.HasPrefix(key, extensionKeyPrefix) {
+			continue
+		}
+		fieldType, ok := fields[key]
+		if !ok {
+			*paths = append(*paths, joinPath(path, key))
+			continue
+		}
+		if fieldType != nil {
+			unknownFields(node.Content[i+1], fieldType, joinPath(path, key), paths)
+		}
+	}
+}
+
+// structFields adds to fields the types of the fields of the struct type t, keyed by their YAML key, including
+// the fields of its inlined structs. The types of its extra fields are nil.
+func structFields(t reflect.Type, fields map[string]reflect.Type) {
+	for _, key := range extraFields[t] {
+		fields[key] = nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		if util.StringsContain(opts[1:], "inline") {
+			structFields(f.Type, fields)
+			continue
+		}
+		name := opts[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+// hasUnmarshaler returns whether the values of type t are decoded by their own YAML or text unmarshaler.
+func hasUnmarshaler(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	_, yamlUnmarshaler := pt.MethodByName("UnmarshalYAML")
+	_, textUnmarshaler := pt.MethodByName("UnmarshalText")
+	return yamlUnmarshaler || textUnmarshaler
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
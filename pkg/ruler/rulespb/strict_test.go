@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulespb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnmarshalStrict(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedPaths []string
+	}{
+		"known fields": {
+			input: `
+name: test
+interval: 1m
+labels:
+  team: api
+active_time_intervals:
+- weekdays: ["monday:friday"]
+rules:
+- alert: APIDown
+  expr: up{job="api"} == 0
+  for: 5m
+  interval: 2m
+  annotations:
+    summary: The API is down.
+`,
+		},
+		"extension fields": {
+			input: `
+name: test
+x-defaults: &defaults
+  for: 5m
+rules:
+- alert: APIDown
+  expr: up{job="api"} == 0
+  x-owner: api
+  <<: *defaults
+`,
+		},
+		"unknown fields": {
+			input: `
+name: test
+intervl: 1m
+rules:
+- record: up:sum
+  expr: sum(up)
+- alert: APIDown
+  expr: up{job="api"} == 0
+  anotations:
+    summary: The API is down.
+active_time_intervals:
+- weekday: ["monday:friday"]
+`,
+			expectedPaths: []string{"intervl", "rules[1].anotations", "active_time_intervals[0].weekday"},
+		},
+		"unknown fields of merged mappings": {
+			input: `
+name: test
+x-defaults: &defaults
+  fr: 5m
+rules:
+- alert: APIDown
+  expr: up{job="api"} == 0
+  <<: *defaults
+`,
+			expectedPaths: []string{"rules[0].fr"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rg := RuleGroup{}
+			err := UnmarshalStrict([]byte(tc.input), &rg)
+			if len(tc.expectedPaths) == 0 {
+				require.NoError(t, err)
+
+				// The rule group is decoded as by yaml.Unmarshal.
+				expected := RuleGroup{}
+				require.NoError(t, yaml.Unmarshal([]byte(tc.input), &expected))
+				assert.Equal(t, expected, rg)
+				return
+			}
+
+			require.Error(t, err)
+			var unknownFieldsErr *UnknownFieldsError
+			require.ErrorAs(t, err, &unknownFieldsErr)
+			assert.Equal(t, tc.expectedPaths, unknownFieldsErr.Paths)
+		})
+	}
+}
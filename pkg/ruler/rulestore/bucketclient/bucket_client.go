@@ -8,10 +8,14 @@ package bucketclient
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -28,6 +32,8 @@ import (
 const (
 	// The bucket prefix under which all tenants rule groups are stored.
 	rulesPrefix = "rules"
+	// The bucket prefix under which all tenants deleted rule groups are kept until they're purged.
+	trashPrefix = "rules-trash"
 
 	loadConcurrency = 10
 )
@@ -43,21 +49,47 @@ var (
 // using the Thanos objstore.Bucket interface
 type BucketRuleStore struct {
 	bucket      objstore.Bucket
+	trash       objstore.Bucket
+	generations objstore.Bucket
 	cfgProvider bucket.TenantConfigProvider
 	logger      log.Logger
+
+	// The flat layout if zero.
+	layout Layout
+
+	// Disabled if 0.
+	readAfterWriteWindow time.Duration
+	writeLogMtx          sync.Mutex
 }
 
 func NewBucketRuleStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketRuleStore {
 	return &BucketRuleStore{
 		bucket:      bucket.NewPrefixedBucketClient(bkt, rulesPrefix),
+		trash:       bucket.NewPrefixedBucketClient(bkt, trashPrefix),
+		generations: bucket.NewPrefixedBucketClient(bkt, generationsPrefix),
 		cfgProvider: cfgProvider,
 		logger:      logger,
 	}
 }
 
+// WithLayout sets the layout of the objects of the tenants in the bucket.
+func (b *BucketRuleStore) WithLayout(layout Layout) *BucketRuleStore {
+	b.layout = layout
+	return b
+}
+
 // getRuleGroup loads and return a rules group. If existing rule group is supplied, it is Reset and reused. If nil, new RuleGroupDesc is allocated.
 func (b *BucketRuleStore) getRuleGroup(ctx context.Context, userID, namespace, groupName string, rg *rulespb.RuleGroupDesc) (*rulespb.RuleGroupDesc, error) {
-	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	buf, err := b.readRuleGroup(ctx, userID, namespace, groupName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRuleGroup(getRuleGroupObjectKey(namespace, groupName), buf, rg)
+}
+
+// readRuleGroup returns the stored content of a rule group.
+func (b *BucketRuleStore) readRuleGroup(ctx context.Context, userID, namespace, groupName string) ([]byte, error) {
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
 	objectKey := getRuleGroupObjectKey(namespace, groupName)
 
 	reader, err := userBucket.Get(ctx, objectKey)
@@ -75,15 +107,18 @@ func (b *BucketRuleStore) getRuleGroup(ctx context.Context, userID, namespace, g
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read rule group %s", objectKey)
 	}
+	return buf, nil
+}
 
+// decodeRuleGroup decodes the stored content of a rule group into rg, or a new rule group if rg is nil.
+func decodeRuleGroup(objectKey string, buf []byte, rg *rulespb.RuleGroupDesc) (*rulespb.RuleGroupDesc, error) {
 	if rg == nil {
 		rg = &rulespb.RuleGroupDesc{}
 	} else {
 		rg.Reset()
 	}
 
-	err = proto.Unmarshal(buf, rg)
-	if err != nil {
+	if err := proto.Unmarshal(buf, rg); err != nil {
 		return nil, errors.Wrapf(err, "failed to unmarshal rule group %s", objectKey)
 	}
 
@@ -92,11 +127,7 @@ func (b *BucketRuleStore) getRuleGroup(ctx context.Context, userID, namespace, g
 
 // ListAllUsers implements rules.RuleStore.
 func (b *BucketRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
-	var users []string
-	err := b.bucket.Iter(ctx, "", func(user string) error {
-		users = append(users, strings.TrimSuffix(user, objstore.DirDelim))
-		return nil
-	})
+	users, err := b.layout.ListTenants(ctx, b.bucket)
 	if err != nil {
 		return nil, fmt.Errorf("unable to list users in rule store bucket: %w", err)
 	}
@@ -106,7 +137,21 @@ func (b *BucketRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
 
 // ListRuleGroupsForUserAndNamespace implements rules.RuleStore.
 func (b *BucketRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
-	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	writes, err := b.recordedWrites(ctx, userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	groupList, err := b.listRuleGroups(ctx, userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return patchRuleGroupList(userID, groupList, writes), nil
+}
+
+// listRuleGroups returns the rule groups of the namespace listed in the bucket, or of all the namespaces if empty.
+func (b *BucketRuleStore) listRuleGroups(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
 
 	groupList := rulespb.RuleGroupList{}
 
@@ -144,6 +189,21 @@ func (b *BucketRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context,
 func (b *BucketRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) error {
 	ch := make(chan *rulespb.RuleGroupDesc)
 
+	// The rule groups which can't be decoded don't prevent loading the other ones.
+	var (
+		brokenMtx sync.Mutex
+		broken    []rulestore.BrokenRuleGroup
+	)
+	addBroken := func(gr *rulespb.RuleGroupDesc, user, namespace, group string, err error) {
+		// The rule group is left as listed.
+		gr.Reset()
+		gr.User, gr.Namespace, gr.Name = user, namespace, group
+
+		brokenMtx.Lock()
+		defer brokenMtx.Unlock()
+		broken = append(broken, rulestore.BrokenRuleGroup{User: user, Namespace: namespace, Name: group, Err: err.Error()})
+	}
+
 	// Given we store one file per rule group. With this, we create a pool of workers that will
 	// download all rule groups in parallel. We limit the number of workers to avoid a
 	// particular user having too many rule groups rate limiting us with the object storage.
@@ -156,13 +216,18 @@ func (b *BucketRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[s
 					return fmt.Errorf("invalid rule group: user=%q, namespace=%q, group=%q", user, namespace, group)
 				}
 
-				gr, err := b.getRuleGroup(gCtx, user, namespace, group, gr) // reuse group pointer from the map.
+				buf, err := b.readRuleGroup(gCtx, user, namespace, group)
 				if err != nil {
 					return errors.Wrapf(err, "get rule group user=%q, namespace=%q, name=%q", user, namespace, group)
 				}
 
+				if _, err := decodeRuleGroup(getRuleGroupObjectKey(namespace, group), buf, gr); err != nil { // reuse group pointer from the map.
+					addBroken(gr, user, namespace, group, err)
+					continue
+				}
+
 				if user != gr.User || namespace != gr.Namespace || group != gr.Name {
-					return fmt.Errorf("mismatch between requested rule group and loaded rule group, requested: user=%q, namespace=%q, group=%q, loaded: user=%q, namespace=%q, group=%q", user, namespace, group, gr.User, gr.Namespace, gr.Name)
+					addBroken(gr, user, namespace, group, fmt.Errorf("mismatch between requested rule group and loaded rule group, loaded: user=%q, namespace=%q, group=%q", gr.User, gr.Namespace, gr.Name))
 				}
 			}
 
@@ -186,33 +251,67 @@ outer:
 	}
 	close(ch)
 
-	return g.Wait()
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if len(broken) > 0 {
+		return &rulestore.BrokenRuleGroupsError{Groups: broken}
+	}
+	return nil
 }
 
 // GetRuleGroup implements rules.RuleStore.
 func (b *BucketRuleStore) GetRuleGroup(ctx context.Context, userID string, namespace string, group string) (*rulespb.RuleGroupDesc, error) {
-	return b.getRuleGroup(ctx, userID, namespace, group, nil)
+	return b.getConsistentRuleGroup(ctx, userID, namespace, group)
 }
 
 // SetRuleGroup implements rules.RuleStore.
 func (b *BucketRuleStore) SetRuleGroup(ctx context.Context, userID string, namespace string, group *rulespb.RuleGroupDesc) error {
-	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
-	data, err := proto.Marshal(group)
+	// The rule groups written by newer versions of Mimir may have fields unknown to this version,
+	// which would be dropped. The rule groups which can't be decoded are overwritten, to fix them.
+	buf, err := b.readRuleGroup(ctx, userID, namespace, group.Name)
+	if err != nil && !errors.Is(err, rulestore.ErrGroupNotFound) {
+		return err
+	}
+	if err == nil {
+		if stored, err := decodeRuleGroup(getRuleGroupObjectKey(namespace, group.Name), buf, nil); err == nil {
+			if err := rulespb.CheckOverwrite(stored); err != nil {
+				return err
+			}
+		}
+	}
+
+	versioned := *group
+	versioned.FormatVersion = rulespb.CurrentFormatVersion
+
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
+	data, err := proto.Marshal(&versioned)
 	if err != nil {
 		return err
 	}
 
-	return userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group.Name), bytes.NewBuffer(data))
+	if err := userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group.Name), bytes.NewBuffer(data)); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	b.recordWrites(ctx, userID, recordedWrite{Namespace: namespace, Group: group.Name, Checksum: hex.EncodeToString(sum[:])})
+	return nil
 }
 
 // DeleteRuleGroup implements rules.RuleStore.
 func (b *BucketRuleStore) DeleteRuleGroup(ctx context.Context, userID string, namespace string, group string) error {
-	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
 	err := userBucket.Delete(ctx, getRuleGroupObjectKey(namespace, group))
 	if b.bucket.IsObjNotFoundErr(err) {
 		return rulestore.ErrGroupNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	b.recordWrites(ctx, userID, recordedWrite{Namespace: namespace, Group: group, Deleted: true})
+	return nil
 }
 
 // DeleteNamespace implements rules.RuleStore.
@@ -226,7 +325,12 @@ func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, na
 		return rulestore.ErrGroupNamespaceNotFound
 	}
 
-	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
+	deleted := make([]recordedWrite, 0, len(ruleGroupList))
+	defer func() {
+		b.recordWrites(ctx, userID, deleted...)
+	}()
+
 	for _, rg := range ruleGroupList {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -234,10 +338,11 @@ func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, na
 		objectKey := getRuleGroupObjectKey(rg.Namespace, rg.Name)
 		level.Debug(b.logger).Log("msg", "deleting rule group", "user", userID, "namespace", namespace, "key", objectKey)
 		err = userBucket.Delete(ctx, objectKey)
-		if err != nil {
+		if err != nil && !userBucket.IsObjNotFoundErr(err) {
 			level.Error(b.logger).Log("msg", "unable to delete rule group from namespace", "user", userID, "namespace", namespace, "key", objectKey, "err", err)
 			return err
 		}
+		deleted = append(deleted, recordedWrite{Namespace: rg.Namespace, Group: rg.Name, Deleted: true})
 	}
 
 	return nil
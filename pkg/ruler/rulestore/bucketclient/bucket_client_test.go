@@ -6,6 +6,7 @@
 package bucketclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
@@ -42,7 +43,7 @@ func TestListRules(t *testing.T) {
 	}
 
 	for _, g := range groups {
-		desc := rulespb.ToProto(g.user, g.namespace, g.ruleGroup)
+		desc := rulespb.ToProto(g.user, g.namespace, rulespb.RuleGroup{RuleGroup: g.ruleGroup})
 		require.NoError(t, rs.SetRuleGroup(context.Background(), g.user, g.namespace, desc))
 	}
 
@@ -106,7 +107,7 @@ func TestLoadRules(t *testing.T) {
 	}
 
 	for _, g := range groups {
-		desc := rulespb.ToProto(g.user, g.namespace, g.ruleGroup)
+		desc := rulespb.ToProto(g.user, g.namespace, rulespb.RuleGroup{RuleGroup: g.ruleGroup})
 		require.NoError(t, rs.SetRuleGroup(context.Background(), g.user, g.namespace, desc))
 	}
 
@@ -139,32 +140,68 @@ func TestLoadRules(t *testing.T) {
 		require.Len(t, allGroupsMap, 3)
 
 		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
-			{User: "user1", Namespace: "hello", Name: "first testGroup", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+			{User: "user1", Namespace: "hello", Name: "first testGroup", Interval: time.Minute, FormatVersion: rulespb.CurrentFormatVersion, Rules: []*rulespb.RuleDesc{
 				{
 					For:    5 * time.Minute,
 					Labels: []mimirpb.LabelAdapter{{Name: "label1", Value: "value1"}},
 				},
 			}},
-			{User: "user1", Namespace: "hello", Name: "second testGroup", Interval: 2 * time.Minute},
-			{User: "user1", Namespace: "world", Name: "another namespace testGroup", Interval: 1 * time.Hour},
+			{User: "user1", Namespace: "hello", Name: "second testGroup", Interval: 2 * time.Minute, FormatVersion: rulespb.CurrentFormatVersion},
+			{User: "user1", Namespace: "world", Name: "another namespace testGroup", Interval: 1 * time.Hour, FormatVersion: rulespb.CurrentFormatVersion},
 		}, allGroupsMap["user1"])
 
 		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
-			{User: "user2", Namespace: "+-!@#$%. ", Name: "different user", Interval: 5 * time.Minute},
+			{User: "user2", Namespace: "+-!@#$%. ", Name: "different user", Interval: 5 * time.Minute, FormatVersion: rulespb.CurrentFormatVersion},
 		}, allGroupsMap["user2"])
 
 		require.ElementsMatch(t, []*rulespb.RuleGroupDesc{
-			{User: "user3", Namespace: "hello", Name: "third user", SourceTenants: []string{"tenant-1"}},
+			{User: "user3", Namespace: "hello", Name: "third user", SourceTenants: []string{"tenant-1"}, FormatVersion: rulespb.CurrentFormatVersion},
 		}, allGroupsMap["user3"])
 	}
 
-	// Loading group with mismatched info fails.
+	// Loading group with mismatched info reports it as broken.
 	require.NoError(t, rs.SetRuleGroup(context.Background(), "user1", "hello", &rulespb.RuleGroupDesc{User: "user2", Namespace: "world", Name: "first testGroup"}))
-	require.EqualError(t, rs.LoadRuleGroups(context.Background(), allGroupsMap), "mismatch between requested rule group and loaded rule group, requested: user=\"user1\", namespace=\"hello\", group=\"first testGroup\", loaded: user=\"user2\", namespace=\"world\", group=\"first testGroup\"")
+	var brokenErr *rulestore.BrokenRuleGroupsError
+	require.ErrorAs(t, rs.LoadRuleGroups(context.Background(), allGroupsMap), &brokenErr)
+	require.Equal(t, []rulestore.BrokenRuleGroup{{
+		User:      "user1",
+		Namespace: "hello",
+		Name:      "first testGroup",
+		Err:       "mismatch between requested rule group and loaded rule group, loaded: user=\"user2\", namespace=\"world\", group=\"first testGroup\"",
+	}}, brokenErr.Groups)
 
 	// Load with missing rule groups fails.
 	require.NoError(t, rs.DeleteRuleGroup(context.Background(), "user1", "hello", "first testGroup"))
-	require.EqualError(t, rs.LoadRuleGroups(context.Background(), allGroupsMap), "get rule group user=\"user2\", namespace=\"world\", name=\"first testGroup\": group does not exist")
+	require.EqualError(t, rs.LoadRuleGroups(context.Background(), allGroupsMap), "get rule group user=\"user1\", namespace=\"hello\", name=\"first testGroup\": group does not exist")
+}
+
+func TestLoadRules_BrokenRuleGroups(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	for _, name := range []string{"valid", "corrupted"} {
+		desc := rulespb.ToProto("user1", "namespace", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name, Interval: model.Duration(time.Minute)}})
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", desc))
+	}
+	require.NoError(t, bkt.Upload(ctx, rulesPrefix+"/user1/"+getRuleGroupObjectKey("namespace", "corrupted"), bytes.NewReader([]byte("not a rule group"))))
+
+	groups, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	toLoad := map[string]rulespb.RuleGroupList{"user1": groups}
+
+	// The broken rule group doesn't prevent loading the other ones.
+	var brokenErr *rulestore.BrokenRuleGroupsError
+	require.ErrorAs(t, rs.LoadRuleGroups(ctx, toLoad), &brokenErr)
+	require.Len(t, brokenErr.Groups, 1)
+	assert.Equal(t, "corrupted", brokenErr.Groups[0].Name)
+	assert.Contains(t, brokenErr.Groups[0].Err, "failed to unmarshal rule group")
+
+	for _, g := range toLoad["user1"] {
+		if g.Name == "valid" {
+			assert.Equal(t, time.Minute, g.Interval)
+		}
+	}
 }
 
 func TestDelete(t *testing.T) {
@@ -181,7 +218,7 @@ func TestDelete(t *testing.T) {
 	}
 
 	for _, g := range groups {
-		desc := rulespb.ToProto(g.user, g.namespace, g.ruleGroup)
+		desc := rulespb.ToProto(g.user, g.namespace, rulespb.RuleGroup{RuleGroup: g.ruleGroup})
 		require.NoError(t, rs.SetRuleGroup(context.Background(), g.user, g.namespace, desc))
 	}
 
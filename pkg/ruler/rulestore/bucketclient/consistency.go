@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/dskit/backoff"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// When the read-after-write consistency is enabled, the writes of the rule groups of a tenant are recorded in
+// its write log object, with a generation number incremented by each write. The reads of the rule groups of
+// the tenant check that they reflect the writes recorded within the window: the get of a rule group is retried
+// until it returns the written rule group, and the listed rule groups are patched with the written ones.
+// The write log object is rewritten by each write, so the concurrent writes of a tenant from different
+// replicas may lose some records, whose reads are then only eventually consistent.
+
+const (
+	// The bucket prefix under which the write log of each tenant is stored.
+	generationsPrefix = "rules-generations"
+	writeLogObjectKey = "write-wl.json"
+)
+
+var readAfterWriteBackoff = backoff.Config{
+	MinBackoff: 50 * time.Millisecond,
+	MaxBackoff: time.Second,
+	MaxRetries: 8,
+}
+
+// writeLog is the content of the write log object of a tenant.
+type writeLog struct {
+	Generation int64           `json:"generation"`
+	Writes     []recordedWrite `json:"writes"`
+}
+
+// recordedWrite is a write of a rule group recorded in the write log.
+type recordedWrite struct {
+	Generation int64     `json:"generation"`
+	Namespace  string    `json:"namespace"`
+	Group      string    `json:"group"`
+	Deleted    bool      `json:"deleted,omitempty"`
+	Checksum   string    `json:"checksum,omitempty"`
+	WrittenAt  time.Time `json:"written_at"`
+}
+
+// WithReadAfterWriteConsistency makes the reads of the rule groups of a tenant reflect the writes of
+// the rule groups of the tenant done within the window, from any replica. 0 to disable.
+func (b *BucketRuleStore) WithReadAfterWriteConsistency(window time.Duration) *BucketRuleStore {
+	b.readAfterWriteWindow = window
+	return b
+}
+
+// ruleGroupChecksum returns the checksum of the content of the rule group.
+func ruleGroupChecksum(rg *rulespb.RuleGroupDesc) (string, error) {
+	data, err := proto.Marshal(rg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readWriteLog returns the write log of the user, without the writes older than the window.
+func (b *BucketRuleStore) readWriteLog(ctx context.Context, userID string) (writeLog, error) {
+	userGenerations := b.layout.userBucket(userID, b.generations, b.cfgProvider)
+
+	var wl writeLog
+	reader, err := userGenerations.Get(ctx, writeLogObjectKey)
+	if userGenerations.IsObjNotFoundErr(err) {
+		return wl, nil
+	}
+	if err != nil {
+		return wl, errors.Wrap(err, "failed to get the write log of the rule groups")
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return wl, errors.Wrap(err, "failed to read the write log of the rule groups")
+	}
+	if err := json.Unmarshal(data, &wl); err != nil {
+		return wl, errors.Wrap(err, "failed to unmarshal the write log of the rule groups")
+	}
+
+	minWrittenAt := time.Now().Add(-b.readAfterWriteWindow)
+	writes := wl.Writes[:0]
+	for _, w := range wl.Writes {
+		if !w.WrittenAt.Before(minWrittenAt) {
+			writes = append(writes, w)
+		}
+	}
+	wl.Writes = writes
+	return wl, nil
+}
+
+// recordWrites records the writes in the write log of the user, if the read-after-write consistency is enabled.
+// The failures are only logged, because the write of the rule groups already succeeded.
+func (b *BucketRuleStore) recordWrites(ctx context.Context, userID string, writes ...recordedWrite) {
+	if b.readAfterWriteWindow <= 0 || len(writes) == 0 {
+		return
+	}
+
+	b.writeLogMtx.Lock()
+	defer b.writeLogMtx.Unlock()
+
+	if err := b.updateWriteLog(ctx, userID, writes); err != nil {
+		level.Warn(b.logger).Log("msg", "unable to record the writes of rule groups, their reads are eventually consistent", "user", userID, "err", err)
+	}
+}
+
+func (b *BucketRuleStore) updateWriteLog(ctx context.Context, userID string, writes []recordedWrite) error {
+	wl, err := b.readWriteLog(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	wl.Generation++
+	now := time.Now()
+	for _, w := range writes {
+		w.Generation = wl.Generation
+		w.WrittenAt = now
+
+		// Only the last write of each rule group is kept.
+		kept := wl.Writes[:0]
+		for _, prev := range wl.Writes {
+			if prev.Namespace != w.Namespace || prev.Group != w.Group {
+				kept = append(kept, prev)
+			}
+		}
+		wl.Writes = append(kept, w)
+	}
+
+	data, err := json.Marshal(wl)
+	if err != nil {
+		return err
+	}
+	userGenerations := b.layout.userBucket(userID, b.generations, b.cfgProvider)
+	return errors.Wrap(userGenerations.Upload(ctx, writeLogObjectKey, bytes.NewReader(data)), "failed to upload the write log of the rule groups")
+}
+
+// recordedWrites returns the writes of the rule groups of the namespace recorded within the window, or of all the
+// namespaces if empty. It returns no writes if the read-after-write consistency is disabled.
+func (b *BucketRuleStore) recordedWrites(ctx context.Context, userID, namespace string) ([]recordedWrite, error) {
+	if b.readAfterWriteWindow <= 0 {
+		return nil, nil
+	}
+
+	wl, err := b.readWriteLog(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	writes := make([]recordedWrite, 0, len(wl.Writes))
+	for _, w := range wl.Writes {
+		if namespace == "" || w.Namespace == namespace {
+			writes = append(writes, w)
+		}
+	}
+	return writes, nil
+}
+
+// getConsistentRuleGroup returns the rule group, retrying the get until it reflects the last recorded write of the rule group.
+func (b *BucketRuleStore) getConsistentRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error) {
+	writes, err := b.recordedWrites(ctx, userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *recordedWrite
+	for i := range writes {
+		if writes[i].Group == group {
+			last = &writes[i]
+		}
+	}
+	if last == nil {
+		return b.getRuleGroup(ctx, userID, namespace, group, nil)
+	}
+
+	var rg *rulespb.RuleGroupDesc
+	retries := backoff.New(ctx, readAfterWriteBackoff)
+	for retries.Ongoing() {
+		rg, err = b.getRuleGroup(ctx, userID, namespace, group, nil)
+		if err != nil && !errors.Is(err, rulestore.ErrGroupNotFound) {
+			return nil, err
+		}
+
+		if last.Deleted {
+			if err != nil {
+				return nil, err
+			}
+		} else if err == nil {
+			checksum, err := ruleGroupChecksum(rg)
+			if err != nil {
+				return nil, err
+			}
+			if last.Checksum == "" || checksum == last.Checksum {
+				return rg, nil
+			}
+		}
+		retries.Wait()
+	}
+
+	level.Warn(b.logger).Log("msg", "rule group read doesn't reflect its last write", "user", userID, "namespace", namespace, "group", group, "generation", last.Generation)
+	if last.Deleted || rg == nil {
+		return nil, rulestore.ErrGroupNotFound
+	}
+	return rg, nil
+}
+
+// patchRuleGroupList adds the rule groups created and removes the rule groups deleted by the recorded writes from the list.
+func patchRuleGroupList(userID string, list rulespb.RuleGroupList, writes []recordedWrite) rulespb.RuleGroupList {
+	if len(writes) == 0 {
+		return list
+	}
+
+	type key struct{ namespace, group string }
+	last := make(map[key]recordedWrite, len(writes))
+	for _, w := range writes {
+		last[key{w.Namespace, w.Group}] = w
+	}
+
+	patched := make(rulespb.RuleGroupList, 0, len(list)+len(writes))
+	for _, rg := range list {
+		k := key{rg.Namespace, rg.Name}
+		if w, ok := last[k]; ok {
+			delete(last, k)
+			if w.Deleted {
+				continue
+			}
+		}
+		patched = append(patched, rg)
+	}
+	for _, w := range writes {
+		if w, ok := last[key{w.Namespace, w.Group}]; ok && !w.Deleted {
+			delete(last, key{w.Namespace, w.Group})
+			patched = append(patched, &rulespb.RuleGroupDesc{User: userID, Namespace: w.Namespace, Name: w.Group})
+		}
+	}
+	return patched
+}
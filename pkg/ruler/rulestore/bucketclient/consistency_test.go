@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// laggingBucket is an eventually consistent bucket: the writes of the rule groups are visible only
+// after the given number of gets of the rule groups.
+type laggingBucket struct {
+	objstore.Bucket
+
+	mtx     sync.Mutex
+	lag     int
+	gets    int
+	pending map[string][]byte // Nil for a deletion.
+}
+
+func newLaggingBucket(lag int) *laggingBucket {
+	return &laggingBucket{Bucket: objstore.NewInMemBucket(), lag: lag, pending: map[string][]byte{}}
+}
+
+func (b *laggingBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	if !strings.HasPrefix(name, rulesPrefix+"/") {
+		return b.Bucket.Upload(ctx, name, r)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.pending[name] = data
+	b.gets = 0
+	return nil
+}
+
+func (b *laggingBucket) Delete(ctx context.Context, name string) error {
+	if !strings.HasPrefix(name, rulesPrefix+"/") {
+		return b.Bucket.Delete(ctx, name)
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.pending[name] = nil
+	b.gets = 0
+	return nil
+}
+
+func (b *laggingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if strings.HasPrefix(name, rulesPrefix+"/") {
+		b.mtx.Lock()
+		if b.gets++; b.gets > b.lag {
+			for key, data := range b.pending {
+				if data == nil {
+					_ = b.Bucket.Delete(ctx, key)
+				} else {
+					_ = b.Bucket.Upload(ctx, key, bytes.NewReader(data))
+				}
+			}
+			b.pending = map[string][]byte{}
+		}
+		b.mtx.Unlock()
+	}
+	return b.Bucket.Get(ctx, name)
+}
+
+func TestBucketRuleStore_ReadAfterWriteConsistency(t *testing.T) {
+	ctx := context.Background()
+	group := func(name string) *rulespb.RuleGroupDesc {
+		return rulespb.ToProto("user1", "namespace", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}})
+	}
+	listNames := func(t *testing.T, rs *BucketRuleStore) []string {
+		rgs, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+		require.NoError(t, err)
+		names := make([]string, 0, len(rgs))
+		for _, rg := range rgs {
+			names = append(names, rg.Name)
+		}
+		return names
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		rs := NewBucketRuleStore(newLaggingBucket(2), nil, log.NewNopLogger())
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", group("first")))
+
+		assert.Empty(t, listNames(t, rs))
+		_, err := rs.GetRuleGroup(ctx, "user1", "namespace", "first")
+		assert.Equal(t, rulestore.ErrGroupNotFound, err)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		rs := NewBucketRuleStore(newLaggingBucket(2), nil, log.NewNopLogger()).WithReadAfterWriteConsistency(time.Minute)
+
+		// The created rule groups are read.
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", group("first")))
+		assert.Equal(t, []string{"first"}, listNames(t, rs))
+		rg, err := rs.GetRuleGroup(ctx, "user1", "namespace", "first")
+		require.NoError(t, err)
+		assert.Equal(t, "first", rg.Name)
+
+		// The deleted rule groups aren't read.
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", group("second")))
+		require.NoError(t, rs.DeleteRuleGroup(ctx, "user1", "namespace", "first"))
+		assert.Equal(t, []string{"second"}, listNames(t, rs))
+		_, err = rs.GetRuleGroup(ctx, "user1", "namespace", "first")
+		assert.Equal(t, rulestore.ErrGroupNotFound, err)
+
+		// The writes older than the window aren't checked.
+		rs.WithReadAfterWriteConsistency(time.Nanosecond)
+		require.NoError(t, rs.DeleteRuleGroup(ctx, "user1", "namespace", "second"))
+		time.Sleep(time.Millisecond)
+		assert.Equal(t, []string{"second"}, listNames(t, rs))
+	})
+}
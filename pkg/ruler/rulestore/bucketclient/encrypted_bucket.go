@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v3"
+)
+
+// The objects encrypted by the client are envelopes starting with encryptedObjectMagic. The content of each object is
+// encrypted with AES-256-GCM with its own random data key, which is itself encrypted with AES-256-GCM with a key of
+// the keys file. The name of the object is authenticated, so that an encrypted object can't be read under
+// another name, for example as the rule group of another tenant.
+
+var encryptedObjectMagic = []byte("mimir-encrypted-v1\n")
+
+const encryptionKeySize = 32
+
+var (
+	errNoEncryptionKeys       = errors.New("no encryption key in the keys file")
+	errEmptyEncryptionKeyID   = errors.New("empty encryption key ID")
+	errDuplicateEncryptionKey = errors.New("duplicate encryption key ID")
+	errInvalidEncryptionKey   = errors.New("the encryption key must be 32 bytes encoded in base64")
+	errUnknownEncryptionKey   = errors.New("the object is encrypted with a key not in the keys file")
+	errEncryptedObjectRange   = errors.New("range reads of encrypted objects are not supported")
+	errUnencryptedObject      = errors.New("the object isn't encrypted")
+)
+
+// EncryptionKey is a key used to encrypt the data keys of the objects.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+type encryptionKeysFile struct {
+	Keys []struct {
+		ID  string `yaml:"id"`
+		Key string `yaml:"key"`
+	} `yaml:"keys"`
+}
+
+// LoadEncryptionKeys loads the encryption keys of the YAML file at path. The first key is the one used to encrypt.
+func LoadEncryptionKeys(path string) ([]EncryptionKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the encryption keys file")
+	}
+
+	var file encryptionKeysFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the encryption keys file")
+	}
+	if len(file.Keys) == 0 {
+		return nil, errNoEncryptionKeys
+	}
+
+	keys := make([]EncryptionKey, 0, len(file.Keys))
+	seen := make(map[string]bool, len(file.Keys))
+	for _, k := range file.Keys {
+		if k.ID == "" {
+			return nil, errEmptyEncryptionKeyID
+		}
+		if seen[k.ID] {
+			return nil, errors.Wrapf(errDuplicateEncryptionKey, "key %s", k.ID)
+		}
+		seen[k.ID] = true
+
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, errors.Wrapf(errInvalidEncryptionKey, "key %s", k.ID)
+		}
+		keys = append(keys, EncryptionKey{ID: k.ID, Key: key})
+	}
+	return keys, nil
+}
+
+// encryptedObject is the envelope of an object encrypted by the client.
+type encryptedObject struct {
+	KeyID      string `json:"key_id"`
+	KeyNonce   []byte `json:"key_nonce"`
+	DataKey    []byte `json:"data_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedBucket is a bucket encrypting the objects before uploading them, and decrypting them when read.
+// The objects not encrypted, such as the ones uploaded before the encryption is enabled, are rejected, unless
+// allowUnencrypted is set to read them as is while migrating to the encryption.
+type EncryptedBucket struct {
+	objstore.Bucket
+
+	keys             []EncryptionKey
+	allowUnencrypted bool
+}
+
+// NewEncryptedBucket returns a bucket encrypting the objects with the first of the keys, and decrypting them with any of the keys.
+func NewEncryptedBucket(bkt objstore.Bucket, keys []EncryptionKey, allowUnencrypted bool) *EncryptedBucket {
+	return &EncryptedBucket{Bucket: bkt, keys: keys, allowUnencrypted: allowUnencrypted}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, b)
+	return b, err
+}
+
+func (b *EncryptedBucket) encrypt(name string, data []byte) ([]byte, error) {
+	dataKey, err := randomBytes(encryptionKeySize)
+	if err != nil {
+		return nil, err
+	}
+	dataGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(dataGCM.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	key := b.keys[0]
+	keyGCM, err := newGCM(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	keyNonce, err := randomBytes(keyGCM.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := json.Marshal(encryptedObject{
+		KeyID:      key.ID,
+		KeyNonce:   keyNonce,
+		DataKey:    keyGCM.Seal(nil, keyNonce, dataKey, []byte(key.ID)),
+		Nonce:      nonce,
+		Ciphertext: dataGCM.Seal(nil, nonce, data, []byte(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedObjectMagic...), envelope...), nil
+}
+
+func (b *EncryptedBucket) decrypt(name string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedObjectMagic) {
+		if b.allowUnencrypted {
+			return data, nil
+		}
+		return nil, errUnencryptedObject
+	}
+
+	var envelope encryptedObject
+	if err := json.Unmarshal(data[len(encryptedObjectMagic):], &envelope); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal the encrypted object")
+	}
+
+	for _, key := range b.keys {
+		if key.ID != envelope.KeyID {
+			continue
+		}
+		keyGCM, err := newGCM(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := keyGCM.Open(nil, envelope.KeyNonce, envelope.DataKey, []byte(key.ID))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt the data key with key %s", key.ID)
+		}
+		dataGCM, err := newGCM(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := dataGCM.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(name))
+		return plaintext, errors.Wrap(err, "failed to decrypt the object")
+	}
+	return nil, errors.Wrapf(errUnknownEncryptionKey, "key %s", envelope.KeyID)
+}
+
+// Upload implements objstore.Bucket.
+func (b *EncryptedBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	encrypted, err := b.encrypt(name, data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encrypt object %s", name)
+	}
+	return b.Bucket.Upload(ctx, name, bytes.NewReader(encrypted))
+}
+
+// Get implements objstore.Bucket.
+func (b *EncryptedBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, err := b.Bucket.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := b.decrypt(name, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "object %s", name)
+	}
+	return ioutil.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// GetRange implements objstore.Bucket.
+func (b *EncryptedBucket) GetRange(context.Context, string, int64, int64) (io.ReadCloser, error) {
+	return nil, errEncryptedObjectRange
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func writeEncryptionKeysFile(t *testing.T, ids ...string) string {
+	content := "keys:\n"
+	for _, id := range ids {
+		content += fmt.Sprintf("- {id: %s, key: %s}\n", id, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id[:1]), encryptionKeySize)))
+	}
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content     string
+		expectedErr error
+	}{
+		"no keys": {
+			content:     "keys: []\n",
+			expectedErr: errNoEncryptionKeys,
+		},
+		"empty key ID": {
+			content:     "keys:\n- {key: " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "}\n",
+			expectedErr: errEmptyEncryptionKeyID,
+		},
+		"duplicate key ID": {
+			content:     "keys:\n- {id: a, key: " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "}\n- {id: a, key: " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "}\n",
+			expectedErr: errDuplicateEncryptionKey,
+		},
+		"key too short": {
+			content:     "keys:\n- {id: a, key: " + base64.StdEncoding.EncodeToString(make([]byte, 16)) + "}\n",
+			expectedErr: errInvalidEncryptionKey,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+			_, err := LoadEncryptionKeys(path)
+			require.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestEncryptedBucket(t *testing.T) {
+	ctx := context.Background()
+	raw := objstore.NewInMemBucket()
+	read := func(t *testing.T, bkt objstore.Bucket, name string) (string, error) {
+		reader, err := bkt.Get(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data), nil
+	}
+
+	oldKeys, err := LoadEncryptionKeys(writeEncryptionKeysFile(t, "old"))
+	require.NoError(t, err)
+	rotatedKeys, err := LoadEncryptionKeys(writeEncryptionKeysFile(t, "new", "old"))
+	require.NoError(t, err)
+
+	require.NoError(t, raw.Upload(ctx, "plaintext", strings.NewReader("plaintext content")))
+	require.NoError(t, NewEncryptedBucket(raw, oldKeys, false).Upload(ctx, "encrypted", strings.NewReader("secret content")))
+
+	// The object is stored encrypted.
+	stored, err := read(t, raw, "encrypted")
+	require.NoError(t, err)
+	assert.NotContains(t, stored, "secret content")
+
+	// The encrypted objects are read with any key of the file, and the plaintext objects are rejected.
+	bkt := NewEncryptedBucket(raw, rotatedKeys, false)
+	content, err := read(t, bkt, "encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "secret content", content)
+	_, err = read(t, bkt, "plaintext")
+	require.ErrorIs(t, err, errUnencryptedObject)
+
+	// The plaintext objects are read as is while migrating to the encryption.
+	migrating := NewEncryptedBucket(raw, rotatedKeys, true)
+	content, err = read(t, migrating, "plaintext")
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext content", content)
+	content, err = read(t, migrating, "encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "secret content", content)
+
+	// The objects encrypted with a key not in the file aren't read.
+	require.NoError(t, bkt.Upload(ctx, "rotated", strings.NewReader("rotated content")))
+	_, err = read(t, NewEncryptedBucket(raw, oldKeys, false), "rotated")
+	require.ErrorIs(t, err, errUnknownEncryptionKey)
+
+	// The encrypted objects aren't read under another name.
+	require.NoError(t, raw.Upload(ctx, "copied", strings.NewReader(stored)))
+	_, err = read(t, bkt, "copied")
+	require.Error(t, err)
+
+	_, err = bkt.GetRange(ctx, "encrypted", 0, 1)
+	require.ErrorIs(t, err, errEncryptedObjectRange)
+}
+
+func TestBucketRuleStore_ClientSideEncryption(t *testing.T) {
+	ctx := context.Background()
+	keys, err := LoadEncryptionKeys(writeEncryptionKeysFile(t, "key"))
+	require.NoError(t, err)
+
+	raw := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(NewEncryptedBucket(raw, keys, false), nil, log.NewNopLogger())
+
+	rg := rulespb.ToProto("user1", "namespace", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group-with-internal-hostname.example.com"}})
+	require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", rg))
+
+	for name, data := range raw.Objects() {
+		assert.NotContains(t, string(data), "internal-hostname", name)
+	}
+
+	loaded, err := rs.GetRuleGroup(ctx, "user1", "namespace", rg.Name)
+	require.NoError(t, err)
+	assert.Equal(t, rg.Name, loaded.Name)
+
+	// The rule groups are still encrypted when moved to the trash and restored.
+	require.NoError(t, rs.TrashRuleGroup(ctx, "user1", "namespace", rg.Name))
+	require.NoError(t, rs.RestoreRuleGroup(ctx, "user1", "namespace", rg.Name, time.Time{}))
+	for name, data := range raw.Objects() {
+		assert.NotContains(t, string(data), "internal-hostname", name)
+	}
+	loaded, err = rs.GetRuleGroup(ctx, "user1", "namespace", rg.Name)
+	require.NoError(t, err)
+	assert.Equal(t, rg.Name, loaded.Name)
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// MigrateFormat rewrites the rule groups of all the tenants stored with a format version older than
+// rulespb.CurrentFormatVersion, so that the versions of Mimir older than this one refuse to overwrite them.
+// The rule groups stored with a newer format version and the broken ones are left untouched.
+func MigrateFormat(ctx context.Context, store *BucketRuleStore, dryRun bool, logger log.Logger) error {
+	users, err := store.ListAllUsers(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the tenants")
+	}
+
+	for _, userID := range users {
+		groups, err := store.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the rule groups of tenant %s", userID)
+		}
+
+		err = store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: groups})
+		var brokenErr *rulestore.BrokenRuleGroupsError
+		if errors.As(err, &brokenErr) {
+			for _, g := range brokenErr.Groups {
+				level.Warn(logger).Log("msg", "skipping rule group which can't be loaded", "user", userID, "namespace", g.Namespace, "group", g.Name, "err", g.Err)
+			}
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to load the rule groups of tenant %s", userID)
+		}
+		broken := brokenRuleGroupNames(brokenErr)
+
+		migrated := 0
+		for _, g := range groups {
+			if broken[g.Namespace+"/"+g.Name] {
+				continue
+			}
+			if g.FormatVersion > rulespb.CurrentFormatVersion {
+				level.Warn(logger).Log("msg", "skipping rule group stored with a newer format version", "user", userID, "namespace", g.Namespace, "group", g.Name, "format_version", g.FormatVersion)
+				continue
+			}
+			if g.FormatVersion == rulespb.CurrentFormatVersion {
+				continue
+			}
+			if dryRun {
+				level.Info(logger).Log("msg", "would rewrite rule group", "user", userID, "namespace", g.Namespace, "group", g.Name, "from_format_version", g.FormatVersion, "to_format_version", rulespb.CurrentFormatVersion)
+				continue
+			}
+			if err := store.SetRuleGroup(ctx, userID, g.Namespace, g); err != nil {
+				return errors.Wrapf(err, "failed to rewrite rule group %s/%s of tenant %s", g.Namespace, g.Name, userID)
+			}
+			migrated++
+		}
+		level.Info(logger).Log("msg", "migrated the rule groups of the tenant", "user", userID, "groups", migrated)
+	}
+	return nil
+}
+
+func brokenRuleGroupNames(err *rulestore.BrokenRuleGroupsError) map[string]bool {
+	names := map[string]bool{}
+	if err == nil {
+		return names
+	}
+	for _, g := range err.Groups {
+		names[g.Namespace+"/"+g.Name] = true
+	}
+	return names
+}
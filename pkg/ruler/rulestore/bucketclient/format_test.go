@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// uploadRuleGroup stores the rule group as is, bypassing the format version set by the rule store.
+func uploadRuleGroup(t *testing.T, bkt objstore.Bucket, rg *rulespb.RuleGroupDesc) {
+	data, err := proto.Marshal(rg)
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(context.Background(), rulesPrefix+"/"+rg.User+"/"+getRuleGroupObjectKey(rg.Namespace, rg.Name), bytes.NewReader(data)))
+}
+
+func TestBucketRuleStore_FormatVersion(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	// The rule groups are written with the current format version.
+	rg := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group", Interval: time.Minute}
+	require.NoError(t, rs.SetRuleGroup(ctx, "user-1", "namespace", rg))
+	assert.Zero(t, rg.FormatVersion)
+
+	stored, err := rs.GetRuleGroup(ctx, "user-1", "namespace", "group")
+	require.NoError(t, err)
+	assert.Equal(t, rulespb.CurrentFormatVersion, stored.FormatVersion)
+
+	// The rule groups written by a newer version of Mimir are not overwritten.
+	uploadRuleGroup(t, bkt, &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "newer", FormatVersion: rulespb.CurrentFormatVersion + 1})
+	err = rs.SetRuleGroup(ctx, "user-1", "namespace", &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "newer"})
+	var versionErr *rulespb.FormatVersionError
+	require.ErrorAs(t, err, &versionErr)
+	assert.Equal(t, rulespb.CurrentFormatVersion+1, versionErr.Version)
+
+	stored, err = rs.GetRuleGroup(ctx, "user-1", "namespace", "newer")
+	require.NoError(t, err)
+	assert.Equal(t, rulespb.CurrentFormatVersion+1, stored.FormatVersion)
+
+	// The rule groups which can't be decoded are overwritten.
+	require.NoError(t, bkt.Upload(ctx, rulesPrefix+"/user-1/"+getRuleGroupObjectKey("namespace", "corrupted"), bytes.NewReader([]byte("not a rule group"))))
+	require.NoError(t, rs.SetRuleGroup(ctx, "user-1", "namespace", &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "corrupted"}))
+}
+
+func TestMigrateFormat(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	uploadRuleGroup(t, bkt, &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "unversioned", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}})
+	uploadRuleGroup(t, bkt, &rulespb.RuleGroupDesc{User: "user-2", Namespace: "namespace", Name: "newer", FormatVersion: rulespb.CurrentFormatVersion + 1})
+	require.NoError(t, bkt.Upload(ctx, rulesPrefix+"/user-2/"+getRuleGroupObjectKey("namespace", "corrupted"), bytes.NewReader([]byte("not a rule group"))))
+
+	// Nothing is rewritten in dry-run mode.
+	require.NoError(t, MigrateFormat(ctx, rs, true, log.NewNopLogger()))
+	stored, err := rs.GetRuleGroup(ctx, "user-1", "namespace", "unversioned")
+	require.NoError(t, err)
+	assert.Zero(t, stored.FormatVersion)
+
+	require.NoError(t, MigrateFormat(ctx, rs, false, log.NewNopLogger()))
+
+	stored, err = rs.GetRuleGroup(ctx, "user-1", "namespace", "unversioned")
+	require.NoError(t, err)
+	assert.Equal(t, &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "unversioned", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}, FormatVersion: rulespb.CurrentFormatVersion}, stored)
+
+	stored, err = rs.GetRuleGroup(ctx, "user-2", "namespace", "newer")
+	require.NoError(t, err)
+	assert.Equal(t, rulespb.CurrentFormatVersion+1, stored.FormatVersion)
+
+	_, err = rs.GetRuleGroup(ctx, "user-2", "namespace", "corrupted")
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+// Layout is the layout of the objects of the tenants under each prefix of the bucket.
+type Layout struct {
+	cfg rulestore.LayoutConfig
+}
+
+// NewLayout returns the layout configured by cfg, which must be valid.
+func NewLayout(cfg rulestore.LayoutConfig) Layout {
+	return Layout{cfg: cfg}
+}
+
+func (l Layout) hashed() bool {
+	return l.cfg.Layout == rulestore.HashedLayout
+}
+
+func (l Layout) hashPrefix(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:])[:l.cfg.HashPrefixLength]
+}
+
+// TenantPrefix returns the prefix of the objects of the tenant.
+func (l Layout) TenantPrefix(userID string) string {
+	if !l.hashed() {
+		return userID
+	}
+	return l.hashPrefix(userID) + l.cfg.HashPrefixDelimiter + userID
+}
+
+// userBucket returns the bucket client of the objects of the tenant.
+func (l Layout) userBucket(userID string, bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider) objstore.InstrumentedBucket {
+	return bucket.NewSSEBucketClient(userID, bucket.NewPrefixedBucketClient(bkt, l.TenantPrefix(userID)), cfgProvider)
+}
+
+// ListTenants returns the tenants having objects in the bucket. The objects not stored with the layout,
+// for example during a migration to another layout, are ignored.
+func (l Layout) ListTenants(ctx context.Context, bkt objstore.Bucket) ([]string, error) {
+	var tenants []string
+
+	if !l.hashed() {
+		err := bkt.Iter(ctx, "", func(user string) error {
+			tenants = append(tenants, strings.TrimSuffix(user, objstore.DirDelim))
+			return nil
+		})
+		return tenants, err
+	}
+
+	// With a "/" delimiter the tenants are listed in the directory of each hash prefix.
+	var dirs []string
+	if l.cfg.HashPrefixDelimiter == objstore.DirDelim {
+		err := bkt.Iter(ctx, "", func(dir string) error {
+			dirs = append(dirs, dir)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		dirs = []string{""}
+	}
+
+	for _, dir := range dirs {
+		err := bkt.Iter(ctx, dir, func(entry string) error {
+			entry = strings.TrimSuffix(entry, objstore.DirDelim)
+			prefixLength := l.cfg.HashPrefixLength + len(l.cfg.HashPrefixDelimiter)
+			if len(entry) <= prefixLength {
+				return nil
+			}
+			if user := entry[prefixLength:]; l.TenantPrefix(user) == entry {
+				tenants = append(tenants, user)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
+
+// MigrateLayout copies the objects of the tenants of the rule store from a layout to another, deleting
+// them from the source layout if deleteSource is true. The objects already in the destination layout
+// are overwritten.
+func MigrateLayout(ctx context.Context, bkt objstore.Bucket, from, to Layout, deleteSource, dryRun bool, logger log.Logger) error {
+	for _, prefix := range []string{rulesPrefix, trashPrefix, generationsPrefix} {
+		prefixed := bucket.NewPrefixedBucketClient(bkt, prefix)
+
+		tenants, err := from.ListTenants(ctx, prefixed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the tenants under %s", prefix)
+		}
+
+		// The top-level directories of the objects already in the destination layout, for example
+		// copied by a previous migration, may be listed as tenants of the source layout.
+		migrated, err := to.ListTenants(ctx, prefixed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the tenants under %s", prefix)
+		}
+		destinationDirs := make(map[string]bool, len(migrated))
+		for _, userID := range migrated {
+			destinationDirs[strings.SplitN(to.TenantPrefix(userID), objstore.DirDelim, 2)[0]] = true
+		}
+
+		for _, userID := range tenants {
+			src := from.TenantPrefix(userID)
+			dst := to.TenantPrefix(userID)
+			if src == dst || destinationDirs[src] {
+				continue
+			}
+			srcBucket := bucket.NewPrefixedBucketClient(prefixed, src)
+			dstBucket := bucket.NewPrefixedBucketClient(prefixed, dst)
+
+			copied := 0
+			err := srcBucket.Iter(ctx, "", func(key string) error {
+				if dryRun {
+					level.Info(logger).Log("msg", "would copy object", "user", userID, "from", prefix+objstore.DirDelim+src+objstore.DirDelim+key, "to", prefix+objstore.DirDelim+dst+objstore.DirDelim+key)
+					return nil
+				}
+				if err := copyObject(ctx, srcBucket, dstBucket, key); err != nil {
+					return err
+				}
+				if deleteSource {
+					if err := srcBucket.Delete(ctx, key); err != nil {
+						return errors.Wrapf(err, "failed to delete object %s", key)
+					}
+				}
+				copied++
+				return nil
+			}, objstore.WithRecursiveIter)
+			if err != nil {
+				return errors.Wrapf(err, "failed to migrate the objects of tenant %s under %s", userID, prefix)
+			}
+			level.Info(logger).Log("msg", "migrated the objects of the tenant", "user", userID, "prefix", prefix, "objects", copied)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+func TestLayout_TenantPrefix(t *testing.T) {
+	assert.Equal(t, "user-1", NewLayout(rulestore.LayoutConfig{Layout: rulestore.FlatLayout}).TenantPrefix("user-1"))
+	assert.Equal(t, "c6c2/user-1", NewLayout(rulestore.LayoutConfig{Layout: rulestore.HashedLayout, HashPrefixLength: 4, HashPrefixDelimiter: "/"}).TenantPrefix("user-1"))
+	assert.Equal(t, "c6-user-1", NewLayout(rulestore.LayoutConfig{Layout: rulestore.HashedLayout, HashPrefixLength: 2, HashPrefixDelimiter: "-"}).TenantPrefix("user-1"))
+}
+
+func TestBucketRuleStore_Layout(t *testing.T) {
+	ctx := context.Background()
+	users := []string{"user-1", "user-2", "user-3"}
+
+	for name, cfg := range map[string]rulestore.LayoutConfig{
+		"flat":                      {Layout: rulestore.FlatLayout},
+		"hashed with / delimiter":   {Layout: rulestore.HashedLayout, HashPrefixLength: 4, HashPrefixDelimiter: "/"},
+		"hashed with _-_ delimiter": {Layout: rulestore.HashedLayout, HashPrefixLength: 1, HashPrefixDelimiter: "_-_"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bkt := objstore.NewInMemBucket()
+			layout := NewLayout(cfg)
+			rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger()).WithLayout(layout)
+
+			for _, user := range users {
+				rg := rulespb.ToProto(user, "namespace", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}})
+				require.NoError(t, rs.SetRuleGroup(ctx, user, "namespace", rg))
+			}
+
+			// The objects are stored under the prefix of the tenant.
+			for key := range bkt.Objects() {
+				assert.True(t, strings.HasPrefix(key, rulesPrefix+"/"+layout.TenantPrefix("user-1")+"/") ||
+					strings.HasPrefix(key, rulesPrefix+"/"+layout.TenantPrefix("user-2")+"/") ||
+					strings.HasPrefix(key, rulesPrefix+"/"+layout.TenantPrefix("user-3")+"/"), key)
+			}
+
+			listed, err := rs.ListAllUsers(ctx)
+			require.NoError(t, err)
+			sort.Strings(listed)
+			assert.Equal(t, users, listed)
+
+			rgs, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user-2", "")
+			require.NoError(t, err)
+			require.Len(t, rgs, 1)
+			assert.Equal(t, "user-2", rgs[0].User)
+		})
+	}
+}
+
+func TestMigrateLayout(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	flat := NewLayout(rulestore.LayoutConfig{Layout: rulestore.FlatLayout})
+	hashed := NewLayout(rulestore.LayoutConfig{Layout: rulestore.HashedLayout, HashPrefixLength: 4, HashPrefixDelimiter: "/"})
+
+	flatStore := NewBucketRuleStore(bkt, nil, log.NewNopLogger()).WithLayout(flat)
+	hashedStore := NewBucketRuleStore(bkt, nil, log.NewNopLogger()).WithLayout(hashed)
+	for _, group := range []string{"first", "second"} {
+		rg := rulespb.ToProto("user-1", "namespace", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: group}})
+		require.NoError(t, flatStore.SetRuleGroup(ctx, "user-1", "namespace", rg))
+	}
+	require.NoError(t, flatStore.TrashRuleGroup(ctx, "user-1", "namespace", "second"))
+
+	listUsers := func(rs *BucketRuleStore) []string {
+		users, err := rs.ListAllUsers(ctx)
+		require.NoError(t, err)
+		return users
+	}
+
+	// The dry run doesn't copy the objects.
+	require.NoError(t, MigrateLayout(ctx, bkt, flat, hashed, false, true, log.NewNopLogger()))
+	assert.Empty(t, listUsers(hashedStore))
+
+	// The objects are copied, and the rule groups in the trash remain in the trash.
+	require.NoError(t, MigrateLayout(ctx, bkt, flat, hashed, false, false, log.NewNopLogger()))
+	assert.Equal(t, []string{"user-1"}, listUsers(hashedStore))
+	_, err := hashedStore.GetRuleGroup(ctx, "user-1", "namespace", "first")
+	require.NoError(t, err)
+	require.NoError(t, hashedStore.RestoreRuleGroup(ctx, "user-1", "namespace", "second", time.Time{}))
+
+	// The hash prefixes of the migrated tenants aren't migrated as tenants of the flat layout.
+	require.NoError(t, MigrateLayout(ctx, bkt, flat, hashed, true, false, log.NewNopLogger()))
+	assert.Equal(t, []string{"user-1"}, listUsers(hashedStore))
+	assert.Equal(t, []string{hashed.TenantPrefix("user-1")[:4]}, listUsers(flatStore))
+	rgs, err := hashedStore.ListRuleGroupsForUserAndNamespace(ctx, "user-1", "")
+	require.NoError(t, err)
+	assert.Len(t, rgs, 2)
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// The deleted rule groups are kept in the trash with the same object keys as the rule groups. The time
+// they were deleted at is the last modified time of their object in the trash.
+
+// TrashRuleGroup implements rulestore.TrashRuleStore.
+func (b *BucketRuleStore) TrashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	if err := b.trashRuleGroup(ctx, userID, namespace, group); err != nil {
+		return err
+	}
+	b.recordWrites(ctx, userID, recordedWrite{Namespace: namespace, Group: group, Deleted: true})
+	return nil
+}
+
+func (b *BucketRuleStore) trashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
+	userTrash := b.layout.userBucket(userID, b.trash, b.cfgProvider)
+
+	err := moveObject(ctx, userBucket, userTrash, getRuleGroupObjectKey(namespace, group))
+	if userBucket.IsObjNotFoundErr(errors.Cause(err)) {
+		return rulestore.ErrGroupNotFound
+	}
+	return err
+}
+
+// TrashNamespace implements rulestore.TrashRuleStore.
+func (b *BucketRuleStore) TrashNamespace(ctx context.Context, userID, namespace string) error {
+	ruleGroupList, err := b.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(ruleGroupList) == 0 {
+		return rulestore.ErrGroupNamespaceNotFound
+	}
+
+	trashed := make([]recordedWrite, 0, len(ruleGroupList))
+	defer func() {
+		b.recordWrites(ctx, userID, trashed...)
+	}()
+
+	for _, rg := range ruleGroupList {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		level.Debug(b.logger).Log("msg", "moving rule group to the trash", "user", userID, "namespace", namespace, "group", rg.Name)
+		if err := b.trashRuleGroup(ctx, userID, rg.Namespace, rg.Name); err != nil && err != rulestore.ErrGroupNotFound {
+			level.Error(b.logger).Log("msg", "unable to move rule group of namespace to the trash", "user", userID, "namespace", namespace, "group", rg.Name, "err", err)
+			return err
+		}
+		trashed = append(trashed, recordedWrite{Namespace: rg.Namespace, Group: rg.Name, Deleted: true})
+	}
+
+	return nil
+}
+
+// ListTrashedRuleGroups implements rulestore.TrashRuleStore.
+func (b *BucketRuleStore) ListTrashedRuleGroups(ctx context.Context, userID, namespace string, since time.Time) ([]rulestore.TrashedRuleGroup, error) {
+	var trashed []rulestore.TrashedRuleGroup
+	err := b.iterTrash(ctx, userID, namespace, func(_ string, g rulestore.TrashedRuleGroup) error {
+		if !g.DeletedAt.Before(since) {
+			trashed = append(trashed, g)
+		}
+		return nil
+	})
+	return trashed, err
+}
+
+// RestoreRuleGroup implements rulestore.TrashRuleStore.
+func (b *BucketRuleStore) RestoreRuleGroup(ctx context.Context, userID, namespace, group string, since time.Time) error {
+	userBucket := b.layout.userBucket(userID, b.bucket, b.cfgProvider)
+	userTrash := b.layout.userBucket(userID, b.trash, b.cfgProvider)
+	objectKey := getRuleGroupObjectKey(namespace, group)
+
+	attrs, err := userTrash.Attributes(ctx, objectKey)
+	if userTrash.IsObjNotFoundErr(err) || (err == nil && attrs.LastModified.Before(since)) {
+		return rulestore.ErrGroupNotFound
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the attributes of trashed rule group %s", objectKey)
+	}
+
+	exists, err := userBucket.Exists(ctx, objectKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the existence of rule group %s", objectKey)
+	}
+	if exists {
+		return rulestore.ErrGroupAlreadyExists
+	}
+
+	err = moveObject(ctx, userTrash, userBucket, objectKey)
+	if userTrash.IsObjNotFoundErr(errors.Cause(err)) {
+		return rulestore.ErrGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	b.recordWrites(ctx, userID, recordedWrite{Namespace: namespace, Group: group})
+	return nil
+}
+
+// PurgeTrash implements rulestore.TrashRuleStore.
+func (b *BucketRuleStore) PurgeTrash(ctx context.Context, userID string, before time.Time) error {
+	userTrash := b.layout.userBucket(userID, b.trash, b.cfgProvider)
+	return b.iterTrash(ctx, userID, "", func(key string, g rulestore.TrashedRuleGroup) error {
+		if !g.DeletedAt.Before(before) {
+			return nil
+		}
+		level.Debug(b.logger).Log("msg", "purging rule group from the trash", "user", userID, "namespace", g.Namespace, "group", g.Name)
+		if err := userTrash.Delete(ctx, key); err != nil && !userTrash.IsObjNotFoundErr(err) {
+			return errors.Wrapf(err, "failed to purge trashed rule group %s", key)
+		}
+		return nil
+	})
+}
+
+// iterTrash calls f for each rule group of the namespace in the trash of the user, or of all
+// the namespaces if empty.
+func (b *BucketRuleStore) iterTrash(ctx context.Context, userID, namespace string, f func(key string, g rulestore.TrashedRuleGroup) error) error {
+	userTrash := b.layout.userBucket(userID, b.trash, b.cfgProvider)
+
+	prefix := ""
+	if namespace != "" {
+		prefix = getNamespacePrefix(namespace)
+	}
+
+	return userTrash.Iter(ctx, prefix, func(key string) error {
+		namespace, group, err := parseRuleGroupObjectKey(key)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "invalid rule group object key found while listing trashed rule groups", "user", userID, "key", key, "err", err)
+			return nil
+		}
+
+		attrs, err := userTrash.Attributes(ctx, key)
+		if userTrash.IsObjNotFoundErr(err) {
+			// Restored or purged meanwhile.
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the attributes of trashed rule group %s", key)
+		}
+
+		return f(key, rulestore.TrashedRuleGroup{Namespace: namespace, Name: group, DeletedAt: attrs.LastModified})
+	}, objstore.WithRecursiveIter)
+}
+
+// moveObject copies the object at key from src to dst, then deletes it from src.
+func moveObject(ctx context.Context, src, dst objstore.Bucket, key string) error {
+	if err := copyObject(ctx, src, dst, key); err != nil {
+		return err
+	}
+	if err := src.Delete(ctx, key); err != nil {
+		return errors.Wrapf(err, "failed to delete object %s", key)
+	}
+	return nil
+}
+
+// copyObject copies the object at key from src to dst.
+func copyObject(ctx context.Context, src, dst objstore.Bucket, key string) error {
+	reader, err := src.Get(ctx, key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get object %s", key)
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read object %s", key)
+	}
+	if err := dst.Upload(ctx, key, bytes.NewReader(data)); err != nil {
+		return errors.Wrapf(err, "failed to upload object %s", key)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+func TestBucketRuleStore_Trash(t *testing.T) {
+	ctx := context.Background()
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	for _, g := range []testGroup{
+		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "first"}},
+		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "second"}},
+		{user: "user1", namespace: "world", ruleGroup: rulefmt.RuleGroup{Name: "third"}},
+	} {
+		desc := rulespb.ToProto(g.user, g.namespace, rulespb.RuleGroup{RuleGroup: g.ruleGroup})
+		require.NoError(t, rs.SetRuleGroup(ctx, g.user, g.namespace, desc))
+	}
+
+	trashedNames := func(namespace string, since time.Time) []string {
+		trashed, err := rs.ListTrashedRuleGroups(ctx, "user1", namespace, since)
+		require.NoError(t, err)
+		names := make([]string, 0, len(trashed))
+		for _, g := range trashed {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+	groupNames := func() []string {
+		rgs, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+		require.NoError(t, err)
+		names := make([]string, 0, len(rgs))
+		for _, g := range rgs {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+
+	start := time.Now().Add(-time.Minute)
+	require.NoError(t, rs.TrashNamespace(ctx, "user1", "hello"))
+	require.NoError(t, rs.TrashRuleGroup(ctx, "user1", "world", "third"))
+	assert.Equal(t, rulestore.ErrGroupNotFound, rs.TrashRuleGroup(ctx, "user1", "world", "third"))
+	assert.Equal(t, rulestore.ErrGroupNamespaceNotFound, rs.TrashNamespace(ctx, "user1", "hello"))
+
+	assert.Empty(t, groupNames())
+	assert.ElementsMatch(t, []string{"first", "second", "third"}, trashedNames("", start))
+	assert.ElementsMatch(t, []string{"first", "second"}, trashedNames("hello", start))
+	assert.Empty(t, trashedNames("", time.Now().Add(time.Minute)))
+
+	// The restored rule group is the deleted one.
+	require.NoError(t, rs.RestoreRuleGroup(ctx, "user1", "hello", "first", start))
+	restored, err := rs.GetRuleGroup(ctx, "user1", "hello", "first")
+	require.NoError(t, err)
+	assert.Equal(t, "first", restored.Name)
+	assert.Equal(t, []string{"first"}, groupNames())
+	assert.Equal(t, rulestore.ErrGroupNotFound, rs.RestoreRuleGroup(ctx, "user1", "hello", "first", start))
+
+	// The rule groups deleted before since aren't restored.
+	assert.Equal(t, rulestore.ErrGroupNotFound, rs.RestoreRuleGroup(ctx, "user1", "hello", "second", time.Now().Add(time.Minute)))
+
+	// The rule groups with the same name as an existing rule group aren't restored.
+	desc := rulespb.ToProto("user1", "world", rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "third"}})
+	require.NoError(t, rs.SetRuleGroup(ctx, "user1", "world", desc))
+	assert.Equal(t, rulestore.ErrGroupAlreadyExists, rs.RestoreRuleGroup(ctx, "user1", "world", "third", start))
+
+	// Only the rule groups deleted before the given time are purged.
+	require.NoError(t, rs.PurgeTrash(ctx, "user1", start))
+	assert.ElementsMatch(t, []string{"second", "third"}, trashedNames("", start))
+	require.NoError(t, rs.PurgeTrash(ctx, "user1", time.Now().Add(time.Minute)))
+	assert.Empty(t, trashedNames("", start))
+
+	// The trash isn't listed as a tenant.
+	users, err := rs.ListAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user1"}, users)
+}
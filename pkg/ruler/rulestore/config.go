@@ -7,18 +7,115 @@ package rulestore
 
 import (
 	"flag"
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
 
 	"github.com/grafana/mimir/pkg/ruler/rulestore/local"
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/util/fieldcategory"
+)
+
+const (
+	// FlatLayout stores the objects of each tenant under the tenant ID.
+	FlatLayout = "flat"
+	// HashedLayout stores the objects of each tenant under a prefix of the hash of the tenant ID, followed by the tenant ID.
+	HashedLayout = "hashed"
+
+	maxHashPrefixLength = 64
+)
+
+var (
+	supportedLayouts = []string{FlatLayout, HashedLayout}
+
+	errUnsupportedLayout          = errors.New("unsupported rule storage layout")
+	errInvalidHashPrefixLength    = fmt.Errorf("the hash prefix length must be between 1 and %d", maxHashPrefixLength)
+	errInvalidHashPrefixDelimiter = errors.New("the hash prefix delimiter must be non-empty and can't contain hexadecimal digits")
+	errSecondaryLocalBackend      = errors.New("the secondary rule storage isn't supported with the local backend")
 )
 
 // Config configures a rule store.
 type Config struct {
 	bucket.Config `yaml:",inline"`
 	Local         local.Config `yaml:"local"`
+
+	ReadAfterWriteWindow time.Duration              `yaml:"read_after_write_window" category:"experimental"`
+	ClientSideEncryption ClientSideEncryptionConfig `yaml:"client_side_encryption"`
+	Layout               LayoutConfig               `yaml:",inline"`
+
+	Secondary SecondaryConfig `yaml:"secondary"`
+}
+
+// SecondaryConfig configures a secondary object storage, to which the rule groups are written in addition to the
+// rule storage, to migrate the rule groups from a backend to another.
+type SecondaryConfig struct {
+	Enabled       bool `yaml:"enabled" category:"experimental"`
+	bucket.Config `yaml:",inline"`
+}
+
+func (cfg *SecondaryConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Write the rule groups to the secondary object storage too, and read them from it when the rule storage fails, to migrate the rule groups from a rule storage backend to another. The rule groups are compared between both storages on each read. The secondary storage uses the client-side encryption and the layout of the rule storage.")
+
+	// The bucket config is shared with the other components, so its fields are categorized here.
+	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "ruler-secondary", f)
+	f.VisitAll(func(fl *flag.Flag) {
+		if strings.HasPrefix(fl.Name, prefix) {
+			fieldcategory.AddOverride(fl.Name, fieldcategory.Experimental)
+		}
+	})
+}
+
+func (cfg *SecondaryConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	return cfg.Config.Validate()
+}
+
+// LayoutConfig configures the layout of the objects of the tenants in the bucket.
+type LayoutConfig struct {
+	Layout              string `yaml:"layout" category:"experimental"`
+	HashPrefixLength    int    `yaml:"hash_prefix_length" category:"experimental"`
+	HashPrefixDelimiter string `yaml:"hash_prefix_delimiter" category:"experimental"`
+}
+
+func (cfg *LayoutConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Layout, prefix+"layout", FlatLayout, fmt.Sprintf("Layout of the objects of the tenants in the bucket. With the %s layout, the objects of a tenant are stored under its tenant ID. With the %s layout, they're stored under a prefix of the hexadecimal SHA-256 hash of the tenant ID, followed by the tenant ID, to spread the tenants over the partitions of the object storage. Use the migrate-rules-layout tool to move the objects to another layout. Supported values: %s.", FlatLayout, HashedLayout, strings.Join(supportedLayouts, ", ")))
+	f.IntVar(&cfg.HashPrefixLength, prefix+"hash-prefix-length", 4, "Number of hexadecimal digits of the hash of the tenant ID prefixing the objects of the tenant, with the hashed layout.")
+	f.StringVar(&cfg.HashPrefixDelimiter, prefix+"hash-prefix-delimiter", "/", "Delimiter between the hash prefix and the tenant ID, with the hashed layout.")
+}
+
+func (cfg *LayoutConfig) Validate() error {
+	switch cfg.Layout {
+	case FlatLayout:
+		return nil
+	case HashedLayout:
+		if cfg.HashPrefixLength < 1 || cfg.HashPrefixLength > maxHashPrefixLength {
+			return errInvalidHashPrefixLength
+		}
+		if cfg.HashPrefixDelimiter == "" || strings.ContainsAny(cfg.HashPrefixDelimiter, "0123456789abcdef") {
+			return errInvalidHashPrefixDelimiter
+		}
+		return nil
+	default:
+		return errUnsupportedLayout
+	}
+}
+
+// ClientSideEncryptionConfig configures the encryption of the rule groups by the client, before they're
+// uploaded to the object storage.
+type ClientSideEncryptionConfig struct {
+	KeysFile         string `yaml:"keys_file" category:"experimental"`
+	AllowUnencrypted bool   `yaml:"allow_unencrypted" category:"experimental"`
+}
+
+func (cfg *ClientSideEncryptionConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.KeysFile, prefix+"keys-file", "", "Path to the YAML file of the keys used to encrypt the rule groups before uploading them to the object storage, in addition to any server-side encryption. Each rule group is encrypted with its own data key, which is encrypted with the first key of the file. The other keys of the file are only used to decrypt the rule groups encrypted before a key rotation. The rule groups which aren't encrypted are rejected, unless -ruler-storage.client-side-encryption.allow-unencrypted is set. Empty to disable.")
+	f.BoolVar(&cfg.AllowUnencrypted, prefix+"allow-unencrypted", false, "Read the rule groups which aren't encrypted, such as the ones uploaded before the client-side encryption is enabled, as is. Only set it while migrating to the client-side encryption, because anyone with write access to the object storage can then inject rule groups.")
 }
 
 // RegisterFlags registers the backend storage config.
@@ -28,6 +125,25 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.ExtraBackends = []string{local.Name}
 	cfg.Local.RegisterFlagsWithPrefix(prefix, f)
 	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "ruler", f)
+
+	cfg.ClientSideEncryption.RegisterFlagsWithPrefix(prefix+"client-side-encryption.", f)
+	cfg.Layout.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Secondary.RegisterFlagsWithPrefix(prefix+"secondary.", f)
+	f.DurationVar(&cfg.ReadAfterWriteWindow, prefix+"read-after-write-window", 0, "Window after a rule group is written or deleted through the configuration API during which the reads of the rule groups of the tenant reflect the write, from any replica, even if the object storage is eventually consistent. The writes are recorded in a per-tenant object under the rules-generations prefix of the bucket, which is read by each read of the rule groups of the tenant. Only the object storage backends are supported. 0 to disable.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if err := cfg.Config.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Layout.Validate(); err != nil {
+		return err
+	}
+	if cfg.Secondary.Enabled && cfg.Backend == local.Name {
+		return errSecondaryLocalBackend
+	}
+	return cfg.Secondary.Validate()
 }
 
 // IsDefaults returns true if the storage options have not been set.
@@ -6,10 +6,14 @@
 package rulestore
 
 import (
+	"flag"
 	"testing"
 
 	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore/local"
+	"github.com/grafana/mimir/pkg/storage/bucket"
 )
 
 func TestIsDefaults(t *testing.T) {
@@ -45,3 +49,94 @@ func TestIsDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestLayoutConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		setup    func(cfg *LayoutConfig)
+		expected error
+	}{
+		"should pass with the default config": {
+			setup: func(cfg *LayoutConfig) {},
+		},
+		"should pass with the hashed layout": {
+			setup: func(cfg *LayoutConfig) {
+				cfg.Layout = HashedLayout
+			},
+		},
+		"should fail with an unsupported layout": {
+			setup: func(cfg *LayoutConfig) {
+				cfg.Layout = "unknown"
+			},
+			expected: errUnsupportedLayout,
+		},
+		"should fail with an invalid hash prefix length": {
+			setup: func(cfg *LayoutConfig) {
+				cfg.Layout = HashedLayout
+				cfg.HashPrefixLength = 0
+			},
+			expected: errInvalidHashPrefixLength,
+		},
+		"should fail with a hash prefix delimiter containing hexadecimal digits": {
+			setup: func(cfg *LayoutConfig) {
+				cfg.Layout = HashedLayout
+				cfg.HashPrefixDelimiter = "-a"
+			},
+			expected: errInvalidHashPrefixDelimiter,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := LayoutConfig{}
+			cfg.RegisterFlagsWithPrefix("", flag.NewFlagSet("", flag.PanicOnError))
+			testData.setup(&cfg)
+
+			assert.Equal(t, testData.expected, cfg.Validate())
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		setup    func(cfg *Config)
+		expected error
+	}{
+		"should pass with the default config": {
+			setup: func(cfg *Config) {},
+		},
+		"should pass with the secondary storage enabled": {
+			setup: func(cfg *Config) {
+				cfg.Secondary.Enabled = true
+			},
+		},
+		"should not validate the secondary storage if disabled": {
+			setup: func(cfg *Config) {
+				cfg.Secondary.Backend = "unknown"
+			},
+		},
+		"should fail with an unsupported secondary backend": {
+			setup: func(cfg *Config) {
+				cfg.Secondary.Enabled = true
+				cfg.Secondary.Backend = "unknown"
+			},
+			expected: bucket.ErrUnsupportedStorageBackend,
+		},
+		"should fail with the secondary storage enabled and the local backend": {
+			setup: func(cfg *Config) {
+				cfg.Backend = local.Name
+				cfg.Secondary.Enabled = true
+			},
+			expected: errSecondaryLocalBackend,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			testData.setup(&cfg)
+
+			assert.Equal(t, testData.expected, cfg.Validate())
+		})
+	}
+}
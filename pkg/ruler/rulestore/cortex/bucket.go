@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+// The bucket prefix under which Cortex stores the rule groups of all the tenants.
+const rulesPrefix = "rules"
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// ruleGroupFields are the fields of the rule groups with the same meaning in Cortex and Mimir, by number,
+// with their wire type. The field 10 holds the source tenants of the federated rule groups in Mimir, while
+// the Cortex versions may use it for other data, so it's only kept when it's length-delimited.
+var ruleGroupFields = map[uint64]int{
+	1:  wireBytes, // name
+	2:  wireBytes, // namespace
+	3:  wireBytes, // interval
+	4:  wireBytes, // rules
+	6:  wireBytes, // user
+	9:  wireBytes, // options
+	10: wireBytes, // sourceTenants
+}
+
+// ruleFields are the fields of the rules with the same meaning in Cortex and Mimir, by number, with their wire type.
+var ruleFields = map[uint64]int{
+	1: wireBytes, // expr
+	2: wireBytes, // record
+	3: wireBytes, // alert
+	4: wireBytes, // for
+	5: wireBytes, // labels
+	6: wireBytes, // annotations
+}
+
+// ReadBucket reads the rule groups of all the tenants of a Cortex object store, by tenant. The rule groups which
+// can't be read or decoded are logged and skipped.
+func ReadBucket(ctx context.Context, bkt objstore.Bucket, logger log.Logger) (map[string]rulespb.RuleGroupList, error) {
+	rulesBucket := bucket.NewPrefixedBucketClient(bkt, rulesPrefix)
+	groups := map[string]rulespb.RuleGroupList{}
+
+	err := rulesBucket.Iter(ctx, "", func(key string) error {
+		userID, namespace, name, err := parseRuleGroupObjectKey(key)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping object which isn't a rule group", "key", key, "err", err)
+			return nil
+		}
+
+		rg, err := readRuleGroup(ctx, rulesBucket, key, logger)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping rule group which can't be read", "user", userID, "namespace", namespace, "group", name, "err", err)
+			return nil
+		}
+		if rg.User != userID || rg.Namespace != namespace || rg.Name != name {
+			level.Warn(logger).Log("msg", "skipping rule group whose content doesn't match its object key", "user", userID, "namespace", namespace, "group", name)
+			return nil
+		}
+
+		groups[userID] = append(groups[userID], rg)
+		return nil
+	}, objstore.WithRecursiveIter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the rule groups")
+	}
+	return groups, nil
+}
+
+func readRuleGroup(ctx context.Context, bkt objstore.Bucket, key string, logger log.Logger) (*rulespb.RuleGroupDesc, error) {
+	reader, err := bkt.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRuleGroup(buf, func(field uint64) {
+		level.Warn(logger).Log("msg", "dropping rule group field unknown to the migration", "key", key, "field", field)
+	})
+}
+
+// decodeRuleGroup decodes a rule group stored by Cortex, keeping only the fields with the same meaning in
+// Cortex and Mimir. dropped is called with the number of each other field.
+func decodeRuleGroup(buf []byte, dropped func(field uint64)) (*rulespb.RuleGroupDesc, error) {
+	filtered, err := filterFields(buf, ruleGroupFields, func(field uint64, value []byte) ([]byte, error) {
+		if field != 4 {
+			return value, nil
+		}
+		return filterFields(value, ruleFields, nil, dropped)
+	}, dropped)
+	if err != nil {
+		return nil, err
+	}
+
+	rg := &rulespb.RuleGroupDesc{}
+	if err := proto.Unmarshal(filtered, rg); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal rule group")
+	}
+	return rg, nil
+}
+
+// filterFields returns the protobuf encoded message with only the fields of keep having the expected wire type.
+// The length-delimited values of the kept fields are rewritten by nested, if any.
+func filterFields(buf []byte, keep map[uint64]int, nested func(field uint64, value []byte) ([]byte, error), dropped func(field uint64)) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(buf); {
+		key, n := proto.DecodeVarint(buf[i:])
+		if n == 0 {
+			return nil, errors.New("invalid field key")
+		}
+		field, wireType := key>>3, int(key&7)
+		start := i + n
+
+		var end int
+		switch wireType {
+		case wireVarint:
+			_, m := proto.DecodeVarint(buf[start:])
+			if m == 0 {
+				return nil, errors.New("invalid varint")
+			}
+			end = start + m
+		case wireFixed64:
+			end = start + 8
+		case wireFixed32:
+			end = start + 4
+		case wireBytes:
+			length, m := proto.DecodeVarint(buf[start:])
+			if m == 0 || length > uint64(len(buf)-start-m) {
+				return nil, errors.New("invalid length")
+			}
+			start += m
+			end = start + int(length)
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", wireType, field)
+		}
+		if end > len(buf) {
+			return nil, errors.New("unexpected end of message")
+		}
+
+		if expected, ok := keep[field]; !ok || expected != wireType {
+			// The empty length-delimited fields, like the zero durations, carry no data.
+			if dropped != nil && end > start {
+				dropped(field)
+			}
+			i = end
+			continue
+		}
+
+		if wireType != wireBytes {
+			out = append(out, buf[i:end]...)
+			i = end
+			continue
+		}
+		value := buf[start:end]
+		if nested != nil {
+			var err error
+			if value, err = nested(field, value); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, proto.EncodeVarint(key)...)
+		out = append(out, proto.EncodeVarint(uint64(len(value)))...)
+		out = append(out, value...)
+		i = end
+	}
+	return out, nil
+}
+
+// parseRuleGroupObjectKey parses a Cortex bucket object key in the format "<user>/<namespace>/<rule group>",
+// the namespace and the rule group being encoded in base64.
+func parseRuleGroupObjectKey(key string) (userID, namespace, group string, _ error) {
+	parts := strings.Split(key, objstore.DirDelim)
+	if len(parts) != 3 || parts[0] == "" {
+		return "", "", "", errors.New("invalid rule group object key")
+	}
+
+	decodedNamespace, err := base64.URLEncoding.DecodeString(parts[1])
+	if err != nil || len(decodedNamespace) == 0 {
+		return "", "", "", errors.New("invalid namespace")
+	}
+	decodedGroup, err := base64.URLEncoding.DecodeString(parts[2])
+	if err != nil || len(decodedGroup) == 0 {
+		return "", "", "", errors.New("invalid rule group name")
+	}
+	return parts[0], string(decodedNamespace), string(decodedGroup), nil
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func cortexObjectKey(userID, namespace, group string) string {
+	return rulesPrefix + "/" + userID + "/" + base64.URLEncoding.EncodeToString([]byte(namespace)) + "/" + base64.URLEncoding.EncodeToString([]byte(group))
+}
+
+// appendVarintField appends a varint field to a protobuf encoded message.
+func appendVarintField(buf []byte, field, value uint64) []byte {
+	buf = append(buf, proto.EncodeVarint(field<<3|wireVarint)...)
+	return append(buf, proto.EncodeVarint(value)...)
+}
+
+func TestDecodeRuleGroup(t *testing.T) {
+	rg := &rulespb.RuleGroupDesc{
+		User:          "user-1",
+		Namespace:     "namespace",
+		Name:          "group",
+		Interval:      time.Minute,
+		SourceTenants: []string{"tenant-1", "tenant-2"},
+		Rules:         []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}},
+	}
+
+	t.Run("should keep the fields shared by Cortex and Mimir", func(t *testing.T) {
+		buf, err := proto.Marshal(rg)
+		require.NoError(t, err)
+
+		var dropped []uint64
+		decoded, err := decodeRuleGroup(buf, func(field uint64) { dropped = append(dropped, field) })
+		require.NoError(t, err)
+		assert.Equal(t, rg, decoded)
+		assert.Empty(t, dropped)
+	})
+
+	t.Run("should drop the other fields", func(t *testing.T) {
+		withRuleInterval := *rg
+		withRuleInterval.Rules = []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)", Interval: 5 * time.Minute}}
+		buf, err := proto.Marshal(&withRuleInterval)
+		require.NoError(t, err)
+		buf = appendVarintField(buf, 11, 42)
+
+		var dropped []uint64
+		decoded, err := decodeRuleGroup(buf, func(field uint64) { dropped = append(dropped, field) })
+		require.NoError(t, err)
+		assert.Equal(t, rg, decoded)
+		assert.Equal(t, []uint64{13, 11}, dropped)
+	})
+
+	t.Run("should drop the field 10 if it's not length-delimited", func(t *testing.T) {
+		withoutSourceTenants := *rg
+		withoutSourceTenants.SourceTenants = nil
+		buf, err := proto.Marshal(&withoutSourceTenants)
+		require.NoError(t, err)
+		buf = appendVarintField(buf, 10, 100)
+
+		var dropped []uint64
+		decoded, err := decodeRuleGroup(buf, func(field uint64) { dropped = append(dropped, field) })
+		require.NoError(t, err)
+		assert.Equal(t, &withoutSourceTenants, decoded)
+		assert.Equal(t, []uint64{10}, dropped)
+	})
+
+	t.Run("should fail with a truncated message", func(t *testing.T) {
+		buf, err := proto.Marshal(rg)
+		require.NoError(t, err)
+
+		_, err = decodeRuleGroup(buf[:len(buf)-3], nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestReadBucket(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	upload := func(key string, data []byte) {
+		require.NoError(t, bkt.Upload(ctx, key, bytes.NewReader(data)))
+	}
+	for _, rg := range []*rulespb.RuleGroupDesc{
+		{User: "user-1", Namespace: "namespace", Name: "group-1", Interval: time.Minute},
+		{User: "user-1", Namespace: "namespace", Name: "group-2", SourceTenants: []string{"tenant-1"}},
+		{User: "user-2", Namespace: "other", Name: "group"},
+	} {
+		buf, err := proto.Marshal(rg)
+		require.NoError(t, err)
+		upload(cortexObjectKey(rg.User, rg.Namespace, rg.Name), buf)
+	}
+
+	// The objects which aren't valid rule groups are skipped.
+	upload(cortexObjectKey("user-2", "other", "corrupted"), []byte{0xff})
+	mismatching, err := proto.Marshal(&rulespb.RuleGroupDesc{User: "user-3", Namespace: "other", Name: "group"})
+	require.NoError(t, err)
+	upload(cortexObjectKey("user-2", "other", "mismatching"), mismatching)
+	upload(rulesPrefix+"/user-2/not-a-rule-group", []byte("content"))
+
+	groups, err := ReadBucket(ctx, bkt, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]rulespb.RuleGroupList{
+		"user-1": {
+			{User: "user-1", Namespace: "namespace", Name: "group-1", Interval: time.Minute},
+			{User: "user-1", Namespace: "namespace", Name: "group-2", SourceTenants: []string{"tenant-1"}},
+		},
+		"user-2": {
+			{User: "user-2", Namespace: "other", Name: "group"},
+		},
+	}, groups)
+}
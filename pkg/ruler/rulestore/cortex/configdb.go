@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// The endpoint of the Cortex configs service returning the rules configurations of all the tenants.
+const configsRulesPath = "/private/api/prom/configs/rules"
+
+// The rules format version of the Cortex rules configurations in the Prometheus 2.x YAML format.
+const rulesFormatV2 = "2"
+
+type configsResponse struct {
+	Configs map[string]versionedRulesConfig `json:"configs"`
+}
+
+type versionedRulesConfig struct {
+	ID        int         `json:"id"`
+	Config    rulesConfig `json:"config"`
+	DeletedAt time.Time   `json:"deleted_at"`
+}
+
+type rulesConfig struct {
+	Files         map[string]string `json:"rules_files"`
+	FormatVersion string            `json:"rule_format_version"`
+}
+
+// ReadConfigDB reads the rule groups of all the tenants of the Cortex configs service at url, by tenant. Each rule
+// file of a tenant is migrated to a namespace named after it. The tenants whose rules configuration is deleted or
+// isn't in the Prometheus 2.x format, and the rule files which can't be parsed, are logged and skipped.
+func ReadConfigDB(ctx context.Context, client *http.Client, url string, logger log.Logger) (map[string]rulespb.RuleGroupList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+configsRulesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the rules configurations")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the rules configurations: unexpected status %s", resp.Status)
+	}
+
+	var configs configsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&configs); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the rules configurations")
+	}
+
+	groups := map[string]rulespb.RuleGroupList{}
+	for userID, cfg := range configs.Configs {
+		if !cfg.DeletedAt.IsZero() {
+			continue
+		}
+		if cfg.Config.FormatVersion != rulesFormatV2 {
+			level.Warn(logger).Log("msg", "skipping tenant whose rules configuration isn't in the Prometheus 2.x format", "user", userID, "format_version", cfg.Config.FormatVersion)
+			continue
+		}
+
+		files := make([]string, 0, len(cfg.Config.Files))
+		for file := range cfg.Config.Files {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			var ruleFile struct {
+				Groups []rulespb.RuleGroup `yaml:"groups"`
+			}
+			if err := yaml.Unmarshal([]byte(cfg.Config.Files[file]), &ruleFile); err != nil {
+				level.Warn(logger).Log("msg", "skipping rule file which can't be parsed", "user", userID, "file", file, "err", err)
+				continue
+			}
+			for _, rg := range ruleFile.Groups {
+				groups[userID] = append(groups[userID], rulespb.ToProto(userID, file, rg))
+			}
+		}
+	}
+	return groups, nil
+}
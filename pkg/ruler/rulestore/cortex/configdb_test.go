@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestReadConfigDB(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, configsRulesPath, r.URL.Path)
+		_, _ = w.Write([]byte(`{"configs": {
+			"user-1": {"id": 1, "config": {"rule_format_version": "2", "rules_files": {
+				"alerts.yaml": "groups:\n- name: alerts\n  interval: 1m\n  rules:\n  - alert: Down\n    expr: up == 0\n    labels:\n      severity: critical\n",
+				"federated.yaml": "groups:\n- name: federated\n  source_tenants: [tenant-1, tenant-2]\n  rules:\n  - record: up:sum\n    expr: sum(up)\n",
+				"invalid.yaml": "groups: {"
+			}}},
+			"user-2": {"id": 2, "config": {"rule_format_version": "1", "rules_files": {"rules": "up:sum = sum(up)"}}},
+			"user-3": {"id": 3, "config": {"rule_format_version": "2", "rules_files": {"rules.yaml": "groups: []"}}, "deleted_at": "2022-01-01T00:00:00Z"}
+		}}`))
+	}))
+	defer server.Close()
+
+	groups, err := ReadConfigDB(context.Background(), server.Client(), server.URL+"/", log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]rulespb.RuleGroupList{
+		"user-1": {
+			{User: "user-1", Namespace: "alerts.yaml", Name: "alerts", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+				{Alert: "Down", Expr: "up == 0", Labels: []mimirpb.LabelAdapter{{Name: "severity", Value: "critical"}}, Annotations: []mimirpb.LabelAdapter{}},
+			}},
+			{User: "user-1", Namespace: "federated.yaml", Name: "federated", SourceTenants: []string{"tenant-1", "tenant-2"}, Rules: []*rulespb.RuleDesc{
+				{Record: "up:sum", Expr: "sum(up)", Labels: []mimirpb.LabelAdapter{}, Annotations: []mimirpb.LabelAdapter{}},
+			}},
+		},
+	}, groups)
+}
+
+func TestReadConfigDB_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := ReadConfigDB(context.Background(), server.Client(), server.URL, log.NewNopLogger())
+	assert.EqualError(t, err, "failed to get the rules configurations: unexpected status 503 Service Unavailable")
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// Migrate writes the rule groups read from Cortex into the Mimir rule store, by tenant. The rule groups already
+// stored with the same configuration are left untouched, so that the migration can run again, up to the switch
+// from Cortex to Mimir, to migrate the rule groups updated meanwhile.
+func Migrate(ctx context.Context, groups map[string]rulespb.RuleGroupList, store rulestore.RuleStore, dryRun bool, logger log.Logger) error {
+	users := make([]string, 0, len(groups))
+	for userID := range groups {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+
+	for _, userID := range users {
+		migrated, unchanged := 0, 0
+		for _, rg := range groups[userID] {
+			stored, err := store.GetRuleGroup(ctx, userID, rg.Namespace, rg.Name)
+			if err != nil && !errors.Is(err, rulestore.ErrGroupNotFound) {
+				return errors.Wrapf(err, "failed to get rule group %s/%s of tenant %s", rg.Namespace, rg.Name, userID)
+			}
+			if err == nil && sameConfiguration(stored, rg) {
+				unchanged++
+				continue
+			}
+
+			if dryRun {
+				level.Info(logger).Log("msg", "would migrate rule group", "user", userID, "namespace", rg.Namespace, "group", rg.Name)
+				continue
+			}
+			now := time.Now()
+			migratedGroup := *rg
+			migratedGroup.UpdatedAt = &now
+			if err := store.SetRuleGroup(ctx, userID, rg.Namespace, &migratedGroup); err != nil {
+				return errors.Wrapf(err, "failed to store rule group %s/%s of tenant %s", rg.Namespace, rg.Name, userID)
+			}
+			migrated++
+		}
+		level.Info(logger).Log("msg", "migrated the rule groups of the tenant", "user", userID, "migrated", migrated, "unchanged", unchanged)
+	}
+	return nil
+}
+
+// sameConfiguration returns whether the stored rule group has the configuration of the migrated one. The raw
+// content, the update time and the format version of the rule groups are not part of their configuration.
+func sameConfiguration(stored, migrated *rulespb.RuleGroupDesc) bool {
+	s, m := *stored, *migrated
+	s.Raw, m.Raw = nil, nil
+	s.UpdatedAt, m.UpdatedAt = nil, nil
+	s.FormatVersion, m.FormatVersion = 0, 0
+	return s.Equal(&m)
+}
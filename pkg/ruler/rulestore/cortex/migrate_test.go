@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package cortex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	groups := map[string]rulespb.RuleGroupList{
+		"user-1": {
+			{User: "user-1", Namespace: "namespace", Name: "group", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+			{User: "user-1", Namespace: "namespace", Name: "federated", SourceTenants: []string{"tenant-1", "tenant-2"}},
+		},
+	}
+
+	// Nothing is stored in dry-run mode.
+	require.NoError(t, Migrate(ctx, groups, store, true, log.NewNopLogger()))
+	_, err := store.GetRuleGroup(ctx, "user-1", "namespace", "group")
+	require.ErrorIs(t, err, rulestore.ErrGroupNotFound)
+
+	require.NoError(t, Migrate(ctx, groups, store, false, log.NewNopLogger()))
+	for _, rg := range groups["user-1"] {
+		stored, err := store.GetRuleGroup(ctx, "user-1", rg.Namespace, rg.Name)
+		require.NoError(t, err)
+		assert.True(t, sameConfiguration(stored, rg))
+		assert.NotNil(t, stored.UpdatedAt)
+	}
+
+	// The rule groups with the same configuration are not stored again.
+	stored, err := store.GetRuleGroup(ctx, "user-1", "namespace", "group")
+	require.NoError(t, err)
+	require.NoError(t, Migrate(ctx, groups, store, false, log.NewNopLogger()))
+	unchanged, err := store.GetRuleGroup(ctx, "user-1", "namespace", "group")
+	require.NoError(t, err)
+	assert.Equal(t, stored.UpdatedAt, unchanged.UpdatedAt)
+
+	// The updated rule groups are stored again.
+	groups["user-1"][0].Interval = 2 * time.Minute
+	require.NoError(t, Migrate(ctx, groups, store, false, log.NewNopLogger()))
+	updated, err := store.GetRuleGroup(ctx, "user-1", "namespace", "group")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, updated.Interval)
+}
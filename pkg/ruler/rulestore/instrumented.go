@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/instrument"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+type instrumentedMetrics struct {
+	duration      *prometheus.HistogramVec
+	ruleGroupSize *prometheus.HistogramVec
+}
+
+func newInstrumentedMetrics(reg prometheus.Registerer) *instrumentedMetrics {
+	return &instrumentedMetrics{
+		duration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_storage_operation_duration_seconds",
+			Help:    "Time spent in seconds by the operations of the rule store.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"operation", "status_code"}),
+		ruleGroupSize: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_storage_rule_group_size_bytes",
+			Help:    "Size in bytes of the rule groups read from or written to the rule store.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"operation"}),
+	}
+}
+
+// instrumentedRuleStore wraps a RuleStore to track the latency, the outcome and the rule group sizes of
+// its operations, and to trace them.
+type instrumentedRuleStore struct {
+	store   RuleStore
+	metrics *instrumentedMetrics
+}
+
+// instrumentedTrashRuleStore is an instrumentedRuleStore wrapping a TrashRuleStore.
+type instrumentedTrashRuleStore struct {
+	*instrumentedRuleStore
+	store TrashRuleStore
+}
+
+// NewInstrumentedRuleStore wraps the store to expose the metrics of its operations and trace them. The
+// returned store implements TrashRuleStore if the store does.
+func NewInstrumentedRuleStore(store RuleStore, reg prometheus.Registerer) RuleStore {
+	instrumented := &instrumentedRuleStore{store: store, metrics: newInstrumentedMetrics(reg)}
+	if trashStore, ok := store.(TrashRuleStore); ok {
+		return &instrumentedTrashRuleStore{instrumentedRuleStore: instrumented, store: trashStore}
+	}
+	return instrumented
+}
+
+// operationStatusCode converts the error of a rule store operation into an HTTP-like status code.
+func operationStatusCode(err error) string {
+	switch {
+	case err == nil:
+		return "200"
+	case errors.Is(err, ErrGroupNotFound), errors.Is(err, ErrGroupNamespaceNotFound), errors.Is(err, ErrUserNotFound):
+		return "404"
+	case errors.Is(err, ErrGroupAlreadyExists):
+		return "409"
+	case errors.Is(err, context.Canceled):
+		return "cancel"
+	default:
+		return "500"
+	}
+}
+
+// do runs f as the operation of the rule store, tagging its span with the given key-value pairs.
+func (s *instrumentedRuleStore) do(ctx context.Context, operation string, f func(context.Context) error, tags ...string) error {
+	return instrument.CollectedRequest(ctx, "rulestore."+operation, operationCollector{s.metrics.duration, operation}, operationStatusCode, func(ctx context.Context) error {
+		if sp := opentracing.SpanFromContext(ctx); sp != nil {
+			for i := 0; i+1 < len(tags); i += 2 {
+				sp.SetTag(tags[i], tags[i+1])
+			}
+		}
+		return f(ctx)
+	})
+}
+
+func (s *instrumentedRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
+	var users []string
+	err := s.do(ctx, "list_users", func(ctx context.Context) (err error) {
+		users, err = s.store.ListAllUsers(ctx)
+		return err
+	})
+	return users, err
+}
+
+func (s *instrumentedRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
+	var rgs rulespb.RuleGroupList
+	err := s.do(ctx, "list", func(ctx context.Context) (err error) {
+		rgs, err = s.store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+		return err
+	}, "user", userID, "namespace", namespace)
+	return rgs, err
+}
+
+func (s *instrumentedRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) error {
+	err := s.do(ctx, "load", func(ctx context.Context) error {
+		return s.store.LoadRuleGroups(ctx, groupsToLoad)
+	})
+	if err == nil {
+		for _, rgs := range groupsToLoad {
+			for _, rg := range rgs {
+				s.metrics.ruleGroupSize.WithLabelValues("load").Observe(float64(rg.Size()))
+			}
+		}
+	}
+	return err
+}
+
+func (s *instrumentedRuleStore) GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error) {
+	var rg *rulespb.RuleGroupDesc
+	err := s.do(ctx, "get", func(ctx context.Context) (err error) {
+		rg, err = s.store.GetRuleGroup(ctx, userID, namespace, group)
+		return err
+	}, "user", userID, "namespace", namespace, "group", group)
+	if err == nil {
+		s.metrics.ruleGroupSize.WithLabelValues("get").Observe(float64(rg.Size()))
+	}
+	return rg, err
+}
+
+func (s *instrumentedRuleStore) SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error {
+	err := s.do(ctx, "set", func(ctx context.Context) error {
+		return s.store.SetRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group.GetName())
+	if err == nil {
+		s.metrics.ruleGroupSize.WithLabelValues("set").Observe(float64(group.Size()))
+	}
+	return err
+}
+
+func (s *instrumentedRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error {
+	return s.do(ctx, "delete", func(ctx context.Context) error {
+		return s.store.DeleteRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group)
+}
+
+func (s *instrumentedRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
+	return s.do(ctx, "delete_namespace", func(ctx context.Context) error {
+		return s.store.DeleteNamespace(ctx, userID, namespace)
+	}, "user", userID, "namespace", namespace)
+}
+
+func (s *instrumentedTrashRuleStore) TrashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	return s.do(ctx, "trash", func(ctx context.Context) error {
+		return s.store.TrashRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group)
+}
+
+func (s *instrumentedTrashRuleStore) TrashNamespace(ctx context.Context, userID, namespace string) error {
+	return s.do(ctx, "trash_namespace", func(ctx context.Context) error {
+		return s.store.TrashNamespace(ctx, userID, namespace)
+	}, "user", userID, "namespace", namespace)
+}
+
+func (s *instrumentedTrashRuleStore) ListTrashedRuleGroups(ctx context.Context, userID, namespace string, since time.Time) ([]TrashedRuleGroup, error) {
+	var trashed []TrashedRuleGroup
+	err := s.do(ctx, "list_trash", func(ctx context.Context) (err error) {
+		trashed, err = s.store.ListTrashedRuleGroups(ctx, userID, namespace, since)
+		return err
+	}, "user", userID, "namespace", namespace)
+	return trashed, err
+}
+
+func (s *instrumentedTrashRuleStore) RestoreRuleGroup(ctx context.Context, userID, namespace, group string, since time.Time) error {
+	return s.do(ctx, "restore", func(ctx context.Context) error {
+		return s.store.RestoreRuleGroup(ctx, userID, namespace, group, since)
+	}, "user", userID, "namespace", namespace, "group", group)
+}
+
+func (s *instrumentedTrashRuleStore) PurgeTrash(ctx context.Context, userID string, before time.Time) error {
+	return s.do(ctx, "purge_trash", func(ctx context.Context) error {
+		return s.store.PurgeTrash(ctx, userID, before)
+	}, "user", userID)
+}
+
+// operationCollector is an instrument.Collector tracking the duration of the operations of the rule store
+// with the operation label, rather than the traced method name.
+type operationCollector struct {
+	duration  *prometheus.HistogramVec
+	operation string
+}
+
+// Register implements instrument.Collector.
+func (c operationCollector) Register() {}
+
+// Before implements instrument.Collector.
+func (c operationCollector) Before(context.Context, string, time.Time) {}
+
+// After implements instrument.Collector.
+func (c operationCollector) After(_ context.Context, _, statusCode string, start time.Time) {
+	c.duration.WithLabelValues(c.operation, statusCode).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// mapRuleStore is a RuleStore keeping the rule groups of a single user in memory.
+type mapRuleStore struct {
+	groups map[string]*rulespb.RuleGroupDesc
+}
+
+func (s *mapRuleStore) ListAllUsers(_ context.Context) ([]string, error) {
+	return []string{"user1"}, nil
+}
+
+func (s *mapRuleStore) ListRuleGroupsForUserAndNamespace(_ context.Context, _ string, _ string) (rulespb.RuleGroupList, error) {
+	var rgs rulespb.RuleGroupList
+	for _, rg := range s.groups {
+		rgs = append(rgs, rg)
+	}
+	return rgs, nil
+}
+
+func (s *mapRuleStore) LoadRuleGroups(_ context.Context, _ map[string]rulespb.RuleGroupList) error {
+	return nil
+}
+
+func (s *mapRuleStore) GetRuleGroup(_ context.Context, _, _, group string) (*rulespb.RuleGroupDesc, error) {
+	rg, ok := s.groups[group]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	return rg, nil
+}
+
+func (s *mapRuleStore) SetRuleGroup(_ context.Context, _, _ string, group *rulespb.RuleGroupDesc) error {
+	s.groups[group.Name] = group
+	return nil
+}
+
+func (s *mapRuleStore) DeleteRuleGroup(_ context.Context, _, _ string, group string) error {
+	delete(s.groups, group)
+	return nil
+}
+
+func (s *mapRuleStore) DeleteNamespace(_ context.Context, _, _ string) error {
+	return context.Canceled
+}
+
+func TestInstrumentedRuleStore(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	store := NewInstrumentedRuleStore(&mapRuleStore{groups: map[string]*rulespb.RuleGroupDesc{}}, reg)
+
+	// The returned store is a TrashRuleStore only if the wrapped store is.
+	_, ok := store.(TrashRuleStore)
+	assert.False(t, ok)
+
+	rg := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}
+	require.NoError(t, store.SetRuleGroup(ctx, "user1", "namespace", rg))
+	_, err := store.GetRuleGroup(ctx, "user1", "namespace", "group")
+	require.NoError(t, err)
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "missing")
+	require.ErrorIs(t, err, ErrGroupNotFound)
+	require.ErrorIs(t, store.DeleteNamespace(ctx, "user1", "namespace"), context.Canceled)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_storage_rule_group_size_bytes Size in bytes of the rule groups read from or written to the rule store.
+		# TYPE cortex_ruler_storage_rule_group_size_bytes histogram
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="256"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="1024"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="4096"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="16384"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="65536"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="262144"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="1.048576e+06"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="4.194304e+06"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="get",le="+Inf"} 1
+		cortex_ruler_storage_rule_group_size_bytes_sum{operation="get"} 27
+		cortex_ruler_storage_rule_group_size_bytes_count{operation="get"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="256"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="1024"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="4096"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="16384"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="65536"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="262144"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="1.048576e+06"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="4.194304e+06"} 1
+		cortex_ruler_storage_rule_group_size_bytes_bucket{operation="set",le="+Inf"} 1
+		cortex_ruler_storage_rule_group_size_bytes_sum{operation="set"} 27
+		cortex_ruler_storage_rule_group_size_bytes_count{operation="set"} 1
+	`), "cortex_ruler_storage_rule_group_size_bytes"))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "cortex_ruler_storage_operation_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			counts[labels["operation"]+"/"+labels["status_code"]] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, map[string]uint64{"set/200": 1, "get/200": 1, "get/404": 1, "delete_namespace/cancel": 1}, counts)
+}
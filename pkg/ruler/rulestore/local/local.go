@@ -156,10 +156,18 @@ func (l *Client) loadAllRulesGroupsForUserAndNamespace(_ context.Context, userID
 		return nil, errors.Wrapf(allErrors[0], "error parsing %s", filename)
 	}
 
+	// The rule groups of a file are last updated when the file is.
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to stat rule file %s", filename)
+	}
+	updatedAt := info.ModTime()
+
 	var list rulespb.RuleGroupList
 
 	for _, group := range rulegroups.Groups {
-		desc := rulespb.ToProto(userID, namespace, group)
+		desc := rulespb.ToProto(userID, namespace, rulespb.RuleGroup{RuleGroup: group})
+		desc.UpdatedAt = &updatedAt
 		list = append(list, desc)
 	}
 
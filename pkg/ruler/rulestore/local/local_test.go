@@ -58,6 +58,9 @@ func TestClient_LoadAllRuleGroups(t *testing.T) {
 
 	err = ioutil.WriteFile(path.Join(dir, user1, namespace1), b, 0777)
 	require.NoError(t, err)
+	info, err := os.Stat(path.Join(dir, user1, namespace1))
+	require.NoError(t, err)
+	updatedAt := info.ModTime()
 
 	const ignoredDir = "ignored-dir"
 	err = os.Mkdir(path.Join(dir, user1, ignoredDir), os.ModeDir|0644)
@@ -90,7 +93,11 @@ func TestClient_LoadAllRuleGroups(t *testing.T) {
 
 		require.Equal(t, 2, len(actual))
 		// We rely on the fact that files are parsed in alphabetical order, and our namespace1 < namespace2.
-		require.Equal(t, rulespb.ToProto(u, namespace1, ruleGroups.Groups[0]), actual[0])
-		require.Equal(t, rulespb.ToProto(u, namespace2, ruleGroups.Groups[0]), actual[1])
+		for i, ns := range []string{namespace1, namespace2} {
+			// The rule groups are last updated when their file is.
+			expected := rulespb.ToProto(u, ns, rulespb.RuleGroup{RuleGroup: ruleGroups.Groups[0]})
+			expected.UpdatedAt = &updatedAt
+			require.Equal(t, expected, actual[i])
+		}
 	}
 }
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const (
+	comparisonMatch    = "match"
+	comparisonMismatch = "mismatch"
+	comparisonError    = "error"
+)
+
+type mirroredMetrics struct {
+	writeFailures *prometheus.CounterVec
+	fallbacks     *prometheus.CounterVec
+	comparisons   *prometheus.CounterVec
+}
+
+func newMirroredMetrics(reg prometheus.Registerer) *mirroredMetrics {
+	return &mirroredMetrics{
+		writeFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_storage_secondary_write_failures_total",
+			Help: "Total number of writes to the secondary rule store which failed while the write to the rule store succeeded.",
+		}, []string{"operation"}),
+		fallbacks: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_storage_secondary_fallbacks_total",
+			Help: "Total number of reads served by the secondary rule store because the read from the rule store failed.",
+		}, []string{"operation"}),
+		comparisons: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_storage_secondary_comparisons_total",
+			Help: "Total number of comparisons of the reads from the rule store and the secondary rule store, by result.",
+		}, []string{"operation", "result"}),
+	}
+}
+
+// mirroredRuleStore writes the rule groups to a primary and a secondary RuleStore, and reads them from the
+// primary store, falling back to the secondary store when the primary store fails. The reads from both stores
+// are compared, to tell when the secondary store holds the same rule groups as the primary one.
+type mirroredRuleStore struct {
+	primary   RuleStore
+	secondary RuleStore
+	logger    log.Logger
+	metrics   *mirroredMetrics
+}
+
+// mirroredTrashRuleStore is a mirroredRuleStore of two TrashRuleStores.
+type mirroredTrashRuleStore struct {
+	*mirroredRuleStore
+	primary   TrashRuleStore
+	secondary TrashRuleStore
+}
+
+// NewMirroredRuleStore returns a RuleStore writing the rule groups to both stores and reading them from the
+// primary store, falling back to the secondary store. The errors of the secondary store are logged and counted,
+// but never returned if the primary store succeeds. The returned store implements TrashRuleStore if both
+// stores do.
+func NewMirroredRuleStore(primary, secondary RuleStore, logger log.Logger, reg prometheus.Registerer) RuleStore {
+	mirrored := &mirroredRuleStore{primary: primary, secondary: secondary, logger: logger, metrics: newMirroredMetrics(reg)}
+
+	primaryTrash, primaryOK := primary.(TrashRuleStore)
+	secondaryTrash, secondaryOK := secondary.(TrashRuleStore)
+	if primaryOK && secondaryOK {
+		return &mirroredTrashRuleStore{mirroredRuleStore: mirrored, primary: primaryTrash, secondary: secondaryTrash}
+	}
+	return mirrored
+}
+
+// read runs the read of the primary store and, concurrently, the read of the secondary store. If the primary
+// read fails with an error other than not found, the result of the secondary read is used instead. Otherwise
+// both results are compared with equal, which is only called if both reads succeed.
+func (s *mirroredRuleStore) read(operation string, read func(RuleStore) (interface{}, error), equal func(primary, secondary interface{}) bool) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	secondaryResult := make(chan result, 1)
+	go func() {
+		value, err := read(s.secondary)
+		secondaryResult <- result{value, err}
+	}()
+
+	value, err := read(s.primary)
+	secondary := <-secondaryResult
+
+	if err != nil && !isNotFound(err) {
+		if secondary.err != nil {
+			return nil, err
+		}
+		level.Warn(s.logger).Log("msg", "reading from the secondary rule store because the rule store failed", "operation", operation, "err", err)
+		s.metrics.fallbacks.WithLabelValues(operation).Inc()
+		return secondary.value, nil
+	}
+
+	switch {
+	case secondary.err != nil && !isNotFound(secondary.err):
+		s.metrics.comparisons.WithLabelValues(operation, comparisonError).Inc()
+	case (err == nil) == (secondary.err == nil) && (err != nil || equal(value, secondary.value)):
+		s.metrics.comparisons.WithLabelValues(operation, comparisonMatch).Inc()
+	default:
+		s.metrics.comparisons.WithLabelValues(operation, comparisonMismatch).Inc()
+	}
+	return value, err
+}
+
+// write runs the write against the primary store, then against the secondary store if it succeeds.
+func (s *mirroredRuleStore) write(operation string, write func(RuleStore) error, logKeyvals ...interface{}) error {
+	if err := write(s.primary); err != nil {
+		return err
+	}
+	if err := write(s.secondary); err != nil && !isNotFound(err) {
+		level.Warn(s.logger).Log(append([]interface{}{"msg", "failed to write to the secondary rule store", "operation", operation, "err", err}, logKeyvals...)...)
+		s.metrics.writeFailures.WithLabelValues(operation).Inc()
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrGroupNotFound) || errors.Is(err, ErrGroupNamespaceNotFound) || errors.Is(err, ErrUserNotFound)
+}
+
+func (s *mirroredRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
+	users, err := s.read("list_users", func(store RuleStore) (interface{}, error) {
+		return store.ListAllUsers(ctx)
+	}, func(primary, secondary interface{}) bool {
+		return sameStrings(primary.([]string), secondary.([]string))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users.([]string), nil
+}
+
+func (s *mirroredRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
+	rgs, err := s.read("list", func(store RuleStore) (interface{}, error) {
+		return store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	}, func(primary, secondary interface{}) bool {
+		return sameStrings(ruleGroupKeys(primary.(rulespb.RuleGroupList)), ruleGroupKeys(secondary.(rulespb.RuleGroupList)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rgs.(rulespb.RuleGroupList), nil
+}
+
+// LoadRuleGroups loads the rule groups from the primary store, or from the secondary store if the primary store
+// fails. The loaded rule groups aren't compared, since they're compared when listed.
+func (s *mirroredRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) error {
+	err := s.primary.LoadRuleGroups(ctx, groupsToLoad)
+	var brokenErr *BrokenRuleGroupsError
+	if err == nil || errors.As(err, &brokenErr) {
+		return err
+	}
+
+	secondaryErr := s.secondary.LoadRuleGroups(ctx, groupsToLoad)
+	if secondaryErr != nil && !errors.As(secondaryErr, &brokenErr) {
+		return err
+	}
+	level.Warn(s.logger).Log("msg", "loaded the rule groups from the secondary rule store because the rule store failed", "err", err)
+	s.metrics.fallbacks.WithLabelValues("load").Inc()
+	return secondaryErr
+}
+
+func (s *mirroredRuleStore) GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error) {
+	rg, err := s.read("get", func(store RuleStore) (interface{}, error) {
+		return store.GetRuleGroup(ctx, userID, namespace, group)
+	}, func(primary, secondary interface{}) bool {
+		return primary.(*rulespb.RuleGroupDesc).Equal(secondary.(*rulespb.RuleGroupDesc))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rg.(*rulespb.RuleGroupDesc), nil
+}
+
+func (s *mirroredRuleStore) SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error {
+	return s.write("set", func(store RuleStore) error {
+		return store.SetRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group.GetName())
+}
+
+func (s *mirroredRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error {
+	return s.write("delete", func(store RuleStore) error {
+		return store.DeleteRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group)
+}
+
+func (s *mirroredRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
+	return s.write("delete_namespace", func(store RuleStore) error {
+		return store.DeleteNamespace(ctx, userID, namespace)
+	}, "user", userID, "namespace", namespace)
+}
+
+func (s *mirroredTrashRuleStore) TrashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	return s.write("trash", func(store RuleStore) error {
+		return store.(TrashRuleStore).TrashRuleGroup(ctx, userID, namespace, group)
+	}, "user", userID, "namespace", namespace, "group", group)
+}
+
+func (s *mirroredTrashRuleStore) TrashNamespace(ctx context.Context, userID, namespace string) error {
+	return s.write("trash_namespace", func(store RuleStore) error {
+		return store.(TrashRuleStore).TrashNamespace(ctx, userID, namespace)
+	}, "user", userID, "namespace", namespace)
+}
+
+// ListTrashedRuleGroups lists the trash of the primary store only, since the trash of the secondary store
+// may lack the rule groups deleted before the secondary store is enabled.
+func (s *mirroredTrashRuleStore) ListTrashedRuleGroups(ctx context.Context, userID, namespace string, since time.Time) ([]TrashedRuleGroup, error) {
+	return s.primary.ListTrashedRuleGroups(ctx, userID, namespace, since)
+}
+
+func (s *mirroredTrashRuleStore) RestoreRuleGroup(ctx context.Context, userID, namespace, group string, since time.Time) error {
+	if err := s.primary.RestoreRuleGroup(ctx, userID, namespace, group, since); err != nil {
+		return err
+	}
+
+	// The rule group may be missing from the trash of the secondary store, so the restored rule group is
+	// copied from the primary store instead.
+	rg, err := s.primary.GetRuleGroup(ctx, userID, namespace, group)
+	if err == nil {
+		err = s.secondary.SetRuleGroup(ctx, userID, namespace, rg)
+	}
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to write to the secondary rule store", "operation", "restore", "err", err, "user", userID, "namespace", namespace, "group", group)
+		s.metrics.writeFailures.WithLabelValues("restore").Inc()
+	}
+	return nil
+}
+
+func (s *mirroredTrashRuleStore) PurgeTrash(ctx context.Context, userID string, before time.Time) error {
+	return s.write("purge_trash", func(store RuleStore) error {
+		return store.(TrashRuleStore).PurgeTrash(ctx, userID, before)
+	}, "user", userID)
+}
+
+// ruleGroupKeys returns the namespace and the name of each rule group.
+func ruleGroupKeys(rgs rulespb.RuleGroupList) []string {
+	keys := make([]string, 0, len(rgs))
+	for _, rg := range rgs {
+		keys = append(keys, rg.Namespace+"/"+rg.Name)
+	}
+	return keys
+}
+
+// sameStrings returns whether both slices hold the same strings, in any order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
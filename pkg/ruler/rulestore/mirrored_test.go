@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+var errStoreUnavailable = errors.New("store unavailable")
+
+// failingRuleStore is a mapRuleStore whose operations fail while failing is set.
+type failingRuleStore struct {
+	*mapRuleStore
+	failing bool
+}
+
+func newFailingRuleStore() *failingRuleStore {
+	return &failingRuleStore{mapRuleStore: &mapRuleStore{groups: map[string]*rulespb.RuleGroupDesc{}}}
+}
+
+func (s *failingRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
+	if s.failing {
+		return nil, errStoreUnavailable
+	}
+	return s.mapRuleStore.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+}
+
+func (s *failingRuleStore) GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error) {
+	if s.failing {
+		return nil, errStoreUnavailable
+	}
+	return s.mapRuleStore.GetRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s *failingRuleStore) SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error {
+	if s.failing {
+		return errStoreUnavailable
+	}
+	return s.mapRuleStore.SetRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s *failingRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error {
+	if s.failing {
+		return errStoreUnavailable
+	}
+	if _, ok := s.groups[group]; !ok {
+		return ErrGroupNotFound
+	}
+	return s.mapRuleStore.DeleteRuleGroup(ctx, userID, namespace, group)
+}
+
+func TestMirroredRuleStore(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	primary, secondary := newFailingRuleStore(), newFailingRuleStore()
+	store := NewMirroredRuleStore(primary, secondary, log.NewNopLogger(), reg)
+
+	// The returned store is a TrashRuleStore only if both stores are.
+	_, ok := store.(TrashRuleStore)
+	assert.False(t, ok)
+
+	// The rule group already in the primary store is missing from the secondary store.
+	existing := &rulespb.RuleGroupDesc{Name: "existing", Namespace: "namespace", User: "user1"}
+	primary.groups[existing.Name] = existing
+	_, err := store.GetRuleGroup(ctx, "user1", "namespace", "existing")
+	require.NoError(t, err)
+
+	// The writes go to both stores.
+	rg := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}
+	require.NoError(t, store.SetRuleGroup(ctx, "user1", "namespace", rg))
+	assert.Contains(t, secondary.groups, "group")
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "group")
+	require.NoError(t, err)
+
+	// Once the secondary store is backfilled, the listings match.
+	secondary.groups[existing.Name] = existing
+	rgs, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	assert.Len(t, rgs, 2)
+
+	// The reads fall back to the secondary store when the primary store fails.
+	primary.failing = true
+	got, err := store.GetRuleGroup(ctx, "user1", "namespace", "group")
+	require.NoError(t, err)
+	assert.Equal(t, rg, got)
+
+	// The writes fail with the primary store, without writing to the secondary store.
+	require.ErrorIs(t, store.DeleteRuleGroup(ctx, "user1", "namespace", "group"), errStoreUnavailable)
+	assert.Contains(t, secondary.groups, "group")
+
+	// The failures of the secondary store are only counted.
+	primary.failing, secondary.failing = false, true
+	require.NoError(t, store.DeleteRuleGroup(ctx, "user1", "namespace", "group"))
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "existing")
+	require.NoError(t, err)
+
+	// Both stores failing fail the reads with the error of the primary store.
+	primary.failing = true
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "existing")
+	require.ErrorIs(t, err, errStoreUnavailable)
+
+	// The rule groups missing from both stores match.
+	primary.failing, secondary.failing = false, false
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "missing")
+	require.ErrorIs(t, err, ErrGroupNotFound)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_storage_secondary_comparisons_total Total number of comparisons of the reads from the rule store and the secondary rule store, by result.
+		# TYPE cortex_ruler_storage_secondary_comparisons_total counter
+		cortex_ruler_storage_secondary_comparisons_total{operation="get",result="error"} 1
+		cortex_ruler_storage_secondary_comparisons_total{operation="get",result="match"} 2
+		cortex_ruler_storage_secondary_comparisons_total{operation="get",result="mismatch"} 1
+		cortex_ruler_storage_secondary_comparisons_total{operation="list",result="match"} 1
+		# HELP cortex_ruler_storage_secondary_fallbacks_total Total number of reads served by the secondary rule store because the read from the rule store failed.
+		# TYPE cortex_ruler_storage_secondary_fallbacks_total counter
+		cortex_ruler_storage_secondary_fallbacks_total{operation="get"} 1
+		# HELP cortex_ruler_storage_secondary_write_failures_total Total number of writes to the secondary rule store which failed while the write to the rule store succeeded.
+		# TYPE cortex_ruler_storage_secondary_write_failures_total counter
+		cortex_ruler_storage_secondary_write_failures_total{operation="delete"} 1
+	`)))
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+var errTrashSupportChanged = errors.New("the rule store can't be replaced by a store with a different support of the trash of the deleted rule groups, which requires a restart")
+
+// ReloadableRuleStore wraps a RuleStore which can be replaced while it's used, to apply the changes of the
+// rule storage configuration without restarting. The operations in progress complete with the replaced store.
+type ReloadableRuleStore struct {
+	trash bool
+
+	mtx   sync.RWMutex
+	store RuleStore
+}
+
+// reloadableTrashRuleStore is a ReloadableRuleStore wrapping a TrashRuleStore.
+type reloadableTrashRuleStore struct {
+	*ReloadableRuleStore
+}
+
+// NewReloadableRuleStore wraps the store so that it can be replaced with Reload.
+func NewReloadableRuleStore(store RuleStore) *ReloadableRuleStore {
+	_, trash := store.(TrashRuleStore)
+	return &ReloadableRuleStore{store: store, trash: trash}
+}
+
+// Store returns the RuleStore using the current store. It implements TrashRuleStore if the wrapped
+// store does.
+func (s *ReloadableRuleStore) Store() RuleStore {
+	if s.trash {
+		return reloadableTrashRuleStore{s}
+	}
+	return s
+}
+
+// Reload replaces the store. The new store must implement TrashRuleStore if and only if the replaced
+// store does, because the users of Store may rely on it.
+func (s *ReloadableRuleStore) Reload(store RuleStore) error {
+	if _, trash := store.(TrashRuleStore); trash != s.trash {
+		return errTrashSupportChanged
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.store = store
+	return nil
+}
+
+func (s *ReloadableRuleStore) current() RuleStore {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.store
+}
+
+func (s *ReloadableRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
+	return s.current().ListAllUsers(ctx)
+}
+
+func (s *ReloadableRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rulespb.RuleGroupList, error) {
+	return s.current().ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+}
+
+func (s *ReloadableRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) error {
+	return s.current().LoadRuleGroups(ctx, groupsToLoad)
+}
+
+func (s *ReloadableRuleStore) GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error) {
+	return s.current().GetRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s *ReloadableRuleStore) SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error {
+	return s.current().SetRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s *ReloadableRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error {
+	return s.current().DeleteRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s *ReloadableRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
+	return s.current().DeleteNamespace(ctx, userID, namespace)
+}
+
+func (s reloadableTrashRuleStore) currentTrash() TrashRuleStore {
+	return s.current().(TrashRuleStore)
+}
+
+func (s reloadableTrashRuleStore) TrashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	return s.currentTrash().TrashRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s reloadableTrashRuleStore) TrashNamespace(ctx context.Context, userID, namespace string) error {
+	return s.currentTrash().TrashNamespace(ctx, userID, namespace)
+}
+
+func (s reloadableTrashRuleStore) ListTrashedRuleGroups(ctx context.Context, userID, namespace string, since time.Time) ([]TrashedRuleGroup, error) {
+	return s.currentTrash().ListTrashedRuleGroups(ctx, userID, namespace, since)
+}
+
+func (s reloadableTrashRuleStore) RestoreRuleGroup(ctx context.Context, userID, namespace, group string, since time.Time) error {
+	return s.currentTrash().RestoreRuleGroup(ctx, userID, namespace, group, since)
+}
+
+func (s reloadableTrashRuleStore) PurgeTrash(ctx context.Context, userID string, before time.Time) error {
+	return s.currentTrash().PurgeTrash(ctx, userID, before)
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rulestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// trashMapRuleStore is a mapRuleStore implementing TrashRuleStore, whose trash is always empty.
+type trashMapRuleStore struct {
+	*mapRuleStore
+}
+
+func (s trashMapRuleStore) TrashRuleGroup(ctx context.Context, userID, namespace, group string) error {
+	return s.DeleteRuleGroup(ctx, userID, namespace, group)
+}
+
+func (s trashMapRuleStore) TrashNamespace(ctx context.Context, userID, namespace string) error {
+	return s.DeleteNamespace(ctx, userID, namespace)
+}
+
+func (s trashMapRuleStore) ListTrashedRuleGroups(context.Context, string, string, time.Time) ([]TrashedRuleGroup, error) {
+	return nil, nil
+}
+
+func (s trashMapRuleStore) RestoreRuleGroup(context.Context, string, string, string, time.Time) error {
+	return ErrGroupNotFound
+}
+
+func (s trashMapRuleStore) PurgeTrash(context.Context, string, time.Time) error {
+	return nil
+}
+
+func TestReloadableRuleStore(t *testing.T) {
+	ctx := context.Background()
+	rg := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}
+
+	first := &mapRuleStore{groups: map[string]*rulespb.RuleGroupDesc{"group": rg}}
+	reloadable := NewReloadableRuleStore(first)
+	store := reloadable.Store()
+	_, ok := store.(TrashRuleStore)
+	assert.False(t, ok)
+
+	got, err := store.GetRuleGroup(ctx, "user1", "namespace", "group")
+	require.NoError(t, err)
+	assert.Equal(t, rg, got)
+
+	// The store is replaced for the users of the returned store.
+	second := &mapRuleStore{groups: map[string]*rulespb.RuleGroupDesc{}}
+	require.NoError(t, reloadable.Reload(second))
+	_, err = store.GetRuleGroup(ctx, "user1", "namespace", "group")
+	require.ErrorIs(t, err, ErrGroupNotFound)
+	require.NoError(t, store.SetRuleGroup(ctx, "user1", "namespace", rg))
+	assert.Contains(t, second.groups, "group")
+
+	// The support of the trash can't change.
+	require.ErrorIs(t, reloadable.Reload(trashMapRuleStore{first}), errTrashSupportChanged)
+	rgs, err := store.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	assert.Len(t, rgs, 1)
+
+	trashReloadable := NewReloadableRuleStore(trashMapRuleStore{first})
+	trashStore, ok := trashReloadable.Store().(TrashRuleStore)
+	require.True(t, ok)
+	require.ErrorIs(t, trashReloadable.Reload(second), errTrashSupportChanged)
+	require.NoError(t, trashReloadable.Reload(trashMapRuleStore{second}))
+	require.NoError(t, trashStore.TrashRuleGroup(ctx, "user1", "namespace", "group"))
+	assert.NotContains(t, second.groups, "group")
+	assert.Contains(t, first.groups, "group")
+}
@@ -8,6 +8,8 @@ package rulestore
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -19,8 +21,30 @@ var (
 	ErrGroupNamespaceNotFound = errors.New("group namespace does not exist")
 	// ErrUserNotFound is returned if the user does not currently exist
 	ErrUserNotFound = errors.New("no rule groups found for user")
+	// ErrGroupAlreadyExists is returned if a rule group to restore from the trash already exists
+	ErrGroupAlreadyExists = errors.New("group already exists")
 )
 
+// BrokenRuleGroup is a rule group whose stored content can't be decoded, for example because it
+// was written by a newer version or is corrupted.
+type BrokenRuleGroup struct {
+	User      string `json:"user" yaml:"user"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Err       string `json:"error" yaml:"error"`
+}
+
+// BrokenRuleGroupsError is returned by LoadRuleGroups when some rule groups can't be decoded.
+// The other rule groups are loaded.
+type BrokenRuleGroupsError struct {
+	Groups []BrokenRuleGroup
+}
+
+func (e *BrokenRuleGroupsError) Error() string {
+	g := e.Groups[0]
+	return fmt.Sprintf("%d rule groups can't be decoded, including user=%q, namespace=%q, group=%q: %s", len(e.Groups), g.User, g.Namespace, g.Name, g.Err)
+}
+
 // RuleStore is used to store and retrieve rules.
 // Methods starting with "List" prefix may return partially loaded groups: with only group Name, Namespace and User fields set.
 // To make sure that rules within each group are loaded, client must use LoadRuleGroups method.
@@ -39,6 +63,8 @@ type RuleStore interface {
 	// NOTE: The RuleGroupList map passed to this method *MAY* be filtered for
 	// sharding purposes. It *MUST* populate the rules if the List methods have
 	// not populated the rule groups with their actual rules.
+	// If some rule groups can't be decoded, it *MAY* load the other ones and return
+	// a *BrokenRuleGroupsError listing them.
 	LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) error
 
 	GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error)
@@ -51,3 +77,32 @@ type RuleStore interface {
 	// If namespace is empty, deletes all rule groups for user.
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
 }
+
+// TrashedRuleGroup identifies a rule group in the trash.
+type TrashedRuleGroup struct {
+	Namespace string
+	Name      string
+	DeletedAt time.Time
+}
+
+// TrashRuleStore is a RuleStore able to move the deleted rule groups to a trash, from which they can be restored.
+type TrashRuleStore interface {
+	RuleStore
+
+	// TrashRuleGroup moves a single rule group to the trash.
+	TrashRuleGroup(ctx context.Context, userID, namespace, group string) error
+
+	// TrashNamespace moves all the rule groups of the namespace to the trash.
+	TrashNamespace(ctx context.Context, userID, namespace string) error
+
+	// ListTrashedRuleGroups returns the rule groups of the namespace in the trash, deleted at or after since.
+	// If namespace is empty, the rule groups of all namespaces are returned.
+	ListTrashedRuleGroups(ctx context.Context, userID, namespace string, since time.Time) ([]TrashedRuleGroup, error)
+
+	// RestoreRuleGroup moves a rule group deleted at or after since from the trash back to the rule groups.
+	// It returns ErrGroupAlreadyExists if a rule group with the same name exists.
+	RestoreRuleGroup(ctx context.Context, userID, namespace, group string, since time.Time) error
+
+	// PurgeTrash permanently deletes the rule groups in the trash deleted before the given time.
+	PurgeTrash(ctx context.Context, userID string, before time.Time) error
+}
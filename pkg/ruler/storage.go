@@ -12,6 +12,7 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/thanos-io/thanos/pkg/objstore"
 
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
@@ -29,15 +30,39 @@ func NewRuleStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.
 		level.Warn(logger).Log("msg", "-ruler-storage.backend=filesystem is for development and testing only; you should switch to an external object store for production use or use a shared filesystem")
 	}
 
-	bucketClient, err := bucket.NewClient(ctx, cfg.Config, "ruler-storage", logger, reg)
+	store, err := newBucketRuleStore(ctx, cfg, cfg.Config, "ruler-storage", cfgProvider, logger, reg)
 	if err != nil {
 		return nil, err
 	}
 
-	store := bucketclient.NewBucketRuleStore(bucketClient, cfgProvider, logger)
+	if cfg.Secondary.Enabled {
+		secondary, err := newBucketRuleStore(ctx, cfg, cfg.Secondary.Config, "ruler-storage-secondary", cfgProvider, logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		store = rulestore.NewMirroredRuleStore(store, secondary, logger, reg)
+	}
+	return rulestore.NewInstrumentedRuleStore(store, reg), nil
+}
+
+// newBucketRuleStore returns a rule store of the bucket configured by bucketCfg, with the encryption and the
+// layout of cfg.
+func newBucketRuleStore(ctx context.Context, cfg rulestore.Config, bucketCfg bucket.Config, name string, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) (rulestore.RuleStore, error) {
+	bucketClient, err := bucket.NewClient(ctx, bucketCfg, name, logger, reg)
 	if err != nil {
 		return nil, err
 	}
 
-	return store, nil
+	var bkt objstore.Bucket = bucketClient
+	if cfg.ClientSideEncryption.KeysFile != "" {
+		keys, err := bucketclient.LoadEncryptionKeys(cfg.ClientSideEncryption.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		bkt = bucketclient.NewEncryptedBucket(bkt, keys, cfg.ClientSideEncryption.AllowUnencrypted)
+	}
+
+	return bucketclient.NewBucketRuleStore(bkt, cfgProvider, logger).
+		WithLayout(bucketclient.NewLayout(cfg.Layout)).
+		WithReadAfterWriteConsistency(cfg.ReadAfterWriteWindow), nil
 }
@@ -5,29 +5,64 @@ package ruler
 import (
 	"context"
 	"flag"
+	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/dskit/tenant"
 
+	"github.com/grafana/mimir/pkg/querier/tenantfederation"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
 
+var (
+	errInvalidTenantFederationMaxConcurrent     = errors.New("invalid ruler tenant federation max concurrent, must be greater than zero")
+	errInvalidTenantFederationSourceTenantLabel = errors.New("invalid ruler tenant federation source tenant label, must be a valid label name other than the metric name")
+)
+
 type TenantFederationConfig struct {
-	Enabled bool `yaml:"enabled"`
+	Enabled           bool   `yaml:"enabled"`
+	MaxConcurrent     int    `yaml:"max_concurrent" category:"experimental"`
+	SourceTenantLabel string `yaml:"source_tenant_label" category:"experimental"`
+	ConsentsEnabled   bool   `yaml:"consents_enabled" category:"experimental"`
+
+	Audit FederationAuditConfig `yaml:"audit" category:"experimental"`
 }
 
 func (cfg *TenantFederationConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.Enabled, "ruler.tenant-federation.enabled", false, "Enable running rule groups against multiple tenants. The tenant IDs involved need to be in the rule group's 'source_tenants' field. If this flag is set to 'false' when there are already created federated rule groups, then these rules groups will be skipped during evaluations.")
+	f.IntVar(&cfg.MaxConcurrent, "ruler.tenant-federation.max-concurrent", 16, "Maximum number of source tenants of a federated rule group whose series are fetched concurrently by each query of the group.")
+	f.StringVar(&cfg.SourceTenantLabel, "ruler.tenant-federation.source-tenant-label", defaultSourceTenantLabel, "Label added to the series read from each source tenant of a federated rule group, whose value is the source tenant, so that the rules can aggregate by source tenant. The 'source_tenant_label' field of a rule group overrides it.")
+	f.BoolVar(&cfg.ConsentsEnabled, "ruler.tenant-federation.consents-enabled", false, "Require the source tenants of a federated rule group to grant their consent to the tenant owning the rule group through the federation consents API, both when the rule group is created and when it's evaluated. Requires an object storage backend for the ruler storage.")
+	cfg.Audit.RegisterFlags(f)
+}
+
+func (cfg *TenantFederationConfig) Validate() error {
+	if cfg.Enabled && cfg.MaxConcurrent <= 0 {
+		return errInvalidTenantFederationMaxConcurrent
+	}
+	if cfg.Enabled && !isValidSourceTenantLabel(cfg.SourceTenantLabel) {
+		return errInvalidTenantFederationSourceTenantLabel
+	}
+	return cfg.Audit.Validate()
 }
 
 type contextKey int
 
 const federatedGroupSourceTenants contextKey = 1
 
+// defaultSourceTenantLabel is the label added by the tenant federation of the queriers.
+const defaultSourceTenantLabel = "__tenant_id__"
+
 // FederatedGroupContextFunc prepares the context for federated rules.
 // It injects g.SourceTenants() in to the context to be used by mergeQuerier.
 func FederatedGroupContextFunc(ctx context.Context, g *rules.Group) context.Context {
@@ -45,21 +80,150 @@ func ExtractTenantIDs(ctx context.Context) (string, error) {
 	return tenant.TenantID(ctx)
 }
 
-func TenantFederationQueryFunc(regularQueryable, federatedQueryable rules.QueryFunc) rules.QueryFunc {
+// TenantFederationQueryFunc returns a QueryFunc running the queries of the federated rule groups with
+// federatedQueryable, the series of each source tenant having the label sourceTenantLabel or the label
+// configured in the rule group, and the queries of the other rule groups with regularQueryable.
+func TenantFederationQueryFunc(regularQueryable, federatedQueryable rules.QueryFunc, sourceTenantLabel string) rules.QueryFunc {
 	return func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
 		if sourceTenants, _ := ctx.Value(federatedGroupSourceTenants).([]string); len(sourceTenants) > 0 {
 			ctx = user.InjectOrgID(ctx, tenant.JoinTenantIDs(tenant.NormalizeTenantIDs(sourceTenants)))
+			label := sourceTenantLabel
+			if groupLabel := evaluatedRuleGroupDesc(ctx).GetSourceTenantLabel(); groupLabel != "" {
+				label = groupLabel
+			}
+			ctx = tenantfederation.InjectIDLabelName(ctx, label)
 			return federatedQueryable(ctx, q, t)
 		}
 		return regularQueryable(ctx, q, t)
 	}
 }
 
+// validateDestTenants validates the destination tenants of the rule group rg.
+func validateDestTenants(rg rulespb.RuleGroup) error {
+	seen := make(map[string]struct{}, len(rg.DestTenants))
+	for _, destTenant := range rg.DestTenants {
+		if destTenant == "" {
+			return errors.New("empty destination tenant")
+		}
+		if err := tenant.ValidTenantID(destTenant); err != nil {
+			return errors.Wrapf(err, "invalid destination tenant %q", destTenant)
+		}
+		if _, ok := seen[destTenant]; ok {
+			return errors.Errorf("duplicate destination tenant %q", destTenant)
+		}
+		seen[destTenant] = struct{}{}
+	}
+	return nil
+}
+
+// DestTenantNotAllowedError is returned when a destination tenant of a federated rule group isn't allowed by
+// the limits of the tenant owning the rule group.
+type DestTenantNotAllowedError struct {
+	DestTenant  string
+	OwnerTenant string
+}
+
+func (e *DestTenantNotAllowedError) Error() string {
+	return fmt.Sprintf("the tenant %s is not allowed to write to the destination tenant %s", e.OwnerTenant, e.DestTenant)
+}
+
+// authorizeDestTenants returns a DestTenantNotAllowedError if a destination tenant other than the tenant owning
+// the rule group isn't in the destination tenants allowed by its limits. No other tenant is allowed if limits is nil.
+func authorizeDestTenants(limits RulesLimits, userID string, destTenants []string) error {
+	var allowed []string
+	if limits != nil {
+		allowed = limits.RulerFederationDestTenants(userID)
+	}
+	for _, destTenant := range destTenants {
+		if destTenant != userID && !containsString(allowed, destTenant) {
+			return &DestTenantNotAllowedError{DestTenant: destTenant, OwnerTenant: userID}
+		}
+	}
+	return nil
+}
+
+// validateSourceTenantLabel validates the source tenant label of the rule group rg.
+func validateSourceTenantLabel(rg rulespb.RuleGroup) error {
+	if rg.SourceTenantLabel != "" && !isValidSourceTenantLabel(rg.SourceTenantLabel) {
+		return errors.Errorf("invalid source tenant label %q: must be a valid label name other than the metric name", rg.SourceTenantLabel)
+	}
+	return nil
+}
+
+func isValidSourceTenantLabel(label string) bool {
+	return model.LabelName(label).IsValid() && label != labels.MetricName
+}
+
+const (
+	federationConsentGranted     = "granted"
+	federationConsentMissing     = "missing"
+	federationConsentUnknown     = "unknown"
+	federationConsentNotRequired = "not_required"
+)
+
+// ruleGroupFederation returns the effective federation settings of the rule group g, or nil if g is not a
+// federated rule group.
+func (r *Ruler) ruleGroupFederation(ctx context.Context, g *rulespb.RuleGroupDesc) *RuleGroupFederation {
+	if len(g.GetSourceTenants()) == 0 && len(g.GetDestTenants()) == 0 {
+		return nil
+	}
+
+	f := &RuleGroupFederation{
+		Enabled:             r.cfg.TenantFederation.Enabled,
+		SourceTenants:       g.GetSourceTenants(),
+		DestTenants:         g.GetDestTenants(),
+		SourceTenantLabel:   r.cfg.TenantFederation.SourceTenantLabel,
+		SourceTenantsStatus: []FederationSourceTenantStatus{},
+	}
+	if len(f.SourceTenants) == 0 {
+		f.SourceTenants = []string{g.GetUser()}
+	}
+	if len(f.DestTenants) == 0 {
+		f.DestTenants = []string{g.GetUser()}
+	}
+	if label := g.GetSourceTenantLabel(); label != "" {
+		f.SourceTenantLabel = label
+	}
+
+	for _, sourceTenant := range g.GetSourceTenants() {
+		// The series of the tenant of the group are not restricted.
+		if sourceTenant == g.GetUser() {
+			continue
+		}
+
+		status := FederationSourceTenantStatus{
+			Tenant:         sourceTenant,
+			Consent:        federationConsentNotRequired,
+			AllowedMetrics: r.limits.RulerFederationAllowedMetrics(sourceTenant),
+			BlockedMetrics: r.limits.RulerFederationBlockedMetrics(sourceTenant),
+		}
+		if _, err := sourceTenantMetricsMatchers(status.AllowedMetrics, status.BlockedMetrics); err != nil {
+			status.Error = errors.Wrapf(err, "invalid federation metrics restrictions of the source tenant %s", sourceTenant).Error()
+		}
+		if r.federationConsents != nil {
+			err := r.federationConsents.Check(ctx, g.GetUser(), []string{sourceTenant})
+			var consentErr *FederationConsentError
+			switch {
+			case err == nil:
+				status.Consent = federationConsentGranted
+			case errors.As(err, &consentErr):
+				status.Consent = federationConsentMissing
+				status.Error = err.Error()
+			default:
+				status.Consent = federationConsentUnknown
+			}
+		}
+		f.SourceTenantsStatus = append(f.SourceTenantsStatus, status)
+	}
+	return f
+}
+
+// RemoveFederatedRuleGroups removes the rule groups reading or writing the series of other tenants.
 func RemoveFederatedRuleGroups(groups map[string]rulespb.RuleGroupList) {
 	for userID, groupList := range groups {
 		amended := make(rulespb.RuleGroupList, 0, len(groupList))
 		for _, group := range groupList {
-			if len(group.GetSourceTenants()) > 0 {
+			if len(group.GetSourceTenants()) > 0 || len(group.GetDestTenants()) > 0 {
 				continue
 			}
 			amended = append(amended, group)
@@ -67,3 +231,134 @@ func RemoveFederatedRuleGroups(groups map[string]rulespb.RuleGroupList) {
 		groups[userID] = amended
 	}
 }
+
+// NewSourceTenantsInstrumentedQueryable wraps the queryable used to fetch the series of each source tenant
+// of the federated rule groups, tracking the time spent fetching the series of each source tenant.
+func NewSourceTenantsInstrumentedQueryable(q storage.Queryable, reg prometheus.Registerer) storage.Queryable {
+	return &sourceTenantsInstrumentedQueryable{
+		Queryable: q,
+		duration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_federated_query_source_tenant_duration_seconds",
+			Help:    "Time spent fetching the series of a source tenant of a federated rule group.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"source_tenant"}),
+	}
+}
+
+type sourceTenantsInstrumentedQueryable struct {
+	storage.Queryable
+	duration *prometheus.HistogramVec
+}
+
+func (q *sourceTenantsInstrumentedQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	querier, err := q.Queryable.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	// The federated queryable queries each source tenant separately.
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return querier, nil
+	}
+	return &sourceTenantInstrumentedQuerier{Querier: querier, duration: q.duration.WithLabelValues(tenantID)}, nil
+}
+
+type sourceTenantInstrumentedQuerier struct {
+	storage.Querier
+	duration prometheus.Observer
+}
+
+func (q *sourceTenantInstrumentedQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	start := time.Now()
+	defer func() { q.duration.Observe(time.Since(start).Seconds()) }()
+	return q.Querier.Select(sortSeries, hints, matchers...)
+}
+
+// federatedSourceTenant returns the source tenant queried by the federated queryable for a federated rule
+// group, which queries each source tenant separately, unless it's the tenant owning the rule group.
+func federatedSourceTenant(ctx context.Context) (string, bool) {
+	if sourceTenants, _ := ctx.Value(federatedGroupSourceTenants).([]string); len(sourceTenants) == 0 {
+		return "", false
+	}
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil || tenantID == evaluatedRuleGroupDesc(ctx).GetUser() {
+		return "", false
+	}
+	return tenantID, true
+}
+
+// NewSourceTenantMetricsQueryable wraps the queryable used to fetch the series of each source tenant of the
+// federated rule groups, restricting the series of each source tenant to the metric names matching its
+// RulerFederationAllowedMetrics and not matching its RulerFederationBlockedMetrics. The series of the tenant
+// owning the rule group, and the queries of the non-federated rule groups, aren't restricted.
+func NewSourceTenantMetricsQueryable(q storage.Queryable, limits RulesLimits) storage.Queryable {
+	return &sourceTenantMetricsQueryable{Queryable: q, limits: limits}
+}
+
+type sourceTenantMetricsQueryable struct {
+	storage.Queryable
+	limits RulesLimits
+}
+
+func (q *sourceTenantMetricsQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	querier, err := q.Queryable.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, ok := federatedSourceTenant(ctx)
+	if !ok {
+		return querier, nil
+	}
+
+	matchers, err := sourceTenantMetricsMatchers(q.limits.RulerFederationAllowedMetrics(tenantID), q.limits.RulerFederationBlockedMetrics(tenantID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid federation metrics restrictions of the source tenant %s", tenantID)
+	}
+	if len(matchers) == 0 {
+		return querier, nil
+	}
+	return &sourceTenantMetricsQuerier{Querier: querier, matchers: matchers}, nil
+}
+
+// sourceTenantMetricsMatchers returns the matchers of the metric names matching allowed and not matching blocked.
+func sourceTenantMetricsMatchers(allowed, blocked string) ([]*labels.Matcher, error) {
+	var matchers []*labels.Matcher
+	if allowed != "" {
+		m, err := labels.NewMatcher(labels.MatchRegexp, labels.MetricName, allowed)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	if blocked != "" {
+		m, err := labels.NewMatcher(labels.MatchNotRegexp, labels.MetricName, blocked)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// sourceTenantMetricsQuerier is a storage.Querier adding the matchers of the metric names readable by the
+// federated rule groups to every request.
+type sourceTenantMetricsQuerier struct {
+	storage.Querier
+	matchers []*labels.Matcher
+}
+
+func (q *sourceTenantMetricsQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	return q.Querier.Select(sortSeries, hints, q.withMatchers(matchers)...)
+}
+
+func (q *sourceTenantMetricsQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return q.Querier.LabelValues(name, q.withMatchers(matchers)...)
+}
+
+func (q *sourceTenantMetricsQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return q.Querier.LabelNames(q.withMatchers(matchers)...)
+}
+
+func (q *sourceTenantMetricsQuerier) withMatchers(matchers []*labels.Matcher) []*labels.Matcher {
+	return append(append(make([]*labels.Matcher, 0, len(matchers)+len(q.matchers)), matchers...), q.matchers...)
+}
@@ -4,10 +4,31 @@ package ruler
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/mimir/pkg/querier/tenantfederation"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
 
@@ -37,6 +58,13 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 		SourceTenants: []string{"tenant-2", "tenant-3"},
 	}
 
+	federatedGroupWithDestTenants := &rulespb.RuleGroupDesc{
+		Namespace:   "ns",
+		Name:        "federated-dest",
+		User:        userID,
+		DestTenants: []string{"tenant-2", "tenant-3"},
+	}
+
 	testCases := map[string]struct {
 		tenantFederationEnabled bool
 		existingRules           rulespb.RuleGroupList
@@ -51,7 +79,7 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 		},
 		"tenant federation disabled with a federated rules and a regular rule": {
 			tenantFederationEnabled: false,
-			existingRules:           rulespb.RuleGroupList{federatedGroupWithOneTenant, regularGroup},
+			existingRules:           rulespb.RuleGroupList{federatedGroupWithOneTenant, federatedGroupWithDestTenants, regularGroup},
 
 			expectedRunningGroupsNames: []string{regularGroup.Name},
 		},
@@ -63,9 +91,9 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 		},
 		"tenant federation enabled with federated and regular groups": {
 			tenantFederationEnabled: true,
-			existingRules:           rulespb.RuleGroupList{regularGroup, federatedGroupWithOneTenant, federatedGroupWithMultipleTenants},
+			existingRules:           rulespb.RuleGroupList{regularGroup, federatedGroupWithOneTenant, federatedGroupWithMultipleTenants, federatedGroupWithDestTenants},
 
-			expectedRunningGroupsNames: []string{regularGroup.Name, federatedGroupWithOneTenant.Name, federatedGroupWithMultipleTenants.Name},
+			expectedRunningGroupsNames: []string{regularGroup.Name, federatedGroupWithOneTenant.Name, federatedGroupWithMultipleTenants.Name, federatedGroupWithDestTenants.Name},
 		},
 	}
 
@@ -90,3 +118,299 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateDestTenants(t *testing.T) {
+	for name, tc := range map[string]struct {
+		destTenants []string
+		expectErr   bool
+	}{
+		"no destination tenants":        {},
+		"valid destination tenants":     {destTenants: []string{"team-a", "global"}},
+		"invalid destination tenant":    {destTenants: []string{"team-a", "team/b"}, expectErr: true},
+		"empty destination tenant":      {destTenants: []string{""}, expectErr: true},
+		"duplicate destination tenants": {destTenants: []string{"team-a", "global", "team-a"}, expectErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := validateDestTenants(rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}, DestTenants: tc.destTenants})
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeDestTenants(t *testing.T) {
+	limits := ruleLimits{destTenants: map[string][]string{"tenant-1": {"team-a", "global"}}}
+
+	assert.NoError(t, authorizeDestTenants(limits, "tenant-1", nil))
+	assert.NoError(t, authorizeDestTenants(limits, "tenant-1", []string{"tenant-1", "team-a", "global"}))
+	assert.ErrorAs(t, authorizeDestTenants(limits, "tenant-1", []string{"team-a", "team-b"}), new(*DestTenantNotAllowedError))
+	assert.ErrorAs(t, authorizeDestTenants(limits, "tenant-2", []string{"team-a"}), new(*DestTenantNotAllowedError))
+	assert.ErrorAs(t, authorizeDestTenants(nil, "tenant-1", []string{"team-a"}), new(*DestTenantNotAllowedError))
+}
+
+func TestAPI_CreateRuleGroupWithDestTenants(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	r.limits = ruleLimits{destTenants: map[string][]string{"user1": {"team-a"}}}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/config/v1/rules/{namespace}").Methods(http.MethodPost).HandlerFunc(a.CreateRuleGroup)
+	create := func(destTenants string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := "name: group\ndest_tenants: " + destTenants + "\nrules:\n- record: up:sum\n  expr: sum(up)\n"
+		router.ServeHTTP(w, requestFor(t, http.MethodPost, "https://localhost:8080/config/v1/rules/namespace", strings.NewReader(body), "user1"))
+		return w
+	}
+
+	assert.Equal(t, http.StatusAccepted, create("[user1, team-a]").Code)
+
+	// The tenant can't write to a destination tenant which isn't allowed by its limits.
+	w := create("[team-a, team-b]")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "the tenant user1 is not allowed to write to the destination tenant team-b")
+}
+
+func TestSourceTenantsInstrumentedQueryable(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	var (
+		mtx        sync.Mutex
+		running    int
+		maxRunning int
+	)
+	upstream := storage.QueryableFunc(func(context.Context, int64, int64) (storage.Querier, error) {
+		return &slowQuerier{Querier: storage.NoopQuerier(), select_: func() {
+			mtx.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mtx.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mtx.Lock()
+			running--
+			mtx.Unlock()
+		}}, nil
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	queryable := tenantfederation.NewQueryableWithMaxConcurrency(NewSourceTenantsInstrumentedQueryable(upstream, reg), false, 2, log.NewNopLogger())
+
+	ctx := user.InjectOrgID(context.Background(), "tenant-1|tenant-2|tenant-3|tenant-4")
+	q, err := queryable.Querier(ctx, 0, 1000)
+	require.NoError(t, err)
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"))
+	for set.Next() {
+	}
+	require.NoError(t, set.Err())
+
+	assert.LessOrEqual(t, maxRunning, 2)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	sourceTenants := map[string]uint64{}
+	for _, m := range families[0].GetMetric() {
+		sourceTenants[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+	}
+	assert.Equal(t, map[string]uint64{"tenant-1": 1, "tenant-2": 1, "tenant-3": 1, "tenant-4": 1}, sourceTenants)
+}
+
+func TestTenantFederationQueryFunc_SourceTenantLabel(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	// The same series are returned for every tenant.
+	upstream := teststorage.New(t)
+	t.Cleanup(func() { _ = upstream.Close() })
+	app := upstream.Appender(context.Background())
+	_, err := app.Append(0, labels.FromStrings(labels.MetricName, "up"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	eng := promql.NewEngine(promql.EngineOpts{Logger: log.NewNopLogger(), MaxSamples: 1e6, Timeout: time.Minute})
+	regularQueryFunc := rules.EngineQueryFunc(eng, upstream)
+	federatedQueryFunc := rules.EngineQueryFunc(eng, tenantfederation.NewQueryable(upstream, false, log.NewNopLogger()))
+	queryFunc := TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc, "source_tenant")
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{
+		{User: "tenant-1", Namespace: "namespace", Name: "default-label", SourceTenants: []string{"tenant-2", "tenant-3"}},
+		{User: "tenant-1", Namespace: "namespace", Name: "group-label", SourceTenants: []string{"tenant-2", "tenant-3"}, SourceTenantLabel: "team"},
+	})
+
+	for group, expectedLabel := range map[string]string{"default-label": "source_tenant", "group-label": "team"} {
+		t.Run(group, func(t *testing.T) {
+			ctx := user.InjectOrgID(context.Background(), "tenant-1")
+			ctx = context.WithValue(ctx, tenantRuleGroups, registry)
+			ctx = context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace", name: group})
+			ctx = context.WithValue(ctx, federatedGroupSourceTenants, []string{"tenant-2", "tenant-3"})
+
+			vector, err := queryFunc(ctx, "count by ("+expectedLabel+") (up)", time.Unix(1, 0))
+			require.NoError(t, err)
+
+			var sourceTenants []string
+			for _, sample := range vector {
+				sourceTenants = append(sourceTenants, sample.Metric.Get(expectedLabel))
+			}
+			assert.ElementsMatch(t, []string{"tenant-2", "tenant-3"}, sourceTenants)
+		})
+	}
+}
+
+func TestValidateSourceTenantLabel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		label     string
+		expectErr bool
+	}{
+		"no source tenant label":      {},
+		"valid source tenant label":   {label: "source_tenant"},
+		"invalid source tenant label": {label: "source-tenant", expectErr: true},
+		"metric name":                 {label: labels.MetricName, expectErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := validateSourceTenantLabel(rulespb.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}, SourceTenantLabel: tc.label})
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSourceTenantMetricsQueryable(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	// The same series are returned for every tenant.
+	upstream := teststorage.New(t)
+	t.Cleanup(func() { _ = upstream.Close() })
+	app := upstream.Appender(context.Background())
+	for _, name := range []string{"up", "team_requests", "secret_requests"} {
+		_, err := app.Append(0, labels.FromStrings(labels.MetricName, name), 1000, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	limits := ruleLimits{
+		federationAllowed: map[string]string{"tenant-1": "up", "tenant-2": "up|team_.*", "tenant-4": "("},
+		federationBlocked: map[string]string{"tenant-1": ".*", "tenant-3": "secret_.*"},
+	}
+	eng := promql.NewEngine(promql.EngineOpts{Logger: log.NewNopLogger(), MaxSamples: 1e6, Timeout: time.Minute})
+	queryFunc := rules.EngineQueryFunc(eng, tenantfederation.NewQueryable(NewSourceTenantMetricsQueryable(upstream, limits), false, log.NewNopLogger()))
+
+	registry := newRuleGroupsRegistry()
+	registry.set(rulespb.RuleGroupList{{User: "tenant-1", Namespace: "namespace", Name: "group", SourceTenants: []string{"tenant-1", "tenant-2", "tenant-3"}}})
+	groupContext := func(sourceTenants ...string) context.Context {
+		ctx := user.InjectOrgID(context.Background(), tenant.JoinTenantIDs(sourceTenants))
+		ctx = context.WithValue(ctx, tenantRuleGroups, registry)
+		ctx = context.WithValue(ctx, evaluatedRuleGroup, ruleGroupInfo{namespace: "namespace", name: "group"})
+		return context.WithValue(ctx, federatedGroupSourceTenants, sourceTenants)
+	}
+	query := func(ctx context.Context) ([]string, error) {
+		vector, err := queryFunc(ctx, `count by (__tenant_id__, __name__) ({__name__=~".+"})`, time.Unix(1, 0))
+		var series []string
+		for _, sample := range vector {
+			series = append(series, sample.Metric.Get("__tenant_id__")+"/"+sample.Metric.Get(labels.MetricName))
+		}
+		return series, err
+	}
+
+	// The series of the tenant owning the rule group aren't restricted.
+	series, err := query(groupContext("tenant-1", "tenant-2", "tenant-3"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"tenant-1/up", "tenant-1/team_requests", "tenant-1/secret_requests",
+		"tenant-2/up", "tenant-2/team_requests",
+		"tenant-3/up", "tenant-3/team_requests",
+	}, series)
+
+	// The queries of the non-federated rule groups aren't restricted.
+	series, err = query(user.InjectOrgID(context.Background(), "tenant-2"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tenant-2/up", "tenant-2/team_requests", "tenant-2/secret_requests"}, series)
+
+	// The queries fail with invalid restrictions.
+	_, err = query(groupContext("tenant-2", "tenant-4"))
+	require.Error(t, err)
+}
+
+func TestRuler_RuleGroupFederation(t *testing.T) {
+	r := &Ruler{
+		cfg: Config{TenantFederation: TenantFederationConfig{Enabled: true, SourceTenantLabel: defaultSourceTenantLabel}},
+		limits: ruleLimits{
+			federationAllowed: map[string]string{"tenant-1": "up", "tenant-2": "up|team_.*", "tenant-4": "("},
+			federationBlocked: map[string]string{"tenant-3": "secret_.*"},
+		},
+	}
+	group := &rulespb.RuleGroupDesc{User: "tenant-1", Namespace: "namespace", Name: "group", SourceTenants: []string{"tenant-1", "tenant-2", "tenant-3", "tenant-4"}}
+
+	t.Run("non-federated rule group", func(t *testing.T) {
+		assert.Nil(t, r.ruleGroupFederation(context.Background(), &rulespb.RuleGroupDesc{User: "tenant-1", Namespace: "namespace", Name: "group"}))
+	})
+
+	t.Run("federation consents disabled", func(t *testing.T) {
+		assert.Equal(t, &RuleGroupFederation{
+			Enabled:           true,
+			SourceTenants:     []string{"tenant-1", "tenant-2", "tenant-3", "tenant-4"},
+			DestTenants:       []string{"tenant-1"},
+			SourceTenantLabel: "__tenant_id__",
+			SourceTenantsStatus: []FederationSourceTenantStatus{
+				{Tenant: "tenant-2", Consent: "not_required", AllowedMetrics: "up|team_.*"},
+				{Tenant: "tenant-3", Consent: "not_required", BlockedMetrics: "secret_.*"},
+				{Tenant: "tenant-4", Consent: "not_required", AllowedMetrics: "(", Error: "invalid federation metrics restrictions of the source tenant tenant-4: error parsing regexp: missing closing ): `(`"},
+			},
+		}, r.ruleGroupFederation(context.Background(), group))
+	})
+
+	t.Run("federation consents enabled", func(t *testing.T) {
+		r.federationConsents = NewFederationConsents(objstore.NewInMemBucket())
+		t.Cleanup(func() { r.federationConsents = nil })
+		_, err := r.federationConsents.Grant(context.Background(), "tenant-2", []string{"tenant-1"})
+		require.NoError(t, err)
+
+		f := r.ruleGroupFederation(context.Background(), group)
+		require.Len(t, f.SourceTenantsStatus, 3)
+		assert.Equal(t, FederationSourceTenantStatus{Tenant: "tenant-2", Consent: "granted", AllowedMetrics: "up|team_.*"}, f.SourceTenantsStatus[0])
+		assert.Equal(t, FederationSourceTenantStatus{Tenant: "tenant-3", Consent: "missing", BlockedMetrics: "secret_.*", Error: "the source tenant tenant-3 has not granted its consent to federate its series to the tenant tenant-1"}, f.SourceTenantsStatus[1])
+	})
+
+	t.Run("destination tenants and source tenant label of the rule group", func(t *testing.T) {
+		f := r.ruleGroupFederation(context.Background(), &rulespb.RuleGroupDesc{User: "tenant-1", Namespace: "namespace", Name: "group", DestTenants: []string{"tenant-5", "tenant-6"}, SourceTenantLabel: "team"})
+		assert.Equal(t, []string{"tenant-1"}, f.SourceTenants)
+		assert.Equal(t, []string{"tenant-5", "tenant-6"}, f.DestTenants)
+		assert.Equal(t, "team", f.SourceTenantLabel)
+		assert.Empty(t, f.SourceTenantsStatus)
+	})
+
+	t.Run("rules API output", func(t *testing.T) {
+		grp := newRuleGroup(&GroupStateDesc{Group: group})
+		grp.Federation = r.ruleGroupFederation(context.Background(), group)
+
+		b, err := json.Marshal(grp)
+		require.NoError(t, err)
+		assert.Contains(t, string(b), `"federation":{"enabled":true,"sourceTenants":["tenant-1","tenant-2","tenant-3","tenant-4"],"destTenants":["tenant-1"],"sourceTenantLabel":"__tenant_id__","sourceTenantsStatus":[{"tenant":"tenant-2","consent":"not_required","allowedMetrics":"up|team_.*"}`)
+		b, err = json.Marshal(newRuleGroupV2(grp))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), `"federation":{"enabled":true,"source_tenants":["tenant-1","tenant-2","tenant-3","tenant-4"],"dest_tenants":["tenant-1"],"source_tenant_label":"__tenant_id__","source_tenants_status":[{"tenant":"tenant-2","consent":"not_required","allowed_metrics":"up|team_.*"}`)
+	})
+}
+
+type slowQuerier struct {
+	storage.Querier
+	select_ func()
+}
+
+func (q *slowQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	q.select_()
+	return q.Querier.Select(sortSeries, hints, matchers...)
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"time"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// minPollCheckInterval is the minimum interval between the periodic syncs of the rule groups, whatever the
+// poll intervals of the users.
+const minPollCheckInterval = time.Second
+
+// polledUserRules are the rule groups of a user loaded at the latest poll of the rule storage for the user.
+type polledUserRules struct {
+	groups rulespb.RuleGroupList
+	// The rule groups which can't be loaded.
+	broken   []rulestore.BrokenRuleGroup
+	polledAt time.Time
+}
+
+// pollInterval returns how frequently the rule storage is polled for the changes of the rule groups of the user.
+func (r *Ruler) pollInterval(userID string) time.Duration {
+	if interval := r.limits.RulerPollInterval(userID); interval > 0 {
+		return interval
+	}
+	return r.cfg.PollInterval
+}
+
+// pollDue returns whether the poll interval of the user elapsed at now since the latest poll of its rule groups.
+// A tenth of the interval is tolerated, so that the user is polled by the periodic sync at the end of its interval
+// even if the sync starts slightly earlier.
+func (r *Ruler) pollDue(userID string, now time.Time) bool {
+	p, ok := r.polledRules[userID]
+	if !ok {
+		return true
+	}
+	interval := r.pollInterval(userID)
+	return now.Sub(p.polledAt) >= interval-interval/10
+}
+
+// pollCheckInterval returns the interval until the next periodic sync of the rule groups, which is the shortest
+// poll interval of the users.
+func (r *Ruler) pollCheckInterval() time.Duration {
+	r.polledRulesMtx.Lock()
+	defer r.polledRulesMtx.Unlock()
+
+	interval := r.cfg.PollInterval
+	for userID := range r.polledRules {
+		if i := r.pollInterval(userID); i < interval {
+			interval = i
+		}
+	}
+	if interval < minPollCheckInterval {
+		return minPollCheckInterval
+	}
+	return interval
+}
+
+// updatePolledRules records the rule groups in configs of the users polled at now, and adds to configs the
+// rule groups of the users which weren't polled, loaded at their latest poll. The users are those returned by
+// listRulesPolled, and broken are the rule groups of the polled users which can't be loaded. It returns the rule
+// groups which can't be loaded of all the users.
+func (r *Ruler) updatePolledRules(configs map[string]rulespb.RuleGroupList, broken []rulestore.BrokenRuleGroup, users map[string]bool, now time.Time) []rulestore.BrokenRuleGroup {
+	brokenByUser := map[string][]rulestore.BrokenRuleGroup{}
+	for _, g := range broken {
+		brokenByUser[g.User] = append(brokenByUser[g.User], g)
+	}
+
+	var allBroken []rulestore.BrokenRuleGroup
+	for userID, polled := range users {
+		if polled {
+			r.polledRules[userID] = polledUserRules{groups: configs[userID], broken: brokenByUser[userID], polledAt: now}
+		}
+		p := r.polledRules[userID]
+		if len(p.groups) > 0 {
+			configs[userID] = p.groups
+		}
+		allBroken = append(allBroken, p.broken...)
+	}
+
+	// The users not owned by this ruler anymore are polled again if they get back to it.
+	for userID := range r.polledRules {
+		if _, ok := users[userID]; !ok {
+			delete(r.polledRules, userID)
+		}
+	}
+	return allBroken
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestRuler_PollInterval(t *testing.T) {
+	ctx := context.Background()
+	storage := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		require.NoError(t, storage.SetRuleGroup(ctx, userID, "namespace", &rulespb.RuleGroupDesc{User: userID, Namespace: "namespace", Name: "first", Interval: time.Minute}))
+	}
+
+	cfg := defaultRulerConfig(t)
+	cfg.PollInterval = time.Minute
+	r := buildRuler(t, cfg, storage, nil)
+	r.limits = ruleLimits{pollIntervals: map[string]time.Duration{"user1": 10 * time.Second, "user3": time.Hour}}
+	require.NoError(t, services.StartAndAwaitRunning(ctx, r))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, r)) })
+	r.syncRules(ctx, rulerSyncReasonInitial)
+
+	// The periodic syncs happen at the shortest poll interval of the users.
+	assert.Equal(t, 10*time.Second, r.pollCheckInterval())
+	assert.Equal(t, 3.0, testutil.ToFloat64(r.metrics.syncPolledTenants))
+
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		require.NoError(t, storage.SetRuleGroup(ctx, userID, "namespace", &rulespb.RuleGroupDesc{User: userID, Namespace: "namespace", Name: "second", Interval: time.Minute}))
+	}
+	numGroups := func() map[string]int {
+		return map[string]int{
+			"user1": len(r.manager.GetRules("user1")),
+			"user2": len(r.manager.GetRules("user2")),
+			"user3": len(r.manager.GetRules("user3")),
+		}
+	}
+	// elapse moves the latest poll of the rule groups of the users back in time by d.
+	elapse := func(d time.Duration) {
+		r.polledRulesMtx.Lock()
+		defer r.polledRulesMtx.Unlock()
+		for userID, p := range r.polledRules {
+			p.polledAt = p.polledAt.Add(-d)
+			r.polledRules[userID] = p
+		}
+	}
+
+	// The users aren't polled before the end of their poll interval.
+	r.syncRules(ctx, rulerSyncReasonPeriodic)
+	assert.Equal(t, map[string]int{"user1": 1, "user2": 1, "user3": 1}, numGroups())
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.metrics.syncPolledTenants))
+
+	elapse(10 * time.Second)
+	r.syncRules(ctx, rulerSyncReasonPeriodic)
+	assert.Equal(t, map[string]int{"user1": 2, "user2": 1, "user3": 1}, numGroups())
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.metrics.syncPolledTenants))
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.metrics.syncFailures))
+
+	elapse(time.Minute)
+	r.syncRules(ctx, rulerSyncReasonPeriodic)
+	assert.Equal(t, map[string]int{"user1": 2, "user2": 2, "user3": 1}, numGroups())
+
+	// The syncs on ring changes poll all the users.
+	r.syncRules(ctx, rulerSyncReasonRingChange)
+	assert.Equal(t, map[string]int{"user1": 2, "user2": 2, "user3": 2}, numGroups())
+
+	// The users without rule groups anymore are removed at their next poll.
+	require.NoError(t, storage.DeleteNamespace(ctx, "user1", "namespace"))
+	elapse(10 * time.Second)
+	r.syncRules(ctx, rulerSyncReasonPeriodic)
+	assert.Equal(t, map[string]int{"user1": 0, "user2": 2, "user3": 2}, numGroups())
+	assert.Equal(t, time.Minute, r.pollCheckInterval())
+}
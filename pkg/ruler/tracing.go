@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/dskit/tenant"
+)
+
+// TracingQueryFunc runs each query executed by a rule evaluation in its own span. The span of the
+// rule evaluation, created by the Prometheus rules manager and only tagged with the rule name, is
+// tagged with the tenant and the rule group too, so that slow rules can be found by those.
+func TracingQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		tags := opentracing.Tags{}
+		if userID, err := tenant.TenantID(ctx); err == nil {
+			tags["user"] = userID
+		}
+		if sourceTenants, _ := ctx.Value(federatedGroupSourceTenants).([]string); len(sourceTenants) > 0 {
+			tags["source_tenants"] = tenant.JoinTenantIDs(tenant.NormalizeTenantIDs(sourceTenants))
+		}
+		if g, ok := ctx.Value(evaluatedRuleGroup).(ruleGroupInfo); ok {
+			tags["rule_group_namespace"] = g.namespace
+			tags["rule_group"] = g.name
+		}
+
+		if parent := opentracing.SpanFromContext(ctx); parent != nil {
+			for k, v := range tags {
+				parent.SetTag(k, v)
+			}
+		}
+
+		sp, ctx := opentracing.StartSpanFromContext(ctx, "ruler.query", tags)
+		defer sp.Finish()
+		sp.SetTag("query", qs)
+		sp.SetTag("time", t)
+
+		res, err := qf(ctx, qs, t)
+		if err != nil {
+			ext.Error.Set(sp, true)
+			sp.LogFields(otlog.Error(err))
+		}
+		return res, err
+	}
+}
+
+// WithTracingMiddleware injects the span found in the passed context in to the headers of the outgoing
+// request, so that the queries run by the remote querier are traced as part of the rule evaluation.
+func WithTracingMiddleware(ctx context.Context, req *httpgrpc.HTTPRequest) error {
+	sp := opentracing.SpanFromContext(ctx)
+	if sp == nil {
+		return nil
+	}
+
+	carrier := opentracing.HTTPHeadersCarrier(http.Header{})
+	if err := sp.Tracer().Inject(sp.Context(), opentracing.HTTPHeaders, carrier); err != nil {
+		return err
+	}
+	for k, v := range carrier {
+		req.Headers = append(req.Headers, &httpgrpc.Header{
+			Key:    textproto.CanonicalMIMEHeaderKey(k),
+			Values: v,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+)
+
+func TestTracingQueryFunc(t *testing.T) {
+	for name, tc := range map[string]struct {
+		queryErr error
+	}{
+		"successful query": {},
+		"failed query":     {queryErr: errors.New("query failed")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tracer := mocktracer.New()
+			previous := opentracing.GlobalTracer()
+			opentracing.SetGlobalTracer(tracer)
+			t.Cleanup(func() { opentracing.SetGlobalTracer(previous) })
+
+			g := rules.NewGroup(rules.GroupOptions{
+				Name:  "group-1",
+				File:  "/rules/user-1/namespace%2F1",
+				Opts:  &rules.ManagerOptions{},
+				Rules: []rules.Rule{},
+			})
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			ctx = ChainGroupEvaluationContextFuncs(FederatedGroupContextFunc, RuleGroupContextFunc)(ctx, g)
+			ruleSpan := tracer.StartSpan("rule")
+			ctx = opentracing.ContextWithSpan(ctx, ruleSpan)
+
+			var querySpan opentracing.Span
+			qf := TracingQueryFunc(func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+				querySpan = opentracing.SpanFromContext(ctx)
+				return nil, tc.queryErr
+			})
+
+			_, err := qf(ctx, "up", time.Now())
+			assert.Equal(t, tc.queryErr, err)
+			ruleSpan.Finish()
+
+			expectedTags := map[string]interface{}{
+				"user":                 "user-1",
+				"rule_group_namespace": "namespace/1",
+				"rule_group":           "group-1",
+			}
+			for k, v := range expectedTags {
+				assert.Equal(t, v, ruleSpan.(*mocktracer.MockSpan).Tag(k), k)
+			}
+
+			spans := tracer.FinishedSpans()
+			require.Len(t, spans, 2)
+			require.Equal(t, "ruler.query", spans[0].OperationName)
+			require.Equal(t, querySpan, spans[0])
+			assert.Equal(t, ruleSpan.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+			for k, v := range expectedTags {
+				assert.Equal(t, v, spans[0].Tag(k), k)
+			}
+			assert.Equal(t, "up", spans[0].Tag("query"))
+			if tc.queryErr != nil {
+				assert.Equal(t, true, spans[0].Tag("error"))
+			} else {
+				assert.Nil(t, spans[0].Tag("error"))
+			}
+		})
+	}
+}
+
+func TestWithTracingMiddleware(t *testing.T) {
+	tracer := mocktracer.New()
+	sp := tracer.StartSpan("ruler.query")
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+
+	req := httpgrpc.HTTPRequest{}
+	require.NoError(t, WithTracingMiddleware(ctx, &req))
+
+	headers := map[string][]string{}
+	for _, h := range req.Headers {
+		headers[h.Key] = h.Values
+	}
+	spanCtx := sp.Context().(mocktracer.MockSpanContext)
+	assert.Equal(t, []string{strconv.Itoa(spanCtx.TraceID)}, headers["Mockpfx-Ids-Traceid"])
+	assert.Equal(t, []string{strconv.Itoa(spanCtx.SpanID)}, headers["Mockpfx-Ids-Spanid"])
+
+	// No span in the context means no header is added.
+	req = httpgrpc.HTTPRequest{}
+	require.NoError(t, WithTracingMiddleware(context.Background(), &req))
+	assert.Empty(t, req.Headers)
+}
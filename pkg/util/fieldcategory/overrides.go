@@ -2,7 +2,10 @@
 
 package fieldcategory
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 type Category int
 
@@ -30,48 +33,68 @@ func (c Category) String() string {
 
 // Fields are primarily categorized via struct tags, but this can be impossible when third party libraries are involved
 // Only categorize fields here when you can't otherwise, since struct tags are less likely to become stale
-var overrides = map[string]Category{
-	// weaveworks/common/server in server.Config
-	"server.graceful-shutdown-timeout":                  Advanced,
-	"server.grpc-conn-limit":                            Advanced,
-	"server.grpc-listen-network":                        Advanced,
-	"server.grpc-max-concurrent-streams":                Advanced,
-	"server.grpc-max-recv-msg-size-bytes":               Advanced,
-	"server.grpc-max-send-msg-size-bytes":               Advanced,
-	"server.grpc-tls-ca-path":                           Advanced,
-	"server.grpc-tls-cert-path":                         Advanced,
-	"server.grpc-tls-client-auth":                       Advanced,
-	"server.grpc-tls-key-path":                          Advanced,
-	"server.grpc.keepalive.max-connection-age":          Advanced,
-	"server.grpc.keepalive.max-connection-age-grace":    Advanced,
-	"server.grpc.keepalive.max-connection-idle":         Advanced,
-	"server.grpc.keepalive.min-time-between-pings":      Advanced,
-	"server.grpc.keepalive.ping-without-stream-allowed": Advanced,
-	"server.grpc.keepalive.time":                        Advanced,
-	"server.grpc.keepalive.timeout":                     Advanced,
-	"server.http-conn-limit":                            Advanced,
-	"server.http-idle-timeout":                          Advanced,
-	"server.http-listen-network":                        Advanced,
-	"server.http-read-timeout":                          Advanced,
-	"server.http-tls-ca-path":                           Advanced,
-	"server.http-tls-cert-path":                         Advanced,
-	"server.http-tls-client-auth":                       Advanced,
-	"server.http-tls-key-path":                          Advanced,
-	"server.http-write-timeout":                         Advanced,
-	"server.log-source-ips-enabled":                     Advanced,
-	"server.log-source-ips-header":                      Advanced,
-	"server.log-source-ips-regex":                       Advanced,
-	"server.path-prefix":                                Advanced,
-	"server.register-instrumentation":                   Advanced,
+var (
+	overridesMtx sync.RWMutex
+	overrides    = map[string]Category{
+		// weaveworks/common/server in server.Config
+		"server.graceful-shutdown-timeout":                  Advanced,
+		"server.grpc-conn-limit":                            Advanced,
+		"server.grpc-listen-network":                        Advanced,
+		"server.grpc-max-concurrent-streams":                Advanced,
+		"server.grpc-max-recv-msg-size-bytes":               Advanced,
+		"server.grpc-max-send-msg-size-bytes":               Advanced,
+		"server.grpc-tls-ca-path":                           Advanced,
+		"server.grpc-tls-cert-path":                         Advanced,
+		"server.grpc-tls-client-auth":                       Advanced,
+		"server.grpc-tls-key-path":                          Advanced,
+		"server.grpc.keepalive.max-connection-age":          Advanced,
+		"server.grpc.keepalive.max-connection-age-grace":    Advanced,
+		"server.grpc.keepalive.max-connection-idle":         Advanced,
+		"server.grpc.keepalive.min-time-between-pings":      Advanced,
+		"server.grpc.keepalive.ping-without-stream-allowed": Advanced,
+		"server.grpc.keepalive.time":                        Advanced,
+		"server.grpc.keepalive.timeout":                     Advanced,
+		"server.http-conn-limit":                            Advanced,
+		"server.http-idle-timeout":                          Advanced,
+		"server.http-listen-network":                        Advanced,
+		"server.http-read-timeout":                          Advanced,
+		"server.http-tls-ca-path":                           Advanced,
+		"server.http-tls-cert-path":                         Advanced,
+		"server.http-tls-client-auth":                       Advanced,
+		"server.http-tls-key-path":                          Advanced,
+		"server.http-write-timeout":                         Advanced,
+		"server.log-source-ips-enabled":                     Advanced,
+		"server.log-source-ips-header":                      Advanced,
+		"server.log-source-ips-regex":                       Advanced,
+		"server.path-prefix":                                Advanced,
+		"server.register-instrumentation":                   Advanced,
+	}
+)
+
+// AddOverride categorizes the field, for the fields registered by configs shared with other components, which
+// can't be categorized via struct tags for a single use.
+func AddOverride(fieldName string, category Category) {
+	overridesMtx.Lock()
+	defer overridesMtx.Unlock()
+	overrides[fieldName] = category
 }
 
 func GetOverride(fieldName string) (category Category, ok bool) {
+	overridesMtx.RLock()
+	defer overridesMtx.RUnlock()
 	category, ok = overrides[fieldName]
 	return
 }
 
 func VisitOverrides(f func(name string)) {
+	overridesMtx.RLock()
+	names := make([]string, 0, len(overrides))
 	for override := range overrides {
-		f(override)
+		names = append(names, override)
+	}
+	overridesMtx.RUnlock()
+
+	for _, name := range names {
+		f(name)
 	}
 }
@@ -94,6 +94,56 @@ type Limits struct {
 	RulerMaxRulesPerRuleGroup   int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
 	RulerMaxRuleGroupsPerTenant int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
 
+	RulerOnDemandEvaluationsPerMinute int  `yaml:"ruler_on_demand_evaluations_per_minute" json:"ruler_on_demand_evaluations_per_minute" category:"experimental"`
+	RulerMaxConcurrentQueries         int  `yaml:"ruler_max_concurrent_queries" json:"ruler_max_concurrent_queries" category:"experimental"`
+	RulerMaxRecordingRuleLabels       int  `yaml:"ruler_max_recording_rule_labels" json:"ruler_max_recording_rule_labels" category:"experimental"`
+	RulerMaxAlertsPerRule             int  `yaml:"ruler_max_alerts_per_rule" json:"ruler_max_alerts_per_rule" category:"experimental"`
+	RulerAlertsSeriesEnabled          bool `yaml:"ruler_alerts_series_enabled" json:"ruler_alerts_series_enabled" category:"advanced"`
+	RulerEvaluationMetricsEnabled     bool `yaml:"ruler_evaluation_metrics_enabled" json:"ruler_evaluation_metrics_enabled" category:"experimental"`
+
+	RulerWriteBatchSize         int            `yaml:"ruler_write_batch_size" json:"ruler_write_batch_size" category:"experimental"`
+	RulerWriteBatchFlushTimeout model.Duration `yaml:"ruler_write_batch_flush_timeout" json:"ruler_write_batch_flush_timeout" category:"experimental"`
+
+	RulerConfigAPIWriteRateLimit      float64 `yaml:"ruler_config_api_write_rate_limit" json:"ruler_config_api_write_rate_limit" category:"experimental"`
+	RulerConfigAPIWriteRateLimitBurst int     `yaml:"ruler_config_api_write_rate_limit_burst" json:"ruler_config_api_write_rate_limit_burst" category:"experimental"`
+
+	RulerEvaluationPool string         `yaml:"ruler_evaluation_pool" json:"ruler_evaluation_pool" category:"experimental"`
+	RulerPollInterval   model.Duration `yaml:"ruler_poll_interval" json:"ruler_poll_interval" category:"experimental"`
+
+	RulerQueryBackendURL               string `yaml:"ruler_query_backend_url" json:"ruler_query_backend_url" category:"experimental"`
+	RulerQueryBackendBasicAuthUsername string `yaml:"ruler_query_backend_basic_auth_username" json:"ruler_query_backend_basic_auth_username" category:"experimental"`
+	RulerQueryBackendBasicAuthPassword Secret `yaml:"ruler_query_backend_basic_auth_password" json:"ruler_query_backend_basic_auth_password" category:"experimental"`
+	RulerQueryBackendBearerToken       Secret `yaml:"ruler_query_backend_bearer_token" json:"ruler_query_backend_bearer_token" category:"experimental"`
+
+	RulerFederationAllowedMetrics string                 `yaml:"ruler_federation_allowed_metrics" json:"ruler_federation_allowed_metrics" category:"experimental"`
+	RulerFederationBlockedMetrics string                 `yaml:"ruler_federation_blocked_metrics" json:"ruler_federation_blocked_metrics" category:"experimental"`
+	RulerFederationDestTenants    flagext.StringSliceCSV `yaml:"ruler_federation_dest_tenants" json:"ruler_federation_dest_tenants" category:"experimental"`
+
+	RulerAlertGeneratorURL string `yaml:"ruler_alert_generator_url" json:"ruler_alert_generator_url" category:"experimental"`
+
+	RulerNotificationQueueCapacity       int            `yaml:"ruler_notification_queue_capacity" json:"ruler_notification_queue_capacity" category:"advanced"`
+	RulerNotificationTimeout             model.Duration `yaml:"ruler_notification_timeout" json:"ruler_notification_timeout" category:"advanced"`
+	RulerNotificationMaxRetries          int            `yaml:"ruler_notification_max_retries" json:"ruler_notification_max_retries" category:"experimental"`
+	RulerNotificationRateLimit           float64        `yaml:"ruler_notification_rate_limit" json:"ruler_notification_rate_limit" category:"experimental"`
+	RulerNotificationRateLimitBurst      int            `yaml:"ruler_notification_rate_limit_burst" json:"ruler_notification_rate_limit_burst" category:"experimental"`
+	RulerNotificationDeduplicationWindow model.Duration `yaml:"ruler_notification_deduplication_window" json:"ruler_notification_deduplication_window" category:"experimental"`
+
+	// These configs don't have a CLI flag registered here, because the default values are the
+	// Alertmanager client settings of the ruler.
+	RulerAlertmanagerClientTLSCertPath        string `yaml:"ruler_alertmanager_client_tls_cert_path" json:"ruler_alertmanager_client_tls_cert_path" category:"experimental" doc:"nocli|description=Path to the client certificate file used to authenticate with the Alertmanager when sending the notifications of the tenant. Also requires the key path to be configured. If not set, the default ruler Alertmanager client settings are used."`
+	RulerAlertmanagerClientTLSKeyPath         string `yaml:"ruler_alertmanager_client_tls_key_path" json:"ruler_alertmanager_client_tls_key_path" category:"experimental" doc:"nocli|description=Path to the key file for the client certificate used when sending the notifications of the tenant. If not set, the default ruler Alertmanager client settings are used."`
+	RulerAlertmanagerClientTLSCAPath          string `yaml:"ruler_alertmanager_client_tls_ca_path" json:"ruler_alertmanager_client_tls_ca_path" category:"experimental" doc:"nocli|description=Path to the CA certificates file to validate the Alertmanager server certificate against when sending the notifications of the tenant. If not set, the default ruler Alertmanager client settings are used."`
+	RulerAlertmanagerClientTLSServerName      string `yaml:"ruler_alertmanager_client_tls_server_name" json:"ruler_alertmanager_client_tls_server_name" category:"experimental" doc:"nocli|description=Override the expected name on the Alertmanager server certificate when sending the notifications of the tenant. If not set, the default ruler Alertmanager client settings are used."`
+	RulerAlertmanagerClientSigV4Region        string `yaml:"ruler_alertmanager_client_sigv4_region" json:"ruler_alertmanager_client_sigv4_region" category:"experimental" doc:"nocli|description=AWS region used to sign the notifications of the tenant sent to the Alertmanager with AWS Signature Version 4. Signing is enabled when the region is set."`
+	RulerAlertmanagerClientSigV4AccessKey     string `yaml:"ruler_alertmanager_client_sigv4_access_key" json:"ruler_alertmanager_client_sigv4_access_key" category:"experimental" doc:"nocli|description=AWS access key ID used to sign the notifications of the tenant. If not set, the credentials are retrieved from the default AWS credential chain."`
+	RulerAlertmanagerClientSigV4SecretKey     Secret `yaml:"ruler_alertmanager_client_sigv4_secret_key" json:"ruler_alertmanager_client_sigv4_secret_key" category:"experimental" doc:"nocli|description=AWS secret access key used to sign the notifications of the tenant."`
+	RulerAlertmanagerClientSigV4Profile       string `yaml:"ruler_alertmanager_client_sigv4_profile" json:"ruler_alertmanager_client_sigv4_profile" category:"experimental" doc:"nocli|description=Named AWS profile used to sign the notifications of the tenant."`
+	RulerAlertmanagerClientSigV4RoleARN       string `yaml:"ruler_alertmanager_client_sigv4_role_arn" json:"ruler_alertmanager_client_sigv4_role_arn" category:"experimental" doc:"nocli|description=AWS role ARN assumed to sign the notifications of the tenant."`
+	RulerAlertmanagerClientOAuth2ClientID     string `yaml:"ruler_alertmanager_client_oauth2_client_id" json:"ruler_alertmanager_client_oauth2_client_id" category:"experimental" doc:"nocli|description=OAuth2 client ID used to authenticate the notifications of the tenant sent to the Alertmanager, using the client credentials grant. OAuth2 is enabled when the client ID is set, and replaces the basic authentication."`
+	RulerAlertmanagerClientOAuth2ClientSecret Secret `yaml:"ruler_alertmanager_client_oauth2_client_secret" json:"ruler_alertmanager_client_oauth2_client_secret" category:"experimental" doc:"nocli|description=OAuth2 client secret used to authenticate the notifications of the tenant."`
+	RulerAlertmanagerClientOAuth2TokenURL     string `yaml:"ruler_alertmanager_client_oauth2_token_url" json:"ruler_alertmanager_client_oauth2_token_url" category:"experimental" doc:"nocli|description=URL of the OAuth2 token endpoint used to authenticate the notifications of the tenant."`
+	RulerAlertmanagerClientOAuth2Scopes       string `yaml:"ruler_alertmanager_client_oauth2_scopes" json:"ruler_alertmanager_client_oauth2_scopes" category:"experimental" doc:"nocli|description=Comma-separated list of OAuth2 scopes requested to authenticate the notifications of the tenant."`
+
 	// Store-gateway.
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
 
@@ -172,6 +222,34 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The tenant's shard size when sharding is used by ruler. Value of 0 disables shuffle sharding for the tenant, and tenant rules will be sharded across all ruler replicas.")
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 20, "Maximum number of rules per rule group per-tenant. 0 to disable.")
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 70, "Maximum number of rule groups per-tenant. 0 to disable.")
+	f.BoolVar(&l.RulerAlertsSeriesEnabled, "ruler.alerts-series-enabled", true, "Write the ALERTS and ALERTS_FOR_STATE series of the tenant's alerting rules, like Prometheus does. The ALERTS_FOR_STATE series are used to restore the state of alerts with a 'for' duration when a rule group is loaded by a ruler, and the ALERTS series are used to record the alert history.")
+	f.BoolVar(&l.RulerEvaluationMetricsEnabled, "ruler.evaluation-metrics-enabled", false, "Write the metrics of the evaluations of the tenant's rule groups, such as mimir_rule_group_last_duration_seconds, to the tenant's own series, so that the tenant can alert on its rule groups evaluating slowly or failing. The metrics of an evaluation are written when the next evaluation of the rule group starts.")
+	f.Float64Var(&l.RulerConfigAPIWriteRateLimit, "ruler.config-api-write-rate-limit", 0, "Per-tenant rate limit of the requests of the ruler configuration API changing the rule groups, such as creating, deleting or restoring rule groups, in requests per second. The requests exceeding the limit are rejected with a 429 response having a Retry-After header. 0 to disable.")
+	f.IntVar(&l.RulerConfigAPIWriteRateLimitBurst, "ruler.config-api-write-rate-limit-burst", 10, "Per-tenant allowed burst of the requests of the ruler configuration API changing the rule groups.")
+	f.StringVar(&l.RulerEvaluationPool, "ruler.evaluation-pool", "", "Pool of rulers evaluating the rule groups of the tenant, configured on the rulers with -ruler.ring.pool. The rule groups of a tenant assigned to a pool without rulers aren't evaluated. Empty for the default pool.")
+	f.Var(&l.RulerPollInterval, "ruler.tenant-poll-interval", "How frequently the rulers poll the rule storage for the changes of the tenant's rule groups, instead of -ruler.poll-interval. A shorter interval propagates the changes faster, at the cost of more requests to the rule storage. The interval is checked when the rulers poll the rule storage for the changes of any tenant, and the new tenants are polled at the next check. 0 to use -ruler.poll-interval.")
+	f.StringVar(&l.RulerQueryBackendURL, "ruler.query-backend-url", "", "URL of an external Prometheus-compatible query API, such as a Prometheus server or a Thanos querier, evaluating the expressions of the tenant's rules instead of the read path of Mimir. The results of the recording rules are still written to Mimir, and the state of the alerts is still restored from Mimir. The tenant ID isn't sent to the external API. Empty to evaluate the expressions with the read path of Mimir.")
+	f.StringVar(&l.RulerQueryBackendBasicAuthUsername, "ruler.query-backend-basic-auth-username", "", "Username of the HTTP basic authentication of the requests to the external query API of the tenant.")
+	f.Var(&l.RulerQueryBackendBasicAuthPassword, "ruler.query-backend-basic-auth-password", "Password of the HTTP basic authentication of the requests to the external query API of the tenant.")
+	f.Var(&l.RulerQueryBackendBearerToken, "ruler.query-backend-bearer-token", "Bearer token authenticating the requests to the external query API of the tenant. It replaces the basic authentication.")
+	f.StringVar(&l.RulerFederationAllowedMetrics, "ruler.federation-allowed-metrics", "", "Regular expression, anchored at both ends, matching the metric names of the tenant that the federated rule groups of other tenants can read when the tenant is one of their source tenants. Empty to allow all the metrics.")
+	f.StringVar(&l.RulerFederationBlockedMetrics, "ruler.federation-blocked-metrics", "", "Regular expression, anchored at both ends, matching the metric names of the tenant that the federated rule groups of other tenants can't read when the tenant is one of their source tenants. It applies after -ruler.federation-allowed-metrics. Empty to block no metrics.")
+	f.Var(&l.RulerFederationDestTenants, "ruler.federation-dest-tenants", "Comma-separated list of the tenants the recording rules of the tenant's federated rule groups can write their series to, with the rule groups' 'dest_tenants' field. It's checked when a rule group is created and when its series are written. Empty to allow no other tenant than the tenant itself.")
+	f.StringVar(&l.RulerAlertGeneratorURL, "ruler.alert-generator-url", "", "Template of the external URL prefixing the generator URL of the alerts sent by the tenant's alerting rules, for example the URL of the tenant's Grafana instance. The template can reference the namespace and the name of the rule group of the alert, path-escaped, as {{ .Namespace }} and {{ .Group }}. Empty to use -ruler.external.url.")
+	f.IntVar(&l.RulerNotificationQueueCapacity, "ruler.notification-queue-capacity", 10000, "Capacity of the queue for notifications to be sent to the Alertmanager. Changes are applied when the notifier of the tenant is created.")
+	_ = l.RulerNotificationTimeout.Set("10s")
+	f.Var(&l.RulerNotificationTimeout, "ruler.notification-timeout", "HTTP timeout duration when sending notifications to the Alertmanager. The timeout includes the retries.")
+	f.IntVar(&l.RulerNotificationMaxRetries, "ruler.notification-max-retries", 0, "Maximum number of retries when sending notifications to the Alertmanager fails because of a network error, a 5xx or a 429 response. 0 to disable retries.")
+	f.Float64Var(&l.RulerNotificationRateLimit, "ruler.notification-rate-limit", 0, "Per-tenant rate limit of the notifications sent to the Alertmanager, in notifications per second. Notifications exceeding the limit are dropped. 0 to disable.")
+	f.IntVar(&l.RulerNotificationRateLimitBurst, "ruler.notification-rate-limit-burst", 1000, "Per-tenant allowed burst of the notifications sent to the Alertmanager.")
+	f.Var(&l.RulerNotificationDeduplicationWindow, "ruler.notification-deduplication-window", "Per-tenant window within which a notification identical to one already sent to the Alertmanager is dropped. Notifications are identical when they are for the same alert, with the same annotations, start time and state. The window should be lower than the time after which the Alertmanager resolves an alert whose notification is not resent, which is 4 times the greater of the rule group evaluation interval and -ruler.resend-delay. 0 to disable.")
+	f.IntVar(&l.RulerOnDemandEvaluationsPerMinute, "ruler.on-demand-evaluations-per-minute", 0, "Maximum number of on-demand rule group evaluations per minute per-tenant. 0 to disable the on-demand evaluation API for the tenant.")
+	f.IntVar(&l.RulerMaxRecordingRuleLabels, "ruler.max-recording-rule-labels", 0, "Maximum number of labels that each recording rule of the tenant can add to its results with its labels block. The labels prefixed with __, such as __tenant_id__, are reserved and can't be added by recording rules. 0 to disable.")
+	f.IntVar(&l.RulerMaxAlertsPerRule, "ruler.max-alerts-per-rule", 0, "Maximum number of simultaneously active alerts of each alerting rule of the tenant. The alerts beyond the limit are dropped, keeping the alerts already active, and the number of alerts dropped at the latest evaluation of the rule is returned by the rules API. 0 to disable.")
+	f.IntVar(&l.RulerWriteBatchSize, "ruler.write-batch-size", 0, "Maximum number of series in each write request of the results of the rule evaluations of the tenant. When greater than 0, the results of the rule groups of the tenant evaluated around the same time are batched in the same write request, which is sent once it reaches this number of series or after -ruler.write-batch-flush-timeout. The rule evaluations don't wait for the write requests of the batches, whose failures are logged and tracked by the cortex_ruler_write_requests_failed_total metric instead of failing the rule evaluations: the health and the last error of the rules don't reflect them. The pending batch is written when the ruler stops, and waited for within -ruler.shutdown-grace-period. 0 to write the results of each rule evaluation in a separate request.")
+	_ = l.RulerWriteBatchFlushTimeout.Set("100ms")
+	f.Var(&l.RulerWriteBatchFlushTimeout, "ruler.write-batch-flush-timeout", "Maximum time the results of the rule evaluations of the tenant wait for other results to be batched with, when -ruler.write-batch-size is greater than 0.")
+	f.IntVar(&l.RulerMaxConcurrentQueries, "ruler.max-concurrent-queries", 0, "Maximum number of queries that the rule evaluations of the tenant can run concurrently on each ruler. The queries exceeding the limit wait for a running query of the tenant to complete, so that the tenants with many rules don't delay the rule evaluations of the other tenants. 0 to disable.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. 0 to disable.")
 	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards to use when splitting blocks. 0 to disable splitting.")
@@ -488,11 +566,206 @@ func (o *Overrides) RulerMaxRulesPerRuleGroup(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRulesPerRuleGroup
 }
 
+// RulerMaxAlertsPerRule returns the maximum number of active alerts of each alerting rule for a given user.
+func (o *Overrides) RulerMaxAlertsPerRule(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxAlertsPerRule
+}
+
+// RulerMaxRecordingRuleLabels returns the maximum number of labels added by each recording rule for a given user.
+func (o *Overrides) RulerMaxRecordingRuleLabels(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxRecordingRuleLabels
+}
+
 // RulerMaxRuleGroupsPerTenant returns the maximum number of rule groups for a given user.
 func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerAlertsSeriesEnabled returns whether the ALERTS and ALERTS_FOR_STATE series should be written for a given user.
+func (o *Overrides) RulerAlertsSeriesEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).RulerAlertsSeriesEnabled
+}
+
+// RulerEvaluationMetricsEnabled returns whether the metrics of the rule group evaluations should be written for a given user.
+func (o *Overrides) RulerEvaluationMetricsEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).RulerEvaluationMetricsEnabled
+}
+
+// RulerNotificationQueueCapacity returns the capacity of the queue for notifications to be sent to the Alertmanager for a given user.
+func (o *Overrides) RulerNotificationQueueCapacity(userID string) int {
+	return o.getOverridesForUser(userID).RulerNotificationQueueCapacity
+}
+
+// RulerNotificationTimeout returns the timeout when sending notifications to the Alertmanager for a given user.
+func (o *Overrides) RulerNotificationTimeout(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerNotificationTimeout)
+}
+
+// RulerNotificationMaxRetries returns the maximum number of retries when sending notifications to the Alertmanager for a given user.
+func (o *Overrides) RulerNotificationMaxRetries(userID string) int {
+	return o.getOverridesForUser(userID).RulerNotificationMaxRetries
+}
+
+// RulerNotificationRateLimit returns the rate limit of notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerNotificationRateLimit(userID string) float64 {
+	return o.getOverridesForUser(userID).RulerNotificationRateLimit
+}
+
+// RulerNotificationRateLimitBurst returns the burst of notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerNotificationRateLimitBurst(userID string) int {
+	return o.getOverridesForUser(userID).RulerNotificationRateLimitBurst
+}
+
+// RulerNotificationDeduplicationWindow returns the window within which identical notifications are deduplicated for a given user.
+func (o *Overrides) RulerNotificationDeduplicationWindow(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerNotificationDeduplicationWindow)
+}
+
+// RulerQueryBackendURL returns the URL of the external query API evaluating the rules of a given user.
+func (o *Overrides) RulerQueryBackendURL(userID string) string {
+	return o.getOverridesForUser(userID).RulerQueryBackendURL
+}
+
+// RulerQueryBackendBasicAuthUsername returns the basic authentication username of the external query API for a given user.
+func (o *Overrides) RulerQueryBackendBasicAuthUsername(userID string) string {
+	return o.getOverridesForUser(userID).RulerQueryBackendBasicAuthUsername
+}
+
+// RulerQueryBackendBasicAuthPassword returns the basic authentication password of the external query API for a given user.
+func (o *Overrides) RulerQueryBackendBasicAuthPassword(userID string) string {
+	return string(o.getOverridesForUser(userID).RulerQueryBackendBasicAuthPassword)
+}
+
+// RulerQueryBackendBearerToken returns the bearer token of the external query API for a given user.
+func (o *Overrides) RulerQueryBackendBearerToken(userID string) string {
+	return string(o.getOverridesForUser(userID).RulerQueryBackendBearerToken)
+}
+
+// RulerFederationAllowedMetrics returns the regular expression of the metric names of a given user readable by the federated rule groups of other users.
+func (o *Overrides) RulerFederationAllowedMetrics(userID string) string {
+	return o.getOverridesForUser(userID).RulerFederationAllowedMetrics
+}
+
+// RulerFederationBlockedMetrics returns the regular expression of the metric names of a given user not readable by the federated rule groups of other users.
+func (o *Overrides) RulerFederationBlockedMetrics(userID string) string {
+	return o.getOverridesForUser(userID).RulerFederationBlockedMetrics
+}
+
+// RulerFederationDestTenants returns the tenants the federated rule groups of a given user can write the series of their recording rules to.
+func (o *Overrides) RulerFederationDestTenants(userID string) []string {
+	return o.getOverridesForUser(userID).RulerFederationDestTenants
+}
+
+// RulerAlertmanagerClientTLSCertPath returns the per-tenant override of the client certificate used to send notifications to the Alertmanager.
+func (o *Overrides) RulerAlertmanagerClientTLSCertPath(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientTLSCertPath
+}
+
+// RulerAlertmanagerClientTLSKeyPath returns the per-tenant override of the client key used to send notifications to the Alertmanager.
+func (o *Overrides) RulerAlertmanagerClientTLSKeyPath(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientTLSKeyPath
+}
+
+// RulerAlertmanagerClientTLSCAPath returns the per-tenant override of the CA used to validate the Alertmanager server certificate.
+func (o *Overrides) RulerAlertmanagerClientTLSCAPath(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientTLSCAPath
+}
+
+// RulerAlertmanagerClientTLSServerName returns the per-tenant override of the expected name on the Alertmanager server certificate.
+func (o *Overrides) RulerAlertmanagerClientTLSServerName(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientTLSServerName
+}
+
+// RulerAlertmanagerClientSigV4Region returns the AWS region used to sign the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientSigV4Region(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientSigV4Region
+}
+
+// RulerAlertmanagerClientSigV4AccessKey returns the AWS access key ID used to sign the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientSigV4AccessKey(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientSigV4AccessKey
+}
+
+// RulerAlertmanagerClientSigV4SecretKey returns the AWS secret access key used to sign the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientSigV4SecretKey(userID string) string {
+	return string(o.getOverridesForUser(userID).RulerAlertmanagerClientSigV4SecretKey)
+}
+
+// RulerAlertmanagerClientSigV4Profile returns the AWS profile used to sign the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientSigV4Profile(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientSigV4Profile
+}
+
+// RulerAlertmanagerClientSigV4RoleARN returns the AWS role ARN assumed to sign the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientSigV4RoleARN(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientSigV4RoleARN
+}
+
+// RulerAlertmanagerClientOAuth2ClientID returns the OAuth2 client ID used to authenticate the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientOAuth2ClientID(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientOAuth2ClientID
+}
+
+// RulerAlertmanagerClientOAuth2ClientSecret returns the OAuth2 client secret used to authenticate the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientOAuth2ClientSecret(userID string) string {
+	return string(o.getOverridesForUser(userID).RulerAlertmanagerClientOAuth2ClientSecret)
+}
+
+// RulerAlertmanagerClientOAuth2TokenURL returns the OAuth2 token URL used to authenticate the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientOAuth2TokenURL(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientOAuth2TokenURL
+}
+
+// RulerAlertmanagerClientOAuth2Scopes returns the comma-separated OAuth2 scopes requested to authenticate the notifications sent to the Alertmanager for a given user.
+func (o *Overrides) RulerAlertmanagerClientOAuth2Scopes(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientOAuth2Scopes
+}
+
+// RulerOnDemandEvaluationsPerMinute returns the maximum number of on-demand rule group evaluations per minute for a given user.
+func (o *Overrides) RulerOnDemandEvaluationsPerMinute(userID string) int {
+	return o.getOverridesForUser(userID).RulerOnDemandEvaluationsPerMinute
+}
+
+// RulerConfigAPIWriteRateLimit returns the rate limit of the requests of the ruler configuration API changing the rule groups of a given user.
+func (o *Overrides) RulerConfigAPIWriteRateLimit(userID string) float64 {
+	return o.getOverridesForUser(userID).RulerConfigAPIWriteRateLimit
+}
+
+// RulerConfigAPIWriteRateLimitBurst returns the burst of the requests of the ruler configuration API changing the rule groups of a given user.
+func (o *Overrides) RulerConfigAPIWriteRateLimitBurst(userID string) int {
+	return o.getOverridesForUser(userID).RulerConfigAPIWriteRateLimitBurst
+}
+
+// RulerAlertGeneratorURL returns the template of the external URL of the alerts of a given user.
+func (o *Overrides) RulerAlertGeneratorURL(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertGeneratorURL
+}
+
+// RulerEvaluationPool returns the pool of rulers evaluating the rule groups of a given user.
+func (o *Overrides) RulerEvaluationPool(userID string) string {
+	return o.getOverridesForUser(userID).RulerEvaluationPool
+}
+
+// RulerPollInterval returns how frequently the rule storage is polled for the changes of the rule groups of a given user.
+func (o *Overrides) RulerPollInterval(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerPollInterval)
+}
+
+// RulerWriteBatchSize returns the maximum number of series in each write request of the results of the rule evaluations of a given user.
+func (o *Overrides) RulerWriteBatchSize(userID string) int {
+	return o.getOverridesForUser(userID).RulerWriteBatchSize
+}
+
+// RulerWriteBatchFlushTimeout returns the maximum time the results of the rule evaluations of a given user wait to be batched.
+func (o *Overrides) RulerWriteBatchFlushTimeout(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerWriteBatchFlushTimeout)
+}
+
+// RulerMaxConcurrentQueries returns the maximum number of queries that the rule evaluations of a given user can run concurrently.
+func (o *Overrides) RulerMaxConcurrentQueries(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxConcurrentQueries
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
@@ -109,6 +109,33 @@ func TestLimitsLoadingFromJson(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestLimitsSecretsAreMasked(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	inp := `
+ruler_query_backend_basic_auth_password: query-backend-password
+ruler_query_backend_bearer_token: query-backend-token
+ruler_alertmanager_client_sigv4_secret_key: sigv4-secret-key
+ruler_alertmanager_client_oauth2_client_secret: oauth2-client-secret
+`
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(inp), &l))
+
+	ov, err := NewOverrides(l, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "query-backend-password", ov.RulerQueryBackendBasicAuthPassword("user"))
+	assert.Equal(t, "query-backend-token", ov.RulerQueryBackendBearerToken("user"))
+	assert.Equal(t, "sigv4-secret-key", ov.RulerAlertmanagerClientSigV4SecretKey("user"))
+	assert.Equal(t, "oauth2-client-secret", ov.RulerAlertmanagerClientOAuth2ClientSecret("user"))
+
+	out, err := yaml.Marshal(l)
+	require.NoError(t, err)
+	for _, secret := range []string{"query-backend-password", "query-backend-token", "sigv4-secret-key", "oauth2-client-secret"} {
+		assert.NotContains(t, string(out), secret)
+	}
+	assert.Contains(t, string(out), "ruler_alertmanager_client_oauth2_client_secret: <secret>")
+}
+
 func TestLimitsTagsYamlMatchJson(t *testing.T) {
 	limits := reflect.TypeOf(Limits{})
 	n := limits.NumField()
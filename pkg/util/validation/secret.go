@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+)
+
+const secretPlaceholder = "<secret>"
+
+// Secret is a string limit holding a credential. It's printed as <secret> when not empty,
+// so that the credential isn't exposed by the runtime config and flags endpoints.
+type Secret string
+
+// String implements flag.Value.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secretPlaceholder
+}
+
+// Set implements flag.Value.
+func (s *Secret) Set(v string) error {
+	*s = Secret(v)
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSecret(t *testing.T) {
+	type cfg struct {
+		Secret Secret `yaml:"secret" json:"secret"`
+	}
+
+	var c cfg
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	fs.Var(&c.Secret, "secret", "")
+	require.NoError(t, fs.Parse([]string{"-secret", "from-flag"}))
+	assert.Equal(t, Secret("from-flag"), c.Secret)
+	assert.Equal(t, "<secret>", fs.Lookup("secret").Value.String())
+
+	require.NoError(t, yaml.Unmarshal([]byte("secret: from-yaml"), &c))
+	assert.Equal(t, Secret("from-yaml"), c.Secret)
+
+	out, err := yaml.Marshal(c)
+	require.NoError(t, err)
+	assert.Equal(t, "secret: <secret>\n", string(out))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"secret": "from-json"}`), &c))
+	assert.Equal(t, Secret("from-json"), c.Secret)
+
+	out, err = json.Marshal(c)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"secret":"<secret>"}`, string(out))
+
+	// Empty secrets are kept empty.
+	out, err = yaml.Marshal(cfg{})
+	require.NoError(t, err)
+	assert.Equal(t, "secret: \"\"\n", string(out))
+}
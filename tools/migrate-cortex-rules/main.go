@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/cortex"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+const (
+	sourceBucket   = "bucket"
+	sourceConfigDB = "configdb"
+)
+
+type config struct {
+	source          string
+	cortexStorage   bucket.Config
+	configDBURL     string
+	configDBTimeout time.Duration
+	storage         rulestore.Config
+	dryRun          bool
+}
+
+func main() {
+	ctx := context.Background()
+	logger := log.WithPrefix(log.NewLogfmtLogger(os.Stderr), "time", log.DefaultTimestampUTC)
+
+	cfg := parseFlags()
+	if err := cfg.storage.Validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid rule storage configuration.", "err", err)
+		os.Exit(1)
+	}
+
+	var (
+		groups map[string]rulespb.RuleGroupList
+		err    error
+	)
+	switch cfg.source {
+	case sourceBucket:
+		if err := cfg.cortexStorage.Validate(); err != nil {
+			level.Error(logger).Log("msg", "Invalid Cortex storage configuration.", "err", err)
+			os.Exit(1)
+		}
+		cortexBucket, err := bucket.NewClient(ctx, cfg.cortexStorage, "migrate-cortex-rules-source", logger, nil)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to create the Cortex bucket client.", "err", err)
+			os.Exit(1)
+		}
+		groups, err = cortex.ReadBucket(ctx, cortexBucket, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to read the Cortex rule groups.", "err", err)
+			os.Exit(1)
+		}
+	case sourceConfigDB:
+		if cfg.configDBURL == "" {
+			level.Error(logger).Log("msg", "The URL of the Cortex configs service is required.")
+			os.Exit(1)
+		}
+		groups, err = cortex.ReadConfigDB(ctx, &http.Client{Timeout: cfg.configDBTimeout}, cfg.configDBURL, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to read the Cortex rule groups.", "err", err)
+			os.Exit(1)
+		}
+	default:
+		level.Error(logger).Log("msg", "Unsupported source.", "source", cfg.source)
+		os.Exit(1)
+	}
+
+	bkt, err := bucket.NewClient(ctx, cfg.storage.Config, "migrate-cortex-rules", logger, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to create the bucket client.", "err", err)
+		os.Exit(1)
+	}
+	var rulesBucket objstore.Bucket = bkt
+	if cfg.storage.ClientSideEncryption.KeysFile != "" {
+		keys, err := bucketclient.LoadEncryptionKeys(cfg.storage.ClientSideEncryption.KeysFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load the encryption keys.", "err", err)
+			os.Exit(1)
+		}
+		rulesBucket = bucketclient.NewEncryptedBucket(rulesBucket, keys, cfg.storage.ClientSideEncryption.AllowUnencrypted)
+	}
+	store := bucketclient.NewBucketRuleStore(rulesBucket, nil, logger).WithLayout(bucketclient.NewLayout(cfg.storage.Layout))
+
+	if err := cortex.Migrate(ctx, groups, store, cfg.dryRun, logger); err != nil {
+		level.Error(logger).Log("msg", "Failed to migrate the Cortex rule groups.", "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "Migrated the Cortex rule groups.", "tenants", len(groups))
+}
+
+func parseFlags() config {
+	var cfg config
+
+	f := flag.NewFlagSet("migrate-cortex-rules", flag.ExitOnError)
+	f.SetOutput(os.Stdout)
+	f.Usage = func() {
+		fmt.Println("This tool copies the rule groups of the tenants of a Cortex rule storage, or of the Cortex configs service, to the Mimir ruler storage.")
+		fmt.Println("The Cortex object storage is configured with the -cortex-storage.* flags, and the Mimir ruler storage with the same -ruler-storage.* flags as Mimir.")
+		fmt.Println("The rule files of the configs service are migrated to namespaces named after them.")
+		fmt.Println("Run the tool while Cortex is running, then again right before switching the rulers from Cortex to Mimir, to copy the rule groups updated meanwhile.")
+		fmt.Println("The rule groups deleted from Cortex meanwhile are not deleted from the Mimir ruler storage.")
+		fmt.Println("")
+		fmt.Println("Usage:")
+		fmt.Println("        migrate-cortex-rules -source bucket -cortex-storage.backend <backend> -ruler-storage.backend <backend> [-dry-run]")
+		fmt.Println("        migrate-cortex-rules -source configdb -configdb.url <url> -ruler-storage.backend <backend> [-dry-run]")
+		fmt.Println("")
+		f.PrintDefaults()
+	}
+
+	f.StringVar(&cfg.source, "source", sourceBucket, fmt.Sprintf("Where to read the Cortex rule groups from. Supported values are: %s, %s.", sourceBucket, sourceConfigDB))
+	cfg.cortexStorage.RegisterFlagsWithPrefix("cortex-storage.", f)
+	f.StringVar(&cfg.configDBURL, "configdb.url", "", "URL of the Cortex configs service.")
+	f.DurationVar(&cfg.configDBTimeout, "configdb.timeout", 5*time.Second, "Timeout of the requests to the Cortex configs service.")
+	cfg.storage.RegisterFlags(f)
+	f.BoolVar(&cfg.dryRun, "dry-run", false, "Don't store the rule groups, just print the intentions.")
+
+	if err := f.Parse(os.Args[1:]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return cfg
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+type config struct {
+	storage rulestore.Config
+	dryRun  bool
+}
+
+func main() {
+	ctx := context.Background()
+	logger := log.WithPrefix(log.NewLogfmtLogger(os.Stderr), "time", log.DefaultTimestampUTC)
+
+	cfg := parseFlags()
+	if err := cfg.storage.Validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid rule storage configuration.", "err", err)
+		os.Exit(1)
+	}
+
+	bkt, err := bucket.NewClient(ctx, cfg.storage.Config, "migrate-rules-format", logger, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to create the bucket client.", "err", err)
+		os.Exit(1)
+	}
+
+	var rulesBucket objstore.Bucket = bkt
+	if cfg.storage.ClientSideEncryption.KeysFile != "" {
+		keys, err := bucketclient.LoadEncryptionKeys(cfg.storage.ClientSideEncryption.KeysFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load the encryption keys.", "err", err)
+			os.Exit(1)
+		}
+		rulesBucket = bucketclient.NewEncryptedBucket(rulesBucket, keys, cfg.storage.ClientSideEncryption.AllowUnencrypted)
+	}
+
+	store := bucketclient.NewBucketRuleStore(rulesBucket, nil, logger).WithLayout(bucketclient.NewLayout(cfg.storage.Layout))
+	if err := bucketclient.MigrateFormat(ctx, store, cfg.dryRun, logger); err != nil {
+		level.Error(logger).Log("msg", "Failed to migrate the format of the rule groups.", "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "Migrated the format of the rule groups.", "format_version", rulespb.CurrentFormatVersion)
+}
+
+func parseFlags() config {
+	var cfg config
+
+	f := flag.NewFlagSet("migrate-rules-format", flag.ExitOnError)
+	f.SetOutput(os.Stdout)
+	f.Usage = func() {
+		fmt.Println("This tool rewrites the rule groups of the tenants of the ruler storage stored with an older format version with the format version of this version of Mimir.")
+		fmt.Println("The storage is configured with the same -ruler-storage.* flags as Mimir.")
+		fmt.Println("Run the tool once all the rulers and the configuration API replicas are upgraded, so that the older versions of Mimir refuse to overwrite the rule groups if they're rolled back.")
+		fmt.Println("The rule groups stored with a newer format version and the ones which can't be loaded are left untouched.")
+		fmt.Println("")
+		fmt.Println("Usage:")
+		fmt.Println("        migrate-rules-format -ruler-storage.backend <backend> [-dry-run]")
+		fmt.Println("")
+		f.PrintDefaults()
+	}
+
+	cfg.storage.RegisterFlags(f)
+	f.BoolVar(&cfg.dryRun, "dry-run", false, "Don't rewrite the rule groups, just print the intentions.")
+
+	if err := f.Parse(os.Args[1:]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return cfg
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+type config struct {
+	storage      rulestore.Config
+	destination  rulestore.LayoutConfig
+	deleteSource bool
+	dryRun       bool
+}
+
+func main() {
+	ctx := context.Background()
+	logger := log.WithPrefix(log.NewLogfmtLogger(os.Stderr), "time", log.DefaultTimestampUTC)
+
+	cfg := parseFlags()
+	if err := cfg.storage.Validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid rule storage configuration.", "err", err)
+		os.Exit(1)
+	}
+	if err := cfg.destination.Validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid destination layout.", "err", err)
+		os.Exit(1)
+	}
+
+	bkt, err := bucket.NewClient(ctx, cfg.storage.Config, "migrate-rules-layout", logger, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to create the bucket client.", "err", err)
+		os.Exit(1)
+	}
+
+	// The encrypted objects are decrypted and encrypted again, because they're bound to their object name.
+	var rulesBucket objstore.Bucket = bkt
+	if cfg.storage.ClientSideEncryption.KeysFile != "" {
+		keys, err := bucketclient.LoadEncryptionKeys(cfg.storage.ClientSideEncryption.KeysFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load the encryption keys.", "err", err)
+			os.Exit(1)
+		}
+		rulesBucket = bucketclient.NewEncryptedBucket(rulesBucket, keys, cfg.storage.ClientSideEncryption.AllowUnencrypted)
+	}
+
+	from := bucketclient.NewLayout(cfg.storage.Layout)
+	to := bucketclient.NewLayout(cfg.destination)
+	if err := bucketclient.MigrateLayout(ctx, rulesBucket, from, to, cfg.deleteSource, cfg.dryRun, logger); err != nil {
+		level.Error(logger).Log("msg", "Failed to migrate the rule storage layout.", "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "Migrated the rule storage layout.")
+}
+
+func parseFlags() config {
+	var cfg config
+
+	f := flag.NewFlagSet("migrate-rules-layout", flag.ExitOnError)
+	f.SetOutput(os.Stdout)
+	f.Usage = func() {
+		fmt.Println("This tool copies the rule groups, the trashed rule groups and the write logs of the tenants of the ruler storage from a layout to another.")
+		fmt.Println("The source layout and the storage are configured with the same -ruler-storage.* flags as Mimir, and the destination layout with the -destination.* flags.")
+		fmt.Println("Run the tool, update the layout of the rulers and the configuration API replicas, then run the tool again with -delete-source to copy the rule groups written meanwhile and delete the source objects.")
+		fmt.Println("The deletion time of the trashed rule groups is reset to the time of the copy.")
+		fmt.Println("")
+		fmt.Println("Usage:")
+		fmt.Println("        migrate-rules-layout -ruler-storage.backend <backend> [-ruler-storage.layout <layout>] -destination.layout <layout> [-delete-source] [-dry-run]")
+		fmt.Println("")
+		f.PrintDefaults()
+	}
+
+	cfg.storage.RegisterFlags(f)
+	cfg.destination.RegisterFlagsWithPrefix("destination.", f)
+	f.BoolVar(&cfg.deleteSource, "delete-source", false, "Delete the objects from the source layout once copied.")
+	f.BoolVar(&cfg.dryRun, "dry-run", false, "Don't copy the objects, just print the intentions.")
+
+	if err := f.Parse(os.Args[1:]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return cfg
+}